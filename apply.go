@@ -0,0 +1,265 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultApplyParallelism is the number of steps Apply runs simultaneously by default.
+const DefaultApplyParallelism = 10
+
+// ErrPlanCycle is matched by errors.Is for every *PlanCycleError.
+var ErrPlanCycle = errors.New("plan has a dependency cycle")
+
+// PlanCycleError is returned by Apply for plans with circular dependencies.
+type PlanCycleError struct {
+	// Cycle lists the steps of the cycle, each depending on the next one,
+	// starting and ending with the same step.
+	Cycle []string
+}
+
+func (e *PlanCycleError) Error() string {
+	return fmt.Sprintf("plan has a dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+func (e *PlanCycleError) Is(target error) bool { return target == ErrPlanCycle }
+
+// Step is a node of a plan run by Apply.
+type Step struct {
+	// Name identifies the step in DependsOn of other steps and in the results. Required and unique.
+	Name string
+	// DependsOn lists the steps that must succeed before this one starts.
+	DependsOn []string
+	// Run starts the step. Apply waits for the returned operation, if any.
+	Run func(ctx context.Context) (*operation.Operation, error)
+}
+
+// ApplyOptions configures Apply.
+type ApplyOptions struct {
+	// Parallelism bounds the number of steps running at the same time.
+	// Zero means DefaultApplyParallelism.
+	Parallelism int
+}
+
+// StepStatus is an outcome of a single step of a plan.
+type StepStatus int
+
+const (
+	StepSucceeded StepStatus = iota
+	StepFailed
+	// StepSkipped steps were not started, because a dependency failed or ctx was done.
+	StepSkipped
+)
+
+func (s StepStatus) String() string {
+	switch s {
+	case StepSucceeded:
+		return "succeeded"
+	case StepFailed:
+		return "failed"
+	case StepSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// StepResult is a result of a single step of a plan.
+type StepResult struct {
+	Name   string
+	Status StepStatus
+	// Operation is the operation of the step, nil if Run didn't return one.
+	Operation *operation.Operation
+	// Err is set for StepFailed results, and for StepSkipped ones with the reason of skipping.
+	Err error
+}
+
+// Apply runs the steps of the plan as soon as their dependencies succeeded, with bounded
+// concurrency, and waits for their operations. Dependents of a failed step are skipped
+// while independent steps keep running.
+//
+// The plan is checked before anything runs: unknown or duplicate step names and dependency
+// cycles (*PlanCycleError) fail Apply without results. Otherwise the result has one entry
+// per step, in the order of the plan, and the returned error aggregates all failures.
+func (sdk *SDK) Apply(ctx context.Context, plan []Step, opts ApplyOptions) ([]StepResult, error) {
+	deps, err := planDependencies(plan)
+	if err != nil {
+		return nil, err
+	}
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultApplyParallelism
+	}
+
+	results := make([]StepResult, len(plan))
+	waiting := make([]int, len(plan))
+	dependents := make([][]int, len(plan))
+	var ready []int
+	for i, step := range plan {
+		results[i] = StepResult{Name: step.Name, Status: StepSkipped}
+		waiting[i] = len(deps[i])
+		for _, d := range deps[i] {
+			dependents[d] = append(dependents[d], i)
+		}
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	type finished struct {
+		i   int
+		res StepResult
+	}
+	done := make(chan finished)
+	started := make([]bool, len(plan))
+	running := 0
+	for {
+		for len(ready) > 0 && running < parallelism && ctx.Err() == nil {
+			i := ready[0]
+			ready = ready[1:]
+			started[i] = true
+			running++
+			go func(i int) {
+				done <- finished{i: i, res: runStep(ctx, plan[i])}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+		f := <-done
+		running--
+		results[f.i] = f.res
+		if f.res.Status != StepSucceeded {
+			skipDependents(plan, dependents, results, started, f.i)
+			continue
+		}
+		for _, d := range dependents[f.i] {
+			if waiting[d]--; waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+		// Start steps in the order of the plan.
+		sort.Ints(ready)
+	}
+
+	var errs error
+	for i, res := range results {
+		switch {
+		case res.Status == StepFailed:
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(res.Err, "step %q", res.Name))
+		case !started[i] && res.Err == nil && ctx.Err() != nil:
+			results[i].Err = ctx.Err()
+		}
+	}
+	if errs == nil && ctx.Err() != nil {
+		errs = ctx.Err()
+	}
+	return results, errs
+}
+
+func runStep(ctx context.Context, step Step) StepResult {
+	res := StepResult{Name: step.Name}
+	var op *operation.Operation
+	err := operation.SafeCall("step run", func() (err error) {
+		op, err = step.Run(ctx)
+		return err
+	})
+	if err == nil && op != nil {
+		res.Operation = op
+		err = op.Wait(ctx)
+	}
+	if err != nil {
+		res.Status = StepFailed
+		res.Err = err
+	}
+	return res
+}
+
+// skipDependents marks the steps depending on the failed one, directly or not, as skipped.
+func skipDependents(plan []Step, dependents [][]int, results []StepResult, started []bool, failed int) {
+	queue := append([]int(nil), dependents[failed]...)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if started[i] || results[i].Err != nil {
+			continue
+		}
+		results[i].Err = fmt.Errorf("dependency %q did not succeed", plan[failed].Name)
+		queue = append(queue, dependents[i]...)
+	}
+}
+
+// planDependencies resolves DependsOn names to step indexes and checks the plan is a DAG.
+func planDependencies(plan []Step) ([][]int, error) {
+	index := make(map[string]int, len(plan))
+	for i, step := range plan {
+		if step.Name == "" {
+			return nil, fmt.Errorf("plan step %d: name required", i)
+		}
+		if step.Run == nil {
+			return nil, fmt.Errorf("plan step %q: run required", step.Name)
+		}
+		if _, ok := index[step.Name]; ok {
+			return nil, fmt.Errorf("plan step %q: duplicate name", step.Name)
+		}
+		index[step.Name] = i
+	}
+	deps := make([][]int, len(plan))
+	for i, step := range plan {
+		for _, name := range step.DependsOn {
+			d, ok := index[name]
+			if !ok {
+				return nil, fmt.Errorf("plan step %q: unknown dependency %q", step.Name, name)
+			}
+			deps[i] = append(deps[i], d)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(plan))
+	var path []int
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			var cycle []string
+			for j := len(path) - 1; j >= 0; j-- {
+				cycle = append([]string{plan[path[j]].Name}, cycle...)
+				if path[j] == i {
+					break
+				}
+			}
+			return &PlanCycleError{Cycle: append(cycle, plan[i].Name)}
+		}
+		state[i] = visiting
+		path = append(path, i)
+		for _, d := range deps[i] {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range plan {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
@@ -0,0 +1,235 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// stepLog records the order in which steps ran and the peak number of concurrent steps.
+type stepLog struct {
+	mu      sync.Mutex
+	order   []string
+	running int
+	peak    int
+}
+
+func (l *stepLog) step(name string, deps []string, run func() (*operation.Operation, error)) Step {
+	return Step{Name: name, DependsOn: deps, Run: func(ctx context.Context) (*operation.Operation, error) {
+		l.mu.Lock()
+		l.order = append(l.order, name)
+		l.running++
+		if l.running > l.peak {
+			l.peak = l.running
+		}
+		l.mu.Unlock()
+		defer func() {
+			l.mu.Lock()
+			l.running--
+			l.mu.Unlock()
+		}()
+		time.Sleep(time.Millisecond)
+		return run()
+	}}
+}
+
+func (l *stepLog) ran() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.order...)
+}
+
+func ok() (*operation.Operation, error) { return nil, nil }
+
+func doneOperation(failed bool) func() (*operation.Operation, error) {
+	return func() (*operation.Operation, error) {
+		p := &dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_DONE}
+		if failed {
+			p.Error = &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"}
+		}
+		return operation.New(nil, p), nil
+	}
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestApply_Order(t *testing.T) {
+	var log stepLog
+	plan := []Step{
+		log.step("transfer", []string{"clickhouse", "kafka"}, ok),
+		log.step("clickhouse", []string{"network"}, doneOperation(false)),
+		log.step("kafka", []string{"network"}, ok),
+		log.step("network", nil, ok),
+	}
+	results, err := (&SDK{}).Apply(context.Background(), plan, ApplyOptions{})
+	require.NoError(t, err)
+
+	ran := log.ran()
+	require.Len(t, ran, 4)
+	assert.Equal(t, "network", ran[0])
+	assert.Equal(t, "transfer", ran[3])
+	for i, res := range results {
+		assert.Equal(t, plan[i].Name, res.Name)
+		assert.Equal(t, StepSucceeded, res.Status, res.Name)
+	}
+	assert.NotNil(t, results[1].Operation)
+}
+
+func TestApply_Parallelism(t *testing.T) {
+	var log stepLog
+	var plan []Step
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		plan = append(plan, log.step(name, nil, func() (*operation.Operation, error) {
+			time.Sleep(5 * time.Millisecond)
+			return nil, nil
+		}))
+	}
+	_, err := (&SDK{}).Apply(context.Background(), plan, ApplyOptions{Parallelism: 2})
+	require.NoError(t, err)
+	assert.Len(t, log.ran(), 6)
+	assert.Equal(t, 2, log.peak)
+}
+
+func TestApply_FailureSkipsDependents(t *testing.T) {
+	var log stepLog
+	plan := []Step{
+		log.step("network", nil, ok),
+		log.step("clickhouse", []string{"network"}, doneOperation(true)),
+		log.step("kafka", []string{"network"}, ok),
+		log.step("transfer", []string{"clickhouse", "kafka"}, ok),
+		log.step("dashboard", []string{"transfer"}, ok),
+		log.step("topic", []string{"kafka"}, ok),
+	}
+	results, err := (&SDK{}).Apply(context.Background(), plan, ApplyOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `step "clickhouse"`)
+	assert.Contains(t, err.Error(), "boom")
+
+	statuses := map[string]StepStatus{}
+	for _, res := range results {
+		statuses[res.Name] = res.Status
+	}
+	assert.Equal(t, map[string]StepStatus{
+		"network":    StepSucceeded,
+		"clickhouse": StepFailed,
+		"kafka":      StepSucceeded,
+		"transfer":   StepSkipped,
+		"dashboard":  StepSkipped,
+		"topic":      StepSucceeded,
+	}, statuses)
+	assert.EqualError(t, results[3].Err, `dependency "clickhouse" did not succeed`)
+	assert.EqualError(t, results[4].Err, `dependency "clickhouse" did not succeed`)
+	assert.Equal(t, -1, indexOf(log.ran(), "transfer"))
+}
+
+func TestApply_RunError(t *testing.T) {
+	var log stepLog
+	plan := []Step{
+		log.step("a", nil, func() (*operation.Operation, error) { return nil, errors.New("rejected") }),
+		log.step("b", []string{"a"}, ok),
+	}
+	results, err := (&SDK{}).Apply(context.Background(), plan, ApplyOptions{})
+	require.Error(t, err)
+	assert.Equal(t, StepFailed, results[0].Status)
+	assert.EqualError(t, results[0].Err, "rejected")
+	assert.Equal(t, StepSkipped, results[1].Status)
+}
+
+func TestApply_RunPanic(t *testing.T) {
+	var warned []error
+	operation.SetWarningHandler(func(err error) { warned = append(warned, err) })
+	t.Cleanup(func() { operation.SetWarningHandler(nil) })
+	var log stepLog
+	plan := []Step{
+		log.step("a", nil, func() (*operation.Operation, error) { panic("boom") }),
+		log.step("b", nil, ok),
+	}
+	results, err := (&SDK{}).Apply(context.Background(), plan, ApplyOptions{Parallelism: 1})
+	require.Error(t, err)
+	assert.Equal(t, StepFailed, results[0].Status)
+	assert.ErrorIs(t, results[0].Err, operation.ErrCallbackPanicked)
+	assert.Equal(t, StepSucceeded, results[1].Status)
+	assert.Len(t, warned, 1)
+}
+
+func TestApply_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var log stepLog
+	plan := []Step{
+		log.step("a", nil, func() (*operation.Operation, error) {
+			cancel()
+			return nil, nil
+		}),
+		log.step("b", []string{"a"}, ok),
+	}
+	results, err := (&SDK{}).Apply(ctx, plan, ApplyOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, StepSucceeded, results[0].Status)
+	assert.Equal(t, StepSkipped, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, context.Canceled)
+	assert.Equal(t, []string{"a"}, log.ran())
+}
+
+func TestApply_InvalidPlan(t *testing.T) {
+	var log stepLog
+	for name, tc := range map[string]struct {
+		plan []Step
+		err  string
+	}{
+		"cycle": {
+			plan: []Step{
+				log.step("network", nil, ok),
+				log.step("a", []string{"network", "c"}, ok),
+				log.step("b", []string{"a"}, ok),
+				log.step("c", []string{"b"}, ok),
+			},
+			err: "plan has a dependency cycle: a -> c -> b -> a",
+		},
+		"self": {
+			plan: []Step{log.step("a", []string{"a"}, ok)},
+			err:  "plan has a dependency cycle: a -> a",
+		},
+		"unknown dependency": {
+			plan: []Step{log.step("a", []string{"b"}, ok)},
+			err:  `plan step "a": unknown dependency "b"`,
+		},
+		"duplicate": {
+			plan: []Step{log.step("a", nil, ok), log.step("a", nil, ok)},
+			err:  `plan step "a": duplicate name`,
+		},
+		"no name": {
+			plan: []Step{log.step("", nil, ok)},
+			err:  "plan step 0: name required",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			results, err := (&SDK{}).Apply(context.Background(), tc.plan, ApplyOptions{})
+			assert.EqualError(t, err, tc.err)
+			assert.Nil(t, results)
+		})
+	}
+	assert.Empty(t, log.ran(), "nothing runs for invalid plans")
+
+	_, err := (&SDK{}).Apply(context.Background(), []Step{log.step("a", []string{"a"}, ok)}, ApplyOptions{})
+	var cycle *PlanCycleError
+	require.True(t, errors.As(err, &cycle))
+	assert.ErrorIs(t, err, ErrPlanCycle)
+	assert.Equal(t, []string{"a", "a"}, cycle.Cycle)
+}
@@ -2,7 +2,10 @@ package dcsdk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,6 +30,7 @@ func NewIAMTokenMiddleware(authenticator Authenticator, now func() time.Time) *I
 		now:            now,
 		authenticator:  authenticator,
 		subjectToState: map[authSubject]iamTokenState{},
+		jitter:         equalJitter,
 	}
 }
 
@@ -38,12 +42,37 @@ type IamTokenMiddleware struct {
 	// mutex guards conn and currentState, and excludes multiple simultaneous token updates
 	mutex          sync.RWMutex
 	subjectToState map[authSubject]iamTokenState
+
+	// refresh is the config of WithRefreshBackoff.
+	refresh CredentialsRefreshConfig
+	// jitter may be replaced in tests
+	jitter func(time.Duration) time.Duration
 }
 
 type iamTokenState struct {
 	token     string
 	expiresAt time.Time
 	version   int
+	// credentials is the version of the credentials the token was created with.
+	credentials int
+	// backoff is the state of the failed refreshes since the token was created.
+	backoff refreshBackoff
+}
+
+// credentialsVersioner is implemented by authenticators whose credentials change at runtime,
+// see SDK.UpdateConfig. Tokens cached for other versions of the credentials are not used.
+type credentialsVersioner interface {
+	credentialsVersion(ctx context.Context) int
+}
+
+func (c *IamTokenMiddleware) credentialsVersion(ctx context.Context, subject authSubject) int {
+	if _, ok := subject.(mainSubject); !ok {
+		return 0
+	}
+	if v, ok := c.authenticator.(credentialsVersioner); ok {
+		return v.credentialsVersion(ctx)
+	}
+	return 0
 }
 
 func WithAuthAsServiceAccount(serviceAccountID string) grpc.CallOption {
@@ -103,7 +132,7 @@ func (c *IamTokenMiddleware) GetIAMToken(ctx context.Context, originalSubject bo
 
 	token := state.token
 	expiresIn := state.expiresAt.Sub(c.now())
-	if expiresIn > 0 {
+	if expiresIn > 0 && state.credentials == c.credentialsVersion(ctx, subject) {
 		grpclog.Infof("IAM Token Cached. Expires in: %s. ", expiresIn)
 		return token, nil
 	}
@@ -114,6 +143,10 @@ func (c *IamTokenMiddleware) GetIAMToken(ctx context.Context, originalSubject bo
 	}
 	token, err = c.updateToken(ctx, subject, state.version)
 	if err != nil {
+		var unavailable *CredentialsUnavailableError
+		if errors.As(err, &unavailable) {
+			return "", err
+		}
 		st, ok := status.FromError(err)
 		if ok && st.Code() == codes.Unauthenticated {
 			return "", err
@@ -123,18 +156,41 @@ func (c *IamTokenMiddleware) GetIAMToken(ctx context.Context, originalSubject bo
 	return token, nil
 }
 
+// Refresh replaces the cached token of the subject of the call options with a new one,
+// even if the cached token hasn't expired yet. The server may reject a token before its
+// expiration time, e.g. after the key was rotated. While the refreshes back off after
+// failures, see CredentialsRefreshConfig, it returns *CredentialsUnavailableError and the
+// cached token is still used until it expires.
+func (c *IamTokenMiddleware) Refresh(ctx context.Context, opts ...grpc.CallOption) error {
+	subject, err := callAuthSubject(ctx, false, opts)
+	if err != nil {
+		return err
+	}
+	c.mutex.RLock()
+	version := c.subjectToState[subject].version
+	c.mutex.RUnlock()
+	_, err = c.updateToken(ctx, subject, version)
+	return err
+}
+
 func (c *IamTokenMiddleware) updateToken(ctx context.Context, subject authSubject, currentVersion int) (string, error) {
 	c.mutex.RLock()
 	state := c.subjectToState[subject]
 	c.mutex.RUnlock()
-	if state.version != currentVersion {
+	credentials := c.credentialsVersion(ctx, subject)
+	if state.version != currentVersion && state.credentials == credentials && state.token != "" {
 		// someone have already updated it
 		return state.token, nil
 	}
+	if err := c.backingOff(state, credentials); err != nil {
+		return "", err
+	}
 
 	resp, err := subject.createIAMToken(ctx, c.authenticator)
 	if err != nil {
-		return "", sdkerrors.WithMessage(err, "iam token create failed")
+		err = sdkerrors.WithMessage(err, "iam token create failed")
+		c.refreshFailed(subject, credentials, err)
+		return "", err
 	}
 	expiresAt, expiresAtErr := resp.ExpiresAt.AsTime(), resp.ExpiresAt.CheckValid()
 	if expiresAtErr != nil {
@@ -143,16 +199,63 @@ func (c *IamTokenMiddleware) updateToken(ctx context.Context, subject authSubjec
 		expiresAt = c.now().Add(time.Minute)
 	}
 
+	var recovered bool
+	defer func() {
+		if recovered {
+			c.reportBackoff(CredentialsBackoff{})
+		}
+	}()
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	state = c.subjectToState[subject]
+	recovered = state.backoff.failures > 0
+	if state.credentials > credentials {
+		// The token was created with credentials replaced meanwhile.
+		return resp.IamToken, nil
+	}
 	c.subjectToState[subject] = iamTokenState{
-		token:     resp.IamToken,
-		expiresAt: expiresAt,
-		version:   currentVersion + 1,
+		token:       resp.IamToken,
+		expiresAt:   expiresAt,
+		version:     state.version + 1,
+		credentials: credentials,
+	}
+	if _, ok := subject.(credentialsSubject); ok {
+		c.evictCredentialsSubjects()
 	}
 	return resp.IamToken, nil
 }
 
+// maxCredentialsSubjects bounds the number of tokens cached for the credentials of
+// ContextWithCredentials, e.g. of the tenants of a process.
+const maxCredentialsSubjects = 1024
+
+// evictCredentialsSubjects drops the expired tokens cached for the credentials of
+// ContextWithCredentials, and the ones expiring first beyond maxCredentialsSubjects, so that
+// rotated or unused credentials are not kept forever. c.mutex must be held.
+func (c *IamTokenMiddleware) evictCredentialsSubjects() {
+	now := c.now()
+	var cached []authSubject
+	for subject, state := range c.subjectToState {
+		if _, ok := subject.(credentialsSubject); !ok {
+			continue
+		}
+		if !state.expiresAt.After(now) {
+			delete(c.subjectToState, subject)
+			continue
+		}
+		cached = append(cached, subject)
+	}
+	if len(cached) <= maxCredentialsSubjects {
+		return
+	}
+	sort.Slice(cached, func(i, j int) bool {
+		return c.subjectToState[cached[i]].expiresAt.Before(c.subjectToState[cached[j]].expiresAt)
+	})
+	for _, subject := range cached[:len(cached)-maxCredentialsSubjects] {
+		delete(c.subjectToState, subject)
+	}
+}
+
 type authSubject interface {
 	createIAMToken(ctx context.Context, a Authenticator) (*iamkey.CreateIamTokenResponse, error)
 }
@@ -163,12 +266,28 @@ var _ serviceAccountSubject
 type mainSubject struct{}
 type serviceAccountSubject struct{ serviceAccountID string }
 
+// credentialsSubject is the subject of the credentials of ContextWithCredentials, keyed by
+// their identity.
+type credentialsSubject struct{ creds Credentials }
+
+// credentialsAuthenticator is implemented by authenticators supporting ContextWithCredentials.
+type credentialsAuthenticator interface {
+	createIAMTokenWith(ctx context.Context, creds Credentials) (*iamkey.CreateIamTokenResponse, error)
+}
+
 func (s mainSubject) createIAMToken(ctx context.Context, a Authenticator) (*iamkey.CreateIamTokenResponse, error) {
 	return a.CreateIAMToken(ctx)
 }
 func (s serviceAccountSubject) createIAMToken(ctx context.Context, a Authenticator) (*iamkey.CreateIamTokenResponse, error) {
 	return a.CreateIAMTokenForServiceAccount(ctx, s.serviceAccountID)
 }
+func (s credentialsSubject) createIAMToken(ctx context.Context, a Authenticator) (*iamkey.CreateIamTokenResponse, error) {
+	ca, ok := a.(credentialsAuthenticator)
+	if !ok {
+		return nil, fmt.Errorf("authenticator %T doesn't support context credentials", a)
+	}
+	return ca.createIAMTokenWith(ctx, s.creds)
+}
 
 type withServiceAccountID struct {
 	grpc.EmptyCallOption
@@ -187,6 +306,12 @@ func callAuthSubject(ctx context.Context, originalSubject bool, os []grpc.CallOp
 		}
 	}
 	var subject authSubject = mainSubject{}
+	if creds := contextCredentials(ctx); creds != nil {
+		if !reflect.TypeOf(creds).Comparable() {
+			return nil, fmt.Errorf("context credentials of type %T are not comparable, use a pointer", creds)
+		}
+		subject = credentialsSubject{creds: creds}
+	}
 	if saOpt != nil {
 		sa, err := saOpt.serviceAccountIDGet(ctx)
 		if err != nil {
@@ -0,0 +1,183 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultCredentialsRefreshBackoff is the delay of the next token refresh after the first
+	// failure, doubled for every next one.
+	DefaultCredentialsRefreshBackoff = time.Second
+	// DefaultCredentialsRefreshMaxBackoff bounds the delay between token refreshes, e.g.
+	// during an outage of the token endpoint.
+	DefaultCredentialsRefreshMaxBackoff = 5 * time.Minute
+)
+
+// CredentialsRefreshConfig configures the backoff of failed IAM token refreshes, so that
+// the token endpoint isn't called in a tight loop by every call while it fails.
+type CredentialsRefreshConfig struct {
+	// Backoff is the delay of the next refresh after the first failure, doubled for every
+	// next one up to MaxBackoff and jittered by up to a half. Defaults to
+	// DefaultCredentialsRefreshBackoff.
+	Backoff time.Duration
+	// MaxBackoff bounds the delay. Defaults to DefaultCredentialsRefreshMaxBackoff.
+	MaxBackoff time.Duration
+	// OnBackoff, if set, is called with the backoff state of the credentials after every
+	// failed refresh, and with a zero state once a refresh succeeds again.
+	OnBackoff func(CredentialsBackoff)
+}
+
+// CredentialsBackoff is the backoff state of the token refreshes of a credentials.
+type CredentialsBackoff struct {
+	// Failures is the number of consecutive failed refreshes, zero once one succeeds.
+	Failures int
+	// Delay is the delay of the next refresh after the last failure.
+	Delay time.Duration
+	// RetryAt is when the next refresh is allowed.
+	RetryAt time.Time
+	// Err is the error of the last failed refresh.
+	Err error
+	// TokenExpiresAt is the expiration time of the token still cached, zero if there is
+	// none. The token is used until then.
+	TokenExpiresAt time.Time
+}
+
+// ErrCredentialsUnavailable is matched by errors.Is for every *CredentialsUnavailableError.
+var ErrCredentialsUnavailable = errors.New("credentials unavailable")
+
+// CredentialsUnavailableError is the error of the calls made while the token refresh backs
+// off without a cached token, see CredentialsRefreshConfig. Its gRPC status code is
+// Unavailable.
+type CredentialsUnavailableError struct {
+	// RetryAt is when the next refresh is allowed.
+	RetryAt time.Time
+	// Failures is the number of consecutive failed refreshes.
+	Failures int
+	// Err is the error of the last failed refresh.
+	Err error
+}
+
+func (e *CredentialsUnavailableError) Error() string {
+	return fmt.Sprintf("credentials unavailable: %d token refreshes failed, next one at %s: %v", e.Failures, e.RetryAt.Format(time.RFC3339), e.Err)
+}
+
+func (e *CredentialsUnavailableError) Is(target error) bool {
+	return target == ErrCredentialsUnavailable
+}
+
+func (e *CredentialsUnavailableError) Unwrap() error { return e.Err }
+
+func (e *CredentialsUnavailableError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// refreshBackoff is the backoff state of a subject, kept in its iamTokenState.
+type refreshBackoff struct {
+	failures int
+	delay    time.Duration
+	retryAt  time.Time
+	err      error
+	// credentials is the version of the credentials the refreshes failed with.
+	credentials int
+}
+
+// WithRefreshBackoff sets the backoff of failed token refreshes, see CredentialsRefreshConfig.
+func (c *IamTokenMiddleware) WithRefreshBackoff(conf CredentialsRefreshConfig) *IamTokenMiddleware {
+	c.refresh = conf
+	return c
+}
+
+// Backoff returns the backoff state of the token refreshes of the subject of the call
+// options, zero if the last refresh succeeded.
+func (c *IamTokenMiddleware) Backoff(ctx context.Context, opts ...grpc.CallOption) (CredentialsBackoff, error) {
+	subject, err := callAuthSubject(ctx, false, opts)
+	if err != nil {
+		return CredentialsBackoff{}, err
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.subjectToState[subject].backoffState(), nil
+}
+
+func (s iamTokenState) backoffState() CredentialsBackoff {
+	if s.backoff.failures == 0 {
+		return CredentialsBackoff{}
+	}
+	b := CredentialsBackoff{Failures: s.backoff.failures, Delay: s.backoff.delay, RetryAt: s.backoff.retryAt, Err: s.backoff.err}
+	if s.token != "" {
+		b.TokenExpiresAt = s.expiresAt
+	}
+	return b
+}
+
+// backingOff returns the error of the refreshes of state with the version of the
+// credentials until the next one is allowed, nil after that.
+func (c *IamTokenMiddleware) backingOff(state iamTokenState, credentials int) error {
+	b := state.backoff
+	if b.failures == 0 || b.credentials != credentials || !c.now().Before(b.retryAt) {
+		return nil
+	}
+	return &CredentialsUnavailableError{RetryAt: b.retryAt, Failures: b.failures, Err: b.err}
+}
+
+// refreshFailed records the failed refresh of the subject and reports the new backoff state.
+func (c *IamTokenMiddleware) refreshFailed(subject authSubject, credentials int, err error) {
+	c.mutex.Lock()
+	state := c.subjectToState[subject]
+	b := state.backoff
+	if b.credentials != credentials {
+		b = refreshBackoff{credentials: credentials}
+	}
+	b.failures++
+	b.delay = c.backoffDelay(b.failures)
+	b.retryAt = c.now().Add(b.delay)
+	b.err = err
+	state.backoff = b
+	c.subjectToState[subject] = state
+	c.mutex.Unlock()
+	c.reportBackoff(state.backoffState())
+}
+
+// backoffDelay returns the jittered delay after the consecutive failures.
+func (c *IamTokenMiddleware) backoffDelay(failures int) time.Duration {
+	delay, max := c.refresh.Backoff, c.refresh.MaxBackoff
+	if delay <= 0 {
+		delay = DefaultCredentialsRefreshBackoff
+	}
+	if max <= 0 {
+		max = DefaultCredentialsRefreshMaxBackoff
+	}
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return c.jitter(delay)
+}
+
+func (c *IamTokenMiddleware) reportBackoff(b CredentialsBackoff) {
+	if c.refresh.OnBackoff != nil {
+		c.refresh.OnBackoff(b)
+	}
+}
+
+// equalJitter returns a random delay between a half and the whole of d.
+func equalJitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// CredentialsBackoff returns the backoff state of the token refreshes of the credentials of
+// the SDK, or of the context, see ContextWithCredentials. It is zero unless the last
+// refresh failed.
+func (sdk *SDK) CredentialsBackoff(ctx context.Context) (CredentialsBackoff, error) {
+	return sdk.tokens.Backoff(ctx)
+}
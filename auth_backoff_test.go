@@ -0,0 +1,151 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/iamkey"
+)
+
+// outageAuthenticator issues tokens valid for an hour unless it is down.
+type outageAuthenticator struct {
+	Authenticator
+	now    func() time.Time
+	down   bool
+	calls  int
+	issued int
+}
+
+func (a *outageAuthenticator) CreateIAMToken(ctx context.Context) (*iamkey.CreateIamTokenResponse, error) {
+	a.calls++
+	if a.down {
+		return nil, status.Error(codes.Unavailable, "token endpoint is down")
+	}
+	a.issued++
+	return &iamkey.CreateIamTokenResponse{IamToken: fmt.Sprintf("token-%d", a.issued), ExpiresAt: timestamppb.New(a.now().Add(time.Hour))}, nil
+}
+
+func newBackoffMiddleware(conf CredentialsRefreshConfig) (*IamTokenMiddleware, *outageAuthenticator, *time.Time) {
+	clock := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	auth := &outageAuthenticator{now: now}
+	m := NewIAMTokenMiddleware(auth, now).WithRefreshBackoff(conf)
+	m.jitter = func(d time.Duration) time.Duration { return d }
+	return m, auth, &clock
+}
+
+func TestCredentialsRefreshBackoff(t *testing.T) {
+	var reported []CredentialsBackoff
+	m, auth, clock := newBackoffMiddleware(CredentialsRefreshConfig{
+		Backoff:    time.Second,
+		MaxBackoff: 5 * time.Second,
+		OnBackoff:  func(b CredentialsBackoff) { reported = append(reported, b) },
+	})
+	ctx := context.Background()
+	token, err := m.GetIAMToken(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	*clock = clock.Add(time.Hour)
+	auth.down = true
+	_, err = m.GetIAMToken(ctx, false)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "the failure of the refresh itself")
+	assert.NotErrorIs(t, err, ErrCredentialsUnavailable)
+
+	for _, delay := range []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		calls := auth.calls
+		_, err = m.GetIAMToken(ctx, false)
+		var unavailable *CredentialsUnavailableError
+		require.ErrorAs(t, err, &unavailable, "the refresh backs off")
+		assert.ErrorIs(t, err, ErrCredentialsUnavailable)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, calls, auth.calls, "the token endpoint is not called while backing off")
+
+		*clock = unavailable.RetryAt
+		_, err = m.GetIAMToken(ctx, false)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCredentialsUnavailable, "the refresh is retried once allowed")
+		assert.Equal(t, calls+1, auth.calls)
+		b, err := m.Backoff(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, delay, b.Delay)
+		assert.Equal(t, clock.Add(delay), b.RetryAt)
+		assert.True(t, b.TokenExpiresAt.Equal(*clock) || b.TokenExpiresAt.Before(*clock), "the cached token has expired")
+	}
+	require.Len(t, reported, 5)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, []int{reported[0].Failures, reported[1].Failures, reported[2].Failures, reported[3].Failures, reported[4].Failures})
+	assert.Equal(t, time.Second, reported[0].Delay)
+	assert.Contains(t, reported[4].Err.Error(), "token endpoint is down")
+
+	auth.down = false
+	*clock = clock.Add(5 * time.Second)
+	token, err = m.GetIAMToken(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	require.Len(t, reported, 6)
+	assert.Equal(t, CredentialsBackoff{}, reported[5], "the recovery is reported")
+	b, err := m.Backoff(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CredentialsBackoff{}, b)
+
+	*clock = clock.Add(time.Hour)
+	auth.down = true
+	_, err = m.GetIAMToken(ctx, false)
+	require.Error(t, err)
+	b, err = m.Backoff(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, b.Delay, "the backoff restarts after a success")
+}
+
+func TestCredentialsRefreshBackoff_StaleToken(t *testing.T) {
+	m, auth, clock := newBackoffMiddleware(CredentialsRefreshConfig{Backoff: 2 * time.Hour, MaxBackoff: 2 * time.Hour})
+	ctx := context.Background()
+	_, err := m.GetIAMToken(ctx, false)
+	require.NoError(t, err)
+
+	auth.down = true
+	require.Error(t, m.Refresh(ctx))
+	assert.ErrorIs(t, m.Refresh(ctx), ErrCredentialsUnavailable)
+	token, err := m.GetIAMToken(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "the unexpired token is used while backing off")
+	b, err := m.Backoff(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.Failures)
+	assert.Equal(t, 2*time.Hour, b.Delay)
+	assert.Equal(t, clock.Add(time.Hour), b.TokenExpiresAt)
+
+	*clock = clock.Add(time.Hour)
+	_, err = m.GetIAMToken(ctx, false)
+	var unavailable *CredentialsUnavailableError
+	require.ErrorAs(t, err, &unavailable, "an expired token is not used")
+	assert.Equal(t, 1, unavailable.Failures)
+	assert.Equal(t, 1, auth.issued)
+}
+
+func TestCredentialsRefreshBackoff_Cap(t *testing.T) {
+	m, _, _ := newBackoffMiddleware(CredentialsRefreshConfig{})
+	assert.Equal(t, time.Second, m.backoffDelay(1))
+	assert.Equal(t, 4*time.Second, m.backoffDelay(3))
+	assert.Equal(t, DefaultCredentialsRefreshMaxBackoff, m.backoffDelay(100), "capped without overflowing")
+
+	for i := 0; i < 100; i++ {
+		d := equalJitter(time.Minute)
+		assert.True(t, d >= 30*time.Second && d <= time.Minute, d)
+	}
+}
+
+func TestCredentialsUnavailableError(t *testing.T) {
+	err := fmt.Errorf("call: %w", &CredentialsUnavailableError{Failures: 2, RetryAt: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), Err: errors.New("boom")})
+	assert.ErrorIs(t, err, ErrCredentialsUnavailable)
+	assert.EqualError(t, err, "call: credentials unavailable: 2 token refreshes failed, next one at 2023-05-01T12:00:00Z: boom")
+}
@@ -0,0 +1,288 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/iamkey"
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// rotatingCredentials issues token-1, token-2, ... valid for an hour.
+type rotatingCredentials struct {
+	mu     sync.Mutex
+	issued int
+}
+
+func (c *rotatingCredentials) DCAPICredentials() {}
+
+func (c *rotatingCredentials) IAMToken(ctx context.Context) (*iamkey.CreateIamTokenResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.issued++
+	return &iamkey.CreateIamTokenResponse{
+		IamToken:  fmt.Sprintf("token-%d", c.issued),
+		ExpiresAt: timestamppb.New(time.Now().Add(time.Hour)),
+	}, nil
+}
+
+// revokingOperations keeps the operation pending for two polls and then revokes the tokens
+// accepted so far, like a server revoking a token before its expiration time.
+type revokingOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+
+	mu      sync.Mutex
+	polls   int
+	revoked map[string]bool
+}
+
+func (s *revokingOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := md.Get("authorization")[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revoked[token] {
+		return nil, status.Error(codes.Unauthenticated, "token revoked")
+	}
+	s.polls++
+	if s.polls == 2 {
+		s.revoked = map[string]bool{token: true}
+		return nil, status.Error(codes.Unauthenticated, "token revoked")
+	}
+	st := dcv1.Operation_STATUS_PENDING
+	if s.polls > 2 {
+		st = dcv1.Operation_STATUS_DONE
+	}
+	return &dcv1.Operation{Id: req.OperationId, Status: st}, nil
+}
+
+func TestWrapOperation_RefreshesRevokedToken(t *testing.T) {
+	creds := &rotatingCredentials{}
+	ops := &revokingOperations{}
+	sdk := newTestSDKWithConfig(t, Config{Credentials: creds}, func(s *grpc.Server) {
+		clickhouse.RegisterOperationServiceServer(s, ops)
+	})
+
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.WaitInterval(context.Background(), time.Millisecond))
+	assert.Equal(t, 2, creds.issued, "the revoked token must be refreshed exactly once")
+}
+
+func TestWrapOperation_RevokedCredentials(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterOperationServiceServer(s, &revokingOperations{})
+	})
+
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	err = op.WaitInterval(context.Background(), time.Millisecond)
+	assert.ErrorIs(t, err, operation.ErrCredentials)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// tokenRecordingOperations is pending for two polls and records the tokens of the polls.
+type tokenRecordingOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+
+	mu     sync.Mutex
+	polls  map[string]int
+	tokens map[string][]string
+}
+
+func (s *tokenRecordingOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls[req.OperationId]++
+	s.tokens[req.OperationId] = append(s.tokens[req.OperationId], md.Get("authorization")[0])
+	op := &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_RUNNING}
+	if s.polls[req.OperationId] > 2 {
+		op.Status = dcv1.Operation_STATUS_DONE
+	}
+	return op, nil
+}
+
+func TestContextWithCredentials(t *testing.T) {
+	rec := &configRecordingEndpoints{}
+	ops := &tokenRecordingOperations{polls: map[string]int{}, tokens: map[string][]string{}}
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		transfer.RegisterEndpointServiceServer(s, rec)
+		clickhouse.RegisterOperationServiceServer(s, ops)
+	})
+	tenants := map[string]Credentials{
+		"cho-a": NewIAMTokenCredentials("tenant-a"),
+		"cho-b": NewIAMTokenCredentials("tenant-b"),
+	}
+
+	var wg sync.WaitGroup
+	for id, creds := range tenants {
+		wg.Add(1)
+		go func(id string, creds Credentials) {
+			defer wg.Done()
+			ctx := ContextWithCredentials(context.Background(), creds)
+			for i := 0; i < 10; i++ {
+				_, err := sdk.Transfer().Endpoint().Create(ctx, &transfer.CreateEndpointRequest{Name: id})
+				assert.NoError(t, err)
+			}
+			op, err := sdk.ResumeOperation(id)
+			require.NoError(t, err)
+			assert.NoError(t, op.WaitInterval(ctx, time.Millisecond))
+		}(id, creds)
+	}
+	wg.Wait()
+
+	want := map[string]string{"cho-a": "tenant-a", "cho-b": "tenant-b"}
+	rec.mu.Lock()
+	for _, call := range rec.calls {
+		assert.Equal(t, want[call.name], call.token)
+	}
+	assert.Len(t, rec.calls, 20)
+	rec.mu.Unlock()
+	for id, token := range want {
+		assert.Equal(t, []string{"Bearer " + token, "Bearer " + token, "Bearer " + token}, ops.tokens[id], "every poll uses the credentials of the wait context")
+	}
+
+	_, err := sdk.Transfer().Endpoint().Create(context.Background(), &transfer.CreateEndpointRequest{Name: "default"})
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", rec.last().token, "calls without context credentials use the SDK ones")
+}
+
+// tenantAuthenticator issues a token of the credentials of the context valid for an hour.
+type tenantAuthenticator struct {
+	Authenticator
+	now func() time.Time
+}
+
+func (a tenantAuthenticator) createIAMTokenWith(ctx context.Context, creds Credentials) (*iamkey.CreateIamTokenResponse, error) {
+	return &iamkey.CreateIamTokenResponse{IamToken: fmt.Sprintf("%p", creds), ExpiresAt: timestamppb.New(a.now().Add(time.Hour))}, nil
+}
+
+func (c *IamTokenMiddleware) credentialsSubjects() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var n int
+	for subject := range c.subjectToState {
+		if _, ok := subject.(credentialsSubject); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func TestContextWithCredentials_Evicted(t *testing.T) {
+	clock := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	m := NewIAMTokenMiddleware(tenantAuthenticator{now: now}, now)
+	token := func(creds Credentials) string {
+		token, err := m.GetIAMToken(ContextWithCredentials(context.Background(), creds), false)
+		require.NoError(t, err)
+		return token
+	}
+
+	first := NewIAMTokenCredentials("tenant-0")
+	want := token(first)
+	for i := 1; i < maxCredentialsSubjects+10; i++ {
+		clock = clock.Add(time.Millisecond)
+		token(NewIAMTokenCredentials(fmt.Sprintf("tenant-%d", i)))
+	}
+	assert.Equal(t, maxCredentialsSubjects, m.credentialsSubjects(), "the tokens expiring first are evicted")
+	assert.Equal(t, want, token(first), "an evicted token is created again")
+
+	clock = clock.Add(2 * time.Hour)
+	token(NewIAMTokenCredentials("tenant-new"))
+	assert.Equal(t, 1, m.credentialsSubjects(), "expired tokens are evicted")
+	assert.Equal(t, want, token(first))
+}
+
+type funcCredentials func()
+
+func (funcCredentials) DCAPICredentials() {}
+
+func (funcCredentials) IAMToken(ctx context.Context) (*iamkey.CreateIamTokenResponse, error) {
+	return &iamkey.CreateIamTokenResponse{IamToken: "func"}, nil
+}
+
+func TestContextWithCredentials_NotComparable(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		transfer.RegisterEndpointServiceServer(s, &configRecordingEndpoints{})
+	})
+	ctx := ContextWithCredentials(context.Background(), funcCredentials(func() {}))
+	_, err := sdk.Transfer().Endpoint().Create(ctx, &transfer.CreateEndpointRequest{Name: "e"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not comparable")
+}
+
+// rotationOperations replaces the credentials of the SDK on the second poll with rotate. The
+// polls with the token "token-denied" are rejected, the others done from the third one.
+type rotationOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	rotate func()
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (s *rotationOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := md.Get("authorization")[0]
+	s.mu.Lock()
+	s.tokens = append(s.tokens, token)
+	polls := len(s.tokens)
+	s.mu.Unlock()
+
+	switch {
+	case polls == 2:
+		s.rotate()
+	case token == "Bearer token-denied":
+		return nil, status.Error(codes.PermissionDenied, "no permission on the operation")
+	case polls > 2:
+		return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_DONE}, nil
+	}
+	return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func TestWrapOperation_CredentialsRotatedDuringWait(t *testing.T) {
+	for _, token := range []string{"token-b", "token-denied"} {
+		t.Run(token, func(t *testing.T) {
+			var sdk *SDK
+			ops := &rotationOperations{rotate: func() {
+				assert.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) { c.Credentials = NewIAMTokenCredentials(token) }))
+			}}
+			sdk = newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("token-a")}, func(s *grpc.Server) {
+				clickhouse.RegisterOperationServiceServer(s, ops)
+			})
+
+			op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+			require.NoError(t, err)
+			started := time.Now()
+			err = op.WaitInterval(context.Background(), time.Millisecond)
+			if token == "token-b" {
+				require.NoError(t, err)
+				assert.Equal(t, []string{"Bearer token-a", "Bearer token-a", "Bearer token-b"}, ops.tokens, "the new token is used by the next poll")
+				return
+			}
+			var rotated *operation.CredentialsRotatedError
+			require.ErrorAs(t, err, &rotated)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+			assert.False(t, rotated.RotatedAt.Before(started))
+			assert.Contains(t, err.Error(), "credentials were rotated during the wait")
+			assert.Len(t, ops.tokens, 4, "the rejected poll is retried once")
+		})
+	}
+}
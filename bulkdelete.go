@@ -0,0 +1,274 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	clickhousepb "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkapb "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkpb "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	transferpb "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const (
+	// DefaultBulkDeleteParallelism is the number of deletes BulkDelete runs simultaneously by
+	// default.
+	DefaultBulkDeleteParallelism = 4
+	// DefaultBulkDeleteInterval is the least time between the delete requests of BulkDelete
+	// without BulkDeleteOptions.Limiter.
+	DefaultBulkDeleteInterval = 100 * time.Millisecond
+)
+
+// bulkDeleteOrder are the kinds of BulkDelete in the order they are deleted: the resources
+// using others first, e.g. the transfers before their endpoints, the endpoints before their
+// clusters and the clusters before their networks.
+var bulkDeleteOrder = []ResourceKind{
+	ResourceTransfer,
+	ResourceTransferEndpoint,
+	ResourceKafkaCluster,
+	ResourceClickHouseCluster,
+	ResourceNetwork,
+}
+
+// BulkDeleteOptions configures BulkDelete.
+type BulkDeleteOptions struct {
+	// Parallelism bounds the number of deletes running at the same time, waits included.
+	// Zero means DefaultBulkDeleteParallelism.
+	Parallelism int
+	// ContinueOnError makes BulkDelete delete all the resources whatever the failures. By
+	// default, no delete starts after the first failure: the others are skipped.
+	ContinueOnError bool
+	// Limiter limits the rate of the delete requests, e.g. a *rate.Limiter of
+	// golang.org/x/time/rate. Defaults to one request every DefaultBulkDeleteInterval.
+	Limiter operation.PollLimiter
+	// OnProgress, if set, is called once a resource is deleted, failed or found already
+	// deleted, with the numbers of resources done so far and in total. The calls are made one
+	// at a time, from the goroutine of BulkDelete.
+	OnProgress func(done, total int, last Outcome)
+}
+
+// Outcome is the outcome of the delete of a resource of BulkDelete.
+type Outcome struct {
+	Ref ResourceRef
+	// Status is StepSkipped for the resources not deleted, because ctx was done or an earlier
+	// delete failed.
+	Status StepStatus
+	// Operation is the delete operation, nil if no delete was made or the resource was
+	// already deleted.
+	Operation *operation.Operation
+	// AlreadyDeleted is set for the resources not found, which succeed.
+	AlreadyDeleted bool
+	Err            error
+}
+
+// BulkDelete deletes the resources of refs, e.g. the ones left in a test project, and waits
+// for their operations. The resources are deleted kind after kind, in the order of their
+// dependencies: transfers, transfer endpoints, Kafka clusters, ClickHouse clusters, then
+// networks. The deletes of a kind run concurrently, see BulkDeleteOptions, and the ones of
+// the next kind start once all of them are done. A resource not found, by its delete or its
+// operation, is already deleted: it succeeds. The resources of other kinds, e.g. workbooks,
+// fail up front without requests, and don't stop the deletes of the others.
+//
+// The result has one outcome per reference, in the order of refs: a reference repeated is
+// deleted once. The returned error aggregates the failures, or is the error of ctx if it was
+// done before all the deletes.
+func (sdk *SDK) BulkDelete(ctx context.Context, refs []ResourceRef, options BulkDeleteOptions, opts ...grpc.CallOption) ([]Outcome, error) {
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultBulkDeleteParallelism
+	}
+	limiter := options.Limiter
+	if limiter == nil {
+		limiter = &intervalLimiter{interval: DefaultBulkDeleteInterval}
+	}
+
+	outcomes := make([]Outcome, len(refs))
+	// first is the index of the first reference of every name, deleted for the repeated ones.
+	first := map[string]int{}
+	// phases are the indexes of the references deleted together, the ones of every kind of
+	// bulkDeleteOrder.
+	phases := make([][]int, len(bulkDeleteOrder))
+	// unsupported are the indexes of the references of the other kinds, failed up front.
+	var unsupported []int
+	for i, ref := range refs {
+		outcomes[i] = Outcome{Ref: ref, Status: StepSkipped}
+		if _, ok := first[ref.String()]; ok {
+			continue
+		}
+		first[ref.String()] = i
+		phase := -1
+		for j, kind := range bulkDeleteOrder {
+			if ref.Kind == kind {
+				phase = j
+			}
+		}
+		if phase < 0 {
+			outcomes[i] = Outcome{Ref: ref, Status: StepFailed, Err: fmt.Errorf("bulk delete of %q resources is not supported", ref.Kind)}
+			unsupported = append(unsupported, i)
+			continue
+		}
+		phases[phase] = append(phases[phase], i)
+	}
+
+	type finished struct {
+		i   int
+		out Outcome
+	}
+	done := make(chan finished)
+	started := make([]bool, len(refs))
+	var (
+		ready          []int
+		phase, running int
+		finishedN      int
+		failed         bool
+	)
+	progress := func(out Outcome) {
+		finishedN++
+		if options.OnProgress != nil {
+			_ = operation.SafeCall("bulk delete progress", func() error {
+				options.OnProgress(finishedN, len(first), out)
+				return nil
+			})
+		}
+	}
+	// The unsupported references fail without stopping the deletes of the others.
+	for _, i := range unsupported {
+		progress(outcomes[i])
+	}
+	for {
+		for len(ready) == 0 && running == 0 && phase < len(phases) {
+			ready = phases[phase]
+			phase++
+		}
+		for len(ready) > 0 && running < parallelism && ctx.Err() == nil && (options.ContinueOnError || !failed) {
+			i := ready[0]
+			ready = ready[1:]
+			started[i] = true
+			running++
+			go func(i int) {
+				done <- finished{i: i, out: sdk.deleteResource(ctx, limiter, refs[i], opts)}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+		f := <-done
+		running--
+		outcomes[f.i] = f.out
+		if f.out.Status == StepFailed {
+			failed = true
+		}
+		progress(f.out)
+	}
+
+	var errs error
+	for i, ref := range refs {
+		if j := first[ref.String()]; j != i {
+			outcomes[i] = outcomes[j]
+			continue
+		}
+		switch {
+		case outcomes[i].Status == StepFailed:
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(outcomes[i].Err, "delete %s", ref))
+		case started[i]:
+		case ctx.Err() != nil:
+			outcomes[i].Err = ctx.Err()
+		default:
+			outcomes[i].Err = errors.New("skipped after an earlier delete failed")
+		}
+	}
+	if errs == nil && ctx.Err() != nil && finishedN < len(first) {
+		errs = ctx.Err()
+	}
+	return outcomes, errs
+}
+
+// deleteResource deletes the resource with the Delete method of its service and waits for
+// the operation.
+func (sdk *SDK) deleteResource(ctx context.Context, limiter operation.PollLimiter, ref ResourceRef, opts []grpc.CallOption) Outcome {
+	out := Outcome{Ref: ref, Status: StepFailed}
+	var del func() (*dcv1.Operation, error)
+	switch ref.Kind {
+	case ResourceClickHouseCluster:
+		del = func() (*dcv1.Operation, error) {
+			return sdk.ClickHouse().Cluster().Delete(ctx, &clickhousepb.DeleteClusterRequest{ClusterId: ref.ID}, opts...)
+		}
+	case ResourceKafkaCluster:
+		del = func() (*dcv1.Operation, error) {
+			return sdk.Kafka().Cluster().Delete(ctx, &kafkapb.DeleteClusterRequest{ClusterId: ref.ID}, opts...)
+		}
+	case ResourceNetwork:
+		del = func() (*dcv1.Operation, error) {
+			return sdk.Network().Network().Delete(ctx, &networkpb.DeleteNetworkRequest{NetworkId: ref.ID}, opts...)
+		}
+	case ResourceTransfer:
+		del = func() (*dcv1.Operation, error) {
+			return sdk.Transfer().Transfer().Delete(ctx, &transferpb.DeleteTransferRequest{TransferId: ref.ID}, opts...)
+		}
+	case ResourceTransferEndpoint:
+		del = func() (*dcv1.Operation, error) {
+			return sdk.Transfer().Endpoint().Delete(ctx, &transferpb.DeleteEndpointRequest{EndpointId: ref.ID}, opts...)
+		}
+	default:
+		out.Err = fmt.Errorf("bulk delete of %q resources is not supported", ref.Kind)
+		return out
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		out.Err = status.FromContextError(err).Err()
+		return out
+	}
+	op, err := sdk.WrapOperation(del())
+	if err == nil {
+		out.Operation = op
+		err = op.Wait(ctx)
+	}
+	switch {
+	case status.Code(err) == codes.NotFound && ctx.Err() == nil:
+		out.Status, out.AlreadyDeleted, out.Operation = StepSucceeded, true, nil
+	case err != nil:
+		out.Err = err
+	default:
+		out.Status = StepSucceeded
+	}
+	return out
+}
+
+// intervalLimiter allows one call every interval.
+type intervalLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (l *intervalLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	t := time.NewTimer(at.Sub(now))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,260 @@
+package dcsdk
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// bulkDeletes fakes the deletes of all the services, returning done operations. The IDs
+// ending with "404" are not found by the delete, the ones ending with "gone" by its
+// operation, and the ones of fail fail.
+type bulkDeletes struct {
+	fail map[string]bool
+
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (f *bulkDeletes) delete(ctx context.Context, opID, id string) (*dcv1.Operation, error) {
+	f.mu.Lock()
+	f.deleted = append(f.deleted, id)
+	f.mu.Unlock()
+	op := &dcv1.Operation{Id: opID, ResourceId: id, Status: dcv1.Operation_STATUS_DONE}
+	switch {
+	case id[len(id)-3:] == "404":
+		return nil, status.Errorf(codes.NotFound, "%s not found", id)
+	case len(id) > 4 && id[len(id)-4:] == "gone":
+		op.Error = &rpcstatus.Status{Code: int32(code.Code_NOT_FOUND), Message: id + " was deleted"}
+	case f.fail[id]:
+		op.Error = &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "delete of " + id + " failed"}
+	}
+	return op, nil
+}
+
+func (f *bulkDeletes) deletedIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deleted...)
+}
+
+type bulkClickHouse struct {
+	clickhouse.UnimplementedClusterServiceServer
+	*bulkDeletes
+}
+
+func (f bulkClickHouse) Delete(ctx context.Context, req *clickhouse.DeleteClusterRequest) (*dcv1.Operation, error) {
+	return f.delete(ctx, "cho-"+req.ClusterId, req.ClusterId)
+}
+
+type bulkKafka struct {
+	kafka.UnimplementedClusterServiceServer
+	*bulkDeletes
+}
+
+func (f bulkKafka) Delete(ctx context.Context, req *kafka.DeleteClusterRequest) (*dcv1.Operation, error) {
+	return f.delete(ctx, "kfo-"+req.ClusterId, req.ClusterId)
+}
+
+type bulkNetworks struct {
+	network.UnimplementedNetworkServiceServer
+	*bulkDeletes
+}
+
+func (f bulkNetworks) Delete(ctx context.Context, req *network.DeleteNetworkRequest) (*dcv1.Operation, error) {
+	return f.delete(ctx, "00000000-0000-4000-8000-000000000001", req.NetworkId)
+}
+
+type bulkTransfers struct {
+	transfer.UnimplementedTransferServiceServer
+	*bulkDeletes
+}
+
+func (f bulkTransfers) Delete(ctx context.Context, req *transfer.DeleteTransferRequest) (*dcv1.Operation, error) {
+	return f.delete(ctx, "dtj-"+req.TransferId, req.TransferId)
+}
+
+type bulkEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+	*bulkDeletes
+}
+
+func (f bulkEndpoints) Delete(ctx context.Context, req *transfer.DeleteEndpointRequest) (*dcv1.Operation, error) {
+	return f.delete(ctx, "dte-"+req.EndpointId, req.EndpointId)
+}
+
+func newBulkDeleteSDK(t *testing.T, f *bulkDeletes) *SDK {
+	return newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, bulkClickHouse{bulkDeletes: f})
+		kafka.RegisterClusterServiceServer(s, bulkKafka{bulkDeletes: f})
+		network.RegisterNetworkServiceServer(s, bulkNetworks{bulkDeletes: f})
+		transfer.RegisterTransferServiceServer(s, bulkTransfers{bulkDeletes: f})
+		transfer.RegisterEndpointServiceServer(s, bulkEndpoints{bulkDeletes: f})
+	})
+}
+
+// countingLimiter counts the requests it allows.
+type countingLimiter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.n++
+	return nil
+}
+
+var bulkRefs = []ResourceRef{
+	{Kind: ResourceNetwork, ID: "net1"},
+	{Kind: ResourceClickHouseCluster, ID: "chc1"},
+	{Kind: ResourceClickHouseCluster, ID: "chcgone"},
+	{Kind: ResourceKafkaCluster, ID: "kfc1"},
+	{Kind: ResourceKafkaCluster, ID: "kfcfail"},
+	{Kind: ResourceTransferEndpoint, ID: "dte1"},
+	{Kind: ResourceTransferEndpoint, ID: "dte404"},
+	{Kind: ResourceTransfer, ID: "dtt1"},
+	{Kind: ResourceTransfer, ID: "dtt2"},
+	{Kind: ResourceClickHouseCluster, ID: "chc1"},
+}
+
+func TestBulkDelete_ContinueOnError(t *testing.T) {
+	f := &bulkDeletes{fail: map[string]bool{"kfcfail": true}}
+	sdk := newBulkDeleteSDK(t, f)
+	limiter := &countingLimiter{}
+	var progress []int
+	outcomes, err := sdk.BulkDelete(context.Background(), bulkRefs, BulkDeleteOptions{
+		Parallelism:     2,
+		ContinueOnError: true,
+		Limiter:         limiter,
+		OnProgress: func(done, total int, last Outcome) {
+			// Unsynchronized: the race detector reports concurrent calls.
+			progress = append(progress, done)
+			assert.Equal(t, 9, total)
+			assert.NotEmpty(t, last.Ref.ID)
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "delete of kfcfail failed")
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, progress)
+	assert.Equal(t, 9, limiter.n, "every delete request is limited")
+
+	deleted := f.deletedIDs()
+	require.Len(t, deleted, 9, "every resource is deleted once")
+	order := map[string]int{"dtt": 0, "dte": 1, "kfc": 2, "chc": 3, "net": 4}
+	for i := 1; i < len(deleted); i++ {
+		assert.LessOrEqual(t, order[deleted[i-1][:3]], order[deleted[i][:3]], "the kinds in order: %v", deleted)
+	}
+
+	require.Len(t, outcomes, len(bulkRefs))
+	for i, out := range outcomes {
+		assert.Equal(t, bulkRefs[i], out.Ref)
+		if out.Ref.ID == "kfcfail" {
+			assert.Equal(t, StepFailed, out.Status)
+			assert.Equal(t, codes.Internal, status.Code(out.Err))
+			continue
+		}
+		assert.Equal(t, StepSucceeded, out.Status, out.Ref.ID)
+		assert.NoError(t, out.Err, out.Ref.ID)
+		switch out.Ref.ID {
+		case "chcgone", "dte404":
+			assert.True(t, out.AlreadyDeleted, "not found is already deleted: %s", out.Ref.ID)
+			assert.Nil(t, out.Operation)
+		default:
+			assert.False(t, out.AlreadyDeleted, out.Ref.ID)
+			require.NotNil(t, out.Operation, out.Ref.ID)
+			assert.True(t, out.Operation.Ok())
+		}
+	}
+	assert.Same(t, outcomes[1].Operation, outcomes[len(bulkRefs)-1].Operation, "repeated references are deleted once")
+}
+
+func TestBulkDelete_StopsOnError(t *testing.T) {
+	f := &bulkDeletes{fail: map[string]bool{"kfcfail": true}}
+	sdk := newBulkDeleteSDK(t, f)
+	var last []Outcome
+	outcomes, err := sdk.BulkDelete(context.Background(), bulkRefs, BulkDeleteOptions{
+		Parallelism: 1,
+		Limiter:     &countingLimiter{},
+		OnProgress:  func(done, total int, out Outcome) { last = append(last, out) },
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"dtt1", "dtt2", "dte1", "dte404", "kfc1", "kfcfail"}, f.deletedIDs(), "no delete after the failure")
+	require.Len(t, last, 6)
+	assert.Equal(t, StepFailed, last[5].Status)
+
+	for _, out := range outcomes {
+		switch out.Ref.Kind {
+		case ResourceClickHouseCluster, ResourceNetwork:
+			assert.Equal(t, StepSkipped, out.Status, out.Ref.ID)
+			assert.EqualError(t, out.Err, "skipped after an earlier delete failed")
+		}
+	}
+	assert.NotContains(t, err.Error(), "skipped", "the skipped resources don't fail")
+}
+
+func TestBulkDelete_UnsupportedAndCancelled(t *testing.T) {
+	f := &bulkDeletes{}
+	sdk := newBulkDeleteSDK(t, f)
+	refs := []ResourceRef{{Kind: ResourceVisualizationWorkbook, ID: "wb1"}, {Kind: ResourceTransfer, ID: "dtt1"}}
+	outcomes, err := sdk.BulkDelete(context.Background(), refs, BulkDeleteOptions{ContinueOnError: true, Limiter: &countingLimiter{}})
+	require.Error(t, err)
+	assert.Equal(t, StepFailed, outcomes[0].Status)
+	assert.EqualError(t, outcomes[0].Err, `bulk delete of "visualization/workbook" resources is not supported`)
+	assert.Equal(t, StepSucceeded, outcomes[1].Status)
+	assert.Equal(t, []string{"dtt1"}, f.deletedIDs())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	outcomes, err = sdk.BulkDelete(ctx, refs[1:], BulkDeleteOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, StepSkipped, outcomes[0].Status)
+	assert.ErrorIs(t, outcomes[0].Err, context.Canceled)
+	assert.Len(t, f.deletedIDs(), 1)
+}
+
+func TestBulkDelete_UnsupportedDoesNotStop(t *testing.T) {
+	f := &bulkDeletes{}
+	sdk := newBulkDeleteSDK(t, f)
+	refs := []ResourceRef{
+		{Kind: ResourceTransfer, ID: "dtt1"},
+		{Kind: ResourceVisualizationWorkbook, ID: "wb1"},
+		{Kind: ResourceKafkaCluster, ID: "kfc1"},
+		{Kind: ResourceNetwork, ID: "net1"},
+	}
+	var progress []int
+	outcomes, err := sdk.BulkDelete(context.Background(), refs, BulkDeleteOptions{
+		Limiter:    &countingLimiter{},
+		OnProgress: func(done, total int, out Outcome) { progress = append(progress, done, total) },
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `bulk delete of "visualization/workbook" resources is not supported`)
+	assert.Equal(t, []string{"dtt1", "kfc1", "net1"}, f.deletedIDs(), "the workbook doesn't stop the deletes")
+	assert.Equal(t, StepFailed, outcomes[1].Status)
+	for _, i := range []int{0, 2, 3} {
+		assert.Equal(t, StepSucceeded, outcomes[i].Status, refs[i].ID)
+	}
+	assert.Equal(t, []int{1, 4, 2, 4, 3, 4, 4, 4}, progress)
+}
+
+func TestIntervalLimiter(t *testing.T) {
+	l := &intervalLimiter{interval: DefaultBulkDeleteInterval}
+	require.NoError(t, l.Wait(context.Background()), "the first call is allowed at once")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.Canceled, "the next one waits for the interval")
+}
@@ -0,0 +1,101 @@
+package dcsdk
+
+import (
+	"sort"
+	"strings"
+
+	clickhousepb "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkapb "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkpb "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	transferpb "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	visualizationpb "github.com/doublecloud/go-genproto/doublecloud/visualization/v1"
+	"google.golang.org/grpc"
+)
+
+// MethodInfo describes a gRPC method the service clients of the SDK can invoke.
+type MethodInfo struct {
+	// FullMethod is the full gRPC method name, e.g. "/doublecloud.kafka.v1.TopicService/Create".
+	FullMethod string `json:"full_method"`
+	// Kind is the service owning the method.
+	Kind ServiceKind `json:"kind"`
+	// Read reports whether the method only reads, i.e. it is a Get or List method.
+	// It is the classification the read cache uses to tell reads from mutating calls.
+	Read bool `json:"read"`
+	// Destructive reports whether the mutating method deletes, stops or revokes something.
+	Destructive bool `json:"destructive"`
+}
+
+// catalogServices are the gRPC services behind the service clients of the SDK.
+var catalogServices = []struct {
+	kind ServiceKind
+	desc *grpc.ServiceDesc
+}{
+	{ClickHouseServiceID, &clickhousepb.BackupService_ServiceDesc},
+	{ClickHouseServiceID, &clickhousepb.ClusterService_ServiceDesc},
+	{ClickHouseServiceID, &clickhousepb.OperationService_ServiceDesc},
+	{ClickHouseServiceID, &clickhousepb.VersionService_ServiceDesc},
+	{KafkaServiceID, &kafkapb.ClusterService_ServiceDesc},
+	{KafkaServiceID, &kafkapb.OperationService_ServiceDesc},
+	{KafkaServiceID, &kafkapb.TopicService_ServiceDesc},
+	{KafkaServiceID, &kafkapb.UserService_ServiceDesc},
+	{KafkaServiceID, &kafkapb.VersionService_ServiceDesc},
+	{VpcServiceID, &networkpb.NetworkConnectionService_ServiceDesc},
+	{VpcServiceID, &networkpb.NetworkService_ServiceDesc},
+	{VpcServiceID, &networkpb.OperationService_ServiceDesc},
+	{TransferServiceID, &transferpb.EndpointService_ServiceDesc},
+	{TransferServiceID, &transferpb.OperationService_ServiceDesc},
+	{TransferServiceID, &transferpb.TransferService_ServiceDesc},
+	{VisualizationServiceID, &visualizationpb.WorkbookService_ServiceDesc},
+}
+
+// mutatingMethods classifies the mutating methods by name: whether they are destructive.
+// Methods missing here are reported as destructive.
+var mutatingMethods = map[string]bool{
+	"Activate":              false,
+	"AdviseDatasetFields":   false,
+	"Create":                false,
+	"CreateConnection":      false,
+	"Deactivate":            true,
+	"Delete":                true,
+	"DeleteConnection":      true,
+	"GrantPermission":       false,
+	"Import":                false,
+	"RescheduleMaintenance": false,
+	"ResetCredentials":      true,
+	"Restore":               false,
+	"RevokePermission":      true,
+	"Start":                 false,
+	"Stop":                  true,
+	"Update":                false,
+	"UpdateConnection":      false,
+}
+
+// isDestructiveMethod reports whether the method is destructive and whether it is classified.
+func isDestructiveMethod(method string) (destructive, ok bool) {
+	if isReadMethod(method) {
+		return false, true
+	}
+	destructive, ok = mutatingMethods[method[strings.LastIndex(method, "/")+1:]]
+	return destructive || !ok, ok
+}
+
+// MethodCatalog returns every gRPC method the service clients of the SDK can invoke, sorted by
+// the full method name, e.g. to derive the permissions a workload needs. The list only changes
+// with the SDK version.
+func (sdk *SDK) MethodCatalog() []MethodInfo {
+	var methods []MethodInfo
+	for _, s := range catalogServices {
+		for _, m := range s.desc.Methods {
+			method := "/" + s.desc.ServiceName + "/" + m.MethodName
+			destructive, _ := isDestructiveMethod(method)
+			methods = append(methods, MethodInfo{
+				FullMethod:  method,
+				Kind:        s.kind,
+				Read:        isReadMethod(method),
+				Destructive: destructive,
+			})
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].FullMethod < methods[j].FullMethod })
+	return methods
+}
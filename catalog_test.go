@@ -0,0 +1,93 @@
+package dcsdk
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// invokedMethods calls every service client method of the SDK and returns the gRPC methods
+// they invoke against a server failing every call.
+func invokedMethods(t *testing.T) map[string]bool {
+	var mu sync.Mutex
+	invoked := map[string]bool{}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		mu.Lock()
+		invoked[method] = true
+		mu.Unlock()
+		return status.Error(codes.Unimplemented, "unimplemented")
+	}))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	sdk, err := Build(context.Background(), Config{Credentials: NewIAMTokenCredentials("test-token"), Plaintext: true},
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sdk.Shutdown(context.Background()) })
+
+	var wg sync.WaitGroup
+	for _, method := range serviceClientMethods(sdk) {
+		method := method
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = callWithContext(ctx, method)
+		}()
+	}
+	wg.Wait()
+	return invoked
+}
+
+func TestMethodCatalog_CoversServiceClients(t *testing.T) {
+	catalog := map[string]bool{}
+	for _, m := range (&SDK{}).MethodCatalog() {
+		catalog[m.FullMethod] = true
+	}
+	invoked := invokedMethods(t)
+	require.NotEmpty(t, invoked)
+	for method := range invoked {
+		assert.True(t, catalog[method], "%s is invoked by a service client but missing in catalogServices", method)
+	}
+	for method := range catalog {
+		assert.True(t, invoked[method], "%s is in the catalog but no service client invokes it", method)
+	}
+}
+
+func TestMethodCatalog_Classified(t *testing.T) {
+	catalog := (&SDK{}).MethodCatalog()
+	require.NotEmpty(t, catalog)
+	for _, m := range catalog {
+		_, ok := isDestructiveMethod(m.FullMethod)
+		assert.True(t, ok, "%s is not classified in mutatingMethods", m.FullMethod)
+		assert.NotEmpty(t, m.Kind, m.FullMethod)
+	}
+}
+
+func TestMethodCatalog(t *testing.T) {
+	byMethod := map[string]MethodInfo{}
+	for _, m := range (&SDK{}).MethodCatalog() {
+		byMethod[m.FullMethod] = m
+	}
+	assert.Equal(t, MethodInfo{FullMethod: "/doublecloud.kafka.v1.TopicService/List", Kind: KafkaServiceID, Read: true},
+		byMethod["/doublecloud.kafka.v1.TopicService/List"])
+	assert.Equal(t, MethodInfo{FullMethod: "/doublecloud.clickhouse.v1.ClusterService/Update", Kind: ClickHouseServiceID},
+		byMethod["/doublecloud.clickhouse.v1.ClusterService/Update"])
+	assert.Equal(t, MethodInfo{FullMethod: "/doublecloud.network.v1.NetworkService/Delete", Kind: VpcServiceID, Destructive: true},
+		byMethod["/doublecloud.network.v1.NetworkService/Delete"])
+	assert.True(t, byMethod["/doublecloud.transfer.v1.TransferService/Deactivate"].Destructive)
+}
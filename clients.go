@@ -0,0 +1,42 @@
+package dcsdk
+
+import (
+	clickhousepb "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkapb "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkpb "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	transferpb "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	visualizationpb "github.com/doublecloud/go-genproto/doublecloud/visualization/v1"
+
+	"github.com/doublecloud/go-sdk/gen/clickhouse"
+	"github.com/doublecloud/go-sdk/gen/kafka"
+	"github.com/doublecloud/go-sdk/gen/network"
+	"github.com/doublecloud/go-sdk/gen/transfer"
+	"github.com/doublecloud/go-sdk/gen/visualization"
+)
+
+// The clients of the SDK implement the client interfaces of genproto. The operation clients
+// are routed by runtime assertions against them, see operationClient and the kinds of the
+// operation package: a change of the interfaces upstream breaks the build here rather than
+// the polls.
+var (
+	_ clickhousepb.BackupServiceClient    = (*clickhouse.BackupServiceClient)(nil)
+	_ clickhousepb.ClusterServiceClient   = (*clickhouse.ClusterServiceClient)(nil)
+	_ clickhousepb.OperationServiceClient = (*clickhouse.OperationServiceClient)(nil)
+	_ clickhousepb.VersionServiceClient   = (*clickhouse.VersionServiceClient)(nil)
+
+	_ kafkapb.ClusterServiceClient   = (*kafka.ClusterServiceClient)(nil)
+	_ kafkapb.OperationServiceClient = (*kafka.OperationServiceClient)(nil)
+	_ kafkapb.TopicServiceClient     = (*kafka.TopicServiceClient)(nil)
+	_ kafkapb.UserServiceClient      = (*kafka.UserServiceClient)(nil)
+	_ kafkapb.VersionServiceClient   = (*kafka.VersionServiceClient)(nil)
+
+	_ networkpb.NetworkConnectionServiceClient = (*network.NetworkConnectionServiceClient)(nil)
+	_ networkpb.NetworkServiceClient           = (*network.NetworkServiceClient)(nil)
+	_ networkpb.OperationServiceClient         = (*network.OperationServiceClient)(nil)
+
+	_ transferpb.EndpointServiceClient  = (*transfer.EndpointServiceClient)(nil)
+	_ transferpb.OperationServiceClient = (*transfer.OperationServiceClient)(nil)
+	_ transferpb.TransferServiceClient  = (*transfer.TransferServiceClient)(nil)
+
+	_ visualizationpb.WorkbookServiceClient = (*visualization.WorkbookServiceClient)(nil)
+)
@@ -0,0 +1,57 @@
+package dcsdk
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// dateResolution is the resolution of the Date header: its timestamps are truncated to
+// seconds, so they are half a second early on average.
+const dateResolution = time.Second
+
+// ClockSkew returns the estimated offset of the API server clock from the local one: server
+// time minus local time. It is measured from the Date headers of responses and the creation
+// time of operations started by the calls of the SDK. It reports false until measured.
+func (sdk *SDK) ClockSkew() (time.Duration, bool) {
+	return sdk.skew.Offset()
+}
+
+// clockSkew returns the estimate of the clock offset for operations, see operation.WithClockSkew.
+func (sdk *SDK) clockSkew() time.Duration {
+	offset, _ := sdk.skew.Offset()
+	return offset
+}
+
+// interceptClockSkew feeds the clock skew estimator. It is the last interceptor of the chain,
+// so that the measured call time doesn't include e.g. token exchanges.
+func (sdk *SDK) interceptClockSkew(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var headers metadata.MD
+	sent := now()
+	err := invoker(ctx, method, req, reply, cc, append(opts[:len(opts):len(opts)], grpc.Header(&headers))...)
+	if err != nil {
+		return err
+	}
+	if server, ok := responseTime(method, reply, headers); ok {
+		sdk.skew.Observe(server, sent, now())
+	}
+	return nil
+}
+
+// responseTime returns the server time of the response: its Date header, or the creation time
+// of the operation started by a mutating call.
+func responseTime(method string, reply interface{}, headers metadata.MD) (time.Time, bool) {
+	if vals := headers.Get("date"); len(vals) > 0 {
+		if t, err := http.ParseTime(vals[0]); err == nil {
+			return t.Add(dateResolution / 2), true
+		}
+	}
+	if op, ok := reply.(*dcv1.Operation); ok && !isReadMethod(method) && op.GetCreateTime() != nil {
+		return op.GetCreateTime().AsTime(), true
+	}
+	return time.Time{}, false
+}
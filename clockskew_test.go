@@ -0,0 +1,61 @@
+package dcsdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// skewedClusters runs a clock ahead of the local one by offset, sending it in the Date
+// header of Get responses and in the creation time of operations.
+type skewedClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	offset time.Duration
+}
+
+func (s *skewedClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	return &dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING, CreateTime: timestamppb.New(time.Now().Add(s.offset))}, nil
+}
+
+func (s *skewedClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	date := time.Now().Add(s.offset).UTC().Format(http.TimeFormat)
+	if err := grpc.SetHeader(ctx, metadata.Pairs("date", date)); err != nil {
+		return nil, err
+	}
+	return &clickhouse.Cluster{Id: req.ClusterId}, nil
+}
+
+func TestClockSkew(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, &skewedClusters{offset: -30 * time.Second})
+	})
+	ctx := context.Background()
+	_, ok := sdk.ClockSkew()
+	assert.False(t, ok)
+
+	_, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: "chc1"})
+	require.NoError(t, err)
+	skew, ok := sdk.ClockSkew()
+	require.True(t, ok)
+	assert.InDelta(t, float64(-30*time.Second), float64(skew), float64(time.Second), "measured from the Date header")
+
+	op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "events"}))
+	require.NoError(t, err)
+	skew, _ = sdk.ClockSkew()
+	assert.InDelta(t, float64(-30*time.Second), float64(skew), float64(time.Second))
+
+	// The operation was just created, but it is 30s old by the local clock.
+	assert.InDelta(t, float64(30*time.Second), float64(op.Age()), float64(time.Second))
+	assert.Less(t, op.Age(operation.WithSkewCorrection(true)), time.Second)
+}
@@ -0,0 +1,194 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/retry"
+)
+
+// ErrImmutableConfig is matched by errors.Is for every *ImmutableConfigError.
+var ErrImmutableConfig = errors.New("sdk: config field can't be changed at runtime")
+
+// ImmutableConfigError is returned by UpdateConfig for changes of the fields that are fixed
+// when the SDK is built.
+type ImmutableConfigError struct {
+	// Fields are the names of the changed fields, e.g. "Endpoint".
+	Fields []string
+}
+
+func (e *ImmutableConfigError) Error() string {
+	return fmt.Sprintf("sdk: config fields can't be changed at runtime: %s", strings.Join(e.Fields, ", "))
+}
+
+func (e *ImmutableConfigError) Is(target error) bool { return target == ErrImmutableConfig }
+
+// MutableConfig is the config passed to the update function of UpdateConfig. Only
+// Credentials, DefaultLabels, RequestMutators, Retry, OnWorkflowEnd, Metrics, PollPolicy,
+// Logger and WaitTelemetrySampling may be changed: the other fields, such as Endpoint,
+// TLSConfig and ReadCache, are fixed when the SDK is built.
+type MutableConfig struct {
+	Config
+}
+
+// immutableFields are the fields of Config fixed when the SDK is built.
+var immutableFields = []string{
+	"Endpoint", "Plaintext", "TLSConfig", "SecurityProfile", "Environment", "EnvironmentDetector",
+	"ReadCache", "NegativeCacheTTL", "MetricLabelLimit", "MetricLabelOverflow", "StatusFeedURL", "StatusFeedInterval", "JSONEncoding",
+	"MaxConcurrentWaits", "WaitJournal", "CheckpointStore", "CheckpointInterval",
+}
+
+// configSnapshot is the config of the SDK as seen by a call: interceptors read it once per
+// call and UpdateConfig replaces it as a whole.
+type configSnapshot struct {
+	Config
+	retry *retry.Interceptor
+	// credentials counts the changes of Config.Credentials, see credentialsVersioner.
+	credentials int
+	// credentialsRotatedAt is the time of the last change of Config.Credentials, zero if
+	// none, see operation.WithCredentialsRotation.
+	credentialsRotatedAt time.Time
+}
+
+func newConfigSnapshot(conf Config, credentials int) *configSnapshot {
+	return &configSnapshot{Config: conf, retry: retry.NewInterceptor(conf.Retry), credentials: credentials}
+}
+
+var emptyConfigSnapshot = newConfigSnapshot(Config{}, 0)
+
+// config returns the current config of the SDK.
+func (sdk *SDK) config() *configSnapshot {
+	if s := sdk.snapshot.Load(); s != nil {
+		return s
+	}
+	return emptyConfigSnapshot
+}
+
+// credentialsRotatedAt returns the time Config.Credentials were last replaced by
+// UpdateConfig, zero if never.
+func (sdk *SDK) credentialsRotatedAt() time.Time {
+	return sdk.config().credentialsRotatedAt
+}
+
+// UpdateConfig applies update to a copy of the config of the SDK and swaps the result in
+// atomically: calls in flight keep the config they started with, later calls see all the
+// changes at once. The changes are validated like the config of Build; on error the config
+// is left as is. Changes of immutable fields fail with *ImmutableConfigError, see
+// MutableConfig. The IAM tokens cached for replaced credentials are not used anymore.
+// Concurrent updates are applied one after another.
+func (sdk *SDK) UpdateConfig(update func(c *MutableConfig)) error {
+	sdk.configMu.Lock()
+	defer sdk.configMu.Unlock()
+	snapshot := sdk.config()
+	current := snapshot.Config
+	c := &MutableConfig{Config: current}
+	c.DefaultLabels = make(map[string]string, len(current.DefaultLabels))
+	for k, v := range current.DefaultLabels {
+		c.DefaultLabels[k] = v
+	}
+	if err := operation.SafeCall("config update", func() error {
+		update(c)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if fields := changedFields(current, c.Config, immutableFields); len(fields) > 0 {
+		return &ImmutableConfigError{Fields: fields}
+	}
+	credentials, rotatedAt := snapshot.credentials, snapshot.credentialsRotatedAt
+	if len(changedFields(current, c.Config, []string{"Credentials"})) > 0 {
+		if err := prepareCredentials(&c.Config); err != nil {
+			return err
+		}
+		credentials, rotatedAt = credentials+1, now()
+	}
+	updated := newConfigSnapshot(c.Config, credentials)
+	updated.credentialsRotatedAt = rotatedAt
+	sdk.snapshot.Store(updated)
+	return nil
+}
+
+// changedFields returns the names of the fields differing between a and b.
+func changedFields(a, b Config, names []string) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var changed []string
+	for _, name := range names {
+		fa, fb := va.FieldByName(name), vb.FieldByName(name)
+		if !sameValue(fa, fb) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// sameValue compares comparable values with ==, funcs by pointer and others deeply.
+func sameValue(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Func {
+		return a.Pointer() == b.Pointer()
+	}
+	if a.Kind() == reflect.Interface && (a.IsNil() || b.IsNil()) {
+		return a.IsNil() == b.IsNil()
+	}
+	if a.Kind() == reflect.Interface {
+		a, b = a.Elem(), b.Elem()
+		if a.Type() != b.Type() {
+			return false
+		}
+	}
+	if a.Type().Comparable() {
+		return a.Interface() == b.Interface()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// prepareCredentials validates the credentials of conf and applies its security profile.
+func prepareCredentials(conf *Config) error {
+	if conf.Credentials == nil {
+		return errors.New("credentials required")
+	}
+	switch creds := conf.Credentials.(type) {
+	case ExchangeableCredentials, NonExchangeableCredentials:
+	default:
+		return fmt.Errorf("unsupported credentials type %T", creds)
+	}
+	if creds, ok := conf.Credentials.(profiledCredentials); ok {
+		var err error
+		conf.Credentials, err = creds.withSecurityProfile(conf.SecurityProfile)
+		if err != nil {
+			return err
+		}
+	}
+	return checkEnvironment(*conf)
+}
+
+type configKey struct{}
+
+// callConfig returns the config of the call of ctx, the current one outside of calls.
+func (sdk *SDK) callConfig(ctx context.Context) *configSnapshot {
+	if s, ok := ctx.Value(configKey{}).(*configSnapshot); ok {
+		return s
+	}
+	return sdk.config()
+}
+
+func (sdk *SDK) credentialsVersion(ctx context.Context) int {
+	return sdk.callConfig(ctx).credentials
+}
+
+// interceptConfig pins the current config for the interceptors of the call. It runs first.
+func (sdk *SDK) interceptConfig(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(context.WithValue(ctx, configKey{}, sdk.config()), method, req, reply, cc, opts...)
+}
+
+// interceptRetry retries the calls by the retry config of the call.
+func (sdk *SDK) interceptRetry(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return sdk.callConfig(ctx).retry.InterceptUnary(ctx, method, req, reply, cc, invoker, opts...)
+}
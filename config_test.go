@@ -0,0 +1,165 @@
+package dcsdk
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type configCall struct {
+	name   string
+	token  string
+	labels map[string]string
+}
+
+// configRecordingEndpoints records the token and the labels of the created endpoints.
+type configRecordingEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+
+	mu    sync.Mutex
+	calls []configCall
+}
+
+func (s *configRecordingEndpoints) Create(ctx context.Context, req *transfer.CreateEndpointRequest) (*dcv1.Operation, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	call := configCall{name: req.Name, labels: req.Labels}
+	if auth := md.Get("authorization"); len(auth) > 0 {
+		call.token = strings.TrimPrefix(auth[0], "Bearer ")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	return &dcv1.Operation{Id: "dteo1", Status: dcv1.Operation_STATUS_DONE}, nil
+}
+
+func (s *configRecordingEndpoints) last() configCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[len(s.calls)-1]
+}
+
+func newConfigTestSDK(t *testing.T) (*SDK, *configRecordingEndpoints) {
+	rec := &configRecordingEndpoints{}
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials:   NewIAMTokenCredentials("token-a"),
+		DefaultLabels: map[string]string{"team": "data"},
+	}, func(s *grpc.Server) {
+		transfer.RegisterEndpointServiceServer(s, rec)
+	})
+	return sdk, rec
+}
+
+func createConfigEndpoint(t *testing.T, sdk *SDK) {
+	_, err := sdk.Transfer().Endpoint().Create(context.Background(), &transfer.CreateEndpointRequest{Name: "e"})
+	require.NoError(t, err)
+}
+
+func TestUpdateConfig_Immutable(t *testing.T) {
+	sdk, rec := newConfigTestSDK(t)
+	endpoint := sdk.config().Endpoint
+
+	err := sdk.UpdateConfig(func(c *MutableConfig) {
+		c.Endpoint = "api.example.com:443"
+		c.TLSConfig = &tls.Config{}
+		c.DefaultLabels["team"] = "analytics"
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrImmutableConfig))
+	var immutable *ImmutableConfigError
+	require.True(t, errors.As(err, &immutable))
+	assert.Equal(t, []string{"Endpoint", "TLSConfig"}, immutable.Fields)
+
+	assert.Equal(t, endpoint, sdk.config().Endpoint)
+	createConfigEndpoint(t, sdk)
+	assert.Equal(t, map[string]string{"team": "data"}, rec.last().labels, "a failed update changes nothing")
+}
+
+func TestUpdateConfig_Mutable(t *testing.T) {
+	sdk, rec := newConfigTestSDK(t)
+	createConfigEndpoint(t, sdk)
+	assert.Equal(t, configCall{name: "e", token: "token-a", labels: map[string]string{"team": "data"}}, rec.last())
+
+	stats := &MetricsStats{}
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) {
+		c.Credentials = NewIAMTokenCredentials("token-b")
+		c.DefaultLabels["env"] = "prod"
+		c.Metrics = stats
+	}))
+	createConfigEndpoint(t, sdk)
+	assert.Equal(t, configCall{name: "e", token: "token-b", labels: map[string]string{"team": "data", "env": "prod"}}, rec.last(), "the cached token of the old credentials is not used")
+	assert.Len(t, stats.Series(), 1)
+
+	err := sdk.UpdateConfig(func(c *MutableConfig) { c.Credentials = nil })
+	assert.Error(t, err)
+	createConfigEndpoint(t, sdk)
+	assert.Equal(t, "token-b", rec.last().token, "invalid credentials are rejected")
+}
+
+func TestUpdateConfig_Panic(t *testing.T) {
+	sdk, rec := newConfigTestSDK(t)
+
+	err := sdk.UpdateConfig(func(c *MutableConfig) {
+		c.DefaultLabels["env"] = "prod"
+		panic("boom")
+	})
+	assert.Error(t, err)
+	createConfigEndpoint(t, sdk)
+	assert.Equal(t, map[string]string{"team": "data"}, rec.last().labels)
+}
+
+// TestUpdateConfig_Concurrent checks that every call sees the token and the labels of a
+// single config, while the config is updated.
+func TestUpdateConfig_Concurrent(t *testing.T) {
+	sdk, rec := newConfigTestSDK(t)
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) { c.DefaultLabels["token"] = "token-a" }))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			token := []string{"token-a", "token-b"}[i%2]
+			assert.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) {
+				c.Credentials = NewIAMTokenCredentials(token)
+				c.DefaultLabels["token"] = token
+			}))
+		}
+	}()
+	var calls sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		calls.Add(1)
+		go func() {
+			defer calls.Done()
+			for j := 0; j < 50; j++ {
+				_, err := sdk.Transfer().Endpoint().Create(context.Background(), &transfer.CreateEndpointRequest{Name: "e"})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	calls.Wait()
+	close(done)
+	wg.Wait()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	require.Len(t, rec.calls, 200)
+	for _, call := range rec.calls {
+		assert.Equal(t, call.labels["token"], call.token)
+	}
+}
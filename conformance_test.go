@@ -0,0 +1,274 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	clickhousepb "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkapb "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/doublecloud/go-sdk/gen/clickhouse"
+	"github.com/doublecloud/go-sdk/gen/kafka"
+	"github.com/doublecloud/go-sdk/gen/transfer"
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// Every exported method taking a context must return promptly once the context is done,
+// with an error derived from it: the context error itself, possibly wrapped, or a status
+// with the matching code. The conformance tests enforce this for every service client
+// method reachable from SDK against a server that never responds.
+
+// conformanceBound is how long a method may take to return after its context is done.
+const conformanceBound = time.Second
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// newSilentSDK returns an SDK connected to a server that accepts every call and never responds.
+func newSilentSDK(t *testing.T) *SDK {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	sdk, err := Build(context.Background(), Config{Credentials: NewIAMTokenCredentials("test-token"), Plaintext: true},
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sdk.Shutdown(context.Background()) })
+	return sdk
+}
+
+// conformanceArgs are the arguments after ctx of the methods validating them before
+// any call, which are called with zero arguments otherwise.
+var conformanceArgs = map[string][]interface{}{
+	"ClickHouse.Cluster.Clone":                      {"chc1", clickhouse.CloneOptions{Name: "clone"}},
+	"ClickHouse.Cluster.RescheduleMaintenanceUntil": {"chc1", time.Now().Add(time.Hour)},
+	"Kafka.Cluster.RescheduleMaintenanceUntil":      {"kfc1", time.Now().Add(time.Hour)},
+	"Kafka.Cluster.Import":                          {"kfc1", &kafka.ClusterExport{Topics: []*kafkapb.TopicSpec{{Name: "topic1"}}}, kafka.ImportOptions{}},
+	"Kafka.Topic.CreateBatch":                       {"kfc1", []*kafkapb.TopicSpec{{Name: "topic1"}}, kafka.BatchOptions{}},
+	"Transfer.Endpoint.UploadSample":                {"dte1", strings.NewReader("id\n"), transfer.SampleOptions{}},
+}
+
+// conformanceMethod is a method of a service client taking a context as the first argument.
+type conformanceMethod struct {
+	name string
+	fn   reflect.Value
+	args []interface{}
+}
+
+// serviceClientMethods lists the context-taking methods of the service clients of every
+// service group of the SDK, e.g. sdk.Kafka().Cluster().Get.
+func serviceClientMethods(sdk *SDK) []conformanceMethod {
+	groups := map[string]interface{}{
+		"ClickHouse":    sdk.ClickHouse(),
+		"Kafka":         sdk.Kafka(),
+		"Network":       sdk.Network(),
+		"Transfer":      sdk.Transfer(),
+		"Visualization": sdk.Visualization(),
+	}
+	var methods []conformanceMethod
+	for groupName, group := range groups {
+		g := reflect.ValueOf(group)
+		for i := 0; i < g.NumMethod(); i++ {
+			m := g.Method(i)
+			if m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+				continue
+			}
+			client := m.Call(nil)[0]
+			clientName := groupName + "." + g.Type().Method(i).Name
+			for j := 0; j < client.NumMethod(); j++ {
+				cm := client.Method(j)
+				if cm.Type().NumIn() == 0 || cm.Type().In(0) != contextType {
+					continue
+				}
+				name := clientName + "." + client.Type().Method(j).Name
+				methods = append(methods, conformanceMethod{name: name, fn: cm, args: conformanceArgs[name]})
+			}
+		}
+	}
+	return methods
+}
+
+// callWithContext calls the method with ctx and the conformance arguments, if any,
+// and returns its error. Methods returning iterators are driven with Next and report
+// the iterator error.
+func callWithContext(ctx context.Context, method conformanceMethod) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	fn := method.fn
+	typ := fn.Type()
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	for _, arg := range method.args {
+		args = append(args, reflect.ValueOf(arg))
+	}
+	for i := len(args); i < typ.NumIn(); i++ {
+		if typ.IsVariadic() && i == typ.NumIn()-1 {
+			break
+		}
+		in := typ.In(i)
+		if in.Kind() == reflect.Ptr {
+			args = append(args, reflect.New(in.Elem()))
+		} else {
+			args = append(args, reflect.Zero(in))
+		}
+	}
+	out := fn.Call(args)
+	last := out[len(out)-1]
+	if last.Type().Implements(errorType) {
+		if last.IsNil() {
+			return nil
+		}
+		return last.Interface().(error)
+	}
+	it := last
+	next, errMethod := it.MethodByName("Next"), it.MethodByName("Error")
+	if !next.IsValid() || !errMethod.IsValid() {
+		return fmt.Errorf("unexpected results %v", typ)
+	}
+	for next.Call(nil)[0].Bool() {
+	}
+	if e := errMethod.Call(nil)[0]; !e.IsNil() {
+		return e.Interface().(error)
+	}
+	return nil
+}
+
+func isContextError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		st, ok := status.FromError(err)
+		if ok && (st.Code() == codes.Canceled || st.Code() == codes.DeadlineExceeded) {
+			return true
+		}
+	}
+	return false
+}
+
+func assertPromptReturn(t *testing.T, method conformanceMethod, ctx context.Context, done time.Time) {
+	t.Helper()
+	errCh := make(chan error, 1)
+	go func() { errCh <- callWithContext(ctx, method) }()
+	select {
+	case err := <-errCh:
+		assert.WithinDuration(t, done, time.Now(), conformanceBound, "%s returned late", method.name)
+		assert.True(t, isContextError(err), "%s returned a non-context error: %v", method.name, err)
+	case <-time.After(time.Until(done) + conformanceBound):
+		t.Errorf("%s did not return within %s after its context was done", method.name, conformanceBound)
+	}
+}
+
+// assertAllPromptReturn calls the methods concurrently, each with a context from newContext.
+func assertAllPromptReturn(t *testing.T, methods []conformanceMethod, newContext func() (context.Context, context.CancelFunc)) {
+	t.Helper()
+	require.NotEmpty(t, methods)
+	var wg sync.WaitGroup
+	for _, method := range methods {
+		method := method
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := newContext()
+			defer cancel()
+			done := time.Now()
+			if deadline, ok := ctx.Deadline(); ok {
+				done = deadline
+			}
+			assertPromptReturn(t, method, ctx, done)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConformance_CancelledContext(t *testing.T) {
+	sdk := newSilentSDK(t)
+	assertAllPromptReturn(t, serviceClientMethods(sdk), func() (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx, cancel
+	})
+}
+
+func TestConformance_ContextDoneWhileBlocked(t *testing.T) {
+	sdk := newSilentSDK(t)
+	assertAllPromptReturn(t, serviceClientMethods(sdk), func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 50*time.Millisecond)
+	})
+}
+
+// sdkMethods lists the blocking SDK methods outside of the service clients.
+func sdkMethods(t *testing.T, sdk *SDK) []conformanceMethod {
+	wait := func(id string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			op, err := sdk.WrapOperation(&dcv1.Operation{Id: id, Status: dcv1.Operation_STATUS_PENDING}, nil)
+			require.NoError(t, err)
+			return op.Wait(ctx)
+		}
+	}
+	var methods []conformanceMethod
+	for name, call := range map[string]func(ctx context.Context) error{
+		"Apply": func(ctx context.Context) error {
+			_, err := sdk.Apply(ctx, []Step{{Name: "a", Run: func(ctx context.Context) (*operation.Operation, error) {
+				return sdk.WrapOperation(sdk.ClickHouse().Cluster().Delete(ctx, &clickhousepb.DeleteClusterRequest{}))
+			}}}, ApplyOptions{})
+			return err
+		},
+		"ClickHouse operation Wait": wait("cho1"),
+		"Kafka operation Wait":      wait("kfo1"),
+		"Transfer operation Wait":   wait("dtj1"),
+		"Network operation Wait":    wait("2e4a5ad7-5c1f-4d48-8bb4-2b3a8f3f8f12"),
+	} {
+		methods = append(methods, conformanceMethod{name: name, fn: reflect.ValueOf(call)})
+	}
+	return methods
+}
+
+func TestConformance_SDK(t *testing.T) {
+	sdk := newSilentSDK(t)
+	assertAllPromptReturn(t, sdkMethods(t, sdk), func() (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx, cancel
+	})
+	assertAllPromptReturn(t, sdkMethods(t, sdk), func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 50*time.Millisecond)
+	})
+}
+
+func TestConformance_TokenExchange(t *testing.T) {
+	creds, err := ServiceAccountKey(testServiceAccountKey(t, 2048))
+	require.NoError(t, err)
+	sdk, err := Build(context.Background(), Config{Credentials: creds})
+	require.NoError(t, err)
+
+	// Only the cancelled context is checked: a live one would reach the real token service.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assertPromptReturn(t, conformanceMethod{name: "CreateIAMToken", fn: reflect.ValueOf(func(ctx context.Context) error {
+		_, err := sdk.CreateIAMToken(ctx)
+		return err
+	})}, ctx, time.Now())
+}
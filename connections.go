@@ -0,0 +1,64 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrSharedConnection is matched by errors.Is for every *SharedConnectionError.
+var ErrSharedConnection = errors.New("sdk: connection shared with other services")
+
+// SharedConnectionError is returned by CloseService for a service whose endpoint is the
+// one of other services: their connection is shared and is not closed.
+type SharedConnectionError struct {
+	Service ServiceKind
+	Address string
+	// SharedWith are the other services of the endpoint, sorted.
+	SharedWith []ServiceKind
+}
+
+func (e *SharedConnectionError) Error() string {
+	return fmt.Sprintf("sdk: connection of %s to %s is shared with %v, not closed", e.Service, e.Address, e.SharedWith)
+}
+
+func (e *SharedConnectionError) Is(target error) bool { return target == ErrSharedConnection }
+
+// CloseService closes the connection of the service, e.g. to move to another private link,
+// keeping the connections of the other services. The connection is closed once the calls
+// in flight on it end; the calls made meanwhile and later dial a new one. If ctx is done
+// first, the connection is closed anyway, failing the calls still in flight, and the error
+// of ctx is returned. The connections shared with other services are not closed, see
+// SharedConnectionError. It does nothing if the service has no connection yet.
+func (sdk *SDK) CloseService(ctx context.Context, kind ServiceKind) error {
+	if err := sdk.ensureInit(ctx); err != nil {
+		return err
+	}
+	endpoint, ok := sdk.Endpoint(kind)
+	if !ok {
+		return &ServiceIsNotAvailableError{
+			ServiceID:           kind,
+			APIEndpoint:         sdk.config().Endpoint,
+			availableServiceIDs: sdk.KnownServices(),
+		}
+	}
+	if shared := sdk.servicesOf(endpoint.Address, kind); len(shared) > 0 {
+		return &SharedConnectionError{Service: kind, Address: endpoint.Address, SharedWith: shared}
+	}
+	return sdk.cc.CloseConn(ctx, endpoint.Address)
+}
+
+// servicesOf returns the services other than kind with an endpoint of address, sorted.
+func (sdk *SDK) servicesOf(address string, kind ServiceKind) []ServiceKind {
+	sdk.endpoints.mu.Lock()
+	defer sdk.endpoints.mu.Unlock()
+	var kinds []ServiceKind
+	for k, ep := range sdk.endpoints.ep {
+		if k != kind && ep.Address == address {
+			kinds = append(kinds, k)
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
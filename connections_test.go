@@ -0,0 +1,76 @@
+package dcsdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func newConnectionsTestSDK(t *testing.T) *SDK {
+	rec := &labelRecorder{}
+	return newTestSDK(t, func(s *grpc.Server) {
+		transfer.RegisterTransferServiceServer(s, labelRecordingTransfers{labelRecorder: rec})
+		clickhouse.RegisterClusterServiceServer(s, labelRecordingClusters{labelRecorder: rec})
+	})
+}
+
+func TestCloseService(t *testing.T) {
+	sdk := newConnectionsTestSDK(t)
+	ctx := context.Background()
+	require.NoError(t, sdk.CloseService(ctx, TransferServiceID), "no connection yet")
+
+	_, err := sdk.Transfer().Transfer().Create(ctx, &transfer.CreateTransferRequest{Name: "t"})
+	require.NoError(t, err)
+	_, err = sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "c"})
+	require.NoError(t, err)
+	transferConn, err := sdk.getConn(TransferServiceID)(ctx)
+	require.NoError(t, err)
+	clickhouseConn, err := sdk.getConn(ClickHouseServiceID)(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, sdk.CloseService(ctx, TransferServiceID))
+	assert.Equal(t, connectivity.Shutdown, transferConn.GetState())
+	assert.NotEqual(t, connectivity.Shutdown, clickhouseConn.GetState(), "the other services keep their connection")
+	same, err := sdk.getConn(ClickHouseServiceID)(ctx)
+	require.NoError(t, err)
+	assert.Same(t, clickhouseConn, same)
+
+	_, err = sdk.Transfer().Transfer().Create(ctx, &transfer.CreateTransferRequest{Name: "t"})
+	require.NoError(t, err, "dialed again")
+	reopened, err := sdk.getConn(TransferServiceID)(ctx)
+	require.NoError(t, err)
+	assert.NotSame(t, transferConn, reopened)
+
+	var unavailable *ServiceIsNotAvailableError
+	assert.ErrorAs(t, sdk.CloseService(ctx, "warehouse"), &unavailable)
+}
+
+func TestCloseService_Shared(t *testing.T) {
+	sdk := newConnectionsTestSDK(t)
+	ctx := context.Background()
+	_, err := sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "c"})
+	require.NoError(t, err)
+	clickhouseEndpoint, _ := sdk.Endpoint(ClickHouseServiceID)
+	for _, kind := range []ServiceKind{TransferServiceID, KafkaServiceID} {
+		ep, _ := sdk.Endpoint(kind)
+		ep.Address = clickhouseEndpoint.Address
+	}
+	conn, err := sdk.getConn(ClickHouseServiceID)(ctx)
+	require.NoError(t, err)
+
+	err = sdk.CloseService(ctx, ClickHouseServiceID)
+	var shared *SharedConnectionError
+	require.ErrorAs(t, err, &shared)
+	assert.ErrorIs(t, err, ErrSharedConnection)
+	assert.Equal(t, []ServiceKind{KafkaServiceID, TransferServiceID}, shared.SharedWith)
+	assert.Equal(t, clickhouseEndpoint.Address, shared.Address)
+	assert.NotEqual(t, connectivity.Shutdown, conn.GetState(), "the shared connection is kept")
+	_, err = sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "c"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,86 @@
+package dcsdk
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/google/uuid"
+)
+
+// ErrNoConsoleURL is returned by ConsoleURL for the service kinds without a console page.
+var ErrNoConsoleURL = errors.New("no console url")
+
+// defaultConsoleURLs are the templates of the console pages of the resources by service
+// kind, expanded by ConsoleURL.
+var defaultConsoleURLs = map[ServiceKind]string{
+	ClickHouseServiceID:    "https://app.double.cloud/projects/{project}/clickhouse/clusters/{resource}",
+	KafkaServiceID:         "https://app.double.cloud/projects/{project}/kafka/clusters/{resource}",
+	VpcServiceID:           "https://app.double.cloud/projects/{project}/networks/{resource}",
+	TransferServiceID:      "https://app.double.cloud/projects/{project}/data-transfer/{resource}",
+	VisualizationServiceID: "https://app.double.cloud/projects/{project}/visualization/workbooks/{resource}",
+}
+
+// DefaultConsoleURLs returns the URL templates of the console pages of the resources by
+// service kind, which Config.ConsoleURLs overrides.
+func DefaultConsoleURLs() map[ServiceKind]string {
+	templates := make(map[ServiceKind]string, len(defaultConsoleURLs))
+	for kind, template := range defaultConsoleURLs {
+		templates[kind] = template
+	}
+	return templates
+}
+
+// ConsoleURL returns the URL of the console page of the resource of the kind in the
+// project, from the template of Config.ConsoleURLs or DefaultConsoleURLs. It returns
+// ErrNoConsoleURL for the kinds without a template rather than guessing one.
+func (sdk *SDK) ConsoleURL(kind ServiceKind, projectID, resourceID string) (string, error) {
+	template, ok := sdk.config().ConsoleURLs[kind]
+	if !ok {
+		template, ok = defaultConsoleURLs[kind]
+	}
+	if !ok || template == "" {
+		return "", fmt.Errorf("%w for service %q", ErrNoConsoleURL, kind)
+	}
+	if projectID == "" || resourceID == "" {
+		return "", fmt.Errorf("console url of %s resource %q in project %q: project and resource ids required", kind, resourceID, projectID)
+	}
+	return strings.NewReplacer(
+		"{project}", url.PathEscape(projectID),
+		"{resource}", url.PathEscape(resourceID),
+	).Replace(template), nil
+}
+
+// ConsoleURL returns the console URL of the resource of the operation listed, see
+// SDK.ConsoleURL.
+func (ops *Operations) ConsoleURL(s OperationSummary) (string, error) {
+	return ops.sdk.ConsoleURL(s.Kind, s.ProjectID, s.ResourceID)
+}
+
+// operationServiceKind returns the service kind of the operation ID, like operationClient,
+// "" if it is unknown.
+func operationServiceKind(id string) ServiceKind {
+	switch {
+	case strings.HasPrefix(id, operation.CLICKHOUSE_OPERATION_PREFIX):
+		return ClickHouseServiceID
+	case strings.HasPrefix(id, operation.KAFKA_OPERATION_PREFIX):
+		return KafkaServiceID
+	case strings.HasPrefix(id, operation.TRANSFER_ENDPOINTS_OPERATION_PREFIX), strings.HasPrefix(id, operation.TRANSFER_OPERATION_PREFIX):
+		return TransferServiceID
+	}
+	if _, err := uuid.Parse(id); err == nil {
+		return VpcServiceID
+	}
+	return ""
+}
+
+// consoleURLOf is the operation.ConsoleURLFunc of the operations of WrapOperation.
+func (sdk *SDK) consoleURLOf(o *operation.Operation) (string, error) {
+	kind := operationServiceKind(o.Id())
+	if kind == "" {
+		return "", fmt.Errorf("%w for %s", ErrNoConsoleURL, o)
+	}
+	return sdk.ConsoleURL(kind, o.Proto().GetProjectId(), o.ResourceId())
+}
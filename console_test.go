@@ -0,0 +1,82 @@
+package dcsdk
+
+import (
+	"testing"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+func TestConsoleURL(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	for kind, want := range map[ServiceKind]string{
+		ClickHouseServiceID:    "https://app.double.cloud/projects/prj1/clickhouse/clusters/chc1",
+		KafkaServiceID:         "https://app.double.cloud/projects/prj1/kafka/clusters/chc1",
+		VpcServiceID:           "https://app.double.cloud/projects/prj1/networks/chc1",
+		TransferServiceID:      "https://app.double.cloud/projects/prj1/data-transfer/chc1",
+		VisualizationServiceID: "https://app.double.cloud/projects/prj1/visualization/workbooks/chc1",
+	} {
+		u, err := sdk.ConsoleURL(kind, "prj1", "chc1")
+		require.NoError(t, err, kind)
+		assert.Equal(t, want, u, kind)
+	}
+	assert.Len(t, DefaultConsoleURLs(), 5, "every kind has a golden URL")
+
+	u, err := sdk.ConsoleURL(KafkaServiceID, "prj 1", "kf/1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.double.cloud/projects/prj%201/kafka/clusters/kf%2F1", u, "the IDs are escaped")
+
+	_, err = sdk.ConsoleURL("billing", "prj1", "b1")
+	assert.ErrorIs(t, err, ErrNoConsoleURL)
+	_, err = sdk.ConsoleURL(KafkaServiceID, "prj1", "")
+	assert.Error(t, err)
+}
+
+func TestConsoleURL_Config(t *testing.T) {
+	conf := Config{Credentials: NewIAMTokenCredentials("test-token"), ConsoleURLs: map[ServiceKind]string{
+		KafkaServiceID:      "https://console.example.com/{project}/kafka/{resource}",
+		ClickHouseServiceID: "",
+	}}
+	sdk := newTestSDKWithConfig(t, conf, func(s *grpc.Server) {})
+	u, err := sdk.ConsoleURL(KafkaServiceID, "prj1", "kf1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://console.example.com/prj1/kafka/kf1", u)
+	_, err = sdk.ConsoleURL(ClickHouseServiceID, "prj1", "chc1")
+	assert.ErrorIs(t, err, ErrNoConsoleURL, "an empty template disables the kind")
+	u, err = sdk.ConsoleURL(VpcServiceID, "prj1", "net1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.double.cloud/projects/prj1/networks/net1", u, "the other kinds keep the defaults")
+
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) { c.ConsoleURLs = nil }))
+	u, err = sdk.ConsoleURL(KafkaServiceID, "prj1", "kf1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.double.cloud/projects/prj1/kafka/clusters/kf1", u)
+}
+
+func TestOperation_ConsoleURL(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "dtj1", ProjectId: "prj1", Status: dcv1.Operation_STATUS_RUNNING}, nil)
+	require.NoError(t, err)
+	_, err = op.ConsoleURL()
+	assert.ErrorIs(t, err, operation.ErrResourceIDUnavailable, "the resource ID isn't known yet")
+
+	op, err = sdk.WrapOperation(&dcv1.Operation{Id: "dtj1", ProjectId: "prj1", ResourceId: "dtt1", Status: dcv1.Operation_STATUS_DONE}, nil)
+	require.NoError(t, err)
+	u, err := op.ConsoleURL()
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.double.cloud/projects/prj1/data-transfer/dtt1", u)
+
+	_, err = operation.New(nil, &dcv1.Operation{Id: "cho1", ResourceId: "chc1"}).ConsoleURL()
+	assert.ErrorIs(t, err, operation.ErrNoConsoleURL)
+}
+
+func TestOperations_ConsoleURL(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	u, err := sdk.Operations().ConsoleURL(OperationSummary{ID: "cho1", Kind: ClickHouseServiceID, ProjectID: "prj1", ResourceID: "chc1"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.double.cloud/projects/prj1/clickhouse/clusters/chc1", u)
+}
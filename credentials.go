@@ -50,17 +50,37 @@ func ServiceAccountKey(key *iamkey.Key) (Credentials, error) {
 	if err != nil {
 		return nil, err
 	}
-	return exchangeableCredentialsFunc(func() (*iamkey.CreateIamTokenRequest, error) {
-		signedJWT, err := jwtBuilder.SignedToken()
-		if err != nil {
-			return nil, sdkerrors.WithMessage(err, "JWT sign failed")
+	return &serviceAccountCredentials{jwtBuilder: jwtBuilder}, nil
+}
+
+type serviceAccountCredentials struct {
+	jwtBuilder *serviceAccountJWTBuilder
+}
+
+var _ ExchangeableCredentials = (*serviceAccountCredentials)(nil)
+
+func (c *serviceAccountCredentials) DCAPICredentials() {}
+
+func (c *serviceAccountCredentials) IAMTokenRequest() (*iamkey.CreateIamTokenRequest, error) {
+	signedJWT, err := c.jwtBuilder.SignedToken()
+	if err != nil {
+		return nil, sdkerrors.WithMessage(err, "JWT sign failed")
+	}
+	return &iamkey.CreateIamTokenRequest{
+		Identity: &iamkey.CreateIamTokenRequest_Jwt{
+			Jwt: signedJWT,
+		},
+	}, nil
+}
+
+func (c *serviceAccountCredentials) withSecurityProfile(p SecurityProfile) (Credentials, error) {
+	if p == SecurityProfileStrict {
+		if bits := c.jwtBuilder.rsaPrivateKey.N.BitLen(); bits < strictMinRSAKeyBits {
+			return nil, fmt.Errorf("%d bit service account key is not allowed by the strict security profile, at least %d bits required", bits, strictMinRSAKeyBits)
 		}
-		return &iamkey.CreateIamTokenRequest{
-			Identity: &iamkey.CreateIamTokenRequest_Jwt{
-				Jwt: signedJWT,
-			},
-		}, nil
-	}), nil
+	}
+	// The IAM Token Service accepts PS256 JWTs only, which is FIPS-approved in both profiles.
+	return c, nil
 }
 
 func newServiceAccountJWTBuilder(key *iamkey.Key) (*serviceAccountJWTBuilder, error) {
@@ -157,3 +177,19 @@ func NewIAMTokenCredentials(iamToken string) NonExchangeableCredentials {
 		iamToken: iamToken,
 	}
 }
+
+type credentialsKey struct{}
+
+// ContextWithCredentials returns a context whose calls are authorized with creds instead of
+// Config.Credentials, e.g. with credentials scoped to a tenant. Operation waits on the context
+// poll with creds too. The IAM tokens of creds are cached by their identity, so the same
+// Credentials should be reused for the calls of a tenant rather than built for each call;
+// creds must be comparable, e.g. a pointer. WithAuthAsServiceAccount takes precedence.
+func ContextWithCredentials(ctx context.Context, creds Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+func contextCredentials(ctx context.Context) Credentials {
+	creds, _ := ctx.Value(credentialsKey{}).(Credentials)
+	return creds
+}
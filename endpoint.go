@@ -0,0 +1,178 @@
+package dcsdk
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultEndpoint is the API endpoint of Config.Endpoint if it is empty.
+	DefaultEndpoint = "api.double.cloud:443"
+	// DefaultEndpointPort is the port NormalizeEndpoint adds to the endpoints without one.
+	DefaultEndpointPort = "443"
+)
+
+// endpointSchemes are the schemes NormalizeEndpoint strips.
+var endpointSchemes = []string{"https", "http", "grpcs", "grpc", "dns"}
+
+// ErrInvalidEndpoint is matched by errors.Is for every *EndpointError.
+var ErrInvalidEndpoint = errors.New("invalid endpoint")
+
+// EndpointError is returned by NormalizeEndpoint for the endpoints it can't make an address of.
+type EndpointError struct {
+	Input string
+	// Reason is what is wrong, e.g. "paths are not supported".
+	Reason string
+}
+
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("invalid endpoint %q: %s", e.Input, e.Reason)
+}
+
+func (e *EndpointError) Is(target error) bool { return target == ErrInvalidEndpoint }
+
+// NormalizeEndpoint returns the "host:port" address of the API endpoint s, given in any of
+// the usual formats: with a scheme such as https:// or grpc://, which is stripped, with or
+// without a port, DefaultEndpointPort if missing, with a trailing slash, or as an IPv4 or
+// IPv6 address, bracketed or not. The hosts are lower cased. Endpoints with credentials,
+// paths other than "/", queries or fragments fail with *EndpointError.
+//
+// The unix socket targets of gRPC, "unix:path" and "unix:///path", are returned as is, e.g.
+// for local testing.
+func NormalizeEndpoint(s string) (string, error) {
+	in := s
+	s = strings.TrimSpace(s)
+	fail := func(format string, args ...interface{}) (string, error) {
+		return "", &EndpointError{Input: in, Reason: fmt.Sprintf(format, args...)}
+	}
+	if s == "" {
+		return fail("empty")
+	}
+	if strings.HasPrefix(s, "unix:") || strings.HasPrefix(s, "unix-abstract:") {
+		return s, nil
+	}
+
+	if scheme, rest, ok := strings.Cut(s, "://"); ok {
+		if !knownEndpointScheme(strings.ToLower(scheme)) {
+			return fail("unknown scheme %q, want one of %s or none", scheme, strings.Join(endpointSchemes, ", "))
+		}
+		u, err := url.Parse("//" + rest)
+		if err != nil {
+			return fail("%v", errors.Unwrap(err))
+		}
+		switch {
+		case u.User != nil:
+			return fail("credentials are not supported, set Config.Credentials")
+		case u.RawQuery != "" || u.ForceQuery:
+			return fail("queries are not supported")
+		case u.Fragment != "":
+			return fail("fragments are not supported")
+		case strings.ToLower(scheme) == "dns" && u.Host == "":
+			// The DNS targets of gRPC, dns:///host:port, have the address in their path.
+			s = strings.TrimLeft(u.Path, "/")
+		case u.Path != "" && u.Path != "/":
+			return fail("paths are not supported, got %q", u.Path)
+		default:
+			s = u.Host
+		}
+	} else {
+		switch {
+		case strings.Contains(s, "@"):
+			return fail("credentials are not supported, set Config.Credentials")
+		case strings.ContainsAny(s, "?#"):
+			return fail("queries are not supported")
+		}
+		s = strings.TrimSuffix(s, "/")
+		if strings.Contains(s, "/") {
+			return fail("paths are not supported")
+		}
+	}
+
+	host, port, err := splitEndpoint(s)
+	if err != nil {
+		return fail("%s", err)
+	}
+	if port == "" {
+		port = DefaultEndpointPort
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fail("invalid port %q", port)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), nil
+}
+
+func knownEndpointScheme(scheme string) bool {
+	for _, s := range endpointSchemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// splitEndpoint splits the host and the port, possibly empty, of "host[:port]". IPv6
+// addresses are either bracketed, with an optional port, or bare, without port.
+func splitEndpoint(s string) (host, port string, err error) {
+	switch {
+	case s == "":
+		return "", "", errors.New("no host")
+	case strings.HasPrefix(s, "["):
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return "", "", errors.New("missing ] of the IPv6 address")
+		}
+		host, rest := s[1:end], s[end+1:]
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+			return "", "", fmt.Errorf("invalid IPv6 address %q", host)
+		}
+		if rest == "" {
+			return host, "", nil
+		}
+		if !strings.HasPrefix(rest, ":") || rest == ":" {
+			return "", "", fmt.Errorf("invalid port %q", strings.TrimPrefix(rest, ":"))
+		}
+		return host, rest[1:], nil
+	case strings.Count(s, ":") > 1:
+		if net.ParseIP(s) == nil {
+			return "", "", fmt.Errorf("invalid IPv6 address %q, bracket it to add a port", s)
+		}
+		return s, "", nil
+	}
+	host, port, found := strings.Cut(s, ":")
+	if found && port == "" {
+		return "", "", errors.New("empty port")
+	}
+	if host == "" {
+		return "", "", errors.New("no host")
+	}
+	for _, r := range host {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+			return "", "", fmt.Errorf("invalid character %q in host", r)
+		}
+	}
+	return host, port, nil
+}
+
+// Validate checks the config and normalizes its Endpoint, DefaultEndpoint if empty, with
+// NormalizeEndpoint. Build validates the config it is given.
+func (c *Config) Validate() error {
+	if c.Credentials == nil {
+		return errors.New("credentials required")
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = DefaultEndpoint
+	}
+	endpoint, err := NormalizeEndpoint(c.Endpoint)
+	if err != nil {
+		return err
+	}
+	c.Endpoint = endpoint
+	if c.Plaintext && c.SecurityProfile == SecurityProfileStrict {
+		return errors.New("plaintext connections are not allowed by the strict security profile")
+	}
+	return nil
+}
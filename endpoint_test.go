@@ -0,0 +1,83 @@
+package dcsdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	for in, want := range map[string]string{
+		"api.double.cloud":                 "api.double.cloud:443",
+		"api.double.cloud:443":             "api.double.cloud:443",
+		"api.double.cloud:8443":            "api.double.cloud:8443",
+		"https://api.double.cloud":         "api.double.cloud:443",
+		"https://api.double.cloud/":        "api.double.cloud:443",
+		"HTTPS://API.Double.Cloud:443/":    "api.double.cloud:443",
+		"grpcs://api.double.cloud:443":     "api.double.cloud:443",
+		"dns:///api.double.cloud:443":      "api.double.cloud:443",
+		"  api.double.cloud/  ":            "api.double.cloud:443",
+		"10.0.0.1":                         "10.0.0.1:443",
+		"http://10.0.0.1:8080":             "10.0.0.1:8080",
+		"::1":                              "[::1]:443",
+		"[::1]":                            "[::1]:443",
+		"[2001:db8::1]:9443":               "[2001:db8::1]:9443",
+		"https://[2001:db8::1]/":           "[2001:db8::1]:443",
+		"unix:/tmp/dc.sock":                "unix:/tmp/dc.sock",
+		"unix:///tmp/dc.sock":              "unix:///tmp/dc.sock",
+		"localhost:50051":                  "localhost:50051",
+		"clickhouse_test.internal":         "clickhouse_test.internal:443",
+		"https://api.double.cloud:443/?":   "",
+		"https://api.double.cloud/v1":      "",
+		"api.double.cloud/v1/":             "",
+		"api.double.cloud:443?token=x":     "",
+		"https://user:pw@api.double.cloud": "",
+		"ftp://api.double.cloud":           "",
+		"api.double.cloud:":                "",
+		"api.double.cloud:https":           "",
+		"api.double.cloud:70000":           "",
+		"2001:db8::1:443x":                 "",
+		"[2001:db8::1":                     "",
+		"[10.0.0.1]:443":                   "",
+		"api double cloud":                 "",
+		"":                                 "",
+	} {
+		got, err := NormalizeEndpoint(in)
+		if want == "" {
+			assert.ErrorIs(t, err, ErrInvalidEndpoint, "%q", in)
+			continue
+		}
+		if assert.NoError(t, err, "%q", in) {
+			assert.Equal(t, want, got, "%q", in)
+		}
+	}
+}
+
+func TestNormalizeEndpoint_Errors(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://api.double.cloud/v1":      `invalid endpoint "https://api.double.cloud/v1": paths are not supported, got "/v1"`,
+		"api.double.cloud?region=eu":       `invalid endpoint "api.double.cloud?region=eu": queries are not supported`,
+		"ftp://api.double.cloud":           `invalid endpoint "ftp://api.double.cloud": unknown scheme "ftp", want one of https, http, grpcs, grpc, dns or none`,
+		"2001:db8::1:443x":                 `invalid endpoint "2001:db8::1:443x": invalid IPv6 address "2001:db8::1:443x", bracket it to add a port`,
+		"https://user:pw@api.double.cloud": `invalid endpoint "https://user:pw@api.double.cloud": credentials are not supported, set Config.Credentials`,
+	} {
+		_, err := NormalizeEndpoint(in)
+		assert.EqualError(t, err, want)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	conf := Config{Credentials: NewIAMTokenCredentials("token"), Endpoint: "https://api.double.cloud/"}
+	require.NoError(t, conf.Validate())
+	assert.Equal(t, "api.double.cloud:443", conf.Endpoint)
+
+	conf = Config{Credentials: NewIAMTokenCredentials("token")}
+	require.NoError(t, conf.Validate())
+	assert.Equal(t, DefaultEndpoint, conf.Endpoint)
+
+	_, err := Build(context.Background(), Config{Credentials: NewIAMTokenCredentials("token"), Endpoint: "api.double.cloud/v1"})
+	assert.ErrorIs(t, err, ErrInvalidEndpoint, "Build fails before dialing")
+	assert.EqualError(t, (&Config{}).Validate(), "credentials required")
+}
@@ -0,0 +1,205 @@
+package dcsdk
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// EnvironmentProduction is the environment of the public DoubleCloud API.
+const EnvironmentProduction = "prod"
+
+// ErrEnvironmentMismatch is matched by errors.Is for every *EnvironmentMismatchError.
+var ErrEnvironmentMismatch = errors.New("environment mismatch")
+
+// EnvironmentMismatchError is returned by Build for credentials of another environment than
+// the API endpoint, e.g. production credentials used against a preprod endpoint.
+type EnvironmentMismatchError struct {
+	Endpoint string
+	// EndpointEnvironment is Config.Environment or the environment detected from Endpoint.
+	EndpointEnvironment string
+	// CredentialsEnvironment is the environment of the credentials, and Source tells where
+	// it comes from, e.g. the credentials tag or the token audience.
+	CredentialsEnvironment string
+	Source                 string
+}
+
+func (e *EnvironmentMismatchError) Error() string {
+	return fmt.Sprintf("endpoint %q is in environment %q, but the credentials are for environment %q (%s)",
+		e.Endpoint, e.EndpointEnvironment, e.CredentialsEnvironment, e.Source)
+}
+
+func (e *EnvironmentMismatchError) Is(target error) bool { return target == ErrEnvironmentMismatch }
+
+// TokenClaims are the claims of a credentials token used to detect its environment.
+type TokenClaims struct {
+	Issuer   string
+	Audience []string
+}
+
+// EnvironmentDetector detects the environments of the API endpoint and of the credentials,
+// see Config.EnvironmentDetector. Unknown environments are returned as "" and are not checked.
+type EnvironmentDetector interface {
+	// EndpointEnvironment returns the environment of the API endpoint address.
+	EndpointEnvironment(endpoint string) string
+	// TokenEnvironment returns the environment of the token with the claims.
+	TokenEnvironment(claims TokenClaims) string
+}
+
+// DefaultEnvironmentDetector detects the environment from the hosts of the endpoint and of the
+// token issuer and audience: "api.double.cloud", "auth.double.cloud" and their subdomains are in
+// EnvironmentProduction, "<host>.<env>.double.cloud" hosts, e.g. "api.preprod.double.cloud",
+// are in env. Other hosts, e.g. of on-prem installs, are unknown.
+var DefaultEnvironmentDetector EnvironmentDetector = hostEnvironmentDetector{}
+
+type hostEnvironmentDetector struct{}
+
+func (hostEnvironmentDetector) EndpointEnvironment(endpoint string) string {
+	return hostEnvironment(endpoint)
+}
+
+func (hostEnvironmentDetector) TokenEnvironment(claims TokenClaims) string {
+	for _, s := range append([]string{claims.Issuer}, claims.Audience...) {
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			s = u.Host
+		}
+		if env := hostEnvironment(s); env != "" {
+			return env
+		}
+	}
+	return ""
+}
+
+// hostEnvironment returns the environment of a "host[:port]" address.
+func hostEnvironment(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	rest := strings.TrimSuffix(host, ".double.cloud")
+	if rest == host || rest == "" {
+		return ""
+	}
+	labels := strings.Split(rest, ".")
+	switch env := labels[len(labels)-1]; env {
+	case "api", "auth":
+		return EnvironmentProduction
+	default:
+		if len(labels) == 1 {
+			return ""
+		}
+		return env
+	}
+}
+
+// WithEnvironment tags the credentials with the environment they are issued for, checked by
+// Build against the environment of the API endpoint. The tag takes precedence over the
+// environment detected from the credentials token.
+func WithEnvironment(creds Credentials, env string) Credentials {
+	switch c := creds.(type) {
+	case ExchangeableCredentials:
+		return &exchangeableEnvironmentCredentials{ExchangeableCredentials: c, env: env}
+	case NonExchangeableCredentials:
+		return &nonExchangeableEnvironmentCredentials{NonExchangeableCredentials: c, env: env}
+	default:
+		return creds
+	}
+}
+
+// environmentCredentials are credentials tagged with WithEnvironment.
+type environmentCredentials interface {
+	environment() string
+}
+
+type exchangeableEnvironmentCredentials struct {
+	ExchangeableCredentials
+	env string
+}
+
+func (c *exchangeableEnvironmentCredentials) environment() string { return c.env }
+
+func (c *exchangeableEnvironmentCredentials) withSecurityProfile(p SecurityProfile) (Credentials, error) {
+	profiled, ok := c.ExchangeableCredentials.(profiledCredentials)
+	if !ok {
+		return c, nil
+	}
+	creds, err := profiled.withSecurityProfile(p)
+	if err != nil {
+		return nil, err
+	}
+	return WithEnvironment(creds, c.env), nil
+}
+
+type nonExchangeableEnvironmentCredentials struct {
+	NonExchangeableCredentials
+	env string
+}
+
+func (c *nonExchangeableEnvironmentCredentials) environment() string { return c.env }
+
+// checkEnvironment fails with *EnvironmentMismatchError if the environments of the endpoint and
+// of the credentials are both known and differ. The token environment is detected from the
+// service account JWT and from static IAM tokens only, as other credentials may need API calls
+// to issue a token.
+func checkEnvironment(conf Config) error {
+	detector := conf.EnvironmentDetector
+	if detector == nil {
+		detector = DefaultEnvironmentDetector
+	}
+	endpointEnv := conf.Environment
+	if endpointEnv == "" {
+		endpointEnv = detector.EndpointEnvironment(conf.Endpoint)
+	}
+	if endpointEnv == "" {
+		return nil
+	}
+	credsEnv, source := credentialsEnvironment(conf.Credentials, detector)
+	if credsEnv == "" || credsEnv == endpointEnv {
+		return nil
+	}
+	return &EnvironmentMismatchError{
+		Endpoint:               conf.Endpoint,
+		EndpointEnvironment:    endpointEnv,
+		CredentialsEnvironment: credsEnv,
+		Source:                 source,
+	}
+}
+
+func credentialsEnvironment(creds Credentials, detector EnvironmentDetector) (env, source string) {
+	if c, ok := creds.(environmentCredentials); ok && c.environment() != "" {
+		return c.environment(), "credentials tag"
+	}
+	var token string
+	switch c := creds.(type) {
+	case *exchangeableEnvironmentCredentials:
+		creds = c.ExchangeableCredentials
+	case *nonExchangeableEnvironmentCredentials:
+		creds = c.NonExchangeableCredentials
+	}
+	switch c := creds.(type) {
+	case *serviceAccountCredentials:
+		req, err := c.IAMTokenRequest()
+		if err != nil {
+			return "", ""
+		}
+		token = req.GetJwt()
+	case *IAMTokenCredentials:
+		token = c.iamToken
+	case IAMTokenCredentials:
+		token = c.iamToken
+	default:
+		return "", ""
+	}
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return "", ""
+	}
+	tc := TokenClaims{Issuer: claims.Issuer, Audience: claims.Audience}
+	env = detector.TokenEnvironment(tc)
+	return env, fmt.Sprintf("token issuer %q, audience %q", tc.Issuer, strings.Join(tc.Audience, ","))
+}
@@ -0,0 +1,114 @@
+package dcsdk
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticToken returns an unverifiable IAM token with the issuer and audience.
+func syntheticToken(t *testing.T, issuer string, audience ...string) Credentials {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:   issuer,
+		Audience: audience,
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	return NewIAMTokenCredentials(s)
+}
+
+func TestHostEnvironment(t *testing.T) {
+	for address, env := range map[string]string{
+		"api.double.cloud:443":                "prod",
+		"clickhouse.api.double.cloud:443":     "prod",
+		"auth.double.cloud":                   "prod",
+		"API.Double.Cloud.":                   "prod",
+		"api.preprod.double.cloud:443":        "preprod",
+		"kafka.api.preprod.double.cloud:443":  "preprod",
+		"double.cloud":                        "",
+		"console.double.cloud":                "",
+		"dc.example.com:443":                  "",
+		"localhost:8080":                      "",
+		"api.double.cloud.example.com":        "",
+		"https://auth.preprod.double.cloud/x": "",
+	} {
+		assert.Equal(t, env, hostEnvironment(address), address)
+	}
+}
+
+func TestBuild_EnvironmentMismatch(t *testing.T) {
+	prodToken := syntheticToken(t, "https://auth.double.cloud/", "https://api.double.cloud")
+	preprodToken := syntheticToken(t, "https://auth.preprod.double.cloud/", "https://api.preprod.double.cloud")
+
+	_, err := Build(context.Background(), Config{Credentials: prodToken, Endpoint: "api.preprod.double.cloud:443"})
+	require.ErrorIs(t, err, ErrEnvironmentMismatch)
+	var mismatch *EnvironmentMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, "preprod", mismatch.EndpointEnvironment)
+	assert.Equal(t, "prod", mismatch.CredentialsEnvironment)
+	assert.EqualError(t, err, `endpoint "api.preprod.double.cloud:443" is in environment "preprod", but the credentials are for environment "prod" `+
+		`(token issuer "https://auth.double.cloud/", audience "https://api.double.cloud")`)
+
+	_, err = Build(context.Background(), Config{Credentials: preprodToken})
+	assert.ErrorIs(t, err, ErrEnvironmentMismatch, "the default endpoint is in production")
+
+	// Service account JWTs are always for the production token service.
+	sa, err := ServiceAccountKey(testServiceAccountKey(t, 2048))
+	require.NoError(t, err)
+	_, err = Build(context.Background(), Config{Credentials: sa, Endpoint: "api.preprod.double.cloud:443", SecurityProfile: SecurityProfileStrict})
+	assert.ErrorIs(t, err, ErrEnvironmentMismatch)
+
+	_, err = Build(context.Background(), Config{Credentials: WithEnvironment(NewIAMTokenCredentials("opaque"), "preprod")})
+	require.ErrorIs(t, err, ErrEnvironmentMismatch)
+	assert.True(t, strings.HasSuffix(err.Error(), "(credentials tag)"), err.Error())
+
+	_, err = Build(context.Background(), Config{Credentials: prodToken, Endpoint: "dc.example.com:443", Environment: "staging"})
+	assert.ErrorIs(t, err, ErrEnvironmentMismatch, "the configured environment is checked even for unknown endpoints")
+}
+
+type onPremDetector struct{}
+
+func (onPremDetector) EndpointEnvironment(endpoint string) string {
+	if strings.HasSuffix(endpoint, ".corp.example:443") {
+		return "onprem"
+	}
+	return ""
+}
+
+func (onPremDetector) TokenEnvironment(claims TokenClaims) string {
+	if claims.Issuer == "https://sso.corp.example/" {
+		return "onprem"
+	}
+	return DefaultEnvironmentDetector.TokenEnvironment(claims)
+}
+
+func TestBuild_EnvironmentMatch(t *testing.T) {
+	prodToken := syntheticToken(t, "https://auth.double.cloud/", "https://api.double.cloud")
+	preprodToken := syntheticToken(t, "https://auth.preprod.double.cloud/", "https://api.preprod.double.cloud")
+	sa, err := ServiceAccountKey(testServiceAccountKey(t, 2048))
+	require.NoError(t, err)
+
+	for name, conf := range map[string]Config{
+		"prod token":           {Credentials: prodToken},
+		"preprod token":        {Credentials: preprodToken, Endpoint: "api.preprod.double.cloud:443"},
+		"service account":      {Credentials: sa},
+		"tagged service acc":   {Credentials: WithEnvironment(sa, "preprod"), Endpoint: "api.preprod.double.cloud:443", SecurityProfile: SecurityProfileStrict},
+		"opaque token":         {Credentials: NewIAMTokenCredentials("opaque"), Endpoint: "api.preprod.double.cloud:443"},
+		"unknown endpoint":     {Credentials: prodToken, Endpoint: "dc.example.com:443"},
+		"configured":           {Credentials: preprodToken, Endpoint: "10.0.0.1:443", Environment: "preprod"},
+		"on-prem":              {Credentials: syntheticToken(t, "https://sso.corp.example/"), Endpoint: "api.corp.example:443", EnvironmentDetector: onPremDetector{}},
+		"on-prem unknown host": {Credentials: prodToken, Endpoint: "10.0.0.1:443", EnvironmentDetector: onPremDetector{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Build(context.Background(), conf)
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err = Build(context.Background(), Config{Credentials: prodToken, Endpoint: "api.corp.example:443", EnvironmentDetector: onPremDetector{}})
+	assert.ErrorIs(t, err, ErrEnvironmentMismatch)
+}
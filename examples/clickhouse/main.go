@@ -36,7 +36,7 @@ func createCluster(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation
 	log.Println("https://app.double.cloud/clickhouse/" + x.ResourceId + "/operations")
 	op, err := dc.WrapOperation(x, err)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	err = op.Wait(ctx)
 	return op, err
@@ -45,12 +45,32 @@ func createCluster(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation
 func deleteCluster(ctx context.Context, dc *dc.SDK, clusterID string) (*operation.Operation, error) {
 	op, err := dc.WrapOperation(dc.ClickHouse().Cluster().Delete(ctx, &clickhouse.DeleteClusterRequest{ClusterId: clusterID}))
 	if err != nil {
-		log.Fatal(err)
+		return op, err
 	}
 	err = op.Wait(ctx)
 	return op, err
 }
 
+// run creates a cluster, waits for it and deletes it once pause returns.
+func run(ctx context.Context, sdk *dc.SDK, flags *cmdFlags, pause func()) error {
+	op, err := createCluster(ctx, sdk, flags)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster: %w", err)
+	}
+	clusterID := op.ResourceId()
+
+	log.Println("Wonderful! 🚀 Check out created cluster\n\thttps://app.double.cloud/clickhouse/" + clusterID)
+
+	log.Println("Press F to respect and delete all created resources ...")
+	pause()
+
+	log.Println("Deleting cluster", clusterID)
+	if _, err := deleteCluster(ctx, sdk, clusterID); err != nil {
+		return fmt.Errorf("failed to delete cluster: %w", err)
+	}
+	return nil
+}
+
 func main() {
 	flags := parseCmd()
 	ctx := context.Background()
@@ -71,21 +91,8 @@ func main() {
 		log.Fatal(err)
 	}
 
-	op, err := createCluster(ctx, sdk, flags)
-	if err != nil {
-		log.Panic(err, "Failed to create cluster")
-	}
-	clusterID := op.ResourceId()
-
-	log.Println("Wonderful! 🚀 Check out created cluster\n\thttps://app.double.cloud/clickhouse/" + clusterID)
-
-	log.Println("Press F to respect and delete all created resources ...")
-	fmt.Scanln()
-
-	log.Println("Deleting cluster", clusterID)
-	op, err = deleteCluster(ctx, sdk, clusterID)
-	if err != nil {
-		log.Panic(err, "Failed to delete cluster")
+	if err := run(ctx, sdk, flags, func() { fmt.Scanln() }); err != nil {
+		log.Panic(err)
 	}
 }
 
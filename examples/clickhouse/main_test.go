@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/doublecloud/go-sdk/sdktest"
+)
+
+func testFlags() *cmdFlags {
+	str := func(s string) *string { return &s }
+	return &cmdFlags{
+		projectID: str("project"),
+		region:    str("eu-central-1"),
+		name:      str("go-example"),
+		networkID: str("network"),
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := sdktest.New(t)
+	ctx := context.Background()
+
+	var created *clickhouse.Cluster
+	err := run(ctx, srv.SDK(t), testFlags(), func() {
+		clusters, err := srv.ClickHouse.List(ctx, &clickhouse.ListClustersRequest{ProjectId: "project"})
+		require.NoError(t, err)
+		require.Len(t, clusters.Clusters, 1)
+		created = clusters.Clusters[0]
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, created)
+	assert.Equal(t, dcv1.ClusterStatus_CLUSTER_STATUS_ALIVE, created.Status)
+	assert.Equal(t, "go-example", created.Name)
+	assert.Equal(t, int64(1), created.Resources.GetClickhouse().GetReplicaCount().GetValue())
+	assert.Nil(t, srv.ClickHouse.Cluster(created.Id), "cluster must be deleted")
+}
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
 	dc "github.com/doublecloud/go-sdk"
@@ -38,7 +39,7 @@ func createCluster(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation
 	log.Println("https://app.double.cloud/kafka/" + x.ResourceId + "/operations")
 	op, err := dc.WrapOperation(x, err)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	err = op.Wait(ctx)
 	return op, err
@@ -47,12 +48,91 @@ func createCluster(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation
 func deleteCluster(ctx context.Context, dc *dc.SDK, clusterID string) (*operation.Operation, error) {
 	op, err := dc.WrapOperation(dc.Kafka().Cluster().Delete(ctx, &kafka.DeleteClusterRequest{ClusterId: clusterID}))
 	if err != nil {
-		log.Fatal(err)
+		return op, err
 	}
 	err = op.Wait(ctx)
 	return op, err
 }
 
+func createTopic(ctx context.Context, dc *dc.SDK, clusterID string, flags *cmdFlags) (*operation.Operation, error) {
+	op, err := dc.WrapOperation(dc.Kafka().Topic().Create(ctx, &kafka.CreateTopicRequest{
+		ClusterId: clusterID,
+		TopicSpec: &kafka.TopicSpec{
+			Name:              *flags.topic,
+			Partitions:        wrapperspb.Int64(3),
+			ReplicationFactor: wrapperspb.Int64(1),
+			TopicConfig: &kafka.TopicSpec_TopicConfig_3{TopicConfig_3: &kafka.TopicConfig3{
+				CleanupPolicy: kafka.TopicConfig3_CLEANUP_POLICY_DELETE,
+				RetentionMs:   wrapperspb.Int64(int64(24 * time.Hour / time.Millisecond)),
+			}},
+		},
+	}))
+	if err != nil {
+		return op, err
+	}
+	err = op.Wait(ctx)
+	return op, err
+}
+
+// rotateTopicConfig replaces the config of the topic, keeping its partitioning.
+func rotateTopicConfig(ctx context.Context, dc *dc.SDK, clusterID, topicName string, config *kafka.TopicConfig3) (*operation.Operation, error) {
+	topic, err := dc.Kafka().Topic().Get(ctx, &kafka.GetTopicRequest{ClusterId: clusterID, TopicName: topicName})
+	if err != nil {
+		return nil, err
+	}
+	op, err := dc.WrapOperation(dc.Kafka().Topic().Update(ctx, &kafka.UpdateTopicRequest{
+		ClusterId: clusterID,
+		TopicName: topicName,
+		TopicSpec: &kafka.TopicSpec{
+			Name:              topicName,
+			Partitions:        topic.Partitions,
+			ReplicationFactor: topic.ReplicationFactor,
+			TopicConfig:       &kafka.TopicSpec_TopicConfig_3{TopicConfig_3: config},
+		},
+	}))
+	if err != nil {
+		return op, err
+	}
+	err = op.Wait(ctx)
+	return op, err
+}
+
+// run creates a cluster with a topic, rotates the topic config and deletes the cluster once pause
+// returns.
+func run(ctx context.Context, sdk *dc.SDK, flags *cmdFlags, pause func()) error {
+	op, err := createCluster(ctx, sdk, flags)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster: %w", err)
+	}
+	clusterID := op.ResourceId()
+
+	log.Println("Wonderful! 🚀 Check out created cluster\n\thttps://app.double.cloud/kafka/" + clusterID)
+
+	log.Println("Creating topic", *flags.topic)
+	if _, err := createTopic(ctx, sdk, clusterID, flags); err != nil {
+		return fmt.Errorf("failed to create topic: %w", err)
+	}
+
+	log.Println("Rotating config of topic", *flags.topic)
+	_, err = rotateTopicConfig(ctx, sdk, clusterID, *flags.topic, &kafka.TopicConfig3{
+		CleanupPolicy:   kafka.TopicConfig3_CLEANUP_POLICY_COMPACT_AND_DELETE,
+		CompressionType: kafka.TopicConfig3_COMPRESSION_TYPE_ZSTD,
+		RetentionMs:     wrapperspb.Int64(int64(7 * 24 * time.Hour / time.Millisecond)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rotate topic config: %w", err)
+	}
+
+	log.Println("Press F to respect and delete all created resources ...")
+	pause()
+
+	log.Println("Deleting cluster", clusterID)
+	if _, err := deleteCluster(ctx, sdk, clusterID); err != nil {
+		return fmt.Errorf("failed to delete cluster: %w", err)
+	}
+	return nil
+}
+
 func main() {
 	flags := parseCmd()
 	ctx := context.Background()
@@ -73,21 +153,8 @@ func main() {
 		log.Fatal(err)
 	}
 
-	op, err := createCluster(ctx, sdk, flags)
-	if err != nil {
-		log.Panic(err, "Failed to create cluster")
-	}
-	clusterID := op.ResourceId()
-
-	log.Println("Wonderful! 🚀 Check out created cluster\n\thttps://app.double.cloud/kafka/" + clusterID)
-
-	log.Println("Press F to respect and delete all created resources ...")
-	fmt.Scanln()
-
-	log.Println("Deleting cluster", clusterID)
-	op, err = deleteCluster(ctx, sdk, clusterID)
-	if err != nil {
-		log.Panic(err, "Failed to delete cluster")
+	if err := run(ctx, sdk, flags, func() { fmt.Scanln() }); err != nil {
+		log.Panic(err)
 	}
 }
 
@@ -97,6 +164,7 @@ type cmdFlags struct {
 	region    *string
 	name      *string
 	networkID *string
+	topic     *string
 }
 
 func parseCmd() (ret *cmdFlags) {
@@ -108,6 +176,7 @@ func parseCmd() (ret *cmdFlags) {
 	ret.name = flag.String("name", "go-example", "Name for your service")
 	ret.region = flag.String("region", "eu-central-1", "Region to deploy to.")
 	ret.networkID = flag.String("networkID", "10.0.0.0/16", "Network of the cluster.")
+	ret.topic = flag.String("topic", "go-example", "Name of the topic to create.")
 
 	flag.Parse()
 	return
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/doublecloud/go-sdk/sdktest"
+)
+
+func testFlags() *cmdFlags {
+	str := func(s string) *string { return &s }
+	return &cmdFlags{
+		projectID: str("project"),
+		region:    str("eu-central-1"),
+		name:      str("go-example"),
+		networkID: str("network"),
+		topic:     str("events"),
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := sdktest.New(t)
+	ctx := context.Background()
+
+	var cluster *kafka.Cluster
+	var topic *kafka.Topic
+	err := run(ctx, srv.SDK(t), testFlags(), func() {
+		clusters, err := srv.Kafka.List(ctx, &kafka.ListClustersRequest{ProjectId: "project"})
+		require.NoError(t, err)
+		require.Len(t, clusters.Clusters, 1)
+		cluster = clusters.Clusters[0]
+		topic = srv.Kafka.Topic(cluster.Id, "events")
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, cluster)
+	assert.Equal(t, dcv1.ClusterStatus_CLUSTER_STATUS_ALIVE, cluster.Status)
+
+	require.NotNil(t, topic, "topic must be created")
+	assert.Equal(t, int64(3), topic.Partitions.GetValue(), "partitioning must be kept")
+	config := topic.GetTopicConfig_3()
+	require.NotNil(t, config)
+	assert.Equal(t, kafka.TopicConfig3_CLEANUP_POLICY_COMPACT_AND_DELETE, config.CleanupPolicy)
+	assert.Equal(t, kafka.TopicConfig3_COMPRESSION_TYPE_ZSTD, config.CompressionType)
+
+	assert.Nil(t, srv.Kafka.Cluster(cluster.Id), "cluster must be deleted")
+}
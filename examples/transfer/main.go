@@ -38,6 +38,46 @@ func createS3SourceEndpoint(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*
 	return op, err
 }
 
+func createPostgresSourceEndpoint(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation.Operation, error) {
+	op, err := dc.WrapOperation(dc.Transfer().Endpoint().Create(ctx, &transfer.CreateEndpointRequest{
+		ProjectId: *flags.projectID,
+		Name:      fmt.Sprint("pg-src-", *flags.name),
+		Settings: &transfer.EndpointSettings{
+			Settings: &transfer.EndpointSettings_PostgresSource{
+				PostgresSource: &endpoint.PostgresSource{
+					Connection: &endpoint.PostgresConnection{
+						Connection: &endpoint.PostgresConnection_OnPremise{
+							OnPremise: &endpoint.OnPremisePostgres{
+								Hosts: []string{*flags.pgHost},
+								Port:  5432,
+							},
+						},
+					},
+					Database: "postgres",
+					User:     "user",
+					Password: &endpoint.Secret{Value: &endpoint.Secret_Raw{Raw: "98s*%^P!3Bw38"}},
+				},
+			},
+		},
+	}))
+	if err != nil {
+		return op, err
+	}
+	err = op.Wait(ctx)
+	return op, err
+}
+
+func createSourceEndpoint(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation.Operation, error) {
+	switch *flags.source {
+	case "s3":
+		return createS3SourceEndpoint(ctx, dc, flags)
+	case "postgres":
+		return createPostgresSourceEndpoint(ctx, dc, flags)
+	default:
+		return nil, fmt.Errorf("unknown source %q, expected s3 or postgres", *flags.source)
+	}
+}
+
 func createCHDstEndpoint(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*operation.Operation, error) {
 	op, err := dc.WrapOperation(dc.Transfer().Endpoint().Create(ctx, &transfer.CreateEndpointRequest{
 		ProjectId: *flags.projectID,
@@ -69,7 +109,7 @@ func createCHDstEndpoint(ctx context.Context, dc *dc.SDK, flags *cmdFlags) (*ope
 func deleteEndpoint(ctx context.Context, dc *dc.SDK, endpointId string) (*operation.Operation, error) {
 	op, err := dc.WrapOperation(dc.Transfer().Endpoint().Delete(ctx, &transfer.DeleteEndpointRequest{EndpointId: endpointId}))
 	if err != nil {
-		log.Fatal(err)
+		return op, err
 	}
 	err = op.Wait(ctx)
 	return op, err
@@ -123,78 +163,82 @@ func deleteTransfer(ctx context.Context, dc *dc.SDK, transferId string) (*operat
 	return op, err
 }
 
-func main() {
-	flags := parseCmd()
-	ctx := context.Background()
-
-	key, err := iamkey.ReadFromJSONFile(*flags.saPath)
-	if err != nil {
-		panic(err)
-	}
-	creds, err := dc.ServiceAccountKey(key)
-	if err != nil {
-		panic(err)
-	}
-
-	sdk, err := dc.Build(ctx, dc.Config{
-		Credentials: creds,
-	})
+// run sets up a transfer from the source endpoint to ClickHouse, activates it and deletes everything
+// once pause returns.
+func run(ctx context.Context, sdk *dc.SDK, flags *cmdFlags, pause func()) error {
+	op, err := createSourceEndpoint(ctx, sdk, flags)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	op, err := createS3SourceEndpoint(ctx, sdk, flags)
-	if err != nil {
-		log.Panic(err, "Failed to create s3 source endpoint")
+		return fmt.Errorf("failed to create %s source endpoint: %w", *flags.source, err)
 	}
 	srcEndpointId := op.ResourceId()
+	log.Printf("Created %s source endpoint: %s", *flags.source, srcEndpointId)
 
-	log.Println("Created s3 source endpoint: ", srcEndpointId)
 	op, err = createCHDstEndpoint(ctx, sdk, flags)
 	if err != nil {
-		log.Panic(err, "Failed to create s3 dst endpoint")
+		return fmt.Errorf("failed to create clickhouse destination endpoint: %w", err)
 	}
 	dstEndpointId := op.ResourceId()
 	log.Println("Created ClickHouse destination endpoint: ", dstEndpointId)
 
 	op, err = createTransfer(ctx, sdk, flags, srcEndpointId, dstEndpointId)
 	if err != nil {
-		log.Panic(err, "Failed to create transfer")
+		return fmt.Errorf("failed to create transfer: %w", err)
 	}
 	transferId := op.ResourceId()
 
 	log.Println("Wonderful! 🚀 Check out created transfer\n\thttps://app.double.cloud/data-transfer/" + transferId)
 
 	log.Println("Activating transfer", transferId)
-	op, err = activateTransfer(ctx, sdk, transferId)
-	if err != nil {
+	if _, err := activateTransfer(ctx, sdk, transferId); err != nil {
 		log.Println("Activate failed, because we specified unexisted cluster, nothing to look here")
 	}
 
 	log.Println("Press F to respect and delete all created resources ...")
-	fmt.Scanln()
+	pause()
 
 	log.Println("Deactivating transfer", transferId)
-	op, err = deactivateTransfer(ctx, sdk, transferId)
-	if err != nil {
-		log.Panic(err, "Failed to activate")
+	if _, err := deactivateTransfer(ctx, sdk, transferId); err != nil {
+		return fmt.Errorf("failed to deactivate transfer: %w", err)
 	}
 
 	log.Println("Deleting transfer", transferId)
-	op, err = deleteTransfer(ctx, sdk, transferId)
-	if err != nil {
-		log.Panic(err, "Failed to delete transfer")
+	if _, err := deleteTransfer(ctx, sdk, transferId); err != nil {
+		return fmt.Errorf("failed to delete transfer: %w", err)
 	}
 
-	log.Println("Deleting s3 endpoint", srcEndpointId)
-	op, err = deleteEndpoint(ctx, sdk, srcEndpointId)
-	if err != nil {
-		log.Panic(err, "Failed to delete s3 source endpoint")
+	log.Printf("Deleting %s source endpoint %s", *flags.source, srcEndpointId)
+	if _, err := deleteEndpoint(ctx, sdk, srcEndpointId); err != nil {
+		return fmt.Errorf("failed to delete %s source endpoint: %w", *flags.source, err)
 	}
 	log.Println("Deleting clickhouse destination endpoint", dstEndpointId)
-	op, err = deleteEndpoint(ctx, sdk, dstEndpointId)
+	if _, err := deleteEndpoint(ctx, sdk, dstEndpointId); err != nil {
+		return fmt.Errorf("failed to delete clickhouse destination endpoint: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	flags := parseCmd()
+	ctx := context.Background()
+
+	key, err := iamkey.ReadFromJSONFile(*flags.saPath)
 	if err != nil {
-		log.Panic(err, "Failed to delete clickhouse destination endpoint")
+		panic(err)
+	}
+	creds, err := dc.ServiceAccountKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	sdk, err := dc.Build(ctx, dc.Config{
+		Credentials: creds,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := run(ctx, sdk, flags, func() { fmt.Scanln() }); err != nil {
+		log.Panic(err)
 	}
 }
 
@@ -202,6 +246,8 @@ type cmdFlags struct {
 	saPath    *string
 	projectID *string
 	name      *string
+	source    *string
+	pgHost    *string
 }
 
 func parseCmd() (ret *cmdFlags) {
@@ -211,6 +257,8 @@ func parseCmd() (ret *cmdFlags) {
 		"Members -> Service Accounts -> Create and then create authorized keys")
 	ret.projectID = flag.String("projectID", "", "Your project id")
 	ret.name = flag.String("name", "go-example", "Name for your service")
+	ret.source = flag.String("source", "s3", "Source of the transfer: s3 or postgres.")
+	ret.pgHost = flag.String("pgHost", "localhost", "Host of the PostgreSQL source.")
 
 	flag.Parse()
 	return
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/doublecloud/go-sdk/sdktest"
+)
+
+func testFlags(source string) *cmdFlags {
+	str := func(s string) *string { return &s }
+	return &cmdFlags{
+		projectID: str("project"),
+		name:      str("go-example"),
+		source:    str(source),
+		pgHost:    str("pg.example.com"),
+	}
+}
+
+func TestRun_PostgresToClickHouse(t *testing.T) {
+	srv := sdktest.New(t)
+	ctx := context.Background()
+
+	var running *transfer.Transfer
+	err := run(ctx, srv.SDK(t), testFlags("postgres"), func() {
+		transfers, err := srv.Transfer.List(ctx, &transfer.ListTransfersRequest{ProjectId: "project"})
+		require.NoError(t, err)
+		require.Len(t, transfers.Transfers, 1)
+		running = transfers.Transfers[0]
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, running)
+	assert.Equal(t, transfer.TransferStatus_RUNNING, running.Status)
+	assert.Equal(t, transfer.TransferType_SNAPSHOT_ONLY, running.Type)
+	pg := running.Source.GetSettings().GetPostgresSource()
+	require.NotNil(t, pg, "source must be postgres")
+	assert.Equal(t, []string{"pg.example.com"}, pg.GetConnection().GetOnPremise().GetHosts())
+	assert.NotNil(t, running.Target.GetSettings().GetClickhouseTarget(), "target must be clickhouse")
+
+	assert.Nil(t, srv.Transfer.Transfer(running.Id), "transfer must be deleted")
+	assert.Nil(t, srv.Transfer.Endpoint(running.Source.Id), "source endpoint must be deleted")
+	assert.Nil(t, srv.Transfer.Endpoint(running.Target.Id), "target endpoint must be deleted")
+}
+
+func TestRun_UnknownSource(t *testing.T) {
+	srv := sdktest.New(t)
+
+	err := run(context.Background(), srv.SDK(t), testFlags("oracle"), func() {
+		t.Fatal("must fail before pausing")
+	})
+	assert.ErrorContains(t, err, `unknown source "oracle"`)
+}
@@ -7,6 +7,8 @@ import (
 	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // BackupServiceClient is a clickhouse.BackupServiceClient with
 // lazy GRPC connection initialization.
 type BackupServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements clickhouse.BackupServiceClient
@@ -66,6 +68,7 @@ type BackupIterator struct {
 	request *clickhouse.ListBackupsRequest
 
 	items []*clickhouse.Backup
+	leak  *lifecycle.Tracker
 }
 
 func (c *BackupServiceClient) BackupIterator(ctx context.Context, req *clickhouse.ListBackupsRequest, opts ...grpc.CallOption) *BackupIterator {
@@ -75,13 +78,15 @@ func (c *BackupServiceClient) BackupIterator(ctx context.Context, req *clickhous
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &BackupIterator{
+	it := &BackupIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "clickhouse.BackupIterator")
+	return it
 }
 
 func (it *BackupIterator) Next() bool {
@@ -96,6 +101,7 @@ func (it *BackupIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -103,11 +109,16 @@ func (it *BackupIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Backups
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *BackupIterator) Take(size int64) ([]*clickhouse.Backup, error) {
@@ -152,3 +163,10 @@ func (it *BackupIterator) Value() *clickhouse.Backup {
 func (it *BackupIterator) Error() error {
 	return it.err
 }
+
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *BackupIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
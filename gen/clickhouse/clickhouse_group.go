@@ -8,11 +8,23 @@ import (
 
 // ClickHouse provides access to "clickhouse" service of DoubleCloud
 type ClickHouse struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // NewClickHouse creates instance of ClickHouse
 func NewClickHouse(g func(ctx context.Context) (*grpc.ClientConn, error)) *ClickHouse {
+	return NewClickHouseWithConnInterface(func(ctx context.Context) (grpc.ClientConnInterface, error) {
+		conn, err := g(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+// NewClickHouseWithConnInterface creates instance of ClickHouse calling through
+// connections returned by g, e.g. ones wrapping SDK connections.
+func NewClickHouseWithConnInterface(g func(ctx context.Context) (grpc.ClientConnInterface, error)) *ClickHouse {
 	return &ClickHouse{g}
 }
 
@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// cloneCopiedFields are the Cluster fields Clone copies to the derived request.
+// Every Cluster field must be either here or in cloneStrippedFields: fields added to the
+// proto are not copied until they are classified.
+var cloneCopiedFields = []protoreflect.Name{
+	"project_id",
+	"cloud_type",
+	"region_id",
+	"description",
+	"version",
+	"resources",
+	"access",
+	"encryption",
+	"network_id",
+	"clickhouse_config",
+	"maintenance_window",
+}
+
+// cloneStrippedFields are the Cluster fields Clone never copies: identity, state
+// and timestamps of the source cluster.
+var cloneStrippedFields = []protoreflect.Name{
+	"id",
+	"name",
+	"create_time",
+	"status",
+	"connection_info",
+	"private_connection_info",
+	"maintenance_operation",
+}
+
+// CloneOptions configure Clone.
+type CloneOptions struct {
+	// Name of the new cluster. Required.
+	Name string
+	// PresetOverride, if set, replaces the ClickHouse resource preset of the source cluster.
+	PresetOverride string
+	// DiskOverride, if positive, replaces the disk size per replica in bytes.
+	DiskOverride int64
+	// FromLatestBackup restores the new cluster from the latest backup of the source cluster
+	// instead of creating an empty one.
+	FromLatestBackup bool
+}
+
+// CloneRequest is the request derived by Clone. Exactly one of Create and Restore is set.
+type CloneRequest struct {
+	Create  *clickhouse.CreateClusterRequest
+	Restore *clickhouse.RestoreClusterRequest
+}
+
+// Clone creates a cluster with the spec of the source cluster and the overrides applied.
+// It returns the operation and the request sent, also if the request failed.
+func (c *ClusterServiceClient) Clone(ctx context.Context, sourceClusterID string, options CloneOptions, opts ...grpc.CallOption) (*doublecloud.Operation, *CloneRequest, error) {
+	if options.Name == "" {
+		return nil, nil, errors.New("clickhouse clone: name required")
+	}
+	source, err := c.Get(ctx, &clickhouse.GetClusterRequest{ClusterId: sourceClusterID}, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !options.FromLatestBackup {
+		req := &clickhouse.CreateClusterRequest{}
+		deriveCloneRequest(req, source, options)
+		op, err := c.Create(ctx, req, opts...)
+		return op, &CloneRequest{Create: req}, err
+	}
+
+	backups, err := c.ClusterBackupsIterator(ctx, &clickhouse.ListClusterBackupsRequest{ClusterId: sourceClusterID}, opts...).TakeAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	latest := latestBackup(backups)
+	if latest == nil {
+		return nil, nil, fmt.Errorf("clickhouse clone: cluster %s has no backups", sourceClusterID)
+	}
+	req := &clickhouse.RestoreClusterRequest{BackupId: latest.GetId()}
+	deriveCloneRequest(req, source, options)
+	op, err := c.Restore(ctx, req, opts...)
+	return op, &CloneRequest{Restore: req}, err
+}
+
+// deriveCloneRequest fills a create or restore request from the source cluster.
+func deriveCloneRequest(req proto.Message, source *clickhouse.Cluster, options CloneOptions) {
+	src := proto.Clone(source).ProtoReflect()
+	dst := req.ProtoReflect()
+	for _, name := range cloneCopiedFields {
+		sf, df := src.Descriptor().Fields().ByName(name), dst.Descriptor().Fields().ByName(name)
+		if sf == nil || df == nil || !src.Has(sf) {
+			continue
+		}
+		dst.Set(df, src.Get(sf))
+	}
+	dst.Set(dst.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString(options.Name))
+
+	if options.PresetOverride == "" && options.DiskOverride <= 0 {
+		return
+	}
+	resources := dst.Mutable(dst.Descriptor().Fields().ByName("resources")).Message().Interface().(*clickhouse.ClusterResources)
+	if resources.Clickhouse == nil {
+		resources.Clickhouse = &clickhouse.ClusterResources_Clickhouse{}
+	}
+	if options.PresetOverride != "" {
+		resources.Clickhouse.ResourcePresetId = options.PresetOverride
+	}
+	if options.DiskOverride > 0 {
+		resources.Clickhouse.DiskSize = wrapperspb.Int64(options.DiskOverride)
+	}
+}
+
+func latestBackup(backups []*clickhouse.Backup) *clickhouse.Backup {
+	var latest *clickhouse.Backup
+	for _, b := range backups {
+		if latest == nil || b.GetCreateTime().AsTime().After(latest.GetCreateTime().AsTime()) {
+			latest = b
+		}
+	}
+	return latest
+}
@@ -0,0 +1,161 @@
+package clickhouse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func prodCluster() *clickhouse.Cluster {
+	return &clickhouse.Cluster{
+		Id:          "chc1",
+		ProjectId:   "p1",
+		CloudType:   "aws",
+		RegionId:    "eu-central-1",
+		CreateTime:  timestamppb.New(time.Unix(1, 0)),
+		Name:        "prod",
+		Description: "main cluster",
+		Status:      doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE,
+		Version:     "23.3",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c8-m32",
+			DiskSize:         wrapperspb.Int64(512 << 30),
+			ReplicaCount:     wrapperspb.Int64(3),
+		}},
+		ConnectionInfo:        &clickhouse.ConnectionInfo{Host: "prod.example", Password: "secret"},
+		PrivateConnectionInfo: &clickhouse.PrivateConnectionInfo{Host: "prod.internal", Password: "secret"},
+		NetworkId:             "net1",
+		MaintenanceOperation:  &doublecloud.MaintenanceOperation{Info: "update"},
+	}
+}
+
+// TestCloneFieldsClassified fails when genproto adds a Cluster field: decide whether
+// Clone may copy it and add it to cloneCopiedFields or cloneStrippedFields.
+func TestCloneFieldsClassified(t *testing.T) {
+	classified := map[protoreflect.Name]bool{}
+	for _, name := range append(append([]protoreflect.Name(nil), cloneCopiedFields...), cloneStrippedFields...) {
+		assert.False(t, classified[name], "%s classified twice", name)
+		classified[name] = true
+	}
+	fields := (&clickhouse.Cluster{}).ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		assert.True(t, classified[fields.Get(i).Name()], "cluster field %s is not classified for cloning", fields.Get(i).Name())
+	}
+	assert.Len(t, classified, fields.Len(), "classified fields missing from Cluster")
+
+	create := (&clickhouse.CreateClusterRequest{}).ProtoReflect().Descriptor().Fields()
+	restore := (&clickhouse.RestoreClusterRequest{}).ProtoReflect().Descriptor().Fields()
+	for _, name := range cloneCopiedFields {
+		src := fields.ByName(name)
+		for _, dst := range []protoreflect.FieldDescriptor{create.ByName(name), restore.ByName(name)} {
+			if dst == nil {
+				continue
+			}
+			assert.Equal(t, src.Kind(), dst.Kind(), name)
+			if src.Message() != nil {
+				assert.Equal(t, src.Message().FullName(), dst.Message().FullName(), name)
+			}
+		}
+		assert.NotNil(t, create.ByName(name), "%s is not a create request field", name)
+	}
+}
+
+func TestDeriveCloneRequest(t *testing.T) {
+	source := prodCluster()
+	req := &clickhouse.CreateClusterRequest{}
+	deriveCloneRequest(req, source, CloneOptions{Name: "staging", PresetOverride: "s1-c2-m4", DiskOverride: 32 << 30})
+
+	assertProtoEqual(t, &clickhouse.CreateClusterRequest{
+		ProjectId:   "p1",
+		CloudType:   "aws",
+		RegionId:    "eu-central-1",
+		Name:        "staging",
+		Description: "main cluster",
+		Version:     "23.3",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			DiskSize:         wrapperspb.Int64(32 << 30),
+			ReplicaCount:     wrapperspb.Int64(3),
+		}},
+		NetworkId: "net1",
+	}, req)
+	assert.Equal(t, "s1-c8-m32", source.Resources.Clickhouse.ResourcePresetId, "the source cluster must not be modified")
+
+	bare := &clickhouse.CreateClusterRequest{}
+	deriveCloneRequest(bare, &clickhouse.Cluster{Id: "chc1"}, CloneOptions{Name: "staging"})
+	assert.Nil(t, bare.Resources, "no overrides, no resources")
+}
+
+// cloneClusters serves prodCluster with its backups and records create and restore requests.
+type cloneClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	backups []*clickhouse.Backup
+	created *clickhouse.CreateClusterRequest
+	restore *clickhouse.RestoreClusterRequest
+}
+
+func (s *cloneClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return prodCluster(), nil
+}
+
+func (s *cloneClusters) ListBackups(ctx context.Context, req *clickhouse.ListClusterBackupsRequest) (*clickhouse.ListClusterBackupsResponse, error) {
+	return &clickhouse.ListClusterBackupsResponse{Backups: s.backups}, nil
+}
+
+func (s *cloneClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*doublecloud.Operation, error) {
+	s.created = req
+	return &doublecloud.Operation{Id: "cho1"}, nil
+}
+
+func (s *cloneClusters) Restore(ctx context.Context, req *clickhouse.RestoreClusterRequest) (*doublecloud.Operation, error) {
+	s.restore = req
+	return &doublecloud.Operation{Id: "cho2"}, nil
+}
+
+func TestClone(t *testing.T) {
+	srv := &cloneClusters{backups: []*clickhouse.Backup{
+		{Id: "b1", CreateTime: timestamppb.New(time.Unix(10, 0))},
+		{Id: "b3", CreateTime: timestamppb.New(time.Unix(30, 0))},
+		{Id: "b2", CreateTime: timestamppb.New(time.Unix(20, 0))},
+	}}
+	ch := newTestClickHouse(t, func(s *grpc.Server) { clickhouse.RegisterClusterServiceServer(s, srv) })
+	ctx := context.Background()
+
+	op, req, err := ch.Cluster().Clone(ctx, "chc1", CloneOptions{Name: "staging"})
+	require.NoError(t, err)
+	assert.Equal(t, "cho1", op.Id)
+	require.NotNil(t, req.Create)
+	assert.Nil(t, req.Restore)
+	assertProtoEqual(t, req.Create, srv.created)
+	assert.Equal(t, "staging", srv.created.Name)
+
+	op, req, err = ch.Cluster().Clone(ctx, "chc1", CloneOptions{Name: "staging", PresetOverride: "s1-c2-m4", FromLatestBackup: true})
+	require.NoError(t, err)
+	assert.Equal(t, "cho2", op.Id)
+	require.NotNil(t, req.Restore)
+	assertProtoEqual(t, req.Restore, srv.restore)
+	assert.Equal(t, "b3", srv.restore.BackupId)
+	assert.Equal(t, "s1-c2-m4", srv.restore.Resources.Clickhouse.ResourcePresetId)
+
+	srv.backups = nil
+	_, _, err = ch.Cluster().Clone(ctx, "chc1", CloneOptions{Name: "staging", FromLatestBackup: true})
+	assert.EqualError(t, err, "clickhouse clone: cluster chc1 has no backups")
+
+	_, _, err = ch.Cluster().Clone(ctx, "chc1", CloneOptions{})
+	assert.EqualError(t, err, "clickhouse clone: name required")
+}
+
+func assertProtoEqual(t *testing.T, want, got proto.Message) {
+	t.Helper()
+	assert.True(t, proto.Equal(want, got), "want %v\ngot  %v", want, got)
+}
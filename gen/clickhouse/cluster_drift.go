@@ -0,0 +1,57 @@
+package clickhouse
+
+import (
+	"context"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+// ClusterSpec is the desired state of a cluster checked by Drift. Only the fields set
+// in the spec are compared, see specutil.Drift.
+type ClusterSpec = clickhouse.Cluster
+
+// DriftOptions configure Drift.
+type DriftOptions struct {
+	// FixDrift makes Drift build the update request reverting the drift, see DriftReport.Fix.
+	// The request is not sent.
+	FixDrift bool
+}
+
+// DriftReport lists the differences of a live cluster from its desired spec.
+type DriftReport struct {
+	ClusterID string
+	// Live is the cluster as fetched by Drift.
+	Live *clickhouse.Cluster
+	// Drift lists the drifted fields, Old being the live value and New the desired one.
+	Drift []specutil.FieldChange
+	// Fix is the update reverting the drift, built if DriftOptions.FixDrift is set and the
+	// cluster drifted. Apply it with Update.
+	Fix *clickhouse.UpdateClusterRequest
+	// Unfixable lists the drift Fix can't revert, as the fields can't be updated.
+	Unfixable []specutil.FieldChange
+	// RequiresRecreate lists the drift of immutable fields, see specutil.RegisterImmutable:
+	// only recreating the cluster reverts it.
+	RequiresRecreate []specutil.FieldChange
+}
+
+// Drifted reports whether the live cluster differs from the desired spec.
+func (r *DriftReport) Drifted() bool { return len(r.Drift) > 0 }
+
+// Drift fetches the cluster and compares it with the desired spec, ignoring the fields
+// managed by the server, e.g. status and connection info.
+func (c *ClusterServiceClient) Drift(ctx context.Context, clusterID string, desired *ClusterSpec, options DriftOptions, opts ...grpc.CallOption) (*DriftReport, error) {
+	live, err := c.Get(ctx, &clickhouse.GetClusterRequest{ClusterId: clusterID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	report := &DriftReport{ClusterID: clusterID, Live: live, Drift: specutil.Drift(live, desired)}
+	report.RequiresRecreate = specutil.Immutable(live.ProtoReflect().Descriptor().FullName(), report.Drift)
+	if options.FixDrift && report.Drifted() {
+		report.Fix = &clickhouse.UpdateClusterRequest{ClusterId: clusterID}
+		report.Unfixable = specutil.FillUpdate(report.Fix, desired, report.Drift)
+	}
+	return report, nil
+}
@@ -0,0 +1,61 @@
+package clickhouse
+
+import (
+	"context"
+	"testing"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+type driftClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	live *clickhouse.Cluster
+}
+
+func (s *driftClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return s.live, nil
+}
+
+func TestDrift(t *testing.T) {
+	live := prodCluster()
+	live.Status = doublecloud.ClusterStatus_CLUSTER_STATUS_UPDATING
+	ch := newTestClickHouse(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, &driftClusters{live: live})
+	})
+	ctx := context.Background()
+
+	report, err := ch.Cluster().Drift(ctx, live.Id, &ClusterSpec{Name: live.Name, Version: live.Version}, DriftOptions{FixDrift: true})
+	require.NoError(t, err)
+	assert.False(t, report.Drifted())
+	assert.Nil(t, report.Fix)
+
+	desired := &ClusterSpec{
+		RegionId: "us-east-1",
+		Version:  "23.8",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ReplicaCount: wrapperspb.Int64(5),
+		}},
+	}
+	report, err = ch.Cluster().Drift(ctx, live.Id, desired, DriftOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Drifted())
+	assert.Len(t, report.Drift, 3)
+	assert.Nil(t, report.Fix, "the fix is built only if asked")
+
+	report, err = ch.Cluster().Drift(ctx, live.Id, desired, DriftOptions{FixDrift: true})
+	require.NoError(t, err)
+	assertProtoEqual(t, &clickhouse.UpdateClusterRequest{
+		ClusterId: live.Id,
+		Version:   "23.8",
+		Resources: desired.Resources,
+	}, report.Fix)
+	assert.Equal(t, []specutil.FieldChange{{Path: "region_id", Old: `"` + live.RegionId + `"`, New: `"us-east-1"`}}, report.Unfixable)
+	assert.Equal(t, report.Unfixable, report.RequiresRecreate, "region_id is immutable")
+}
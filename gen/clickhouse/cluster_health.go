@@ -0,0 +1,188 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultFailedOperationsWindow is the default of HealthOptions.FailedOperationsWindow.
+const DefaultFailedOperationsWindow = 24 * time.Hour
+
+// HealthVerdict is the overall health of a cluster, see ClusterHealth.
+type HealthVerdict string
+
+const (
+	HealthHealthy   HealthVerdict = "healthy"
+	HealthDegraded  HealthVerdict = "degraded"
+	HealthUnhealthy HealthVerdict = "unhealthy"
+)
+
+// HealthSummary is the health of a cluster, see ClusterServiceClient.Health.
+type HealthSummary struct {
+	ClusterID string
+	Status    doublecloud.ClusterStatus
+	Hosts     []*clickhouse.Host
+	// UnhealthyHosts are the hosts not alive, ordered by name.
+	UnhealthyHosts []*clickhouse.Host
+	// Maintenance is the planned maintenance of the cluster, nil if there is none.
+	Maintenance *doublecloud.MaintenanceOperation
+	// RunningOperations are the operations of the cluster not done yet.
+	RunningOperations []*doublecloud.Operation
+	// FailedOperations are the operations of the cluster that failed within
+	// HealthOptions.FailedOperationsWindow.
+	FailedOperations []*doublecloud.Operation
+
+	Verdict HealthVerdict
+	// Reasons explain a verdict other than HealthHealthy.
+	Reasons []string
+}
+
+// HealthRules computes the verdict of the summary and its reasons, see ClusterHealth.
+type HealthRules func(s *HealthSummary) (HealthVerdict, []string)
+
+// HealthOptions configures ClusterServiceClient.Health.
+type HealthOptions struct {
+	// Rules replaces ClusterHealth, e.g. for other thresholds.
+	Rules HealthRules
+	// FailedOperationsWindow is how far back failed operations are reported.
+	// Defaults to DefaultFailedOperationsWindow.
+	FailedOperationsWindow time.Duration
+}
+
+// Health returns the health of the cluster: its status, the statuses of its hosts, its planned
+// maintenance and its running and recently failed operations, with a verdict computed by
+// options.Rules, ClusterHealth by default. The operations are those of the first page of
+// ListOperations.
+func (c *ClusterServiceClient) Health(ctx context.Context, clusterID string, options HealthOptions, opts ...grpc.CallOption) (*HealthSummary, error) {
+	cluster, err := c.Get(ctx, &clickhouse.GetClusterRequest{ClusterId: clusterID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := c.ClusterHostsIterator(ctx, &clickhouse.ListClusterHostsRequest{ClusterId: clusterID}, opts...).TakeAll()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.ListOperations(ctx, &clickhouse.ListClusterOperationsRequest{ClusterId: clusterID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &HealthSummary{
+		ClusterID:   clusterID,
+		Status:      cluster.GetStatus(),
+		Hosts:       hosts,
+		Maintenance: cluster.GetMaintenanceOperation(),
+	}
+	for _, h := range hosts {
+		if h.GetStatus() != doublecloud.HostStatus_HOST_STATUS_ALIVE {
+			s.UnhealthyHosts = append(s.UnhealthyHosts, h)
+		}
+	}
+	sort.Slice(s.UnhealthyHosts, func(i, j int) bool { return s.UnhealthyHosts[i].GetName() < s.UnhealthyHosts[j].GetName() })
+
+	window := options.FailedOperationsWindow
+	if window <= 0 {
+		window = DefaultFailedOperationsWindow
+	}
+	since := time.Now().Add(-window)
+	for _, op := range resp.GetOperations() {
+		switch {
+		case !operation.StatusDone(op.GetStatus()):
+			s.RunningOperations = append(s.RunningOperations, op)
+		case op.GetError() != nil && !operationTime(op).Before(since):
+			s.FailedOperations = append(s.FailedOperations, op)
+		}
+	}
+
+	rules := options.Rules
+	if rules == nil {
+		rules = ClusterHealth
+	}
+	s.Verdict, s.Reasons = rules(s)
+	return s, nil
+}
+
+// operationTime is when the operation finished, or was created if the finish time is unset.
+func operationTime(op *doublecloud.Operation) time.Time {
+	if t := protoTime(op.GetFinishTime()); !t.IsZero() {
+		return t
+	}
+	return protoTime(op.GetCreateTime())
+}
+
+// ClusterHealth is the default HealthRules:
+//
+//   - HealthUnhealthy if the cluster is dead, failed or stopped, or has hosts and some shard
+//     of them has no alive host;
+//   - HealthDegraded if the cluster is degraded, in an unknown state or creating, starting or
+//     stopping, if its status is newer than the SDK, see sdkerrors.UnknownEnum, if some host
+//     is not alive, or if some operation failed recently;
+//   - HealthHealthy otherwise, including updating clusters, running operations and planned
+//     maintenance.
+//
+// The reasons list every rule that matched, the unhealthy ones first.
+func ClusterHealth(s *HealthSummary) (HealthVerdict, []string) {
+	var unhealthy, degraded []string
+	switch s.Status {
+	case doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE, doublecloud.ClusterStatus_CLUSTER_STATUS_UPDATING:
+	case doublecloud.ClusterStatus_CLUSTER_STATUS_DEAD, doublecloud.ClusterStatus_CLUSTER_STATUS_ERROR, doublecloud.ClusterStatus_CLUSTER_STATUS_STOPPED:
+		unhealthy = append(unhealthy, fmt.Sprintf("cluster is %s", clusterStatusName(s.Status)))
+	case doublecloud.ClusterStatus_CLUSTER_STATUS_DEGRADED, doublecloud.ClusterStatus_CLUSTER_STATUS_UNKNOWN,
+		doublecloud.ClusterStatus_CLUSTER_STATUS_INVALID, doublecloud.ClusterStatus_CLUSTER_STATUS_CREATING,
+		doublecloud.ClusterStatus_CLUSTER_STATUS_STARTING, doublecloud.ClusterStatus_CLUSTER_STATUS_STOPPING:
+		degraded = append(degraded, fmt.Sprintf("cluster is %s", clusterStatusName(s.Status)))
+	default:
+		// Not ready until the SDK knows the status.
+		sdkerrors.UnknownEnum(string(s.Status.Descriptor().FullName()), int32(s.Status))
+		degraded = append(degraded, fmt.Sprintf("cluster status %d is unknown to the SDK", s.Status))
+	}
+
+	alive := map[string]bool{}
+	var shards []string
+	for _, h := range s.Hosts {
+		if _, ok := alive[h.GetShardName()]; !ok {
+			shards = append(shards, h.GetShardName())
+		}
+		alive[h.GetShardName()] = alive[h.GetShardName()] || h.GetStatus() == doublecloud.HostStatus_HOST_STATUS_ALIVE
+	}
+	sort.Strings(shards)
+	for _, shard := range shards {
+		if !alive[shard] {
+			unhealthy = append(unhealthy, fmt.Sprintf("shard %q has no alive host", shard))
+		}
+	}
+	if n := len(s.UnhealthyHosts); n > 0 {
+		degraded = append(degraded, fmt.Sprintf("%d of %d hosts are not alive", n, len(s.Hosts)))
+	}
+	if n := len(s.FailedOperations); n > 0 {
+		degraded = append(degraded, fmt.Sprintf("%d operations failed recently", n))
+	}
+
+	switch {
+	case len(unhealthy) > 0:
+		return HealthUnhealthy, append(unhealthy, degraded...)
+	case len(degraded) > 0:
+		return HealthDegraded, degraded
+	}
+	return HealthHealthy, nil
+}
+
+// clusterStatusName returns the status without the enum prefix in lower case, e.g. "dead".
+func clusterStatusName(status doublecloud.ClusterStatus) string {
+	const prefix = len("CLUSTER_STATUS_")
+	name := status.String()
+	if len(name) > prefix {
+		name = name[prefix:]
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,164 @@
+package clickhouse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const (
+	alive    = doublecloud.HostStatus_HOST_STATUS_ALIVE
+	dead     = doublecloud.HostStatus_HOST_STATUS_DEAD
+	degraded = doublecloud.HostStatus_HOST_STATUS_DEGRADED
+)
+
+func host(name, shard string, status doublecloud.HostStatus) *clickhouse.Host {
+	return &clickhouse.Host{Name: name, ShardName: shard, Status: status}
+}
+
+func healthSummary(status doublecloud.ClusterStatus, hosts ...*clickhouse.Host) *HealthSummary {
+	s := &HealthSummary{Status: status, Hosts: hosts}
+	for _, h := range hosts {
+		if h.Status != alive {
+			s.UnhealthyHosts = append(s.UnhealthyHosts, h)
+		}
+	}
+	return s
+}
+
+func TestClusterHealth(t *testing.T) {
+	failed := healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE, host("h1", "s1", alive))
+	failed.FailedOperations = []*doublecloud.Operation{{Id: "cho1"}}
+	running := healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE, host("h1", "s1", alive))
+	running.RunningOperations = []*doublecloud.Operation{{Id: "cho1"}}
+	running.Maintenance = &doublecloud.MaintenanceOperation{Info: "upgrade"}
+
+	for _, tc := range []struct {
+		name    string
+		summary *HealthSummary
+		verdict HealthVerdict
+		reasons []string
+	}{
+		{"all alive", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE,
+			host("h1", "s1", alive), host("h2", "s1", alive), host("h3", "s2", alive)),
+			HealthHealthy, nil},
+		{"updating", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_UPDATING, host("h1", "s1", alive)),
+			HealthHealthy, nil},
+		{"running operations and maintenance", running, HealthHealthy, nil},
+		{"replica dead", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_DEGRADED,
+			host("h1", "s1", alive), host("h2", "s1", dead)),
+			HealthDegraded, []string{"cluster is degraded", "1 of 2 hosts are not alive"}},
+		{"host degraded", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE,
+			host("h1", "s1", degraded), host("h2", "s1", alive)),
+			HealthDegraded, []string{"1 of 2 hosts are not alive"}},
+		{"shard down", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_DEGRADED,
+			host("h1", "s1", alive), host("h2", "s2", dead), host("h3", "s2", degraded)),
+			HealthUnhealthy, []string{`shard "s2" has no alive host`, "cluster is degraded", "2 of 3 hosts are not alive"}},
+		{"cluster dead", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_DEAD, host("h1", "", dead)),
+			HealthUnhealthy, []string{"cluster is dead", `shard "" has no alive host`, "1 of 1 hosts are not alive"}},
+		{"stopped", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_STOPPED),
+			HealthUnhealthy, []string{"cluster is stopped"}},
+		{"creating", healthSummary(doublecloud.ClusterStatus_CLUSTER_STATUS_CREATING),
+			HealthDegraded, []string{"cluster is creating"}},
+		{"failed operation", failed, HealthDegraded, []string{"1 operations failed recently"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			verdict, reasons := ClusterHealth(tc.summary)
+			assert.Equal(t, tc.verdict, verdict)
+			assert.Equal(t, tc.reasons, reasons)
+		})
+	}
+}
+
+type healthClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	hosts      []*clickhouse.Host
+	operations []*doublecloud.Operation
+}
+
+func (s *healthClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return &clickhouse.Cluster{
+		Id:                   req.ClusterId,
+		Status:               doublecloud.ClusterStatus_CLUSTER_STATUS_DEGRADED,
+		MaintenanceOperation: &doublecloud.MaintenanceOperation{Info: "upgrade"},
+	}, nil
+}
+
+func (s *healthClusters) ListHosts(ctx context.Context, req *clickhouse.ListClusterHostsRequest) (*clickhouse.ListClusterHostsResponse, error) {
+	return &clickhouse.ListClusterHostsResponse{Hosts: s.hosts}, nil
+}
+
+func (s *healthClusters) ListOperations(ctx context.Context, req *clickhouse.ListClusterOperationsRequest) (*clickhouse.ListClusterOperationsResponse, error) {
+	return &clickhouse.ListClusterOperationsResponse{Operations: s.operations}, nil
+}
+
+func TestHealth(t *testing.T) {
+	failure := &rpcstatus.Status{Code: 13, Message: "boom"}
+	srv := &healthClusters{
+		hosts: []*clickhouse.Host{host("h2", "s1", dead), host("h1", "s1", alive)},
+		operations: []*doublecloud.Operation{
+			{Id: "cho-running", Status: doublecloud.Operation_STATUS_RUNNING},
+			{Id: "cho-failed", Status: doublecloud.Operation_STATUS_DONE, Error: failure, FinishTime: timestamppb.New(time.Now().Add(-time.Hour))},
+			{Id: "cho-old", Status: doublecloud.Operation_STATUS_DONE, Error: failure, FinishTime: timestamppb.New(time.Now().Add(-48 * time.Hour))},
+			{Id: "cho-done", Status: doublecloud.Operation_STATUS_DONE, FinishTime: timestamppb.Now()},
+		},
+	}
+	ch := newTestClickHouse(t, func(s *grpc.Server) { clickhouse.RegisterClusterServiceServer(s, srv) })
+
+	s, err := ch.Cluster().Health(context.Background(), "chc1", HealthOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "chc1", s.ClusterID)
+	assert.Len(t, s.Hosts, 2)
+	require.Len(t, s.UnhealthyHosts, 1)
+	assert.Equal(t, "h2", s.UnhealthyHosts[0].Name)
+	assert.Equal(t, "upgrade", s.Maintenance.GetInfo())
+	require.Len(t, s.RunningOperations, 1)
+	assert.Equal(t, "cho-running", s.RunningOperations[0].Id)
+	require.Len(t, s.FailedOperations, 1)
+	assert.Equal(t, "cho-failed", s.FailedOperations[0].Id)
+	assert.Equal(t, HealthDegraded, s.Verdict)
+	assert.Equal(t, []string{"cluster is degraded", "1 of 2 hosts are not alive", "1 operations failed recently"}, s.Reasons)
+
+	s, err = ch.Cluster().Health(context.Background(), "chc1", HealthOptions{
+		FailedOperationsWindow: 72 * time.Hour,
+		Rules: func(s *HealthSummary) (HealthVerdict, []string) {
+			if len(s.UnhealthyHosts) > 0 {
+				return HealthUnhealthy, []string{"any host down"}
+			}
+			return HealthHealthy, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, s.FailedOperations, 2)
+	assert.Equal(t, HealthUnhealthy, s.Verdict)
+	assert.Equal(t, []string{"any host down"}, s.Reasons)
+}
+
+// TestClusterHealth_StatusEnum checks that every cluster status of the API is handled
+// explicitly, and that the statuses added since are not ready.
+func TestClusterHealth_StatusEnum(t *testing.T) {
+	var unknown []int32
+	defer sdkerrors.SetUnknownEnumHandler(func(kind string, value int32) {
+		assert.Equal(t, "doublecloud.v1.ClusterStatus", kind)
+		unknown = append(unknown, value)
+	})()
+
+	for v, name := range doublecloud.ClusterStatus_name {
+		ClusterHealth(healthSummary(doublecloud.ClusterStatus(v)))
+		assert.Empty(t, unknown, name)
+	}
+	verdict, reasons := ClusterHealth(healthSummary(doublecloud.ClusterStatus(99), host("h1", "s1", alive)))
+	assert.Equal(t, []int32{99}, unknown)
+	assert.Equal(t, HealthDegraded, verdict)
+	assert.Equal(t, []string{"cluster status 99 is unknown to the SDK"}, reasons)
+}
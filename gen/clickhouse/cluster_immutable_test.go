@@ -0,0 +1,62 @@
+package clickhouse
+
+import (
+	"context"
+	"testing"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+type immutableClusters struct {
+	driftClusters
+	gets, updates int
+}
+
+func (s *immutableClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	s.gets++
+	return s.live, nil
+}
+
+func (s *immutableClusters) Update(ctx context.Context, req *clickhouse.UpdateClusterRequest) (*doublecloud.Operation, error) {
+	s.updates++
+	return &doublecloud.Operation{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+func TestUpdate_Immutable(t *testing.T) {
+	live := prodCluster()
+	srv := &immutableClusters{driftClusters: driftClusters{live: live}}
+	ch := newTestClickHouse(t, func(s *grpc.Server) { clickhouse.RegisterClusterServiceServer(s, srv) })
+	ctx := context.Background()
+
+	_, err := ch.Cluster().Update(ctx, &clickhouse.UpdateClusterRequest{ClusterId: live.Id, Version: "24.3"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, srv.gets, "the cluster is not fetched without immutable fields in the update")
+
+	// A deployment where the version can't be changed in place.
+	specutil.RegisterImmutable("doublecloud.clickhouse.v1.Cluster", "version")
+	defer specutil.UnregisterImmutable("doublecloud.clickhouse.v1.Cluster", "version")
+	_, err = ch.Cluster().Update(ctx, &clickhouse.UpdateClusterRequest{ClusterId: live.Id, Version: "24.3"})
+	var immutableErr *specutil.ImmutableFieldError
+	require.ErrorAs(t, err, &immutableErr)
+	assert.ErrorIs(t, err, specutil.ErrImmutableField)
+	assert.Equal(t, "version", immutableErr.Path)
+	assert.Equal(t, `"`+live.Version+`"`, immutableErr.Current)
+	assert.Equal(t, 1, srv.updates, "the update is rejected before it is sent")
+
+	_, err = ch.Cluster().Update(ctx, &clickhouse.UpdateClusterRequest{ClusterId: live.Id, Version: live.Version, Description: "Orders"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, srv.updates)
+
+	report, err := ch.Cluster().Drift(ctx, live.Id, &ClusterSpec{Version: "24.3", Description: "Orders"}, DriftOptions{FixDrift: true})
+	require.NoError(t, err)
+	require.Len(t, report.RequiresRecreate, 1)
+	assert.Equal(t, "version", report.RequiresRecreate[0].Path, "immutable drift requires a recreate")
+	assert.Equal(t, report.RequiresRecreate, report.Unfixable)
+	assert.Empty(t, report.Fix.GetVersion())
+}
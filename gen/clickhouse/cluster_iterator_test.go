@@ -0,0 +1,68 @@
+package clickhouse
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
+)
+
+type listClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+}
+
+func (listClusters) List(ctx context.Context, req *clickhouse.ListClustersRequest) (*clickhouse.ListClustersResponse, error) {
+	return &clickhouse.ListClustersResponse{Clusters: []*clickhouse.Cluster{{Id: "chc1"}, {Id: "chc2"}}}, nil
+}
+
+func TestClusterIterator_Close(t *testing.T) {
+	ch := newTestClickHouse(t, func(s *grpc.Server) { clickhouse.RegisterClusterServiceServer(s, listClusters{}) })
+	lifecycle.EnableLeakDetection(true)
+	defer lifecycle.EnableLeakDetection(false)
+	leaks := make(chan lifecycle.Leak, 10)
+	defer lifecycle.SetLeakReporter(func(l lifecycle.Leak) { leaks <- l })()
+	ctx := context.Background()
+
+	func() {
+		closed := ch.Cluster().ClusterIterator(ctx, &clickhouse.ListClustersRequest{})
+		require.True(t, closed.Next())
+		closed.Close()
+		assert.False(t, closed.Next(), "closed mid-way")
+		assert.NoError(t, closed.Error())
+
+		exhausted := ch.Cluster().ClusterIterator(ctx, &clickhouse.ListClustersRequest{})
+		clusters, err := exhausted.TakeAll()
+		require.NoError(t, err)
+		assert.Len(t, clusters, 2)
+
+		abandoned := ch.Cluster().ClusterIterator(ctx, &clickhouse.ListClustersRequest{})
+		require.True(t, abandoned.Next())
+	}()
+
+	var reported []lifecycle.Leak
+	for deadline := time.Now().Add(5 * time.Second); len(reported) == 0 && time.Now().Before(deadline); {
+		runtime.GC()
+		select {
+		case l := <-leaks:
+			reported = append(reported, l)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	for len(leaks) > 0 {
+		reported = append(reported, <-leaks)
+	}
+	require.Len(t, reported, 1, "only the abandoned iterator is reported")
+	assert.Equal(t, "clickhouse.ClusterIterator", reported[0].Kind)
+	assert.Contains(t, reported[0].Stack, "TestClusterIterator_Close")
+}
@@ -0,0 +1,124 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/locale"
+	"github.com/doublecloud/go-sdk/pkg/paging"
+)
+
+// MaintenanceInfo is the planned maintenance of a cluster.
+type MaintenanceInfo struct {
+	// Info is a human readable description of the maintenance.
+	Info string
+	// ScheduledTime is when the maintenance starts.
+	ScheduledTime time.Time
+	// Deadline is the latest time the maintenance can be delayed until, zero if not limited.
+	Deadline time.Time
+	// NextWindowTime is the start of the next maintenance window, zero if the cluster has none.
+	NextWindowTime time.Time
+	// Operation is the earliest running cluster operation created at or after ScheduledTime,
+	// i.e. the maintenance once it started, and nil before that. The maintenance info
+	// doesn't carry an operation ID, so this is a best-effort match. The operations of the
+	// cluster are listed page by page, until the ones created before ScheduledTime if they
+	// are listed newest first.
+	Operation *operation.Operation
+	// Locale is the locale of the context of MaintenanceInfo, String formats with.
+	Locale locale.Locale
+}
+
+// Format describes the maintenance in the language and the time zone of l.
+func (m *MaintenanceInfo) Format(l locale.Locale) string {
+	parts := []string{l.Message("maintenance.info", m.Info, l.Time(m.ScheduledTime))}
+	if !m.Deadline.IsZero() {
+		parts = append(parts, l.Message("maintenance.deadline", l.Time(m.Deadline)))
+	}
+	if !m.NextWindowTime.IsZero() {
+		parts = append(parts, l.Message("maintenance.window", l.Time(m.NextWindowTime)))
+	}
+	if m.Operation != nil {
+		parts = append(parts, l.Message("maintenance.started", m.Operation.Id()))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// String describes the maintenance in its Locale, see Format.
+func (m *MaintenanceInfo) String() string {
+	return m.Format(m.Locale)
+}
+
+// MaintenanceInfo returns the planned maintenance of the cluster, nil if there is none,
+// described in the locale of ctx, see locale.NewContext.
+func (c *ClusterServiceClient) MaintenanceInfo(ctx context.Context, clusterID string, opts ...grpc.CallOption) (*MaintenanceInfo, error) {
+	cluster, err := c.Get(ctx, &clickhouse.GetClusterRequest{ClusterId: clusterID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m := cluster.GetMaintenanceOperation()
+	if m == nil {
+		return nil, nil
+	}
+	info := &MaintenanceInfo{
+		Info:           m.GetInfo(),
+		ScheduledTime:  protoTime(m.GetScheduledMaintenanceTime()),
+		Deadline:       protoTime(m.GetDeadlineMaintenanceTime()),
+		NextWindowTime: protoTime(m.GetNextMaintenanceWindowTime()),
+		Locale:         locale.FromContext(ctx),
+	}
+	ops := paging.New(ctx, func(ctx context.Context, p *doublecloud.Paging) ([]*doublecloud.Operation, *doublecloud.NextPage, error) {
+		resp, err := c.ListOperations(ctx, &clickhouse.ListClusterOperationsRequest{ClusterId: clusterID, Paging: p}, opts...)
+		return resp.GetOperations(), resp.GetNextPage(), err
+	})
+	defer ops.Close()
+	var started *doublecloud.Operation
+	var previous time.Time
+	for ops.Next() {
+		op := ops.Value()
+		created := protoTime(op.GetCreateTime())
+		if created.Before(info.ScheduledTime) {
+			if !previous.IsZero() && created.Before(previous) {
+				// Listed newest first: the operations of the next pages are older.
+				break
+			}
+		} else if !operation.StatusDone(op.GetStatus()) && (started == nil || created.Before(protoTime(started.GetCreateTime()))) {
+			started = op
+		}
+		previous = created
+	}
+	if err := ops.Error(); err != nil {
+		return nil, err
+	}
+	if started != nil {
+		info.Operation = operation.New(&OperationServiceClient{getConn: c.getConn}, started)
+	}
+	return info, nil
+}
+
+// RescheduleMaintenanceUntil delays the planned maintenance of the cluster until the given time,
+// which must not be after MaintenanceInfo.Deadline.
+func (c *ClusterServiceClient) RescheduleMaintenanceUntil(ctx context.Context, clusterID string, until time.Time, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
+	if until.IsZero() {
+		return nil, errors.New("reschedule time required")
+	}
+	return c.RescheduleMaintenance(ctx, &clickhouse.RescheduleMaintenanceRequest{
+		ClusterId:        clusterID,
+		RescheduleType:   doublecloud.RescheduleType_RESCHEDULE_TYPE_SPECIFIC_TIME,
+		DelayedUntilTime: timestamppb.New(until),
+	}, opts...)
+}
+
+func protoTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,177 @@
+package clickhouse
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/pkg/locale"
+)
+
+var maintenanceTime = time.Date(2023, 5, 20, 3, 0, 0, 0, time.UTC)
+
+type maintenanceClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	maintenance *doublecloud.MaintenanceOperation
+	operations  []*doublecloud.Operation
+	// pageSize, if set, pages the operations.
+	pageSize    int
+	pages       int
+	rescheduled *clickhouse.RescheduleMaintenanceRequest
+}
+
+func (s *maintenanceClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return &clickhouse.Cluster{Id: req.ClusterId, MaintenanceOperation: s.maintenance}, nil
+}
+
+func (s *maintenanceClusters) ListOperations(ctx context.Context, req *clickhouse.ListClusterOperationsRequest) (*clickhouse.ListClusterOperationsResponse, error) {
+	s.pages++
+	if s.pageSize == 0 {
+		return &clickhouse.ListClusterOperationsResponse{Operations: s.operations}, nil
+	}
+	offset, _ := strconv.Atoi(req.GetPaging().GetPageToken())
+	end := offset + s.pageSize
+	if end >= len(s.operations) {
+		return &clickhouse.ListClusterOperationsResponse{Operations: s.operations[offset:]}, nil
+	}
+	return &clickhouse.ListClusterOperationsResponse{Operations: s.operations[offset:end], NextPage: &doublecloud.NextPage{Token: strconv.Itoa(end)}}, nil
+}
+
+func (s *maintenanceClusters) RescheduleMaintenance(ctx context.Context, req *clickhouse.RescheduleMaintenanceRequest) (*doublecloud.Operation, error) {
+	s.rescheduled = req
+	return &doublecloud.Operation{Id: "cho-reschedule", ResourceId: req.ClusterId}, nil
+}
+
+type maintenanceOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+}
+
+func (maintenanceOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*doublecloud.Operation, error) {
+	return &doublecloud.Operation{Id: req.OperationId, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+func newMaintenanceClickHouse(t *testing.T, srv *maintenanceClusters) *ClickHouse {
+	return newTestClickHouse(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, srv)
+		clickhouse.RegisterOperationServiceServer(s, maintenanceOperations{})
+	})
+}
+
+func TestMaintenanceInfo(t *testing.T) {
+	srv := &maintenanceClusters{
+		maintenance: &doublecloud.MaintenanceOperation{
+			Info:                     "Upgrade to 23.3",
+			ScheduledMaintenanceTime: timestamppb.New(maintenanceTime),
+			DeadlineMaintenanceTime:  timestamppb.New(maintenanceTime.Add(14 * 24 * time.Hour)),
+		},
+	}
+	ch := newMaintenanceClickHouse(t, srv)
+	ctx := context.Background()
+
+	info, err := ch.Cluster().MaintenanceInfo(ctx, "chc1")
+	require.NoError(t, err)
+	assert.Equal(t, "Upgrade to 23.3", info.Info)
+	assert.True(t, maintenanceTime.Equal(info.ScheduledTime))
+	assert.True(t, maintenanceTime.Add(14*24*time.Hour).Equal(info.Deadline))
+	assert.True(t, info.NextWindowTime.IsZero())
+	assert.Nil(t, info.Operation, "not started yet")
+
+	srv.operations = []*doublecloud.Operation{
+		{Id: "cho-update", Status: doublecloud.Operation_STATUS_RUNNING, CreateTime: timestamppb.New(maintenanceTime.Add(-time.Hour))},
+		{Id: "cho-maintenance", Status: doublecloud.Operation_STATUS_RUNNING, CreateTime: timestamppb.New(maintenanceTime)},
+	}
+	info, err = ch.Cluster().MaintenanceInfo(ctx, "chc1")
+	require.NoError(t, err)
+	require.NotNil(t, info.Operation)
+	assert.Equal(t, "cho-maintenance", info.Operation.Id())
+	require.NoError(t, info.Operation.Wait(ctx))
+	assert.True(t, info.Operation.Done())
+}
+
+func TestMaintenanceInfo_Locale(t *testing.T) {
+	srv := &maintenanceClusters{
+		maintenance: &doublecloud.MaintenanceOperation{
+			Info:                     "Upgrade to 23.3",
+			ScheduledMaintenanceTime: timestamppb.New(maintenanceTime),
+			DeadlineMaintenanceTime:  timestamppb.New(maintenanceTime.Add(14 * 24 * time.Hour)),
+		},
+	}
+	ch := newMaintenanceClickHouse(t, srv)
+
+	info, err := ch.Cluster().MaintenanceInfo(context.Background(), "chc1")
+	require.NoError(t, err)
+	assert.Equal(t, `maintenance "Upgrade to 23.3" is scheduled for May 20, 2023 03:00 UTC; it can be delayed until Jun 3, 2023 03:00 UTC`, info.String())
+
+	msk := time.FixedZone("MSK", 3*60*60)
+	info, err = ch.Cluster().MaintenanceInfo(locale.NewContext(context.Background(), locale.New("ru", msk)), "chc1")
+	require.NoError(t, err)
+	assert.Equal(t, `обслуживание "Upgrade to 23.3" запланировано на 20.05.2023 06:00 MSK; его можно отложить до 03.06.2023 06:00 MSK`, info.String())
+	assert.Equal(t, `maintenance "Upgrade to 23.3" is scheduled for May 20, 2023 06:00 MSK; it can be delayed until Jun 3, 2023 06:00 MSK`, info.Format(locale.New("fr", msk)), "no French translation")
+}
+
+func TestMaintenanceInfo_NotPlanned(t *testing.T) {
+	ch := newMaintenanceClickHouse(t, &maintenanceClusters{})
+	info, err := ch.Cluster().MaintenanceInfo(context.Background(), "chc1")
+	require.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestRescheduleMaintenanceUntil(t *testing.T) {
+	srv := &maintenanceClusters{}
+	ch := newMaintenanceClickHouse(t, srv)
+	ctx := context.Background()
+
+	op, err := ch.Cluster().RescheduleMaintenanceUntil(ctx, "chc1", maintenanceTime.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "cho-reschedule", op.Id)
+	assert.Equal(t, "chc1", srv.rescheduled.ClusterId)
+	assert.Equal(t, doublecloud.RescheduleType_RESCHEDULE_TYPE_SPECIFIC_TIME, srv.rescheduled.RescheduleType)
+	assert.True(t, maintenanceTime.Add(time.Hour).Equal(srv.rescheduled.DelayedUntilTime.AsTime()))
+
+	_, err = ch.Cluster().RescheduleMaintenanceUntil(ctx, "chc1", time.Time{})
+	assert.EqualError(t, err, "reschedule time required")
+}
+
+func TestMaintenanceInfo_Pages(t *testing.T) {
+	at := func(d time.Duration) *timestamppb.Timestamp { return timestamppb.New(maintenanceTime.Add(d)) }
+	done, running := doublecloud.Operation_STATUS_DONE, doublecloud.Operation_STATUS_RUNNING
+	for name, tc := range map[string]struct {
+		operations []*doublecloud.Operation
+		pages      int
+	}{
+		"oldest first": {[]*doublecloud.Operation{
+			{Id: "cho-old", Status: done, CreateTime: at(-2 * time.Hour)},
+			{Id: "cho-update", Status: running, CreateTime: at(-time.Hour)},
+			{Id: "cho-maintenance", Status: running, CreateTime: at(time.Minute)},
+			{Id: "cho-scale", Status: running, CreateTime: at(time.Hour)},
+		}, 4},
+		"newest first": {[]*doublecloud.Operation{
+			{Id: "cho-scale", Status: running, CreateTime: at(time.Hour)},
+			{Id: "cho-maintenance", Status: running, CreateTime: at(time.Minute)},
+			{Id: "cho-update", Status: running, CreateTime: at(-time.Hour)},
+			{Id: "cho-old", Status: done, CreateTime: at(-2 * time.Hour)},
+		}, 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := &maintenanceClusters{
+				maintenance: &doublecloud.MaintenanceOperation{Info: "Upgrade", ScheduledMaintenanceTime: timestamppb.New(maintenanceTime)},
+				operations:  tc.operations,
+				pageSize:    1,
+			}
+			info, err := newMaintenanceClickHouse(t, srv).Cluster().MaintenanceInfo(context.Background(), "chc1")
+			require.NoError(t, err)
+			require.NotNil(t, info.Operation, "found on a later page")
+			assert.Equal(t, "cho-maintenance", info.Operation.Id(), "the earliest started since the scheduled time")
+			assert.Equal(t, tc.pages, srv.pages, "no page is listed past the older operations")
+		})
+	}
+}
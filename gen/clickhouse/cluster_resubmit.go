@@ -0,0 +1,23 @@
+package clickhouse
+
+import (
+	"context"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// CreateWithResubmit creates the cluster and waits for it. If the operation ends invalid and
+// resubmittable (see operation.IsResubmittable), the create is made again as the policy allows.
+// The result lists the operations of all attempts.
+func (c *ClusterServiceClient) CreateWithResubmit(ctx context.Context, in *clickhouse.CreateClusterRequest, policy operation.ResubmitPolicy, opts ...grpc.CallOption) (*operation.ResubmitResult, error) {
+	return operation.Resubmit(ctx, policy, func(ctx context.Context) (*operation.Operation, error) {
+		p, err := c.Create(ctx, in, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return operation.New(&OperationServiceClient{getConn: c.getConn}, p), nil
+	}, opts...)
+}
@@ -0,0 +1,84 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// resubmitClusters starts an operation per create call. The operations of the first
+// invalid calls end with STATUS_INVALID for the reason, the later ones succeed.
+type resubmitClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	invalid int
+	reason  string
+
+	mu      sync.Mutex
+	creates int
+}
+
+func (s *resubmitClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*doublecloud.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creates++
+	return &doublecloud.Operation{Id: fmt.Sprintf("cho%d", s.creates), Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+type resubmitOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	*resubmitClusters
+}
+
+func (s resubmitOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*doublecloud.Operation, error) {
+	var n int
+	_, _ = fmt.Sscanf(req.OperationId, "cho%d", &n)
+	if n > s.invalid {
+		return &doublecloud.Operation{Id: req.OperationId, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}
+	st, err := status.New(codes.Unavailable, "no capacity").WithDetails(&errdetails.ErrorInfo{Reason: s.reason})
+	if err != nil {
+		return nil, err
+	}
+	return &doublecloud.Operation{Id: req.OperationId, Status: doublecloud.Operation_STATUS_INVALID, Error: st.Proto()}, nil
+}
+
+func TestCreateWithResubmit(t *testing.T) {
+	require.NoError(t, operation.RegisterResubmittable(operation.KindClickHouse, "TEST_CAPACITY_EXHAUSTED"))
+	policy := operation.ResubmitPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	for name, tc := range map[string]struct {
+		srv *resubmitClusters
+		ops int
+		ok  bool
+	}{
+		"resubmitted":   {srv: &resubmitClusters{invalid: 2, reason: "TEST_CAPACITY_EXHAUSTED"}, ops: 3, ok: true},
+		"out of tries":  {srv: &resubmitClusters{invalid: 3, reason: "TEST_CAPACITY_EXHAUSTED"}, ops: 3},
+		"not listed":    {srv: &resubmitClusters{invalid: 1, reason: "QUOTA_EXCEEDED"}, ops: 1},
+		"first succeed": {srv: &resubmitClusters{}, ops: 1, ok: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ch := newTestClickHouse(t, func(s *grpc.Server) {
+				clickhouse.RegisterClusterServiceServer(s, tc.srv)
+				clickhouse.RegisterOperationServiceServer(s, resubmitOperations{resubmitClusters: tc.srv})
+			})
+			res, err := ch.Cluster().CreateWithResubmit(context.Background(), &clickhouse.CreateClusterRequest{Name: "c"}, policy)
+			assert.Equal(t, tc.ok, err == nil, "error: %v", err)
+			assert.Len(t, res.Operations, tc.ops)
+			assert.Equal(t, tc.ops, tc.srv.creates)
+			assert.Equal(t, tc.ok, res.Last().Ok())
+		})
+	}
+}
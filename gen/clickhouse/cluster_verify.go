@@ -0,0 +1,26 @@
+package clickhouse
+
+import (
+	"context"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+// VerifyUpdated returns a verification function for operation.WaitAndVerify that reports
+// whether the cluster read back from the service reflects every field set in the update request.
+func (c *ClusterServiceClient) VerifyUpdated(req *clickhouse.UpdateClusterRequest, opts ...grpc.CallOption) operation.VerifyFunc {
+	want := proto.Clone(req).(*clickhouse.UpdateClusterRequest)
+	want.ClusterId = ""
+	return func(ctx context.Context) (bool, error) {
+		cluster, err := c.Get(ctx, &clickhouse.GetClusterRequest{ClusterId: req.GetClusterId()}, opts...)
+		if err != nil {
+			return false, err
+		}
+		return specutil.Matches(cluster, want), nil
+	}
+}
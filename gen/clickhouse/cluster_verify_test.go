@@ -0,0 +1,75 @@
+package clickhouse
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// staleClusters serves the previous cluster state for the first staleReads Get calls.
+type staleClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	staleReads int
+	gets       int
+}
+
+func (s *staleClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	s.gets++
+	replicas := int64(3)
+	if s.gets <= s.staleReads {
+		replicas = 1
+	}
+	return &clickhouse.Cluster{
+		Id:          req.ClusterId,
+		Name:        "prod",
+		Description: "main cluster",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			ReplicaCount:     wrapperspb.Int64(replicas),
+		}},
+	}, nil
+}
+
+func newTestClickHouse(t *testing.T, register func(s *grpc.Server)) *ClickHouse {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewClickHouse(func(ctx context.Context) (*grpc.ClientConn, error) { return conn, nil })
+}
+
+func TestVerifyUpdated(t *testing.T) {
+	srv := &staleClusters{staleReads: 2}
+	ch := newTestClickHouse(t, func(s *grpc.Server) { clickhouse.RegisterClusterServiceServer(s, srv) })
+	verify := ch.Cluster().VerifyUpdated(&clickhouse.UpdateClusterRequest{
+		ClusterId: "chc1",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ReplicaCount: wrapperspb.Int64(3),
+		}},
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		ok, err := verify(ctx)
+		require.NoError(t, err)
+		assert.False(t, ok, "stale read %d", i)
+	}
+	ok, err := verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
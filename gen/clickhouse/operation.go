@@ -7,6 +7,8 @@ import (
 	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // OperationServiceClient is a clickhouse.OperationServiceClient with
 // lazy GRPC connection initialization.
 type OperationServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Get implements clickhouse.OperationServiceClient
@@ -48,6 +50,7 @@ type OperationIterator struct {
 	request *clickhouse.ListOperationsRequest
 
 	items []*doublecloud.Operation
+	leak  *lifecycle.Tracker
 }
 
 func (c *OperationServiceClient) OperationIterator(ctx context.Context, req *clickhouse.ListOperationsRequest, opts ...grpc.CallOption) *OperationIterator {
@@ -57,13 +60,15 @@ func (c *OperationServiceClient) OperationIterator(ctx context.Context, req *cli
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &OperationIterator{
+	it := &OperationIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "clickhouse.OperationIterator")
+	return it
 }
 
 func (it *OperationIterator) Next() bool {
@@ -78,6 +83,7 @@ func (it *OperationIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -85,11 +91,16 @@ func (it *OperationIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Operations
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *OperationIterator) Take(size int64) ([]*doublecloud.Operation, error) {
@@ -134,3 +145,10 @@ func (it *OperationIterator) Value() *doublecloud.Operation {
 func (it *OperationIterator) Error() error {
 	return it.err
 }
+
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *OperationIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
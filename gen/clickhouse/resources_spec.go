@@ -0,0 +1,64 @@
+package clickhouse
+
+import (
+	"fmt"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ResourcesSpec builds ClusterResources for create and update requests.
+// Sizes are wrapped in the proto, so a field is sent only if its setter was called:
+// an unset field keeps the service default (or the current value on update),
+// while an explicitly set zero is sent as zero and rejected by Validate.
+type ResourcesSpec struct {
+	r *clickhouse.ClusterResources_Clickhouse
+}
+
+// NewResourcesSpec starts a spec with the given resource preset.
+// An empty preset leaves the preset unchanged on update.
+func NewResourcesSpec(resourcePresetID string) *ResourcesSpec {
+	return &ResourcesSpec{r: &clickhouse.ClusterResources_Clickhouse{ResourcePresetId: resourcePresetID}}
+}
+
+// SetDiskSize sets the disk size per replica in bytes.
+func (s *ResourcesSpec) SetDiskSize(bytes int64) *ResourcesSpec {
+	s.r.DiskSize = wrapperspb.Int64(bytes)
+	return s
+}
+
+func (s *ResourcesSpec) SetReplicaCount(n int64) *ResourcesSpec {
+	s.r.ReplicaCount = wrapperspb.Int64(n)
+	return s
+}
+
+func (s *ResourcesSpec) SetShardCount(n int64) *ResourcesSpec {
+	s.r.ShardCount = wrapperspb.Int64(n)
+	return s
+}
+
+// Validate rejects explicitly set non-positive sizes: the service cannot tell whether
+// those mean "keep the current value" or a real zero.
+func (s *ResourcesSpec) Validate() error {
+	for _, f := range []struct {
+		name  string
+		value *wrapperspb.Int64Value
+	}{
+		{"disk_size", s.r.DiskSize},
+		{"replica_count", s.r.ReplicaCount},
+		{"shard_count", s.r.ShardCount},
+	} {
+		if f.value != nil && f.value.GetValue() <= 0 {
+			return fmt.Errorf("clickhouse resources: %s must be positive if set, got %d", f.name, f.value.GetValue())
+		}
+	}
+	return nil
+}
+
+// Build validates the spec and returns the resources proto.
+func (s *ResourcesSpec) Build() (*clickhouse.ClusterResources, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &clickhouse.ClusterResources{Clickhouse: s.r}, nil
+}
@@ -0,0 +1,43 @@
+package clickhouse
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// Golden wire representation of ClusterResources.Clickhouse: an unset field is absent,
+// an explicit zero is an empty wrapper message.
+func TestResourcesSpec_Wire(t *testing.T) {
+	for name, tc := range map[string]struct {
+		spec *ResourcesSpec
+		wire string
+	}{
+		"unset":              {NewResourcesSpec("s1"), "0a027331"},
+		"disk_size zero":     {NewResourcesSpec("s1").SetDiskSize(0), "0a0273311200"},
+		"disk_size one":      {NewResourcesSpec("s1").SetDiskSize(1), "0a02733112020801"},
+		"replica_count zero": {NewResourcesSpec("s1").SetReplicaCount(0), "0a0273311a00"},
+		"replica_count one":  {NewResourcesSpec("s1").SetReplicaCount(1), "0a0273311a020801"},
+		"shard_count zero":   {NewResourcesSpec("s1").SetShardCount(0), "0a0273312200"},
+		"shard_count one":    {NewResourcesSpec("s1").SetShardCount(1), "0a02733122020801"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(tc.spec.r)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wire, hex.EncodeToString(b))
+		})
+	}
+}
+
+func TestResourcesSpec_Validate(t *testing.T) {
+	_, err := NewResourcesSpec("s1").SetDiskSize(32 << 30).SetReplicaCount(0).Build()
+	assert.EqualError(t, err, "clickhouse resources: replica_count must be positive if set, got 0")
+
+	res, err := NewResourcesSpec("s1").SetShardCount(2).Build()
+	require.NoError(t, err)
+	assert.Nil(t, res.GetClickhouse().GetReplicaCount())
+	assert.Equal(t, int64(2), res.GetClickhouse().GetShardCount().GetValue())
+}
@@ -6,6 +6,8 @@ import (
 
 	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -13,7 +15,7 @@ import (
 // VersionServiceClient is a clickhouse.VersionServiceClient with
 // lazy GRPC connection initialization.
 type VersionServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // List implements clickhouse.VersionServiceClient
@@ -38,6 +40,7 @@ type VersionIterator struct {
 	request *clickhouse.ListVersionsRequest
 
 	items []*clickhouse.Version
+	leak  *lifecycle.Tracker
 }
 
 func (c *VersionServiceClient) VersionIterator(ctx context.Context, req *clickhouse.ListVersionsRequest, opts ...grpc.CallOption) *VersionIterator {
@@ -47,13 +50,15 @@ func (c *VersionServiceClient) VersionIterator(ctx context.Context, req *clickho
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &VersionIterator{
+	it := &VersionIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "clickhouse.VersionIterator")
+	return it
 }
 
 func (it *VersionIterator) Next() bool {
@@ -68,6 +73,7 @@ func (it *VersionIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -75,11 +81,16 @@ func (it *VersionIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Versions
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *VersionIterator) Take(size int64) ([]*clickhouse.Version, error) {
@@ -124,3 +135,10 @@ func (it *VersionIterator) Value() *clickhouse.Version {
 func (it *VersionIterator) Error() error {
 	return it.err
 }
+
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *VersionIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
@@ -7,6 +7,8 @@ import (
 	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // ClusterServiceClient is a kafka.ClusterServiceClient with
 // lazy GRPC connection initialization.
 type ClusterServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements kafka.ClusterServiceClient
@@ -66,6 +68,7 @@ type ClusterIterator struct {
 	request *kafka.ListClustersRequest
 
 	items []*kafka.Cluster
+	leak  *lifecycle.Tracker
 }
 
 func (c *ClusterServiceClient) ClusterIterator(ctx context.Context, req *kafka.ListClustersRequest, opts ...grpc.CallOption) *ClusterIterator {
@@ -75,13 +78,15 @@ func (c *ClusterServiceClient) ClusterIterator(ctx context.Context, req *kafka.L
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &ClusterIterator{
+	it := &ClusterIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "kafka.ClusterIterator")
+	return it
 }
 
 func (it *ClusterIterator) Next() bool {
@@ -96,6 +101,7 @@ func (it *ClusterIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -103,11 +109,16 @@ func (it *ClusterIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Clusters
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *ClusterIterator) Take(size int64) ([]*kafka.Cluster, error) {
@@ -153,6 +164,13 @@ func (it *ClusterIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *ClusterIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // ListHosts implements kafka.ClusterServiceClient
 func (c *ClusterServiceClient) ListHosts(ctx context.Context, in *kafka.ListClusterHostsRequest, opts ...grpc.CallOption) (*kafka.ListClusterHostsResponse, error) {
 	conn, err := c.getConn(ctx)
@@ -175,6 +193,7 @@ type ClusterHostsIterator struct {
 	request *kafka.ListClusterHostsRequest
 
 	items []*kafka.Host
+	leak  *lifecycle.Tracker
 }
 
 func (c *ClusterServiceClient) ClusterHostsIterator(ctx context.Context, req *kafka.ListClusterHostsRequest, opts ...grpc.CallOption) *ClusterHostsIterator {
@@ -184,13 +203,15 @@ func (c *ClusterServiceClient) ClusterHostsIterator(ctx context.Context, req *ka
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &ClusterHostsIterator{
+	it := &ClusterHostsIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "kafka.ClusterHostsIterator")
+	return it
 }
 
 func (it *ClusterHostsIterator) Next() bool {
@@ -205,6 +226,7 @@ func (it *ClusterHostsIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -212,11 +234,16 @@ func (it *ClusterHostsIterator) Next() bool {
 	response, err := it.client.ListHosts(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Hosts
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *ClusterHostsIterator) Take(size int64) ([]*kafka.Host, error) {
@@ -262,6 +289,13 @@ func (it *ClusterHostsIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *ClusterHostsIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // ListOperations implements kafka.ClusterServiceClient
 func (c *ClusterServiceClient) ListOperations(ctx context.Context, in *kafka.ListClusterOperationsRequest, opts ...grpc.CallOption) (*kafka.ListClusterOperationsResponse, error) {
 	conn, err := c.getConn(ctx)
@@ -284,6 +318,7 @@ type ClusterOperationsIterator struct {
 	request *kafka.ListClusterOperationsRequest
 
 	items []*doublecloud.Operation
+	leak  *lifecycle.Tracker
 }
 
 func (c *ClusterServiceClient) ClusterOperationsIterator(ctx context.Context, req *kafka.ListClusterOperationsRequest, opts ...grpc.CallOption) *ClusterOperationsIterator {
@@ -293,13 +328,15 @@ func (c *ClusterServiceClient) ClusterOperationsIterator(ctx context.Context, re
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &ClusterOperationsIterator{
+	it := &ClusterOperationsIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "kafka.ClusterOperationsIterator")
+	return it
 }
 
 func (it *ClusterOperationsIterator) Next() bool {
@@ -314,6 +351,7 @@ func (it *ClusterOperationsIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -321,11 +359,16 @@ func (it *ClusterOperationsIterator) Next() bool {
 	response, err := it.client.ListOperations(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Operations
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *ClusterOperationsIterator) Take(size int64) ([]*doublecloud.Operation, error) {
@@ -371,6 +414,13 @@ func (it *ClusterOperationsIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *ClusterOperationsIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // RescheduleMaintenance implements kafka.ClusterServiceClient
 func (c *ClusterServiceClient) RescheduleMaintenance(ctx context.Context, in *kafka.RescheduleMaintenanceRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
@@ -407,11 +457,16 @@ func (c *ClusterServiceClient) Stop(ctx context.Context, in *kafka.StopClusterRe
 	return kafka.NewClusterServiceClient(conn).Stop(ctx, in, opts...)
 }
 
-// Update implements kafka.ClusterServiceClient
+// Update implements kafka.ClusterServiceClient. Updates changing immutable fields fail
+// with *specutil.ImmutableFieldError before the request is sent.
 func (c *ClusterServiceClient) Update(ctx context.Context, in *kafka.UpdateClusterRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return kafka.NewClusterServiceClient(conn).Update(ctx, in, opts...)
+	client := kafka.NewClusterServiceClient(conn)
+	if err := checkImmutable(ctx, client, in, opts); err != nil {
+		return nil, err
+	}
+	return client.Update(ctx, in, opts...)
 }
@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+type driftClusters struct {
+	kafka.UnimplementedClusterServiceServer
+	live *kafka.Cluster
+}
+
+func (s *driftClusters) Get(ctx context.Context, req *kafka.GetClusterRequest) (*kafka.Cluster, error) {
+	return s.live, nil
+}
+
+func TestDrift(t *testing.T) {
+	live := &kafka.Cluster{
+		Id:               "kfc1",
+		Name:             "events",
+		Status:           doublecloud.ClusterStatus_CLUSTER_STATUS_ALIVE,
+		PlannedOperation: &doublecloud.MaintenanceOperation{Info: "restart"},
+		Resources: &kafka.ClusterResources{Kafka: &kafka.ClusterResources_Kafka{
+			ResourcePresetId: "s1-c2-m4",
+			BrokerCount:      wrapperspb.Int64(3),
+		}},
+	}
+	k := newTestKafkaServer(t, func(s *grpc.Server) {
+		kafka.RegisterClusterServiceServer(s, &driftClusters{live: live})
+	})
+	ctx := context.Background()
+
+	report, err := k.Cluster().Drift(ctx, "kfc1", &ClusterSpec{Name: "events", Status: doublecloud.ClusterStatus_CLUSTER_STATUS_DEAD}, DriftOptions{FixDrift: true})
+	require.NoError(t, err)
+	assert.False(t, report.Drifted(), "server-managed fields are ignored")
+
+	desired := &ClusterSpec{Description: "Events", Resources: &kafka.ClusterResources{Kafka: &kafka.ClusterResources_Kafka{
+		BrokerCount: wrapperspb.Int64(5),
+	}}}
+	report, err = k.Cluster().Drift(ctx, "kfc1", desired, DriftOptions{FixDrift: true})
+	require.NoError(t, err)
+	assert.Equal(t, []specutil.FieldChange{
+		{Path: "description", Old: `""`, New: `"Events"`},
+		{Path: "resources.kafka.broker_count", Old: "3", New: "5"},
+	}, report.Drift)
+	assert.Empty(t, report.Unfixable)
+	assert.Empty(t, report.RequiresRecreate)
+	want := &kafka.UpdateClusterRequest{ClusterId: "kfc1", Description: "Events", Resources: desired.Resources}
+	assert.True(t, proto.Equal(want, report.Fix), "got %v", report.Fix)
+}
@@ -0,0 +1,328 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/paging"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// ErrMissingSecret is returned by Import for objects whose secrets are not in ImportOptions.Secrets.
+var ErrMissingSecret = errors.New("missing secret")
+
+// SecretRef refers to a secret that Export doesn't save, e.g. a user password.
+// Import takes its value from ImportOptions.Secrets.
+type SecretRef string
+
+// UserPasswordRef is the reference to the password of the user in a ClusterExport.
+func UserPasswordRef(user string) SecretRef { return SecretRef("user/" + user + "/password") }
+
+// ClusterExport is the configuration of a cluster saved by Export, to be re-applied onto another
+// cluster by Import, e.g. in disaster recovery drills. It is serializable with encoding/json.
+// Connectors are not exported, the Kafka API has none.
+type ClusterExport struct {
+	ClusterID string
+	// Cluster is the cluster without its connection info, which holds credentials.
+	Cluster *kafka.Cluster
+	Topics  []*kafka.TopicSpec
+	Users   []UserExport
+}
+
+// UserExport is an exported user.
+type UserExport struct {
+	// Spec is the user without the password.
+	Spec *kafka.UserSpec
+	// Password refers to the password of the user, see UserPasswordRef.
+	Password SecretRef
+}
+
+type clusterExportJSON struct {
+	ClusterID string            `json:"cluster_id"`
+	Cluster   json.RawMessage   `json:"cluster"`
+	Topics    []json.RawMessage `json:"topics,omitempty"`
+	Users     []userExportJSON  `json:"users,omitempty"`
+}
+
+type userExportJSON struct {
+	Spec     json.RawMessage `json:"spec"`
+	Password SecretRef       `json:"password"`
+}
+
+// MarshalJSON encodes the messages of the export with protojson.
+func (e *ClusterExport) MarshalJSON() ([]byte, error) {
+	var err error
+	v := clusterExportJSON{ClusterID: e.ClusterID}
+	if v.Cluster, err = protojson.Marshal(e.Cluster); err != nil {
+		return nil, sdkerrors.WithMessage(err, "cluster")
+	}
+	for _, t := range e.Topics {
+		b, err := protojson.Marshal(t)
+		if err != nil {
+			return nil, sdkerrors.WithMessagef(err, "topic %q", t.GetName())
+		}
+		v.Topics = append(v.Topics, b)
+	}
+	for _, u := range e.Users {
+		b, err := protojson.Marshal(u.Spec)
+		if err != nil {
+			return nil, sdkerrors.WithMessagef(err, "user %q", u.Spec.GetName())
+		}
+		v.Users = append(v.Users, userExportJSON{Spec: b, Password: u.Password})
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the export encoded by MarshalJSON.
+func (e *ClusterExport) UnmarshalJSON(data []byte) error {
+	var v clusterExportJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = ClusterExport{ClusterID: v.ClusterID, Cluster: &kafka.Cluster{}}
+	if err := protojson.Unmarshal(v.Cluster, e.Cluster); err != nil {
+		return sdkerrors.WithMessage(err, "cluster")
+	}
+	for i, b := range v.Topics {
+		t := &kafka.TopicSpec{}
+		if err := protojson.Unmarshal(b, t); err != nil {
+			return sdkerrors.WithMessagef(err, "topic %d", i)
+		}
+		e.Topics = append(e.Topics, t)
+	}
+	for i, u := range v.Users {
+		spec := &kafka.UserSpec{}
+		if err := protojson.Unmarshal(u.Spec, spec); err != nil {
+			return sdkerrors.WithMessagef(err, "user %d", i)
+		}
+		e.Users = append(e.Users, UserExport{Spec: spec, Password: u.Password})
+	}
+	return nil
+}
+
+// Export saves the configuration of the cluster, its topics and its users, see ClusterExport.
+func (c *ClusterServiceClient) Export(ctx context.Context, clusterID string, opts ...grpc.CallOption) (*ClusterExport, error) {
+	cluster, err := c.Get(ctx, &kafka.GetClusterRequest{ClusterId: clusterID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cluster = proto.Clone(cluster).(*kafka.Cluster)
+	cluster.ConnectionInfo = nil
+	cluster.PrivateConnectionInfo = nil
+	cluster.MetricsExporterConnectionInfo = nil
+	export := &ClusterExport{ClusterID: clusterID, Cluster: cluster}
+
+	topics, err := paging.New(ctx, func(ctx context.Context, p *doublecloud.Paging) ([]*kafka.Topic, *doublecloud.NextPage, error) {
+		resp, err := (&TopicServiceClient{getConn: c.getConn}).List(ctx, &kafka.ListTopicsRequest{ClusterId: clusterID, Paging: p}, opts...)
+		return resp.GetTopics(), resp.GetNextPage(), err
+	}).TakeAll()
+	if err != nil {
+		return nil, sdkerrors.WithMessage(err, "list topics")
+	}
+	for _, t := range topics {
+		export.Topics = append(export.Topics, topicSpec(t))
+	}
+
+	users, err := paging.New(ctx, func(ctx context.Context, p *doublecloud.Paging) ([]*kafka.User, *doublecloud.NextPage, error) {
+		resp, err := (&UserServiceClient{getConn: c.getConn}).List(ctx, &kafka.ListUsersRequest{ClusterId: clusterID, Paging: p}, opts...)
+		return resp.GetUsers(), resp.GetNextPage(), err
+	}).TakeAll()
+	if err != nil {
+		return nil, sdkerrors.WithMessage(err, "list users")
+	}
+	for _, u := range users {
+		export.Users = append(export.Users, UserExport{
+			Spec:     &kafka.UserSpec{Name: u.GetName(), Permissions: u.GetPermissions()},
+			Password: UserPasswordRef(u.GetName()),
+		})
+	}
+	return export, nil
+}
+
+// topicSpec returns the spec creating the topic.
+func topicSpec(t *kafka.Topic) *kafka.TopicSpec {
+	spec := &kafka.TopicSpec{Name: t.GetName(), Partitions: t.GetPartitions(), ReplicationFactor: t.GetReplicationFactor()}
+	switch config := t.GetTopicConfig().(type) {
+	case *kafka.Topic_TopicConfig_2_8:
+		spec.TopicConfig = &kafka.TopicSpec_TopicConfig_2_8{TopicConfig_2_8: config.TopicConfig_2_8}
+	case *kafka.Topic_TopicConfig_3:
+		spec.TopicConfig = &kafka.TopicSpec_TopicConfig_3{TopicConfig_3: config.TopicConfig_3}
+	}
+	return spec
+}
+
+// ImportOptions configures ClusterServiceClient.Import.
+type ImportOptions struct {
+	// SkipExisting reports topics and users that already exist in the target cluster as
+	// ImportSkipped instead of ImportFailed. Existing objects are not updated.
+	SkipExisting bool
+	// Secrets are the values of the SecretRef of the export. Users whose password is missing
+	// fail with ErrMissingSecret.
+	Secrets map[SecretRef]string
+	// SyncCluster updates the settings of the target cluster to the exported ones, see Drift.
+	// The name, description, placement and encryption of the target cluster are kept.
+	SyncCluster bool
+	// Parallelism bounds the number of topics being created at the same time, see BatchOptions.
+	Parallelism int
+}
+
+// ImportStatus is an outcome of importing a single object.
+type ImportStatus int
+
+const (
+	ImportApplied ImportStatus = iota
+	ImportUnchanged
+	ImportSkipped
+	ImportFailed
+)
+
+func (s ImportStatus) String() string {
+	switch s {
+	case ImportApplied:
+		return "applied"
+	case ImportUnchanged:
+		return "unchanged"
+	case ImportSkipped:
+		return "skipped"
+	case ImportFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ImportResult is a result of importing a single object.
+type ImportResult struct {
+	// Kind is "cluster", "topic" or "user".
+	Kind   string
+	Name   string
+	Status ImportStatus
+	// Err is set for ImportFailed and ImportSkipped results.
+	Err error
+}
+
+// ImportReport lists the results of Import: the cluster settings if synced, then the topics
+// and the users in the order of the export.
+type ImportReport struct {
+	TargetClusterID string
+	Results         []ImportResult
+}
+
+// Import re-applies the exported configuration onto the target cluster. The cluster settings
+// are synced first if requested, then the topics are created with CreateBatch and the users,
+// whose permissions refer to the topics, are created last. A failure doesn't stop the import
+// of the other objects; the returned error aggregates all failures.
+func (c *ClusterServiceClient) Import(ctx context.Context, targetClusterID string, export *ClusterExport, options ImportOptions, opts ...grpc.CallOption) (*ImportReport, error) {
+	report := &ImportReport{TargetClusterID: targetClusterID}
+	if options.SyncCluster {
+		report.Results = append(report.Results, c.syncCluster(ctx, targetClusterID, export.Cluster, opts))
+	}
+
+	topics, _ := (&TopicServiceClient{getConn: c.getConn}).CreateBatch(ctx, targetClusterID, export.Topics, BatchOptions{
+		Parallelism:            options.Parallelism,
+		ContinueOnError:        true,
+		TreatExistingAsSuccess: options.SkipExisting,
+	}, opts...)
+	for _, t := range topics {
+		res := ImportResult{Kind: "topic", Name: t.Name, Err: t.Err}
+		switch t.Status {
+		case TopicCreated:
+			res.Status = ImportApplied
+		case TopicAlreadyExisted:
+			res.Status = ImportSkipped
+		case TopicSkipped:
+			res.Status = ImportFailed
+			res.Err = ctx.Err()
+		default:
+			res.Status = ImportFailed
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	for _, u := range export.Users {
+		report.Results = append(report.Results, c.importUser(ctx, targetClusterID, u, options, opts))
+	}
+
+	var errs error
+	for _, res := range report.Results {
+		if res.Status == ImportFailed {
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(res.Err, "%s %q", res.Kind, res.Name))
+		}
+	}
+	if errs == nil && ctx.Err() != nil {
+		errs = ctx.Err()
+	}
+	return report, errs
+}
+
+func (c *ClusterServiceClient) syncCluster(ctx context.Context, clusterID string, exported *kafka.Cluster, opts []grpc.CallOption) ImportResult {
+	res := ImportResult{Kind: "cluster", Name: clusterID, Status: ImportFailed}
+	if exported == nil {
+		res.Err = errors.New("export has no cluster")
+		return res
+	}
+	desired := proto.Clone(exported).(*kafka.Cluster)
+	desired.ProjectId, desired.CloudType, desired.RegionId, desired.NetworkId = "", "", "", ""
+	desired.Name, desired.Description = "", ""
+	desired.CreateTime, desired.Encryption = nil, nil
+
+	drift, err := c.Drift(ctx, clusterID, desired, DriftOptions{FixDrift: true}, opts...)
+	switch {
+	case err != nil:
+		res.Err = err
+	case !drift.Drifted():
+		res.Status = ImportUnchanged
+	case len(drift.Unfixable) > 0:
+		res.Err = fmt.Errorf("fields can't be updated: %v", drift.Unfixable)
+	default:
+		res.Err = c.wait(ctx, opts)(c.Update(ctx, drift.Fix, opts...))
+		if res.Err == nil {
+			res.Status = ImportApplied
+		}
+	}
+	return res
+}
+
+func (c *ClusterServiceClient) importUser(ctx context.Context, clusterID string, u UserExport, options ImportOptions, opts []grpc.CallOption) ImportResult {
+	spec := proto.Clone(u.Spec).(*kafka.UserSpec)
+	res := ImportResult{Kind: "user", Name: spec.GetName(), Status: ImportFailed}
+	password, ok := options.Secrets[u.Password]
+	if !ok {
+		res.Err = fmt.Errorf("%w %q", ErrMissingSecret, u.Password)
+		return res
+	}
+	spec.Password = password
+	err := c.wait(ctx, opts)((&UserServiceClient{getConn: c.getConn}).Create(ctx, &kafka.CreateUserRequest{ClusterId: clusterID, UserSpec: spec}, opts...))
+	switch {
+	case err == nil:
+		res.Status = ImportApplied
+	case options.SkipExisting && status.Code(err) == codes.AlreadyExists:
+		res.Status = ImportSkipped
+		res.Err = err
+	default:
+		res.Err = err
+	}
+	return res
+}
+
+// wait returns a func waiting for the operation returned by a call.
+func (c *ClusterServiceClient) wait(ctx context.Context, opts []grpc.CallOption) func(*doublecloud.Operation, error) error {
+	return func(op *doublecloud.Operation, err error) error {
+		if err != nil {
+			return err
+		}
+		return operation.New(&OperationServiceClient{getConn: c.getConn}, op).Wait(ctx, opts...)
+	}
+}
@@ -0,0 +1,330 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"testing"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeCluster is an in-memory Kafka cluster of fakeKafkaService.
+type fakeCluster struct {
+	cluster   *kafka.Cluster
+	topics    map[string]*kafka.TopicSpec
+	users     map[string]*kafka.UserSpec
+	topicList []string
+}
+
+// fakeKafkaService serves clusters, topics and users from memory, returning done operations.
+// Lists return a single item per page to exercise paging.
+type fakeKafkaService struct {
+	mu       sync.Mutex
+	clusters map[string]*fakeCluster
+	updates  []*kafka.UpdateClusterRequest
+}
+
+func newFakeKafkaService(clusters ...*kafka.Cluster) *fakeKafkaService {
+	f := &fakeKafkaService{clusters: map[string]*fakeCluster{}}
+	for _, c := range clusters {
+		f.clusters[c.Id] = &fakeCluster{cluster: c, topics: map[string]*kafka.TopicSpec{}, users: map[string]*kafka.UserSpec{}}
+	}
+	return f
+}
+
+func (f *fakeKafkaService) get(id string) (*fakeCluster, error) {
+	c, ok := f.clusters[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %q not found", id)
+	}
+	return c, nil
+}
+
+func done(id string) *doublecloud.Operation {
+	return &doublecloud.Operation{Id: "kfo-" + id, Status: doublecloud.Operation_STATUS_DONE}
+}
+
+// page returns the item of the page requested with the token, one item per page.
+func page(n int, p *doublecloud.Paging) (int, *doublecloud.NextPage) {
+	i := 0
+	if t := p.GetPageToken(); t != "" {
+		i = int(t[0] - '0')
+	}
+	if i+1 < n {
+		return i, &doublecloud.NextPage{Token: string(rune('0' + i + 1))}
+	}
+	return i, nil
+}
+
+func (f *fakeKafkaService) GetCluster(ctx context.Context, req *kafka.GetClusterRequest) (*kafka.Cluster, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	return c.cluster, nil
+}
+
+func (f *fakeKafkaService) UpdateCluster(ctx context.Context, req *kafka.UpdateClusterRequest) (*doublecloud.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	f.updates = append(f.updates, req)
+	if req.Resources != nil {
+		c.cluster.Resources = req.Resources
+	}
+	return done(req.ClusterId), nil
+}
+
+func (f *fakeKafkaService) ListTopics(ctx context.Context, req *kafka.ListTopicsRequest) (*kafka.ListTopicsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.topicList) == 0 {
+		return &kafka.ListTopicsResponse{}, nil
+	}
+	i, next := page(len(c.topicList), req.Paging)
+	spec := c.topics[c.topicList[i]]
+	topic := &kafka.Topic{Name: spec.Name, ClusterId: req.ClusterId, Partitions: spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor, IsHa: true}
+	if cfg, ok := spec.TopicConfig.(*kafka.TopicSpec_TopicConfig_3); ok {
+		topic.TopicConfig = &kafka.Topic_TopicConfig_3{TopicConfig_3: cfg.TopicConfig_3}
+	}
+	return &kafka.ListTopicsResponse{Topics: []*kafka.Topic{topic}, NextPage: next}, nil
+}
+
+func (f *fakeKafkaService) CreateTopic(ctx context.Context, req *kafka.CreateTopicRequest) (*doublecloud.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	name := req.TopicSpec.GetName()
+	if _, ok := c.topics[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "topic %q exists", name)
+	}
+	c.topics[name] = req.TopicSpec
+	c.topicList = append(c.topicList, name)
+	return done(name), nil
+}
+
+func (f *fakeKafkaService) ListUsers(ctx context.Context, req *kafka.ListUsersRequest) (*kafka.ListUsersResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(c.users))
+	for name := range c.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return &kafka.ListUsersResponse{}, nil
+	}
+	i, next := page(len(names), req.Paging)
+	u := c.users[names[i]]
+	return &kafka.ListUsersResponse{Users: []*kafka.User{{Name: u.Name, ClusterId: req.ClusterId, Permissions: u.Permissions}}, NextPage: next}, nil
+}
+
+func (f *fakeKafkaService) CreateUser(ctx context.Context, req *kafka.CreateUserRequest) (*doublecloud.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.get(req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	name := req.UserSpec.GetName()
+	if _, ok := c.users[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "user %q exists", name)
+	}
+	c.users[name] = req.UserSpec
+	return done(name), nil
+}
+
+// The services share the fake, but their methods have the same names.
+type (
+	fakeClusterService struct {
+		kafka.UnimplementedClusterServiceServer
+		*fakeKafkaService
+	}
+	fakeTopicService struct {
+		kafka.UnimplementedTopicServiceServer
+		*fakeKafkaService
+	}
+	fakeUserService struct {
+		kafka.UnimplementedUserServiceServer
+		*fakeKafkaService
+	}
+)
+
+func (f fakeClusterService) Get(ctx context.Context, req *kafka.GetClusterRequest) (*kafka.Cluster, error) {
+	return f.GetCluster(ctx, req)
+}
+
+func (f fakeClusterService) Update(ctx context.Context, req *kafka.UpdateClusterRequest) (*doublecloud.Operation, error) {
+	return f.UpdateCluster(ctx, req)
+}
+
+func (f fakeTopicService) List(ctx context.Context, req *kafka.ListTopicsRequest) (*kafka.ListTopicsResponse, error) {
+	return f.ListTopics(ctx, req)
+}
+
+func (f fakeTopicService) Create(ctx context.Context, req *kafka.CreateTopicRequest) (*doublecloud.Operation, error) {
+	return f.CreateTopic(ctx, req)
+}
+
+func (f fakeUserService) List(ctx context.Context, req *kafka.ListUsersRequest) (*kafka.ListUsersResponse, error) {
+	return f.ListUsers(ctx, req)
+}
+
+func (f fakeUserService) Create(ctx context.Context, req *kafka.CreateUserRequest) (*doublecloud.Operation, error) {
+	return f.CreateUser(ctx, req)
+}
+
+func newTestExportKafka(t *testing.T, f *fakeKafkaService) *Kafka {
+	return newTestKafkaServer(t, func(s *grpc.Server) {
+		kafka.RegisterClusterServiceServer(s, fakeClusterService{fakeKafkaService: f})
+		kafka.RegisterTopicServiceServer(s, fakeTopicService{fakeKafkaService: f})
+		kafka.RegisterUserServiceServer(s, fakeUserService{fakeKafkaService: f})
+		kafka.RegisterOperationServiceServer(s, fakeOperations{})
+	})
+}
+
+func testResources(brokers int64) *kafka.ClusterResources {
+	return &kafka.ClusterResources{Kafka: &kafka.ClusterResources_Kafka{ResourcePresetId: "s1-c2-m4", BrokerCount: wrapperspb.Int64(brokers)}}
+}
+
+func sourceKafkaService() *fakeKafkaService {
+	f := newFakeKafkaService(
+		&kafka.Cluster{Id: "kfc-src", Name: "events", ProjectId: "p1", RegionId: "eu-central-1", Resources: testResources(3),
+			ConnectionInfo: &kafka.ConnectionInfo{User: "admin", Password: "secret"}},
+		&kafka.Cluster{Id: "kfc-dst", Name: "events-dr", ProjectId: "p2", RegionId: "eu-west-1", Resources: testResources(1)},
+	)
+	src := f.clusters["kfc-src"]
+	for _, spec := range []*kafka.TopicSpec{
+		{Name: "orders", Partitions: wrapperspb.Int64(6), ReplicationFactor: wrapperspb.Int64(3),
+			TopicConfig: &kafka.TopicSpec_TopicConfig_3{TopicConfig_3: &kafka.TopicConfig3{RetentionMs: wrapperspb.Int64(3600000)}}},
+		{Name: "payments", Partitions: wrapperspb.Int64(1)},
+	} {
+		src.topics[spec.Name] = spec
+		src.topicList = append(src.topicList, spec.Name)
+	}
+	src.users["app"] = &kafka.UserSpec{Name: "app", Password: "app-password",
+		Permissions: []*kafka.Permission{{TopicName: "orders", Role: kafka.Permission_ACCESS_ROLE_PRODUCER}}}
+	src.users["audit"] = &kafka.UserSpec{Name: "audit", Password: "audit-password"}
+	return f
+}
+
+func TestClusterExport_RoundTrip(t *testing.T) {
+	f := sourceKafkaService()
+	k := newTestExportKafka(t, f)
+	ctx := context.Background()
+
+	export, err := k.Cluster().Export(ctx, "kfc-src")
+	require.NoError(t, err)
+	assert.Nil(t, export.Cluster.ConnectionInfo, "credentials are not exported")
+	require.Len(t, export.Topics, 2)
+	require.Len(t, export.Users, 2)
+	for _, u := range export.Users {
+		assert.Empty(t, u.Spec.Password)
+		assert.Equal(t, UserPasswordRef(u.Spec.Name), u.Password)
+	}
+
+	b, err := json.Marshal(export)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+	assert.NotContains(t, string(b), "app-password")
+	var decoded ClusterExport
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, "kfc-src", decoded.ClusterID)
+	assert.True(t, proto.Equal(export.Cluster, decoded.Cluster))
+	assert.True(t, proto.Equal(f.clusters["kfc-src"].topics["orders"], decoded.Topics[0]), "got %v", decoded.Topics[0])
+
+	report, err := k.Cluster().Import(ctx, "kfc-dst", &decoded, ImportOptions{
+		SyncCluster: true,
+		Secrets:     map[SecretRef]string{UserPasswordRef("app"): "new-app", UserPasswordRef("audit"): "new-audit"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []ImportResult{
+		{Kind: "cluster", Name: "kfc-dst", Status: ImportApplied},
+		{Kind: "topic", Name: "orders", Status: ImportApplied},
+		{Kind: "topic", Name: "payments", Status: ImportApplied},
+		{Kind: "user", Name: "app", Status: ImportApplied},
+		{Kind: "user", Name: "audit", Status: ImportApplied},
+	}, report.Results)
+
+	dst := f.clusters["kfc-dst"]
+	assert.Equal(t, "events-dr", dst.cluster.Name)
+	assert.Equal(t, int64(3), dst.cluster.Resources.Kafka.BrokerCount.GetValue())
+	require.Len(t, f.updates, 1)
+	assert.Empty(t, f.updates[0].Name, "the identity of the target is kept")
+	for name, spec := range f.clusters["kfc-src"].topics {
+		assert.True(t, proto.Equal(spec, dst.topics[name]), name)
+	}
+	assert.Equal(t, "new-app", dst.users["app"].Password)
+	assert.True(t, proto.Equal(f.clusters["kfc-src"].users["app"].Permissions[0], dst.users["app"].Permissions[0]))
+
+	// Importing again finds everything in place.
+	report, err = k.Cluster().Import(ctx, "kfc-dst", &decoded, ImportOptions{SyncCluster: true, SkipExisting: true,
+		Secrets: map[SecretRef]string{UserPasswordRef("app"): "x", UserPasswordRef("audit"): "y"}})
+	require.NoError(t, err)
+	statuses := []ImportStatus{}
+	for _, res := range report.Results {
+		statuses = append(statuses, res.Status)
+	}
+	assert.Equal(t, []ImportStatus{ImportUnchanged, ImportSkipped, ImportSkipped, ImportSkipped, ImportSkipped}, statuses)
+	assert.Equal(t, "new-app", dst.users["app"].Password, "existing users are not updated")
+}
+
+func TestClusterExport_ImportFailures(t *testing.T) {
+	f := sourceKafkaService()
+	k := newTestExportKafka(t, f)
+	ctx := context.Background()
+	export, err := k.Cluster().Export(ctx, "kfc-src")
+	require.NoError(t, err)
+	f.clusters["kfc-dst"].topics["payments"] = &kafka.TopicSpec{Name: "payments"}
+	f.clusters["kfc-dst"].topicList = []string{"payments"}
+
+	report, err := k.Cluster().Import(ctx, "kfc-dst", export, ImportOptions{
+		Secrets: map[SecretRef]string{UserPasswordRef("app"): "new-app"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `topic "payments"`)
+	assert.Contains(t, err.Error(), `user "audit": missing secret "user/audit/password"`)
+
+	byName := map[string]ImportResult{}
+	for _, res := range report.Results {
+		byName[res.Name] = res
+	}
+	assert.Equal(t, ImportApplied, byName["orders"].Status, "failures don't stop the import")
+	assert.Equal(t, ImportFailed, byName["payments"].Status)
+	assert.Equal(t, codes.AlreadyExists, status.Code(byName["payments"].Err))
+	assert.Equal(t, ImportApplied, byName["app"].Status)
+	assert.ErrorIs(t, byName["audit"].Err, ErrMissingSecret)
+	assert.NotContains(t, f.clusters["kfc-dst"].users, "audit")
+	assert.Empty(t, f.updates, "the cluster is synced only on request")
+
+	_, err = k.Cluster().Import(ctx, "kfc-missing", export, ImportOptions{SyncCluster: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `cluster "kfc-missing"`)
+}
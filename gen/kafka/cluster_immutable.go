@@ -0,0 +1,24 @@
+package kafka
+
+import (
+	"context"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+// checkImmutable fetches the cluster if the update sets a field with immutable paths, see
+// specutil.RegisterImmutable, and returns *specutil.ImmutableFieldError if it changes one.
+func checkImmutable(ctx context.Context, client kafka.ClusterServiceClient, in *kafka.UpdateClusterRequest, opts []grpc.CallOption) error {
+	if !specutil.UpdatesImmutable("doublecloud.kafka.v1.Cluster", in) {
+		return nil
+	}
+	current, err := client.Get(ctx, &kafka.GetClusterRequest{ClusterId: in.GetClusterId()}, opts...)
+	if err != nil {
+		return sdkerrors.WithMessage(err, "get cluster to check immutable fields")
+	}
+	return specutil.CheckUpdate(current, in)
+}
@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type maintenanceClusters struct {
+	kafka.UnimplementedClusterServiceServer
+
+	planned    *doublecloud.MaintenanceOperation
+	operations []*doublecloud.Operation
+	// pageSize, if set, pages the operations.
+	pageSize    int
+	pages       int
+	rescheduled *kafka.RescheduleMaintenanceRequest
+}
+
+func (s *maintenanceClusters) Get(ctx context.Context, req *kafka.GetClusterRequest) (*kafka.Cluster, error) {
+	return &kafka.Cluster{Id: req.ClusterId, PlannedOperation: s.planned}, nil
+}
+
+func (s *maintenanceClusters) ListOperations(ctx context.Context, req *kafka.ListClusterOperationsRequest) (*kafka.ListClusterOperationsResponse, error) {
+	s.pages++
+	if s.pageSize == 0 {
+		return &kafka.ListClusterOperationsResponse{Operations: s.operations}, nil
+	}
+	offset, _ := strconv.Atoi(req.GetPaging().GetPageToken())
+	end := offset + s.pageSize
+	if end >= len(s.operations) {
+		return &kafka.ListClusterOperationsResponse{Operations: s.operations[offset:]}, nil
+	}
+	return &kafka.ListClusterOperationsResponse{Operations: s.operations[offset:end], NextPage: &doublecloud.NextPage{Token: strconv.Itoa(end)}}, nil
+}
+
+func (s *maintenanceClusters) RescheduleMaintenance(ctx context.Context, req *kafka.RescheduleMaintenanceRequest) (*doublecloud.Operation, error) {
+	s.rescheduled = req
+	return &doublecloud.Operation{Id: "kfo-reschedule", ResourceId: req.ClusterId}, nil
+}
+
+func TestMaintenanceInfo(t *testing.T) {
+	scheduled := time.Date(2023, 5, 20, 3, 0, 0, 0, time.UTC)
+	srv := &maintenanceClusters{}
+	k := newTestKafkaServer(t, func(s *grpc.Server) {
+		kafka.RegisterClusterServiceServer(s, srv)
+		kafka.RegisterOperationServiceServer(s, fakeOperations{})
+	})
+	ctx := context.Background()
+
+	info, err := k.Cluster().MaintenanceInfo(ctx, "kfc1")
+	require.NoError(t, err)
+	assert.Nil(t, info, "no maintenance planned")
+
+	srv.planned = &doublecloud.MaintenanceOperation{
+		Info:                      "Upgrade to 3.4",
+		ScheduledMaintenanceTime:  timestamppb.New(scheduled),
+		NextMaintenanceWindowTime: timestamppb.New(scheduled.Add(7 * 24 * time.Hour)),
+	}
+	srv.operations = []*doublecloud.Operation{
+		{Id: "kfo-maintenance", Status: doublecloud.Operation_STATUS_RUNNING, CreateTime: timestamppb.New(scheduled.Add(time.Minute))},
+	}
+	info, err = k.Cluster().MaintenanceInfo(ctx, "kfc1")
+	require.NoError(t, err)
+	assert.Equal(t, "Upgrade to 3.4", info.Info)
+	assert.True(t, scheduled.Equal(info.ScheduledTime))
+	assert.True(t, info.Deadline.IsZero())
+	assert.True(t, scheduled.Add(7*24*time.Hour).Equal(info.NextWindowTime))
+	require.NotNil(t, info.Operation)
+	require.NoError(t, info.Operation.Wait(ctx))
+	assert.Equal(t, "kfo-maintenance", info.Operation.Id())
+	assert.Equal(t, `maintenance "Upgrade to 3.4" is scheduled for May 20, 2023 03:00 UTC; the next maintenance window starts at May 27, 2023 03:00 UTC; it started as kfo-maintenance`, info.String())
+
+	_, err = k.Cluster().RescheduleMaintenanceUntil(ctx, "kfc1", scheduled.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, doublecloud.RescheduleType_RESCHEDULE_TYPE_SPECIFIC_TIME, srv.rescheduled.RescheduleType)
+	assert.True(t, scheduled.Add(time.Hour).Equal(srv.rescheduled.DelayedUntilTime.AsTime()))
+}
+
+func newMaintenanceKafka(t *testing.T, srv *maintenanceClusters) *Kafka {
+	return newTestKafkaServer(t, func(s *grpc.Server) {
+		kafka.RegisterClusterServiceServer(s, srv)
+		kafka.RegisterOperationServiceServer(s, fakeOperations{})
+	})
+}
+
+func TestMaintenanceInfo_Pages(t *testing.T) {
+	scheduled := time.Date(2023, 5, 20, 3, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *timestamppb.Timestamp { return timestamppb.New(scheduled.Add(d)) }
+	done, running := doublecloud.Operation_STATUS_DONE, doublecloud.Operation_STATUS_RUNNING
+	for name, tc := range map[string]struct {
+		operations []*doublecloud.Operation
+		pages      int
+	}{
+		"oldest first": {[]*doublecloud.Operation{
+			{Id: "kfo-old", Status: done, CreateTime: at(-2 * time.Hour)},
+			{Id: "kfo-update", Status: running, CreateTime: at(-time.Hour)},
+			{Id: "kfo-maintenance", Status: running, CreateTime: at(time.Minute)},
+			{Id: "kfo-scale", Status: running, CreateTime: at(time.Hour)},
+		}, 4},
+		"newest first": {[]*doublecloud.Operation{
+			{Id: "kfo-scale", Status: running, CreateTime: at(time.Hour)},
+			{Id: "kfo-maintenance", Status: running, CreateTime: at(time.Minute)},
+			{Id: "kfo-update", Status: running, CreateTime: at(-time.Hour)},
+			{Id: "kfo-old", Status: done, CreateTime: at(-2 * time.Hour)},
+		}, 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := &maintenanceClusters{
+				planned:    &doublecloud.MaintenanceOperation{Info: "Upgrade", ScheduledMaintenanceTime: timestamppb.New(scheduled)},
+				operations: tc.operations,
+				pageSize:   1,
+			}
+			info, err := newMaintenanceKafka(t, srv).Cluster().MaintenanceInfo(context.Background(), "kfc1")
+			require.NoError(t, err)
+			require.NotNil(t, info.Operation, "found on a later page")
+			assert.Equal(t, "kfo-maintenance", info.Operation.Id(), "the earliest started since the scheduled time")
+			assert.Equal(t, tc.pages, srv.pages, "no page is listed past the older operations")
+		})
+	}
+}
@@ -8,11 +8,23 @@ import (
 
 // Kafka provides access to "kafka" service of DoubleCloud
 type Kafka struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // NewKafka creates instance of Kafka
 func NewKafka(g func(ctx context.Context) (*grpc.ClientConn, error)) *Kafka {
+	return NewKafkaWithConnInterface(func(ctx context.Context) (grpc.ClientConnInterface, error) {
+		conn, err := g(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+// NewKafkaWithConnInterface creates instance of Kafka calling through
+// connections returned by g, e.g. ones wrapping SDK connections.
+func NewKafkaWithConnInterface(g func(ctx context.Context) (grpc.ClientConnInterface, error)) *Kafka {
 	return &Kafka{g}
 }
 
@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"fmt"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ResourcesSpec builds ClusterResources for create and update requests.
+// Sizes are wrapped in the proto, so a field is sent only if its setter was called:
+// an unset field keeps the service default (or the current value on update),
+// while an explicitly set zero is sent as zero and rejected by Validate.
+type ResourcesSpec struct {
+	r *kafka.ClusterResources_Kafka
+}
+
+// NewResourcesSpec starts a spec with the given resource preset.
+// An empty preset leaves the preset unchanged on update.
+func NewResourcesSpec(resourcePresetID string) *ResourcesSpec {
+	return &ResourcesSpec{r: &kafka.ClusterResources_Kafka{ResourcePresetId: resourcePresetID}}
+}
+
+// SetDiskSize sets the disk size per broker in bytes.
+func (s *ResourcesSpec) SetDiskSize(bytes int64) *ResourcesSpec {
+	s.r.DiskSize = wrapperspb.Int64(bytes)
+	return s
+}
+
+// SetBrokerCount sets the number of brokers in each availability zone.
+func (s *ResourcesSpec) SetBrokerCount(n int64) *ResourcesSpec {
+	s.r.BrokerCount = wrapperspb.Int64(n)
+	return s
+}
+
+func (s *ResourcesSpec) SetZoneCount(n int64) *ResourcesSpec {
+	s.r.ZoneCount = wrapperspb.Int64(n)
+	return s
+}
+
+// Validate rejects explicitly set non-positive sizes, which the service cannot tell apart
+// from "keep the current value".
+func (s *ResourcesSpec) Validate() error {
+	return positive("kafka resources", []int64Field{
+		{"disk_size", s.r.DiskSize},
+		{"broker_count", s.r.BrokerCount},
+		{"zone_count", s.r.ZoneCount},
+	})
+}
+
+// Build validates the spec and returns the resources proto.
+func (s *ResourcesSpec) Build() (*kafka.ClusterResources, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &kafka.ClusterResources{Kafka: s.r}, nil
+}
+
+// TopicSpecBuilder builds TopicSpec with the same presence rules as ResourcesSpec:
+// partitions and replication factor are sent only if set.
+type TopicSpecBuilder struct {
+	t *kafka.TopicSpec
+}
+
+func NewTopicSpec(name string) *TopicSpecBuilder {
+	return &TopicSpecBuilder{t: &kafka.TopicSpec{Name: name}}
+}
+
+func (b *TopicSpecBuilder) SetPartitions(n int64) *TopicSpecBuilder {
+	b.t.Partitions = wrapperspb.Int64(n)
+	return b
+}
+
+func (b *TopicSpecBuilder) SetReplicationFactor(n int64) *TopicSpecBuilder {
+	b.t.ReplicationFactor = wrapperspb.Int64(n)
+	return b
+}
+
+// Validate requires a topic name and rejects explicitly set non-positive counts.
+func (b *TopicSpecBuilder) Validate() error {
+	if b.t.Name == "" {
+		return fmt.Errorf("kafka topic: name required")
+	}
+	return positive(fmt.Sprintf("kafka topic %q", b.t.Name), []int64Field{
+		{"partitions", b.t.Partitions},
+		{"replication_factor", b.t.ReplicationFactor},
+	})
+}
+
+// Build validates the spec and returns the topic spec proto.
+func (b *TopicSpecBuilder) Build() (*kafka.TopicSpec, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b.t, nil
+}
+
+type int64Field struct {
+	name  string
+	value *wrapperspb.Int64Value
+}
+
+func positive(what string, fields []int64Field) error {
+	for _, f := range fields {
+		if f.value != nil && f.value.GetValue() <= 0 {
+			return fmt.Errorf("%s: %s must be positive if set, got %d", what, f.name, f.value.GetValue())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// Golden wire representations: an unset field is absent, an explicit zero is an empty wrapper message.
+func TestResourcesSpec_Wire(t *testing.T) {
+	for name, tc := range map[string]struct {
+		msg  proto.Message
+		wire string
+	}{
+		"unset":                   {NewResourcesSpec("s1").r, "0a027331"},
+		"disk_size zero":          {NewResourcesSpec("s1").SetDiskSize(0).r, "0a0273311200"},
+		"disk_size one":           {NewResourcesSpec("s1").SetDiskSize(1).r, "0a02733112020801"},
+		"broker_count zero":       {NewResourcesSpec("s1").SetBrokerCount(0).r, "0a0273311a00"},
+		"broker_count one":        {NewResourcesSpec("s1").SetBrokerCount(1).r, "0a0273311a020801"},
+		"zone_count zero":         {NewResourcesSpec("s1").SetZoneCount(0).r, "0a0273312200"},
+		"zone_count one":          {NewResourcesSpec("s1").SetZoneCount(1).r, "0a02733122020801"},
+		"topic unset":             {NewTopicSpec("t").t, "0a0174"},
+		"partitions zero":         {NewTopicSpec("t").SetPartitions(0).t, "0a01741200"},
+		"partitions one":          {NewTopicSpec("t").SetPartitions(1).t, "0a017412020801"},
+		"replication_factor zero": {NewTopicSpec("t").SetReplicationFactor(0).t, "0a01741a00"},
+		"replication_factor one":  {NewTopicSpec("t").SetReplicationFactor(1).t, "0a01741a020801"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(tc.msg)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wire, hex.EncodeToString(b))
+		})
+	}
+}
+
+func TestResourcesSpec_Validate(t *testing.T) {
+	_, err := NewResourcesSpec("s1").SetBrokerCount(0).Build()
+	assert.EqualError(t, err, "kafka resources: broker_count must be positive if set, got 0")
+
+	res, err := NewResourcesSpec("s1").SetBrokerCount(1).SetZoneCount(3).Build()
+	require.NoError(t, err, "broker_count is per zone")
+	assert.Equal(t, int64(3), res.GetKafka().GetZoneCount().GetValue())
+
+	_, err = NewTopicSpec("t").SetPartitions(-1).Build()
+	assert.EqualError(t, err, `kafka topic "t": partitions must be positive if set, got -1`)
+
+	_, err = NewTopicSpec("").Build()
+	assert.Error(t, err)
+
+	spec, err := NewTopicSpec("t").SetPartitions(3).Build()
+	require.NoError(t, err)
+	assert.Nil(t, spec.GetReplicationFactor())
+}
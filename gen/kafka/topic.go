@@ -7,6 +7,8 @@ import (
 	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,10 +16,12 @@ import (
 // TopicServiceClient is a kafka.TopicServiceClient with
 // lazy GRPC connection initialization.
 type TopicServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements kafka.TopicServiceClient
+//
+// The new topic is addressed by the cluster ID and name of the request, not by a resource ID.
 func (c *TopicServiceClient) Create(ctx context.Context, in *kafka.CreateTopicRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
 	if err != nil {
@@ -66,6 +70,7 @@ type TopicIterator struct {
 	request *kafka.ListTopicsRequest
 
 	items []*kafka.Topic
+	leak  *lifecycle.Tracker
 }
 
 func (c *TopicServiceClient) TopicIterator(ctx context.Context, req *kafka.ListTopicsRequest, opts ...grpc.CallOption) *TopicIterator {
@@ -75,13 +80,15 @@ func (c *TopicServiceClient) TopicIterator(ctx context.Context, req *kafka.ListT
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &TopicIterator{
+	it := &TopicIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "kafka.TopicIterator")
+	return it
 }
 
 func (it *TopicIterator) Next() bool {
@@ -96,6 +103,7 @@ func (it *TopicIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -103,11 +111,16 @@ func (it *TopicIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Topics
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *TopicIterator) Take(size int64) ([]*kafka.Topic, error) {
@@ -153,6 +166,13 @@ func (it *TopicIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *TopicIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // Update implements kafka.TopicServiceClient
 func (c *TopicServiceClient) Update(ctx context.Context, in *kafka.UpdateTopicRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
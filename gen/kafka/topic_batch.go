@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultBatchParallelism is the number of topics CreateBatch creates simultaneously by default.
+const DefaultBatchParallelism = 10
+
+// BatchOptions configures TopicServiceClient.CreateBatch.
+type BatchOptions struct {
+	// Parallelism bounds the number of topics being created at the same time.
+	// Zero means DefaultBatchParallelism.
+	Parallelism int
+	// ContinueOnError keeps creating the remaining topics after a failure.
+	// Otherwise topics that were not started yet are reported as TopicSkipped.
+	ContinueOnError bool
+	// TreatExistingAsSuccess reports topics that already exist as TopicAlreadyExisted
+	// instead of TopicFailed.
+	TreatExistingAsSuccess bool
+}
+
+// TopicBatchStatus is an outcome of a single topic creation in a batch.
+type TopicBatchStatus int
+
+const (
+	TopicCreated TopicBatchStatus = iota
+	TopicAlreadyExisted
+	TopicFailed
+	TopicSkipped
+)
+
+func (s TopicBatchStatus) String() string {
+	switch s {
+	case TopicCreated:
+		return "created"
+	case TopicAlreadyExisted:
+		return "already existed"
+	case TopicFailed:
+		return "failed"
+	case TopicSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// TopicBatchResult is a result of a single topic creation in a batch.
+type TopicBatchResult struct {
+	Name   string
+	Status TopicBatchStatus
+	// Operation is the create operation, nil if the create call itself failed or was skipped.
+	Operation *operation.Operation
+	// Err is set for TopicFailed and TopicAlreadyExisted results.
+	Err error
+}
+
+// CreateBatch creates the topics in the cluster with bounded concurrency and waits for all of them.
+// The result has one entry per spec, in the order of specs.
+// The returned error aggregates all failures; topics that already existed are not failures
+// when opts.TreatExistingAsSuccess is set.
+func (c *TopicServiceClient) CreateBatch(ctx context.Context, clusterID string, specs []*kafka.TopicSpec, opts BatchOptions, callOpts ...grpc.CallOption) ([]TopicBatchResult, error) {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultBatchParallelism
+	}
+	results := make([]TopicBatchResult, len(specs))
+	for i, spec := range specs {
+		results[i] = TopicBatchResult{Name: spec.GetName(), Status: TopicSkipped}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	sem := make(chan struct{}, parallelism)
+	for i, spec := range specs {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed && !opts.ContinueOnError
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int, spec *kafka.TopicSpec) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := c.createTopic(ctx, clusterID, spec, opts, callOpts)
+			mu.Lock()
+			results[i] = res
+			if res.Status == TopicFailed {
+				failed = true
+			}
+			mu.Unlock()
+		}(i, spec)
+	}
+	wg.Wait()
+
+	var errs error
+	for _, res := range results {
+		if res.Status == TopicFailed {
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(res.Err, "topic %q", res.Name))
+		}
+	}
+	if errs == nil && ctx.Err() != nil {
+		errs = ctx.Err()
+	}
+	return results, errs
+}
+
+func (c *TopicServiceClient) createTopic(ctx context.Context, clusterID string, spec *kafka.TopicSpec, opts BatchOptions, callOpts []grpc.CallOption) TopicBatchResult {
+	res := TopicBatchResult{Name: spec.GetName()}
+	proto, err := c.Create(ctx, &kafka.CreateTopicRequest{ClusterId: clusterID, TopicSpec: spec}, callOpts...)
+	if err == nil {
+		res.Operation = operation.New(&OperationServiceClient{getConn: c.getConn}, proto)
+		err = res.Operation.Wait(ctx, callOpts...)
+	}
+	switch {
+	case err == nil:
+		res.Status = TopicCreated
+	case opts.TreatExistingAsSuccess && status.Code(err) == codes.AlreadyExists:
+		res.Status = TopicAlreadyExisted
+		res.Err = err
+	default:
+		res.Status = TopicFailed
+		res.Err = err
+	}
+	return res
+}
@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeTopics fails topics by name: "exists" is rejected by Create, "late-exists" and "bad"
+// fail in the operation, everything else is created after one poll.
+type fakeTopics struct {
+	kafka.UnimplementedTopicServiceServer
+
+	mu      sync.Mutex
+	created []string
+}
+
+func (f *fakeTopics) Create(ctx context.Context, req *kafka.CreateTopicRequest) (*doublecloud.Operation, error) {
+	name := req.GetTopicSpec().GetName()
+	f.mu.Lock()
+	f.created = append(f.created, name)
+	f.mu.Unlock()
+	switch name {
+	case "exists":
+		return nil, status.Error(codes.AlreadyExists, "topic exists")
+	case "late-exists":
+		return &doublecloud.Operation{Id: "kfo-" + name, Status: doublecloud.Operation_STATUS_DONE,
+			Error: &rpcstatus.Status{Code: int32(code.Code_ALREADY_EXISTS), Message: "topic exists"}}, nil
+	case "bad":
+		return &doublecloud.Operation{Id: "kfo-" + name, Status: doublecloud.Operation_STATUS_DONE,
+			Error: &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"}}, nil
+	}
+	return &doublecloud.Operation{Id: "kfo-" + name, Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+type fakeOperations struct {
+	kafka.UnimplementedOperationServiceServer
+}
+
+func (fakeOperations) Get(ctx context.Context, req *kafka.GetOperationRequest) (*doublecloud.Operation, error) {
+	return &doublecloud.Operation{Id: req.OperationId, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+func newTestKafka(t *testing.T, f *fakeTopics) *Kafka {
+	return newTestKafkaServer(t, func(s *grpc.Server) {
+		kafka.RegisterTopicServiceServer(s, f)
+		kafka.RegisterOperationServiceServer(s, fakeOperations{})
+	})
+}
+
+func newTestKafkaServer(t *testing.T, register func(s *grpc.Server)) *Kafka {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewKafka(func(ctx context.Context) (*grpc.ClientConn, error) { return conn, nil })
+}
+
+func topicSpecs(names ...string) []*kafka.TopicSpec {
+	specs := make([]*kafka.TopicSpec, len(names))
+	for i, n := range names {
+		specs[i] = &kafka.TopicSpec{Name: n}
+	}
+	return specs
+}
+
+func TestCreateBatch_MixedOutcomes(t *testing.T) {
+	k := newTestKafka(t, &fakeTopics{})
+	results, err := k.Topic().CreateBatch(context.Background(), "cluster",
+		topicSpecs("a", "exists", "b", "late-exists", "bad"),
+		BatchOptions{Parallelism: 2, ContinueOnError: true, TreatExistingAsSuccess: true})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `topic "bad"`)
+	assert.NotContains(t, err.Error(), "exists")
+
+	statuses := map[string]TopicBatchStatus{}
+	for _, r := range results {
+		statuses[r.Name] = r.Status
+	}
+	assert.Equal(t, map[string]TopicBatchStatus{
+		"a":           TopicCreated,
+		"exists":      TopicAlreadyExisted,
+		"b":           TopicCreated,
+		"late-exists": TopicAlreadyExisted,
+		"bad":         TopicFailed,
+	}, statuses)
+	assert.Equal(t, "b", results[2].Name)
+	assert.True(t, results[0].Operation.Ok())
+}
+
+func TestCreateBatch_ExistingIsFailureByDefault(t *testing.T) {
+	k := newTestKafka(t, &fakeTopics{})
+	results, err := k.Topic().CreateBatch(context.Background(), "cluster", topicSpecs("exists"),
+		BatchOptions{ContinueOnError: true})
+	require.Error(t, err)
+	assert.Equal(t, TopicFailed, results[0].Status)
+	assert.Equal(t, codes.AlreadyExists, status.Code(results[0].Err))
+}
+
+func TestCreateBatch_StopOnError(t *testing.T) {
+	f := &fakeTopics{}
+	k := newTestKafka(t, f)
+	results, err := k.Topic().CreateBatch(context.Background(), "cluster", topicSpecs("bad", "a", "b"),
+		BatchOptions{Parallelism: 1})
+	require.Error(t, err)
+	assert.Equal(t, TopicFailed, results[0].Status)
+	assert.Equal(t, TopicSkipped, results[1].Status)
+	assert.Equal(t, TopicSkipped, results[2].Status)
+	assert.Equal(t, []string{"bad"}, f.created)
+}
@@ -7,6 +7,8 @@ import (
 	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,10 +16,12 @@ import (
 // UserServiceClient is a kafka.UserServiceClient with
 // lazy GRPC connection initialization.
 type UserServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements kafka.UserServiceClient
+//
+// The new user is addressed by the cluster ID and name of the request, not by a resource ID.
 func (c *UserServiceClient) Create(ctx context.Context, in *kafka.CreateUserRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
 	if err != nil {
@@ -75,6 +79,7 @@ type UserIterator struct {
 	request *kafka.ListUsersRequest
 
 	items []*kafka.User
+	leak  *lifecycle.Tracker
 }
 
 func (c *UserServiceClient) UserIterator(ctx context.Context, req *kafka.ListUsersRequest, opts ...grpc.CallOption) *UserIterator {
@@ -84,13 +89,15 @@ func (c *UserServiceClient) UserIterator(ctx context.Context, req *kafka.ListUse
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &UserIterator{
+	it := &UserIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "kafka.UserIterator")
+	return it
 }
 
 func (it *UserIterator) Next() bool {
@@ -105,6 +112,7 @@ func (it *UserIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -112,11 +120,16 @@ func (it *UserIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Users
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *UserIterator) Take(size int64) ([]*kafka.User, error) {
@@ -162,6 +175,13 @@ func (it *UserIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *UserIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // RevokePermission implements kafka.UserServiceClient
 func (c *UserServiceClient) RevokePermission(ctx context.Context, in *kafka.RevokeUserPermissionRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
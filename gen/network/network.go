@@ -7,6 +7,8 @@ import (
 	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // NetworkServiceClient is a network.NetworkServiceClient with
 // lazy GRPC connection initialization.
 type NetworkServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements network.NetworkServiceClient
@@ -75,6 +77,7 @@ type NetworkIterator struct {
 	request *network.ListNetworksRequest
 
 	items []*network.Network
+	leak  *lifecycle.Tracker
 }
 
 func (c *NetworkServiceClient) NetworkIterator(ctx context.Context, req *network.ListNetworksRequest, opts ...grpc.CallOption) *NetworkIterator {
@@ -84,13 +87,15 @@ func (c *NetworkServiceClient) NetworkIterator(ctx context.Context, req *network
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &NetworkIterator{
+	it := &NetworkIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "network.NetworkIterator")
+	return it
 }
 
 func (it *NetworkIterator) Next() bool {
@@ -105,6 +110,7 @@ func (it *NetworkIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -112,11 +118,16 @@ func (it *NetworkIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Networks
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *NetworkIterator) Take(size int64) ([]*network.Network, error) {
@@ -161,3 +172,10 @@ func (it *NetworkIterator) Value() *network.Network {
 func (it *NetworkIterator) Error() error {
 	return it.err
 }
+
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *NetworkIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
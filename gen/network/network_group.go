@@ -8,11 +8,23 @@ import (
 
 // Network provides access to "network" service of DoubleCloud
 type Network struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // NewNetwork creates instance of Network
 func NewNetwork(g func(ctx context.Context) (*grpc.ClientConn, error)) *Network {
+	return NewNetworkWithConnInterface(func(ctx context.Context) (grpc.ClientConnInterface, error) {
+		conn, err := g(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+// NewNetworkWithConnInterface creates instance of Network calling through
+// connections returned by g, e.g. ones wrapping SDK connections.
+func NewNetworkWithConnInterface(g func(ctx context.Context) (grpc.ClientConnInterface, error)) *Network {
 	return &Network{g}
 }
 
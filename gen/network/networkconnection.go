@@ -7,6 +7,8 @@ import (
 	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // NetworkConnectionServiceClient is a network.NetworkConnectionServiceClient with
 // lazy GRPC connection initialization.
 type NetworkConnectionServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements network.NetworkConnectionServiceClient
@@ -66,6 +68,7 @@ type NetworkConnectionIterator struct {
 	request *network.ListNetworkConnectionsRequest
 
 	items []*network.NetworkConnection
+	leak  *lifecycle.Tracker
 }
 
 func (c *NetworkConnectionServiceClient) NetworkConnectionIterator(ctx context.Context, req *network.ListNetworkConnectionsRequest, opts ...grpc.CallOption) *NetworkConnectionIterator {
@@ -75,13 +78,15 @@ func (c *NetworkConnectionServiceClient) NetworkConnectionIterator(ctx context.C
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &NetworkConnectionIterator{
+	it := &NetworkConnectionIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "network.NetworkConnectionIterator")
+	return it
 }
 
 func (it *NetworkConnectionIterator) Next() bool {
@@ -96,6 +101,7 @@ func (it *NetworkConnectionIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -103,11 +109,16 @@ func (it *NetworkConnectionIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.NetworkConnections
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *NetworkConnectionIterator) Take(size int64) ([]*network.NetworkConnection, error) {
@@ -152,3 +163,10 @@ func (it *NetworkConnectionIterator) Value() *network.NetworkConnection {
 func (it *NetworkConnectionIterator) Error() error {
 	return it.err
 }
+
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *NetworkConnectionIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
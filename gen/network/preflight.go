@@ -0,0 +1,132 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrPreflightFailed is matched by errors.Is for every *PreflightError.
+var ErrPreflightFailed = errors.New("network: preflight failed")
+
+// Requirements describe what a resource placed into a network needs from it.
+type Requirements struct {
+	// NeededIPs is the number of addresses the resource takes. Zero skips the check.
+	NeededIPs int
+	// Region the resource is placed in. Empty skips the check.
+	Region string
+}
+
+// PreflightFindingKind is a kind of problem found by Preflight.
+type PreflightFindingKind int
+
+const (
+	NetworkNotFound PreflightFindingKind = iota + 1
+	NetworkNotActive
+	RegionMismatch
+	InsufficientAddresses
+)
+
+func (k PreflightFindingKind) String() string {
+	switch k {
+	case NetworkNotFound:
+		return "network not found"
+	case NetworkNotActive:
+		return "network not active"
+	case RegionMismatch:
+		return "region mismatch"
+	case InsufficientAddresses:
+		return "insufficient addresses"
+	default:
+		return "unknown"
+	}
+}
+
+type PreflightFinding struct {
+	Kind    PreflightFindingKind
+	Message string
+}
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	NetworkID string
+	// Network is the checked network, nil if it was not found.
+	Network  *network.Network
+	Findings []PreflightFinding
+}
+
+// OK reports whether the network satisfies the requirements.
+func (r *PreflightReport) OK() bool { return len(r.Findings) == 0 }
+
+// Err returns *PreflightError if there are findings and nil otherwise.
+func (r *PreflightReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return &PreflightError{Report: r}
+}
+
+// PreflightError reports findings of a failed preflight check.
+// It converts to a FailedPrecondition gRPC status.
+type PreflightError struct {
+	Report *PreflightReport
+}
+
+func (e *PreflightError) Error() string {
+	msgs := make([]string, len(e.Report.Findings))
+	for i, f := range e.Report.Findings {
+		msgs[i] = f.Message
+	}
+	return fmt.Sprintf("network %s preflight failed: %s", e.Report.NetworkID, strings.Join(msgs, "; "))
+}
+
+func (e *PreflightError) Is(target error) bool { return target == ErrPreflightFailed }
+
+func (e *PreflightError) GRPCStatus() *status.Status {
+	return status.New(codes.FailedPrecondition, e.Error())
+}
+
+// Preflight checks that the network exists, is active, is in the required region and its
+// IPv4 block is large enough for the required number of addresses.
+// The API does not expose address usage, so only the size of the block is checked.
+// Problems are reported as findings; the error is returned only if the check itself failed.
+func (n *Network) Preflight(ctx context.Context, networkID string, req Requirements, opts ...grpc.CallOption) (*PreflightReport, error) {
+	report := &PreflightReport{NetworkID: networkID}
+	nw, err := n.Network().Get(ctx, &network.GetNetworkRequest{NetworkId: networkID}, opts...)
+	if status.Code(err) == codes.NotFound {
+		report.add(NetworkNotFound, "network %s not found", networkID)
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Network = nw
+
+	if nw.GetStatus() != network.Network_NETWORK_STATUS_ACTIVE {
+		report.add(NetworkNotActive, "network status is %s", nw.GetStatus())
+	}
+	if req.Region != "" && nw.GetRegionId() != req.Region {
+		report.add(RegionMismatch, "network is in region %q, not %q", nw.GetRegionId(), req.Region)
+	}
+	if req.NeededIPs > 0 && nw.GetIpv4CidrBlock() != "" {
+		prefix, err := netip.ParsePrefix(nw.GetIpv4CidrBlock())
+		if err != nil {
+			return nil, fmt.Errorf("network %s: parse ipv4 cidr block: %w", networkID, err)
+		}
+		if bits := prefix.Addr().BitLen() - prefix.Bits(); bits < 31 && 1<<bits < req.NeededIPs {
+			report.add(InsufficientAddresses, "ipv4 block %s has %d addresses, %d needed", prefix, 1<<bits, req.NeededIPs)
+		}
+	}
+	return report, nil
+}
+
+func (r *PreflightReport) add(kind PreflightFindingKind, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, PreflightFinding{Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
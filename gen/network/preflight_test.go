@@ -0,0 +1,99 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeNetworks struct {
+	network.UnimplementedNetworkServiceServer
+	networks map[string]*network.Network
+}
+
+func (f *fakeNetworks) Get(ctx context.Context, req *network.GetNetworkRequest) (*network.Network, error) {
+	if req.NetworkId == "broken" {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	nw, ok := f.networks[req.NetworkId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return nw, nil
+}
+
+func newTestNetwork(t *testing.T, networks ...*network.Network) *Network {
+	f := &fakeNetworks{networks: map[string]*network.Network{}}
+	for _, nw := range networks {
+		f.networks[nw.Id] = nw
+	}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	network.RegisterNetworkServiceServer(srv, f)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewNetwork(func(ctx context.Context) (*grpc.ClientConn, error) { return conn, nil })
+}
+
+func findingKinds(r *PreflightReport) []PreflightFindingKind {
+	var kinds []PreflightFindingKind
+	for _, f := range r.Findings {
+		kinds = append(kinds, f.Kind)
+	}
+	return kinds
+}
+
+func TestPreflight(t *testing.T) {
+	n := newTestNetwork(t,
+		&network.Network{Id: "ok", RegionId: "eu-central-1", Ipv4CidrBlock: "10.0.0.0/24", Status: network.Network_NETWORK_STATUS_ACTIVE},
+		&network.Network{Id: "creating", RegionId: "eu-central-1", Ipv4CidrBlock: "10.0.0.0/24", Status: network.Network_NETWORK_STATUS_CREATING},
+		&network.Network{Id: "small", RegionId: "eu-central-1", Ipv4CidrBlock: "10.0.0.0/30", Status: network.Network_NETWORK_STATUS_ACTIVE},
+	)
+	for name, tc := range map[string]struct {
+		networkID string
+		req       Requirements
+		want      []PreflightFindingKind
+	}{
+		"ok":                     {"ok", Requirements{NeededIPs: 3, Region: "eu-central-1"}, nil},
+		"not found":              {"missing", Requirements{}, []PreflightFindingKind{NetworkNotFound}},
+		"not active":             {"creating", Requirements{}, []PreflightFindingKind{NetworkNotActive}},
+		"region mismatch":        {"ok", Requirements{Region: "us-east-1"}, []PreflightFindingKind{RegionMismatch}},
+		"insufficient addresses": {"small", Requirements{NeededIPs: 6}, []PreflightFindingKind{InsufficientAddresses}},
+		"several":                {"creating", Requirements{Region: "us-east-1"}, []PreflightFindingKind{NetworkNotActive, RegionMismatch}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			report, err := n.Preflight(context.Background(), tc.networkID, tc.req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, findingKinds(report))
+			assert.Equal(t, tc.want == nil, report.OK())
+			if tc.want == nil {
+				assert.NoError(t, report.Err())
+				return
+			}
+			err = report.Err()
+			assert.True(t, errors.Is(err, ErrPreflightFailed))
+			assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		})
+	}
+}
+
+func TestPreflight_CheckFailure(t *testing.T) {
+	_, err := newTestNetwork(t).Preflight(context.Background(), "broken", Requirements{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,119 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/budget"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// RollbackTimeout is the least time given to the rollback of CreateCrossProject.
+const RollbackTimeout = budget.DefaultCleanupTimeout
+
+// CrossProjectResult holds the operations made by CreateCrossProject.
+// An operation is nil if the corresponding create call was not made or failed before returning one.
+type CrossProjectResult struct {
+	Source   *operation.Operation
+	Target   *operation.Operation
+	Transfer *operation.Operation
+}
+
+// CreateCrossProject creates the source endpoint, the target endpoint and a transfer between them.
+// The endpoints and the transfer may live in different projects, so each request must carry
+// its own ProjectId; nothing is substituted. SourceId and TargetId of the transfer request
+// are filled from the created endpoints.
+//
+// Each step waits for its operation. If a step fails, the endpoints created so far are deleted,
+// including the one of a create whose wait failed before its operation finished, and the
+// returned error includes any rollback failure. The rollback is made even if ctx is
+// done: it gets at least RollbackTimeout on a context not canceled with ctx, see
+// budget.Cleanup.
+func (t *Transfer) CreateCrossProject(ctx context.Context, src, dst *transfer.CreateEndpointRequest, spec *transfer.CreateTransferRequest, opts ...grpc.CallOption) (*CrossProjectResult, error) {
+	switch {
+	case src.GetProjectId() == "":
+		return nil, fmt.Errorf("source endpoint project id required")
+	case dst.GetProjectId() == "":
+		return nil, fmt.Errorf("target endpoint project id required")
+	case spec.GetProjectId() == "":
+		return nil, fmt.Errorf("transfer project id required")
+	}
+	res := &CrossProjectResult{}
+
+	var err error
+	res.Source, err = t.createEndpoint(ctx, src, opts)
+	if err != nil {
+		return res, t.rollback(ctx, sdkerrors.WithMessage(err, "create source endpoint"), opts, res.Source)
+	}
+
+	res.Target, err = t.createEndpoint(ctx, dst, opts)
+	if err != nil {
+		return res, t.rollback(ctx, sdkerrors.WithMessage(err, "create target endpoint"), opts, res.Target, res.Source)
+	}
+
+	spec = proto.Clone(spec).(*transfer.CreateTransferRequest)
+	spec.SourceId = res.Source.ResourceId()
+	spec.TargetId = res.Target.ResourceId()
+	p, err := t.Transfer().Create(ctx, spec, opts...)
+	if err == nil {
+		res.Transfer = operation.New(t.Operation(), p)
+		err = res.Transfer.Wait(ctx, opts...)
+	}
+	if err != nil {
+		return res, t.rollback(ctx, sdkerrors.WithMessage(err, "create transfer"), opts, res.Target, res.Source)
+	}
+	return res, nil
+}
+
+// createEndpoint creates an endpoint and waits for it. The operation is returned
+// even if it failed, so callers can inspect it.
+func (t *Transfer) createEndpoint(ctx context.Context, req *transfer.CreateEndpointRequest, opts []grpc.CallOption) (*operation.Operation, error) {
+	p, err := t.Endpoint().Create(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	op := operation.New(t.Operation(), p)
+	return op, op.Wait(ctx, opts...)
+}
+
+// rollback deletes the endpoints created by the given operations, nil for the creates not
+// made. The creates still running are waited for first, and the endpoints of the failed ones
+// are left as is. It returns cause as is if the rollback succeeded, and together with the
+// deletion failures otherwise.
+func (t *Transfer) rollback(ctx context.Context, cause error, opts []grpc.CallOption, created ...*operation.Operation) error {
+	ctx, cancel := budget.Cleanup(ctx, RollbackTimeout)
+	defer cancel()
+
+	var errs error
+	for _, op := range created {
+		if op == nil {
+			continue
+		}
+		id := op.ResourceId()
+		if err := op.Wait(ctx, opts...); err != nil && !op.Done() {
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(err, "rollback: wait for endpoint %s", id))
+			continue
+		}
+		if op.Failed() {
+			continue
+		}
+		id = op.ResourceId()
+		p, err := t.Endpoint().Delete(ctx, &transfer.DeleteEndpointRequest{EndpointId: id}, opts...)
+		if err == nil {
+			err = operation.New(t.Operation(), p).Wait(ctx, opts...)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(err, "rollback: delete endpoint %s", id))
+		}
+	}
+	if errs != nil {
+		return multierror.Append(cause, errs)
+	}
+	return cause
+}
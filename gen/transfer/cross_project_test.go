@@ -0,0 +1,217 @@
+package transfer
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeEndpoints creates endpoints with the id "e-<name>". An endpoint named "bad" fails
+// in the operation, "rejected" is rejected by Create, and the first poll of the operation of
+// "lost" fails, see fakeOperations.
+type fakeEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+
+	mu       sync.Mutex
+	projects map[string]string
+	deleted  []string
+}
+
+func (f *fakeEndpoints) Create(ctx context.Context, req *transfer.CreateEndpointRequest) (*doublecloud.Operation, error) {
+	if req.Name == "rejected" {
+		return nil, status.Error(codes.InvalidArgument, "rejected")
+	}
+	f.mu.Lock()
+	f.projects["e-"+req.Name] = req.ProjectId
+	f.mu.Unlock()
+	op := &doublecloud.Operation{Id: "dte-" + req.Name, ResourceId: "e-" + req.Name, Status: doublecloud.Operation_STATUS_PENDING}
+	if req.Name == "bad" {
+		op.Id = "dte-bad"
+	}
+	return op, nil
+}
+
+func (f *fakeEndpoints) Delete(ctx context.Context, req *transfer.DeleteEndpointRequest) (*doublecloud.Operation, error) {
+	f.mu.Lock()
+	f.deleted = append(f.deleted, req.EndpointId)
+	f.mu.Unlock()
+	return &doublecloud.Operation{Id: "dte-delete-" + req.EndpointId, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+type fakeTransfers struct {
+	transfer.UnimplementedTransferServiceServer
+
+	fail bool
+	// stall makes Create block until the call is done.
+	stall bool
+	req   *transfer.CreateTransferRequest
+}
+
+func (f *fakeTransfers) Create(ctx context.Context, req *transfer.CreateTransferRequest) (*doublecloud.Operation, error) {
+	f.req = req
+	if f.stall {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	id := "dtj-ok"
+	if f.fail {
+		id = "dtj-bad"
+	}
+	return &doublecloud.Operation{Id: id, ResourceId: "t1", Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+type fakeOperations struct {
+	transfer.UnimplementedOperationServiceServer
+
+	mu   sync.Mutex
+	lost bool
+}
+
+func (f *fakeOperations) Get(ctx context.Context, req *transfer.GetOperationRequest) (*doublecloud.Operation, error) {
+	if req.OperationId == "dte-lost" {
+		f.mu.Lock()
+		first := !f.lost
+		f.lost = true
+		f.mu.Unlock()
+		if first {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		}
+	}
+	op := &doublecloud.Operation{Id: req.OperationId, Status: doublecloud.Operation_STATUS_DONE}
+	if strings.HasPrefix(req.OperationId, "dte-") {
+		op.ResourceId = "e-" + strings.TrimPrefix(req.OperationId, "dte-")
+	}
+	if req.OperationId == "dte-bad" || req.OperationId == "dtj-bad" {
+		op.Error = &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"}
+	}
+	return op, nil
+}
+
+func newTestTransfer(t *testing.T, e *fakeEndpoints, tr *fakeTransfers) *Transfer {
+	return newTestTransferWith(t, func(srv *grpc.Server) {
+		transfer.RegisterEndpointServiceServer(srv, e)
+		transfer.RegisterTransferServiceServer(srv, tr)
+		transfer.RegisterOperationServiceServer(srv, &fakeOperations{})
+	})
+}
+
+func newTestTransferWith(t *testing.T, register func(srv *grpc.Server)) *Transfer {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewTransfer(func(ctx context.Context) (*grpc.ClientConn, error) { return conn, nil })
+}
+
+func endpointReq(project, name string) *transfer.CreateEndpointRequest {
+	return &transfer.CreateEndpointRequest{ProjectId: project, Name: name}
+}
+
+func TestCreateCrossProject(t *testing.T) {
+	e := &fakeEndpoints{projects: map[string]string{}}
+	tr := &fakeTransfers{}
+	res, err := newTestTransfer(t, e, tr).CreateCrossProject(context.Background(),
+		endpointReq("project-a", "src"), endpointReq("project-b", "dst"),
+		&transfer.CreateTransferRequest{ProjectId: "project-b", Name: "t"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"e-src": "project-a", "e-dst": "project-b"}, e.projects)
+	assert.Equal(t, "e-src", tr.req.SourceId)
+	assert.Equal(t, "e-dst", tr.req.TargetId)
+	assert.Equal(t, "project-b", tr.req.ProjectId)
+	assert.True(t, res.Source.Ok())
+	assert.True(t, res.Target.Ok())
+	assert.True(t, res.Transfer.Ok())
+	assert.Empty(t, e.deleted)
+}
+
+func TestCreateCrossProject_RollbackSourceOnTargetFailure(t *testing.T) {
+	for _, name := range []string{"bad", "rejected"} {
+		t.Run(name, func(t *testing.T) {
+			e := &fakeEndpoints{projects: map[string]string{}}
+			tr := &fakeTransfers{}
+			res, err := newTestTransfer(t, e, tr).CreateCrossProject(context.Background(),
+				endpointReq("project-a", "src"), endpointReq("project-b", name),
+				&transfer.CreateTransferRequest{ProjectId: "project-b"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "create target endpoint")
+			assert.Equal(t, []string{"e-src"}, e.deleted)
+			assert.Nil(t, res.Transfer)
+			assert.Nil(t, tr.req)
+		})
+	}
+}
+
+func TestCreateCrossProject_RollbackOnWaitFailure(t *testing.T) {
+	for name, tc := range map[string]struct {
+		src, dst string
+		deleted  []string
+	}{
+		"source": {"lost", "dst", []string{"e-lost"}},
+		"target": {"src", "lost", []string{"e-lost", "e-src"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := &fakeEndpoints{projects: map[string]string{}}
+			_, err := newTestTransfer(t, e, &fakeTransfers{}).CreateCrossProject(context.Background(),
+				endpointReq("project-a", tc.src), endpointReq("project-b", tc.dst),
+				&transfer.CreateTransferRequest{ProjectId: "project-b"})
+			require.Error(t, err)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+			assert.Equal(t, tc.deleted, e.deleted, "the creates are waited for and rolled back")
+		})
+	}
+}
+
+func TestCreateCrossProject_RollbackBothOnTransferFailure(t *testing.T) {
+	e := &fakeEndpoints{projects: map[string]string{}}
+	res, err := newTestTransfer(t, e, &fakeTransfers{fail: true}).CreateCrossProject(context.Background(),
+		endpointReq("project-a", "src"), endpointReq("project-b", "dst"),
+		&transfer.CreateTransferRequest{ProjectId: "project-b"})
+	require.Error(t, err)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, []string{"e-dst", "e-src"}, e.deleted)
+	assert.True(t, res.Transfer.Failed())
+}
+
+func TestCreateCrossProject_RollbackAfterDeadline(t *testing.T) {
+	e := &fakeEndpoints{projects: map[string]string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := newTestTransfer(t, e, &fakeTransfers{stall: true}).CreateCrossProject(ctx,
+		endpointReq("project-a", "src"), endpointReq("project-b", "dst"),
+		&transfer.CreateTransferRequest{ProjectId: "project-b"})
+	require.Error(t, err)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, []string{"e-dst", "e-src"}, e.deleted, "the rollback has its own budget")
+}
+
+func TestCreateCrossProject_RequiresProjects(t *testing.T) {
+	e := &fakeEndpoints{projects: map[string]string{}}
+	_, err := newTestTransfer(t, e, &fakeTransfers{}).CreateCrossProject(context.Background(),
+		endpointReq("project-a", "src"), endpointReq("", "dst"),
+		&transfer.CreateTransferRequest{ProjectId: "project-b"})
+	assert.EqualError(t, err, "target endpoint project id required")
+	assert.Empty(t, e.projects)
+}
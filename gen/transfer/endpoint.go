@@ -7,6 +7,8 @@ import (
 	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // EndpointServiceClient is a transfer.EndpointServiceClient with
 // lazy GRPC connection initialization.
 type EndpointServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Create implements transfer.EndpointServiceClient
@@ -66,6 +68,7 @@ type EndpointIterator struct {
 	request *transfer.ListEndpointsRequest
 
 	items []*transfer.Endpoint
+	leak  *lifecycle.Tracker
 }
 
 func (c *EndpointServiceClient) EndpointIterator(ctx context.Context, req *transfer.ListEndpointsRequest, opts ...grpc.CallOption) *EndpointIterator {
@@ -75,13 +78,15 @@ func (c *EndpointServiceClient) EndpointIterator(ctx context.Context, req *trans
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &EndpointIterator{
+	it := &EndpointIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "transfer.EndpointIterator")
+	return it
 }
 
 func (it *EndpointIterator) Next() bool {
@@ -96,6 +101,7 @@ func (it *EndpointIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -103,11 +109,16 @@ func (it *EndpointIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Endpoints
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *EndpointIterator) Take(size int64) ([]*transfer.Endpoint, error) {
@@ -153,6 +164,13 @@ func (it *EndpointIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *EndpointIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // Update implements transfer.EndpointServiceClient
 func (c *EndpointServiceClient) Update(ctx context.Context, in *transfer.UpdateEndpointRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
@@ -0,0 +1,114 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultMaxSampleSize bounds the samples set by UploadSample: the sample is sent inline
+// in the endpoint settings, and the update must fit into a single gRPC message.
+const DefaultMaxSampleSize = 1 << 20
+
+// sampleChunkSize is the size of the reads of UploadSample between progress reports.
+const sampleChunkSize = 32 << 10
+
+// ErrSampleTooLarge is matched by errors.Is for every *SampleTooLargeError.
+var ErrSampleTooLarge = errors.New("transfer: sample too large")
+
+// SampleTooLargeError is returned by UploadSample for samples over the maximum size.
+// The sample is not read past the maximum.
+type SampleTooLargeError struct {
+	EndpointID string
+	MaxSize    int64
+}
+
+func (e *SampleTooLargeError) Error() string {
+	return fmt.Sprintf("transfer: sample for endpoint %s exceeds %d bytes, upload a smaller sample file", e.EndpointID, e.MaxSize)
+}
+
+func (e *SampleTooLargeError) Is(target error) bool { return target == ErrSampleTooLarge }
+
+// SampleOptions configure UploadSample.
+type SampleOptions struct {
+	// MaxSize bounds the sample size in bytes. Defaults to DefaultMaxSampleSize.
+	MaxSize int64
+	// Progress, if set, is called with the number of bytes read so far.
+	Progress func(read int64)
+}
+
+// UploadSample sets the schema of the S3 source endpoint, used for schema inference, to the
+// sample read from r. The endpoint API has no upload call and takes the sample inline in the
+// S3 source settings, so r is read whole, up to options.MaxSize, before the endpoint is
+// updated; the sample must be UTF-8 text. Reading stops when ctx is done.
+// It returns the operation of the endpoint update.
+func (c *EndpointServiceClient) UploadSample(ctx context.Context, endpointID string, r io.Reader, options SampleOptions, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
+	sample, err := readSample(ctx, endpointID, r, options)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := c.Get(ctx, &transfer.GetEndpointRequest{EndpointId: endpointID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	settings := proto.Clone(endpoint.GetSettings()).(*transfer.EndpointSettings)
+	s3 := settings.GetS3Source()
+	if s3 == nil {
+		return nil, fmt.Errorf("transfer: endpoint %s is not an S3 source, samples are not supported", endpointID)
+	}
+	s3.Schema = sample
+	return c.Update(ctx, &transfer.UpdateEndpointRequest{
+		EndpointId:  endpointID,
+		Name:        endpoint.GetName(),
+		Description: endpoint.GetDescription(),
+		Labels:      endpoint.GetLabels(),
+		Settings:    settings,
+	}, opts...)
+}
+
+func readSample(ctx context.Context, endpointID string, r io.Reader, options SampleOptions) (string, error) {
+	maxSize := options.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSampleSize
+	}
+	var buf bytes.Buffer
+	// Reading one byte over the maximum tells a sample of exactly the maximum size from a larger one.
+	lr := io.LimitReader(r, maxSize+1)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", sdkerrors.WithMessagef(err, "transfer: sample for endpoint %s", endpointID)
+		}
+		n, err := io.CopyN(&buf, lr, sampleChunkSize)
+		if int64(buf.Len()) > maxSize {
+			return "", &SampleTooLargeError{EndpointID: endpointID, MaxSize: maxSize}
+		}
+		if n > 0 && options.Progress != nil {
+			read := int64(buf.Len())
+			_ = operation.SafeCall("sample progress", func() error {
+				options.Progress(read)
+				return nil
+			})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", sdkerrors.WithMessagef(err, "transfer: read sample for endpoint %s", endpointID)
+		}
+	}
+	if !utf8.Valid(buf.Bytes()) {
+		return "", fmt.Errorf("transfer: sample for endpoint %s is not UTF-8 text", endpointID)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,126 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	airbyte "github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint/airbyte"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// sampleEndpoints serves a single S3 source endpoint "dte1" and a Postgres source "dte2",
+// recording updates.
+type sampleEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+	updates []*transfer.UpdateEndpointRequest
+}
+
+func (s *sampleEndpoints) Get(ctx context.Context, req *transfer.GetEndpointRequest) (*transfer.Endpoint, error) {
+	if req.EndpointId == "dte2" {
+		return &transfer.Endpoint{Id: req.EndpointId, Settings: &transfer.EndpointSettings{
+			Settings: &transfer.EndpointSettings_PostgresSource{},
+		}}, nil
+	}
+	return &transfer.Endpoint{Id: req.EndpointId, Name: "orders-s3", Labels: map[string]string{"team": "data"}, Settings: &transfer.EndpointSettings{
+		Settings: &transfer.EndpointSettings_S3Source{S3Source: &airbyte.S3Source{Dataset: "orders", PathPattern: "*.csv"}},
+	}}, nil
+}
+
+func (s *sampleEndpoints) Update(ctx context.Context, req *transfer.UpdateEndpointRequest) (*doublecloud.Operation, error) {
+	s.updates = append(s.updates, req)
+	return &doublecloud.Operation{Id: "dteop1", Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+func newTestSampleEndpoints(t *testing.T) (*EndpointServiceClient, *sampleEndpoints) {
+	svc := &sampleEndpoints{}
+	lis := bufconn.Listen(4 << 20)
+	srv := grpc.NewServer()
+	transfer.RegisterEndpointServiceServer(srv, svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewTransfer(func(ctx context.Context) (*grpc.ClientConn, error) { return conn, nil }).Endpoint(), svc
+}
+
+func TestUploadSample(t *testing.T) {
+	c, svc := newTestSampleEndpoints(t)
+	sample := strings.Repeat("id,amount\n", 10000)
+	var progress []int64
+	op, err := c.UploadSample(context.Background(), "dte1", strings.NewReader(sample), SampleOptions{
+		Progress: func(read int64) { progress = append(progress, read) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dteop1", op.GetId())
+
+	require.Len(t, svc.updates, 1)
+	update := svc.updates[0]
+	assert.Equal(t, "orders-s3", update.Name)
+	assert.Equal(t, map[string]string{"team": "data"}, update.Labels)
+	assert.Equal(t, sample, update.GetSettings().GetS3Source().GetSchema())
+	assert.Equal(t, "*.csv", update.GetSettings().GetS3Source().GetPathPattern(), "other settings are kept")
+	assert.Equal(t, []int64{32 << 10, 64 << 10, 96 << 10, int64(len(sample))}, progress)
+}
+
+func TestUploadSample_Size(t *testing.T) {
+	c, svc := newTestSampleEndpoints(t)
+	_, err := c.UploadSample(context.Background(), "dte1", strings.NewReader("12345678"), SampleOptions{MaxSize: 8})
+	require.NoError(t, err, "a sample of exactly the maximum size is accepted")
+
+	_, err = c.UploadSample(context.Background(), "dte1", strings.NewReader("123456789"), SampleOptions{MaxSize: 8})
+	assert.ErrorIs(t, err, ErrSampleTooLarge)
+	assert.EqualError(t, err, "transfer: sample for endpoint dte1 exceeds 8 bytes, upload a smaller sample file")
+
+	_, err = c.UploadSample(context.Background(), "dte1", io.LimitReader(zeros{}, DefaultMaxSampleSize+1), SampleOptions{})
+	assert.ErrorIs(t, err, ErrSampleTooLarge)
+	assert.Len(t, svc.updates, 1, "oversized samples are not sent")
+}
+
+func TestUploadSample_Errors(t *testing.T) {
+	c, svc := newTestSampleEndpoints(t)
+	ctx := context.Background()
+
+	_, err := c.UploadSample(ctx, "dte2", strings.NewReader("id\n"), SampleOptions{})
+	assert.EqualError(t, err, "transfer: endpoint dte2 is not an S3 source, samples are not supported")
+
+	_, err = c.UploadSample(ctx, "dte1", strings.NewReader("\xff\xfe"), SampleOptions{})
+	assert.EqualError(t, err, "transfer: sample for endpoint dte1 is not UTF-8 text")
+
+	readErr := errors.New("disk failure")
+	_, err = c.UploadSample(ctx, "dte1", io.MultiReader(strings.NewReader("id\n"), failingReader{readErr}), SampleOptions{})
+	assert.ErrorIs(t, err, readErr)
+
+	// The context is cancelled by the progress report of the first chunk.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_, err = c.UploadSample(ctx, "dte1", io.LimitReader(zeros{}, 1<<19), SampleOptions{Progress: func(int64) { cancel() }})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, svc.updates)
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '0'
+	}
+	return len(p), nil
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/budget"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// ValidationCleanupTimeout is the least time given to the deletion of the temporary endpoint made by ValidateSpec.
+const ValidationCleanupTimeout = 30 * time.Second
+
+// ValidationFinding is a problem of the spec reported by the service.
+type ValidationFinding struct {
+	// Field is the path of the rejected field, e.g. an entry of include_tables,
+	// if the service pointed one out.
+	Field   string
+	Code    codes.Code
+	Message string
+}
+
+// ValidationReport is the result of ValidateSpec.
+type ValidationReport struct {
+	// EndpointID is the ID of the temporary endpoint, if one was created.
+	EndpointID string
+	Findings   []ValidationFinding
+	// CleanupErr is set if the temporary endpoint may have been left behind.
+	CleanupErr error
+}
+
+// Valid reports whether the service accepted the spec.
+func (r *ValidationReport) Valid() bool { return len(r.Findings) == 0 }
+
+// ValidateSpec checks the spec by creating a temporary endpoint from it, as the endpoint API
+// has no validate-only call, and deleting it afterwards. Rejections of the spec by the service,
+// both by the create call and by its operation, are reported as findings; other failures are
+// returned as errors together with the report.
+//
+// The temporary endpoint is deleted even if ctx is done mid-validation: the deletion gets
+// at least ValidationCleanupTimeout on a context not canceled with ctx, see budget.Cleanup,
+// and its failure is reported in ValidationReport.CleanupErr.
+func (c *EndpointServiceClient) ValidateSpec(ctx context.Context, spec *transfer.CreateEndpointRequest, opts ...grpc.CallOption) (*ValidationReport, error) {
+	req := proto.Clone(spec).(*transfer.CreateEndpointRequest)
+	req.Name = validationEndpointName(spec.GetName())
+	report := &ValidationReport{}
+
+	p, err := c.Create(ctx, req, opts...)
+	if err != nil {
+		if findings := validationFindings(status.Convert(err)); findings != nil {
+			report.Findings = findings
+			return report, nil
+		}
+		return report, err
+	}
+	op := operation.New(&OperationServiceClient{getConn: c.getConn}, p)
+	report.EndpointID = op.ResourceId()
+	defer func() {
+		report.CleanupErr = c.deleteValidationEndpoint(ctx, op, opts)
+	}()
+
+	if err := op.Wait(ctx, opts...); err != nil && !op.Failed() {
+		return report, err
+	}
+	report.EndpointID = op.ResourceId()
+	if st := op.ErrorStatus(); st != nil {
+		report.Findings = validationFindings(st)
+		if report.Findings == nil {
+			return report, sdkerrors.WithMessagef(op.Error(), "%s failed", op)
+		}
+	}
+	return report, nil
+}
+
+func validationEndpointName(name string) string {
+	suffix := uuid.NewString()[:8]
+	if name == "" {
+		return "validate-" + suffix
+	}
+	return fmt.Sprintf("%s-validate-%s", name, suffix)
+}
+
+// validationFindings converts a rejection of the spec to findings, one per field violation
+// if the service listed them. It returns nil for statuses that don't reject the spec.
+func validationFindings(st *status.Status) []ValidationFinding {
+	if st.Code() != codes.InvalidArgument && st.Code() != codes.FailedPrecondition {
+		return nil
+	}
+	var findings []ValidationFinding
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				findings = append(findings, ValidationFinding{Field: v.GetField(), Code: st.Code(), Message: v.GetDescription()})
+			}
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, ValidationFinding{Code: st.Code(), Message: st.Message()})
+	}
+	return findings
+}
+
+// deleteValidationEndpoint waits for the creation to finish and deletes the created endpoint.
+func (c *EndpointServiceClient) deleteValidationEndpoint(ctx context.Context, created *operation.Operation, opts []grpc.CallOption) error {
+	ctx, cancel := budget.Cleanup(ctx, ValidationCleanupTimeout)
+	defer cancel()
+
+	if err := created.Wait(ctx, opts...); err != nil && !created.Done() {
+		return sdkerrors.WithMessagef(err, "cleanup: wait for temporary endpoint %s", created.ResourceId())
+	}
+	if created.Failed() {
+		return nil
+	}
+	id := created.ResourceId()
+	p, err := c.Delete(ctx, &transfer.DeleteEndpointRequest{EndpointId: id}, opts...)
+	if err == nil {
+		err = operation.New(&OperationServiceClient{getConn: c.getConn}, p).Wait(ctx, opts...)
+	}
+	return sdkerrors.WithMessagef(err, "cleanup: delete temporary endpoint %s", id)
+}
@@ -0,0 +1,162 @@
+package transfer
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// validationService fakes endpoints and their operations. The "case" label of the
+// create request picks the behavior: "rejected" is rejected by Create, "missing-table"
+// fails in the operation with a field violation, "slow" stays pending for the first poll.
+type validationService struct {
+	transfer.UnimplementedEndpointServiceServer
+
+	mu      sync.Mutex
+	cases   map[string]string
+	polls   map[string]int
+	names   []string
+	deleted []string
+}
+
+func (s *validationService) Create(ctx context.Context, req *transfer.CreateEndpointRequest) (*doublecloud.Operation, error) {
+	c := req.Labels["case"]
+	if c == "rejected" {
+		return nil, status.Error(codes.InvalidArgument, "settings are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names = append(s.names, req.Name)
+	s.cases["dte-"+req.Name] = c
+	return &doublecloud.Operation{Id: "dte-" + req.Name, ResourceId: "e-" + req.Name, Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+func (s *validationService) Delete(ctx context.Context, req *transfer.DeleteEndpointRequest) (*doublecloud.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, req.EndpointId)
+	return &doublecloud.Operation{Id: "dte-delete", Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+// validationOperations serves the operations of validationService.
+type validationOperations struct {
+	transfer.UnimplementedOperationServiceServer
+	s *validationService
+}
+
+func (o validationOperations) Get(ctx context.Context, req *transfer.GetOperationRequest) (*doublecloud.Operation, error) {
+	s := o.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls[req.OperationId]++
+	op := &doublecloud.Operation{Id: req.OperationId, Status: doublecloud.Operation_STATUS_DONE}
+	if strings.HasPrefix(req.OperationId, "dte-") && req.OperationId != "dte-delete" {
+		op.ResourceId = "e-" + strings.TrimPrefix(req.OperationId, "dte-")
+	}
+	switch s.cases[req.OperationId] {
+	case "slow":
+		if s.polls[req.OperationId] == 1 {
+			op.Status = doublecloud.Operation_STATUS_RUNNING
+		}
+	case "missing-table":
+		st, err := status.New(codes.FailedPrecondition, "tables not found").WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "settings.postgres_source.include_tables[1]", Description: `table "public.orders" does not exist`},
+				{Field: "settings.postgres_source.include_tables[2]", Description: `table "public.log" has no primary key`},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		op.Error = st.Proto()
+	}
+	return op, nil
+}
+
+func (s *validationService) deletedEndpoints() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+func newTestValidation(t *testing.T) (*EndpointServiceClient, *validationService) {
+	svc := &validationService{cases: map[string]string{}, polls: map[string]int{}}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	transfer.RegisterEndpointServiceServer(srv, svc)
+	transfer.RegisterOperationServiceServer(srv, validationOperations{s: svc})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewTransfer(func(ctx context.Context) (*grpc.ClientConn, error) { return conn, nil }).Endpoint(), svc
+}
+
+func validationSpec(c string) *transfer.CreateEndpointRequest {
+	return &transfer.CreateEndpointRequest{ProjectId: "p", Name: "pg", Labels: map[string]string{"case": c}}
+}
+
+func TestValidateSpec(t *testing.T) {
+	endpoints, svc := newTestValidation(t)
+	spec := validationSpec("ok")
+	report, err := endpoints.ValidateSpec(context.Background(), spec)
+	require.NoError(t, err)
+	assert.True(t, report.Valid())
+	assert.NoError(t, report.CleanupErr)
+
+	require.Len(t, svc.names, 1)
+	assert.True(t, strings.HasPrefix(svc.names[0], "pg-validate-"), svc.names[0])
+	assert.Equal(t, "pg", spec.Name, "the spec must not be modified")
+	assert.Equal(t, "e-"+svc.names[0], report.EndpointID)
+	assert.Equal(t, []string{report.EndpointID}, svc.deletedEndpoints())
+}
+
+func TestValidateSpec_Findings(t *testing.T) {
+	endpoints, svc := newTestValidation(t)
+
+	report, err := endpoints.ValidateSpec(context.Background(), validationSpec("missing-table"))
+	require.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Equal(t, []ValidationFinding{
+		{Field: "settings.postgres_source.include_tables[1]", Code: codes.FailedPrecondition, Message: `table "public.orders" does not exist`},
+		{Field: "settings.postgres_source.include_tables[2]", Code: codes.FailedPrecondition, Message: `table "public.log" has no primary key`},
+	}, report.Findings)
+	assert.Empty(t, svc.deletedEndpoints(), "failed creation leaves nothing to delete")
+
+	report, err = endpoints.ValidateSpec(context.Background(), validationSpec("rejected"))
+	require.NoError(t, err)
+	assert.Equal(t, []ValidationFinding{{Code: codes.InvalidArgument, Message: "settings are required"}}, report.Findings)
+	assert.Empty(t, report.EndpointID)
+}
+
+func TestValidateSpec_CleanupOnCancel(t *testing.T) {
+	endpoints, svc := newTestValidation(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// The creation is still running when ctx is done: cleanup waits for it with its own context.
+	report, err := endpoints.ValidateSpec(ctx, validationSpec("slow"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotNil(t, report)
+	assert.NoError(t, report.CleanupErr)
+	assert.NotEmpty(t, report.EndpointID)
+	assert.Equal(t, []string{report.EndpointID}, svc.deletedEndpoints())
+}
@@ -14,7 +14,7 @@ import (
 // OperationServiceClient is a transfer.OperationServiceClient with
 // lazy GRPC connection initialization.
 type OperationServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Get implements transfer.OperationServiceClient
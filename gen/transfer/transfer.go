@@ -7,6 +7,8 @@ import (
 	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
 	doublecloud "github.com/doublecloud/go-genproto/doublecloud/v1"
 	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 )
 
 //revive:disable
@@ -14,7 +16,7 @@ import (
 // TransferServiceClient is a transfer.TransferServiceClient with
 // lazy GRPC connection initialization.
 type TransferServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // Activate implements transfer.TransferServiceClient
@@ -84,6 +86,7 @@ type TransferIterator struct {
 	request *transfer.ListTransfersRequest
 
 	items []*transfer.Transfer
+	leak  *lifecycle.Tracker
 }
 
 func (c *TransferServiceClient) TransferIterator(ctx context.Context, req *transfer.ListTransfersRequest, opts ...grpc.CallOption) *TransferIterator {
@@ -93,13 +96,15 @@ func (c *TransferServiceClient) TransferIterator(ctx context.Context, req *trans
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	return &TransferIterator{
+	it := &TransferIterator{
 		ctx:      ctx,
 		opts:     opts,
 		client:   c,
 		request:  req,
 		pageSize: pageSize,
 	}
+	it.leak = lifecycle.Track(it, "transfer.TransferIterator")
+	return it
 }
 
 func (it *TransferIterator) Next() bool {
@@ -114,6 +119,7 @@ func (it *TransferIterator) Next() bool {
 	it.items = nil // consume last item, if any
 
 	if it.started {
+		it.leak.Close()
 		return false
 	}
 	it.started = true
@@ -121,11 +127,16 @@ func (it *TransferIterator) Next() bool {
 	response, err := it.client.List(it.ctx, it.request, it.opts...)
 	it.err = err
 	if err != nil {
+		it.leak.Close()
 		return false
 	}
 
 	it.items = response.Transfers
-	return len(it.items) > 0
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
 }
 
 func (it *TransferIterator) Take(size int64) ([]*transfer.Transfer, error) {
@@ -171,6 +182,13 @@ func (it *TransferIterator) Error() error {
 	return it.err
 }
 
+// Close ends the iteration and releases the buffered items: Next returns false from then on.
+func (it *TransferIterator) Close() {
+	it.started = true
+	it.items = nil
+	it.leak.Close()
+}
+
 // Update implements transfer.TransferServiceClient
 func (c *TransferServiceClient) Update(ctx context.Context, in *transfer.UpdateTransferRequest, opts ...grpc.CallOption) (*doublecloud.Operation, error) {
 	conn, err := c.getConn(ctx)
@@ -8,11 +8,23 @@ import (
 
 // Transfer provides access to "transfer" service of DoubleCloud
 type Transfer struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // NewTransfer creates instance of Transfer
 func NewTransfer(g func(ctx context.Context) (*grpc.ClientConn, error)) *Transfer {
+	return NewTransferWithConnInterface(func(ctx context.Context) (grpc.ClientConnInterface, error) {
+		conn, err := g(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+// NewTransferWithConnInterface creates instance of Transfer calling through
+// connections returned by g, e.g. ones wrapping SDK connections.
+func NewTransferWithConnInterface(g func(ctx context.Context) (grpc.ClientConnInterface, error)) *Transfer {
 	return &Transfer{g}
 }
 
@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultMaxLag is the replication lag above which DefaultHealthRules report a transfer as
+// degraded.
+const DefaultMaxLag = 5 * time.Minute
+
+// HealthVerdict is the overall health of a transfer, see TransferHealth.
+type HealthVerdict string
+
+const (
+	HealthHealthy   HealthVerdict = "healthy"
+	HealthDegraded  HealthVerdict = "degraded"
+	HealthUnhealthy HealthVerdict = "unhealthy"
+)
+
+// Names of the metrics of TransferHealth.Unavailable.
+const (
+	MetricLag           = "lag"
+	MetricRowsPerSecond = "rows_per_second"
+	MetricLastErrorTime = "last_error_time"
+)
+
+// TransferMetrics are the replication metrics of a transfer. The nil fields are unavailable,
+// unlike zero values: a lag of 0 means the target is up to date.
+type TransferMetrics struct {
+	// Lag is how far the target is behind the source.
+	Lag *time.Duration
+	// RowsPerSecond is the recent throughput of the transfer.
+	RowsPerSecond *float64
+	// LastError is the message of the last error of the transfer, empty if there is none.
+	LastError string
+	// LastErrorTime is when LastError happened.
+	LastErrorTime *time.Time
+}
+
+// MetricsSource returns the replication metrics of transfers, e.g. from the monitoring of
+// the deployment: the DoubleCloud API exposes none.
+type MetricsSource interface {
+	TransferMetrics(ctx context.Context, transferID string) (*TransferMetrics, error)
+}
+
+// MetricsSourceFunc is a MetricsSource function.
+type MetricsSourceFunc func(ctx context.Context, transferID string) (*TransferMetrics, error)
+
+func (f MetricsSourceFunc) TransferMetrics(ctx context.Context, transferID string) (*TransferMetrics, error) {
+	return f(ctx, transferID)
+}
+
+// TransferHealth is the health of a transfer, see TransferServiceClient.Health.
+type TransferHealth struct {
+	TransferID string
+	Status     transfer.TransferStatus
+	Type       transfer.TransferType
+	// Warning is the warning of the transfer, if any.
+	Warning string
+	// TransferMetrics are those of HealthOptions.Metrics. Without them, LastError is the
+	// warning of a failed transfer, and the other metrics are unavailable.
+	TransferMetrics
+	// Unavailable names the metrics that are nil, e.g. MetricLag, in the order of the fields.
+	Unavailable []string
+	// MetricsErr is the error of HealthOptions.Metrics, if it failed. The metrics are
+	// unavailable then.
+	MetricsErr error
+
+	Verdict HealthVerdict
+	// Reasons explain a verdict other than HealthHealthy.
+	Reasons []string
+}
+
+// HealthRules computes the verdict of the health and its reasons, see DefaultHealthRules.
+type HealthRules func(h *TransferHealth) (HealthVerdict, []string)
+
+// HealthOptions configures TransferServiceClient.Health.
+type HealthOptions struct {
+	// Metrics, if set, provides the replication metrics of the transfer.
+	Metrics MetricsSource
+	// Rules replaces DefaultHealthRules, e.g. TransferHealthRules with another lag.
+	Rules HealthRules
+}
+
+// Health returns the health of the transfer: its status, its replication metrics from
+// options.Metrics, if set, and a verdict computed by options.Rules, DefaultHealthRules by
+// default. It makes a single Get call and a single call of options.Metrics, and caches
+// nothing, so that monitors can poll it. A failure of options.Metrics leaves the metrics
+// unavailable, see TransferHealth.MetricsErr, instead of failing Health.
+func (c *TransferServiceClient) Health(ctx context.Context, transferID string, options HealthOptions, opts ...grpc.CallOption) (*TransferHealth, error) {
+	t, err := c.Get(ctx, &transfer.GetTransferRequest{TransferId: transferID}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h := &TransferHealth{
+		TransferID: transferID,
+		Status:     t.GetStatus(),
+		Type:       t.GetType(),
+		Warning:    t.GetWarning(),
+	}
+	if options.Metrics != nil {
+		metrics, err := options.Metrics.TransferMetrics(ctx, transferID)
+		switch {
+		case err != nil:
+			h.MetricsErr = err
+		case metrics != nil:
+			h.TransferMetrics = *metrics
+		}
+	}
+	if h.LastError == "" && h.Status == transfer.TransferStatus_ERROR {
+		h.LastError = h.Warning
+	}
+	if h.Lag == nil {
+		h.Unavailable = append(h.Unavailable, MetricLag)
+	}
+	if h.RowsPerSecond == nil {
+		h.Unavailable = append(h.Unavailable, MetricRowsPerSecond)
+	}
+	if h.LastErrorTime == nil {
+		h.Unavailable = append(h.Unavailable, MetricLastErrorTime)
+	}
+
+	rules := options.Rules
+	if rules == nil {
+		rules = DefaultHealthRules
+	}
+	h.Verdict, h.Reasons = rules(h)
+	return h, nil
+}
+
+// DefaultHealthRules are TransferHealthRules with DefaultMaxLag.
+var DefaultHealthRules = TransferHealthRules(DefaultMaxLag)
+
+// TransferHealthRules returns the rules:
+//
+//   - HealthUnhealthy if the transfer is in error;
+//   - HealthDegraded if the transfer is not running, e.g. created but not activated, stopped
+//     or in a status newer than the SDK, see sdkerrors.UnknownEnum, if it has a warning, if its lag is over maxLag, or if it
+//     replicates no rows while lagging;
+//   - HealthHealthy otherwise, including snapshotting transfers and snapshot transfers done.
+//
+// Unavailable metrics don't change the verdict. The reasons list every rule that matched,
+// the unhealthy ones first.
+func TransferHealthRules(maxLag time.Duration) HealthRules {
+	return func(h *TransferHealth) (HealthVerdict, []string) {
+		var unhealthy, degraded []string
+		switch h.Status {
+		case transfer.TransferStatus_RUNNING, transfer.TransferStatus_SNAPSHOTTING, transfer.TransferStatus_DONE:
+		case transfer.TransferStatus_ERROR:
+			reason := "transfer is error"
+			if h.LastError != "" {
+				reason += ": " + h.LastError
+			}
+			unhealthy = append(unhealthy, reason)
+		case transfer.TransferStatus_TRANSFER_STATUS_UNSPECIFIED, transfer.TransferStatus_CREATING, transfer.TransferStatus_CREATED,
+			transfer.TransferStatus_STOPPING, transfer.TransferStatus_STOPPED:
+			degraded = append(degraded, fmt.Sprintf("transfer is %s", strings.ToLower(strings.TrimPrefix(h.Status.String(), "TRANSFER_STATUS_"))))
+		default:
+			// Not running until the SDK knows the status.
+			sdkerrors.UnknownEnum(string(h.Status.Descriptor().FullName()), int32(h.Status))
+			degraded = append(degraded, fmt.Sprintf("transfer status %d is unknown to the SDK", h.Status))
+		}
+		if h.Warning != "" && h.Status != transfer.TransferStatus_ERROR {
+			degraded = append(degraded, "transfer has a warning: "+h.Warning)
+		}
+		if h.Lag != nil && *h.Lag > maxLag {
+			degraded = append(degraded, fmt.Sprintf("lag %s is over %s", *h.Lag, maxLag))
+		}
+		if h.Lag != nil && *h.Lag > 0 && h.RowsPerSecond != nil && *h.RowsPerSecond == 0 {
+			degraded = append(degraded, "no rows replicated while lagging")
+		}
+
+		switch {
+		case len(unhealthy) > 0:
+			return HealthUnhealthy, append(unhealthy, degraded...)
+		case len(degraded) > 0:
+			return HealthDegraded, degraded
+		}
+		return HealthHealthy, nil
+	}
+}
@@ -0,0 +1,157 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	transfer "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+type healthTransfers struct {
+	transfer.UnimplementedTransferServiceServer
+	transfer *transfer.Transfer
+
+	mu   sync.Mutex
+	gets int
+}
+
+func (f *healthTransfers) Get(ctx context.Context, req *transfer.GetTransferRequest) (*transfer.Transfer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	return f.transfer, nil
+}
+
+func newHealthTestTransfer(t *testing.T, tr *transfer.Transfer) (*TransferServiceClient, *healthTransfers) {
+	srv := &healthTransfers{transfer: tr}
+	return newTestTransferWith(t, func(s *grpc.Server) { transfer.RegisterTransferServiceServer(s, srv) }).Transfer(), srv
+}
+
+// countingMetrics returns metrics, or err, counting the calls.
+type countingMetrics struct {
+	metrics *TransferMetrics
+	err     error
+	calls   int
+}
+
+func (m *countingMetrics) TransferMetrics(ctx context.Context, transferID string) (*TransferMetrics, error) {
+	m.calls++
+	return m.metrics, m.err
+}
+
+func durationOf(d time.Duration) *time.Duration { return &d }
+func rateOf(r float64) *float64                 { return &r }
+
+func TestHealth_Metrics(t *testing.T) {
+	c, srv := newHealthTestTransfer(t, &transfer.Transfer{Id: "dtt1", Status: transfer.TransferStatus_RUNNING, Type: transfer.TransferType_INCREMENT_ONLY})
+	failedAt := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	metrics := &countingMetrics{metrics: &TransferMetrics{
+		Lag:           durationOf(20 * time.Minute),
+		RowsPerSecond: rateOf(0),
+		LastError:     "connection reset by peer",
+		LastErrorTime: &failedAt,
+	}}
+	ctx := context.Background()
+
+	h, err := c.Health(ctx, "dtt1", HealthOptions{Metrics: metrics})
+	require.NoError(t, err)
+	assert.Equal(t, transfer.TransferStatus_RUNNING, h.Status)
+	assert.Equal(t, 20*time.Minute, *h.Lag)
+	assert.Equal(t, 0.0, *h.RowsPerSecond)
+	assert.Equal(t, "connection reset by peer", h.LastError)
+	assert.Equal(t, failedAt, *h.LastErrorTime)
+	assert.Empty(t, h.Unavailable)
+	assert.Equal(t, HealthDegraded, h.Verdict)
+	assert.Equal(t, []string{"lag 20m0s is over 5m0s", "no rows replicated while lagging"}, h.Reasons)
+
+	for i := 0; i < 3; i++ {
+		_, err = c.Health(ctx, "dtt1", HealthOptions{Metrics: metrics})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, srv.gets, "a single Get per call, nothing cached")
+	assert.Equal(t, 4, metrics.calls)
+
+	metrics.metrics.Lag, metrics.metrics.RowsPerSecond = durationOf(time.Second), rateOf(1500)
+	h, err = c.Health(ctx, "dtt1", HealthOptions{Metrics: metrics})
+	require.NoError(t, err)
+	assert.Equal(t, HealthHealthy, h.Verdict)
+	assert.Empty(t, h.Reasons)
+
+	h, err = c.Health(ctx, "dtt1", HealthOptions{Metrics: metrics, Rules: TransferHealthRules(0)})
+	require.NoError(t, err)
+	assert.Equal(t, HealthDegraded, h.Verdict, "the rules of the options")
+	assert.Equal(t, []string{"lag 1s is over 0s"}, h.Reasons)
+}
+
+func TestHealth_NoMetrics(t *testing.T) {
+	c, _ := newHealthTestTransfer(t, &transfer.Transfer{Id: "dtt1", Status: transfer.TransferStatus_ERROR, Warning: "source is unreachable"})
+	h, err := c.Health(context.Background(), "dtt1", HealthOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, h.Lag, "unavailable rather than zero")
+	assert.Nil(t, h.RowsPerSecond)
+	assert.Nil(t, h.LastErrorTime)
+	assert.Equal(t, []string{MetricLag, MetricRowsPerSecond, MetricLastErrorTime}, h.Unavailable)
+	assert.Equal(t, "source is unreachable", h.LastError, "derived from the warning of the failed transfer")
+	assert.Equal(t, HealthUnhealthy, h.Verdict)
+	assert.Equal(t, []string{"transfer is error: source is unreachable"}, h.Reasons)
+
+	metricsErr := errors.New("monitoring is down")
+	failing := MetricsSourceFunc(func(ctx context.Context, id string) (*TransferMetrics, error) { return nil, metricsErr })
+	h, err = c.Health(context.Background(), "dtt1", HealthOptions{Metrics: failing})
+	require.NoError(t, err, "metrics failures don't fail the health")
+	assert.ErrorIs(t, h.MetricsErr, metricsErr)
+	assert.Len(t, h.Unavailable, 3)
+}
+
+func TestTransferHealthRules(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		health  TransferHealth
+		verdict HealthVerdict
+		reasons []string
+	}{
+		{"running without metrics", TransferHealth{Status: transfer.TransferStatus_RUNNING}, HealthHealthy, nil},
+		{"snapshotting", TransferHealth{Status: transfer.TransferStatus_SNAPSHOTTING}, HealthHealthy, nil},
+		{"snapshot done", TransferHealth{Status: transfer.TransferStatus_DONE}, HealthHealthy, nil},
+		{"not activated", TransferHealth{Status: transfer.TransferStatus_CREATED}, HealthDegraded, []string{"transfer is created"}},
+		{"stopped", TransferHealth{Status: transfer.TransferStatus_STOPPED}, HealthDegraded, []string{"transfer is stopped"}},
+		{"unknown", TransferHealth{}, HealthDegraded, []string{"transfer is unspecified"}},
+		{"warning", TransferHealth{Status: transfer.TransferStatus_RUNNING, Warning: "slow target"}, HealthDegraded, []string{"transfer has a warning: slow target"}},
+		{"error without message", TransferHealth{Status: transfer.TransferStatus_ERROR}, HealthUnhealthy, []string{"transfer is error"}},
+		{"error lagging", TransferHealth{Status: transfer.TransferStatus_ERROR, TransferMetrics: TransferMetrics{Lag: durationOf(time.Hour)}},
+			HealthUnhealthy, []string{"transfer is error", "lag 1h0m0s is over 5m0s"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			verdict, reasons := DefaultHealthRules(&tc.health)
+			assert.Equal(t, tc.verdict, verdict)
+			assert.Equal(t, tc.reasons, reasons)
+		})
+	}
+}
+
+// TestTransferHealthRules_StatusEnum checks that every transfer status of the API is handled
+// explicitly, and that the statuses added since are not running.
+func TestTransferHealthRules_StatusEnum(t *testing.T) {
+	var unknown []int32
+	defer sdkerrors.SetUnknownEnumHandler(func(kind string, value int32) {
+		assert.Equal(t, "doublecloud.transfer.v1.TransferStatus", kind)
+		unknown = append(unknown, value)
+	})()
+
+	for v, name := range transfer.TransferStatus_name {
+		DefaultHealthRules(&TransferHealth{Status: transfer.TransferStatus(v)})
+		assert.Empty(t, unknown, name)
+	}
+	verdict, reasons := DefaultHealthRules(&TransferHealth{Status: transfer.TransferStatus(99)})
+	assert.Equal(t, []int32{99}, unknown)
+	assert.Equal(t, HealthDegraded, verdict)
+	assert.Equal(t, []string{"transfer status 99 is unknown to the SDK"}, reasons)
+}
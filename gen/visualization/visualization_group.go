@@ -8,11 +8,23 @@ import (
 
 // Visualization provides access to "visualization" service of DoubleCloud
 type Visualization struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // NewVisualization creates instance of Visualization
 func NewVisualization(g func(ctx context.Context) (*grpc.ClientConn, error)) *Visualization {
+	return NewVisualizationWithConnInterface(func(ctx context.Context) (grpc.ClientConnInterface, error) {
+		conn, err := g(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+// NewVisualizationWithConnInterface creates instance of Visualization calling through
+// connections returned by g, e.g. ones wrapping SDK connections.
+func NewVisualizationWithConnInterface(g func(ctx context.Context) (grpc.ClientConnInterface, error)) *Visualization {
 	return &Visualization{g}
 }
 
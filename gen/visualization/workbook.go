@@ -14,7 +14,7 @@ import (
 // WorkbookServiceClient is a visualization.WorkbookServiceClient with
 // lazy GRPC connection initialization.
 type WorkbookServiceClient struct {
-	getConn func(ctx context.Context) (*grpc.ClientConn, error)
+	getConn func(ctx context.Context) (grpc.ClientConnInterface, error)
 }
 
 // AdviseDatasetFields implements visualization.WorkbookServiceClient
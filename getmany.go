@@ -0,0 +1,173 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const (
+	// DefaultGetManyBatchSize bounds the resources of a batch of GetMany unless
+	// GetManyOptions.BatchSize sets the one of the service.
+	DefaultGetManyBatchSize = 50
+	// DefaultGetManyConcurrency bounds the batches of GetMany in flight.
+	DefaultGetManyConcurrency = 4
+)
+
+// GetManyResult is the resource of an input of GetMany, or the error getting it.
+type GetManyResult struct {
+	// Input is the reference passed to GetMany, and Ref the one it was parsed to.
+	Input    string
+	Ref      ResourceRef
+	Resource proto.Message
+	Err      error
+}
+
+// BatchGetFunc gets a batch of resources of one service. It returns a result per reference,
+// in the order of refs, or an error failing the whole batch.
+type BatchGetFunc func(ctx context.Context, refs []ResourceRef) ([]GetManyResult, error)
+
+// GetManyOptions configures GetMany.
+type GetManyOptions struct {
+	// BatchSize bounds the resources of a batch by service. Defaults to
+	// DefaultGetManyBatchSize for the services missing.
+	BatchSize map[ServiceKind]int
+	// Concurrency bounds the batches in flight. Defaults to DefaultGetManyConcurrency.
+	Concurrency int
+	// BatchGet gets a batch. The API has no batch get, so by default a batch is the Gets of
+	// its resources one after another, see GetResource, failing as a whole once ctx is done.
+	BatchGet BatchGetFunc
+}
+
+func (o GetManyOptions) batchSize(kind ServiceKind) int {
+	if n := o.BatchSize[kind]; n > 0 {
+		return n
+	}
+	return DefaultGetManyBatchSize
+}
+
+// GetMany gets the resources of refs, e.g. thousands of IDs, in the formats of
+// ParseResourceRef. The references are split into batches of a service, made with bounded
+// concurrency, see GetManyOptions. The result has one entry per reference, in the order of
+// refs: a reference repeated is got once. If a batch fails as a whole, every reference of
+// the batch gets its error.
+//
+// The returned error aggregates the failures of all references.
+func (sdk *SDK) GetMany(ctx context.Context, refs []string, options GetManyOptions, opts ...grpc.CallOption) ([]GetManyResult, error) {
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultGetManyConcurrency
+	}
+	if options.BatchGet == nil {
+		options.BatchGet = func(ctx context.Context, refs []ResourceRef) ([]GetManyResult, error) {
+			return sdk.getBatch(ctx, refs, opts)
+		}
+	}
+
+	results := make([]GetManyResult, len(refs))
+	// positions are the indexes of the results of every reference, by its name.
+	positions := map[string][]int{}
+	var (
+		byService = map[ServiceKind][]ResourceRef{}
+		services  []ServiceKind
+	)
+	for i, in := range refs {
+		results[i].Input = in
+		ref, err := sdk.ParseResourceRef(in)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Ref = ref
+		name := ref.String()
+		if _, ok := positions[name]; !ok {
+			kind := ref.Kind.ServiceKind()
+			if _, ok := byService[kind]; !ok {
+				services = append(services, kind)
+			}
+			byService[kind] = append(byService[kind], ref)
+		}
+		positions[name] = append(positions[name], i)
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	set := func(ref ResourceRef, res GetManyResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, i := range positions[ref.String()] {
+			results[i].Resource, results[i].Err = res.Resource, res.Err
+		}
+	}
+	sem := make(chan struct{}, options.Concurrency)
+	for _, kind := range services {
+		all, size := byService[kind], options.batchSize(kind)
+		for len(all) > 0 {
+			if size > len(all) {
+				size = len(all)
+			}
+			batch := all[:size]
+			all = all[size:]
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				got, err := runBatch(ctx, options.BatchGet, batch)
+				for i, ref := range batch {
+					if err != nil {
+						set(ref, GetManyResult{Err: err})
+					} else {
+						set(ref, got[i])
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	var errs error
+	for _, res := range results {
+		if res.Err != nil {
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(res.Err, "get %s", res.Input))
+		}
+	}
+	return results, errs
+}
+
+// runBatch runs the batch get, checking that it returned a result per reference.
+func runBatch(ctx context.Context, get BatchGetFunc, batch []ResourceRef) ([]GetManyResult, error) {
+	var got []GetManyResult
+	err := operation.SafeCall("batch get", func() (err error) {
+		got, err = get(ctx, batch)
+		return err
+	})
+	if err == nil && len(got) != len(batch) {
+		err = fmt.Errorf("batch get returned %d results for %d resources", len(got), len(batch))
+	}
+	return got, err
+}
+
+// getBatch gets the resources of the batch one after another.
+func (sdk *SDK) getBatch(ctx context.Context, refs []ResourceRef, opts []grpc.CallOption) ([]GetManyResult, error) {
+	results := make([]GetManyResult, len(refs))
+	for i, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		results[i].Ref = ref
+		results[i].Resource, results[i].Err = sdk.GetResource(ctx, ref, opts...)
+	}
+	return results, nil
+}
@@ -0,0 +1,164 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// batchRecorder is a BatchGetFunc recording its batches. The batches with an ID of fail
+// fail as a whole; the ID "chc404" is not found.
+type batchRecorder struct {
+	fail map[string]bool
+
+	mu       sync.Mutex
+	batches  [][]string
+	inFlight int
+	maxIn    int
+}
+
+func (r *batchRecorder) get(ctx context.Context, refs []ResourceRef) ([]GetManyResult, error) {
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		ids[i] = ref.ID
+	}
+	r.mu.Lock()
+	r.batches = append(r.batches, ids)
+	if r.inFlight++; r.inFlight > r.maxIn {
+		r.maxIn = r.inFlight
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.inFlight--
+		r.mu.Unlock()
+	}()
+
+	results := make([]GetManyResult, len(refs))
+	for i, ref := range refs {
+		if r.fail[ref.ID] {
+			return nil, status.Errorf(codes.Unavailable, "batch of %s failed", ref.ID)
+		}
+		if ref.ID == "chc404" {
+			results[i].Err = status.Error(codes.NotFound, "not found")
+			continue
+		}
+		results[i].Resource = &clickhouse.Cluster{Id: ref.ID}
+	}
+	return results, nil
+}
+
+func TestGetMany_Batches(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	var refs []string
+	for i := 1; i <= 9; i++ {
+		refs = append(refs, fmt.Sprintf("chc%d", i), fmt.Sprintf("kfc%d", i))
+	}
+	refs = append(refs, "chc404", "chc2", "not a ref")
+	rec := &batchRecorder{fail: map[string]bool{"chc3": true, "kfc9": true}}
+
+	results, err := sdk.GetMany(context.Background(), refs, GetManyOptions{
+		BatchSize:   map[ServiceKind]int{ClickHouseServiceID: 2, KafkaServiceID: 2},
+		Concurrency: 3,
+		BatchGet:    rec.get,
+	})
+	require.Error(t, err)
+	require.Len(t, results, len(refs))
+
+	for _, b := range rec.batches {
+		assert.LessOrEqual(t, len(b), 2, "the batch size of the service")
+		assert.Equal(t, b[0][:3], b[len(b)-1][:3], "the batches are of one service")
+	}
+	assert.Len(t, rec.batches, 10, "5 batches of clickhouse and 5 of kafka, 4 of them full")
+	assert.LessOrEqual(t, rec.maxIn, 3)
+
+	// The clickhouse batches are [chc1 chc2] [chc3 chc4] ..., chc404 the last one.
+	failed := map[string]bool{"chc3": true, "chc4": true, "kfc9": true, "chc404": true, "not a ref": true}
+	for i, res := range results {
+		assert.Equal(t, refs[i], res.Input, "the order of the input")
+		if !failed[res.Input] {
+			require.NoError(t, res.Err, res.Input)
+			assert.Equal(t, res.Input, res.Resource.(*clickhouse.Cluster).GetId())
+			continue
+		}
+		require.Error(t, res.Err, res.Input)
+		assert.Nil(t, res.Resource)
+	}
+	assert.Equal(t, codes.Unavailable, status.Code(results[indexOf(refs, "chc4")].Err), "the batch error of every ID of the batch")
+	assert.Contains(t, results[indexOf(refs, "chc4")].Err.Error(), "batch of chc3 failed")
+	assert.Equal(t, codes.Unavailable, status.Code(results[indexOf(refs, "kfc9")].Err))
+	assert.Equal(t, codes.NotFound, status.Code(results[indexOf(refs, "chc404")].Err))
+	assert.ErrorIs(t, results[len(refs)-1].Err, ErrInvalidResourceRef)
+	assert.Same(t, results[indexOf(refs, "chc2")].Resource, results[len(refs)-2].Resource, "repeated IDs are got once")
+
+	var ids int
+	for _, b := range rec.batches {
+		ids += len(b)
+	}
+	assert.Equal(t, 19, ids, "every ID is got once")
+	assert.Equal(t, 5, strings.Count(err.Error(), "* get "), "the failures of the references")
+}
+
+func TestGetMany_BatchGetResults(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	results, err := sdk.GetMany(context.Background(), []string{"chc1", "chc2"}, GetManyOptions{
+		BatchGet: func(ctx context.Context, refs []ResourceRef) ([]GetManyResult, error) {
+			return []GetManyResult{{Resource: &clickhouse.Cluster{}}}, nil
+		},
+	})
+	require.Error(t, err)
+	for _, res := range results {
+		assert.EqualError(t, res.Err, "batch get returned 1 results for 2 resources")
+	}
+
+	results, err = sdk.GetMany(context.Background(), []string{"chc1"}, GetManyOptions{
+		BatchGet: func(ctx context.Context, refs []ResourceRef) ([]GetManyResult, error) { panic("boom") },
+	})
+	require.Error(t, err)
+	assert.Contains(t, results[0].Err.Error(), "boom", "panics fail the batch")
+}
+
+type manyKafkaClusters struct {
+	kafka.UnimplementedClusterServiceServer
+}
+
+func (manyKafkaClusters) Get(ctx context.Context, req *kafka.GetClusterRequest) (*kafka.Cluster, error) {
+	if req.ClusterId == "kfc404" {
+		return nil, status.Error(codes.NotFound, "no cluster")
+	}
+	return &kafka.Cluster{Id: req.ClusterId, ProjectId: "prj1"}, nil
+}
+
+func TestGetMany_Gets(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, refClusters{})
+		kafka.RegisterClusterServiceServer(s, manyKafkaClusters{})
+	})
+	results, err := sdk.GetMany(context.Background(), []string{"kfc1", "chc1", "kfc404", "kfc2"}, GetManyOptions{
+		BatchSize: map[ServiceKind]int{KafkaServiceID: 2},
+	})
+	require.Error(t, err)
+	assert.True(t, proto.Equal(&kafka.Cluster{Id: "kfc1", ProjectId: "prj1"}, results[0].Resource))
+	assert.True(t, proto.Equal(&clickhouse.Cluster{Id: "chc1", ProjectId: "prj1"}, results[1].Resource))
+	assert.Equal(t, codes.NotFound, status.Code(results[2].Err), "the errors of single IDs fail them only")
+	assert.NoError(t, results[3].Err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = sdk.GetMany(ctx, []string{"kfc1", "kfc2"}, GetManyOptions{})
+	require.Error(t, err)
+	for _, res := range results {
+		assert.Equal(t, codes.Canceled, status.Code(res.Err), "the batch fails once ctx is done")
+	}
+}
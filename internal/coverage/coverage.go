@@ -0,0 +1,189 @@
+// Package coverage detects the RPCs of the generated DoubleCloud services that the clients of
+// gen don't wrap, so the SDK doesn't silently lag behind go-genproto. Its test, run with
+// go test ./internal/coverage, walks the service descriptors of the ClickHouse, Kafka,
+// Network and Transfer APIs, compares them with Wrappers and fails with a report of the RPCs
+// neither wrapped nor acknowledged in allowlist.txt.
+//
+// Each line of allowlist.txt acknowledges an RPC by its full method name and a reason:
+//
+//	# Comments and blank lines are ignored.
+//	doublecloud.clickhouse.v1.BackupService/Restore not released yet, see the changelog
+//
+// Entries for the RPCs wrapped or gone make the test fail too, so the allowlist shrinks
+// as the wrappers catch up.
+package coverage
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	clickhousev1 "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkav1 "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkv1 "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	transferv1 "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/doublecloud/go-sdk/gen/clickhouse"
+	"github.com/doublecloud/go-sdk/gen/kafka"
+	"github.com/doublecloud/go-sdk/gen/network"
+	"github.com/doublecloud/go-sdk/gen/transfer"
+)
+
+// Packages are the proto packages of the services checked.
+var Packages = []protoreflect.FullName{
+	"doublecloud.clickhouse.v1",
+	"doublecloud.kafka.v1",
+	"doublecloud.network.v1",
+	"doublecloud.transfer.v1",
+}
+
+// Wrappers maps the services to the clients of gen wrapping them: an RPC is covered if its
+// client has the method of its name. Services missing here have none of their RPCs covered.
+var Wrappers = map[protoreflect.FullName]reflect.Type{
+	"doublecloud.clickhouse.v1.BackupService":    reflect.TypeOf(&clickhouse.BackupServiceClient{}),
+	"doublecloud.clickhouse.v1.ClusterService":   reflect.TypeOf(&clickhouse.ClusterServiceClient{}),
+	"doublecloud.clickhouse.v1.OperationService": reflect.TypeOf(&clickhouse.OperationServiceClient{}),
+	"doublecloud.clickhouse.v1.VersionService":   reflect.TypeOf(&clickhouse.VersionServiceClient{}),
+
+	"doublecloud.kafka.v1.ClusterService":   reflect.TypeOf(&kafka.ClusterServiceClient{}),
+	"doublecloud.kafka.v1.OperationService": reflect.TypeOf(&kafka.OperationServiceClient{}),
+	"doublecloud.kafka.v1.TopicService":     reflect.TypeOf(&kafka.TopicServiceClient{}),
+	"doublecloud.kafka.v1.UserService":      reflect.TypeOf(&kafka.UserServiceClient{}),
+	"doublecloud.kafka.v1.VersionService":   reflect.TypeOf(&kafka.VersionServiceClient{}),
+
+	"doublecloud.network.v1.NetworkConnectionService": reflect.TypeOf(&network.NetworkConnectionServiceClient{}),
+	"doublecloud.network.v1.NetworkService":           reflect.TypeOf(&network.NetworkServiceClient{}),
+	"doublecloud.network.v1.OperationService":         reflect.TypeOf(&network.OperationServiceClient{}),
+
+	"doublecloud.transfer.v1.EndpointService":  reflect.TypeOf(&transfer.EndpointServiceClient{}),
+	"doublecloud.transfer.v1.OperationService": reflect.TypeOf(&transfer.OperationServiceClient{}),
+	"doublecloud.transfer.v1.TransferService":  reflect.TypeOf(&transfer.TransferServiceClient{}),
+}
+
+// The files of the packages, so that they are registered whatever the imports of gen.
+var _ = []protoreflect.FileDescriptor{
+	clickhousev1.File_doublecloud_clickhouse_v1_cluster_service_proto,
+	kafkav1.File_doublecloud_kafka_v1_cluster_service_proto,
+	networkv1.File_doublecloud_network_v1_network_service_proto,
+	transferv1.File_doublecloud_transfer_v1_transfer_service_proto,
+}
+
+//go:embed allowlist.txt
+var allowlist string
+
+// Services returns the descriptors of the services of the packages registered in files,
+// sorted by full name.
+func Services(files *protoregistry.Files, packages ...protoreflect.FullName) []protoreflect.ServiceDescriptor {
+	var services []protoreflect.ServiceDescriptor
+	for _, pkg := range packages {
+		files.RangeFilesByPackage(pkg, func(f protoreflect.FileDescriptor) bool {
+			for i := 0; i < f.Services().Len(); i++ {
+				services = append(services, f.Services().Get(i))
+			}
+			return true
+		})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].FullName() < services[j].FullName() })
+	return services
+}
+
+// Allowlist maps the full method names of the RPCs acknowledged as not wrapped, e.g.
+// "doublecloud.kafka.v1.ClusterService/Get", to the reasons.
+type Allowlist map[string]string
+
+// ParseAllowlist parses the allowlist format, see the package documentation.
+func ParseAllowlist(text string) (Allowlist, error) {
+	allow := Allowlist{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		method, reason, _ := strings.Cut(entry, " ")
+		reason = strings.TrimSpace(reason)
+		switch _, dup := allow[method]; {
+		case !strings.Contains(method, "/"):
+			return nil, fmt.Errorf("allowlist line %d: %q is not a full method name, want <service>/<method>", line, method)
+		case reason == "":
+			return nil, fmt.Errorf("allowlist line %d: %s: reason required", line, method)
+		case dup:
+			return nil, fmt.Errorf("allowlist line %d: %s listed twice", line, method)
+		}
+		allow[method] = reason
+	}
+	return allow, scanner.Err()
+}
+
+// DefaultAllowlist returns the allowlist of allowlist.txt.
+func DefaultAllowlist() (Allowlist, error) {
+	return ParseAllowlist(allowlist)
+}
+
+// Report is the result of Check.
+type Report struct {
+	// Uncovered are the full method names of the RPCs neither wrapped nor allowlisted.
+	Uncovered []string
+	// Stale are the allowlisted full method names of the RPCs wrapped or not found.
+	Stale []string
+}
+
+// OK reports whether every RPC is covered and the allowlist is up to date.
+func (r *Report) OK() bool {
+	return len(r.Uncovered) == 0 && len(r.Stale) == 0
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	if len(r.Uncovered) > 0 {
+		fmt.Fprintf(&b, "%d RPCs are not wrapped by gen, wrap them or acknowledge them in allowlist.txt with a reason:\n", len(r.Uncovered))
+		for _, m := range r.Uncovered {
+			fmt.Fprintf(&b, "\t%s\n", m)
+		}
+	}
+	if len(r.Stale) > 0 {
+		fmt.Fprintf(&b, "%d allowlist.txt entries are wrapped or no longer exist, remove them:\n", len(r.Stale))
+		for _, m := range r.Stale {
+			fmt.Fprintf(&b, "\t%s\n", m)
+		}
+	}
+	return b.String()
+}
+
+// Check compares the RPCs of services with the methods of their wrappers.
+func Check(services []protoreflect.ServiceDescriptor, wrappers map[protoreflect.FullName]reflect.Type, allow Allowlist) *Report {
+	r := &Report{}
+	seen := map[string]bool{}
+	for _, s := range services {
+		wrapper := wrappers[s.FullName()]
+		for i := 0; i < s.Methods().Len(); i++ {
+			name := string(s.Methods().Get(i).Name())
+			method := fmt.Sprintf("%s/%s", s.FullName(), name)
+			wrapped := false
+			if wrapper != nil {
+				_, wrapped = wrapper.MethodByName(name)
+			}
+			_, allowed := allow[method]
+			switch {
+			case wrapped && allowed:
+				r.Stale = append(r.Stale, method)
+			case !wrapped && !allowed:
+				r.Uncovered = append(r.Uncovered, method)
+			}
+			seen[method] = true
+		}
+	}
+	for method := range allow {
+		if !seen[method] {
+			r.Stale = append(r.Stale, method)
+		}
+	}
+	sort.Strings(r.Uncovered)
+	sort.Strings(r.Stale)
+	return r
+}
@@ -0,0 +1,76 @@
+package coverage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestGenprotoCoverage(t *testing.T) {
+	allow, err := DefaultAllowlist()
+	require.NoError(t, err)
+	services := Services(protoregistry.GlobalFiles, Packages...)
+	require.NotEmpty(t, services)
+	for _, s := range services {
+		assert.Contains(t, Wrappers, s.FullName(), "service without a wrapper")
+	}
+	if r := Check(services, Wrappers, allow); !r.OK() {
+		t.Fatal(r)
+	}
+}
+
+// backupGetter wraps the Get RPC of the ClickHouse backup service only.
+type backupGetter struct{}
+
+func (backupGetter) Get() {}
+
+func TestCheck(t *testing.T) {
+	services := Services(protoregistry.GlobalFiles, "doublecloud.clickhouse.v1", "doublecloud.nope.v1")
+	wrappers := map[protoreflect.FullName]reflect.Type{
+		"doublecloud.clickhouse.v1.BackupService": reflect.TypeOf(backupGetter{}),
+	}
+	for name, wrapper := range Wrappers {
+		if name != "doublecloud.clickhouse.v1.BackupService" {
+			wrappers[name] = wrapper
+		}
+	}
+	r := Check(services, wrappers, Allowlist{
+		"doublecloud.clickhouse.v1.BackupService/List": "paged by BackupIterator",
+		"doublecloud.clickhouse.v1.BackupService/Get":  "wrapped meanwhile",
+		"doublecloud.clickhouse.v1.BackupService/Nope": "removed upstream",
+	})
+	assert.False(t, r.OK())
+	assert.Equal(t, []string{
+		"doublecloud.clickhouse.v1.BackupService/Create",
+		"doublecloud.clickhouse.v1.BackupService/Delete",
+	}, r.Uncovered)
+	assert.Equal(t, []string{
+		"doublecloud.clickhouse.v1.BackupService/Get",
+		"doublecloud.clickhouse.v1.BackupService/Nope",
+	}, r.Stale)
+	assert.Contains(t, r.String(), "2 RPCs are not wrapped by gen")
+	assert.Contains(t, r.String(), "\tdoublecloud.clickhouse.v1.BackupService/Create\n")
+
+	delete(wrappers, "doublecloud.clickhouse.v1.VersionService")
+	r = Check(services, wrappers, nil)
+	assert.Contains(t, r.Uncovered, "doublecloud.clickhouse.v1.VersionService/List", "services without a wrapper are uncovered")
+}
+
+func TestParseAllowlist(t *testing.T) {
+	allow, err := ParseAllowlist("# comment\n\n  doublecloud.kafka.v1.ClusterService/Get   not released yet \n")
+	require.NoError(t, err)
+	assert.Equal(t, Allowlist{"doublecloud.kafka.v1.ClusterService/Get": "not released yet"}, allow)
+
+	for text, want := range map[string]string{
+		"doublecloud.kafka.v1.ClusterService/Get":                     "line 1: doublecloud.kafka.v1.ClusterService/Get: reason required",
+		"ClusterService.Get because":                                  `line 1: "ClusterService.Get" is not a full method name`,
+		"# a\nkafka.ClusterService/Get a\nkafka.ClusterService/Get b": "line 3: kafka.ClusterService/Get listed twice",
+	} {
+		_, err := ParseAllowlist(text)
+		assert.ErrorContains(t, err, want)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build go1.21
+
+package lifecycle
+
+import "context"
+
+// AfterFunc calls f in its own goroutine once ctx is done, see context.AfterFunc. Calling
+// stop stops the association of f with ctx; it reports false if f was started already.
+func AfterFunc(ctx context.Context, f func()) (stop func() bool) {
+	return context.AfterFunc(ctx, f)
+}
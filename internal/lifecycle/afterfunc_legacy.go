@@ -0,0 +1,31 @@
+//go:build !go1.21
+
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// AfterFunc calls f in its own goroutine once ctx is done, like context.AfterFunc of Go
+// 1.21, with a goroutine waiting for ctx until stop is called. Calling stop stops the
+// association of f with ctx; it reports false if f was started already.
+func AfterFunc(ctx context.Context, f func()) (stop func() bool) {
+	var once sync.Once
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			once.Do(func() { go f() })
+		case <-stopped:
+		}
+	}()
+	return func() bool {
+		stop := false
+		once.Do(func() {
+			stop = true
+			close(stopped)
+		})
+		return stop
+	}
+}
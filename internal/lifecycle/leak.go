@@ -0,0 +1,87 @@
+// Package lifecycle ties the background work of the SDK to contexts and detects the
+// iterators abandoned without Close in debug mode, see Track.
+package lifecycle
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// leakDetection is set by EnableLeakDetection.
+var leakDetection atomic.Bool
+
+// EnableLeakDetection turns the detection of Track on or off for the whole process.
+func EnableLeakDetection(on bool) {
+	leakDetection.Store(on)
+}
+
+// Leak is an object tracked by Track collected by the garbage collector without being
+// closed.
+type Leak struct {
+	// Kind names the object, e.g. "clickhouse.ClusterIterator".
+	Kind string
+	// Stack is the stack that created the object.
+	Stack string
+}
+
+var reporter = struct {
+	sync.RWMutex
+	report func(Leak)
+}{report: logLeak}
+
+func logLeak(l Leak) {
+	grpclog.Warningf("dcsdk: %s was not closed, created at:\n%s", l.Kind, l.Stack)
+}
+
+// SetLeakReporter replaces the function called with the leaks, logging them by default,
+// until restore is called.
+func SetLeakReporter(report func(Leak)) (restore func()) {
+	reporter.Lock()
+	defer reporter.Unlock()
+	prev := reporter.report
+	reporter.report = report
+	return func() {
+		reporter.Lock()
+		defer reporter.Unlock()
+		reporter.report = prev
+	}
+}
+
+// Tracker is the leak check of an object of Track. Its methods are no-ops on nil, the
+// Tracker of objects created while the detection is off.
+type Tracker struct {
+	closed atomic.Bool
+}
+
+// Track reports obj, a pointer, as a leak if it is collected by the garbage collector
+// before the returned Tracker is closed, e.g. by the Close of an iterator or once it is
+// exhausted. It returns nil unless EnableLeakDetection is on, as the stack of obj is
+// captured and a finalizer set.
+func Track(obj any, kind string) *Tracker {
+	if !leakDetection.Load() {
+		return nil
+	}
+	t := &Tracker{}
+	stack := string(debug.Stack())
+	runtime.SetFinalizer(obj, func(any) {
+		if t.closed.Load() {
+			return
+		}
+		reporter.RLock()
+		report := reporter.report
+		reporter.RUnlock()
+		report(Leak{Kind: kind, Stack: stack})
+	})
+	return t
+}
+
+// Close marks the object tracked as closed.
+func (t *Tracker) Close() {
+	if t != nil {
+		t.closed.Store(true)
+	}
+}
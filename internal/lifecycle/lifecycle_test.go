@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestAfterFunc(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	called := make(chan struct{})
+	AfterFunc(ctx, func() { close(called) })
+	cancel()
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not called once ctx is done")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	stop := AfterFunc(ctx, func() { t.Error("called after stop") })
+	assert.True(t, stop())
+	assert.False(t, stop(), "stopped already")
+	cancel()
+}
+
+// collect runs the garbage collector until want leaks of the objects track made are
+// reported, and a few more times to catch the leaks reported wrongly.
+func collect(t *testing.T, want int, track func()) []Leak {
+	leaks := make(chan Leak, 10)
+	defer SetLeakReporter(func(l Leak) { leaks <- l })()
+	track()
+	var reported []Leak
+	extra := 5
+	for deadline := time.Now().Add(5 * time.Second); extra > 0 && time.Now().Before(deadline); {
+		if len(reported) >= want {
+			extra--
+		}
+		runtime.GC()
+		select {
+		case l := <-leaks:
+			reported = append(reported, l)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return reported
+}
+
+type iterator struct {
+	leak  *Tracker
+	items []int
+}
+
+func TestTrack(t *testing.T) {
+	assert.Nil(t, Track(&iterator{}, "iterator"), "the detection is off by default")
+	EnableLeakDetection(true)
+	defer EnableLeakDetection(false)
+
+	leaks := collect(t, 1, func() {
+		abandoned := &iterator{items: []int{1, 2}}
+		abandoned.leak = Track(abandoned, "test.iterator")
+		closed := &iterator{}
+		closed.leak = Track(closed, "test.closed")
+		closed.leak.Close()
+	})
+	require.Len(t, leaks, 1, "only the iterator not closed is reported")
+	assert.Equal(t, "test.iterator", leaks[0].Kind)
+	assert.Contains(t, leaks[0].Stack, "TestTrack", "the stack that created the iterator")
+
+	var nilTracker *Tracker
+	nilTracker.Close()
+}
@@ -0,0 +1,74 @@
+// Command record adds a sanitized response of the API to the corpus of testdata/recordings,
+// see recordings.Sanitize. It reads the protojson of the response, or of the
+// google.rpc.Status of the error with -error, e.g. the output of grpcurl or of
+// SDK.MarshalProtoJSON:
+//
+//	grpcurl ... doublecloud.clickhouse.v1.OperationService/Get | \
+//		go run ./internal/recordings/cmd/record -method /doublecloud.clickhouse.v1.OperationService/Get -name operation_done
+//
+// Review the sanitized file before committing it: the sanitizer knows the common identifying
+// values only.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	// The protos of all the services, for the response types of the methods.
+	_ "github.com/doublecloud/go-sdk"
+	"github.com/doublecloud/go-sdk/internal/recordings"
+)
+
+func main() {
+	var (
+		method = flag.String("method", "", "full gRPC method of the response, e.g. /doublecloud.clickhouse.v1.OperationService/Get")
+		name   = flag.String("name", "", "name of the recording file, without extension")
+		note   = flag.String("note", "", "what the recording is about")
+		isErr  = flag.Bool("error", false, "the input is the status of an error")
+		input  = flag.String("in", "-", "input file, - for stdin")
+		dir    = flag.String("dir", "testdata/recordings", "directory of the corpus")
+	)
+	flag.Parse()
+	if *method == "" || *name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*input, *dir, *name, *isErr, recordings.Recording{
+		Method:   *method,
+		Recorded: time.Now().UTC().Format("2006-01-02"),
+		Note:     *note,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "record:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, dir, name string, isErr bool, r recordings.Recording) error {
+	in := os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if isErr {
+		r.Status = data
+	} else {
+		r.Response = data
+	}
+	path, err := recordings.Write(dir, name, r)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
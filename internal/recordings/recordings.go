@@ -0,0 +1,181 @@
+// Package recordings holds the corpus of sanitized responses of the DoubleCloud API of
+// testdata/recordings, checked by the wire-compatibility tests of the SDK against what its
+// parsing, accessor and classification code assumes. New recordings are added with the
+// record command of cmd/record, which sanitizes them, see Sanitize.
+package recordings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Recording is a response of the API: the response message of Method, or the status of
+// the error it failed with.
+type Recording struct {
+	// Method is the full gRPC method, e.g. /doublecloud.clickhouse.v1.OperationService/Get.
+	Method string `json:"method"`
+	// Recorded is the date of the recording, e.g. 2023-05-12: the API version it tells
+	// apart once the API changes.
+	Recorded string `json:"recorded"`
+	// Note tells what the recording is about, e.g. "metadata keys in camel case".
+	Note string `json:"note,omitempty"`
+	// Response is the response message in protojson, Status the google.rpc.Status of the
+	// error, also in protojson. Exactly one of them is set.
+	Response json.RawMessage `json:"response,omitempty"`
+	Status   json.RawMessage `json:"status,omitempty"`
+
+	// Path is the file of the recording, relative to the corpus.
+	Path string `json:"-"`
+}
+
+// Load returns the recordings of the .json files of dir and its subdirectories, sorted by
+// path.
+func Load(dir string) ([]Recording, error) {
+	var recs []Recording
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var r Recording
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("recordings: %s: %w", path, err)
+		}
+		if r.Path, err = filepath.Rel(dir, path); err != nil {
+			return err
+		}
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("recordings: %s: %w", path, err)
+		}
+		recs = append(recs, r)
+		return nil
+	})
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Path < recs[j].Path })
+	return recs, err
+}
+
+func (r Recording) validate() error {
+	switch {
+	case r.Method == "":
+		return errors.New("no method")
+	case r.Recorded == "":
+		return errors.New("no recording date")
+	case (len(r.Response) == 0) == (len(r.Status) == 0):
+		return errors.New("exactly one of response and status required")
+	}
+	return nil
+}
+
+// IsError reports whether the recording is the status of an error.
+func (r Recording) IsError() bool { return len(r.Status) > 0 }
+
+// Service returns the short name of the service of the method, e.g. "clickhouse", the
+// directory of the recording in the corpus.
+func (r Recording) Service() string {
+	parts := strings.Split(strings.TrimPrefix(r.Method, "/"), ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Decode returns the response of the recording. Its fields must all be known to the
+// protos of the SDK: unknown fields fail the decoding, as a drift of the API.
+func (r Recording) Decode() (proto.Message, error) {
+	mt, err := ResponseType(r.Method)
+	if err != nil {
+		return nil, err
+	}
+	msg := mt.New().Interface()
+	if err := protojson.Unmarshal(r.Response, msg); err != nil {
+		return nil, fmt.Errorf("recordings: %s: %w", r.Path, err)
+	}
+	return msg, nil
+}
+
+// DecodeStatus returns the status of the error of the recording. Its details must be of
+// types known to the SDK.
+func (r Recording) DecodeStatus() (*status.Status, error) {
+	var st spb.Status
+	if err := protojson.Unmarshal(r.Status, &st); err != nil {
+		return nil, fmt.Errorf("recordings: %s: %w", r.Path, err)
+	}
+	return status.FromProto(&st), nil
+}
+
+// ResponseType returns the type of the response of the full gRPC method. The packages of
+// the protos of the method must be linked in the binary.
+func ResponseType(method string) (protoreflect.MessageType, error) {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("recordings: invalid method %q", method)
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("recordings: service of %s: %w", method, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("recordings: %s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(name))
+	if md == nil {
+		return nil, fmt.Errorf("recordings: unknown method %s", method)
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+}
+
+// Write sanitizes the recording, see Sanitize, and writes it to dir/<service>/<name>.json,
+// returning the path of the file. The response is checked to decode, see Decode.
+func Write(dir, name string, r Recording) (string, error) {
+	var err error
+	if r.Response, err = sanitizeRaw(r.Response); err != nil {
+		return "", err
+	}
+	if r.Status, err = sanitizeRaw(r.Status); err != nil {
+		return "", err
+	}
+	if err := r.validate(); err != nil {
+		return "", fmt.Errorf("recordings: %w", err)
+	}
+	if r.IsError() {
+		_, err = r.DecodeStatus()
+	} else {
+		_, err = r.Decode()
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := marshalIndent(r)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, r.Service(), name+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func sanitizeRaw(data json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	return Sanitize(data)
+}
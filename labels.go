@@ -0,0 +1,97 @@
+package dcsdk
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// labelsField is the request field default labels are merged into.
+const labelsField protoreflect.Name = "labels"
+
+type labelsKey struct{}
+
+// ContextWithLabels returns a context whose create and update calls get the labels merged
+// into the labels of the request, over Config.DefaultLabels and labels of outer contexts.
+// Labels set in the request win per key. Requests without labels are sent unchanged.
+func ContextWithLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := map[string]string{}
+	for k, v := range contextLabels(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsKey{}, merged)
+}
+
+func contextLabels(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+// labelsFields caches the labels field of request types by message name, nil for
+// requests without a map<string, string> labels field.
+var labelsFields sync.Map
+
+func labelsFieldOf(desc protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	if v, ok := labelsFields.Load(desc.FullName()); ok {
+		fd, _ := v.(protoreflect.FieldDescriptor)
+		return fd
+	}
+	fd := desc.Fields().ByName(labelsField)
+	if fd == nil || !fd.IsMap() || fd.MapKey().Kind() != protoreflect.StringKind || fd.MapValue().Kind() != protoreflect.StringKind {
+		fd = nil
+	}
+	labelsFields.Store(desc.FullName(), fd)
+	return fd
+}
+
+// labelledMethod reports whether the full gRPC method creates or updates a resource.
+func labelledMethod(method string) bool {
+	name := method[strings.LastIndex(method, "/")+1:]
+	return strings.HasPrefix(name, "Create") || strings.HasPrefix(name, "Update")
+}
+
+// interceptLabels merges Config.DefaultLabels and labels of the context into create and update requests.
+func (sdk *SDK) interceptLabels(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if labelledMethod(method) {
+		req = withLabels(req, sdk.callConfig(ctx).DefaultLabels, contextLabels(ctx))
+	}
+	return invoker(ctx, method, req, reply, conn, opts...)
+}
+
+// withLabels returns the request with the labels merged into its labels field, the layers
+// in increasing precedence and labels of the request last. The caller's request is not modified.
+func withLabels(req interface{}, layers ...map[string]string) interface{} {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return req
+	}
+	fd := labelsFieldOf(msg.ProtoReflect().Descriptor())
+	if fd == nil {
+		return req
+	}
+	present := msg.ProtoReflect().Get(fd).Map()
+	var labelled proto.Message
+	for _, layer := range layers {
+		for k, v := range layer {
+			key := protoreflect.ValueOfString(k).MapKey()
+			if present.Has(key) {
+				continue
+			}
+			if labelled == nil {
+				labelled = proto.Clone(msg)
+			}
+			labelled.ProtoReflect().Mutable(fd).Map().Set(key, protoreflect.ValueOfString(v))
+		}
+	}
+	if labelled == nil {
+		return req
+	}
+	return labelled
+}
@@ -0,0 +1,131 @@
+package dcsdk
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// labelRecorder records the requests it receives.
+type labelRecorder struct {
+	mu       sync.Mutex
+	requests []proto.Message
+}
+
+func (r *labelRecorder) record(req proto.Message) (*dcv1.Operation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	return &dcv1.Operation{Id: "op1", Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (r *labelRecorder) last() proto.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[len(r.requests)-1]
+}
+
+type labelRecordingTransfers struct {
+	transfer.UnimplementedTransferServiceServer
+	*labelRecorder
+}
+
+func (s labelRecordingTransfers) Create(ctx context.Context, req *transfer.CreateTransferRequest) (*dcv1.Operation, error) {
+	return s.record(req)
+}
+
+func (s labelRecordingTransfers) Update(ctx context.Context, req *transfer.UpdateTransferRequest) (*dcv1.Operation, error) {
+	return s.record(req)
+}
+
+func (s labelRecordingTransfers) List(ctx context.Context, req *transfer.ListTransfersRequest) (*transfer.ListTransfersResponse, error) {
+	_, _ = s.record(req)
+	return &transfer.ListTransfersResponse{}, nil
+}
+
+type labelRecordingEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+	*labelRecorder
+}
+
+func (s labelRecordingEndpoints) Create(ctx context.Context, req *transfer.CreateEndpointRequest) (*dcv1.Operation, error) {
+	return s.record(req)
+}
+
+func (s labelRecordingEndpoints) Update(ctx context.Context, req *transfer.UpdateEndpointRequest) (*dcv1.Operation, error) {
+	return s.record(req)
+}
+
+type labelRecordingClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	*labelRecorder
+}
+
+func (s labelRecordingClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	return s.record(req)
+}
+
+func newLabelsTestSDK(t *testing.T) (*SDK, *labelRecorder) {
+	rec := &labelRecorder{}
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials:   NewIAMTokenCredentials("test-token"),
+		DefaultLabels: map[string]string{"team": "data", "cost-center": "42"},
+	}, func(s *grpc.Server) {
+		transfer.RegisterTransferServiceServer(s, labelRecordingTransfers{labelRecorder: rec})
+		transfer.RegisterEndpointServiceServer(s, labelRecordingEndpoints{labelRecorder: rec})
+		clickhouse.RegisterClusterServiceServer(s, labelRecordingClusters{labelRecorder: rec})
+	})
+	return sdk, rec
+}
+
+func TestLabels_Transfer(t *testing.T) {
+	sdk, rec := newLabelsTestSDK(t)
+	ctx := ContextWithLabels(context.Background(), map[string]string{"team": "analytics", "env": "prod"})
+
+	req := &transfer.CreateTransferRequest{Name: "t", Labels: map[string]string{"env": "dev"}}
+	_, err := sdk.Transfer().Transfer().Create(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "analytics", "cost-center": "42", "env": "dev"}, rec.last().(*transfer.CreateTransferRequest).Labels)
+	assert.Equal(t, map[string]string{"env": "dev"}, req.Labels, "the caller's request must not be modified")
+
+	_, err = sdk.Transfer().Transfer().Update(context.Background(), &transfer.UpdateTransferRequest{TransferId: "dtt1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "data", "cost-center": "42"}, rec.last().(*transfer.UpdateTransferRequest).Labels)
+
+	// Only create and update requests are labelled.
+	_, err = sdk.Transfer().Transfer().List(ctx, &transfer.ListTransfersRequest{ProjectId: "p1"})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&transfer.ListTransfersRequest{ProjectId: "p1"}, rec.last()))
+}
+
+func TestLabels_Endpoint(t *testing.T) {
+	sdk, rec := newLabelsTestSDK(t)
+	ctx := ContextWithLabels(context.Background(), map[string]string{"env": "prod"})
+	ctx = ContextWithLabels(ctx, map[string]string{"env": "stage"})
+
+	_, err := sdk.Transfer().Endpoint().Create(ctx, &transfer.CreateEndpointRequest{Name: "e", Labels: map[string]string{"cost-center": "7"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "data", "cost-center": "7", "env": "stage"}, rec.last().(*transfer.CreateEndpointRequest).Labels)
+
+	_, err = sdk.Transfer().Endpoint().Update(ctx, &transfer.UpdateEndpointRequest{EndpointId: "dte1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "data", "cost-center": "42", "env": "stage"}, rec.last().(*transfer.UpdateEndpointRequest).Labels)
+}
+
+func TestLabels_ClickHouseSkipped(t *testing.T) {
+	sdk, rec := newLabelsTestSDK(t)
+	ctx := ContextWithLabels(context.Background(), map[string]string{"env": "prod"})
+
+	req := &clickhouse.CreateClusterRequest{ProjectId: "p1", Name: "c"}
+	_, err := sdk.ClickHouse().Cluster().Create(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(req, rec.last()), "requests without labels are sent unchanged")
+}
@@ -0,0 +1,15 @@
+package dcsdk
+
+import (
+	"context"
+	"time"
+
+	"github.com/doublecloud/go-sdk/pkg/locale"
+)
+
+// ContextWithLocale returns ctx carrying the language, a BCP 47 tag such as "en" or "ru",
+// and the time zone of the human-facing output of the SDK calls made with it, e.g.
+// MaintenanceInfo, see package locale. The output falls back to English and UTC.
+func ContextWithLocale(ctx context.Context, lang string, tz *time.Location) context.Context {
+	return locale.NewContext(ctx, locale.New(lang, tz))
+}
@@ -0,0 +1,262 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	dcsdk "github.com/doublecloud/go-sdk"
+	"github.com/doublecloud/go-sdk/pkg/paging"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+)
+
+// Action is what Apply did to an object of the manifest.
+type Action int
+
+const (
+	ActionCreated Action = iota
+	ActionUpdated
+	ActionUnchanged
+	ActionFailed
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionCreated:
+		return "created"
+	case ActionUpdated:
+		return "updated"
+	case ActionUnchanged:
+		return "unchanged"
+	case ActionFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	KindEndpoint = "endpoint"
+	KindTransfer = "transfer"
+)
+
+// ObjectResult is the outcome of applying a single object of the manifest.
+type ObjectResult struct {
+	// Kind is KindEndpoint or KindTransfer.
+	Kind string
+	Name string
+	// ID is the ID of the object, empty if it was not found nor created.
+	ID     string
+	Action Action
+	// Changes are the changes made to an updated object, with sensitive values redacted.
+	Changes []specutil.FieldChange
+	Err     error
+}
+
+// ApplyReport lists the outcomes of the source endpoint, the target endpoint and the transfer,
+// in this order.
+type ApplyReport struct {
+	Objects []ObjectResult
+}
+
+// Failed reports whether any object failed.
+func (r *ApplyReport) Failed() bool {
+	for _, o := range r.Objects {
+		if o.Action == ActionFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply makes the project match the manifest. Endpoints and the transfer are matched by name:
+// missing ones are created, existing ones are updated if they differ from the manifest and
+// left as is otherwise. Apply waits for every operation it starts, so applying the same
+// manifest again reports every object unchanged.
+//
+// Source, target and type of an existing transfer can't be updated: such a transfer fails
+// and must be deleted to apply the manifest. The transfer also fails if an endpoint does.
+// The returned error combines the errors of the failed objects; the report is returned
+// in any case.
+func Apply(ctx context.Context, sdk *dcsdk.SDK, m *PipelineManifest, opts ...grpc.CallOption) (*ApplyReport, error) {
+	a := &applier{sdk: sdk, projectID: m.ProjectID, opts: opts}
+	source := a.endpoint(ctx, &m.Source)
+	target := a.endpoint(ctx, &m.Target)
+	report := &ApplyReport{Objects: []ObjectResult{source, target, a.transfer(ctx, &m.Transfer, source, target)}}
+
+	var errs error
+	for _, o := range report.Objects {
+		if o.Err != nil {
+			errs = multierror.Append(errs, sdkerrors.WithMessagef(o.Err, "%s %s", o.Kind, o.Name))
+		}
+	}
+	return report, errs
+}
+
+type applier struct {
+	sdk       *dcsdk.SDK
+	projectID string
+	opts      []grpc.CallOption
+}
+
+func (a *applier) endpoint(ctx context.Context, e *EndpointManifest) ObjectResult {
+	res := ObjectResult{Kind: KindEndpoint, Name: e.Name}
+	desired := e.CreateRequest(a.projectID)
+	existing, err := a.findEndpoint(ctx, e.Name)
+	if err != nil {
+		return res.fail(err)
+	}
+	if existing == nil {
+		op, err := a.sdk.WrapOperation(a.sdk.Transfer().Endpoint().Create(ctx, desired, a.opts...))
+		if err == nil {
+			err = op.Wait(ctx, a.opts...)
+		}
+		if err != nil {
+			return res.fail(err)
+		}
+		res.ID, res.Action = op.ResourceId(), ActionCreated
+		return res
+	}
+
+	res.ID = existing.GetId()
+	current := &transfer.CreateEndpointRequest{
+		ProjectId:   existing.GetProjectId(),
+		Name:        existing.GetName(),
+		Description: existing.GetDescription(),
+		Labels:      existing.GetLabels(),
+		Settings:    existing.GetSettings(),
+	}
+	res.Changes = specutil.Diff(current, desired)
+	if len(res.Changes) == 0 {
+		res.Action = ActionUnchanged
+		return res
+	}
+	op, err := a.sdk.WrapOperation(a.sdk.Transfer().Endpoint().Update(ctx, &transfer.UpdateEndpointRequest{
+		EndpointId:  existing.GetId(),
+		Name:        desired.GetName(),
+		Description: desired.GetDescription(),
+		Labels:      desired.GetLabels(),
+		Settings:    desired.GetSettings(),
+	}, a.opts...))
+	if err == nil {
+		err = op.Wait(ctx, a.opts...)
+	}
+	if err != nil {
+		return res.fail(err)
+	}
+	res.Action = ActionUpdated
+	return res
+}
+
+func (a *applier) transfer(ctx context.Context, t *TransferManifest, source, target ObjectResult) ObjectResult {
+	res := ObjectResult{Kind: KindTransfer, Name: t.Name}
+	for _, e := range []ObjectResult{source, target} {
+		if e.Action == ActionFailed {
+			return res.fail(fmt.Errorf("endpoint %s failed", e.Name))
+		}
+	}
+	desired := t.CreateRequest(a.projectID, source.ID, target.ID)
+	existing, err := a.findTransfer(ctx, t.Name)
+	if err != nil {
+		return res.fail(err)
+	}
+	if existing == nil {
+		op, err := a.sdk.WrapOperation(a.sdk.Transfer().Transfer().Create(ctx, desired, a.opts...))
+		if err == nil {
+			err = op.Wait(ctx, a.opts...)
+		}
+		if err != nil {
+			return res.fail(err)
+		}
+		res.ID, res.Action = op.ResourceId(), ActionCreated
+		return res
+	}
+
+	res.ID = existing.GetId()
+	switch {
+	case existing.GetSource().GetId() != source.ID:
+		return res.fail(fmt.Errorf("source endpoint of transfer %s is %s, not %s, and can't be updated", res.ID, existing.GetSource().GetId(), source.ID))
+	case existing.GetTarget().GetId() != target.ID:
+		return res.fail(fmt.Errorf("target endpoint of transfer %s is %s, not %s, and can't be updated", res.ID, existing.GetTarget().GetId(), target.ID))
+	case existing.GetType() != desired.GetType():
+		return res.fail(fmt.Errorf("type of transfer %s is %s, not %s, and can't be updated", res.ID, existing.GetType(), desired.GetType()))
+	}
+	update := &transfer.UpdateTransferRequest{
+		TransferId:  existing.GetId(),
+		Name:        desired.GetName(),
+		Description: desired.GetDescription(),
+		Labels:      desired.GetLabels(),
+	}
+	current := &transfer.UpdateTransferRequest{
+		TransferId:  existing.GetId(),
+		Name:        existing.GetName(),
+		Description: existing.GetDescription(),
+		Labels:      existing.GetLabels(),
+	}
+	res.Changes = specutil.Diff(current, update)
+	if len(res.Changes) == 0 {
+		res.Action = ActionUnchanged
+		return res
+	}
+	op, err := a.sdk.WrapOperation(a.sdk.Transfer().Transfer().Update(ctx, update, a.opts...))
+	if err == nil {
+		err = op.Wait(ctx, a.opts...)
+	}
+	if err != nil {
+		return res.fail(err)
+	}
+	res.Action = ActionUpdated
+	return res
+}
+
+func (r ObjectResult) fail(err error) ObjectResult {
+	r.Action, r.Err = ActionFailed, err
+	return r
+}
+
+// findEndpoint returns the endpoint of the project with the given name, nil if there is none.
+func (a *applier) findEndpoint(ctx context.Context, name string) (*transfer.Endpoint, error) {
+	endpoints, err := paging.New(ctx, func(ctx context.Context, p *dcv1.Paging) ([]*transfer.Endpoint, *dcv1.NextPage, error) {
+		resp, err := a.sdk.Transfer().Endpoint().List(ctx, &transfer.ListEndpointsRequest{ProjectId: a.projectID, Page: p}, a.opts...)
+		return resp.GetEndpoints(), resp.GetNextPage(), err
+	}).TakeAll()
+	if err != nil {
+		return nil, sdkerrors.WithMessage(err, "list endpoints")
+	}
+	return findByName(endpoints, name, (*transfer.Endpoint).GetName)
+}
+
+// findTransfer returns the transfer of the project with the given name, nil if there is none.
+func (a *applier) findTransfer(ctx context.Context, name string) (*transfer.Transfer, error) {
+	transfers, err := paging.New(ctx, func(ctx context.Context, p *dcv1.Paging) ([]*transfer.Transfer, *dcv1.NextPage, error) {
+		resp, err := a.sdk.Transfer().Transfer().List(ctx, &transfer.ListTransfersRequest{ProjectId: a.projectID, Page: p}, a.opts...)
+		return resp.GetTransfers(), &dcv1.NextPage{Token: resp.GetNextPageToken()}, err
+	}).TakeAll()
+	if err != nil {
+		return nil, sdkerrors.WithMessage(err, "list transfers")
+	}
+	return findByName(transfers, name, (*transfer.Transfer).GetName)
+}
+
+// findByName fails if several objects have the name: none of them is known to be the manifest one.
+func findByName[T proto.Message](objects []T, name string, nameOf func(T) string) (T, error) {
+	var found T
+	var n int
+	for _, o := range objects {
+		if nameOf(o) == name {
+			found = o
+			n++
+		}
+	}
+	if n > 1 {
+		var zero T
+		return zero, fmt.Errorf("%d objects named %q", n, name)
+	}
+	return found, nil
+}
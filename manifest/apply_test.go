@@ -0,0 +1,125 @@
+package manifest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/doublecloud/go-sdk/pkg/specutil"
+	"github.com/doublecloud/go-sdk/sdktest"
+)
+
+func loadManifest(t *testing.T, name string) *PipelineManifest {
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+	m, err := Load(f)
+	require.NoError(t, err)
+	return m
+}
+
+func actions(r *ApplyReport) map[string]Action {
+	res := map[string]Action{}
+	for _, o := range r.Objects {
+		res[o.Kind+" "+o.Name] = o.Action
+	}
+	return res
+}
+
+func TestApply_Idempotent(t *testing.T) {
+	srv := sdktest.New(t)
+	sdk := srv.SDK(t)
+	ctx := context.Background()
+	m := loadManifest(t, "pipeline.yaml")
+
+	report, err := Apply(ctx, sdk, m)
+	require.NoError(t, err)
+	assert.False(t, report.Failed())
+	assert.Equal(t, map[string]Action{
+		"endpoint orders-pg": ActionCreated,
+		"endpoint orders-ch": ActionCreated,
+		"transfer orders":    ActionCreated,
+	}, actions(report))
+	tr := srv.Transfer.Transfer(report.Objects[2].ID)
+	require.NotNil(t, tr)
+	assert.Equal(t, report.Objects[0].ID, tr.Source.GetId())
+	assert.Equal(t, report.Objects[1].ID, tr.Target.GetId())
+	assert.Equal(t, transfer.TransferType_SNAPSHOT_AND_INCREMENT, tr.Type)
+
+	again, err := Apply(ctx, sdk, m)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Action{
+		"endpoint orders-pg": ActionUnchanged,
+		"endpoint orders-ch": ActionUnchanged,
+		"transfer orders":    ActionUnchanged,
+	}, actions(again))
+	for i, o := range again.Objects {
+		assert.Equal(t, report.Objects[i].ID, o.ID)
+	}
+}
+
+func TestApply_Updates(t *testing.T) {
+	srv := sdktest.New(t)
+	sdk := srv.SDK(t)
+	ctx := context.Background()
+	m := loadManifest(t, "pipeline.yaml")
+	_, err := Apply(ctx, sdk, m)
+	require.NoError(t, err)
+
+	m.Source.Settings.GetPostgresSource().Password = &endpoint.Secret{Value: &endpoint.Secret_Raw{Raw: "hunter3"}}
+	m.Target.Description = "Analytics"
+	m.Transfer.Labels = map[string]string{"team": "analytics"}
+	report, err := Apply(ctx, sdk, m)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Action{
+		"endpoint orders-pg": ActionUpdated,
+		"endpoint orders-ch": ActionUpdated,
+		"transfer orders":    ActionUpdated,
+	}, actions(report))
+	assert.Equal(t, []specutil.FieldChange{
+		{Path: "settings.postgres_source.password.raw", Old: "[REDACTED]", New: "[REDACTED]"},
+	}, report.Objects[0].Changes)
+	assert.Equal(t, []specutil.FieldChange{{Path: "description", Old: `""`, New: `"Analytics"`}}, report.Objects[1].Changes)
+	assert.Equal(t, []specutil.FieldChange{{Path: `labels["team"]`, Old: `"data"`, New: `"analytics"`}}, report.Objects[2].Changes)
+
+	assert.Equal(t, "hunter3", srv.Transfer.Endpoint(report.Objects[0].ID).GetSettings().GetPostgresSource().GetPassword().GetRaw())
+	assert.Equal(t, "Analytics", srv.Transfer.Endpoint(report.Objects[1].ID).GetDescription())
+	assert.Equal(t, "analytics", srv.Transfer.Transfer(report.Objects[2].ID).GetLabels()["team"])
+}
+
+func TestApply_Failures(t *testing.T) {
+	srv := sdktest.New(t)
+	sdk := srv.SDK(t)
+	ctx := context.Background()
+	m := loadManifest(t, "pipeline.yaml")
+	_, err := Apply(ctx, sdk, m)
+	require.NoError(t, err)
+
+	m.Transfer.Type = transfer.TransferType_SNAPSHOT_ONLY
+	report, err := Apply(ctx, sdk, m)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "transfer orders: type of transfer")
+	assert.True(t, report.Failed())
+	assert.Equal(t, ActionUnchanged, report.Objects[0].Action)
+	assert.Equal(t, ActionFailed, report.Objects[2].Action)
+
+	// A second endpoint with the name of the source makes the match ambiguous.
+	op, err := sdk.WrapOperation(sdk.Transfer().Endpoint().Create(ctx, m.Source.CreateRequest(m.ProjectID)))
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(ctx))
+	report, err = Apply(ctx, sdk, m)
+	require.Error(t, err)
+	assert.Equal(t, map[string]Action{
+		"endpoint orders-pg": ActionFailed,
+		"endpoint orders-ch": ActionUnchanged,
+		"transfer orders":    ActionFailed,
+	}, actions(report))
+	assert.EqualError(t, report.Objects[0].Err, `2 objects named "orders-pg"`)
+	assert.EqualError(t, report.Objects[2].Err, "endpoint orders-pg failed")
+}
@@ -0,0 +1,234 @@
+// Package manifest creates Data Transfer pipelines, a source endpoint, a target endpoint and
+// a transfer between them, from declarative YAML manifests:
+//
+//	project_id: prj1
+//	source:
+//	  name: orders-pg
+//	  settings:
+//	    postgres_source:
+//	      database: orders
+//	      include_tables: [public.orders]
+//	target:
+//	  name: orders-ch
+//	  settings:
+//	    clickhouse_target:
+//	      clickhouse_cluster_name: analytics
+//	transfer:
+//	  name: orders
+//	  type: SNAPSHOT_AND_INCREMENT
+//
+// Endpoint settings are doublecloud.transfer.v1.EndpointSettings in the protobuf JSON mapping,
+// with the original field names or their lowerCamelCase JSON names.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidManifest is matched by errors.Is for every *ValidationError.
+var ErrInvalidManifest = errors.New("manifest: invalid manifest")
+
+// ValidationError is returned by Load for manifests violating the schema.
+type ValidationError struct {
+	// Problems describe the rejected fields, e.g. "source.name: required".
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "manifest: invalid manifest: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrInvalidManifest }
+
+// PipelineManifest describes a transfer and its endpoints, all in the same project.
+// Objects are identified by name within the project.
+type PipelineManifest struct {
+	ProjectID string
+	Source    EndpointManifest
+	Target    EndpointManifest
+	Transfer  TransferManifest
+}
+
+// EndpointManifest describes an endpoint.
+type EndpointManifest struct {
+	Name        string
+	Description string
+	Labels      map[string]string
+	Settings    *transfer.EndpointSettings
+}
+
+// TransferManifest describes a transfer between the endpoints of the manifest.
+type TransferManifest struct {
+	Name        string
+	Description string
+	Labels      map[string]string
+	Type        transfer.TransferType
+}
+
+// CreateRequest returns the request creating the endpoint in the project.
+func (e *EndpointManifest) CreateRequest(projectID string) *transfer.CreateEndpointRequest {
+	return &transfer.CreateEndpointRequest{
+		ProjectId:   projectID,
+		Name:        e.Name,
+		Description: e.Description,
+		Labels:      e.Labels,
+		Settings:    e.Settings,
+	}
+}
+
+// CreateRequest returns the request creating the transfer between the given endpoints in the project.
+func (t *TransferManifest) CreateRequest(projectID, sourceID, targetID string) *transfer.CreateTransferRequest {
+	return &transfer.CreateTransferRequest{
+		ProjectId:   projectID,
+		SourceId:    sourceID,
+		TargetId:    targetID,
+		Name:        t.Name,
+		Description: t.Description,
+		Labels:      t.Labels,
+		Type:        t.Type,
+	}
+}
+
+type pipelineYAML struct {
+	ProjectID string       `yaml:"project_id"`
+	Source    endpointYAML `yaml:"source"`
+	Target    endpointYAML `yaml:"target"`
+	Transfer  transferYAML `yaml:"transfer"`
+}
+
+type endpointYAML struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Labels      map[string]string `yaml:"labels"`
+	Settings    yaml.Node         `yaml:"settings"`
+}
+
+type transferYAML struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Labels      map[string]string `yaml:"labels"`
+	Type        string            `yaml:"type"`
+}
+
+// Load reads a manifest. Malformed YAML and unknown keys are reported as is, manifests
+// violating the schema with *ValidationError listing all the problems.
+func Load(r io.Reader) (*PipelineManifest, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var raw pipelineYAML
+	if err := dec.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return nil, &ValidationError{Problems: []string{"empty manifest"}}
+		}
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	v := &validator{}
+	if raw.ProjectID == "" {
+		v.fail("project_id", "required")
+	}
+	m := &PipelineManifest{
+		ProjectID: raw.ProjectID,
+		Source:    v.endpoint("source", raw.Source, "_source"),
+		Target:    v.endpoint("target", raw.Target, "_target"),
+		Transfer:  v.transfer(raw.Transfer),
+	}
+	if m.Source.Name != "" && m.Source.Name == m.Target.Name {
+		v.fail("target.name", "must differ from source.name")
+	}
+	if len(v.problems) > 0 {
+		return nil, &ValidationError{Problems: v.problems}
+	}
+	return m, nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(field, format string, args ...interface{}) {
+	v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+// endpoint converts the endpoint, its settings must be of the kind with the given suffix,
+// e.g. "_source" for postgres_source.
+func (v *validator) endpoint(field string, raw endpointYAML, kind string) EndpointManifest {
+	e := EndpointManifest{Name: raw.Name, Description: raw.Description, Labels: raw.Labels}
+	if e.Name == "" {
+		v.fail(field+".name", "required")
+	}
+	if raw.Settings.IsZero() {
+		v.fail(field+".settings", "required")
+		return e
+	}
+	var settings interface{}
+	if err := raw.Settings.Decode(&settings); err != nil {
+		v.fail(field+".settings", "%v", err)
+		return e
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		v.fail(field+".settings", "%v", err)
+		return e
+	}
+	e.Settings = &transfer.EndpointSettings{}
+	if err := protojson.Unmarshal(data, e.Settings); err != nil {
+		v.fail(field+".settings", "%v", err)
+		return e
+	}
+	m := e.Settings.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("settings"))
+	switch {
+	case fd == nil:
+		v.fail(field+".settings", "endpoint kind required, one of %s", strings.Join(endpointKinds(kind), ", "))
+	case !strings.HasSuffix(string(fd.Name()), kind):
+		v.fail(field+".settings", "%s is not a %s endpoint kind, expected one of %s", fd.Name(), field, strings.Join(endpointKinds(kind), ", "))
+	}
+	return e
+}
+
+// endpointKinds lists the endpoint kinds with the given suffix.
+func endpointKinds(suffix string) []string {
+	var kinds []string
+	fields := (&transfer.EndpointSettings{}).ProtoReflect().Descriptor().Oneofs().ByName("settings").Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if name := string(fields.Get(i).Name()); strings.HasSuffix(name, suffix) {
+			kinds = append(kinds, name)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (v *validator) transfer(raw transferYAML) TransferManifest {
+	t := TransferManifest{Name: raw.Name, Description: raw.Description, Labels: raw.Labels}
+	if t.Name == "" {
+		v.fail("transfer.name", "required")
+	}
+	var types []string
+	for name, value := range transfer.TransferType_value {
+		if value != int32(transfer.TransferType_TRANSFER_TYPE_UNSPECIFIED) {
+			types = append(types, name)
+		}
+	}
+	sort.Strings(types)
+	typ, ok := transfer.TransferType_value[raw.Type]
+	switch {
+	case raw.Type == "":
+		v.fail("transfer.type", "required, one of %s", strings.Join(types, ", "))
+	case !ok || typ == int32(transfer.TransferType_TRANSFER_TYPE_UNSPECIFIED):
+		v.fail("transfer.type", "unknown type %q, expected one of %s", raw.Type, strings.Join(types, ", "))
+	default:
+		t.Type = transfer.TransferType(typ)
+	}
+	return t
+}
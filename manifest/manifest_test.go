@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the manifest tests")
+
+// TestLoad checks the requests built from every manifest of testdata, or the error
+// of Load, against the .golden file of the manifest. Run with -update to rewrite them.
+func TestLoad(t *testing.T) {
+	manifests, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, manifests)
+	for _, path := range manifests {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			defer f.Close()
+
+			var got []byte
+			m, err := Load(f)
+			if err != nil {
+				// protobuf randomizes the spaces of its error messages between builds.
+				got = []byte(strings.ReplaceAll(err.Error(), " ", " ") + "\n")
+			} else {
+				got = goldenRequests(t, m)
+			}
+
+			golden := strings.TrimSuffix(path, ".yaml") + ".golden"
+			if *update {
+				require.NoError(t, os.WriteFile(golden, got, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+// goldenRequests renders the create requests of the manifest as indented JSON with sorted keys.
+func goldenRequests(t *testing.T, m *PipelineManifest) []byte {
+	requests := map[string]proto.Message{
+		"source":   m.Source.CreateRequest(m.ProjectID),
+		"target":   m.Target.CreateRequest(m.ProjectID),
+		"transfer": m.Transfer.CreateRequest(m.ProjectID, "source-id", "target-id"),
+	}
+	doc := map[string]interface{}{}
+	for name, req := range requests {
+		data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(req)
+		require.NoError(t, err)
+		var v interface{}
+		require.NoError(t, json.Unmarshal(data, &v))
+		doc[name] = v
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	require.NoError(t, err)
+	return append(data, '\n')
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrInvalidManifest)
+
+	_, err = Load(strings.NewReader("project_id: [prj1]"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidManifest)
+
+	_, err = Load(bytes.NewReader([]byte("source: {}\n")))
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{
+		"project_id: required",
+		"source.name: required",
+		"source.settings: required",
+		"target.name: required",
+		"target.settings: required",
+		"transfer.name: required",
+		"transfer.type: required, one of INCREMENT_ONLY, SNAPSHOT_AND_INCREMENT, SNAPSHOT_ONLY",
+	}, invalid.Problems)
+}
@@ -0,0 +1,270 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+const (
+	// DefaultMetricLabelLimit is the default of Config.MetricLabelLimit.
+	DefaultMetricLabelLimit = 100
+	// OtherMethod is the method label of calls of methods missing in MethodCatalog.
+	OtherMethod = "other"
+	// OverflowLabelValue replaces the label values over the limit with MetricLabelOverflowDrop.
+	OverflowLabelValue = "other"
+)
+
+// MetricLabelOverflow is what becomes of the values of a label of ContextWithMetricLabels once
+// the label had Config.MetricLabelLimit distinct values.
+type MetricLabelOverflow int
+
+const (
+	// MetricLabelOverflowDrop replaces the values with OverflowLabelValue.
+	MetricLabelOverflowDrop MetricLabelOverflow = iota
+	// MetricLabelOverflowHash replaces the values with one of MetricLabelLimit hash buckets,
+	// "#0" to "#<limit-1>", keeping the values apart in part.
+	MetricLabelOverflowHash
+)
+
+// CallMetric is a call sent to the API, see MetricsRecorder. Its labels have bounded
+// cardinality: operation and resource IDs are never among them.
+type CallMetric struct {
+	Kind ServiceKind
+	// Method is the full gRPC method out of MethodCatalog, or OtherMethod.
+	Method string
+	Code   codes.Code
+	// Labels are the labels of ContextWithMetricLabels, bounded by Config.MetricLabelLimit.
+	Labels map[string]string
+	// Duration is the time of the call, including its retries.
+	Duration time.Duration
+}
+
+// MetricsRecorder records the calls of the SDK, see Config.Metrics.
+type MetricsRecorder interface {
+	RecordCall(m CallMetric)
+}
+
+// MetricsExemplarRecorder is implemented by a MetricsRecorder that attaches the operation
+// IDs to its metrics out of the labels, e.g. as exemplars or trace attributes. It is called
+// after RecordCall for the calls returning an operation and the operation polls.
+type MetricsExemplarRecorder interface {
+	RecordExemplar(m CallMetric, operationID string)
+}
+
+type metricLabelsKey struct{}
+
+// ContextWithMetricLabels returns a context whose calls are recorded with the labels, over
+// labels of outer contexts. Every label gets Config.MetricLabelLimit distinct values at
+// most, see MetricLabelOverflow, and so do the label names.
+func ContextWithMetricLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := map[string]string{}
+	for k, v := range contextMetricLabels(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metricLabelsKey{}, merged)
+}
+
+func contextMetricLabels(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(metricLabelsKey{}).(map[string]string)
+	return labels
+}
+
+// labelGuard bounds the distinct names and values of the labels of ContextWithMetricLabels.
+type labelGuard struct {
+	limit    int
+	overflow MetricLabelOverflow
+
+	mu     sync.Mutex
+	values map[string]map[string]bool
+}
+
+func newLabelGuard(limit int, overflow MetricLabelOverflow) *labelGuard {
+	if limit <= 0 {
+		limit = DefaultMetricLabelLimit
+	}
+	return &labelGuard{limit: limit, overflow: overflow, values: map[string]map[string]bool{}}
+}
+
+// bound returns the labels with the names over the limit dropped and the values over the
+// limit replaced. It returns nil for no labels.
+func (g *labelGuard) bound(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	bounded := make(map[string]string, len(labels))
+	for k, v := range labels {
+		values, ok := g.values[k]
+		if !ok {
+			if len(g.values) >= g.limit {
+				continue
+			}
+			values = map[string]bool{}
+			g.values[k] = values
+		}
+		if !values[v] && len(values) >= g.limit {
+			v = g.overflowValue(v)
+		} else {
+			values[v] = true
+		}
+		bounded[k] = v
+	}
+	return bounded
+}
+
+func (g *labelGuard) overflowValue(v string) string {
+	if g.overflow != MetricLabelOverflowHash {
+		return OverflowLabelValue
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(v))
+	return fmt.Sprintf("#%d", h.Sum32()%uint32(g.limit))
+}
+
+// methodKinds maps the gRPC services of MethodCatalog to their kind.
+var methodKinds = func() map[string]ServiceKind {
+	kinds := map[string]ServiceKind{}
+	for _, s := range catalogServices {
+		kinds[s.desc.ServiceName] = s.kind
+	}
+	return kinds
+}()
+
+// methodLabels returns the kind and the method label of the full gRPC method.
+func methodLabels(method string) (ServiceKind, string) {
+	service := strings.TrimPrefix(method, "/")
+	if i := strings.Index(service, "/"); i >= 0 {
+		service = service[:i]
+	}
+	kind, ok := methodKinds[service]
+	if !ok {
+		return "", OtherMethod
+	}
+	return kind, method
+}
+
+// callOperationID returns the ID of the operation returned or polled by the call, if any.
+func callOperationID(req, reply interface{}) string {
+	if op, ok := reply.(*dcv1.Operation); ok && op.GetId() != "" {
+		return op.GetId()
+	}
+	if poll, ok := req.(interface{ GetOperationId() string }); ok {
+		return poll.GetOperationId()
+	}
+	return ""
+}
+
+// interceptMetrics records the calls with Config.Metrics. It runs before the retry
+// interceptor, so that a call is recorded once with its final code.
+func (sdk *SDK) interceptMetrics(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	metrics := sdk.callConfig(ctx).Metrics
+	if metrics == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	started := now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	kind, methodLabel := methodLabels(method)
+	m := CallMetric{
+		Kind:     kind,
+		Method:   methodLabel,
+		Code:     status.Code(err),
+		Labels:   sdk.metricLabels.bound(contextMetricLabels(ctx)),
+		Duration: now().Sub(started),
+	}
+	_ = operation.SafeCall("metrics recorder", func() error {
+		metrics.RecordCall(m)
+		return nil
+	})
+	if exemplars, ok := metrics.(MetricsExemplarRecorder); ok {
+		if id := callOperationID(req, reply); id != "" {
+			_ = operation.SafeCall("metrics exemplar recorder", func() error {
+				exemplars.RecordExemplar(m, id)
+				return nil
+			})
+		}
+	}
+	return err
+}
+
+// MetricSeries is a series of the calls recorded by MetricsStats, by their labels.
+type MetricSeries struct {
+	Kind   ServiceKind
+	Method string
+	Code   codes.Code
+	Labels map[string]string
+	// Count is the number of calls and Duration their total time.
+	Count    int
+	Duration time.Duration
+}
+
+// MetricsStats is an in-memory MetricsRecorder aggregating the calls by their labels.
+// The zero value is ready to use.
+type MetricsStats struct {
+	mu     sync.Mutex
+	series map[string]*MetricSeries
+}
+
+var _ MetricsRecorder = &MetricsStats{}
+
+// RecordCall implements MetricsRecorder.
+func (s *MetricsStats) RecordCall(m CallMetric) {
+	key := seriesKey(m)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.series == nil {
+		s.series = map[string]*MetricSeries{}
+	}
+	series, ok := s.series[key]
+	if !ok {
+		series = &MetricSeries{Kind: m.Kind, Method: m.Method, Code: m.Code, Labels: m.Labels}
+		s.series[key] = series
+	}
+	series.Count++
+	series.Duration += m.Duration
+}
+
+// Series returns the series recorded so far, ordered by their labels.
+func (s *MetricsStats) Series() []MetricSeries {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.series))
+	for k := range s.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	series := make([]MetricSeries, len(keys))
+	for i, k := range keys {
+		series[i] = *s.series[k]
+	}
+	return series
+}
+
+func seriesKey(m CallMetric) string {
+	names := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s\x00%d", m.Kind, m.Method, m.Code)
+	for _, k := range names {
+		fmt.Fprintf(&b, "\x00%s=%s", k, m.Labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exemplarStats records the exemplars along with the series.
+type exemplarStats struct {
+	MetricsStats
+	exemplars map[string]CallMetric
+}
+
+func (s *exemplarStats) RecordExemplar(m CallMetric, operationID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exemplars[operationID] = m
+}
+
+type churnClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+}
+
+func (churnClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+	return &dcv1.Operation{Id: "cho-" + req.Name, Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func TestMetrics_BoundedUnderChurn(t *testing.T) {
+	const ops = 2000
+	stats := &exemplarStats{exemplars: map[string]CallMetric{}}
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials:      NewIAMTokenCredentials("test-token"),
+		Metrics:          stats,
+		MetricLabelLimit: 10,
+	}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, churnClusters{})
+		clickhouse.RegisterOperationServiceServer(s, fakeClickHouseOperations{})
+	})
+
+	for i := 0; i < ops; i++ {
+		ctx := ContextWithMetricLabels(context.Background(), map[string]string{
+			"team":                    "data",
+			"request":                 fmt.Sprint(i),
+			fmt.Sprintf("label%d", i): "x",
+		})
+		name := fmt.Sprint(i)
+		if i%10 == 0 {
+			name = ""
+		}
+		op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: name}))
+		if err != nil {
+			continue
+		}
+		require.NoError(t, op.Poll(ctx))
+	}
+
+	series := stats.Series()
+	// 3 methods and codes, times 10 values of request and the overflow value.
+	assert.LessOrEqual(t, len(series), 3*11)
+	var calls int
+	names := map[string]bool{}
+	for _, s := range series {
+		calls += s.Count
+		assert.Equal(t, ClickHouseServiceID, s.Kind)
+		assert.Equal(t, "data", s.Labels["team"])
+		for k, v := range s.Labels {
+			names[k] = true
+			assert.NotContains(t, v, "cho-", "operation IDs are not labels")
+		}
+	}
+	assert.Equal(t, ops+ops*9/10, calls)
+	assert.LessOrEqual(t, len(names), 10, "label names are bounded as well")
+
+	assert.Len(t, stats.exemplars, ops*9/10, "the operation IDs are in the exemplars")
+	created := stats.exemplars["cho-1234"]
+	assert.Equal(t, OverflowLabelValue, created.Labels["request"])
+}
+
+func TestMetrics_Labels(t *testing.T) {
+	stats := &MetricsStats{}
+	sdk := newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token"), Metrics: stats}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, churnClusters{})
+	})
+
+	_, err := sdk.ClickHouse().Cluster().Create(context.Background(), &clickhouse.CreateClusterRequest{})
+	require.Error(t, err)
+	_, err = sdk.ClickHouse().Cluster().Get(context.Background(), &clickhouse.GetClusterRequest{ClusterId: "ch1"})
+	require.Error(t, err)
+
+	series := stats.Series()
+	require.Len(t, series, 2)
+	assert.Equal(t, "/doublecloud.clickhouse.v1.ClusterService/Create", series[0].Method)
+	assert.Equal(t, codes.InvalidArgument, series[0].Code)
+	assert.Equal(t, "/doublecloud.clickhouse.v1.ClusterService/Get", series[1].Method)
+	assert.Equal(t, codes.Unimplemented, series[1].Code)
+	assert.Nil(t, series[1].Labels)
+	assert.Equal(t, 1, series[1].Count)
+
+	kind, method := methodLabels("/example.v1.Service/Get")
+	assert.Empty(t, kind)
+	assert.Equal(t, OtherMethod, method)
+}
+
+func TestLabelGuard(t *testing.T) {
+	drop := newLabelGuard(3, MetricLabelOverflowDrop)
+	hash := newLabelGuard(3, MetricLabelOverflowHash)
+	dropped, hashed := map[string]bool{}, map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		dropped[drop.bound(map[string]string{"id": fmt.Sprint(i)})["id"]] = true
+		hashed[hash.bound(map[string]string{"id": fmt.Sprint(i)})["id"]] = true
+	}
+	assert.Equal(t, map[string]bool{"0": true, "1": true, "2": true, OverflowLabelValue: true}, dropped)
+	assert.LessOrEqual(t, len(hashed), 6)
+	assert.Equal(t, hash.bound(map[string]string{"id": "500"}), hash.bound(map[string]string{"id": "500"}), "hashed values are stable")
+	assert.Equal(t, map[string]string{"id": "1"}, drop.bound(map[string]string{"id": "1"}), "values within the limit are kept")
+	assert.Nil(t, drop.bound(nil))
+
+	names := newLabelGuard(2, MetricLabelOverflowDrop)
+	names.bound(map[string]string{"a": "1", "b": "1"})
+	assert.Equal(t, map[string]string{"a": "1"}, names.bound(map[string]string{"a": "1", "c": "1"}))
+}
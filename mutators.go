@@ -0,0 +1,86 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// RequestMutator changes or rejects the requests of the calls before they are sent, e.g. to
+// add a label mandated by an organization or to block the regions it doesn't allow, see
+// Config.RequestMutators.
+type RequestMutator struct {
+	// Name tells the mutator in the errors, e.g. "cost-center".
+	Name string
+	// Mutate may modify req in place, or return an error to block the call. req is a copy
+	// of the request of the caller, which is not modified.
+	Mutate func(ctx context.Context, method string, req proto.Message) error
+	// Reads makes the mutator run for the read-only methods too, the Get and List ones.
+	Reads bool
+}
+
+// ErrRequestRejected is matched by errors.Is for every *RequestRejectedError.
+var ErrRequestRejected = errors.New("request rejected by a request mutator")
+
+// RequestRejectedError is returned for the calls blocked by a request mutator. It converts
+// to the status of Err, FailedPrecondition if Err has none.
+type RequestRejectedError struct {
+	Method string
+	// Mutator is the name of the mutator, Index its index in Config.RequestMutators.
+	Mutator string
+	Index   int
+	Err     error
+}
+
+func (e *RequestRejectedError) Error() string {
+	name := e.Mutator
+	if name == "" {
+		name = fmt.Sprintf("#%d", e.Index)
+	}
+	return fmt.Sprintf("request mutator %s rejected %s: %v", name, e.Method, e.Err)
+}
+
+func (e *RequestRejectedError) Unwrap() error { return e.Err }
+
+func (e *RequestRejectedError) Is(target error) bool { return target == ErrRequestRejected }
+
+func (e *RequestRejectedError) GRPCStatus() *status.Status {
+	if st, ok := status.FromError(e.Err); ok {
+		return status.New(st.Code(), e.Error())
+	}
+	return status.New(codes.FailedPrecondition, e.Error())
+}
+
+// interceptMutators runs Config.RequestMutators on a copy of the request, in order.
+func (sdk *SDK) interceptMutators(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	mutators := sdk.callConfig(ctx).RequestMutators
+	msg, ok := req.(proto.Message)
+	if len(mutators) == 0 || !ok {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+	read := isReadMethod(method)
+	var mutated proto.Message
+	for i, m := range mutators {
+		if m.Mutate == nil || (read && !m.Reads) {
+			continue
+		}
+		if mutated == nil {
+			mutated = proto.Clone(msg)
+		}
+		err := operation.SafeCall("request mutator", func() error { return m.Mutate(ctx, method, mutated) })
+		if err != nil {
+			return &RequestRejectedError{Method: method, Mutator: m.Name, Index: i, Err: err}
+		}
+	}
+	if mutated != nil {
+		req = mutated
+	}
+	return invoker(ctx, method, req, reply, conn, opts...)
+}
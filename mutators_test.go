@@ -0,0 +1,137 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func newMutatorsTestSDK(t *testing.T, mutators ...RequestMutator) (*SDK, *labelRecorder) {
+	rec := &labelRecorder{}
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials:     NewIAMTokenCredentials("test-token"),
+		DefaultLabels:   map[string]string{"team": "data", "cost-center": "42"},
+		RequestMutators: mutators,
+	}, func(s *grpc.Server) {
+		transfer.RegisterTransferServiceServer(s, labelRecordingTransfers{labelRecorder: rec})
+		clickhouse.RegisterClusterServiceServer(s, labelRecordingClusters{labelRecorder: rec})
+	})
+	return sdk, rec
+}
+
+// costCenter sets the cost-center label of the requests with labels.
+func costCenter(ctx context.Context, method string, req proto.Message) error {
+	if req, ok := req.(*transfer.CreateTransferRequest); ok {
+		if req.Labels == nil {
+			req.Labels = map[string]string{}
+		}
+		req.Labels["cost-center"] = "org-7"
+	}
+	return nil
+}
+
+// allowedRegions blocks the clusters outside of EU regions.
+func allowedRegions(ctx context.Context, method string, req proto.Message) error {
+	if req, ok := req.(*clickhouse.CreateClusterRequest); ok && req.GetRegionId() != "eu-central-1" {
+		return errors.New("region " + req.GetRegionId() + " is not allowed")
+	}
+	return nil
+}
+
+func TestRequestMutators_Mutate(t *testing.T) {
+	sdk, rec := newMutatorsTestSDK(t, RequestMutator{Name: "cost-center", Mutate: costCenter})
+	req := &transfer.CreateTransferRequest{Name: "t", Labels: map[string]string{"env": "prod"}}
+	_, err := sdk.Transfer().Transfer().Create(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "cost-center": "org-7", "team": "data"}, rec.last().(*transfer.CreateTransferRequest).GetLabels(),
+		"the labels of the mutators win over the default labels")
+	assert.Equal(t, map[string]string{"env": "prod"}, req.GetLabels(), "the request of the caller is kept")
+}
+
+func TestRequestMutators_Block(t *testing.T) {
+	sdk, rec := newMutatorsTestSDK(t,
+		RequestMutator{Name: "cost-center", Mutate: costCenter},
+		RequestMutator{Name: "regions", Mutate: allowedRegions},
+	)
+	ctx := context.Background()
+	_, err := sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "c", RegionId: "us-east-1"})
+	var rejected *RequestRejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.ErrorIs(t, err, ErrRequestRejected)
+	assert.Equal(t, "regions", rejected.Mutator)
+	assert.Equal(t, 1, rejected.Index)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.EqualError(t, err, "request mutator regions rejected /doublecloud.clickhouse.v1.ClusterService/Create: region us-east-1 is not allowed")
+	assert.Empty(t, rec.requests, "the call is not sent")
+
+	_, err = sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "c", RegionId: "eu-central-1"})
+	require.NoError(t, err)
+	assert.Len(t, rec.requests, 1)
+}
+
+func TestRequestMutators_Order(t *testing.T) {
+	var calls []string
+	mutator := func(name string, err error) RequestMutator {
+		return RequestMutator{Name: name, Mutate: func(ctx context.Context, method string, req proto.Message) error {
+			calls = append(calls, name)
+			r := req.(*transfer.CreateTransferRequest)
+			r.Description += name
+			return err
+		}}
+	}
+	sdk, rec := newMutatorsTestSDK(t, mutator("a", nil), mutator("b", nil), RequestMutator{}, mutator("c", nil))
+	_, err := sdk.Transfer().Transfer().Create(context.Background(), &transfer.CreateTransferRequest{Name: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, calls, "in the registration order")
+	assert.Equal(t, "abc", rec.last().(*transfer.CreateTransferRequest).GetDescription())
+
+	calls = nil
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) {
+		c.RequestMutators = []RequestMutator{mutator("b", status.Error(codes.PermissionDenied, "no")), mutator("a", nil)}
+	}))
+	_, err = sdk.Transfer().Transfer().Create(context.Background(), &transfer.CreateTransferRequest{Name: "t"})
+	assert.Equal(t, []string{"b"}, calls, "the mutators after the rejecting one don't run")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "the status of the mutator")
+	assert.EqualError(t, err, "request mutator b rejected /doublecloud.transfer.v1.TransferService/Create: rpc error: code = PermissionDenied desc = no")
+}
+
+func TestRequestMutators_Reads(t *testing.T) {
+	var writes, reads []string
+	sdk, rec := newMutatorsTestSDK(t,
+		RequestMutator{Name: "writes", Mutate: func(ctx context.Context, method string, req proto.Message) error {
+			writes = append(writes, method)
+			return nil
+		}},
+		RequestMutator{Name: "reads", Reads: true, Mutate: func(ctx context.Context, method string, req proto.Message) error {
+			reads = append(reads, method)
+			if req, ok := req.(*transfer.ListTransfersRequest); ok {
+				req.ProjectId = "prj-org"
+			}
+			return nil
+		}},
+	)
+	_, err := sdk.Transfer().Transfer().List(context.Background(), &transfer.ListTransfersRequest{ProjectId: "prj1"})
+	require.NoError(t, err)
+	assert.Empty(t, writes, "not flagged for reads")
+	assert.Equal(t, []string{"/doublecloud.transfer.v1.TransferService/List"}, reads)
+	assert.Equal(t, "prj-org", rec.last().(*transfer.ListTransfersRequest).GetProjectId())
+}
+
+func TestRequestMutators_Panic(t *testing.T) {
+	sdk, rec := newMutatorsTestSDK(t, RequestMutator{Name: "broken", Mutate: func(ctx context.Context, method string, req proto.Message) error {
+		panic("nil map")
+	}})
+	_, err := sdk.Transfer().Transfer().Create(context.Background(), &transfer.CreateTransferRequest{Name: "t"})
+	assert.ErrorIs(t, err, ErrRequestRejected)
+	assert.Contains(t, err.Error(), "nil map")
+	assert.Empty(t, rec.requests)
+}
@@ -0,0 +1,204 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	clickhousepb "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkapb "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkpb "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	transferpb "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/namegen"
+	"github.com/doublecloud/go-sdk/pkg/paging"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// resourceKafkaTopic is the kind of the Kafka topics, named within their cluster: they have
+// no ResourceRef.
+const resourceKafkaTopic ResourceKind = "kafka/topic"
+
+// nameConstraints are the constraints of the names of the resources by kind.
+var nameConstraints = map[ResourceKind]namegen.Constraints{
+	ResourceClickHouseCluster: namegen.ClickHouseCluster,
+	ResourceKafkaCluster:      namegen.KafkaCluster,
+	ResourceNetwork:           namegen.Network,
+	ResourceTransfer:          namegen.Transfer,
+	ResourceTransferEndpoint:  namegen.TransferEndpoint,
+	resourceKafkaTopic:        namegen.KafkaTopic,
+}
+
+// ValidateName returns *namegen.NameError if the name doesn't meet the constraints of the
+// names of the resources of the kind, see namegen.Constraints. The names of the kinds without
+// known constraints, e.g. workbooks, are valid.
+func ValidateName(kind ResourceKind, name string) error {
+	if c, ok := nameConstraints[kind]; ok {
+		return c.Validate(name)
+	}
+	return nil
+}
+
+// ErrNameTaken is matched by errors.Is for every *NameTakenError.
+var ErrNameTaken = errors.New("resource name already taken")
+
+// NameTakenError is returned by the create calls made WithNameCheck for the names taken by
+// another resource. It converts to an AlreadyExists gRPC status, like the error of the create
+// itself.
+type NameTakenError struct {
+	Kind ResourceKind
+	// ScopeID is the project of the resource, or the cluster of a Kafka topic.
+	ScopeID string
+	Name    string
+}
+
+func (e *NameTakenError) Error() string {
+	return fmt.Sprintf("%s name %q is already taken in %s", e.Kind, e.Name, e.ScopeID)
+}
+
+func (e *NameTakenError) Is(target error) bool { return target == ErrNameTaken }
+
+func (e *NameTakenError) GRPCStatus() *status.Status {
+	return status.New(codes.AlreadyExists, e.Error())
+}
+
+// CheckNameAvailable reports whether no resource of the kind in the project has the name,
+// e.g. to pick another name before a create rather than fail with AlreadyExists minutes into
+// provisioning. The name is validated first, see ValidateName. The services can't filter
+// their listings by name, so all the pages of the resources of the project are listed until
+// the name is found, bypassing the read cache. Workbooks, which can't be listed, are not
+// supported.
+//
+// The check is advisory: a resource created with the name after it still fails the create.
+func (sdk *SDK) CheckNameAvailable(ctx context.Context, kind ResourceKind, projectID, name string, opts ...grpc.CallOption) (bool, error) {
+	c, ok := nameConstraints[kind]
+	if !ok || c.Scope != namegen.ScopeProject {
+		return false, fmt.Errorf("name check of %q resources is not supported", kind)
+	}
+	if err := c.Validate(name); err != nil {
+		return false, err
+	}
+	taken, err := sdk.nameTaken(ctx, kind, projectID, name, opts)
+	if err != nil {
+		return false, sdkerrors.WithMessagef(err, "check %s name", kind)
+	}
+	return !taken, nil
+}
+
+// nameTaken reports whether a resource of the kind has the name in the scope, the project
+// or the cluster of the kind.
+func (sdk *SDK) nameTaken(ctx context.Context, kind ResourceKind, scopeID, name string, opts []grpc.CallOption) (bool, error) {
+	ctx = NoCache(ctx)
+	switch kind {
+	case ResourceClickHouseCluster:
+		return findName(ctx, name, (*clickhousepb.Cluster).GetName, func(ctx context.Context, p *dcv1.Paging) ([]*clickhousepb.Cluster, *dcv1.NextPage, error) {
+			resp, err := sdk.ClickHouse().Cluster().List(ctx, &clickhousepb.ListClustersRequest{ProjectId: scopeID, Paging: p}, opts...)
+			return resp.GetClusters(), resp.GetNextPage(), err
+		})
+	case ResourceKafkaCluster:
+		return findName(ctx, name, (*kafkapb.Cluster).GetName, func(ctx context.Context, p *dcv1.Paging) ([]*kafkapb.Cluster, *dcv1.NextPage, error) {
+			resp, err := sdk.Kafka().Cluster().List(ctx, &kafkapb.ListClustersRequest{ProjectId: scopeID, Paging: p}, opts...)
+			return resp.GetClusters(), resp.GetNextPage(), err
+		})
+	case resourceKafkaTopic:
+		return findName(ctx, name, (*kafkapb.Topic).GetName, func(ctx context.Context, p *dcv1.Paging) ([]*kafkapb.Topic, *dcv1.NextPage, error) {
+			resp, err := sdk.Kafka().Topic().List(ctx, &kafkapb.ListTopicsRequest{ClusterId: scopeID, Paging: p}, opts...)
+			return resp.GetTopics(), resp.GetNextPage(), err
+		})
+	case ResourceNetwork:
+		return findName(ctx, name, (*networkpb.Network).GetName, func(ctx context.Context, p *dcv1.Paging) ([]*networkpb.Network, *dcv1.NextPage, error) {
+			resp, err := sdk.Network().Network().List(ctx, &networkpb.ListNetworksRequest{ProjectId: scopeID, Paging: p}, opts...)
+			return resp.GetNetworks(), resp.GetNextPage(), err
+		})
+	case ResourceTransfer:
+		return findName(ctx, name, (*transferpb.Transfer).GetName, func(ctx context.Context, p *dcv1.Paging) ([]*transferpb.Transfer, *dcv1.NextPage, error) {
+			resp, err := sdk.Transfer().Transfer().List(ctx, &transferpb.ListTransfersRequest{ProjectId: scopeID, Page: p}, opts...)
+			return resp.GetTransfers(), &dcv1.NextPage{Token: resp.GetNextPageToken()}, err
+		})
+	case ResourceTransferEndpoint:
+		return findName(ctx, name, (*transferpb.Endpoint).GetName, func(ctx context.Context, p *dcv1.Paging) ([]*transferpb.Endpoint, *dcv1.NextPage, error) {
+			resp, err := sdk.Transfer().Endpoint().List(ctx, &transferpb.ListEndpointsRequest{ProjectId: scopeID, Page: p}, opts...)
+			return resp.GetEndpoints(), resp.GetNextPage(), err
+		})
+	}
+	return false, fmt.Errorf("name check of %q resources is not supported", kind)
+}
+
+// findName reports whether an item of the pages of fetch has the name, listing no page after
+// the one of the item.
+func findName[T any](ctx context.Context, name string, nameOf func(T) string, fetch paging.PageFunc[T]) (bool, error) {
+	it := paging.New(ctx, fetch)
+	defer it.Close()
+	for it.Next() {
+		if nameOf(it.Value()) == name {
+			return true, nil
+		}
+	}
+	return false, it.Error()
+}
+
+// WithNameCheck makes the create calls of ClickHouse and Kafka clusters, Kafka topics,
+// networks, transfers and transfer endpoints validate the name of the request, see
+// ValidateName, then check it is available, see SDK.CheckNameAvailable, and fail fast with
+// *namegen.NameError or *NameTakenError.
+func WithNameCheck(enabled bool) grpc.CallOption {
+	return &withNameCheck{enabled: enabled}
+}
+
+type withNameCheck struct {
+	grpc.EmptyCallOption
+	enabled bool
+}
+
+func nameCheckEnabled(opts []grpc.CallOption) bool {
+	enabled := false
+	for _, o := range opts {
+		if o, ok := o.(*withNameCheck); ok {
+			enabled = o.enabled
+		}
+	}
+	return enabled
+}
+
+// createdName returns the kind, the scope and the name of the resource a create request
+// creates.
+func createdName(req interface{}) (kind ResourceKind, scopeID, name string, ok bool) {
+	switch req := req.(type) {
+	case *clickhousepb.CreateClusterRequest:
+		return ResourceClickHouseCluster, req.GetProjectId(), req.GetName(), true
+	case *kafkapb.CreateClusterRequest:
+		return ResourceKafkaCluster, req.GetProjectId(), req.GetName(), true
+	case *kafkapb.CreateTopicRequest:
+		return resourceKafkaTopic, req.GetClusterId(), req.GetTopicSpec().GetName(), true
+	case *networkpb.CreateNetworkRequest:
+		return ResourceNetwork, req.GetProjectId(), req.GetName(), true
+	case *transferpb.CreateTransferRequest:
+		return ResourceTransfer, req.GetProjectId(), req.GetName(), true
+	case *transferpb.CreateEndpointRequest:
+		return ResourceTransferEndpoint, req.GetProjectId(), req.GetName(), true
+	}
+	return "", "", "", false
+}
+
+func (sdk *SDK) interceptNameCheck(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !nameCheckEnabled(opts) {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+	if kind, scopeID, name, ok := createdName(req); ok {
+		if err := ValidateName(kind, name); err != nil {
+			return err
+		}
+		taken, err := sdk.nameTaken(ctx, kind, scopeID, name, nil)
+		if err != nil {
+			return sdkerrors.WithMessage(err, "name check")
+		}
+		if taken {
+			return &NameTakenError{Kind: kind, ScopeID: scopeID, Name: name}
+		}
+	}
+	return invoker(ctx, method, req, reply, conn, opts...)
+}
@@ -0,0 +1,257 @@
+package dcsdk
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/namegen"
+)
+
+// namePageSize is the size of the pages of nameListing, whatever the requests.
+const namePageSize = 2
+
+// nameListing fakes the listings of the named resources of all the services, by project or
+// cluster, in pages of namePageSize names. The listings of the scope "denied" fail.
+type nameListing struct {
+	names map[string][]string
+
+	mu      sync.Mutex
+	pages   int
+	creates []string
+}
+
+func (f *nameListing) page(scope string, p *dcv1.Paging) ([]string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pages++
+	if scope == "denied" {
+		return nil, "", status.Error(codes.PermissionDenied, "denied")
+	}
+	offset, _ := strconv.Atoi(p.GetPageToken())
+	names := f.names[scope][offset:]
+	if len(names) <= namePageSize {
+		return names, "", nil
+	}
+	return names[:namePageSize], strconv.Itoa(offset + namePageSize), nil
+}
+
+func (f *nameListing) create(name string) (*dcv1.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.creates = append(f.creates, name)
+	return &dcv1.Operation{Id: "op-" + name}, nil
+}
+
+func (f *nameListing) listed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pages
+}
+
+type nameClickHouse struct {
+	clickhouse.UnimplementedClusterServiceServer
+	*nameListing
+}
+
+func (f nameClickHouse) List(ctx context.Context, req *clickhouse.ListClustersRequest) (*clickhouse.ListClustersResponse, error) {
+	names, next, err := f.page(req.ProjectId, req.Paging)
+	resp := &clickhouse.ListClustersResponse{NextPage: &dcv1.NextPage{Token: next}}
+	for _, n := range names {
+		resp.Clusters = append(resp.Clusters, &clickhouse.Cluster{Name: n})
+	}
+	return resp, err
+}
+
+func (f nameClickHouse) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	return f.create(req.Name)
+}
+
+type nameKafka struct {
+	kafka.UnimplementedClusterServiceServer
+	*nameListing
+}
+
+func (f nameKafka) List(ctx context.Context, req *kafka.ListClustersRequest) (*kafka.ListClustersResponse, error) {
+	names, next, err := f.page(req.ProjectId, req.Paging)
+	resp := &kafka.ListClustersResponse{NextPage: &dcv1.NextPage{Token: next}}
+	for _, n := range names {
+		resp.Clusters = append(resp.Clusters, &kafka.Cluster{Name: n})
+	}
+	return resp, err
+}
+
+type nameTopics struct {
+	kafka.UnimplementedTopicServiceServer
+	*nameListing
+}
+
+func (f nameTopics) List(ctx context.Context, req *kafka.ListTopicsRequest) (*kafka.ListTopicsResponse, error) {
+	names, next, err := f.page(req.ClusterId, req.Paging)
+	resp := &kafka.ListTopicsResponse{NextPage: &dcv1.NextPage{Token: next}}
+	for _, n := range names {
+		resp.Topics = append(resp.Topics, &kafka.Topic{Name: n})
+	}
+	return resp, err
+}
+
+func (f nameTopics) Create(ctx context.Context, req *kafka.CreateTopicRequest) (*dcv1.Operation, error) {
+	return f.create(req.TopicSpec.GetName())
+}
+
+type nameNetworks struct {
+	network.UnimplementedNetworkServiceServer
+	*nameListing
+}
+
+func (f nameNetworks) List(ctx context.Context, req *network.ListNetworksRequest) (*network.ListNetworksResponse, error) {
+	names, next, err := f.page(req.ProjectId, req.Paging)
+	resp := &network.ListNetworksResponse{NextPage: &dcv1.NextPage{Token: next}}
+	for _, n := range names {
+		resp.Networks = append(resp.Networks, &network.Network{Name: n})
+	}
+	return resp, err
+}
+
+type nameTransfers struct {
+	transfer.UnimplementedTransferServiceServer
+	*nameListing
+}
+
+func (f nameTransfers) List(ctx context.Context, req *transfer.ListTransfersRequest) (*transfer.ListTransfersResponse, error) {
+	names, next, err := f.page(req.ProjectId, req.Page)
+	resp := &transfer.ListTransfersResponse{NextPageToken: next}
+	for _, n := range names {
+		resp.Transfers = append(resp.Transfers, &transfer.Transfer{Name: n})
+	}
+	return resp, err
+}
+
+type nameEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+	*nameListing
+}
+
+func (f nameEndpoints) List(ctx context.Context, req *transfer.ListEndpointsRequest) (*transfer.ListEndpointsResponse, error) {
+	names, next, err := f.page(req.ProjectId, req.Page)
+	resp := &transfer.ListEndpointsResponse{NextPage: &dcv1.NextPage{Token: next}}
+	for _, n := range names {
+		resp.Endpoints = append(resp.Endpoints, &transfer.Endpoint{Name: n})
+	}
+	return resp, err
+}
+
+func newNameSDK(t *testing.T, f *nameListing) *SDK {
+	return newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, nameClickHouse{nameListing: f})
+		kafka.RegisterClusterServiceServer(s, nameKafka{nameListing: f})
+		kafka.RegisterTopicServiceServer(s, nameTopics{nameListing: f})
+		network.RegisterNetworkServiceServer(s, nameNetworks{nameListing: f})
+		transfer.RegisterTransferServiceServer(s, nameTransfers{nameListing: f})
+		transfer.RegisterEndpointServiceServer(s, nameEndpoints{nameListing: f})
+	})
+}
+
+func TestCheckNameAvailable(t *testing.T) {
+	for _, kind := range []ResourceKind{ResourceClickHouseCluster, ResourceKafkaCluster, ResourceNetwork, ResourceTransfer, ResourceTransferEndpoint} {
+		t.Run(string(kind), func(t *testing.T) {
+			f := &nameListing{names: map[string][]string{
+				"prj1": {"prod-a", "prod-b", "prod-c", "prod-d", "prod-e"},
+				"prj2": {"prod-f"},
+			}}
+			sdk := newNameSDK(t, f)
+			ctx := context.Background()
+
+			available, err := sdk.CheckNameAvailable(ctx, kind, "prj1", "prod-c")
+			require.NoError(t, err)
+			assert.False(t, available, "the name of the second page is taken")
+			assert.Equal(t, 2, f.listed(), "no page is listed after the one of the name")
+
+			available, err = sdk.CheckNameAvailable(ctx, kind, "prj1", "prod-f")
+			require.NoError(t, err)
+			assert.True(t, available, "the names are unique within the project")
+			assert.Equal(t, 5, f.listed(), "all the pages are listed")
+
+			available, err = sdk.CheckNameAvailable(ctx, kind, "prj2", "prod-f")
+			require.NoError(t, err)
+			assert.False(t, available)
+		})
+	}
+}
+
+func TestCheckNameAvailable_Errors(t *testing.T) {
+	f := &nameListing{}
+	sdk := newNameSDK(t, f)
+	ctx := context.Background()
+
+	_, err := sdk.CheckNameAvailable(ctx, ResourceKafkaCluster, "prj1", "Prod Events")
+	assert.ErrorIs(t, err, namegen.ErrInvalidName)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Zero(t, f.listed(), "invalid names are not looked up")
+
+	_, err = sdk.CheckNameAvailable(ctx, ResourceKafkaCluster, "denied", "prod-events")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.ErrorContains(t, err, `check kafka/cluster name`)
+
+	_, err = sdk.CheckNameAvailable(ctx, ResourceVisualizationWorkbook, "prj1", "dashboard")
+	assert.EqualError(t, err, `name check of "visualization/workbook" resources is not supported`)
+	_, err = sdk.CheckNameAvailable(ctx, resourceKafkaTopic, "kfc1", "events")
+	assert.Error(t, err, "topics are named within their cluster")
+}
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, ValidateName(ResourceTransfer, "prod-data-sync"))
+	assert.ErrorIs(t, ValidateName(ResourceTransferEndpoint, "prod_data"), namegen.ErrInvalidName)
+	assert.NoError(t, ValidateName(ResourceVisualizationWorkbook, "Any name"), "no known constraints")
+}
+
+func TestWithNameCheck(t *testing.T) {
+	f := &nameListing{names: map[string][]string{
+		"prj1": {"prod-a", "prod-b", "prod-c"},
+		"kfc1": {"events", "clicks", "orders"},
+	}}
+	sdk := newNameSDK(t, f)
+	ctx := context.Background()
+	createCluster := func(name string, opts ...grpc.CallOption) error {
+		_, err := sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{ProjectId: "prj1", Name: name}, opts...)
+		return err
+	}
+
+	err := createCluster("prod-c", WithNameCheck(true))
+	require.ErrorIs(t, err, ErrNameTaken)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.EqualError(t, err, `clickhouse/cluster name "prod-c" is already taken in prj1`)
+
+	err = createCluster("Prod C", WithNameCheck(true))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, f.creates, "no create is sent for the names rejected")
+
+	listed := f.listed()
+	require.NoError(t, createCluster("prod-d", WithNameCheck(true)))
+	assert.Equal(t, listed+2, f.listed())
+	require.NoError(t, createCluster("prod-c"), "unchecked by default")
+	require.NoError(t, createCluster("prod-c", WithNameCheck(true), WithNameCheck(false)))
+	assert.Equal(t, listed+2, f.listed())
+	assert.Equal(t, []string{"prod-d", "prod-c", "prod-c"}, f.creates)
+
+	createTopic := func(name string) error {
+		_, err := sdk.Kafka().Topic().Create(ctx, &kafka.CreateTopicRequest{ClusterId: "kfc1", TopicSpec: &kafka.TopicSpec{Name: name}}, WithNameCheck(true))
+		return err
+	}
+	var taken *NameTakenError
+	require.ErrorAs(t, createTopic("orders"), &taken)
+	assert.Equal(t, NameTakenError{Kind: resourceKafkaTopic, ScopeID: "kfc1", Name: "orders"}, *taken)
+	require.NoError(t, createTopic("Orders.v2"))
+}
@@ -0,0 +1,140 @@
+package operation
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// AdaptiveQuantile is the quantile of the completion times of an operation type taken by
+// adaptive waits as the expected completion time, see WithAdaptiveInterval.
+const AdaptiveQuantile = 0.5
+
+// maxHistogramSamples is the number of the latest completion times kept per operation type.
+const maxHistogramSamples = 100
+
+// DurationHistogram holds the completion times of operations by type, see HistogramKey.
+// It is safe for concurrent use.
+type DurationHistogram struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+// NewDurationHistogram returns an empty histogram.
+func NewDurationHistogram() *DurationHistogram {
+	return &DurationHistogram{samples: map[string][]time.Duration{}}
+}
+
+// DefaultDurationHistogram is the histogram of adaptive waits without WithDurationHistogram.
+var DefaultDurationHistogram = NewDurationHistogram()
+
+// HistogramKey returns the type of the operation the histogram groups it by: its service kind
+// and description, e.g. "clickhouse/Create cluster".
+func HistogramKey(o *Operation) string {
+	return kindOf(o.Id()) + "/" + o.Description()
+}
+
+// Observe adds the completion time of an operation of the type, keeping the latest
+// samples only.
+func (h *DurationHistogram) Observe(key string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := append(h.samples[key], d)
+	if len(s) > maxHistogramSamples {
+		s = s[len(s)-maxHistogramSamples:]
+	}
+	h.samples[key] = s
+}
+
+// Quantile returns the q-quantile of the completion times of the type, 0 <= q <= 1.
+// It reports false if there are none.
+func (h *DurationHistogram) Quantile(key string, q float64) (time.Duration, bool) {
+	h.mu.Lock()
+	s := append([]time.Duration(nil), h.samples[key]...)
+	h.mu.Unlock()
+	if len(s) == 0 {
+		return 0, false
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	i := int(q * float64(len(s)-1))
+	if i < 0 {
+		i = 0
+	} else if i >= len(s) {
+		i = len(s) - 1
+	}
+	return s[i], true
+}
+
+// WithAdaptiveInterval makes waits choose the poll interval from the completion times of the
+// operations of the same type, see HistogramKey, instead of polling at a fixed interval:
+// the interval is half of the time left until the expected completion (see AdaptiveQuantile),
+// within [min, max]. Long-running types are polled rarely at first and more often as the
+// expected completion approaches; types without history and operations running longer than
+// expected are polled every min. Intervals suggested by the server take precedence but are
+// capped at max too. Successful waits add the operation completion time to the histogram,
+// DefaultDurationHistogram unless set with WithDurationHistogram. Long-polling waits are not
+// affected.
+func WithAdaptiveInterval(min, max time.Duration) grpc.CallOption {
+	return &adaptiveInterval{min: min, max: max}
+}
+
+// WithDurationHistogram sets the histogram of adaptive waits, see WithAdaptiveInterval.
+func WithDurationHistogram(h *DurationHistogram) grpc.CallOption {
+	return &durationHistogram{h: h}
+}
+
+type adaptiveInterval struct {
+	grpc.EmptyCallOption
+	min, max time.Duration
+}
+
+func (o *adaptiveInterval) newPolicy(h *DurationHistogram) intervalPolicy {
+	return &adaptive{min: o.min, max: o.max, h: h}
+}
+
+type durationHistogram struct {
+	grpc.EmptyCallOption
+	h *DurationHistogram
+}
+
+// adaptive is the interval policy of an adaptive wait.
+type adaptive struct {
+	min, max time.Duration
+	h        *DurationHistogram
+}
+
+func (a *adaptive) next(o *Operation) time.Duration {
+	expected, ok := a.h.Quantile(HistogramKey(o), AdaptiveQuantile)
+	if !ok {
+		return a.min
+	}
+	return a.clamp((expected - o.Age()) / 2)
+}
+
+func (a *adaptive) source() IntervalSource { return IntervalAdaptive }
+
+func (a *adaptive) hinted(d time.Duration) time.Duration {
+	if d > a.max {
+		return a.max
+	}
+	return d
+}
+
+func (a *adaptive) clamp(d time.Duration) time.Duration {
+	if d > a.max {
+		d = a.max
+	}
+	if d < a.min {
+		d = a.min
+	}
+	return d
+}
+
+// done adds the completion time of the operation once it succeeded.
+func (a *adaptive) done(o *Operation) {
+	if o.Ok() && o.Proto().GetCreateTime() != nil && !o.synthetic {
+		a.h.Observe(HistogramKey(o), o.Age())
+	}
+}
@@ -0,0 +1,122 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeClock is the time source of the operation package advanced by the waits it times.
+type fakeClock struct {
+	t         time.Time
+	intervals []time.Duration
+}
+
+func newFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{t: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	now = func() time.Time { return c.t }
+	t.Cleanup(func() { now = time.Now })
+	return c
+}
+
+func (c *fakeClock) newTimer(d time.Duration) (func() <-chan time.Time, func() bool) {
+	c.intervals = append(c.intervals, d)
+	c.t = c.t.Add(d)
+	return defaultTimer(0)
+}
+
+// pollUntil returns a poll func of an operation created now and done after d,
+// suggesting the hinted interval.
+func (c *fakeClock) pollUntil(d, hinted time.Duration) PollFunc {
+	created := c.t
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		op := &Proto{Id: id, Description: "Create cluster", CreateTime: timestamppb.New(created), Status: doublecloud.Operation_STATUS_RUNNING}
+		if !c.t.Before(created.Add(d)) {
+			op.Status = doublecloud.Operation_STATUS_DONE
+			op.FinishTime = timestamppb.New(created.Add(d))
+		}
+		return op, hinted, nil
+	}
+}
+
+func seededHistogram(key string, samples ...time.Duration) *DurationHistogram {
+	h := NewDurationHistogram()
+	for _, d := range samples {
+		h.Observe(key, d)
+	}
+	return h
+}
+
+func TestDurationHistogram_Quantile(t *testing.T) {
+	h := seededHistogram("kafka/Create topic", 3*time.Second, time.Second, 2*time.Second, 5*time.Second, 4*time.Second)
+	for q, want := range map[float64]time.Duration{0: time.Second, 0.5: 3 * time.Second, 0.9: 4 * time.Second, 1: 5 * time.Second} {
+		got, ok := h.Quantile("kafka/Create topic", q)
+		assert.True(t, ok)
+		assert.Equal(t, want, got, "q=%v", q)
+	}
+	_, ok := h.Quantile("kafka/Delete topic", 0.5)
+	assert.False(t, ok)
+
+	for i := 0; i < maxHistogramSamples; i++ {
+		h.Observe("kafka/Create topic", time.Minute)
+	}
+	got, _ := h.Quantile("kafka/Create topic", 0)
+	assert.Equal(t, time.Minute, got, "only the latest samples are kept")
+}
+
+func TestWait_AdaptiveInterval(t *testing.T) {
+	requireKinds(t)
+	clock := newFakeClock(t)
+	h := seededHistogram("clickhouse/Create cluster", 9*time.Minute, 10*time.Minute, 11*time.Minute)
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = clock.newTimer
+
+	opts := []grpc.CallOption{WithPollFunc(clock.pollUntil(10*time.Minute, 0)), WithAdaptiveInterval(time.Second, 2*time.Minute), WithDurationHistogram(h)}
+	require.NoError(t, op.Wait(context.Background(), opts...))
+	require.Greater(t, len(clock.intervals), 8)
+	assert.Equal(t, []time.Duration{
+		2 * time.Minute, 2 * time.Minute, 2 * time.Minute, 2 * time.Minute, // half of 10m-0, 8m, 6m, 4m is capped
+		time.Minute, 30 * time.Second, 15 * time.Second, 7500 * time.Millisecond,
+	}, clock.intervals[:8], "the interval shrinks as the expected completion approaches")
+	assert.Equal(t, time.Second, clock.intervals[len(clock.intervals)-1], "past the expected completion the interval is min")
+	assert.Less(t, len(clock.intervals), 20, "a fixed 1s interval would poll 600 times")
+
+	got, _ := h.Quantile("clickhouse/Create cluster", 1)
+	assert.Equal(t, 11*time.Minute, got)
+	h.mu.Lock()
+	assert.Len(t, h.samples["clickhouse/Create cluster"], 4, "the completion time is added to the histogram")
+	h.mu.Unlock()
+}
+
+func TestWait_AdaptiveIntervalBounds(t *testing.T) {
+	h := seededHistogram("clickhouse/Create cluster", 10*time.Minute)
+	run := func(t *testing.T, d, hinted time.Duration, h *DurationHistogram) []time.Duration {
+		clock := newFakeClock(t)
+		op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = clock.newTimer
+		require.NoError(t, op.Wait(context.Background(), WithPollFunc(clock.pollUntil(d, hinted)),
+			WithAdaptiveInterval(time.Second, 2*time.Minute), WithDurationHistogram(h)))
+		return clock.intervals
+	}
+
+	t.Run("no history", func(t *testing.T) {
+		assert.Equal(t, []time.Duration{time.Second, time.Second, time.Second}, run(t, 3*time.Second, 0, NewDurationHistogram()))
+	})
+	t.Run("server hint", func(t *testing.T) {
+		assert.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second}, run(t, 10*time.Second, 5*time.Second, h),
+			"hints take precedence over the history")
+	})
+	t.Run("server hint above max", func(t *testing.T) {
+		assert.Equal(t, []time.Duration{2 * time.Minute, 2 * time.Minute}, run(t, 4*time.Minute, time.Hour, h))
+	})
+	t.Run("other type", func(t *testing.T) {
+		h := seededHistogram("kafka/Create cluster", 10*time.Minute)
+		assert.Equal(t, []time.Duration{time.Second, time.Second}, run(t, 2*time.Second, 0, h))
+	})
+}
@@ -0,0 +1,45 @@
+package operation
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+)
+
+// ErrAsyncWaitRunning is the result of WaitAsync while another wait of WaitAsync runs.
+var ErrAsyncWaitRunning = errors.New("operation: async wait already running")
+
+// WaitAsync starts Wait in a goroutine, e.g. for select-based event loops, and returns the
+// channel receiving its error, nil once the operation is done successfully. The channel
+// is buffered, so the goroutine ends with the wait even if the result isn't received, and
+// the wait ends promptly once ctx is done.
+//
+// Only one wait of WaitAsync runs at a time: while one does, see AsyncWaitRunning,
+// WaitAsync doesn't start another and its channel receives ErrAsyncWaitRunning. Other
+// consumers of the operation may select on DoneChan instead.
+func (o *Operation) WaitAsync(ctx context.Context, opts ...grpc.CallOption) <-chan error {
+	result := make(chan error, 1)
+	if !o.state.startAsync() {
+		result <- ErrAsyncWaitRunning
+		return result
+	}
+	go func() {
+		err := o.Wait(ctx, opts...)
+		o.state.stopAsync()
+		result <- err
+	}()
+	return result
+}
+
+// AsyncWaitRunning reports whether a wait of WaitAsync runs.
+func (o *Operation) AsyncWaitRunning() bool {
+	return o.state.asyncRunning()
+}
+
+// DoneChan returns the channel closed once the operation is done, as seen by any of its
+// polls or right away if it is done already. It is never closed if the operation isn't
+// polled, e.g. with WaitAsync.
+func (o *Operation) DoneChan() <-chan struct{} {
+	return o.state.doneChan()
+}
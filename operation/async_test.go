@@ -0,0 +1,119 @@
+package operation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// doneAtPoll returns a poll func of an operation done at the n-th poll, suggesting 1ms.
+func doneAtPoll(n int32) PollFunc {
+	var polls int32
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		if atomic.AddInt32(&polls, 1) < n {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, time.Millisecond, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, time.Millisecond, nil
+	}
+}
+
+// blockingPoll returns a poll func blocking until ctx is done or release is closed.
+func blockingPoll(release <-chan struct{}) PollFunc {
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		select {
+		case <-release:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+}
+
+func TestWaitAsync(t *testing.T) {
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	done := op.DoneChan()
+	require.NoError(t, <-op.WaitAsync(context.Background(), WithPollFunc(doneAtPoll(3))))
+	assert.True(t, op.Ok())
+	assert.False(t, op.AsyncWaitRunning())
+	select {
+	case <-done:
+	default:
+		t.Fatal("DoneChan is not closed")
+	}
+}
+
+func TestWaitAsync_Running(t *testing.T) {
+	release := make(chan struct{})
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	first := op.WaitAsync(context.Background(), WithPollFunc(blockingPoll(release)))
+	assert.True(t, op.AsyncWaitRunning())
+	assert.ErrorIs(t, <-op.WaitAsync(context.Background(), WithPollFunc(blockingPoll(release))), ErrAsyncWaitRunning)
+
+	close(release)
+	require.NoError(t, <-first)
+	assert.False(t, op.AsyncWaitRunning())
+	require.NoError(t, <-op.WaitAsync(context.Background()), "a new wait starts once the previous one ended")
+}
+
+func TestWaitAsync_ContextDone(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	ctx, cancel := context.WithCancel(context.Background())
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	result := op.WaitAsync(ctx, WithPollFunc(blockingPoll(nil)))
+	cancel()
+	select {
+	case err := <-result:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the wait didn't end with its context")
+	}
+	assert.False(t, op.AsyncWaitRunning())
+	select {
+	case <-op.DoneChan():
+		t.Fatal("DoneChan is closed for an operation not done")
+	default:
+	}
+}
+
+func TestWaitAsync_ConcurrentPolls(t *testing.T) {
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	poll := WithPollFunc(doneAtPoll(50))
+	result := op.WaitAsync(context.Background(), poll)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !op.Done() {
+				_ = op.Poll(context.Background(), poll)
+				_, _ = op.Id(), op.SuggestedPollInterval()
+				_, _ = op.MarshalJSON()
+			}
+		}()
+	}
+	require.NoError(t, <-result)
+	wg.Wait()
+	<-op.DoneChan()
+	assert.True(t, op.Ok())
+}
+
+func TestDoneChan_Done(t *testing.T) {
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_DONE})
+	select {
+	case <-op.DoneChan():
+	default:
+		t.Fatal("DoneChan is not closed for an operation done")
+	}
+	select {
+	case <-NewFromID(nil, "kfo1").DoneChan():
+		t.Fatal("DoneChan is closed for an operation not polled yet")
+	default:
+	}
+}
@@ -0,0 +1,105 @@
+package operation
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// BackoffPolicy grows the poll interval of a wait exponentially, see WaitBackoff.
+type BackoffPolicy struct {
+	// InitialInterval is the interval after the first poll.
+	InitialInterval time.Duration
+	// MaxInterval bounds the interval, before jitter.
+	MaxInterval time.Duration
+	// Multiplier grows the interval after every poll. Values below 1 are treated as 1.
+	Multiplier float64
+	// Jitter randomizes every interval by up to the fraction of it either way, e.g. 0.2
+	// waits between 80% and 120% of the interval, so that concurrent waits spread their polls.
+	Jitter float64
+}
+
+// DefaultBackoffPolicy polls after 1s, 1.5s, 2.25s and so on up to every 30s, ±20%.
+var DefaultBackoffPolicy = BackoffPolicy{
+	InitialInterval: time.Second,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      1.5,
+	Jitter:          0.2,
+}
+
+// jitterFloat64 returns a random number in [0, 1), replaced in tests.
+var jitterFloat64 = rand.Float64
+
+// WithBackoff makes waits poll with the exponentially growing intervals of the policy instead
+// of the fixed poll interval. The interval is reset to InitialInterval whenever the metadata
+// of the operation changes, as a sign of progress. Intervals suggested by the server take
+// precedence over the policy. Long-polling waits are not affected.
+func WithBackoff(policy BackoffPolicy) grpc.CallOption {
+	return &backoffOption{policy: policy}
+}
+
+// WaitBackoff waits for the operation to be done, polling it with the exponentially growing
+// intervals of the policy, see WithBackoff. It is Wait otherwise.
+func (o *Operation) WaitBackoff(ctx context.Context, policy BackoffPolicy, opts ...grpc.CallOption) error {
+	return o.WaitInterval(ctx, policy.InitialInterval, append(opts[:len(opts):len(opts)], WithBackoff(policy))...)
+}
+
+type backoffOption struct {
+	grpc.EmptyCallOption
+	policy BackoffPolicy
+}
+
+func (o *backoffOption) newPolicy(*DurationHistogram) intervalPolicy {
+	return &backoff{policy: o.policy, interval: o.policy.InitialInterval}
+}
+
+// backoff is the interval policy of a wait with a BackoffPolicy.
+type backoff struct {
+	policy   BackoffPolicy
+	interval time.Duration
+	metadata map[string]string
+	polled   bool
+}
+
+func (b *backoff) next(o *Operation) time.Duration {
+	if metadata := o.Metadata(); !b.polled || !equalMetadata(b.metadata, metadata) {
+		b.polled = true
+		b.metadata = metadata
+		b.interval = b.policy.InitialInterval
+	}
+	max := b.policy.MaxInterval
+	interval := b.interval
+	if max > 0 && interval > max {
+		interval = max
+	}
+	multiplier := b.policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	if b.interval = time.Duration(float64(interval) * multiplier); max > 0 && b.interval > max {
+		b.interval = max
+	}
+	if j := b.policy.Jitter; j > 0 {
+		interval = time.Duration(float64(interval) * (1 + j*(2*jitterFloat64()-1)))
+	}
+	return interval
+}
+
+func (b *backoff) hinted(d time.Duration) time.Duration { return d }
+func (b *backoff) source() IntervalSource               { return IntervalBackoff }
+
+func (b *backoff) done(*Operation) {}
+
+func equalMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// progressingPoll returns a poll func of an operation done at the last poll, with the metadata
+// of every poll from progress, and suggesting the hinted intervals of the polls, if any.
+func progressingPoll(polls int, progress func(poll int) string, hinted map[int]time.Duration) PollFunc {
+	var n int
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		n++
+		op := &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING, Metadata: map[string]string{"progress": progress(n)}}
+		if n >= polls {
+			op.Status = doublecloud.Operation_STATUS_DONE
+		}
+		return op, hinted[n], nil
+	}
+}
+
+func noProgress(int) string { return "0" }
+
+func TestWaitBackoff(t *testing.T) {
+	policy := BackoffPolicy{InitialInterval: time.Second, MaxInterval: 10 * time.Second, Multiplier: 2}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	timer := &recordingTimer{}
+	op.newTimer = timer.newTimer
+
+	require.NoError(t, op.WaitBackoff(context.Background(), policy, WithPollFunc(progressingPoll(7, noProgress, nil))))
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, timer.intervals)
+	assert.True(t, op.Ok())
+}
+
+func TestWaitBackoff_ResetOnProgress(t *testing.T) {
+	policy := BackoffPolicy{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	timer := &recordingTimer{}
+	op.newTimer = timer.newTimer
+
+	// The progress moves at the 4th poll.
+	progress := func(poll int) string { return strconv.Itoa(poll / 4) }
+	require.NoError(t, op.WaitBackoff(context.Background(), policy, WithPollFunc(progressingPoll(7, progress, nil))))
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, time.Second, 2 * time.Second, 4 * time.Second,
+	}, timer.intervals)
+}
+
+func TestWaitBackoff_ServerHint(t *testing.T) {
+	policy := BackoffPolicy{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	timer := &recordingTimer{}
+	op.newTimer = timer.newTimer
+
+	hinted := map[int]time.Duration{2: 15 * time.Second}
+	require.NoError(t, op.WaitBackoff(context.Background(), policy, WithPollFunc(progressingPoll(5, noProgress, hinted))))
+	assert.Equal(t, []time.Duration{time.Second, 15 * time.Second, 2 * time.Second, 4 * time.Second}, timer.intervals,
+		"the hint takes precedence and doesn't advance the backoff")
+}
+
+func TestWaitBackoff_SharedCallOptions(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		status := doublecloud.Operation_STATUS_RUNNING
+		if n > 40 {
+			status = doublecloud.Operation_STATUS_DONE
+		}
+		return &Proto{Id: id, Status: status}, nil
+	}}
+
+	// Spare capacity lets append write past len into the shared backing array.
+	opts := make([]grpc.CallOption, 1, 8)
+	opts[0] = grpc.EmptyCallOption{}
+
+	intervals := []time.Duration{time.Second, 3 * time.Second}
+	timers := make([]*recordingTimer, len(intervals))
+	var wg sync.WaitGroup
+	for i, interval := range intervals {
+		op := New(client, &Proto{Id: fmt.Sprintf("kfo%d", i), Status: doublecloud.Operation_STATUS_PENDING})
+		timers[i] = &recordingTimer{}
+		op.newTimer = timers[i].newTimer
+		policy := BackoffPolicy{InitialInterval: interval, MaxInterval: interval, Multiplier: 2}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, op.WaitBackoff(context.Background(), policy, opts...))
+		}()
+	}
+	wg.Wait()
+
+	for i, interval := range intervals {
+		require.NotEmpty(t, timers[i].intervals)
+		for _, d := range timers[i].intervals {
+			assert.Equal(t, interval, d, "backoff policy shared between operations")
+		}
+	}
+	assert.Len(t, opts, 1)
+}
+
+func TestWaitBackoff_Jitter(t *testing.T) {
+	rolls := []float64{0, 0.5, 0.999}
+	jitterFloat64 = func() float64 {
+		r := rolls[0]
+		rolls = append(rolls[1:], r)
+		return r
+	}
+	defer func() { jitterFloat64 = rand.Float64 }()
+
+	policy := BackoffPolicy{InitialInterval: 10 * time.Second, MaxInterval: 10 * time.Second, Multiplier: 1.5, Jitter: 0.2}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	timer := &recordingTimer{}
+	op.newTimer = timer.newTimer
+	require.NoError(t, op.WaitBackoff(context.Background(), policy, WithPollFunc(progressingPoll(4, noProgress, nil))))
+	require.Len(t, timer.intervals, 3)
+	assert.Equal(t, 8*time.Second, timer.intervals[0])
+	assert.Equal(t, 10*time.Second, timer.intervals[1])
+	assert.InDelta(t, float64(12*time.Second), float64(timer.intervals[2]), float64(10*time.Millisecond))
+}
+
+func TestDefaultBackoffPolicy(t *testing.T) {
+	jitterFloat64 = func() float64 { return 0.5 }
+	defer func() { jitterFloat64 = rand.Float64 }()
+
+	b := (&backoffOption{policy: DefaultBackoffPolicy}).newPolicy(nil)
+	op := New(nil, &Proto{Id: "cho1"})
+	assert.Equal(t, time.Second, b.next(op))
+	assert.Equal(t, 1500*time.Millisecond, b.next(op))
+	assert.Equal(t, 2250*time.Millisecond, b.next(op))
+	for i := 0; i < 10; i++ {
+		b.next(op)
+	}
+	assert.Equal(t, 30*time.Second, b.next(op), "the interval is capped")
+}
@@ -0,0 +1,370 @@
+package operation
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/doublecloud/go-sdk/pkg/retry"
+)
+
+// DefaultWaitConcurrency bounds the polls in flight of WaitAll and WaitAny.
+const DefaultWaitConcurrency = 10
+
+// WithWaitConcurrency sets the number of polls in flight of WaitAll and WaitAny, at least 1.
+func WithWaitConcurrency(n int) grpc.CallOption {
+	return &waitConcurrency{n: n}
+}
+
+type waitConcurrency struct {
+	grpc.EmptyCallOption
+	n int
+}
+
+func waitConcurrencyOf(opts []grpc.CallOption) int {
+	n := DefaultWaitConcurrency
+	for _, o := range opts {
+		if o, ok := o.(*waitConcurrency); ok {
+			n = o.n
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// ErrBatchWait is matched by errors.Is for every *BatchWaitError.
+var ErrBatchWait = errors.New("operation: batch wait failed")
+
+// BatchFailure is an operation of WaitAll or WaitAny that failed, wasn't polled successfully,
+// or wasn't done when the context was, with *WaitCancelledError then.
+type BatchFailure struct {
+	OperationID string
+	Err         error
+}
+
+// BatchWaitError is returned by WaitAll if any of the operations failed, and by WaitAny if
+// none of them completed. It unwraps to the errors of the failures.
+type BatchWaitError struct {
+	// Total is the number of waited operations.
+	Total int
+	// Failures are in the order of the operations.
+	Failures []BatchFailure
+}
+
+func (e *BatchWaitError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%s: %v", f.OperationID, f.Err)
+	}
+	return fmt.Sprintf("operation: %d of %d operations failed: %s", len(e.Failures), e.Total, strings.Join(failures, "; "))
+}
+
+func (e *BatchWaitError) Is(target error) bool { return target == ErrBatchWait }
+
+func (e *BatchWaitError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// FailedIDs returns the IDs of the failed operations.
+func (e *BatchWaitError) FailedIDs() []string {
+	ids := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		ids[i] = f.OperationID
+	}
+	return ids
+}
+
+// WaitAll waits for all the operations to be done and returns *BatchWaitError listing the
+// ones that failed, nil if all of them succeeded.
+//
+// The operations are polled concurrently by a bounded number of pollers, see
+// WithWaitConcurrency, each one every DefaultPollInterval or the interval suggested by the
+// server for it. Failed polls are retried by the RetryPolicy of the options, see
+// WithRetryPolicy; once the retries are exhausted, the operation fails with the poll error.
+// When ctx is done, the operations not done yet fail with *WaitCancelledError, see
+// WaitAllResults for the outcomes of all of them. The options are passed to the polls, e.g. WithPollFunc. Long-polling and the interval
+// policies of waits, such as WithBackoff, are not used.
+func WaitAll(ctx context.Context, ops []*Operation, opts ...grpc.CallOption) error {
+	_, errs := waitBatch(ctx, ops, false, opts)
+	return batchWaitError(ops, errs)
+}
+
+// Results are the outcomes of the operations of WaitAllResults.
+type Results struct {
+	// Outcomes are in the order of the operations.
+	Outcomes []Outcome
+}
+
+// Outcome is the outcome of an operation of WaitAllResults.
+type Outcome struct {
+	Operation *Operation
+	// Err is nil if the operation succeeded, *OperationError if it failed, *PollError if it
+	// couldn't be polled, and *WaitCancelledError if ctx was done before it.
+	Err error
+}
+
+// Cancelled reports whether the wait ended before the operation, because ctx was done.
+func (o Outcome) Cancelled() bool { return errors.Is(o.Err, ErrWaitCancelled) }
+
+// Succeeded returns the operations done successfully.
+func (r *Results) Succeeded() []*Operation {
+	return r.filter(func(o Outcome) bool { return o.Err == nil })
+}
+
+// Failed returns the operations failed or not polled successfully.
+func (r *Results) Failed() []*Operation {
+	return r.filter(func(o Outcome) bool { return o.Err != nil && !o.Cancelled() })
+}
+
+// Cancelled returns the operations not done when the context of the wait was.
+func (r *Results) Cancelled() []*Operation {
+	return r.filter(Outcome.Cancelled)
+}
+
+func (r *Results) filter(match func(Outcome) bool) []*Operation {
+	var ops []*Operation
+	for _, o := range r.Outcomes {
+		if match(o) {
+			ops = append(ops, o.Operation)
+		}
+	}
+	return ops
+}
+
+// WaitAllResults is WaitAll returning the outcome of every operation, also when ctx is done
+// during the wait: the operations done by then have their outcome, the others fail with
+// *WaitCancelledError. The results are always returned, the error is the one of WaitAll.
+func WaitAllResults(ctx context.Context, ops []*Operation, opts ...grpc.CallOption) (*Results, error) {
+	_, errs := waitBatch(ctx, ops, false, opts)
+	r := &Results{Outcomes: make([]Outcome, len(ops))}
+	for i, o := range ops {
+		r.Outcomes[i] = Outcome{Operation: o, Err: errs[i]}
+	}
+	return r, batchWaitError(ops, errs)
+}
+
+// batchWaitError returns *BatchWaitError for the errors of the operations, nil without any.
+func batchWaitError(ops []*Operation, errs []error) error {
+	var failures []BatchFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, BatchFailure{OperationID: ops[i].Id(), Err: err})
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &BatchWaitError{Total: len(ops), Failures: failures}
+}
+
+// WaitAny waits for the first of the operations to be done, polling them like WaitAll, and
+// returns it with its error, like Wait. Operations that fail to be polled drop out of the race;
+// if none is left, or ctx is done first, WaitAny returns nil and *BatchWaitError.
+func WaitAny(ctx context.Context, ops []*Operation, opts ...grpc.CallOption) (*Operation, error) {
+	first, errs := waitBatch(ctx, ops, true, opts)
+	if first != nil {
+		return first, operationError(first)
+	}
+	return nil, batchWaitError(ops, errs)
+}
+
+// batchItem is an operation of a batch wait not done yet.
+type batchItem struct {
+	index    int
+	op       *Operation
+	next     time.Time
+	failures int
+	// calls adds the metadata of the polls, attempts counts them.
+	calls    CallMetadataFunc
+	attempts int
+	// interval and err are the result of the last poll.
+	interval time.Duration
+	err      error
+}
+
+// batchQueue orders the items by the time of their next poll.
+type batchQueue []*batchItem
+
+func (q batchQueue) Len() int            { return len(q) }
+func (q batchQueue) Less(i, j int) bool  { return q[i].next.Before(q[j].next) }
+func (q batchQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *batchQueue) Push(x interface{}) { *q = append(*q, x.(*batchItem)) }
+func (q *batchQueue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// waitBatch polls the operations until all of them, or the first one if first is set, are
+// done. It returns the first operation done and the errors of the operations, nil for the
+// ones succeeded.
+func waitBatch(ctx context.Context, ops []*Operation, first bool, opts []grpc.CallOption) (*Operation, []error) {
+	failed := make([]error, len(ops))
+	clock := clockOf(nil, opts)
+	var queue batchQueue
+	for i, o := range ops {
+		if o.Done() {
+			if first {
+				return o, nil
+			}
+			failed[i] = operationError(o)
+			continue
+		}
+		if pollFuncOf(opts) == nil {
+			if err := o.checkClient(); err != nil {
+				failed[i] = err
+				continue
+			}
+		}
+		queue = append(queue, &batchItem{index: i, op: o, next: clock.now(), calls: callMetadataOf(opts)})
+	}
+	heap.Init(&queue)
+	waitsStarted(len(queue))
+	defer waitsFinished(len(queue))
+
+	ctx, cancel := context.WithCancel(ctx)
+	due := make(chan *batchItem)
+	// Every poll in flight fits the buffer, so workers never block on their results.
+	polled := make(chan *batchItem, len(queue))
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		close(due)
+		wg.Wait()
+	}()
+	n := waitConcurrencyOf(opts)
+	if n > len(queue) {
+		n = len(queue)
+	}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range due {
+				it.poll(ctx, opts)
+				polled <- it
+			}
+		}()
+	}
+
+	var inFlight int
+	var stopTimer func() bool
+	done := ctx.Done()
+	for len(queue) > 0 || inFlight > 0 {
+		for inFlight < n && len(queue) > 0 && ctx.Err() == nil && !queue[0].next.After(clock.now()) {
+			due <- heap.Pop(&queue).(*batchItem)
+			inFlight++
+		}
+		var wake <-chan time.Time
+		if inFlight < n && len(queue) > 0 {
+			var timer func() <-chan time.Time
+			timer, stopTimer = clock.newTimer(queue[0].next.Sub(clock.now()))
+			wake = timer()
+		}
+		select {
+		case it := <-polled:
+			inFlight--
+			o := it.op
+			switch {
+			case o.Done() && first:
+				return o, nil
+			case o.Done():
+				failed[it.index] = operationError(o)
+			case ctx.Err() != nil:
+				failed[it.index] = batchCancelled(ctx, o)
+			case it.err != nil:
+				failed[it.index] = it.err
+			default:
+				it.next = clock.now().Add(it.interval)
+				heap.Push(&queue, it)
+			}
+		case <-wake:
+		case <-done:
+			// The polls in flight return promptly with ctx done, their results are collected.
+			for _, it := range queue {
+				failed[it.index] = batchCancelled(ctx, it.op)
+			}
+			queue, done = nil, nil
+		}
+		if stopTimer != nil {
+			stopTimer()
+			stopTimer = nil
+		}
+	}
+
+	return nil, failed
+}
+
+// batchCancelled is the error of an operation of a batch wait not done when ctx is.
+func batchCancelled(ctx context.Context, o *Operation) error {
+	return &WaitCancelledError{Operation: o, Cancel: CancelNotRequested, Err: ctx.Err()}
+}
+
+// poll polls the operation once, setting the interval before the next poll or the error
+// failing the operation.
+func (it *batchItem) poll(ctx context.Context, opts []grpc.CallOption) {
+	policy := retryPolicyOf(opts)
+	o := it.op
+	if err := pauseControllerOf(opts).wait(ctx, o); err != nil {
+		it.err = err
+		return
+	}
+	limiter := pollLimiterOf(opts)
+	if err := limiter.wait(ctx, o); err != nil {
+		it.err = err
+		return
+	}
+	var headers metadata.MD
+	var hinted time.Duration
+	it.attempts++
+	metrics, clock := metricsOf(opts), clockOf(nil, opts)
+	metrics.PollStarted(o)
+	limiter.polled()
+	started := clock.now()
+	pollCtx, err := withCallMetadata(ctx, it.calls, it.attempts)
+	attemptCtx, cancel := policy.attempt(pollCtx)
+	switch poll := pollFuncOf(opts); {
+	case err != nil:
+	case poll != nil:
+		hinted, err = o.pollWith(attemptCtx, poll)
+	default:
+		err = o.Poll(attemptCtx, append(append([]grpc.CallOption{retry.Disable()}, opts...), grpc.Header(&headers), WithCallMetadata(nil))...)
+	}
+	err = policy.attemptError(ctx, attemptCtx, o, err)
+	cancel()
+	metrics.PollFinished(o, err, clock.now().Sub(started))
+	it.err, it.interval = nil, DefaultPollInterval
+	if err != nil {
+		it.failures++
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if policy.retryable(ctx, err) && it.failures <= policy.MaxConsecutiveFailures {
+			return
+		}
+		it.err = &PollError{Operation: o, Attempts: it.failures, Err: err}
+		return
+	}
+	it.failures = 0
+	hint, ok := hinted, hinted > 0
+	if !ok {
+		hint, ok = o.intervalHint(headers, opts)
+	}
+	o.state.setSuggested(hint)
+	if ok && (hint > 0 || busyPollOf(opts)) {
+		it.interval = hint
+	}
+}
@@ -0,0 +1,361 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// batchPoll is a poll func of operations done after the given number of polls, suggesting
+// a millisecond interval. Operations without a number never complete.
+type batchPoll struct {
+	mu       sync.Mutex
+	polls    map[string]int
+	doneAt   map[string]int
+	failed   map[string]bool
+	errs     map[string]error
+	inFlight int32
+	maxIn    int32
+	interval map[string]time.Duration
+}
+
+func newBatchPoll() *batchPoll {
+	return &batchPoll{polls: map[string]int{}, doneAt: map[string]int{}, failed: map[string]bool{}, errs: map[string]error{}, interval: map[string]time.Duration{}}
+}
+
+func (p *batchPoll) poll(ctx context.Context, id string) (*Proto, time.Duration, error) {
+	in := atomic.AddInt32(&p.inFlight, 1)
+	defer atomic.AddInt32(&p.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&p.maxIn)
+		if in <= max || atomic.CompareAndSwapInt32(&p.maxIn, max, in) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.polls[id]++
+	if err := p.errs[id]; err != nil {
+		return nil, 0, err
+	}
+	interval := time.Millisecond
+	if d, ok := p.interval[id]; ok {
+		interval = d
+	}
+	op := &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}
+	if n, ok := p.doneAt[id]; ok && p.polls[id] >= n {
+		op.Status = doublecloud.Operation_STATUS_DONE
+		if p.failed[id] {
+			op.Error = &rpcstatus.Status{Code: int32(codes.Internal), Message: "boom"}
+		}
+	}
+	return op, interval, nil
+}
+
+func (p *batchPoll) pollsOf(id string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.polls[id]
+}
+
+func pendingOps(ids ...string) []*Operation {
+	ops := make([]*Operation, len(ids))
+	for i, id := range ids {
+		ops[i] = New(nil, &Proto{Id: id, Status: doublecloud.Operation_STATUS_PENDING})
+	}
+	return ops
+}
+
+func TestWaitAll(t *testing.T) {
+	p := newBatchPoll()
+	p.doneAt["vpc1"], p.doneAt["cho1"], p.doneAt["kfo1"] = 1, 3, 5
+	ops := pendingOps("vpc1", "cho1", "kfo1")
+
+	require.NoError(t, WaitAll(context.Background(), ops, WithPollFunc(p.poll)))
+	for _, o := range ops {
+		assert.True(t, o.Ok(), o.Id())
+	}
+	assert.Equal(t, 5, p.pollsOf("kfo1"))
+	assert.Equal(t, 1, p.pollsOf("vpc1"), "done operations are not polled again")
+}
+
+func TestWaitAll_Failures(t *testing.T) {
+	p := newBatchPoll()
+	p.doneAt["cho1"], p.doneAt["cho2"], p.doneAt["kfo1"] = 2, 2, 1
+	p.failed["cho2"] = true
+	p.errs["kfo2"] = status.Error(codes.PermissionDenied, "denied")
+	done := New(nil, &Proto{Id: "dtj1", Status: doublecloud.Operation_STATUS_DONE, Error: &rpcstatus.Status{Code: int32(codes.Aborted)}})
+	ops := append(pendingOps("cho1", "cho2", "kfo1", "kfo2"), done)
+
+	err := WaitAll(context.Background(), ops, WithPollFunc(p.poll))
+	require.ErrorIs(t, err, ErrBatchWait)
+	var batch *BatchWaitError
+	require.ErrorAs(t, err, &batch)
+	assert.Equal(t, 5, batch.Total)
+	assert.Equal(t, []string{"cho2", "kfo2", "dtj1"}, batch.FailedIDs(), "every failed operation is reported, in order")
+	assert.Equal(t, codes.Internal, status.Code(batch.Failures[0].Err))
+	assert.Equal(t, codes.PermissionDenied, status.Code(batch.Failures[1].Err))
+	assert.Contains(t, err.Error(), "3 of 5 operations failed")
+	assert.True(t, ops[0].Ok() && ops[2].Ok(), "the other operations are waited for")
+}
+
+func TestWaitAll_NotFoundRetried(t *testing.T) {
+	var polls int
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		if polls <= 2 {
+			return nil, 0, status.Error(codes.NotFound, "not replicated yet")
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+	}
+	start := time.Now()
+	require.NoError(t, WaitAll(context.Background(), pendingOps("cho1"), WithPollFunc(poll)))
+	assert.Equal(t, 3, polls)
+	assert.Less(t, time.Since(start), 3*DefaultPollInterval)
+}
+
+func TestWaitAll_Concurrency(t *testing.T) {
+	p := newBatchPoll()
+	var ids []string
+	for i := 0; i < 50; i++ {
+		id := "cho" + string(rune('A'+i))
+		ids = append(ids, id)
+		p.doneAt[id] = 3
+	}
+	require.NoError(t, WaitAll(context.Background(), pendingOps(ids...), WithPollFunc(p.poll), WithWaitConcurrency(3)))
+	assert.LessOrEqual(t, atomic.LoadInt32(&p.maxIn), int32(3))
+	assert.Greater(t, atomic.LoadInt32(&p.maxIn), int32(1), "the operations are polled concurrently")
+	for _, id := range ids {
+		assert.Equal(t, 3, p.pollsOf(id))
+	}
+
+	p = newBatchPoll()
+	p.doneAt["cho1"], p.doneAt["cho2"] = 3, 3
+	require.NoError(t, WaitAll(context.Background(), pendingOps("cho1", "cho2"), WithPollFunc(p.poll)))
+	assert.LessOrEqual(t, atomic.LoadInt32(&p.maxIn), int32(DefaultWaitConcurrency))
+}
+
+func TestWaitAll_PerOperationInterval(t *testing.T) {
+	p := newBatchPoll()
+	p.interval["cho1"] = 200 * time.Millisecond
+	p.doneAt["kfo1"] = 20
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		op, interval, err := p.poll(ctx, id)
+		if op.GetStatus() == doublecloud.Operation_STATUS_DONE {
+			cancel()
+		}
+		return op, interval, err
+	}
+
+	err := WaitAll(ctx, pendingOps("cho1", "kfo1"), WithPollFunc(poll))
+	assert.Equal(t, []string{"cho1"}, err.(*BatchWaitError).FailedIDs())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.LessOrEqual(t, p.pollsOf("cho1"), 2, "the slow operation is polled at its own interval")
+}
+
+func TestWaitAll_NonPositiveIntervalHint(t *testing.T) {
+	requireKinds(t)
+	for name, tc := range map[string]struct {
+		opts []grpc.CallOption
+		want []time.Duration
+	}{
+		"default":   {nil, []time.Duration{DefaultPollInterval, DefaultPollInterval}},
+		"busy poll": {[]grpc.CallOption{WithBusyPoll()}, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newFakeClock(t)
+			client := &hintKafkaClient{fakeKafkaClient: fakeKafkaClient{get: runningFor()}, hint: "0"}
+			ops := []*Operation{New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})}
+			require.NoError(t, WaitAll(context.Background(), ops, append(tc.opts, WithClock(c))...))
+			assert.Equal(t, tc.want, c.intervals)
+			assert.Equal(t, 3, client.calls())
+		})
+	}
+}
+
+func TestWaitAll_ContextDone(t *testing.T) {
+	p := newBatchPoll()
+	p.doneAt["cho1"] = 1
+	ops := pendingOps("cho1", "kfo1", "kfo2")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := WaitAll(ctx, ops, WithPollFunc(p.poll))
+	var batch *BatchWaitError
+	require.ErrorAs(t, err, &batch)
+	assert.Equal(t, []string{"kfo1", "kfo2"}, batch.FailedIDs())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	polls := p.pollsOf("kfo1")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, polls, p.pollsOf("kfo1"), "nothing is polled after WaitAll returns")
+}
+
+func TestWaitAny(t *testing.T) {
+	p := newBatchPoll()
+	p.doneAt["kfo1"] = 3
+	ops := pendingOps("cho1", "kfo1", "cho2")
+
+	first, err := WaitAny(context.Background(), ops, WithPollFunc(p.poll))
+	require.NoError(t, err)
+	assert.Same(t, ops[1], first)
+	polls := p.pollsOf("cho1")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, polls, p.pollsOf("cho1"), "the other operations are not polled after WaitAny returns")
+
+	p.doneAt["cho2"], p.failed["cho2"] = 1, true
+	first, err = WaitAny(context.Background(), pendingOps("cho1", "cho2"), WithPollFunc(p.poll))
+	require.Error(t, err)
+	assert.Equal(t, "cho2", first.Id())
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	done := New(nil, &Proto{Id: "dtj1", Status: doublecloud.Operation_STATUS_DONE})
+	first, err = WaitAny(context.Background(), append(pendingOps("cho1"), done), WithPollFunc(p.poll))
+	require.NoError(t, err)
+	assert.Same(t, done, first)
+}
+
+func TestWaitAny_NoneCompletes(t *testing.T) {
+	p := newBatchPoll()
+	p.errs["cho1"] = status.Error(codes.PermissionDenied, "denied")
+	p.errs["kfo1"] = status.Error(codes.Unavailable, "down")
+
+	first, err := WaitAny(context.Background(), pendingOps("cho1", "kfo1"), WithPollFunc(p.poll))
+	assert.Nil(t, first)
+	var batch *BatchWaitError
+	require.ErrorAs(t, err, &batch)
+	assert.Equal(t, []string{"cho1", "kfo1"}, batch.FailedIDs())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	first, err = WaitAny(ctx, pendingOps("cho1x"), WithPollFunc(newBatchPoll().poll))
+	assert.Nil(t, first)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitAll_NoOperationClient(t *testing.T) {
+	requireKinds(t)
+	err := WaitAll(context.Background(), pendingOps("cho1"))
+	assert.ErrorIs(t, err, ErrNoOperationClient)
+}
+
+func TestWaitAllResults(t *testing.T) {
+	p := newBatchPoll()
+	p.doneAt["cho1"] = 2
+	p.doneAt["cho2"], p.failed["cho2"] = 1, true
+	r, err := WaitAllResults(context.Background(), pendingOps("cho1", "cho2"), WithPollFunc(p.poll))
+	assert.Equal(t, []string{"cho2"}, err.(*BatchWaitError).FailedIDs())
+	require.Len(t, r.Outcomes, 2)
+	assert.NoError(t, r.Outcomes[0].Err)
+	assert.ErrorIs(t, r.Outcomes[1].Err, ErrOperationFailed)
+	assert.Equal(t, []string{"cho1"}, opIDs(r.Succeeded()))
+	assert.Equal(t, []string{"cho2"}, opIDs(r.Failed()))
+	assert.Empty(t, r.Cancelled())
+
+	r, err = WaitAllResults(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, r.Outcomes)
+}
+
+func TestWaitAllResults_Cancelled(t *testing.T) {
+	blockUntilDone := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		<-ctx.Done()
+		return nil, 0, ctx.Err()
+	}
+	for name, tc := range map[string]struct {
+		// cancelAt returns the poll func of the wait cancelling it with cancel.
+		cancelAt func(p *batchPoll, cancel func()) PollFunc
+		// pending are the operations of the wait, as opposed to the completed one.
+		pending   []string
+		succeeded []string
+		failed    []string
+		cancelled []string
+	}{
+		"before the wait": {
+			cancelAt: func(p *batchPoll, cancel func()) PollFunc {
+				cancel()
+				return p.poll
+			},
+			pending:   []string{"cho1", "kfo1"},
+			succeeded: []string{"chodone"},
+			cancelled: []string{"cho1", "kfo1"},
+		},
+		"after some operations are done": {
+			cancelAt: func(p *batchPoll, cancel func()) PollFunc {
+				return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+					if id == "kfo1" && p.pollsOf("cho1") > 0 && p.pollsOf("cho2") > 0 {
+						cancel()
+					}
+					return p.poll(ctx, id)
+				}
+			},
+			pending:   []string{"cho1", "cho2", "kfo1"},
+			succeeded: []string{"chodone", "cho1"},
+			failed:    []string{"cho2"},
+			cancelled: []string{"kfo1"},
+		},
+		"during a poll": {
+			cancelAt: func(p *batchPoll, cancel func()) PollFunc {
+				return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+					if id == "kfo1" {
+						cancel()
+						return blockUntilDone(ctx, id)
+					}
+					return p.poll(ctx, id)
+				}
+			},
+			pending:   []string{"kfo1"},
+			succeeded: []string{"chodone"},
+			cancelled: []string{"kfo1"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newBatchPoll()
+			p.doneAt["cho1"] = 1
+			p.doneAt["cho2"], p.failed["cho2"] = 1, true
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			poll := tc.cancelAt(p, cancel)
+			ops := append([]*Operation{New(nil, &Proto{Id: "chodone", Status: doublecloud.Operation_STATUS_DONE})}, pendingOps(tc.pending...)...)
+
+			r, err := WaitAllResults(ctx, ops, WithPollFunc(poll), WithWaitConcurrency(1))
+			require.Len(t, r.Outcomes, len(ops), "the results are always populated")
+			assert.ErrorIs(t, err, ErrWaitCancelled)
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.Equal(t, tc.succeeded, opIDs(r.Succeeded()))
+			assert.Equal(t, tc.failed, opIDs(r.Failed()))
+			assert.Equal(t, tc.cancelled, opIDs(r.Cancelled()))
+			for _, o := range r.Outcomes {
+				var cancelled *WaitCancelledError
+				if errors.As(o.Err, &cancelled) {
+					assert.Same(t, o.Operation, cancelled.Operation)
+					assert.Equal(t, CancelNotRequested, cancelled.Cancel)
+					assert.ErrorIs(t, cancelled, context.Canceled)
+				}
+			}
+		})
+	}
+}
+
+func opIDs(ops []*Operation) []string {
+	var ids []string
+	for _, o := range ops {
+		ids = append(ids, o.Id())
+	}
+	return ids
+}
@@ -0,0 +1,79 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// ErrCallbackPanicked is matched by errors.Is for every *CallbackPanicError.
+//
+// User callbacks run by the SDK, e.g. verify functions, credentials refreshers, request
+// decorators and publisher hooks, never panic through SDK internals. A recovered panic is
+// reported to the warning handler as *CallbackPanicError. It also fails the call that
+// depends on the callback's result, e.g. the poll, the wait or the verification.
+// Panics of callbacks whose result is not needed, like the dead-letter hook, are only reported.
+var ErrCallbackPanicked = errors.New("operation: callback panicked")
+
+// CallbackPanicError describes a panic recovered from a user callback.
+type CallbackPanicError struct {
+	// Callback names the callback, e.g. "verify".
+	Callback string
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *CallbackPanicError) Error() string {
+	return fmt.Sprintf("operation: %s callback panicked: %v", e.Callback, e.Value)
+}
+
+func (e *CallbackPanicError) Is(target error) bool { return target == ErrCallbackPanicked }
+
+var warnings = struct {
+	mu      sync.RWMutex
+	handler func(err error)
+}{}
+
+// SetWarningHandler sets the handler of errors the SDK can't return to the caller, such as
+// *CallbackPanicError. A nil handler restores the default one, logging with grpclog.
+func SetWarningHandler(handler func(err error)) {
+	warnings.mu.Lock()
+	defer warnings.mu.Unlock()
+	warnings.handler = handler
+}
+
+func warn(err error) {
+	warnings.mu.RLock()
+	handler := warnings.handler
+	warnings.mu.RUnlock()
+	if handler == nil {
+		grpclog.Warningf("%v\n%s", err, stackOf(err))
+		return
+	}
+	handler(err)
+}
+
+func stackOf(err error) []byte {
+	var p *CallbackPanicError
+	if errors.As(err, &p) {
+		return p.Stack
+	}
+	return nil
+}
+
+// SafeCall runs the user callback f, named callback in errors. A panic of f is recovered,
+// reported to the warning handler and returned as *CallbackPanicError.
+func SafeCall(callback string, f func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &CallbackPanicError{Callback: callback, Value: v, Stack: debug.Stack()}
+			warn(err)
+		}
+	}()
+	return f()
+}
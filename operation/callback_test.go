@@ -0,0 +1,143 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// warningLog records the errors passed to the warning handler until the test ends.
+type warningLog struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func captureWarnings(t *testing.T) *warningLog {
+	l := &warningLog{}
+	SetWarningHandler(func(err error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.errs = append(l.errs, err)
+	})
+	t.Cleanup(func() { SetWarningHandler(nil) })
+	return l
+}
+
+// callbacks returns the names of the callbacks reported as panicked.
+func (l *warningLog) callbacks() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var res []string
+	for _, err := range l.errs {
+		var p *CallbackPanicError
+		if errors.As(err, &p) {
+			res = append(res, p.Callback)
+		}
+	}
+	return res
+}
+
+func TestSafeCall(t *testing.T) {
+	warnings := captureWarnings(t)
+	assert.NoError(t, SafeCall("noop", func() error { return nil }))
+	assert.EqualError(t, SafeCall("failing", func() error { return errors.New("failed") }), "failed")
+
+	err := SafeCall("panicking", func() error { panic("boom") })
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+	assert.EqualError(t, err, "operation: panicking callback panicked: boom")
+	var p *CallbackPanicError
+	require.ErrorAs(t, err, &p)
+	assert.Contains(t, string(p.Stack), "TestSafeCall")
+	assert.Equal(t, []string{"panicking"}, warnings.callbacks())
+}
+
+func TestCallbackPanic_RequestDecorator(t *testing.T) {
+	requireKinds(t)
+	warnings := captureWarnings(t)
+	assert.ErrorIs(t, SetRequestDecorator(KindKafka, func(id string) proto.Message { panic("boom") }), ErrCallbackPanicked)
+
+	panicking := false
+	require.NoError(t, SetRequestDecorator(KindKafka, func(id string) proto.Message {
+		if panicking {
+			panic("boom")
+		}
+		return &kafka.GetOperationRequest{OperationId: id}
+	}))
+	t.Cleanup(func() { _ = SetRequestDecorator(KindKafka, nil) })
+	panicking = true
+
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+	err := pendingKafkaOp(client).Wait(context.Background())
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+	assert.Zero(t, client.calls(), "no request is sent without the decorated one")
+	assert.Equal(t, []string{"request decorator", "request decorator"}, warnings.callbacks())
+}
+
+func TestCallbackPanic_CredentialsRefresher(t *testing.T) {
+	requireKinds(t)
+	warnings := captureWarnings(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return nil, status.Error(codes.Unauthenticated, "token expired")
+	}}
+	op := pendingKafkaOp(client)
+	op.WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error { panic("boom") })
+
+	err := op.Wait(context.Background())
+	var fatal *FatalPollError
+	require.ErrorAs(t, err, &fatal)
+	assert.ErrorIs(t, fatal.RefreshErr, ErrCallbackPanicked)
+	assert.Equal(t, []string{"credentials refresher"}, warnings.callbacks())
+}
+
+func TestCallbackPanic_Verify(t *testing.T) {
+	warnings := captureWarnings(t)
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE})
+	err := WaitAndVerify(context.Background(), op, func(ctx context.Context) (bool, error) { panic("boom") }, VerifyConfig{Interval: time.Millisecond})
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+	assert.NotErrorIs(t, err, ErrVerificationTimeout)
+	assert.Equal(t, []string{"verify"}, warnings.callbacks())
+}
+
+func TestCallbackPanic_Publisher(t *testing.T) {
+	warnings := captureWarnings(t)
+	p := NewPublisher(PublisherConfig{
+		Publish: func(ctx context.Context, e Event) error {
+			if e.OperationID == "cho1" {
+				panic("boom")
+			}
+			return nil
+		},
+		MaxAttempts: 1,
+		DeadLetter: func(e Event, err error) {
+			assert.ErrorIs(t, err, ErrCallbackPanicked)
+			panic("dead letter boom")
+		},
+	})
+	assert.True(t, p.Enqueue(doneOp("cho1")))
+	assert.True(t, p.Enqueue(doneOp("cho2")))
+	require.NoError(t, p.Close(context.Background()))
+	assert.Equal(t, []string{"publish", "dead-letter"}, warnings.callbacks())
+}
+
+func TestCallbackPanic_ResubmitStart(t *testing.T) {
+	warnings := captureWarnings(t)
+	res, err := Resubmit(context.Background(), ResubmitPolicy{MaxAttempts: 3}, func(ctx context.Context) (*Operation, error) {
+		panic("boom")
+	})
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+	assert.Empty(t, res.Operations)
+	assert.Equal(t, []string{"resubmit start"}, warnings.callbacks())
+}
@@ -0,0 +1,104 @@
+package operation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// RequestIDMetadataKey is the metadata key of the ID of every poll of WithRequestIDs.
+	RequestIDMetadataKey = "x-request-id"
+	// CorrelationIDMetadataKey is the metadata key of the ID shared by the polls of a wait
+	// of WithRequestIDs.
+	CorrelationIDMetadataKey = "x-correlation-id"
+)
+
+// CallMetadataFunc returns the metadata of a poll, with the 1-based number of the poll in
+// the wait, 1 for Poll.
+type CallMetadataFunc func(ctx context.Context, attempt int) metadata.MD
+
+// WithCallMetadata makes Poll and waits call f before every poll and send the metadata it
+// returns with the poll, e.g. for tracing. The metadata is appended to the outgoing metadata
+// of ctx, which is kept. f is called by the poll, so slow functions delay it.
+func WithCallMetadata(f CallMetadataFunc) grpc.CallOption {
+	return &callMetadata{newWait: func() CallMetadataFunc { return f }}
+}
+
+// WithRequestIDs is WithCallMetadata sending a new random UUID with every poll under
+// RequestIDMetadataKey, and an UUID of the wait with all its polls under
+// CorrelationIDMetadataKey. The wait keeps the correlation ID of the outgoing metadata of ctx,
+// if any, so that the polls can be correlated with the calls of the caller.
+func WithRequestIDs() grpc.CallOption {
+	return &callMetadata{newWait: func() CallMetadataFunc {
+		correlationID := newUUID()
+		return func(ctx context.Context, attempt int) metadata.MD {
+			md := metadata.Pairs(RequestIDMetadataKey, newUUID())
+			if out, _ := metadata.FromOutgoingContext(ctx); len(out.Get(CorrelationIDMetadataKey)) == 0 {
+				md.Append(CorrelationIDMetadataKey, correlationID)
+			}
+			return md
+		}
+	}}
+}
+
+type callMetadata struct {
+	grpc.EmptyCallOption
+	// newWait returns the function of a single wait.
+	newWait func() CallMetadataFunc
+}
+
+// callMetadataOf returns the function of a wait made with opts, nil if there is none.
+func callMetadataOf(opts []grpc.CallOption) CallMetadataFunc {
+	var option *callMetadata
+	for _, o := range opts {
+		if o, ok := o.(*callMetadata); ok {
+			option = o
+		}
+	}
+	if option == nil {
+		return nil
+	}
+	return option.newWait()
+}
+
+// withCallMetadata returns ctx with the metadata of f for the attempt appended.
+func withCallMetadata(ctx context.Context, f CallMetadataFunc, attempt int) (context.Context, error) {
+	if f == nil {
+		return ctx, nil
+	}
+	var md metadata.MD
+	err := SafeCall("call metadata", func() error {
+		md = f(ctx, attempt)
+		return nil
+	})
+	if err != nil {
+		return ctx, err
+	}
+	var kv []string
+	for k, vals := range md {
+		for _, v := range vals {
+			kv = append(kv, k, v)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx, nil
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...), nil
+}
+
+// newUUID returns a random UUID of version 4. It is made here, not with a uuid package, so
+// that builds with the operation_nokinds tag don't depend on one, see kinds.go.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
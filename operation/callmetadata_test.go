@@ -0,0 +1,115 @@
+package operation
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataKafkaClient records the outgoing metadata of the polls.
+type metadataKafkaClient struct {
+	fakeKafkaClient
+	sent []metadata.MD
+}
+
+func (c *metadataKafkaClient) Get(ctx context.Context, in *kafka.GetOperationRequest, opts ...grpc.CallOption) (*Proto, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	c.sent = append(c.sent, md)
+	return c.fakeKafkaClient.Get(ctx, in, opts...)
+}
+
+// doneAfter returns a client whose operation is done on the n-th poll.
+func doneAfter(n int) *metadataKafkaClient {
+	return &metadataKafkaClient{fakeKafkaClient: fakeKafkaClient{get: func(i int, id string) (*Proto, error) {
+		if i < n {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}}
+}
+
+func attemptMetadata(ctx context.Context, attempt int) metadata.MD {
+	return metadata.Pairs("x-attempt", strconv.Itoa(attempt))
+}
+
+func TestWait_CallMetadata(t *testing.T) {
+	requireKinds(t)
+	client := doneAfter(3)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "t1", "x-attempt", "caller")
+	require.NoError(t, pendingKafkaOp(client).Wait(ctx, WithCallMetadata(attemptMetadata)))
+
+	require.Len(t, client.sent, 3)
+	for i, md := range client.sent {
+		assert.Equal(t, []string{"t1"}, md.Get("x-tenant"), "the metadata of the caller is kept")
+		assert.Equal(t, []string{"caller", strconv.Itoa(i + 1)}, md.Get("x-attempt"), "appended once per poll")
+	}
+}
+
+func TestPoll_CallMetadata(t *testing.T) {
+	requireKinds(t)
+	client := doneAfter(1)
+	require.NoError(t, pendingKafkaOp(client).Poll(context.Background(), WithCallMetadata(attemptMetadata)))
+	require.Len(t, client.sent, 1)
+	assert.Equal(t, []string{"1"}, client.sent[0].Get("x-attempt"))
+}
+
+const uuidPattern = `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`
+
+func TestWait_RequestIDs(t *testing.T) {
+	requireKinds(t)
+	client := doneAfter(3)
+	require.NoError(t, pendingKafkaOp(client).Wait(context.Background(), WithRequestIDs()))
+
+	require.Len(t, client.sent, 3)
+	requestIDs := map[string]bool{}
+	for _, md := range client.sent {
+		require.Len(t, md.Get(RequestIDMetadataKey), 1)
+		requestIDs[md.Get(RequestIDMetadataKey)[0]] = true
+		assert.Regexp(t, uuidPattern, md.Get(RequestIDMetadataKey)[0])
+		assert.Equal(t, client.sent[0].Get(CorrelationIDMetadataKey), md.Get(CorrelationIDMetadataKey), "the polls of the wait are correlated")
+	}
+	assert.Len(t, requestIDs, 3, "every poll has its own request ID")
+	require.Len(t, client.sent[0].Get(CorrelationIDMetadataKey), 1)
+
+	other := doneAfter(1)
+	require.NoError(t, pendingKafkaOp(other).Wait(context.Background(), WithRequestIDs()))
+	assert.NotEqual(t, client.sent[0].Get(CorrelationIDMetadataKey), other.sent[0].Get(CorrelationIDMetadataKey), "every wait has its own correlation ID")
+}
+
+func TestWait_RequestIDsKeepCorrelationID(t *testing.T) {
+	requireKinds(t)
+	client := doneAfter(2)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), CorrelationIDMetadataKey, "caller")
+	require.NoError(t, pendingKafkaOp(client).Wait(ctx, WithRequestIDs()))
+	for _, md := range client.sent {
+		assert.Equal(t, []string{"caller"}, md.Get(CorrelationIDMetadataKey))
+		assert.Len(t, md.Get(RequestIDMetadataKey), 1)
+	}
+}
+
+func TestWait_CallMetadataPanic(t *testing.T) {
+	client := doneAfter(1)
+	err := pendingKafkaOp(client).Wait(context.Background(), WithCallMetadata(func(context.Context, int) metadata.MD { panic("boom") }))
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+	assert.ErrorIs(t, err, ErrPoll)
+	assert.Empty(t, client.sent)
+}
+
+func TestWaitAll_CallMetadata(t *testing.T) {
+	poll := scriptedPoll(pollStep{status: doublecloud.Operation_STATUS_RUNNING, hint: time.Millisecond}, pollStep{status: doublecloud.Operation_STATUS_DONE})
+	var attempts []int
+	calls := WithCallMetadata(func(ctx context.Context, attempt int) metadata.MD {
+		attempts = append(attempts, attempt)
+		return nil
+	})
+	require.NoError(t, WaitAll(context.Background(), []*Operation{pendingKafkaOp(nil)}, WithPollFunc(poll), calls))
+	assert.Equal(t, []int{1, 2}, attempts)
+}
@@ -0,0 +1,152 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultAbandonCancelTimeout bounds the Cancel call of waits abandoned with WithCancelOnAbandon.
+const DefaultAbandonCancelTimeout = 10 * time.Second
+
+var (
+	// ErrCancelUnsupported is returned by Cancel if the operation client can't cancel operations.
+	ErrCancelUnsupported = errors.New("operation: cancel unsupported")
+	// ErrWaitCancelled is matched by errors.Is for every *WaitCancelledError.
+	ErrWaitCancelled = errors.New("operation: wait cancelled")
+)
+
+// CancelClient is implemented by operation clients able to cancel running operations.
+type CancelClient interface {
+	CancelOperation(ctx context.Context, operationID string, opts ...grpc.CallOption) (*Proto, error)
+}
+
+// Cancel requests the cancellation of the operation, without waiting for it to end.
+// On success the operation state is updated. It returns ErrCancelUnsupported if the
+// client doesn't implement CancelClient or the server doesn't implement the call.
+func (o *Operation) Cancel(ctx context.Context, opts ...grpc.CallOption) error {
+	client, ok := o.Client().(CancelClient)
+	if !ok {
+		return ErrCancelUnsupported
+	}
+	state, err := client.CancelOperation(ctx, o.Id(), opts...)
+	if status.Code(err) == codes.Unimplemented {
+		return ErrCancelUnsupported
+	}
+	if err != nil {
+		return sdkerrors.WithMessagef(err, "%s cancel", o)
+	}
+	o.state.store(state)
+	return nil
+}
+
+// WithCancelOnAbandon makes the wait cancel the operation with a best-effort Cancel if
+// the wait's ctx is done before the operation. The cancel is made on a context detached from
+// the cancellation of ctx, keeping its values, bounded by DefaultAbandonCancelTimeout, and the
+// wait returns *WaitCancelledError with the outcome. Disabled by default.
+func WithCancelOnAbandon(enabled bool) grpc.CallOption {
+	return &cancelOnAbandon{enabled: enabled}
+}
+
+// WaitOrCancel waits for the operation like Wait and cancels it if ctx is done first, e.g. on
+// Ctrl-C: it is Wait with WithCancelOnAbandon(true).
+func (o *Operation) WaitOrCancel(ctx context.Context, opts ...grpc.CallOption) error {
+	return o.Wait(ctx, append(append([]grpc.CallOption(nil), opts...), WithCancelOnAbandon(true))...)
+}
+
+type cancelOnAbandon struct {
+	grpc.EmptyCallOption
+	enabled bool
+}
+
+func cancelOnAbandonOf(opts []grpc.CallOption) bool {
+	var enabled bool
+	for _, o := range opts {
+		if o, ok := o.(*cancelOnAbandon); ok {
+			enabled = o.enabled
+		}
+	}
+	return enabled
+}
+
+// CancelOutcome is the result of the Cancel made by an abandoned wait.
+type CancelOutcome int
+
+const (
+	CancelSent CancelOutcome = iota
+	CancelFailed
+	CancelUnsupported
+	// CancelNotRequested is the outcome of the operations of batch waits, which aren't
+	// cancelled when the context of the wait is done.
+	CancelNotRequested
+)
+
+func (c CancelOutcome) String() string {
+	switch c {
+	case CancelSent:
+		return "cancel sent"
+	case CancelFailed:
+		return "cancel failed"
+	case CancelUnsupported:
+		return "cancel unsupported"
+	case CancelNotRequested:
+		return "cancel not requested"
+	default:
+		return "unknown"
+	}
+}
+
+// WaitCancelledError is returned by waits with WithCancelOnAbandon whose context is done
+// before the operation, and for the operations of batch waits not done when their context
+// is, see WaitAllResults.
+type WaitCancelledError struct {
+	Operation *Operation
+	Cancel    CancelOutcome
+	// CancelErr is the error of the Cancel call, if it failed.
+	CancelErr error
+	// Err is the error of the wait, caused by its context.
+	Err error
+}
+
+func (e *WaitCancelledError) Error() string {
+	msg := fmt.Sprintf("%s wait context done: %v; %s", e.Operation, e.Err, e.Cancel)
+	if e.Cancel == CancelFailed {
+		msg += ": " + e.CancelErr.Error()
+	}
+	return msg
+}
+
+func (e *WaitCancelledError) Is(target error) bool { return target == ErrWaitCancelled }
+func (e *WaitCancelledError) Unwrap() error        { return e.Err }
+
+// abandon cancels the operation of a wait ended by its done context.
+func (o *Operation) abandon(waitCtx context.Context, opts []grpc.CallOption) error {
+	ctx, cancel := context.WithTimeout(detach(waitCtx), DefaultAbandonCancelTimeout)
+	defer cancel()
+	werr := &WaitCancelledError{Operation: o, Err: waitCtx.Err()}
+	switch cerr := o.Cancel(ctx, opts...); {
+	case errors.Is(cerr, ErrCancelUnsupported):
+		werr.Cancel = CancelUnsupported
+	case cerr != nil:
+		werr.Cancel, werr.CancelErr = CancelFailed, cerr
+	}
+	return werr
+}
+
+// detachedContext keeps the values of its parent, such as the credentials of the SDK, but not
+// its deadline and cancellation.
+type detachedContext struct{ parent context.Context }
+
+func detach(ctx context.Context) context.Context { return detachedContext{parent: ctx} }
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
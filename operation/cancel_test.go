@@ -0,0 +1,125 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// cancellableKafkaClient is a fakeKafkaClient answering CancelOperation with err.
+type cancellableKafkaClient struct {
+	*fakeKafkaClient
+	err       error
+	cancelled []string
+	// ctxErr is the error of the cancel call context and value its abandonKey value.
+	ctxErr error
+	value  interface{}
+}
+
+type abandonKey struct{}
+
+func (c *cancellableKafkaClient) CancelOperation(ctx context.Context, operationID string, opts ...grpc.CallOption) (*Proto, error) {
+	c.cancelled = append(c.cancelled, operationID)
+	c.ctxErr = ctx.Err()
+	c.value = ctx.Value(abandonKey{})
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &Proto{Id: operationID, Status: doublecloud.Operation_STATUS_PENDING}, nil
+}
+
+func pendingForever() *fakeKafkaClient {
+	return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_PENDING}, nil
+	}}
+}
+
+func abandonedWait(t *testing.T, client Client, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pendingKafkaOp(client).Wait(ctx, opts...)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	return err
+}
+
+func TestWait_CancelOnAbandon(t *testing.T) {
+	requireKinds(t)
+	t.Run("sent", func(t *testing.T) {
+		client := &cancellableKafkaClient{fakeKafkaClient: pendingForever()}
+		err := abandonedWait(t, client, WithCancelOnAbandon(true))
+		var werr *WaitCancelledError
+		require.ErrorAs(t, err, &werr)
+		assert.ErrorIs(t, err, ErrWaitCancelled)
+		assert.Equal(t, CancelSent, werr.Cancel)
+		assert.Equal(t, []string{"kfo1"}, client.cancelled)
+		assert.NoError(t, client.ctxErr, "cancel is made on a detached context")
+		assert.EqualError(t, err, "operation (id=kfo1) wait context done: context deadline exceeded; cancel sent")
+	})
+	t.Run("context values", func(t *testing.T) {
+		client := &cancellableKafkaClient{fakeKafkaClient: pendingForever()}
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), abandonKey{}, "tenant"), 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, pendingKafkaOp(client).Wait(ctx, WithCancelOnAbandon(true)), ErrWaitCancelled)
+		assert.Equal(t, "tenant", client.value, "the detached context keeps the values of the wait context")
+	})
+	t.Run("failed", func(t *testing.T) {
+		client := &cancellableKafkaClient{fakeKafkaClient: pendingForever(), err: status.Error(codes.FailedPrecondition, "too late")}
+		err := abandonedWait(t, client, WithCancelOnAbandon(true))
+		var werr *WaitCancelledError
+		require.ErrorAs(t, err, &werr)
+		assert.Equal(t, CancelFailed, werr.Cancel)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(werr.CancelErr))
+		assert.Contains(t, err.Error(), "; cancel failed: operation (id=kfo1) cancel: ")
+	})
+	t.Run("unimplemented", func(t *testing.T) {
+		client := &cancellableKafkaClient{fakeKafkaClient: pendingForever(), err: status.Error(codes.Unimplemented, "no cancel")}
+		var werr *WaitCancelledError
+		require.ErrorAs(t, abandonedWait(t, client, WithCancelOnAbandon(true)), &werr)
+		assert.Equal(t, CancelUnsupported, werr.Cancel)
+		assert.NoError(t, werr.CancelErr)
+	})
+	t.Run("unsupported client", func(t *testing.T) {
+		var werr *WaitCancelledError
+		require.ErrorAs(t, abandonedWait(t, pendingForever(), WithCancelOnAbandon(true)), &werr)
+		assert.Equal(t, CancelUnsupported, werr.Cancel)
+	})
+	t.Run("disabled by default", func(t *testing.T) {
+		client := &cancellableKafkaClient{fakeKafkaClient: pendingForever()}
+		assert.NotErrorIs(t, abandonedWait(t, client), ErrWaitCancelled)
+		assert.NotErrorIs(t, abandonedWait(t, client, WithCancelOnAbandon(true), WithCancelOnAbandon(false)), ErrWaitCancelled)
+		assert.Empty(t, client.cancelled)
+	})
+}
+
+func TestWaitOrCancel(t *testing.T) {
+	requireKinds(t)
+	client := &cancellableKafkaClient{fakeKafkaClient: pendingForever()}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pendingKafkaOp(client).WaitOrCancel(ctx)
+	var werr *WaitCancelledError
+	require.ErrorAs(t, err, &werr)
+	assert.Equal(t, CancelSent, werr.Cancel)
+	assert.Equal(t, []string{"kfo1"}, client.cancelled)
+
+	client = &cancellableKafkaClient{fakeKafkaClient: pendingForever()}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, pendingKafkaOp(client).WaitOrCancel(ctx, WithCancelOnAbandon(false)), ErrWaitCancelled, "the cancel can't be disabled")
+}
+
+func TestCancel(t *testing.T) {
+	client := &cancellableKafkaClient{fakeKafkaClient: pendingForever()}
+	op := pendingKafkaOp(client)
+	require.NoError(t, op.Cancel(context.Background()))
+	assert.Equal(t, []string{"kfo1"}, client.cancelled)
+
+	assert.ErrorIs(t, pendingKafkaOp(pendingForever()).Cancel(context.Background()), ErrCancelUnsupported)
+}
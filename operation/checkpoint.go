@@ -0,0 +1,36 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// JSONVersion is the version of the format of MarshalJSON. UnmarshalJSON decodes it and the
+// formats of the SDK versions before, see CheckpointVersionError.
+//
+// Version 0 is the unversioned format written before the format was versioned, decoded
+// like version 1.
+const JSONVersion = 1
+
+// ErrCheckpointVersionUnsupported is matched by errors.Is for every *CheckpointVersionError.
+var ErrCheckpointVersionUnsupported = errors.New("operation: checkpoint version unsupported")
+
+// CheckpointVersionError is returned by the decoding of persisted states, e.g. of
+// UnmarshalJSON, of a format version this SDK doesn't know, e.g. written by a newer SDK
+// before a rollback.
+type CheckpointVersionError struct {
+	// Format names the persisted state, e.g. "operation".
+	Format string
+	// Version is the version of the persisted state, MaxVersion the latest version this
+	// SDK decodes.
+	Version    int
+	MaxVersion int
+}
+
+func (e *CheckpointVersionError) Error() string {
+	return fmt.Sprintf("unsupported %s checkpoint version %d, this SDK supports up to %d", e.Format, e.Version, e.MaxVersion)
+}
+
+func (e *CheckpointVersionError) Is(target error) bool {
+	return target == ErrCheckpointVersionUnsupported
+}
@@ -0,0 +1,78 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var update = flag.Bool("update", false, "rewrite the checkpoint fixture of the current JSONVersion and the golden files")
+
+// checkpointOperation is the operation of the checkpoint fixtures.
+func checkpointOperation() *Operation {
+	return New(nil, &Proto{
+		Id:          "cho1a2b3c4d5e6f7g8h9",
+		ProjectId:   "prj1a2b3c4d5e6f7g8h",
+		Description: "Create cluster",
+		CreatedBy:   "usr9z8y7x6w5v4u3t2s1",
+		CreateTime:  timestamppb.New(time.Date(2023, 5, 12, 14, 30, 11, 0, time.UTC)),
+		Metadata:    map[string]string{"cluster_id": "chcl9x8w7v6u5t4s3r2q"},
+		Status:      doublecloud.Operation_STATUS_RUNNING,
+		ResourceId:  "chcl9x8w7v6u5t4s3r2q",
+	}).WithOrigin("/doublecloud.clickhouse.v1.ClusterService/Create", "prj1a2b3c4d5e6f7g8h/analytics")
+}
+
+// TestMarshalJSON_CurrentVersion checks that the encoding of JSONVersion is still the one of
+// its fixture: changing the format requires a new version, with its fixture written by -update,
+// keeping the fixtures of the versions before. Run with -update to write the fixture.
+func TestMarshalJSON_CurrentVersion(t *testing.T) {
+	data, err := json.Marshal(checkpointOperation())
+	require.NoError(t, err)
+	fixture := filepath.Join("testdata", "checkpoints", fmt.Sprintf("operation_v%d.json", JSONVersion))
+	if *update {
+		var indented bytes.Buffer
+		require.NoError(t, json.Indent(&indented, data, "", "  "))
+		indented.WriteByte('\n')
+		require.NoError(t, os.WriteFile(fixture, indented.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(data))
+}
+
+// TestUnmarshalJSON_Versions checks that the fixtures of every version supported decode.
+func TestUnmarshalJSON_Versions(t *testing.T) {
+	want := checkpointOperation()
+	for v := 0; v <= JSONVersion; v++ {
+		t.Run(fmt.Sprintf("v%d", v), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "checkpoints", fmt.Sprintf("operation_v%d.json", v)))
+			require.NoError(t, err)
+			var o Operation
+			require.NoError(t, json.Unmarshal(data, &o))
+			assert.Equal(t, want.Snapshot(), o.Snapshot())
+			assert.Equal(t, want.Metadata(), o.Metadata())
+			assert.Equal(t, want.String(), o.String(), "the origin is kept")
+			assert.False(t, o.Done())
+		})
+	}
+}
+
+func TestUnmarshalJSON_FutureVersion(t *testing.T) {
+	var o Operation
+	err := json.Unmarshal([]byte(`{"version": 99, "operation": {"id": "cho1", "status": "STATUS_RUNNING"}, "lease": {}}`), &o)
+	var versionErr *CheckpointVersionError
+	require.ErrorAs(t, err, &versionErr)
+	assert.ErrorIs(t, err, ErrCheckpointVersionUnsupported)
+	assert.Equal(t, 99, versionErr.Version)
+	assert.EqualError(t, err, fmt.Sprintf("unsupported operation checkpoint version 99, this SDK supports up to %d", JSONVersion))
+}
@@ -0,0 +1,193 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultCheckpointInterval is the least time between the checkpoints of a wait saved by
+	// WithCheckpointStore.
+	DefaultCheckpointInterval = 10 * time.Second
+	// DefaultCheckpointSaveTimeout bounds the last save or the delete of the checkpoint of a
+	// wait, made once the wait returns, however its context ended.
+	DefaultCheckpointSaveTimeout = 5 * time.Second
+)
+
+// ErrCheckpointNotFound is returned by CheckpointStore.Load for keys with no checkpoint.
+var ErrCheckpointNotFound = errors.New("operation: checkpoint not found")
+
+// CheckpointStore stores the checkpoints of waits, e.g. in Redis or a database, to resume
+// them after a restart. The implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Save stores the checkpoint under the key, replacing the one stored before.
+	Save(ctx context.Context, key string, cp *Checkpoint) error
+	// Load returns the checkpoint of the key, or ErrCheckpointNotFound.
+	Load(ctx context.Context, key string) (*Checkpoint, error)
+	// Delete removes the checkpoint of the key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// MemoryCheckpointStore is a CheckpointStore in memory, e.g. for tests.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]*Checkpoint
+	saves       map[string]int
+}
+
+// NewMemoryCheckpointStore returns an empty store.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: map[string]*Checkpoint{}, saves: map[string]int{}}
+}
+
+func (s *MemoryCheckpointStore) Save(ctx context.Context, key string, cp *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := *cp
+	saved.State = append([]byte(nil), cp.State...)
+	s.checkpoints[key] = &saved
+	s.saves[key]++
+	return nil
+}
+
+func (s *MemoryCheckpointStore) Load(ctx context.Context, key string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.checkpoints[key]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", key, ErrCheckpointNotFound)
+	}
+	loaded := *cp
+	loaded.State = append([]byte(nil), cp.State...)
+	return &loaded, nil
+}
+
+func (s *MemoryCheckpointStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.checkpoints, key)
+	return nil
+}
+
+func (s *MemoryCheckpointStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.checkpoints {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Saves returns the number of saves of the key so far, deleted or not.
+func (s *MemoryCheckpointStore) Saves(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves[key]
+}
+
+// WithCheckpointStore makes the wait save the checkpoint of the operation, see
+// NewCheckpoint, to the store under the key after its polls, at most once per interval, or
+// DefaultCheckpointInterval for a non-positive one. Once the wait returns, the checkpoint is
+// deleted if the operation is done and saved a last time otherwise, e.g. when ctx is
+// canceled on shutdown. The failures of the store are passed to the warning handler, see
+// SetWarningHandler: they never fail the wait.
+func WithCheckpointStore(store CheckpointStore, key string, interval time.Duration) grpc.CallOption {
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	return &checkpointStoreOption{store: store, key: key, interval: interval}
+}
+
+type checkpointStoreOption struct {
+	grpc.EmptyCallOption
+	store    CheckpointStore
+	key      string
+	interval time.Duration
+}
+
+func checkpointStoreOf(opts []grpc.CallOption) *checkpointStoreOption {
+	var c *checkpointStoreOption
+	for _, o := range opts {
+		if o, ok := o.(*checkpointStoreOption); ok {
+			c = o
+		}
+	}
+	if c == nil || c.store == nil {
+		return nil
+	}
+	return c
+}
+
+// waitCheckpoints saves the checkpoints of a wait. A nil *waitCheckpoints saves nothing.
+type waitCheckpoints struct {
+	*checkpointStoreOption
+	now func() time.Time
+	// saved is the time of the last save, if anySaved.
+	saved    time.Time
+	anySaved bool
+}
+
+func newWaitCheckpoints(opts []grpc.CallOption, now func() time.Time) *waitCheckpoints {
+	c := checkpointStoreOf(opts)
+	if c == nil {
+		return nil
+	}
+	return &waitCheckpoints{checkpointStoreOption: c, now: now}
+}
+
+// polled saves the checkpoint of the operation unless one was saved less than the interval
+// ago.
+func (c *waitCheckpoints) polled(ctx context.Context, o *Operation) {
+	if c == nil || o.Done() || ctx.Err() != nil {
+		// The last checkpoint is saved by finish.
+		return
+	}
+	if now := c.now(); !c.anySaved || now.Sub(c.saved) >= c.interval {
+		c.saved, c.anySaved = now, true
+		c.save(ctx, o)
+	}
+}
+
+// finish deletes the checkpoint of the done operation, and saves the one of the operation
+// still running.
+func (c *waitCheckpoints) finish(ctx context.Context, o *Operation) {
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(detach(ctx), DefaultCheckpointSaveTimeout)
+	defer cancel()
+	if !o.Done() {
+		c.save(ctx, o)
+		return
+	}
+	err := SafeCall("checkpoint store", func() error { return c.store.Delete(ctx, c.key) })
+	if err != nil {
+		warn(fmt.Errorf("%s: delete checkpoint %q: %w", o, c.key, err))
+	}
+}
+
+func (c *waitCheckpoints) save(ctx context.Context, o *Operation) {
+	err := SafeCall("checkpoint store", func() error {
+		cp, err := NewCheckpoint(o)
+		if err != nil {
+			return err
+		}
+		return c.store.Save(ctx, c.key, cp)
+	})
+	if err != nil {
+		warn(fmt.Errorf("%s: save checkpoint %q: %w", o, c.key, err))
+	}
+}
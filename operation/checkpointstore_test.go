@@ -0,0 +1,104 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// savedAttempts records the polls of the wait at which the checkpoints were saved.
+type savedAttempts struct {
+	*MemoryCheckpointStore
+	polls *int
+	at    []int
+}
+
+func (s *savedAttempts) Save(ctx context.Context, key string, cp *Checkpoint) error {
+	s.at = append(s.at, *s.polls)
+	return s.MemoryCheckpointStore.Save(ctx, key, cp)
+}
+
+func TestWithCheckpointStore_Cadence(t *testing.T) {
+	var polls int
+	poll := pollsUntilDone(9)
+	counted := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		return poll(ctx, id)
+	}
+	store := &savedAttempts{MemoryCheckpointStore: NewMemoryCheckpointStore(), polls: &polls}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+
+	err := op.WaitInterval(context.Background(), 4*time.Second, WithClock(&stepClock{}), WithPollFunc(counted),
+		WithCheckpointStore(store, "waits/create", 10*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4, 7}, store.at, "polls at 0s, 12s and 24s, at most once per 10s")
+	keys, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	assert.Empty(t, keys, "the checkpoint of the done operation is deleted")
+}
+
+func TestWithCheckpointStore_SavedOnStop(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	var polls int
+	poll := func(pollCtx context.Context, id string) (*Proto, time.Duration, error) {
+		if polls++; polls == 3 {
+			cancel()
+			return nil, 0, ctx.Err()
+		}
+		return &Proto{Id: id, Description: "Create cluster", Status: doublecloud.Operation_STATUS_RUNNING}, 0, nil
+	}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	err := op.WaitInterval(ctx, time.Second, WithClock(&stepClock{}), WithPollFunc(poll), WithCheckpointStore(store, "waits/create", time.Hour))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 2, store.Saves("waits/create"), "the first poll and the stop")
+
+	cp, err := store.Load(context.Background(), "waits/create")
+	require.NoError(t, err)
+	resumed, err := cp.Operation(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "cho1", resumed.Id())
+	assert.Equal(t, "Create cluster", resumed.Proto().GetDescription(), "the last state of the wait")
+	assert.False(t, resumed.Done())
+	require.NoError(t, resumed.Wait(context.Background(), WithPollFunc(pollsUntilDone(1))))
+	assert.True(t, resumed.Ok())
+
+	_, err = store.Load(context.Background(), "waits/other")
+	assert.ErrorIs(t, err, ErrCheckpointNotFound)
+}
+
+// failingStore fails every call.
+type failingStore struct{ MemoryCheckpointStore }
+
+var errStoreDown = errors.New("store down")
+
+func (*failingStore) Save(ctx context.Context, key string, cp *Checkpoint) error { return errStoreDown }
+func (*failingStore) Delete(ctx context.Context, key string) error               { return errStoreDown }
+
+func TestWithCheckpointStore_FailuresWarn(t *testing.T) {
+	var mu sync.Mutex
+	var warned []error
+	SetWarningHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		warned = append(warned, err)
+	})
+	defer SetWarningHandler(nil)
+
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	err := op.WaitInterval(context.Background(), time.Second, WithClock(&stepClock{}), WithPollFunc(pollsUntilDone(3)),
+		WithCheckpointStore(&failingStore{}, "waits/create", time.Nanosecond))
+	require.NoError(t, err, "the failures of the store don't fail the wait")
+	assert.True(t, op.Ok())
+	require.Len(t, warned, 3, "2 saves and the delete")
+	for _, err := range warned {
+		assert.ErrorIs(t, err, errStoreDown)
+	}
+	assert.Contains(t, warned[2].Error(), `delete checkpoint "waits/create"`)
+}
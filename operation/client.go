@@ -0,0 +1,336 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+)
+
+// ErrNoOperationClient is matched by errors.Is for every *NoOperationClientError.
+var ErrNoOperationClient = errors.New("operation: no operation client")
+
+// NoOperationClientError is returned by Poll and waits of an operation whose client is nil
+// or doesn't implement the operation service client of the operation kind.
+type NoOperationClientError struct {
+	Operation *Operation
+	// Kind is the service of the operation, e.g. KindClickHouse.
+	Kind string
+	// Expected is the client interface required for the kind, e.g. "clickhouse.OperationServiceClient".
+	Expected string
+}
+
+func (e *NoOperationClientError) Error() string {
+	return fmt.Sprintf("%s has no %s operation client: %s required", e.Operation, e.Kind, e.Expected)
+}
+
+func (e *NoOperationClientError) Is(target error) bool { return target == ErrNoOperationClient }
+
+// ErrUnknownOperationType is matched by errors.Is for every *UnknownOperationTypeError.
+var ErrUnknownOperationType = errors.New("operation: unknown operation type")
+
+// UnknownOperationTypeError is returned by Poll and waits of an operation whose ID matches
+// no registered kind or resolver, see RegisterKind and RegisterResolver.
+type UnknownOperationTypeError struct {
+	Operation *Operation
+	// ID is the ID of the operation.
+	ID string
+	// Prefix is the leading letters of the ID, e.g. "afo" of "afo1", the prefix a resolver
+	// would have to be registered for; empty if the ID doesn't start with a letter.
+	Prefix string
+	// Available are the kinds whose operation service client the client of the operation
+	// implements, in registration order.
+	Available []string
+}
+
+func newUnknownOperationTypeError(o *Operation) *UnknownOperationTypeError {
+	e := &UnknownOperationTypeError{Operation: o, ID: o.Id()}
+	e.Prefix = e.ID
+	if i := strings.IndexFunc(e.ID, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+		e.Prefix = e.ID[:i]
+	}
+	e.Available = capabilities(o.Client())
+	return e
+}
+
+func (e *UnknownOperationTypeError) Error() string {
+	msg := fmt.Sprintf("%s unknown type", e.Operation)
+	if e.Prefix != "" {
+		msg += fmt.Sprintf(": no kind or resolver registered for prefix %q", e.Prefix)
+	}
+	if len(e.Available) > 0 {
+		msg += fmt.Sprintf(", client implements the operation clients of %s", strings.Join(e.Available, ", "))
+	}
+	return msg
+}
+
+func (e *UnknownOperationTypeError) Is(target error) bool { return target == ErrUnknownOperationType }
+
+// Kinds of operations, as reported by Event.Kind.
+const (
+	KindClickHouse = "clickhouse"
+	KindKafka      = "kafka"
+	KindTransfer   = "transfer"
+	KindNetwork    = "network"
+)
+
+// Kind is a service owning operations and the way to get its operations, see RegisterKind.
+type Kind struct {
+	// Name of the service, reported by Event.Kind, e.g. KindClickHouse.
+	Name string
+	// Match reports whether the operation ID belongs to the service.
+	Match func(id string) bool
+	// Client is the interface the operation client must implement to get the operations,
+	// e.g. clickhouse.OperationServiceClient.
+	Client reflect.Type
+	// NewRequest returns the default Get request of the operation.
+	NewRequest func(id string) proto.Message
+	// Get gets the operation with the request returned by NewRequest.
+	Get func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error)
+	// List, optional, gets a page of the operations of the project and the token of the next
+	// page, empty for the last one, see List.
+	List func(ctx context.Context, client Client, projectID string, paging *dc.Paging, opts ...grpc.CallOption) ([]*Proto, string, error)
+}
+
+// operationKind is a registered Kind.
+type operationKind struct {
+	name       string
+	match      func(id string) bool
+	client     reflect.Type
+	newRequest func(id string) proto.Message
+	get        func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error)
+	list       func(ctx context.Context, client Client, projectID string, paging *dc.Paging, opts ...grpc.CallOption) ([]*Proto, string, error)
+	// implements reports whether a non-nil client implements the client interface.
+	implements func(client Client) bool
+	// resolve gets the operations of kinds registered with RegisterResolver, which have
+	// no client type and request.
+	resolve Resolver
+}
+
+func hasPrefix(prefixes ...string) func(id string) bool {
+	return func(id string) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(id, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// operationKinds are the registered kinds, matched against operation IDs in registration order.
+var operationKinds = struct {
+	mu    sync.RWMutex
+	kinds []*operationKind
+}{}
+
+// RegisterKind registers a service owning operations, so that Poll and waits can get them.
+// The kinds of the DoubleCloud services are registered by default, unless the package is
+// built with the operation_nokinds tag, see kinds.go. Operation IDs are matched against
+// the kinds in registration order.
+func RegisterKind(k Kind) error {
+	return registerClientKind(k, nil)
+}
+
+// registerClientKind is RegisterKind checking the clients with implements, e.g. with a
+// comma-ok assertion, rather than with reflection, if it is set.
+func registerClientKind(k Kind, implements func(client Client) bool) error {
+	if k.Name == "" || k.Match == nil || k.NewRequest == nil || k.Get == nil {
+		return errors.New("operation: kind requires Name, Match, NewRequest and Get")
+	}
+	if k.Client == nil || k.Client.Kind() != reflect.Interface {
+		return fmt.Errorf("operation: client of %s operations must be an interface type, got %v", k.Name, k.Client)
+	}
+	if implements == nil {
+		implements = func(client Client) bool { return reflect.TypeOf(client).Implements(k.Client) }
+	}
+	return registerKind(&operationKind{
+		name:       k.Name,
+		match:      k.Match,
+		client:     k.Client,
+		implements: implements,
+		newRequest: k.NewRequest,
+		get:        k.Get,
+		list:       k.List,
+	}, nil)
+}
+
+// Resolver gets the operation with the given ID using the client of the operation, see
+// RegisterResolver. The client may be of any type, or nil, and it is up to the resolver
+// to check it.
+type Resolver func(ctx context.Context, client Client, id string, opts ...grpc.CallOption) (*Proto, error)
+
+// RegisterResolver registers the resolver getting the operations whose IDs start with the
+// prefix, e.g. of a service the SDK doesn't know about yet. The prefix is the name of the
+// kind, reported by Event.Kind. Like RegisterKind, the resolver is matched after the kinds
+// registered before it; the prefix must not be matched by any of them.
+func RegisterResolver(prefix string, resolve Resolver) error {
+	if prefix == "" || resolve == nil {
+		return errors.New("operation: resolver requires a prefix and a resolve func")
+	}
+	return registerKind(&operationKind{
+		name:    prefix,
+		match:   hasPrefix(prefix),
+		resolve: resolve,
+	}, func(registered *operationKind) error {
+		if registered.match(prefix) {
+			return fmt.Errorf("operation: prefix %q is already matched by %s operations", prefix, registered.name)
+		}
+		return nil
+	})
+}
+
+// registerKind adds the kind unless its name is taken or conflict fails for a registered kind.
+func registerKind(k *operationKind, conflict func(registered *operationKind) error) error {
+	operationKinds.mu.Lock()
+	defer operationKinds.mu.Unlock()
+	for _, registered := range operationKinds.kinds {
+		if registered.name == k.name {
+			return fmt.Errorf("operation: kind %q is already registered", k.name)
+		}
+		if conflict != nil {
+			if err := conflict(registered); err != nil {
+				return err
+			}
+		}
+	}
+	operationKinds.kinds = append(operationKinds.kinds, k)
+	return nil
+}
+
+// RequestDecorator builds the Get request polling the operation with the given ID.
+type RequestDecorator func(id string) proto.Message
+
+var requestDecorators = struct {
+	mu         sync.RWMutex
+	decorators map[string]RequestDecorator
+}{decorators: map[string]RequestDecorator{}}
+
+// SetRequestDecorator makes Poll build the Get requests of operations of the kind, e.g.
+// KindClickHouse, with decorate instead of setting just the operation ID, e.g. to add fields
+// required by a regional deployment. A nil decorate restores the default requests.
+//
+// It fails for unknown kinds, and if decorate doesn't return the Get request type of the
+// kind's operation service, e.g. *clickhouse.GetOperationRequest; decorate is called with
+// an empty ID to check it.
+func SetRequestDecorator(kind string, decorate RequestDecorator) error {
+	k := kindByName(kind)
+	if k == nil {
+		return fmt.Errorf("operation: unknown operation kind %q", kind)
+	}
+	if k.resolve != nil {
+		return fmt.Errorf("operation: %s operations are got by a resolver, which has no requests to decorate", kind)
+	}
+	if decorate != nil {
+		var req proto.Message
+		if err := SafeCall("request decorator", func() error { req = decorate(""); return nil }); err != nil {
+			return err
+		}
+		want, got := reflect.TypeOf(k.newRequest("")), reflect.TypeOf(req)
+		if got != want {
+			return fmt.Errorf("operation: request decorator of %s operations returns %v, %v required", kind, got, want)
+		}
+	}
+	requestDecorators.mu.Lock()
+	defer requestDecorators.mu.Unlock()
+	if decorate == nil {
+		delete(requestDecorators.decorators, kind)
+	} else {
+		requestDecorators.decorators[kind] = decorate
+	}
+	return nil
+}
+
+// request returns the Get request of the operation, built by the decorator of the kind, if any.
+func (k *operationKind) request(id string) (proto.Message, error) {
+	requestDecorators.mu.RLock()
+	decorate := requestDecorators.decorators[k.name]
+	requestDecorators.mu.RUnlock()
+	if decorate == nil {
+		return k.newRequest(id), nil
+	}
+	var req proto.Message
+	err := SafeCall("request decorator", func() error { req = decorate(id); return nil })
+	return req, err
+}
+
+// operationKindOf returns the kind of the operation ID, nil if it is unknown.
+func operationKindOf(id string) *operationKind {
+	operationKinds.mu.RLock()
+	defer operationKinds.mu.RUnlock()
+	for _, k := range operationKinds.kinds {
+		if k.match(id) {
+			return k
+		}
+	}
+	return nil
+}
+
+// kindByName returns the kind with the given name, nil if it is unknown.
+func kindByName(name string) *operationKind {
+	operationKinds.mu.RLock()
+	defer operationKinds.mu.RUnlock()
+	for _, k := range operationKinds.kinds {
+		if k.name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+func (k *operationKind) implementedBy(client Client) bool {
+	if k.resolve != nil {
+		return true
+	}
+	return client != nil && k.implements(client)
+}
+
+// capabilities returns the kinds, in registration order, whose operation service client
+// the client implements. The kinds of resolvers, which take any client, are left out.
+func capabilities(client Client) []string {
+	operationKinds.mu.RLock()
+	defer operationKinds.mu.RUnlock()
+	var kinds []string
+	for _, k := range operationKinds.kinds {
+		if k.resolve == nil && k.implementedBy(client) {
+			kinds = append(kinds, k.name)
+		}
+	}
+	return kinds
+}
+
+// checkClient returns *NoOperationClientError if the operation can't be waited for with its client:
+// the client must implement the operation service client of the kind or LongPollClient.
+// Done operations and operations of unknown kinds pass, the latter fail on Poll.
+func (o *Operation) checkClient() error {
+	if o.Done() {
+		return nil
+	}
+	return o.clientError()
+}
+
+// clientError is checkClient for operations in any state.
+func (o *Operation) clientError() error {
+	kind := operationKindOf(o.Id())
+	if kind == nil || kind.implementedBy(o.Client()) {
+		return nil
+	}
+	if _, ok := o.Client().(LongPollClient); ok {
+		return nil
+	}
+	// The clients of NewMulti the operation fails over to may have the capability.
+	for _, client := range o.clients {
+		if kind.implementedBy(client) {
+			return nil
+		}
+	}
+	return &NoOperationClientError{Operation: o, Kind: kind.name, Expected: kind.client.String()}
+}
@@ -0,0 +1,333 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWait_NoOperationClient(t *testing.T) {
+	requireKinds(t)
+	for name, tc := range map[string]struct {
+		client   Client
+		id       string
+		expected string
+	}{
+		"nil clickhouse":   {id: "cho1", expected: "clickhouse.OperationServiceClient"},
+		"nil network":      {id: "2e4a5ad7-5c1f-4d48-8bb4-2b3a8f3f8f12", expected: "network.OperationServiceClient"},
+		"wrong kafka":      {client: &fakeKafkaClient{}, id: "dtj1", expected: "transfer.OperationServiceClient"},
+		"wrong endpoint":   {client: &fakeKafkaClient{}, id: "dte1", expected: "transfer.OperationServiceClient"},
+		"not a client":     {client: struct{}{}, id: "kfo1", expected: "kafka.OperationServiceClient"},
+		"wrong clickhouse": {client: &fakeKafkaClient{}, id: "cho1", expected: "clickhouse.OperationServiceClient"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			op := New(tc.client, &Proto{Id: tc.id, Status: doublecloud.Operation_STATUS_RUNNING})
+
+			err := op.Wait(context.Background())
+			require.ErrorIs(t, err, ErrNoOperationClient)
+			var noClient *NoOperationClientError
+			require.ErrorAs(t, err, &noClient)
+			assert.Equal(t, tc.expected, noClient.Expected)
+			assert.Contains(t, err.Error(), tc.expected+" required")
+
+			assert.ErrorIs(t, op.Poll(context.Background()), ErrNoOperationClient, "Poll must not panic")
+		})
+	}
+}
+
+func TestWait_NoOperationClient_Kind(t *testing.T) {
+	requireKinds(t)
+	err := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}).Wait(context.Background())
+	var noClient *NoOperationClientError
+	require.ErrorAs(t, err, &noClient)
+	assert.Equal(t, "kafka", noClient.Kind)
+	assert.Equal(t, "operation (id=kfo1) has no kafka operation client: kafka.OperationServiceClient required", err.Error())
+}
+
+func TestNewChecked(t *testing.T) {
+	requireKinds(t)
+	_, err := NewChecked(&fakeKafkaClient{}, nil)
+	assert.ErrorIs(t, err, ErrNilOperation)
+
+	op, err := NewChecked(&fakeKafkaClient{}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_DONE})
+	require.NoError(t, err)
+	assert.Equal(t, "kfo1", op.Id())
+
+	_, err = NewChecked(&fakeKafkaClient{}, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE})
+	var noClient *NoOperationClientError
+	require.ErrorAs(t, err, &noClient, "done operations are checked too")
+	assert.Equal(t, "clickhouse.OperationServiceClient", noClient.Expected)
+	assert.Equal(t, "operation (id=cho1) has no clickhouse operation client: clickhouse.OperationServiceClient required", err.Error())
+
+	_, err = NewChecked(nil, &Proto{Id: "kfo1"})
+	assert.ErrorIs(t, err, ErrNoOperationClient)
+
+	_, err = NewChecked(&longPollKafkaClient{}, &Proto{Id: "cho1"})
+	assert.NoError(t, err, "long-polling clients wait for operations of any kind")
+
+	_, err = NewChecked(&fakeKafkaClient{}, &Proto{Id: "xyz1"})
+	assert.ErrorIs(t, err, ErrUnknownOperationType)
+}
+
+func TestWait_ClientNotRequired(t *testing.T) {
+	// Done operations are not polled.
+	assert.NoError(t, New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE}).Wait(context.Background()))
+
+	err := New(nil, &Proto{Id: "xyz1", Status: doublecloud.Operation_STATUS_PENDING}).Poll(context.Background())
+	assert.EqualError(t, err, `operation (id=xyz1) unknown type: no kind or resolver registered for prefix "xyz"`)
+	assert.ErrorIs(t, err, ErrUnknownOperationType)
+	var unknown *UnknownOperationTypeError
+	require.ErrorAs(t, err, &unknown)
+	assert.Equal(t, "xyz1", unknown.Operation.Id())
+	assert.Equal(t, "xyz1", unknown.ID)
+	assert.Empty(t, unknown.Available)
+	assert.NotErrorIs(t, err, ErrNoOperationClient)
+}
+
+// journalClient is the operation client of a service outside of the built-in kinds.
+type journalClient interface {
+	GetJournalOperation(ctx context.Context, id string) (*Proto, error)
+}
+
+// compositeClient implements the operation clients of several kinds.
+type compositeClient struct {
+	fakeKafkaClient
+}
+
+func (*compositeClient) GetJournalOperation(ctx context.Context, id string) (*Proto, error) {
+	return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+var journalKind sync.Once
+
+// registerJournalKind registers the kind of journalClient, once for all the tests.
+func registerJournalKind(t *testing.T) {
+	journalKind.Do(func() {
+		require.NoError(t, RegisterKind(Kind{
+			Name:       "journal",
+			Match:      hasPrefix("jno"),
+			Client:     reflect.TypeOf((*journalClient)(nil)).Elem(),
+			NewRequest: func(id string) proto.Message { return &kafka.GetOperationRequest{OperationId: id} },
+			Get: func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error) {
+				return client.(journalClient).GetJournalOperation(ctx, req.(*kafka.GetOperationRequest).GetOperationId())
+			},
+		}))
+	})
+}
+
+func TestUnknownOperationTypeError_Available(t *testing.T) {
+	requireKinds(t)
+	registerJournalKind(t)
+	require.NoError(t, New(&compositeClient{}, &Proto{Id: "jno1"}).Poll(context.Background()))
+
+	for name, tc := range map[string]struct {
+		client    Client
+		id        string
+		prefix    string
+		available []string
+		msg       string
+	}{
+		"composite": {
+			client: &compositeClient{}, id: "sqo1", prefix: "sqo", available: []string{"kafka", "journal"},
+			msg: `operation (id=sqo1) unknown type: no kind or resolver registered for prefix "sqo", client implements the operation clients of kafka, journal`,
+		},
+		"single": {
+			client: &fakeKafkaClient{}, id: "sqo1", prefix: "sqo", available: []string{"kafka"},
+			msg: `operation (id=sqo1) unknown type: no kind or resolver registered for prefix "sqo", client implements the operation clients of kafka`,
+		},
+		"no prefix": {
+			client: &fakeKafkaClient{}, id: "42", available: []string{"kafka"},
+			msg: `operation (id=42) unknown type, client implements the operation clients of kafka`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := New(tc.client, &Proto{Id: tc.id}).Poll(context.Background())
+			var unknown *UnknownOperationTypeError
+			require.ErrorAs(t, err, &unknown)
+			assert.Equal(t, tc.prefix, unknown.Prefix)
+			assert.Equal(t, tc.available, unknown.Available)
+			assert.EqualError(t, err, tc.msg)
+		})
+	}
+}
+
+// journalOnly implements the operation client of the journal kind alone.
+type journalOnly struct{}
+
+func (journalOnly) GetJournalOperation(ctx context.Context, id string) (*Proto, error) {
+	return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+func TestCapabilities(t *testing.T) {
+	requireKinds(t)
+	registerJournalKind(t)
+	for name, tc := range map[string]struct {
+		client Client
+		kinds  []string
+	}{
+		"nil":          {},
+		"not a client": {client: struct{}{}},
+		"kafka":        {client: &fakeKafkaClient{}, kinds: []string{KindKafka}},
+		"long poll":    {client: &longPollKafkaClient{}, kinds: []string{KindKafka}},
+		"journal":      {client: journalOnly{}, kinds: []string{"journal"}},
+		"composite":    {client: &compositeClient{}, kinds: []string{KindKafka, "journal"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.kinds, capabilities(tc.client))
+		})
+	}
+}
+
+func TestSetRequestDecorator_Validation(t *testing.T) {
+	requireKinds(t)
+	err := SetRequestDecorator("airflow", func(id string) proto.Message { return &kafka.GetOperationRequest{OperationId: id} })
+	assert.EqualError(t, err, `operation: unknown operation kind "airflow"`)
+
+	err = SetRequestDecorator(KindClickHouse, func(id string) proto.Message { return &kafka.GetOperationRequest{OperationId: id} })
+	assert.EqualError(t, err, "operation: request decorator of clickhouse operations returns *kafka.GetOperationRequest, *clickhouse.GetOperationRequest required")
+
+	assert.NoError(t, SetRequestDecorator(KindKafka, nil), "resetting is allowed without a decorator")
+}
+
+func TestSetRequestDecorator(t *testing.T) {
+	requireKinds(t)
+	var got *kafka.GetOperationRequest
+	client := &recordingKafkaClient{record: func(req *kafka.GetOperationRequest) { got = req }}
+	require.NoError(t, SetRequestDecorator(KindKafka, func(id string) proto.Message {
+		return &kafka.GetOperationRequest{OperationId: "decorated-" + id}
+	}))
+	t.Cleanup(func() { _ = SetRequestDecorator(KindKafka, nil) })
+
+	require.NoError(t, New(client, &Proto{Id: "kfo1"}).Poll(context.Background()))
+	assert.Equal(t, "decorated-kfo1", got.GetOperationId())
+
+	require.NoError(t, SetRequestDecorator(KindKafka, nil))
+	require.NoError(t, New(client, &Proto{Id: "kfo1"}).Poll(context.Background()))
+	assert.Equal(t, "kfo1", got.GetOperationId())
+}
+
+// recordingKafkaClient reports the Get requests it receives.
+type recordingKafkaClient struct {
+	fakeKafkaClient
+	record func(req *kafka.GetOperationRequest)
+}
+
+func (c *recordingKafkaClient) Get(ctx context.Context, in *kafka.GetOperationRequest, opts ...grpc.CallOption) (*Proto, error) {
+	c.record(in)
+	return &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+// ledgerClient is the operation client of a service outside of the built-in kinds.
+type ledgerClient interface {
+	GetLedgerOperation(ctx context.Context, id string) (*Proto, error)
+}
+
+type fakeLedger struct{}
+
+func (fakeLedger) GetLedgerOperation(ctx context.Context, id string) (*Proto, error) {
+	return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+func TestRegisterKind(t *testing.T) {
+	kind := Kind{
+		Name:       "ledger",
+		Match:      hasPrefix("ldg"),
+		Client:     reflect.TypeOf((*ledgerClient)(nil)).Elem(),
+		NewRequest: func(id string) proto.Message { return &kafka.GetOperationRequest{OperationId: id} },
+		Get: func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error) {
+			return client.(ledgerClient).GetLedgerOperation(ctx, req.(*kafka.GetOperationRequest).GetOperationId())
+		},
+	}
+	require.NoError(t, RegisterKind(kind))
+	assert.EqualError(t, RegisterKind(kind), `operation: kind "ledger" is already registered`)
+
+	op := New(fakeLedger{}, &Proto{Id: "ldg1", Status: doublecloud.Operation_STATUS_RUNNING})
+	require.NoError(t, op.Wait(context.Background()))
+	assert.True(t, op.Ok())
+	assert.Equal(t, "ledger", NewEvent(op).Kind)
+
+	err := New(nil, &Proto{Id: "ldg2", Status: doublecloud.Operation_STATUS_RUNNING}).Wait(context.Background())
+	assert.ErrorIs(t, err, ErrNoOperationClient)
+
+	kind.Name, kind.Client = "broken", reflect.TypeOf(fakeLedger{})
+	assert.EqualError(t, RegisterKind(kind), "operation: client of broken operations must be an interface type, got operation.fakeLedger")
+	assert.Error(t, RegisterKind(Kind{Name: "empty"}))
+}
+
+// airflowClient is the operation client of a service registered with a resolver.
+type airflowClient interface {
+	GetAirflowOperation(ctx context.Context, id string) (*Proto, error)
+}
+
+type fakeAirflow struct{}
+
+func (fakeAirflow) GetAirflowOperation(ctx context.Context, id string) (*Proto, error) {
+	return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+}
+
+func resolveAirflow(ctx context.Context, client Client, id string, opts ...grpc.CallOption) (*Proto, error) {
+	c, ok := client.(airflowClient)
+	if !ok {
+		return nil, fmt.Errorf("airflow operation %s requires an airflow client, got %T", id, client)
+	}
+	return c.GetAirflowOperation(ctx, id)
+}
+
+func TestRegisterResolver(t *testing.T) {
+	require.NoError(t, RegisterResolver("afo", resolveAirflow))
+
+	op := New(fakeAirflow{}, &Proto{Id: "afo1", Status: doublecloud.Operation_STATUS_RUNNING})
+	require.NoError(t, op.Wait(context.Background()))
+	assert.True(t, op.Ok())
+	assert.Equal(t, "afo", NewEvent(op).Kind)
+
+	err := New(&fakeKafkaClient{}, &Proto{Id: "afo2", Status: doublecloud.Operation_STATUS_RUNNING}).Wait(context.Background())
+	assert.ErrorContains(t, err, "airflow operation afo2 requires an airflow client, got *operation.fakeKafkaClient", "a mismatched client is reported by the resolver")
+
+	assert.EqualError(t, SetRequestDecorator("afo", nil), "operation: afo operations are got by a resolver, which has no requests to decorate")
+	assert.Error(t, RegisterResolver("", resolveAirflow))
+	assert.Error(t, RegisterResolver("xyz", nil))
+}
+
+func TestRegisterResolver_Conflicts(t *testing.T) {
+	requireKinds(t)
+	resolve := func(ctx context.Context, client Client, id string, opts ...grpc.CallOption) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}
+	assert.EqualError(t, RegisterResolver("cho", resolve), `operation: prefix "cho" is already matched by clickhouse operations`)
+	assert.EqualError(t, RegisterResolver("kfo-peering", resolve), `operation: prefix "kfo-peering" is already matched by kafka operations`,
+		"the prefix would never be reached")
+
+	require.NoError(t, RegisterResolver("pcr", resolve))
+	assert.EqualError(t, RegisterResolver("pcr", resolve), `operation: kind "pcr" is already registered`)
+	assert.EqualError(t, RegisterResolver("pcrx", resolve), `operation: prefix "pcrx" is already matched by pcr operations`)
+}
+
+func TestRegisterResolver_Order(t *testing.T) {
+	requireKinds(t)
+	var resolved []string
+	resolver := func(name string) Resolver {
+		return func(ctx context.Context, client Client, id string, opts ...grpc.CallOption) (*Proto, error) {
+			resolved = append(resolved, name)
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		}
+	}
+	// A shorter prefix registered later only gets the IDs the earlier ones don't match.
+	require.NoError(t, RegisterResolver("vpx", resolver("vpx")))
+	require.NoError(t, RegisterResolver("vp", resolver("vp")))
+
+	for _, id := range []string{"vpx1", "vpc1", "vpx2"} {
+		require.NoError(t, New(nil, &Proto{Id: id}).Poll(context.Background()))
+	}
+	assert.Equal(t, []string{"vpx", "vp", "vpx"}, resolved)
+	assert.Equal(t, KindClickHouse, kindOf("cho1"), "built-in kinds are matched first")
+}
@@ -0,0 +1,83 @@
+package operation
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Clock is the time source of waits, see WithClock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer firing after d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer of a Clock, like time.Timer.
+type Timer interface {
+	// C returns the channel the timer sends the time on when it fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, reporting false if it already fired or was stopped.
+	Stop() bool
+}
+
+// SystemClock is the Clock of the time package, used by waits without WithClock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+func (t systemTimer) Stop() bool          { return t.t.Stop() }
+
+// WithClock makes waits read the time and sleep between polls with c, e.g. a fake clock
+// advanced by tests, see sdktest.Clock. It covers the poll intervals of waits, including
+// WaitAll and WaitAny, the times of WithDecisionLog and WithSLA, and the timeout of
+// ResourceIdWait. The deadlines of contexts keep following the system clock: the timeout of
+// WaitTimeout, the poll timeout of the RetryPolicy and the deadline of WithServerDeadlineHint.
+func WithClock(c Clock) grpc.CallOption {
+	return &clockOption{c: c}
+}
+
+type clockOption struct {
+	grpc.EmptyCallOption
+	c Clock
+}
+
+// waitClock is the time source of a wait: the Clock of WithClock, or else the clock of the
+// package and the timers of the operation.
+type waitClock struct {
+	now      func() time.Time
+	newTimer func(time.Duration) (func() <-chan time.Time, func() bool)
+}
+
+// clockOf returns the clock of a wait of o made with opts. o may be nil for waits of
+// several operations.
+func clockOf(o *Operation, opts []grpc.CallOption) waitClock {
+	var c Clock
+	for _, opt := range opts {
+		if opt, ok := opt.(*clockOption); ok {
+			c = opt.c
+		}
+	}
+	if c == nil {
+		newTimer := defaultTimer
+		if o != nil {
+			newTimer = o.newTimer
+		}
+		return waitClock{now: now, newTimer: func(d time.Duration) (func() <-chan time.Time, func() bool) {
+			runtimeStats.timers.Add(1)
+			return newTimer(d)
+		}}
+	}
+	return waitClock{now: c.Now, newTimer: func(d time.Duration) (func() <-chan time.Time, func() bool) {
+		runtimeStats.timers.Add(1)
+		t := c.NewTimer(d)
+		return t.C, t.Stop
+	}}
+}
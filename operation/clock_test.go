@@ -0,0 +1,80 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Now and NewTimer make the fake clock a Clock of WithClock.
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	wait, stop := c.newTimer(d)
+	return firedTimer{wait, stop}
+}
+
+type firedTimer struct {
+	c    func() <-chan time.Time
+	stop func() bool
+}
+
+func (t firedTimer) C() <-chan time.Time { return t.c() }
+func (t firedTimer) Stop() bool          { return t.stop() }
+
+// hintKafkaClient answers the polls with the poll interval header set to hint, unless it
+// is empty.
+type hintKafkaClient struct {
+	fakeKafkaClient
+	hint string
+}
+
+func (f *hintKafkaClient) Get(ctx context.Context, in *kafka.GetOperationRequest, opts ...grpc.CallOption) (*Proto, error) {
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok && f.hint != "" {
+			*h.HeaderAddr = metadata.Pairs(PollIntervalMetadataKey, f.hint)
+		}
+	}
+	return f.fakeKafkaClient.Get(ctx, in, opts...)
+}
+
+// runningFor answers the polls with the scripted errors first, then with the operation
+// running for two polls and done.
+func runningFor(errs ...codes.Code) func(n int, id string) (*Proto, error) {
+	return func(n int, id string) (*Proto, error) {
+		switch {
+		case n <= len(errs):
+			return nil, status.Error(errs[n-1], "scripted")
+		case n < len(errs)+3:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}
+}
+
+func TestWait_ClockWithoutPackageClock(t *testing.T) {
+	c := &fakeClock{t: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = func(time.Duration) (func() <-chan time.Time, func() bool) {
+		t.Fatal("the timers of the operation are not used with WithClock")
+		return nil, nil
+	}
+	var exceeded int
+	sla := WithSLA(5*time.Second, func(*Operation) { exceeded++ })
+	require.NoError(t, op.WaitInterval(context.Background(), time.Second, WithClock(c), WithPollFunc(c.pollUntil(10*time.Second, 0)), sla))
+	assert.Len(t, c.intervals, 10)
+	assert.Equal(t, 1, exceeded, "the SLA is measured with the clock")
+
+	_, errs := waitBatch(context.Background(), []*Operation{New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING})}, false,
+		[]grpc.CallOption{WithClock(c), WithPollFunc(c.pollUntil(3*time.Second, 0))})
+	assert.Equal(t, []error{nil}, errs)
+}
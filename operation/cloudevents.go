@@ -0,0 +1,119 @@
+package operation
+
+import "time"
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents specification of CloudEvent.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventTypeCompleted and CloudEventTypeFailed are the types of the events of the
+	// operations completed without and with an error.
+	CloudEventTypeCompleted = "com.doublecloud.operation.completed"
+	CloudEventTypeFailed    = "com.doublecloud.operation.failed"
+	// CloudEventContentType is the content type of the data of the events.
+	CloudEventContentType = "application/json"
+)
+
+// cloudEventSourceScheme is the prefix of the sources of the events of the operations
+// without console URL, as the one of the resource references of the SDK.
+const cloudEventSourceScheme = "crn:doublecloud:"
+
+// CloudEvent is an Event in the CloudEvents format. It encodes to the JSON of the structured
+// mode of the specification, e.g. for a Publish of PublisherConfig sending to an event bus:
+//
+//	Publish: func(ctx context.Context, e operation.Event) error {
+//		data, err := json.Marshal(operation.NewCloudEvent(e))
+//		...
+//	}
+//
+// The JSON also decodes to the event.Event of github.com/cloudevents/sdk-go, which can be
+// filled in directly with WriteTo too.
+type CloudEvent struct {
+	SpecVersion string `json:"specversion"`
+	// ID is the ID of the operation: an operation completes once, so publishing its event
+	// again makes a duplicate consumers can drop.
+	ID string `json:"id"`
+	// Source is the console URL of the resource of the operation if known, see
+	// Event.ConsoleURL, and "crn:doublecloud:<service>:<project>" otherwise.
+	Source string `json:"source"`
+	// Type is CloudEventTypeCompleted or CloudEventTypeFailed.
+	Type string `json:"type"`
+	// Subject is the ID of the resource of the operation.
+	Subject         string         `json:"subject,omitempty"`
+	Time            *time.Time     `json:"time,omitempty"`
+	DataContentType string         `json:"datacontenttype"`
+	Data            CloudEventData `json:"data"`
+}
+
+// CloudEventData is the data of a CloudEvent: the summary of the operation of the Event.
+type CloudEventData struct {
+	OperationID string `json:"operation_id"`
+	Kind        string `json:"kind,omitempty"`
+	ProjectID   string `json:"project_id,omitempty"`
+	ResourceID  string `json:"resource_id,omitempty"`
+	Failed      bool   `json:"failed"`
+	// ErrorCode is the name of the gRPC code of the error of a failed operation, e.g.
+	// ResourceExhausted.
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	DurationMs   int64  `json:"duration_ms,omitempty"`
+}
+
+// NewCloudEvent returns the CloudEvent of the event.
+func NewCloudEvent(e Event) CloudEvent {
+	ce := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              e.OperationID,
+		Source:          e.ConsoleURL,
+		Type:            CloudEventTypeCompleted,
+		Subject:         e.ResourceID,
+		DataContentType: CloudEventContentType,
+		Data: CloudEventData{
+			OperationID: e.OperationID,
+			Kind:        e.Kind,
+			ProjectID:   e.ProjectID,
+			ResourceID:  e.ResourceID,
+			Failed:      e.Failed,
+			DurationMs:  e.Duration.Milliseconds(),
+		},
+	}
+	if ce.Source == "" {
+		ce.Source = cloudEventSourceScheme + e.Kind + ":" + e.ProjectID
+	}
+	if e.Failed {
+		ce.Type = CloudEventTypeFailed
+		ce.Data.ErrorCode, ce.Data.ErrorMessage = e.ErrorCode.String(), e.ErrorMessage
+	}
+	if !e.FinishedAt.IsZero() {
+		t := e.FinishedAt.UTC()
+		ce.Time = &t
+	}
+	return ce
+}
+
+// CloudEventWriter is the part of the *event.Event of github.com/cloudevents/sdk-go written
+// by CloudEvent.WriteTo, so that the SDK doesn't depend on it.
+type CloudEventWriter interface {
+	SetSpecVersion(v string)
+	SetID(id string)
+	SetSource(s string)
+	SetType(t string)
+	SetSubject(s string)
+	SetTime(t time.Time)
+	SetData(contentType string, obj interface{}) error
+}
+
+// WriteTo sets the attributes and the data of the event w to the ones of the CloudEvent,
+// e.g. of an event.New() of github.com/cloudevents/sdk-go.
+func (ce CloudEvent) WriteTo(w CloudEventWriter) error {
+	w.SetSpecVersion(ce.SpecVersion)
+	w.SetID(ce.ID)
+	w.SetSource(ce.Source)
+	w.SetType(ce.Type)
+	if ce.Subject != "" {
+		w.SetSubject(ce.Subject)
+	}
+	if ce.Time != nil {
+		w.SetTime(*ce.Time)
+	}
+	return w.SetData(ce.DataContentType, ce.Data)
+}
@@ -0,0 +1,86 @@
+package operation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func cloudEventOperation(failed bool) *Operation {
+	created := time.Date(2023, 5, 12, 14, 30, 11, 0, time.UTC)
+	p := &Proto{
+		Id:         "cho1a2b3c4d5e6f7g8h9",
+		ProjectId:  "prj1a2b3c4d5e6f7g8h",
+		ResourceId: "chcl9x8w7v6u5t4s3r2q",
+		Status:     doublecloud.Operation_STATUS_DONE,
+		CreateTime: timestamppb.New(created),
+		FinishTime: timestamppb.New(created.Add(95*time.Second + 250*time.Millisecond)),
+	}
+	if failed {
+		p.Error = &rpcstatus.Status{Code: int32(code.Code_RESOURCE_EXHAUSTED), Message: "quota exceeded"}
+	}
+	return New(nil, p)
+}
+
+// TestCloudEvent_Golden checks the JSON of the events against the golden files of
+// testdata/cloudevents. Run with -update to rewrite them.
+func TestCloudEvent_Golden(t *testing.T) {
+	requireKinds(t)
+	for name, o := range map[string]*Operation{
+		"completed": cloudEventOperation(false).WithConsoleURL(func(o *Operation) (string, error) {
+			return "https://app.double.cloud/clickhouse/" + o.ResourceId(), nil
+		}),
+		"failed": cloudEventOperation(true),
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.MarshalIndent(NewCloudEvent(NewEvent(o)), "", "  ")
+			require.NoError(t, err)
+			data = append(data, '\n')
+			golden := filepath.Join("testdata", "cloudevents", name+".json")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, data, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(data))
+		})
+	}
+}
+
+// eventRecorder records the setters of CloudEventWriter, as the event.Event of sdk-go.
+type eventRecorder map[string]interface{}
+
+func (r eventRecorder) SetSpecVersion(v string) { r["specversion"] = v }
+func (r eventRecorder) SetID(id string)         { r["id"] = id }
+func (r eventRecorder) SetSource(s string)      { r["source"] = s }
+func (r eventRecorder) SetType(t string)        { r["type"] = t }
+func (r eventRecorder) SetSubject(s string)     { r["subject"] = s }
+func (r eventRecorder) SetTime(t time.Time)     { r["time"] = t }
+func (r eventRecorder) SetData(contentType string, obj interface{}) error {
+	r["datacontenttype"], r["data"] = contentType, obj
+	return nil
+}
+
+func TestCloudEvent_WriteTo(t *testing.T) {
+	ce := NewCloudEvent(Event{OperationID: "op1", Kind: "kafka", ProjectID: "prj1", ResourceID: "res1", Failed: true, ErrorMessage: "boom"})
+	r := eventRecorder{}
+	require.NoError(t, ce.WriteTo(r))
+	assert.Equal(t, eventRecorder{
+		"specversion":     "1.0",
+		"id":              "op1",
+		"source":          "crn:doublecloud:kafka:prj1",
+		"type":            CloudEventTypeFailed,
+		"subject":         "res1",
+		"datacontenttype": "application/json",
+		"data":            ce.Data,
+	}, r, "no time for events of operations without finish time")
+}
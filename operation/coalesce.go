@@ -0,0 +1,99 @@
+package operation
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// WaitCoalescer shares a single poll loop between concurrent waits on the same operation ID.
+// The first waiter starts the loop, later ones subscribe to its result.
+// Each waiter gets its own copy of the final operation state.
+//
+// The shared loop runs detached from the waiters' contexts and uses the call options and the
+// context values, such as credentials, of the waiter that started it. It is cancelled only
+// when every subscribed waiter has given up.
+// A WaitCoalescer may be shared process-wide or per SDK instance. The zero value is not usable,
+// construct it with NewWaitCoalescer.
+type WaitCoalescer struct {
+	mu    sync.Mutex
+	waits map[string]*sharedWait
+}
+
+type sharedWait struct {
+	subscribers int
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	// proto and err are the final state of the loop, set before done is closed.
+	proto *Proto
+	err   error
+}
+
+func NewWaitCoalescer() *WaitCoalescer {
+	return &WaitCoalescer{waits: map[string]*sharedWait{}}
+}
+
+// Wait waits for the operation to complete, like Operation.Wait, joining the poll loop
+// already running for the same operation ID if there is one.
+func (c *WaitCoalescer) Wait(ctx context.Context, o *Operation, opts ...grpc.CallOption) error {
+	if o.Done() {
+		return o.Wait(ctx, opts...)
+	}
+	id := o.Id()
+
+	c.mu.Lock()
+	w, ok := c.waits[id]
+	if !ok {
+		w = c.start(ctx, o, opts)
+		c.waits[id] = w
+	}
+	w.subscribers++
+	c.mu.Unlock()
+
+	select {
+	case <-w.done:
+		o.state.store(proto.Clone(w.proto).(*Proto))
+		return w.err
+	case <-ctx.Done():
+		c.unsubscribe(id, w)
+		return sdkerrors.WithMessagef(ctx.Err(), "%s wait context done", o)
+	}
+}
+
+func (c *WaitCoalescer) start(waiterCtx context.Context, o *Operation, opts []grpc.CallOption) *sharedWait {
+	ctx, cancel := context.WithCancel(detach(waiterCtx))
+	w := &sharedWait{cancel: cancel, done: make(chan struct{})}
+	driver := o.clone()
+	go func() {
+		defer cancel()
+		err := driver.Wait(ctx, opts...)
+
+		c.mu.Lock()
+		if c.waits[driver.Id()] == w {
+			delete(c.waits, driver.Id())
+		}
+		c.mu.Unlock()
+
+		w.proto, w.err = driver.Proto(), err
+		close(w.done)
+	}()
+	return w
+}
+
+func (c *WaitCoalescer) unsubscribe(id string, w *sharedWait) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.subscribers--
+	if w.subscribers > 0 {
+		return
+	}
+	if c.waits[id] == w {
+		delete(c.waits, id)
+	}
+	w.cancel()
+}
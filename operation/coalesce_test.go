@@ -0,0 +1,155 @@
+package operation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (c *WaitCoalescer) active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waits)
+}
+
+func pendingKafkaOp(client Client) *Operation {
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	return op
+}
+
+func TestWaitCoalescer_SharesPollLoop(t *testing.T) {
+	requireKinds(t)
+	release := make(chan struct{})
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		select {
+		case <-release:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE, Metadata: map[string]string{"k": "v"}}, nil
+		default:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+	}}
+	c := NewWaitCoalescer()
+
+	const waiters = 5
+	ops := make([]*Operation, waiters)
+	errs := make([]error, waiters)
+	var wg sync.WaitGroup
+	for i := range ops {
+		ops[i] = pendingKafkaOp(client)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.Wait(context.Background(), ops[i])
+		}(i)
+	}
+	require.Eventually(t, func() bool { return client.calls() > 10 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, c.active())
+	close(release)
+	wg.Wait()
+
+	calls := client.calls()
+	for i := range ops {
+		assert.NoError(t, errs[i])
+		assert.True(t, ops[i].Ok())
+	}
+	// Snapshots are independent copies.
+	ops[0].Metadata()["k"] = "changed"
+	assert.Equal(t, "v", ops[1].Metadata()["k"])
+	assert.Equal(t, 0, c.active())
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, calls, client.calls(), "no polls after completion")
+}
+
+func TestWaitCoalescer_StaggeredCancellation(t *testing.T) {
+	requireKinds(t)
+	release := make(chan struct{})
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		select {
+		case <-release:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		default:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+	}}
+	c := NewWaitCoalescer()
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	errA, errB := make(chan error, 1), make(chan error, 1)
+	opB := pendingKafkaOp(client)
+	go func() { errA <- c.Wait(ctxA, pendingKafkaOp(client)) }()
+	require.Eventually(t, func() bool { return c.active() == 1 }, time.Second, time.Millisecond)
+	go func() { errB <- c.Wait(ctxB, opB) }()
+	require.Eventually(t, func() bool { return client.calls() > 3 }, time.Second, time.Millisecond)
+
+	// The first subscriber leaving must not stop polling for the second one.
+	cancelA()
+	assert.ErrorIs(t, <-errA, context.Canceled)
+	before := client.calls()
+	require.Eventually(t, func() bool { return client.calls() > before+3 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, c.active())
+
+	close(release)
+	require.NoError(t, <-errB)
+	assert.True(t, opB.Ok())
+}
+
+func TestWaitCoalescer_LastSubscriberCancelsLoop(t *testing.T) {
+	requireKinds(t)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+	c := NewWaitCoalescer()
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	errA, errB := make(chan error, 1), make(chan error, 1)
+	go func() { errA <- c.Wait(ctxA, pendingKafkaOp(client)) }()
+	go func() { errB <- c.Wait(ctxB, pendingKafkaOp(client)) }()
+	require.Eventually(t, func() bool { return client.calls() > 3 }, time.Second, time.Millisecond)
+
+	cancelA()
+	<-errA
+	cancelB()
+	assert.ErrorIs(t, <-errB, context.Canceled)
+	assert.Equal(t, 0, c.active())
+
+	time.Sleep(10 * time.Millisecond)
+	stopped := client.calls()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, client.calls(), "poll loop must stop after the last subscriber leaves")
+
+	// A new waiter starts a fresh loop.
+	ctxC, cancelC := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelC()
+	assert.ErrorIs(t, c.Wait(ctxC, pendingKafkaOp(client)), context.DeadlineExceeded)
+	assert.Greater(t, client.calls(), stopped)
+}
+
+func TestWaitCoalescer_KeepsOperationSettings(t *testing.T) {
+	requireKinds(t)
+	var refreshed atomic.Bool
+	var refreshes int
+	client := expiringTokenClient(&refreshed, codes.Unauthenticated)
+	op := pendingKafkaOp(client).WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error {
+		refreshes++
+		refreshed.Store(true)
+		return nil
+	})
+
+	require.NoError(t, NewWaitCoalescer().Wait(context.Background(), op))
+	assert.True(t, op.Ok())
+	assert.Equal(t, 1, refreshes, "the shared loop refreshes the credentials of the operation")
+}
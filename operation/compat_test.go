@@ -0,0 +1,200 @@
+//go:build !operation_nokinds
+
+package operation_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/operation/operationtest"
+)
+
+// The tests of this file pin the behavior of waits without options, the one of the callers
+// written before the options of waits existed. A change of the defaults must update them
+// explicitly: don't regenerate the expectations, make sure the change is intended.
+
+func TestWaitDefaults_Constants(t *testing.T) {
+	assert.Equal(t, time.Second, operation.DefaultPollInterval)
+	assert.Equal(t, operation.RetryPolicy{
+		Codes:                  []codes.Code{codes.NotFound, codes.Unavailable},
+		MaxConsecutiveFailures: 3,
+	}, operation.DefaultRetryPolicy)
+	assert.Equal(t, []codes.Code{codes.Unauthenticated, codes.PermissionDenied}, operation.DefaultFatalCodes)
+	assert.Equal(t, 100*time.Millisecond, operation.DefaultMinIntervalHint)
+	assert.Equal(t, 10*time.Minute, operation.DefaultMaxIntervalHint)
+	assert.Empty(t, operation.PollPolicy{}.Options(), "the zero policy adds no options")
+}
+
+// compatCase is a script answered to a wait without options and what the wait does.
+type compatCase struct {
+	script []operationtest.Step
+	// polls are the times of the polls since the start of the wait.
+	polls  []time.Duration
+	sleeps []time.Duration
+	// err is the message of the error of the wait, empty if it succeeds.
+	err string
+}
+
+const second = time.Second
+
+var compatCases = map[string]compatCase{
+	"done on the first poll": {
+		script: []operationtest.Step{operationtest.Done()},
+		polls:  []time.Duration{0},
+	},
+	"fixed interval": {
+		script: []operationtest.Step{operationtest.Running(), operationtest.Running(), operationtest.Done()},
+		polls:  []time.Duration{0, second, 2 * second},
+		sleeps: []time.Duration{second, second},
+	},
+	"slow polls": {
+		script: []operationtest.Step{operationtest.Running().Taking(300 * time.Millisecond), operationtest.Done()},
+		polls:  []time.Duration{0, 1300 * time.Millisecond},
+		sleeps: []time.Duration{second},
+	},
+	"three NotFound tolerated": {
+		script: []operationtest.Step{
+			operationtest.PollError(codes.NotFound), operationtest.PollError(codes.NotFound), operationtest.PollError(codes.NotFound),
+			operationtest.Done(),
+		},
+		polls:  []time.Duration{0, second, 2 * second, 3 * second},
+		sleeps: []time.Duration{second, second, second},
+	},
+	"four NotFound exhaust the retries": {
+		script: []operationtest.Step{
+			operationtest.PollError(codes.NotFound), operationtest.PollError(codes.NotFound), operationtest.PollError(codes.NotFound),
+			operationtest.PollError(codes.NotFound), operationtest.Done(),
+		},
+		polls:  []time.Duration{0, second, 2 * second, 3 * second},
+		sleeps: []time.Duration{second, second, second},
+		err:    "operation (id=kfo1) poll fail after 4 attempts: rpc error: code = NotFound desc = operationtest: scripted",
+	},
+	"successful polls reset the retries": {
+		script: []operationtest.Step{
+			operationtest.PollError(codes.NotFound), operationtest.PollError(codes.Unavailable), operationtest.PollError(codes.NotFound),
+			operationtest.Running(),
+			operationtest.PollError(codes.Unavailable), operationtest.PollError(codes.NotFound), operationtest.PollError(codes.Unavailable),
+			operationtest.Done(),
+		},
+		polls:  []time.Duration{0, second, 2 * second, 3 * second, 4 * second, 5 * second, 6 * second, 7 * second},
+		sleeps: []time.Duration{second, second, second, second, second, second, second},
+	},
+	"other poll errors end the wait": {
+		script: []operationtest.Step{operationtest.Running(), operationtest.PollError(codes.Internal)},
+		polls:  []time.Duration{0, second},
+		sleeps: []time.Duration{second},
+		err:    "operation (id=kfo1) poll fail: rpc error: code = Internal desc = operationtest: scripted",
+	},
+	"fatal poll errors end the wait": {
+		script: []operationtest.Step{operationtest.PollError(codes.PermissionDenied)},
+		polls:  []time.Duration{0},
+		err:    "operation (id=kfo1) poll fail: rpc error: code = PermissionDenied desc = operationtest: scripted; refresh credentials and wait again",
+	},
+	"header overrides the interval": {
+		script: []operationtest.Step{
+			operationtest.Running().WithPollInterval("5"),
+			operationtest.Running(),
+			operationtest.Running().WithPollInterval("250ms"),
+			operationtest.Running().WithPollInterval("soon"),
+			operationtest.Done(),
+		},
+		polls:  []time.Duration{0, 5 * second, 6 * second, 6250 * time.Millisecond, 7250 * time.Millisecond},
+		sleeps: []time.Duration{5 * second, second, 250 * time.Millisecond, second},
+	},
+	"header bounds": {
+		script: []operationtest.Step{
+			operationtest.Running().WithPollInterval("0.001"),
+			operationtest.Running().WithPollInterval("86400"),
+			operationtest.Done(),
+		},
+		polls:  []time.Duration{0, 100 * time.Millisecond, 100*time.Millisecond + 10*time.Minute},
+		sleeps: []time.Duration{100 * time.Millisecond, 10 * time.Minute},
+	},
+	"operation failed": {
+		script: []operationtest.Step{operationtest.Running(), operationtest.Failed(codes.ResourceExhausted, "quota exceeded")},
+		polls:  []time.Duration{0, second},
+		sleeps: []time.Duration{second},
+		err:    "operation (id=kfo1) failed: rpc error: code = ResourceExhausted desc = quota exceeded",
+	},
+}
+
+// waitPlain is a caller passing nothing but the clock of the harness.
+func waitPlain(ctx context.Context, op *operation.Operation, clock ...grpc.CallOption) error {
+	return op.Wait(ctx, clock...)
+}
+
+func checkCompat(t *testing.T, tc compatCase, trace *operationtest.Trace) {
+	t.Helper()
+	polls := make([]time.Duration, len(trace.Polls))
+	for i, p := range trace.Polls {
+		polls[i] = p.At
+	}
+	assert.Equal(t, tc.polls, polls, "the times of the polls")
+	assert.Equal(t, tc.sleeps, trace.Sleeps)
+	if tc.err == "" {
+		assert.NoError(t, trace.Err)
+	} else {
+		require.Error(t, trace.Err)
+		assert.Equal(t, tc.err, trace.Err.Error())
+	}
+}
+
+func TestWaitDefaults_Timeline(t *testing.T) {
+	for name, tc := range compatCases {
+		t.Run(name, func(t *testing.T) {
+			checkCompat(t, tc, operationtest.Run(context.Background(), tc.script, waitPlain))
+		})
+	}
+}
+
+func TestWaitDefaults_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	polls := 0
+	trace := operationtest.Run(ctx, []operationtest.Step{operationtest.Running()}, func(ctx context.Context, op *operation.Operation, clock ...grpc.CallOption) error {
+		return op.Wait(ctx, append(clock, operation.WithPollCallback(func(*operation.Operation, int, error) {
+			if polls++; polls == 2 {
+				cancel()
+			}
+		}))...)
+	})
+	assert.ErrorIs(t, trace.Err, context.Canceled)
+	assert.Equal(t, "operation (id=kfo1) wait context done: context canceled", trace.Err.Error())
+	// The virtual timer of the sleep after the cancel may fire first, and a poll made then
+	// sees the context done.
+	assert.GreaterOrEqual(t, len(trace.Polls), 2)
+}
+
+// TestWaitDefaults_ExplicitDefaults checks that the option machinery is a no-op on its
+// defaults: spelling them out, or setting the zero policy, makes the same wait as passing
+// nothing.
+func TestWaitDefaults_ExplicitDefaults(t *testing.T) {
+	explicit := map[string]operationtest.WaitFunc{
+		"default options": func(ctx context.Context, op *operation.Operation, clock ...grpc.CallOption) error {
+			return op.WaitInterval(ctx, operation.DefaultPollInterval, append(clock,
+				operation.WithRetryPolicy(operation.DefaultRetryPolicy),
+				operation.FatalCodes(operation.DefaultFatalCodes...),
+				operation.WithIntervalHintBounds(operation.DefaultMinIntervalHint, operation.DefaultMaxIntervalHint),
+			)...)
+		},
+		"zero policy": func(ctx context.Context, op *operation.Operation, clock ...grpc.CallOption) error {
+			return op.WithPollPolicy(operation.PollPolicy{}).Wait(ctx, append(clock, operation.PollPolicy{}.Options()...)...)
+		},
+		"zero interval": func(ctx context.Context, op *operation.Operation, clock ...grpc.CallOption) error {
+			return op.WaitInterval(ctx, 0, clock...)
+		},
+	}
+	for name, wait := range explicit {
+		for caseName, tc := range compatCases {
+			t.Run(name+"/"+caseName, func(t *testing.T) {
+				checkCompat(t, tc, operationtest.Run(context.Background(), tc.script, wait))
+			})
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package operation
+
+import (
+	"errors"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// ErrNoConsoleURL is returned by ConsoleURL for operations without a ConsoleURLFunc.
+var ErrNoConsoleURL = errors.New("operation: no console url")
+
+// ConsoleURLFunc returns the URL of the console page of the resource of the operation, e.g.
+// the one of the SDK for the operations of WrapOperation.
+type ConsoleURLFunc func(o *Operation) (string, error)
+
+// WithConsoleURL sets the builder of ConsoleURL.
+func (o *Operation) WithConsoleURL(build ConsoleURLFunc) *Operation {
+	o.consoleURL = build
+	return o
+}
+
+// ConsoleURL returns the URL of the console page of the resource of the operation. It
+// returns ErrResourceIDUnavailable until the resource ID is known, see ResourceIdWait, and
+// ErrNoConsoleURL without WithConsoleURL.
+func (o *Operation) ConsoleURL() (string, error) {
+	if o.consoleURL == nil {
+		return "", sdkerrors.WithMessagef(ErrNoConsoleURL, "%s", o)
+	}
+	if o.ResourceId() == "" {
+		return "", sdkerrors.WithMessagef(ErrResourceIDUnavailable, "%s console url", o)
+	}
+	var u string
+	err := SafeCall("console url", func() (err error) {
+		u, err = o.consoleURL(o)
+		return err
+	})
+	return u, err
+}
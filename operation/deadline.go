@@ -0,0 +1,212 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+)
+
+// Operation metadata keys of the completion hints set by the backend, see ServerDeadline.
+const (
+	// ExpectedDurationMetadataKey is the expected time from the creation of the operation to
+	// its completion, as a Go duration, e.g. "15m", or a number of seconds.
+	ExpectedDurationMetadataKey = "expected_duration"
+	// DeadlineMetadataKey is the time the operation is expected to be done by, in RFC 3339.
+	// It takes precedence over ExpectedDurationMetadataKey.
+	DeadlineMetadataKey = "deadline"
+)
+
+// ErrServerDeadlineExceeded is matched by errors.Is for every *ServerDeadlineExceededError.
+var ErrServerDeadlineExceeded = errors.New("operation: server deadline exceeded")
+
+// ServerDeadlineExceededError is returned by waits with WithServerDeadlineHint that ran
+// past the deadline derived from the hint of the operation. It unwraps to
+// context.DeadlineExceeded.
+type ServerDeadlineExceededError struct {
+	Operation *Operation
+	// Deadline is the local time the wait was bounded by.
+	Deadline time.Time
+}
+
+func (e *ServerDeadlineExceededError) Error() string {
+	return fmt.Sprintf("%s is not done by the deadline hinted by the server, %s", e.Operation, e.Deadline.Format(time.RFC3339))
+}
+
+func (e *ServerDeadlineExceededError) Is(target error) bool {
+	return target == ErrServerDeadlineExceeded
+}
+
+func (e *ServerDeadlineExceededError) Unwrap() error { return context.DeadlineExceeded }
+
+// ServerDeadline returns the time the operation is expected to be done by according to
+// its metadata, see DeadlineMetadataKey and ExpectedDurationMetadataKey, in the server clock.
+// It reports false if the operation has no hint or the hint is malformed.
+func (o *Operation) ServerDeadline() (time.Time, bool) {
+	md := o.Metadata()
+	if v, ok := md[DeadlineMetadataKey]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, true
+		}
+	}
+	v, ok := md[ExpectedDurationMetadataKey]
+	if !ok || o.Proto().GetCreateTime() == nil {
+		return time.Time{}, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d <= 0 {
+		return time.Time{}, false
+	}
+	return o.CreatedAt().Add(d), true
+}
+
+// WithServerDeadlineHint makes waits bound themselves by the completion hint of the operation,
+// see ServerDeadline, read on the first successful poll: a wait still running at the hinted
+// deadline fails with *ServerDeadlineExceededError. The deadline is corrected for the clock
+// offset set with WithClockSkew and bounded by WithServerDeadlineMax. Waits of operations
+// without a valid hint are not bounded. Disabled by default.
+func WithServerDeadlineHint(enabled bool) grpc.CallOption {
+	return &serverDeadlineHint{enabled: enabled}
+}
+
+// WithServerDeadlineMax bounds the deadlines derived by WithServerDeadlineHint to max after
+// the wait started. Zero means no bound.
+func WithServerDeadlineMax(max time.Duration) grpc.CallOption {
+	return &serverDeadlineMax{max: max}
+}
+
+type serverDeadlineHint struct {
+	grpc.EmptyCallOption
+	enabled bool
+}
+
+type serverDeadlineMax struct {
+	grpc.EmptyCallOption
+	max time.Duration
+}
+
+// serverDeadline bounds a single wait by the hint of the operation.
+type serverDeadline struct {
+	enabled bool
+	max     time.Duration
+	now     func() time.Time
+	started time.Time
+	// read is set once the hint was looked up, deadline if it was found.
+	read     bool
+	deadline time.Time
+}
+
+func serverDeadlineOf(opts []grpc.CallOption, now func() time.Time) *serverDeadline {
+	d := &serverDeadline{now: now, started: now()}
+	for _, o := range opts {
+		switch o := o.(type) {
+		case *serverDeadlineHint:
+			d.enabled = o.enabled
+		case *serverDeadlineMax:
+			d.max = o.max
+		}
+	}
+	return d
+}
+
+// bound returns ctx bounded by the deadline hinted by the operation, once it was polled
+// successfully. It reports false if ctx is left as is.
+func (d *serverDeadline) bound(ctx context.Context, o *Operation) (context.Context, context.CancelFunc, bool) {
+	if !d.enabled || d.read {
+		return ctx, nil, false
+	}
+	d.read = true
+	deadline, ok := o.ServerDeadline()
+	if !ok {
+		return ctx, nil, false
+	}
+	if o.skew != nil {
+		deadline = deadline.Add(-o.skew())
+	}
+	if max := d.started.Add(d.max); d.max > 0 && deadline.After(max) {
+		deadline = max
+	}
+	d.deadline = deadline
+	// The timeout is measured from now, so that the deadline follows the clock of the package.
+	ctx, cancel := context.WithTimeout(ctx, deadline.Sub(d.now()))
+	return ctx, cancel, true
+}
+
+// exceeded returns *ServerDeadlineExceededError if ctx is done by the hinted deadline only,
+// while parent is not done.
+func (d *serverDeadline) exceeded(ctx, parent context.Context, o *Operation) error {
+	if d.deadline.IsZero() || ctx.Err() == nil || parent.Err() != nil {
+		return nil
+	}
+	return &ServerDeadlineExceededError{Operation: o, Deadline: d.deadline}
+}
+
+// ErrWaitTimeout is matched by errors.Is for every *WaitTimeoutError.
+var ErrWaitTimeout = errors.New("operation: wait timed out")
+
+// WaitTimeoutError is returned by WaitTimeout when the timeout runs out before the operation
+// is done. The state of the operation is the one of its last successful poll: Status and
+// Metadata are copied from it, e.g. to tell whether the operation made progress. It has the
+// status codes.DeadlineExceeded and unwraps to the error the wait was cut short with.
+type WaitTimeoutError struct {
+	Operation *Operation
+	Timeout   time.Duration
+	Elapsed   time.Duration
+	Status    dc.Operation_Status
+	Metadata  map[string]string
+	Err       error
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("%s is not done after %s, %s: %v", e.Operation, e.Elapsed.Round(time.Millisecond), e.Status, e.Err)
+}
+
+func (e *WaitTimeoutError) Is(target error) bool { return target == ErrWaitTimeout }
+func (e *WaitTimeoutError) Unwrap() error        { return e.Err }
+
+func (e *WaitTimeoutError) GRPCStatus() *status.Status {
+	return status.New(codes.DeadlineExceeded, e.Error())
+}
+
+// WaitTimeout is Wait bounded by timeout: it returns *WaitTimeoutError if the operation is
+// still running when the timeout runs out, while ctx is alive. A timeout of at most 0 means
+// no timeout.
+func (o *Operation) WaitTimeout(ctx context.Context, timeout time.Duration, opts ...grpc.CallOption) error {
+	if timeout <= 0 {
+		return o.Wait(ctx, opts...)
+	}
+	clock := clockOf(o, opts)
+	started := clock.now()
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := o.Wait(waitCtx, opts...)
+	if err == nil || o.Done() || ctx.Err() != nil || waitCtx.Err() == nil {
+		return err
+	}
+	metadata := make(map[string]string, len(o.Metadata()))
+	for k, v := range o.Metadata() {
+		metadata[k] = v
+	}
+	return &WaitTimeoutError{
+		Operation: o,
+		Timeout:   timeout,
+		Elapsed:   clock.now().Sub(started),
+		Status:    o.Proto().GetStatus(),
+		Metadata:  metadata,
+		Err:       err,
+	}
+}
@@ -0,0 +1,156 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// runningPoll returns a poll func of an operation created at created that never completes.
+func runningPoll(created time.Time, metadata map[string]string) PollFunc {
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		return &Proto{Id: id, CreateTime: timestamppb.New(created), Metadata: metadata, Status: doublecloud.Operation_STATUS_RUNNING}, 0, nil
+	}
+}
+
+func TestOperation_ServerDeadline(t *testing.T) {
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		metadata map[string]string
+		want     time.Time
+	}{
+		"deadline":                    {map[string]string{DeadlineMetadataKey: "2023-05-01T12:30:00Z"}, created.Add(30 * time.Minute)},
+		"expected duration":           {map[string]string{ExpectedDurationMetadataKey: "15m"}, created.Add(15 * time.Minute)},
+		"expected duration seconds":   {map[string]string{ExpectedDurationMetadataKey: "90"}, created.Add(90 * time.Second)},
+		"deadline takes precedence":   {map[string]string{DeadlineMetadataKey: "2023-05-01T12:30:00Z", ExpectedDurationMetadataKey: "1m"}, created.Add(30 * time.Minute)},
+		"malformed deadline":          {map[string]string{DeadlineMetadataKey: "soon", ExpectedDurationMetadataKey: "1m"}, created.Add(time.Minute)},
+		"absent":                      {nil, time.Time{}},
+		"malformed expected duration": {map[string]string{ExpectedDurationMetadataKey: "a while"}, time.Time{}},
+		"negative expected duration":  {map[string]string{ExpectedDurationMetadataKey: "-5m"}, time.Time{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, ok := New(nil, &Proto{CreateTime: timestamppb.New(created), Metadata: tc.metadata}).ServerDeadline()
+			assert.Equal(t, !tc.want.IsZero(), ok)
+			assert.True(t, tc.want.Equal(got), "got %v", got)
+		})
+	}
+}
+
+func TestWait_ServerDeadlineHint(t *testing.T) {
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	poll := runningPoll(time.Now(), map[string]string{ExpectedDurationMetadataKey: "50ms"})
+
+	started := time.Now()
+	err := op.Wait(context.Background(), WithPollFunc(poll), WithServerDeadlineHint(true))
+	require.ErrorIs(t, err, ErrServerDeadlineExceeded)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var exceeded *ServerDeadlineExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Same(t, op, exceeded.Operation)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestWait_ServerDeadlineHintFallback(t *testing.T) {
+	for name, metadata := range map[string]map[string]string{
+		"absent":    nil,
+		"malformed": {ExpectedDurationMetadataKey: "a while", DeadlineMetadataKey: "soon"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+			op.newTimer = fastTimer
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			err := op.Wait(ctx, WithPollFunc(runningPoll(time.Now(), metadata)), WithServerDeadlineHint(true))
+			assert.ErrorIs(t, err, context.DeadlineExceeded, "the wait runs until its context is done")
+			assert.NotErrorIs(t, err, ErrServerDeadlineExceeded)
+		})
+	}
+}
+
+func TestWait_ServerDeadlineHintDisabled(t *testing.T) {
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := op.Wait(ctx, WithPollFunc(runningPoll(time.Now(), map[string]string{ExpectedDurationMetadataKey: "1ms"})))
+	assert.NotErrorIs(t, err, ErrServerDeadlineExceeded, "hints are ignored by default")
+}
+
+func TestWait_ServerDeadlineMax(t *testing.T) {
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	poll := runningPoll(time.Now(), map[string]string{ExpectedDurationMetadataKey: "1h"})
+
+	started := time.Now()
+	err := op.Wait(context.Background(), WithPollFunc(poll), WithServerDeadlineHint(true), WithServerDeadlineMax(50*time.Millisecond))
+	var exceeded *ServerDeadlineExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.WithinDuration(t, started.Add(50*time.Millisecond), exceeded.Deadline, 20*time.Millisecond, "the hint is capped")
+	assert.Less(t, time.Since(started), time.Second)
+
+	// The user context still wins over a later hint.
+	op = New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = op.Wait(ctx, WithPollFunc(poll), WithServerDeadlineHint(true), WithServerDeadlineMax(time.Hour))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrServerDeadlineExceeded)
+}
+
+func TestForwardProgress_ServerDeadline(t *testing.T) {
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	op := New(nil, &Proto{Id: "cho1", CreateTime: timestamppb.New(created), Metadata: map[string]string{ExpectedDurationMetadataKey: "10m"},
+		Status: doublecloud.Operation_STATUS_DONE})
+	var got ProgressUpdate
+	require.NoError(t, ForwardProgress(context.Background(), op, func(u ProgressUpdate) error { got = u; return nil }, WaitConfig{}))
+	assert.True(t, created.Add(10*time.Minute).Equal(got.ServerDeadline))
+}
+
+func TestWaitTimeout(t *testing.T) {
+	var polls int
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		if polls > 1 {
+			return nil, 0, status.Error(codes.Unavailable, "down")
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING, Metadata: map[string]string{"progress": "50"}}, 0, nil
+	}
+	op := pendingKafkaOp(nil)
+	err := op.WaitTimeout(context.Background(), 50*time.Millisecond, WithPollFunc(poll), WithRetryPolicy(RetryPolicy{Codes: []codes.Code{codes.Unavailable}, MaxConsecutiveFailures: 1000}))
+
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, "kfo1", timeoutErr.Operation.Id())
+	assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	assert.GreaterOrEqual(t, timeoutErr.Elapsed, 50*time.Millisecond)
+	assert.Equal(t, doublecloud.Operation_STATUS_RUNNING, timeoutErr.Status)
+	assert.Equal(t, map[string]string{"progress": "50"}, timeoutErr.Metadata)
+	assert.False(t, op.Done())
+	assert.Equal(t, map[string]string{"progress": "50"}, op.Metadata(), "the operation has the state of the last successful poll")
+}
+
+func TestWaitTimeout_DoneInTime(t *testing.T) {
+	poll := scriptedPoll(pollStep{status: doublecloud.Operation_STATUS_RUNNING}, pollStep{status: doublecloud.Operation_STATUS_DONE})
+	assert.NoError(t, pendingKafkaOp(nil).WaitTimeout(context.Background(), time.Minute, WithPollFunc(poll)))
+}
+
+func TestWaitTimeout_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := pendingKafkaOp(nil).WaitTimeout(ctx, time.Minute, WithPollFunc(runningPoll(time.Now(), nil)))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrWaitTimeout)
+}
@@ -0,0 +1,159 @@
+package operation
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+)
+
+// DefaultDecisionLogSize is the size of the decision logs of NewDecisionLog with a size of
+// at most 0.
+const DefaultDecisionLogSize = 100
+
+// IntervalSource is what chose the interval of a Decision.
+type IntervalSource string
+
+const (
+	// IntervalDefault is the poll interval of the wait, e.g. DefaultPollInterval.
+	IntervalDefault IntervalSource = "default"
+	// IntervalServer is the interval suggested by the server, possibly capped by the policy.
+	IntervalServer IntervalSource = "server"
+	// IntervalBackoff is the interval of WithBackoff.
+	IntervalBackoff IntervalSource = "backoff"
+	// IntervalAdaptive is the interval of WithAdaptiveInterval.
+	IntervalAdaptive IntervalSource = "adaptive"
+	// IntervalLongPoll is a blocking wait call made instead of sleeping, see LongPollClient.
+	IntervalLongPoll IntervalSource = "long poll"
+	// IntervalRefresh is a poll made again right after the credentials were refreshed.
+	IntervalRefresh IntervalSource = "credentials refresh"
+	// IntervalNone ends the wait: the operation is done or the wait failed.
+	IntervalNone IntervalSource = ""
+)
+
+// Decision is what a wait did after a poll, see WithDecisionLog.
+type Decision struct {
+	OperationID string
+	// Attempt is the 1-based number of the poll of the wait.
+	Attempt int
+	At      time.Time
+	// Code is the code of the poll error, codes.OK if the poll succeeded, and Status the
+	// status of the operation after the poll.
+	Code   codes.Code
+	Status dc.Operation_Status
+	// Failures is the number of failed polls in a row so far, retried by the RetryPolicy.
+	Failures int
+	// Interval is the wait before the next poll, chosen by Source.
+	Interval time.Duration
+	Source   IntervalSource
+}
+
+func (d Decision) String() string {
+	next := "end"
+	if d.Source != IntervalNone {
+		next = fmt.Sprintf("next in %s (%s)", d.Interval, d.Source)
+	}
+	return fmt.Sprintf("%s %s #%d: %s, %s, %d failures, %s", d.At.Format(time.RFC3339Nano), d.OperationID, d.Attempt, d.Code, d.Status, d.Failures, next)
+}
+
+func (d *Decision) setFailures(n int) {
+	if d != nil {
+		d.Failures = n
+	}
+}
+
+func (d *Decision) setNext(interval time.Duration, source IntervalSource) {
+	if d != nil {
+		d.Interval, d.Source = interval, source
+	}
+}
+
+// DecisionLog keeps the latest decisions of the waits made with WithDecisionLog, e.g. to find
+// out why a wait slept that long. It is safe for concurrent use: waits may share a log.
+type DecisionLog struct {
+	mu      sync.Mutex
+	entries []Decision
+	// next is the index of the oldest entry once the log is full, and total counts the
+	// decisions recorded.
+	next  int
+	total int
+}
+
+// NewDecisionLog returns a log keeping the latest size decisions, DefaultDecisionLogSize if
+// size is at most 0.
+func NewDecisionLog(size int) *DecisionLog {
+	if size <= 0 {
+		size = DefaultDecisionLogSize
+	}
+	return &DecisionLog{entries: make([]Decision, 0, size)}
+}
+
+func (l *DecisionLog) add(d Decision) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total++
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, d)
+		return
+	}
+	l.entries[l.next] = d
+	l.next = (l.next + 1) % len(l.entries)
+}
+
+// Decisions returns the decisions kept, the oldest first.
+func (l *DecisionLog) Decisions() []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append(append([]Decision(nil), l.entries[l.next:]...), l.entries[:l.next]...)
+}
+
+// Dropped returns the number of decisions recorded but not kept anymore.
+func (l *DecisionLog) Dropped() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total - len(l.entries)
+}
+
+// String returns the decisions kept, one per line, e.g. to be logged with the error of a wait.
+func (l *DecisionLog) String() string {
+	var b strings.Builder
+	if n := l.Dropped(); n > 0 {
+		fmt.Fprintf(&b, "%d earlier decisions dropped\n", n)
+	}
+	for _, d := range l.Decisions() {
+		b.WriteString(d.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// WithDecisionLog makes waits record what they do after every poll in log: the interval
+// before the next poll and what chose it, the poll error codes and the retries so far.
+// Disabled by default.
+func WithDecisionLog(log *DecisionLog) grpc.CallOption {
+	return &decisionLog{log: log}
+}
+
+type decisionLog struct {
+	grpc.EmptyCallOption
+	log *DecisionLog
+}
+
+// decisionLogOf returns the log of opts, nil if there is none.
+func decisionLogOf(opts []grpc.CallOption) *DecisionLog {
+	var log *DecisionLog
+	for _, o := range opts {
+		if o, ok := o.(*decisionLog); ok {
+			log = o.log
+		}
+	}
+	return log
+}
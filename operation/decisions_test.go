@@ -0,0 +1,102 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pollStep is a poll of scriptedPoll: an error code, or the status of the operation with the
+// interval suggested by the server.
+type pollStep struct {
+	code   codes.Code
+	status doublecloud.Operation_Status
+	hint   time.Duration
+}
+
+// scriptedPoll answers the polls with the steps in order.
+func scriptedPoll(steps ...pollStep) PollFunc {
+	var n int
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		s := steps[n]
+		n++
+		if s.code != codes.OK {
+			return nil, 0, status.Error(s.code, s.code.String())
+		}
+		return &Proto{Id: id, Status: s.status}, s.hint, nil
+	}
+}
+
+type decisionSummary struct {
+	Attempt  int
+	Code     codes.Code
+	Failures int
+	Interval time.Duration
+	Source   IntervalSource
+}
+
+func summarize(decisions []Decision) []decisionSummary {
+	var s []decisionSummary
+	for _, d := range decisions {
+		s = append(s, decisionSummary{d.Attempt, d.Code, d.Failures, d.Interval, d.Source})
+	}
+	return s
+}
+
+func TestWait_DecisionLog(t *testing.T) {
+	poll := scriptedPoll(
+		pollStep{code: codes.Unavailable},
+		pollStep{code: codes.NotFound},
+		pollStep{status: doublecloud.Operation_STATUS_RUNNING, hint: 5 * time.Second},
+		pollStep{status: doublecloud.Operation_STATUS_RUNNING},
+		pollStep{status: doublecloud.Operation_STATUS_DONE},
+	)
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	log := NewDecisionLog(0)
+	require.NoError(t, op.WaitInterval(context.Background(), 2*time.Second, WithPollFunc(poll), WithDecisionLog(log)))
+
+	assert.Equal(t, []decisionSummary{
+		{1, codes.Unavailable, 1, 2 * time.Second, IntervalDefault},
+		{2, codes.NotFound, 2, 2 * time.Second, IntervalDefault},
+		{3, codes.OK, 0, 5 * time.Second, IntervalServer},
+		{4, codes.OK, 0, 2 * time.Second, IntervalDefault},
+		{5, codes.OK, 0, 0, IntervalNone},
+	}, summarize(log.Decisions()))
+	assert.Equal(t, doublecloud.Operation_STATUS_DONE, log.Decisions()[4].Status)
+	assert.Equal(t, "kfo1", log.Decisions()[0].OperationID)
+	assert.Contains(t, log.String(), "kfo1 #3: OK, STATUS_RUNNING, 0 failures, next in 5s (server)")
+}
+
+func TestWait_DecisionLogFailure(t *testing.T) {
+	poll := scriptedPoll(pollStep{status: doublecloud.Operation_STATUS_RUNNING}, pollStep{code: codes.InvalidArgument})
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	log := NewDecisionLog(0)
+	policy := BackoffPolicy{InitialInterval: 3 * time.Second, MaxInterval: time.Minute, Multiplier: 2}
+	require.ErrorIs(t, op.Wait(context.Background(), WithPollFunc(poll), WithBackoff(policy), WithDecisionLog(log)), ErrPoll)
+
+	assert.Equal(t, []decisionSummary{
+		{1, codes.OK, 0, 3 * time.Second, IntervalBackoff},
+		{2, codes.InvalidArgument, 1, 0, IntervalNone},
+	}, summarize(log.Decisions()), "the decision of the failing poll is recorded too")
+}
+
+func TestDecisionLog_Bounded(t *testing.T) {
+	log := NewDecisionLog(2)
+	for i := 1; i <= 5; i++ {
+		log.add(Decision{Attempt: i})
+	}
+	decisions := log.Decisions()
+	require.Len(t, decisions, 2)
+	assert.Equal(t, 4, decisions[0].Attempt, "the oldest decisions are dropped")
+	assert.Equal(t, 5, decisions[1].Attempt)
+	assert.Equal(t, 3, log.Dropped())
+	assert.Contains(t, log.String(), "3 earlier decisions dropped\n")
+}
@@ -0,0 +1,78 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+var (
+	// ErrOperationFailed is matched by errors.Is for every *OperationError.
+	ErrOperationFailed = errors.New("operation: operation failed")
+	// ErrPoll is matched by errors.Is for every *PollError.
+	ErrPoll = errors.New("operation: poll failed")
+)
+
+// OperationError is returned by waits of operations done with an error. It unwraps to the
+// status error of the operation, and status.Code and status.FromError report its status.
+type OperationError struct {
+	Operation *Operation
+	Status    *status.Status
+}
+
+// operationError returns *OperationError if the operation failed, nil otherwise.
+func operationError(o *Operation) error {
+	st := o.ErrorStatus()
+	if st == nil {
+		return nil
+	}
+	return &OperationError{Operation: o, Status: st}
+}
+
+// FailureSignature returns the sdkerrors.Signature of the error of the operation, so that
+// failures differing only in IDs and timestamps, e.g. of retried flaky operations, can be
+// deduplicated. It returns "" if the operation has no error.
+func FailureSignature(o *Operation) string {
+	st := o.ErrorStatus()
+	if st == nil {
+		return ""
+	}
+	return sdkerrors.Signature(st.Err())
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Operation, e.Status.Err())
+}
+
+func (e *OperationError) Is(target error) bool       { return target == ErrOperationFailed }
+func (e *OperationError) Unwrap() error              { return e.Status.Err() }
+func (e *OperationError) GRPCStatus() *status.Status { return e.Status }
+
+// FailureClass returns the sdkerrors.Classify class of the error of the operation, e.g. to
+// count only the operations failed by the provider in an SLO.
+func (e *OperationError) FailureClass() sdkerrors.FailureClass {
+	return sdkerrors.Classify(e.Status.Err())
+}
+
+// PollError is returned by waits whose poll failed with an error that isn't retried, see
+// RetryPolicy. Exhausted retries return *PollRetriesExhaustedError and fatal codes
+// *FatalPollError instead. It unwraps to the error of the poll.
+type PollError struct {
+	Operation *Operation
+	// Attempts is the number of failed polls in a row, this one included.
+	Attempts int
+	Err      error
+}
+
+func (e *PollError) Error() string {
+	return fmt.Sprintf("%s poll fail: %v", e.Operation, e.Err)
+}
+
+func (e *PollError) Is(target error) bool { return target == ErrPoll }
+func (e *PollError) Unwrap() error        { return e.Err }
+
+// GRPCStatus returns the status of the poll error, codes.Unknown for errors of other kinds.
+func (e *PollError) GRPCStatus() *status.Status { return status.Convert(e.Err) }
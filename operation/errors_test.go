@@ -0,0 +1,147 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+func TestWait_OperationError(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE, Error: &rpcstatus.Status{Code: int32(code.Code_RESOURCE_EXHAUSTED), Message: "quota"}}, nil
+	}}
+	err := pendingKafkaOp(client).Wait(context.Background())
+
+	var opErr *OperationError
+	require.ErrorAs(t, err, &opErr)
+	assert.ErrorIs(t, err, ErrOperationFailed)
+	assert.NotErrorIs(t, err, ErrPoll)
+	assert.Equal(t, "kfo1", opErr.Operation.Id())
+	assert.Equal(t, codes.ResourceExhausted, opErr.Status.Code())
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(errors.Unwrap(err)))
+	assert.EqualError(t, err, "operation (id=kfo1) failed: rpc error: code = ResourceExhausted desc = quota")
+
+	ok := New(client, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_DONE})
+	assert.NoError(t, ok.Wait(context.Background()))
+}
+
+func TestWait_PollError(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		if n == 1 {
+			return nil, status.Error(codes.Unavailable, "blip")
+		}
+		return nil, status.Error(codes.InvalidArgument, "bad id")
+	}}
+	err := pendingKafkaOp(client).Wait(context.Background())
+
+	var pollErr *PollError
+	require.ErrorAs(t, err, &pollErr)
+	assert.ErrorIs(t, err, ErrPoll)
+	assert.NotErrorIs(t, err, ErrOperationFailed)
+	assert.Equal(t, 2, pollErr.Attempts, "the failed polls in a row are counted")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, codes.InvalidArgument, status.Code(errors.Unwrap(err)))
+	assert.EqualError(t, err, "operation (id=kfo1) poll fail: rpc error: code = InvalidArgument desc = bad id")
+}
+
+func TestWait_PollErrorCallbackPanic(t *testing.T) {
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) { panic("boom") }
+	err := pendingKafkaOp(nil).Wait(context.Background(), WithPollFunc(poll))
+
+	var pollErr *PollError
+	require.ErrorAs(t, err, &pollErr)
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+}
+
+func TestWait_PollRetriesExhaustedUnwrap(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return nil, status.Error(codes.Unavailable, "down")
+	}}
+	err := pendingKafkaOp(client).Wait(context.Background())
+
+	assert.ErrorIs(t, err, ErrPollRetriesExhausted)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, codes.Unavailable, status.Code(errors.Unwrap(err)))
+}
+
+func TestWait_FatalPollUnwrap(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	}}
+	err := pendingKafkaOp(client).Wait(context.Background())
+
+	assert.ErrorIs(t, err, ErrFatalPoll)
+	assert.Equal(t, codes.PermissionDenied, status.Code(errors.Unwrap(err)))
+}
+
+func TestWait_ContextErrors(t *testing.T) {
+	requireKinds(t)
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			cancel()
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}}
+		err := pendingKafkaOp(client).Wait(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, ErrPoll)
+		assert.NotErrorIs(t, err, ErrOperationFailed)
+	})
+	t.Run("cancelled while polling", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			cancel()
+			return nil, status.FromContextError(context.Canceled).Err()
+		}}
+		err := pendingKafkaOp(client).Wait(ctx)
+		assert.ErrorIs(t, err, context.Canceled, "the status of the poll is the error of ctx")
+		assert.NotErrorIs(t, err, ErrPoll)
+	})
+}
+
+func TestFailureSignature(t *testing.T) {
+	invalid := loadOperation(t, "clickhouse_create_cluster_invalid.json")
+	failed := loadOperation(t, "clickhouse_create_cluster_failed.json")
+	kafka := loadOperation(t, "kafka_create_cluster_invalid.json")
+	assert.Equal(t, FailureSignature(invalid), FailureSignature(failed), "the same failure of operations of different clusters")
+	assert.NotEqual(t, FailureSignature(invalid), FailureSignature(kafka))
+	assert.Contains(t, FailureSignature(kafka), "|kafka.double.cloud|NETWORK_BUSY|")
+
+	busy := func(id string) *Operation {
+		return New(nil, &Proto{Id: "op1", Error: &rpcstatus.Status{
+			Code:    int32(code.Code_FAILED_PRECONDITION),
+			Message: "network " + id + " is being modified by another operation",
+		}})
+	}
+	assert.Equal(t, FailureSignature(busy("vpc1a2b3c4d5e6f7g8h9")), FailureSignature(busy("vpc9h8g7f6e5d4c3b2a1")))
+	assert.Empty(t, FailureSignature(New(nil, &Proto{Id: "op1", Status: doublecloud.Operation_STATUS_DONE})))
+}
+
+func TestOperationError_FailureClass(t *testing.T) {
+	for name, want := range map[string]sdkerrors.FailureClass{
+		"clickhouse_create_cluster_invalid.json":      sdkerrors.ProviderError,
+		"clickhouse_create_cluster_failed.json":       sdkerrors.ProviderError,
+		"kafka_create_cluster_invalid.json":           sdkerrors.UserError,
+		"kafka_create_cluster_invalid_no_reason.json": sdkerrors.UserError,
+	} {
+		var opErr *OperationError
+		require.ErrorAs(t, operationError(loadOperation(t, name)), &opErr, name)
+		assert.Equal(t, want, opErr.FailureClass(), name)
+	}
+}
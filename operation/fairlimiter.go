@@ -0,0 +1,126 @@
+package operation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// FairLimiter is a PollLimiter serving the polls of the waits sharing it in the order they
+// asked, whatever the order the limiter it wraps serves them in. Without it, with a
+// limiter serving whoever asks first once a token is available, the waits with short
+// intervals ask more often and may get all the tokens, the waits with long ones never
+// polling on time. The zero value is not usable, see NewFairLimiter.
+type FairLimiter struct {
+	l PollLimiter
+
+	mu sync.Mutex
+	// busy is set while a poll waits for l; the others are queued, first in first out.
+	busy  bool
+	queue []chan struct{}
+}
+
+// NewFairLimiter returns a limiter serving the polls in order, one at a time from l, e.g.
+// a *rate.Limiter of golang.org/x/time/rate.
+func NewFairLimiter(l PollLimiter) *FairLimiter {
+	return &FairLimiter{l: l}
+}
+
+// Wait blocks until the polls asking before are served and l allows this one, or ctx is
+// done.
+func (f *FairLimiter) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.busy {
+		f.busy = true
+		f.mu.Unlock()
+	} else {
+		turn := make(chan struct{})
+		f.queue = append(f.queue, turn)
+		f.mu.Unlock()
+		select {
+		case <-turn:
+		case <-ctx.Done():
+			if !f.leave(turn) {
+				// The turn came with ctx done, and is passed on.
+				f.next()
+			}
+			return ctx.Err()
+		}
+	}
+	defer f.next()
+	return f.l.Wait(ctx)
+}
+
+// leave removes turn from the queue, reporting whether it was queued.
+func (f *FairLimiter) leave(turn chan struct{}) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, t := range f.queue {
+		if t == turn {
+			f.queue = append(f.queue[:i], f.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// next gives the turn to the first poll queued, if any.
+func (f *FairLimiter) next() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queue) == 0 {
+		f.busy = false
+		return
+	}
+	close(f.queue[0])
+	f.queue[0] = nil
+	f.queue = f.queue[1:]
+}
+
+// WaitResult is what a single wait made with WithWaitResult did, e.g. to tell the waits
+// starved by a shared PollLimiter.
+type WaitResult struct {
+	// Polls counts the polls of the wait, including the failed ones.
+	Polls int
+	// LimiterWait is the time the polls of the wait waited for their PollLimiter.
+	LimiterWait time.Duration
+}
+
+// WithWaitResult makes the waits add what they do to r: Wait, WaitInterval, ResourceIdWait,
+// and WaitAll and WaitAny, adding up their operations. r is to be read once the waits
+// return.
+func WithWaitResult(r *WaitResult) grpc.CallOption {
+	return &waitResultOption{r: r}
+}
+
+type waitResultOption struct {
+	grpc.EmptyCallOption
+	r *WaitResult
+	// mu guards r against the concurrent polls of batch waits.
+	mu sync.Mutex
+}
+
+func waitResultOf(opts []grpc.CallOption) *waitResultOption {
+	var r *waitResultOption
+	for _, opt := range opts {
+		if opt, ok := opt.(*waitResultOption); ok {
+			r = opt
+		}
+	}
+	if r == nil || r.r == nil {
+		return nil
+	}
+	return r
+}
+
+func (w *waitResultOption) add(polls int, limiterWait time.Duration) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.r.Polls += polls
+	w.r.LimiterWait += limiterWait
+}
@@ -0,0 +1,173 @@
+package operation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gateLimiter allows a poll per token sent to it.
+type gateLimiter chan struct{}
+
+func (g gateLimiter) Wait(ctx context.Context) error {
+	select {
+	case <-g:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// queued waits until n polls are queued by f.
+func queued(t *testing.T, f *FairLimiter, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return len(f.queue) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestFairLimiter_Order(t *testing.T) {
+	gate := make(gateLimiter)
+	f := NewFairLimiter(gate)
+	served := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			assert.NoError(t, f.Wait(context.Background()))
+			served <- i
+		}(i)
+		// The first one waits for the gate, the others are queued.
+		queued(t, f, i)
+	}
+	for i := 0; i < 3; i++ {
+		gate <- struct{}{}
+		assert.Equal(t, i, <-served, "first in first out")
+	}
+	assert.False(t, f.busy)
+}
+
+func TestFairLimiter_ContextDone(t *testing.T) {
+	gate := make(gateLimiter)
+	f := NewFairLimiter(gate)
+	first := make(chan error)
+	go func() { first <- f.Wait(context.Background()) }()
+	queued(t, f, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	second := make(chan error)
+	go func() { second <- f.Wait(ctx) }()
+	queued(t, f, 1)
+	third := make(chan error)
+	go func() { third <- f.Wait(context.Background()) }()
+	queued(t, f, 2)
+
+	cancel()
+	assert.ErrorIs(t, <-second, context.Canceled)
+	queued(t, f, 1)
+	gate <- struct{}{}
+	require.NoError(t, <-first)
+	gate <- struct{}{}
+	require.NoError(t, <-third, "the turn of the poll leaving is not lost")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, f.Wait(ctx), context.Canceled, "the error of the limiter")
+	assert.False(t, f.busy)
+}
+
+func TestWithWaitResult(t *testing.T) {
+	limiter := &countingLimiter{delay: time.Millisecond}
+	var result WaitResult
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(&stepClock{}), WithPollLimiter(limiter), WithWaitResult(&result), WithPollFunc(pollsUntilDone(3))))
+	assert.Equal(t, 3, result.Polls)
+	assert.GreaterOrEqual(t, result.LimiterWait, 3*time.Millisecond)
+
+	result = WaitResult{}
+	op = New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	_, err := op.ResourceIdWait(context.Background(), time.Minute, WithWaitResult(&result), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING, ResourceId: "kfc1"}, 0, nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, WaitResult{Polls: 1}, result, "no limiter")
+
+	result = WaitResult{}
+	ops := []*Operation{
+		New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}),
+		New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING}),
+	}
+	require.NoError(t, WaitAll(context.Background(), ops, WithWaitResult(&result), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+	})))
+	assert.Equal(t, 2, result.Polls, "the polls of all the operations")
+}
+
+// spinLimiter hands out its tokens to whoever asks first, as a limiter without a queue.
+type spinLimiter struct {
+	tokens atomic.Int64
+}
+
+func (l *spinLimiter) Wait(ctx context.Context) error {
+	for {
+		if l.tokens.Add(-1) >= 0 {
+			return nil
+		}
+		l.tokens.Add(1)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Microsecond):
+		}
+	}
+}
+
+func TestFairLimiter_Stress(t *testing.T) {
+	const waits, tokens = 100, 1000
+	underlying := &spinLimiter{}
+	f := NewFairLimiter(underlying)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var polls atomic.Int64
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		if polls.Add(1) == tokens {
+			cancel()
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, 0, nil
+	}
+
+	results := make([]WaitResult, waits)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(r *WaitResult) {
+			defer wg.Done()
+			op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+			err := op.WaitInterval(ctx, 0, WithBusyPoll(), WithPollLimiter(f), WithWaitResult(r), WithPollFunc(poll))
+			assert.ErrorIs(t, err, context.Canceled)
+		}(&results[i])
+	}
+	// The tokens are handed out once all the waits are queued for their first poll.
+	queued(t, f, waits-1)
+	underlying.tokens.Store(tokens)
+	wg.Wait()
+
+	least, most := results[0], results[0]
+	for _, r := range results {
+		if r.Polls < least.Polls {
+			least = r
+		}
+		if r.Polls > most.Polls {
+			most = r
+		}
+	}
+	assert.Equal(t, int64(tokens), polls.Load())
+	assert.LessOrEqual(t, most.Polls-least.Polls, 2, "the waits are served in turn")
+	assert.Positive(t, least.LimiterWait)
+}
@@ -0,0 +1,46 @@
+package operation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// fakeKafkaClient is a kafka.OperationServiceClient answering Get with a scripted function
+// of the 1-based call number.
+type fakeKafkaClient struct {
+	mu   sync.Mutex
+	gets int
+	get  func(n int, id string) (*Proto, error)
+}
+
+func (f *fakeKafkaClient) Get(ctx context.Context, in *kafka.GetOperationRequest, opts ...grpc.CallOption) (*Proto, error) {
+	f.mu.Lock()
+	f.gets++
+	n := f.gets
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		// Like gRPC, which returns the status of the context error.
+		return nil, status.FromContextError(err).Err()
+	}
+	return f.get(n, in.GetOperationId())
+}
+
+func (f *fakeKafkaClient) List(ctx context.Context, in *kafka.ListOperationsRequest, opts ...grpc.CallOption) (*kafka.ListOperationsResponse, error) {
+	return &kafka.ListOperationsResponse{}, nil
+}
+
+func (f *fakeKafkaClient) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets
+}
+
+// fastTimer replaces poll intervals with a millisecond to keep tests quick.
+func fastTimer(time.Duration) (func() <-chan time.Time, func() bool) {
+	return defaultTimer(time.Millisecond)
+}
@@ -0,0 +1,92 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultFatalCodes end a wait on the first poll failing with them: retrying won't help
+// until the caller gets new credentials.
+var DefaultFatalCodes = []codes.Code{codes.Unauthenticated, codes.PermissionDenied}
+
+var (
+	// ErrFatalPoll is matched by errors.Is for every *FatalPollError.
+	ErrFatalPoll = errors.New("operation: fatal poll error")
+	// ErrCredentials is matched by errors.Is for *FatalPollError caused by rejected credentials.
+	ErrCredentials = errors.New("operation: credentials rejected")
+)
+
+// FatalCodes replaces DefaultFatalCodes for the wait. Polls failing with these codes end
+// the wait with *FatalPollError instead of being retried.
+func FatalCodes(c ...codes.Code) grpc.CallOption {
+	return &fatalCodes{codes: c}
+}
+
+type fatalCodes struct {
+	grpc.EmptyCallOption
+	codes []codes.Code
+}
+
+func fatalCodesOf(opts []grpc.CallOption) map[codes.Code]bool {
+	list := DefaultFatalCodes
+	for _, o := range opts {
+		if o, ok := o.(*fatalCodes); ok {
+			list = o.codes
+		}
+	}
+	set := make(map[codes.Code]bool, len(list))
+	for _, c := range list {
+		set[c] = true
+	}
+	return set
+}
+
+// CredentialsRefresher forces refresh of the cached credentials used by the calls with opts.
+type CredentialsRefresher func(ctx context.Context, opts ...grpc.CallOption) error
+
+// WithCredentialsRefresher sets the refresher called once per wait before a fatal poll error
+// is returned. The poll is retried if the refresh succeeds.
+func (o *Operation) WithCredentialsRefresher(r CredentialsRefresher) *Operation {
+	o.refresh = r
+	return o
+}
+
+// FatalPollError is returned by waits when a poll fails with one of the fatal codes.
+type FatalPollError struct {
+	Operation *Operation
+	Code      codes.Code
+	// Refreshed reports whether credentials were refreshed and the poll retried before giving up.
+	Refreshed bool
+	// RefreshErr is the error of the credentials refresh, if it failed.
+	RefreshErr error
+	Err        error
+}
+
+func (e *FatalPollError) Error() string {
+	msg := fmt.Sprintf("%s poll fail: %v", e.Operation, e.Err)
+	if e.RefreshErr != nil {
+		msg += fmt.Sprintf("; credentials refresh failed: %v", e.RefreshErr)
+	}
+	if e.CredentialsRejected() {
+		msg += "; refresh credentials and wait again"
+	}
+	return msg
+}
+
+// CredentialsRejected reports whether the poll failed because of the credentials.
+func (e *FatalPollError) CredentialsRejected() bool {
+	return e.Code == codes.Unauthenticated || e.Code == codes.PermissionDenied
+}
+
+func (e *FatalPollError) Is(target error) bool {
+	return target == ErrFatalPoll || target == ErrCredentials && e.CredentialsRejected()
+}
+
+func (e *FatalPollError) Unwrap() error { return e.Err }
+
+func (e *FatalPollError) GRPCStatus() *status.Status { return status.Convert(e.Err) }
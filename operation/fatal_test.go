@@ -0,0 +1,142 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// expiringTokenClient answers PENDING until the token expires on the third poll, and then
+// rejects polls until the token is refreshed, after which the operation is done.
+func expiringTokenClient(refreshed *atomic.Bool, code codes.Code) *fakeKafkaClient {
+	return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		switch {
+		case n < 3:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_PENDING}, nil
+		case !refreshed.Load():
+			return nil, status.Error(code, "token expired")
+		default:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		}
+	}}
+}
+
+func TestWait_FatalCode_RefreshesOnceAndRetries(t *testing.T) {
+	requireKinds(t)
+	var refreshed atomic.Bool
+	var refreshes int
+	client := expiringTokenClient(&refreshed, codes.Unauthenticated)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	op.WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error {
+		refreshes++
+		refreshed.Store(true)
+		return nil
+	})
+
+	require.NoError(t, op.Wait(context.Background()))
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, 4, client.calls(), "the rejected poll must be retried right after the refresh")
+}
+
+func TestWait_FatalCode_RefreshDoesNotHelp(t *testing.T) {
+	requireKinds(t)
+	var refreshed atomic.Bool // never set: the refreshed token is rejected too
+	var refreshes int
+	client := expiringTokenClient(&refreshed, codes.Unauthenticated)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	op.WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error {
+		refreshes++
+		return nil
+	})
+
+	err := op.Wait(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, 4, client.calls())
+
+	var fatal *FatalPollError
+	require.True(t, errors.As(err, &fatal))
+	assert.True(t, fatal.Refreshed)
+	assert.Equal(t, codes.Unauthenticated, fatal.Code)
+	assert.ErrorIs(t, err, ErrFatalPoll)
+	assert.ErrorIs(t, err, ErrCredentials)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Contains(t, err.Error(), "refresh credentials")
+}
+
+func TestWait_FatalCode_RefreshFails(t *testing.T) {
+	requireKinds(t)
+	var refreshed atomic.Bool
+	client := expiringTokenClient(&refreshed, codes.PermissionDenied)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	op.WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error {
+		return errors.New("key revoked")
+	})
+
+	err := op.Wait(context.Background())
+	var fatal *FatalPollError
+	require.True(t, errors.As(err, &fatal))
+	assert.False(t, fatal.Refreshed)
+	assert.EqualError(t, fatal.RefreshErr, "key revoked")
+	assert.Equal(t, 3, client.calls(), "the poll must not be retried without new credentials")
+	assert.ErrorIs(t, err, ErrCredentials)
+}
+
+func TestWait_FatalCode_NoRefresher(t *testing.T) {
+	requireKinds(t)
+	var refreshed atomic.Bool
+	client := expiringTokenClient(&refreshed, codes.Unauthenticated)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+
+	err := op.Wait(context.Background())
+	assert.ErrorIs(t, err, ErrCredentials)
+	assert.Equal(t, 3, client.calls())
+}
+
+func TestWait_FatalCodes(t *testing.T) {
+	requireKinds(t)
+	notFound := func() *fakeKafkaClient {
+		return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			return nil, status.Error(codes.NotFound, "no such operation")
+		}}
+	}
+
+	t.Run("makes retried codes fatal", func(t *testing.T) {
+		client := notFound()
+		op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = fastTimer
+
+		err := op.Wait(context.Background(), FatalCodes(codes.NotFound))
+		assert.ErrorIs(t, err, ErrFatalPoll)
+		assert.NotErrorIs(t, err, ErrCredentials)
+		assert.Equal(t, 1, client.calls())
+	})
+
+	t.Run("replaces default codes", func(t *testing.T) {
+		var refreshed atomic.Bool
+		client := expiringTokenClient(&refreshed, codes.Unauthenticated)
+		op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = fastTimer
+		op.WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error {
+			t.Error("refresh must not be called for non-fatal codes")
+			return nil
+		})
+
+		err := op.Wait(context.Background(), FatalCodes(codes.NotFound))
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrFatalPoll)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
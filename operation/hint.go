@@ -0,0 +1,141 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// DefaultMinIntervalHint and DefaultMaxIntervalHint bound the poll intervals suggested by
+	// the server unless WithIntervalHintBounds is set.
+	DefaultMinIntervalHint = 100 * time.Millisecond
+	DefaultMaxIntervalHint = 10 * time.Minute
+)
+
+// ErrIntervalHint is matched by errors.Is for every *IntervalHintError.
+var ErrIntervalHint = errors.New("operation: malformed poll interval hint")
+
+// IntervalHintError reports a poll interval header the wait couldn't parse and ignored,
+// see WithIntervalHintCallback.
+type IntervalHintError struct {
+	Operation *Operation
+	// Value is the value of the header.
+	Value string
+	Err   error
+}
+
+func (e *IntervalHintError) Error() string {
+	return fmt.Sprintf("%s: malformed poll interval hint %q: %v", e.Operation, e.Value, e.Err)
+}
+
+func (e *IntervalHintError) Is(target error) bool { return target == ErrIntervalHint }
+
+func (e *IntervalHintError) Unwrap() error { return e.Err }
+
+// ParsePollInterval parses a poll interval suggested by the server: a number of seconds,
+// possibly fractional, e.g. "5" or "0.5", or a Go duration, e.g. "500ms". Zero means no
+// suggestion; negative values are malformed.
+func ParsePollInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var d time.Duration
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(seconds) || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return 0, errors.New("out of range")
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	} else if d, err = time.ParseDuration(value); err != nil {
+		return 0, errors.New("neither seconds nor a duration")
+	}
+	if d < 0 {
+		return 0, errors.New("negative interval")
+	}
+	return d, nil
+}
+
+// WithIntervalHintBounds clamps the positive poll intervals suggested by the server to
+// [min, max], protecting waits from bogus hints. Non-positive bounds mean
+// DefaultMinIntervalHint and DefaultMaxIntervalHint. Intervals of a PollFunc are not clamped.
+func WithIntervalHintBounds(min, max time.Duration) grpc.CallOption {
+	return &intervalHintBounds{min: min, max: max}
+}
+
+type intervalHintBounds struct {
+	grpc.EmptyCallOption
+	min, max time.Duration
+}
+
+// IntervalHintCallback is called by waits with the poll interval headers they ignore because
+// they are malformed, see WithIntervalHintCallback.
+type IntervalHintCallback func(o *Operation, err *IntervalHintError)
+
+// WithIntervalHintCallback makes waits call f when a poll returns a malformed poll interval
+// header, e.g. to log it. The wait ignores such headers and polls at its own interval.
+func WithIntervalHintCallback(f IntervalHintCallback) grpc.CallOption {
+	return &intervalHintCallback{f: f}
+}
+
+type intervalHintCallback struct {
+	grpc.EmptyCallOption
+	f IntervalHintCallback
+}
+
+// SuggestedPollInterval returns the poll interval suggested by the server at the last
+// successful poll of a wait, within the bounds of WithIntervalHintBounds, zero if there
+// was none.
+func (o *Operation) SuggestedPollInterval() time.Duration {
+	return o.state.suggestedInterval()
+}
+
+// intervalHint returns the poll interval suggested by the server in the poll response
+// headers of o, clamped to the bounds of opts. Malformed values are reported to the
+// callback of opts and ignored.
+func (o *Operation) intervalHint(headers metadata.MD, opts []grpc.CallOption) (time.Duration, bool) {
+	vals := headers.Get(PollIntervalMetadataKey)
+	if len(vals) == 0 {
+		return 0, false
+	}
+	min, max := DefaultMinIntervalHint, DefaultMaxIntervalHint
+	var callback IntervalHintCallback
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case *intervalHintBounds:
+			min, max = opt.min, opt.max
+			if min <= 0 {
+				min = DefaultMinIntervalHint
+			}
+			if max <= 0 {
+				max = DefaultMaxIntervalHint
+			}
+		case *intervalHintCallback:
+			callback = opt.f
+		}
+	}
+	if max < min {
+		max = min
+	}
+	d, err := ParsePollInterval(vals[0])
+	if err != nil {
+		if callback != nil {
+			_ = SafeCall("interval hint callback", func() error {
+				callback(o, &IntervalHintError{Operation: o, Value: vals[0], Err: err})
+				return nil
+			})
+		}
+		return 0, false
+	}
+	switch {
+	case d == 0:
+	case d < min:
+		d = min
+	case d > max:
+		d = max
+	}
+	return d, true
+}
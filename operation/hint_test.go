@@ -0,0 +1,60 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePollInterval(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"5":      5 * time.Second,
+		" 5 ":    5 * time.Second,
+		"0":      0,
+		"0.5":    500 * time.Millisecond,
+		"0.001":  time.Millisecond,
+		"500ms":  500 * time.Millisecond,
+		"1m30s":  90 * time.Second,
+		"2.5s":   2500 * time.Millisecond,
+		"86400":  24 * time.Hour,
+		"0s":     0,
+		"1e1":    10 * time.Second,
+		"-0":     0,
+		"0.0000": 0,
+	} {
+		got, err := ParsePollInterval(value)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, want, got, value)
+		}
+	}
+	for _, value := range []string{"", "-3", "-0.5", "-1s", "abc", "5 seconds", "5s5", "NaN", "Inf", "1e300"} {
+		_, err := ParsePollInterval(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestWait_IntervalHintCallback(t *testing.T) {
+	requireKinds(t)
+	for _, hint := range []string{"-3", "garbage", "NaN"} {
+		t.Run(hint, func(t *testing.T) {
+			c := newFakeClock(t)
+			client := &hintKafkaClient{fakeKafkaClient: fakeKafkaClient{get: runningFor()}, hint: hint}
+			op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+			var reported []*IntervalHintError
+			callback := func(o *Operation, err *IntervalHintError) {
+				assert.Same(t, op, o)
+				reported = append(reported, err)
+			}
+			require.NoError(t, op.WaitInterval(context.Background(), 2*time.Second, WithClock(c), WithIntervalHintCallback(callback)))
+			assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, c.intervals, "malformed hints are ignored")
+			require.NotEmpty(t, reported)
+			assert.Equal(t, hint, reported[0].Value)
+			assert.ErrorIs(t, reported[0], ErrIntervalHint)
+			assert.Contains(t, reported[0].Error(), "operation (id=kfo1): malformed poll interval hint")
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package operation
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// intervalPolicy chooses the poll intervals of a single wait instead of the fixed poll
+// interval, see WithAdaptiveInterval and WithBackoff.
+type intervalPolicy interface {
+	// next returns the interval before the next poll of the running operation.
+	next(o *Operation) time.Duration
+	// hinted returns the interval before the next poll when the server suggested d.
+	hinted(d time.Duration) time.Duration
+	// done is called when the wait returns.
+	done(o *Operation)
+	// source is the source of the intervals of next, see Decision.
+	source() IntervalSource
+}
+
+// intervalPolicyOption is a call option choosing the interval policy of waits.
+type intervalPolicyOption interface {
+	newPolicy(h *DurationHistogram) intervalPolicy
+}
+
+// intervalPolicyOf returns a new policy of the last interval policy option, nil if there is none.
+func intervalPolicyOf(opts []grpc.CallOption) intervalPolicy {
+	var option intervalPolicyOption
+	h := DefaultDurationHistogram
+	for _, o := range opts {
+		switch o := o.(type) {
+		case intervalPolicyOption:
+			option = o
+		case *durationHistogram:
+			h = o.h
+		}
+	}
+	if option == nil {
+		return nil
+	}
+	return option.newPolicy(h)
+}
+
+// WithBusyPoll makes waits poll again right away when their poll interval is not positive,
+// e.g. with WaitInterval(ctx, 0) and no interval suggested by the server. Without it such
+// waits poll every DefaultPollInterval: polling in a loop without sleeping floods the API,
+// so it is only meant for tests with fake clients.
+func WithBusyPoll() grpc.CallOption {
+	return &busyPoll{}
+}
+
+type busyPoll struct {
+	grpc.EmptyCallOption
+}
+
+// busyPollOf reports whether opts allow polling without sleeping.
+func busyPollOf(opts []grpc.CallOption) bool {
+	for _, o := range opts {
+		if _, ok := o.(*busyPoll); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// maxJournalBuffer is the size of the lines a WaitJournal buffers before writing them
+// without waiting for a wait to finish.
+const maxJournalBuffer = 32 << 10
+
+// JournalEntry is a line of a WaitJournal: the Decision of a poll, as JSON.
+type JournalEntry struct {
+	Time        time.Time `json:"time"`
+	OperationID string    `json:"operation_id"`
+	Status      string    `json:"status"`
+	Code        string    `json:"code"`
+	// Attempt is the 1-based number of the poll of the wait, and Failures the number of
+	// failed polls in a row so far.
+	Attempt  int `json:"attempt"`
+	Failures int `json:"failures"`
+	// IntervalMs is the wait before the next poll, chosen by Source, empty once the wait is
+	// over, see IntervalSource.
+	IntervalMs int64  `json:"interval_ms"`
+	Source     string `json:"source,omitempty"`
+}
+
+func newJournalEntry(d Decision) JournalEntry {
+	return JournalEntry{
+		Time:        d.At.UTC(),
+		OperationID: d.OperationID,
+		Status:      d.Status.String(),
+		Code:        d.Code.String(),
+		Attempt:     d.Attempt,
+		Failures:    d.Failures,
+		IntervalMs:  d.Interval.Milliseconds(),
+		Source:      string(d.Source),
+	}
+}
+
+// WaitJournal appends a JSON line per poll of the waits made with it to a writer, e.g. to
+// find out after an incident which operations were waited on and what the API returned, see
+// JournalEntry. The lines are buffered and written when a wait finishes, so every write
+// holds whole lines, e.g. for the rotation of OpenJournalFile. It is safe for concurrent
+// use: waits may share a journal.
+type WaitJournal struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewWaitJournal returns a journal writing to w.
+func NewWaitJournal(w io.Writer) *WaitJournal {
+	return &WaitJournal{w: w}
+}
+
+func (j *WaitJournal) add(d Decision) {
+	if j == nil {
+		return
+	}
+	line, err := json.Marshal(newJournalEntry(d))
+	if err != nil {
+		warn(fmt.Errorf("wait journal: %w", err))
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.buf.Write(line)
+	j.buf.WriteByte('\n')
+	if j.buf.Len() >= maxJournalBuffer {
+		j.warnFlush()
+	}
+}
+
+// Flush writes the lines buffered so far, e.g. before the process exits while waits are in
+// progress.
+func (j *WaitJournal) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.flushLocked()
+}
+
+func (j *WaitJournal) flushLocked() error {
+	if j.buf.Len() == 0 {
+		return nil
+	}
+	// The lines are dropped on errors: the journal must not grow without bounds.
+	defer j.buf.Reset()
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+// flush writes the buffered lines at the end of a wait, reporting the errors to the warning
+// handler.
+func (j *WaitJournal) flush() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.warnFlush()
+}
+
+func (j *WaitJournal) warnFlush() {
+	if err := j.flushLocked(); err != nil {
+		warn(fmt.Errorf("wait journal: %w", err))
+	}
+}
+
+// WithWaitJournal makes waits append a line per poll to j, see WaitJournal. It takes
+// precedence over the journal of Operation.WithWaitJournal. Disabled by default.
+func WithWaitJournal(j *WaitJournal) grpc.CallOption {
+	return &waitJournal{journal: j}
+}
+
+type waitJournal struct {
+	grpc.EmptyCallOption
+	journal *WaitJournal
+}
+
+// waitJournalOf returns the journal of opts, else the one of the operation.
+func waitJournalOf(o *Operation, opts []grpc.CallOption) *WaitJournal {
+	j := o.journal
+	for _, opt := range opts {
+		if opt, ok := opt.(*waitJournal); ok {
+			j = opt.journal
+		}
+	}
+	return j
+}
+
+// WithWaitJournal sets the journal of the waits of the operation, e.g. the one of
+// the SDK, see WithWaitJournal.
+func (o *Operation) WithWaitJournal(j *WaitJournal) *Operation {
+	o.journal = j
+	return o
+}
@@ -0,0 +1,168 @@
+package operation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use, counting the writes.
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writes++
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func journalEntries(t *testing.T, s string) []JournalEntry {
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		var e JournalEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e), scanner.Text())
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestWaitJournal_Lines(t *testing.T) {
+	poll := scriptedPoll(
+		pollStep{code: codes.Unavailable},
+		pollStep{status: doublecloud.Operation_STATUS_RUNNING, hint: 5 * time.Second},
+		pollStep{status: doublecloud.Operation_STATUS_DONE},
+	)
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	out := &lockedBuffer{}
+	require.NoError(t, op.WaitInterval(context.Background(), 2*time.Second, WithPollFunc(poll), WithWaitJournal(NewWaitJournal(out))))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^\{"time":"[^"]+Z","operation_id":"kfo1","status":"STATUS_PENDING","code":"Unavailable","attempt":1,"failures":1,"interval_ms":2000,"source":"default"\}$`, lines[0])
+	assert.Regexp(t, `"status":"STATUS_RUNNING","code":"OK","attempt":2,"failures":0,"interval_ms":5000,"source":"server"\}$`, lines[1])
+	assert.Regexp(t, `"status":"STATUS_DONE","code":"OK","attempt":3,"failures":0,"interval_ms":0\}$`, lines[2], "no source once the wait is over")
+	assert.Equal(t, 1, out.writes, "the lines are written at once")
+}
+
+func TestWaitJournal_FlushOnFinish(t *testing.T) {
+	out := &lockedBuffer{}
+	journal := NewWaitJournal(out)
+	var polls int
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		assert.Empty(t, out.String(), "nothing is written while the wait is in progress")
+		st := doublecloud.Operation_STATUS_RUNNING
+		if polls == 3 {
+			st = doublecloud.Operation_STATUS_DONE
+		}
+		return &Proto{Id: id, Status: st}, 0, nil
+	}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING}).WithWaitJournal(journal)
+	op.newTimer = fastTimer
+	require.NoError(t, op.Wait(context.Background(), WithPollFunc(poll)))
+	assert.Len(t, journalEntries(t, out.String()), 3, "the journal of the operation")
+
+	// A failed wait is flushed too.
+	ctx, cancel := context.WithCancel(context.Background())
+	op = New(nil, &Proto{Id: "cho2", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	err := op.Wait(ctx, WithWaitJournal(journal), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		cancel()
+		return nil, 0, ctx.Err()
+	}))
+	require.ErrorIs(t, err, context.Canceled)
+	entries := journalEntries(t, out.String())
+	require.Len(t, entries, 4)
+	assert.Equal(t, "cho2", entries[3].OperationID)
+}
+
+func TestWaitJournal_Concurrent(t *testing.T) {
+	out := &lockedBuffer{}
+	journal := NewWaitJournal(out)
+	const waits, polls = 20, 50
+	var wg sync.WaitGroup
+	for i := 0; i < waits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var n int
+			poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+				n++
+				st := doublecloud.Operation_STATUS_RUNNING
+				if n == polls {
+					st = doublecloud.Operation_STATUS_DONE
+				}
+				return &Proto{Id: id, Status: st}, 0, nil
+			}
+			op := New(nil, &Proto{Id: fmt.Sprintf("cho%d", i), Status: doublecloud.Operation_STATUS_PENDING})
+			op.newTimer = fastTimer
+			assert.NoError(t, op.Wait(context.Background(), WithPollFunc(poll), WithWaitJournal(journal)))
+		}(i)
+	}
+	wg.Wait()
+
+	entries := journalEntries(t, out.String())
+	require.Len(t, entries, waits*polls, "every line is whole")
+	attempts := map[string]int{}
+	for _, e := range entries {
+		attempts[e.OperationID]++
+		assert.Equal(t, attempts[e.OperationID], e.Attempt, "the lines of a wait are in order")
+	}
+	assert.Len(t, attempts, waits)
+}
+
+func TestJournalFile_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waits.jsonl")
+	f, err := OpenJournalFile(path, 10, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "a line too long\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(name string) string {
+		b, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, "a line too long\n", read(path), "the writes are not split")
+	assert.Equal(t, "eeee\n", read(path+".1"))
+	assert.Equal(t, "cccc\ndddd\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3", "at most 2 backups")
+
+	// The file is reopened for appending.
+	f, err = OpenJournalFile(path, 0, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("ffff\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "a line too long\nffff\n", read(path))
+	_, err = f.Write([]byte("gggg\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
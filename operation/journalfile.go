@@ -0,0 +1,98 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// JournalFile is a file rotated by size, e.g. the one of a WaitJournal, see OpenJournalFile.
+// It is safe for concurrent use.
+type JournalFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenJournalFile opens the file of path for appending. Before a write would make it larger
+// than maxSize, the file is renamed to path.1, path.1 to path.2 and so on, keeping backups
+// files at most, and a new one is created. Writes are never split: a write larger than
+// maxSize goes to a file of its own. The file is never rotated with a maxSize of at most 0.
+func OpenJournalFile(path string, maxSize int64, backups int) (*JournalFile, error) {
+	f := &JournalFile{path: path, maxSize: maxSize, backups: backups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *JournalFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.f, f.size = file, info.Size()
+	return nil
+}
+
+func (f *JournalFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups and opens a new file.
+func (f *JournalFile) rotate() error {
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+	f.f = nil
+	if f.backups <= 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return f.open()
+	}
+	for i := f.backups - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Close closes the file. Callers writing through a WaitJournal flush it first.
+func (f *JournalFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
@@ -0,0 +1,124 @@
+package operation
+
+import (
+	"encoding/json"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+type operationJSON struct {
+	// Version is JSONVersion, or 0 for the states encoded before the format was versioned.
+	Version   int             `json:"version,omitempty"`
+	Operation json.RawMessage `json:"operation"`
+	Origin    *originJSON     `json:"origin,omitempty"`
+	// Resumed marks the state of NewFromID, not polled yet.
+	Resumed bool `json:"resumed,omitempty"`
+	// Synthetic marks the operations of NewCompleted and NewFailedSynthetic.
+	Synthetic bool `json:"synthetic,omitempty"`
+	// Omitted are the payloads left out for exceeding the limit of WithPayloadLimit.
+	Omitted []PayloadRef `json:"omitted_payloads,omitempty"`
+}
+
+type originJSON struct {
+	Method   string `json:"method"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// MarshalJSON encodes the state of the operation, with protojson and the options of
+// WithJSONEncoding, and its origin, e.g. to persist an operation in flight. The client and
+// the settings of With methods other than WithOrigin are not encoded. The metadata values
+// and the error details larger than the limit of WithPayloadLimit are left out, see
+// PayloadRef.
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	var err error
+	state, resumed := o.state.load()
+	v := operationJSON{Version: JSONVersion, Resumed: resumed, Synthetic: o.synthetic}
+	state, v.Omitted = limitPayloads(state, o.payloadLimitOrDefault())
+	if v.Operation, err = o.jsonEncoding.Marshal(state); err != nil {
+		return nil, sdkerrors.WithMessage(err, "operation")
+	}
+	if o.origin.method != "" {
+		v.Origin = &originJSON{Method: o.origin.method, Resource: o.origin.resource}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the operation encoded by MarshalJSON under any options, keeping the
+// client, the JSON encoding and the payload limit of o. States of format versions newer
+// than JSONVersion fail with *CheckpointVersionError.
+// Operations decoded without a client, e.g. with json.Unmarshal into a new Operation, can
+// be attached to one with WithClient to be polled and waited.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var v operationJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Version < 0 || v.Version > JSONVersion {
+		return &CheckpointVersionError{Format: "operation", Version: v.Version, MaxVersion: JSONVersion}
+	}
+	state := &Proto{}
+	if err := protojson.Unmarshal(v.Operation, state); err != nil {
+		return sdkerrors.WithMessage(err, "operation")
+	}
+	*o = Operation{state: newOpState(state, v.Resumed), client: o.client, newTimer: defaultTimer, synthetic: v.Synthetic, jsonEncoding: o.jsonEncoding, payloadLimit: o.payloadLimit}
+	if v.Origin != nil {
+		o.origin = origin{method: v.Origin.Method, resource: v.Origin.Resource}
+	}
+	return nil
+}
+
+// WithClient sets the client polling the operation, e.g. of an operation decoded with
+// UnmarshalJSON, replacing the clients of NewMulti.
+func (o *Operation) WithClient(client Client) *Operation {
+	o.state.mu.Lock()
+	defer o.state.mu.Unlock()
+	o.client, o.failover = client, failover{}
+	return o
+}
+
+// WithJSONEncoding sets the protojson options MarshalJSON encodes the state of the operation
+// with, protojson defaults otherwise; the enums are encoded as strings unless
+// opts.UseEnumNumbers.
+func (o *Operation) WithJSONEncoding(opts protojson.MarshalOptions) *Operation {
+	o.jsonEncoding = opts
+	return o
+}
+
+// Snapshot is a plain summary of the state of an operation, e.g. to be logged. Its texts
+// are truncated to TextLimit bytes with TruncateText.
+//
+//revive:disable:var-naming
+type Snapshot struct {
+	Id          string    `json:"id"`
+	Description string    `json:"description,omitempty"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	ResourceId  string    `json:"resource_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Done        bool      `json:"done"`
+	// Error is the error message of failed operations, empty otherwise.
+	Error string `json:"error,omitempty"`
+}
+
+//revive:enable:var-naming
+
+// Snapshot returns the summary of the current state of the operation.
+func (o *Operation) Snapshot() Snapshot {
+	s := Snapshot{
+		Id:          o.Id(),
+		Description: TruncateText(o.Description(), TextLimit),
+		CreatedBy:   TruncateText(o.CreatedBy(), TextLimit),
+		ResourceId:  TruncateText(o.ResourceId(), TextLimit),
+		CreatedAt:   o.CreatedAt(),
+		Done:        o.Done(),
+	}
+	// Not o.Error: it clones the status with its details, whatever their size.
+	if st := o.Proto().GetError(); st != nil {
+		s.Error = status.New(codes.Code(st.GetCode()), TruncateText(st.GetMessage(), TextLimit)).Err().Error()
+	}
+	return s
+}
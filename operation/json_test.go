@@ -0,0 +1,142 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func roundTrip(t *testing.T, o *Operation) *Operation {
+	data, err := json.Marshal(o)
+	require.NoError(t, err)
+	var back Operation
+	require.NoError(t, json.Unmarshal(data, &back))
+	return &back
+}
+
+func TestOperation_JSON(t *testing.T) {
+	detail, err := anypb.New(&errdetails.ErrorInfo{Reason: "QUOTA", Domain: "doublecloud.com"})
+	require.NoError(t, err)
+	state := &Proto{
+		Id:          "kfo1",
+		Description: "Create cluster",
+		CreatedBy:   "user1",
+		CreateTime:  timestamppb.New(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)),
+		Status:      doublecloud.Operation_STATUS_DONE,
+		Metadata:    map[string]string{"cluster_id": "kfc1", "topic_name": "events"},
+		Error:       &rpcstatus.Status{Code: int32(code.Code_RESOURCE_EXHAUSTED), Message: "quota", Details: []*anypb.Any{detail}},
+		ResourceId:  "kfc1",
+	}
+	o := New(nil, state).WithOrigin("kafka.Cluster.Create", "p1/kafka-1")
+
+	back := roundTrip(t, o)
+	assert.True(t, proto.Equal(state, back.Proto()), "got %v", back.Proto())
+	assert.Equal(t, o.String(), back.String(), "the origin is kept")
+	assert.True(t, back.Failed())
+	require.Len(t, back.ErrorStatus().Details(), 1)
+	assert.Equal(t, "QUOTA", back.ErrorStatus().Details()[0].(*errdetails.ErrorInfo).GetReason())
+}
+
+func TestOperation_JSONWithoutTimestamps(t *testing.T) {
+	back := roundTrip(t, New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_RUNNING}))
+	assert.Nil(t, back.Proto().GetCreateTime())
+	assert.True(t, back.CreatedAt().IsZero())
+	assert.Empty(t, back.Metadata())
+	assert.False(t, back.Done())
+}
+
+func TestOperation_JSONResumed(t *testing.T) {
+	back := roundTrip(t, NewFromID(nil, "kfo1"))
+	assert.False(t, back.Done(), "the state is still unknown")
+	method, _ := back.Origin()
+	assert.Equal(t, ResumedOrigin, method)
+}
+
+func TestOperation_JSONContinueWait(t *testing.T) {
+	requireKinds(t)
+	data, err := json.Marshal(New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_RUNNING}))
+	require.NoError(t, err)
+
+	var o Operation
+	require.NoError(t, json.Unmarshal(data, &o))
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+	o.WithClient(client)
+	o.newTimer = fastTimer
+	require.NoError(t, o.Wait(context.Background()))
+	assert.True(t, o.Ok())
+	assert.Equal(t, 1, client.gets)
+}
+
+func TestOperation_Snapshot(t *testing.T) {
+	o := New(nil, &Proto{
+		Id:         "kfo1",
+		CreatedBy:  "user1",
+		Status:     doublecloud.Operation_STATUS_DONE,
+		Error:      &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"},
+		ResourceId: "kfc1",
+	})
+	assert.Equal(t, Snapshot{
+		Id:         "kfo1",
+		CreatedBy:  "user1",
+		ResourceId: "kfc1",
+		Done:       true,
+		Error:      "rpc error: code = Internal desc = boom",
+	}, o.Snapshot())
+	assert.Empty(t, New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_DONE}).Snapshot().Error)
+}
+
+func TestOperation_JSONEncoding(t *testing.T) {
+	state := &Proto{
+		Id:         "kfo1",
+		CreateTime: timestamppb.New(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)),
+		Status:     doublecloud.Operation_STATUS_RUNNING,
+		Metadata:   map[string]string{"cluster_id": "kfc1"},
+		ResourceId: "kfc1",
+	}
+	for i := 0; i < 16; i++ {
+		opts := protojson.MarshalOptions{
+			Multiline:       i&1 != 0,
+			UseProtoNames:   i&2 != 0,
+			UseEnumNumbers:  i&4 != 0,
+			EmitUnpopulated: i&8 != 0,
+		}
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			o := New(nil, proto.Clone(state).(*Proto)).WithJSONEncoding(opts).WithOrigin("kafka.Cluster.Create", "kfc1")
+			data, err := json.Marshal(o)
+			require.NoError(t, err)
+			assert.Equal(t, opts.UseProtoNames, strings.Contains(string(data), `"resource_id"`), "%s", data)
+			assert.Equal(t, !opts.UseEnumNumbers, strings.Contains(string(data), `"STATUS_RUNNING"`), "%s", data)
+			assert.Equal(t, opts.EmitUnpopulated, strings.Contains(string(data), `"description"`), "%s", data)
+
+			var back Operation
+			require.NoError(t, json.Unmarshal(data, &back))
+			assert.True(t, proto.Equal(state, back.Proto()), "got %v", back.Proto())
+			assert.Equal(t, o.String(), back.String())
+		})
+	}
+}
+
+func TestOperation_JSONEncodingKept(t *testing.T) {
+	o := New(nil, &Proto{Id: "kfo1"}).WithJSONEncoding(protojson.MarshalOptions{UseProtoNames: true})
+	require.NoError(t, json.Unmarshal([]byte(`{"operation": {"id": "kfo2", "resourceId": "kfc1"}}`), o))
+	assert.Equal(t, "kfc1", o.ResourceId())
+	data, err := json.Marshal(o)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"resource_id":"kfc1"`, "the encoding of o is kept by UnmarshalJSON")
+}
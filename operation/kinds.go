@@ -0,0 +1,111 @@
+//go:build !operation_nokinds
+
+package operation
+
+// The kinds of the DoubleCloud services are registered here, the only place of the package
+// depending on their genproto packages. Programs that only need the wait machinery, getting
+// operations with their own kinds or with WithPollFunc, can leave these dependencies out
+// of their binaries by building with the operation_nokinds tag.
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+)
+
+func init() {
+	for _, k := range []Kind{
+		{
+			Name:   KindClickHouse,
+			Match:  hasPrefix(CLICKHOUSE_OPERATION_PREFIX),
+			Client: reflect.TypeOf((*clickhouse.OperationServiceClient)(nil)).Elem(),
+			NewRequest: func(id string) proto.Message {
+				return &clickhouse.GetOperationRequest{OperationId: id}
+			},
+			Get: func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error) {
+				return client.(clickhouse.OperationServiceClient).Get(ctx, req.(*clickhouse.GetOperationRequest), opts...)
+			},
+			List: func(ctx context.Context, client Client, projectID string, paging *dc.Paging, opts ...grpc.CallOption) ([]*Proto, string, error) {
+				resp, err := client.(clickhouse.OperationServiceClient).List(ctx, &clickhouse.ListOperationsRequest{ProjectId: projectID, Paging: paging}, opts...)
+				return resp.GetOperations(), resp.GetNextPage().GetToken(), err
+			},
+		},
+		{
+			Name:   KindKafka,
+			Match:  hasPrefix(KAFKA_OPERATION_PREFIX),
+			Client: reflect.TypeOf((*kafka.OperationServiceClient)(nil)).Elem(),
+			NewRequest: func(id string) proto.Message {
+				return &kafka.GetOperationRequest{OperationId: id}
+			},
+			Get: func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error) {
+				return client.(kafka.OperationServiceClient).Get(ctx, req.(*kafka.GetOperationRequest), opts...)
+			},
+			List: func(ctx context.Context, client Client, projectID string, paging *dc.Paging, opts ...grpc.CallOption) ([]*Proto, string, error) {
+				resp, err := client.(kafka.OperationServiceClient).List(ctx, &kafka.ListOperationsRequest{ProjectId: projectID, Paging: paging}, opts...)
+				return resp.GetOperations(), resp.GetNextPage().GetToken(), err
+			},
+		},
+		{
+			Name:   KindTransfer,
+			Match:  hasPrefix(TRANSFER_OPERATION_PREFIX, TRANSFER_ENDPOINTS_OPERATION_PREFIX),
+			Client: reflect.TypeOf((*transfer.OperationServiceClient)(nil)).Elem(),
+			NewRequest: func(id string) proto.Message {
+				return &transfer.GetOperationRequest{OperationId: id}
+			},
+			Get: func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error) {
+				return client.(transfer.OperationServiceClient).Get(ctx, req.(*transfer.GetOperationRequest), opts...)
+			},
+		},
+		{
+			Name: KindNetwork,
+			Match: func(id string) bool {
+				_, err := uuid.Parse(id)
+				return err == nil
+			},
+			Client: reflect.TypeOf((*network.OperationServiceClient)(nil)).Elem(),
+			NewRequest: func(id string) proto.Message {
+				return &network.GetOperationRequest{OperationId: id}
+			},
+			Get: func(ctx context.Context, client Client, req proto.Message, opts ...grpc.CallOption) (*Proto, error) {
+				return client.(network.OperationServiceClient).Get(ctx, req.(*network.GetOperationRequest), opts...)
+			},
+			List: func(ctx context.Context, client Client, projectID string, paging *dc.Paging, opts ...grpc.CallOption) ([]*Proto, string, error) {
+				resp, err := client.(network.OperationServiceClient).List(ctx, &network.ListOperationsRequest{ProjectId: projectID, Paging: paging}, opts...)
+				return resp.GetOperations(), resp.GetNextPage().GetToken(), err
+			},
+		},
+	} {
+		if err := registerClientKind(k, builtinClients[k.Name]); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// builtinClients check the clients of the kinds above with comma-ok assertions.
+var builtinClients = map[string]func(client Client) bool{
+	KindClickHouse: func(client Client) bool {
+		_, ok := client.(clickhouse.OperationServiceClient)
+		return ok
+	},
+	KindKafka: func(client Client) bool {
+		_, ok := client.(kafka.OperationServiceClient)
+		return ok
+	},
+	KindTransfer: func(client Client) bool {
+		_, ok := client.(transfer.OperationServiceClient)
+		return ok
+	},
+	KindNetwork: func(client Client) bool {
+		_, ok := client.(network.OperationServiceClient)
+		return ok
+	},
+}
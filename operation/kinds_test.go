@@ -0,0 +1,9 @@
+//go:build !operation_nokinds
+
+package operation
+
+import "testing"
+
+// requireKinds skips tests getting operations with the kinds of the DoubleCloud services,
+// which are not registered in builds with the operation_nokinds tag, see kinds.go.
+func requireKinds(t *testing.T) {}
@@ -0,0 +1,162 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultListPageSize is the page size of ListRequest unless it sets one.
+const DefaultListPageSize = 1000
+
+// ListRequest selects the operations of List and Iterate.
+type ListRequest struct {
+	// Kind is the service of the operations, e.g. KindClickHouse. Only the kinds registered
+	// with Kind.List can be listed: transfer operations can't.
+	Kind string
+	// ProjectID is the project of the operations.
+	ProjectID string
+	// PageSize is the number of operations requested per page, DefaultListPageSize if not
+	// positive. The services may return fewer.
+	PageSize int64
+}
+
+// List returns the operations of the request, getting all the pages, bound to client to be
+// polled and waited for, e.g. to wait for the operations in flight of a project. The
+// operations listed before a failure are returned with the error.
+func List(ctx context.Context, client Client, req ListRequest, opts ...grpc.CallOption) ([]*Operation, error) {
+	return Iterate(ctx, client, req, opts...).TakeAll()
+}
+
+// Iterate returns an iterator over the operations of List, getting the pages as they are
+// iterated, e.g. for projects with many operations.
+func Iterate(ctx context.Context, client Client, req ListRequest, opts ...grpc.CallOption) *Iterator {
+	it := &Iterator{ctx: ctx, client: client, req: req, opts: opts, seen: map[string]bool{}}
+	if it.req.PageSize <= 0 {
+		it.req.PageSize = DefaultListPageSize
+	}
+	it.kind = kindByName(req.Kind)
+	switch {
+	case req.ProjectID == "":
+		it.err = errors.New("operation: listing operations requires a project ID")
+	case it.kind == nil:
+		it.err = fmt.Errorf("operation: unknown operation kind %q", req.Kind)
+	case it.kind.list == nil:
+		it.err = fmt.Errorf("operation: %s operations can't be listed", req.Kind)
+	case !it.kind.implementedBy(client):
+		it.err = fmt.Errorf("operation: listing %s operations requires %s, got %T", req.Kind, it.kind.client, client)
+	}
+	it.done = it.err != nil
+	if !it.done {
+		it.leak = lifecycle.Track(it, "operation.Iterator")
+	}
+	return it
+}
+
+// Iterator iterates over the operations of Iterate.
+type Iterator struct {
+	ctx    context.Context
+	client Client
+	kind   *operationKind
+	req    ListRequest
+	opts   []grpc.CallOption
+
+	// items are the operations of the page left, items[0] is Value.
+	items []*Operation
+	// token is the token of the next page, seen are the tokens requested so far.
+	token string
+	seen  map[string]bool
+	done  bool
+	err   error
+	leak  *lifecycle.Tracker
+}
+
+func (it *Iterator) Next() bool {
+	if len(it.items) > 0 {
+		it.items = it.items[1:]
+	}
+	for len(it.items) == 0 && !it.done {
+		it.page()
+	}
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
+}
+
+// page gets the next page. The pages of a server returning an empty token or a token
+// requested before are the last ones: the latter would be listed forever.
+func (it *Iterator) page() {
+	if err := it.ctx.Err(); err != nil {
+		it.fail(err)
+		return
+	}
+	it.seen[it.token] = true
+	protos, next, err := it.kind.list(it.ctx, it.client, it.req.ProjectID, &dc.Paging{PageSize: it.req.PageSize, PageToken: it.token}, it.opts...)
+	if err != nil {
+		it.fail(err)
+		return
+	}
+	for _, p := range protos {
+		it.items = append(it.items, New(it.client, p))
+	}
+	switch {
+	case next == "":
+		it.done = true
+	case it.seen[next]:
+		it.fail(fmt.Errorf("page token %q repeated", next))
+	default:
+		it.token = next
+	}
+}
+
+func (it *Iterator) fail(err error) {
+	it.err = sdkerrors.WithMessagef(err, "list %s operations", it.req.Kind)
+	it.done = true
+}
+
+func (it *Iterator) Value() *Operation {
+	if len(it.items) == 0 {
+		panic("calling Value on empty iterator")
+	}
+	return it.items[0]
+}
+
+// Take returns up to size next operations. The operations listed before a failure are
+// returned with the error.
+func (it *Iterator) Take(size int) ([]*Operation, error) {
+	var result []*Operation
+	for len(result) < size && it.Next() {
+		result = append(result, it.Value())
+	}
+	return result, it.Error()
+}
+
+// TakeAll returns all remaining operations. The operations listed before a failure are
+// returned with the error.
+func (it *Iterator) TakeAll() ([]*Operation, error) {
+	var result []*Operation
+	for it.Next() {
+		result = append(result, it.Value())
+	}
+	return result, it.Error()
+}
+
+// Error returns the failure of the listing, if any.
+func (it *Iterator) Error() error {
+	return it.err
+}
+
+// Close ends the iteration and releases the operations of the page left: Next returns
+// false from then on.
+func (it *Iterator) Close() {
+	it.done, it.items, it.seen = true, nil, nil
+	it.leak.Close()
+}
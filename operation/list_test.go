@@ -0,0 +1,169 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// pagingKafkaClient is a fakeKafkaClient listing scripted pages by page token.
+type pagingKafkaClient struct {
+	fakeKafkaClient
+	pages    map[string]*kafka.ListOperationsResponse
+	err      map[string]error
+	requests []*kafka.ListOperationsRequest
+}
+
+func (f *pagingKafkaClient) List(ctx context.Context, in *kafka.ListOperationsRequest, opts ...grpc.CallOption) (*kafka.ListOperationsResponse, error) {
+	f.requests = append(f.requests, in)
+	token := in.GetPaging().GetPageToken()
+	if err := f.err[token]; err != nil {
+		return nil, err
+	}
+	return f.pages[token], nil
+}
+
+func listPage(next string, ids ...string) *kafka.ListOperationsResponse {
+	resp := &kafka.ListOperationsResponse{NextPage: &doublecloud.NextPage{Token: next}}
+	for _, id := range ids {
+		resp.Operations = append(resp.Operations, &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING})
+	}
+	return resp
+}
+
+func operationIDs(ops []*Operation) []string {
+	var ids []string
+	for _, o := range ops {
+		ids = append(ids, o.Id())
+	}
+	return ids
+}
+
+func TestList_Pages(t *testing.T) {
+	requireKinds(t)
+	client := &pagingKafkaClient{pages: map[string]*kafka.ListOperationsResponse{
+		"":   listPage("p2", "kfo1", "kfo2"),
+		"p2": listPage("p3"),
+		"p3": listPage("", "kfo3"),
+	}}
+	ops, err := List(context.Background(), client, ListRequest{Kind: KindKafka, ProjectID: "p1", PageSize: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kfo1", "kfo2", "kfo3"}, operationIDs(ops), "short and empty pages are not the last ones")
+
+	require.Len(t, client.requests, 3)
+	for i, token := range []string{"", "p2", "p3"} {
+		assert.Equal(t, "p1", client.requests[i].GetProjectId())
+		assert.Equal(t, int64(5), client.requests[i].GetPaging().GetPageSize())
+		assert.Equal(t, token, client.requests[i].GetPaging().GetPageToken())
+	}
+
+	client.fakeKafkaClient.get = func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}
+	require.NoError(t, ops[0].Poll(context.Background()), "the operations are bound to the client")
+	assert.True(t, ops[0].Ok())
+}
+
+func TestList_DefaultPageSize(t *testing.T) {
+	requireKinds(t)
+	client := &pagingKafkaClient{pages: map[string]*kafka.ListOperationsResponse{"": listPage("", "kfo1")}}
+	_, err := List(context.Background(), client, ListRequest{Kind: KindKafka, ProjectID: "p1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(DefaultListPageSize), client.requests[0].GetPaging().GetPageSize())
+}
+
+func TestList_TokenLoop(t *testing.T) {
+	requireKinds(t)
+	for name, pages := range map[string]map[string]*kafka.ListOperationsResponse{
+		"same token": {
+			"":   listPage("p2", "kfo1"),
+			"p2": listPage("p2", "kfo2"),
+		},
+		"earlier token": {
+			"":   listPage("p2", "kfo1"),
+			"p2": listPage("p3"),
+			"p3": listPage("p2", "kfo2"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := &pagingKafkaClient{pages: pages}
+			ops, err := List(context.Background(), client, ListRequest{Kind: KindKafka, ProjectID: "p1"})
+			assert.EqualError(t, err, `list kafka operations: page token "p2" repeated`)
+			assert.Equal(t, []string{"kfo1", "kfo2"}, operationIDs(ops), "the operations listed before the loop are returned")
+		})
+	}
+}
+
+func TestList_Failure(t *testing.T) {
+	requireKinds(t)
+	boom := errors.New("boom")
+	client := &pagingKafkaClient{
+		pages: map[string]*kafka.ListOperationsResponse{"": listPage("p2", "kfo1")},
+		err:   map[string]error{"p2": boom},
+	}
+	it := Iterate(context.Background(), client, ListRequest{Kind: KindKafka, ProjectID: "p1"})
+	require.True(t, it.Next())
+	assert.Equal(t, "kfo1", it.Value().Id())
+	assert.NoError(t, it.Error(), "pages are got as they are iterated")
+	assert.Len(t, client.requests, 1)
+
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Error(), boom)
+	assert.False(t, it.Next())
+	assert.Len(t, client.requests, 2, "failed listings are not retried")
+}
+
+func TestList_ContextDone(t *testing.T) {
+	requireKinds(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := &pagingKafkaClient{pages: map[string]*kafka.ListOperationsResponse{"": listPage("", "kfo1")}}
+	ops, err := List(ctx, client, ListRequest{Kind: KindKafka, ProjectID: "p1"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, ops)
+	assert.Empty(t, client.requests)
+}
+
+func TestList_Invalid(t *testing.T) {
+	requireKinds(t)
+	for name, tc := range map[string]struct {
+		client Client
+		req    ListRequest
+		err    string
+	}{
+		"no project":   {&fakeKafkaClient{}, ListRequest{Kind: KindKafka}, "operation: listing operations requires a project ID"},
+		"unknown kind": {&fakeKafkaClient{}, ListRequest{Kind: "airflow", ProjectID: "p1"}, `operation: unknown operation kind "airflow"`},
+		"transfer":     {&fakeKafkaClient{}, ListRequest{Kind: KindTransfer, ProjectID: "p1"}, "operation: transfer operations can't be listed"},
+		"wrong client": {
+			&fakeKafkaClient{}, ListRequest{Kind: KindClickHouse, ProjectID: "p1"},
+			"operation: listing clickhouse operations requires clickhouse.OperationServiceClient, got *operation.fakeKafkaClient",
+		},
+		"nil client": {nil, ListRequest{Kind: KindKafka, ProjectID: "p1"}, "operation: listing kafka operations requires kafka.OperationServiceClient, got <nil>"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ops, err := List(context.Background(), tc.client, tc.req)
+			assert.EqualError(t, err, tc.err)
+			assert.Empty(t, ops)
+		})
+	}
+}
+
+func TestList_Close(t *testing.T) {
+	requireKinds(t)
+	client := &pagingKafkaClient{pages: map[string]*kafka.ListOperationsResponse{
+		"":   listPage("p2", "kfo1", "kfo2"),
+		"p2": listPage("", "kfo3"),
+	}}
+	it := Iterate(context.Background(), client, ListRequest{Kind: KindKafka, ProjectID: "p1"})
+	require.True(t, it.Next())
+	it.Close()
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Error())
+	assert.Len(t, client.requests, 1, "no pages are listed after Close")
+}
@@ -0,0 +1,107 @@
+package operation
+
+import "sync"
+
+// LogLevel is the level of a line of a Logger.
+type LogLevel int
+
+const (
+	// LogDebug is the level of the polls of the waits.
+	LogDebug LogLevel = iota
+	// LogInfo is the level of the end of the waits and of the other events worth a line, e.g.
+	// a refresh of the credentials.
+	LogInfo
+	// LogWarn is the level of the failed polls.
+	LogWarn
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	}
+	return "unknown"
+}
+
+// Logger logs the waits of the operations, see Operation.WithLogger. kv are alternating keys
+// and values, e.g. "attempt", 2, as for slog.Logger.Log, which an adapter may call.
+type Logger interface {
+	Log(level LogLevel, msg string, kv ...interface{})
+}
+
+// The keys of the correlation fields of the lines of Operation.Logger.
+const (
+	LogKeyOperationID = "operation_id"
+	LogKeyKind        = "operation_kind"
+	LogKeyOrigin      = "operation_origin"
+	LogKeyResource    = "operation_resource"
+)
+
+// WithLogger makes the waits of the operation log their polls, their retries and their end
+// with l, every line carrying the correlation fields of the operation, see Logger. Nothing
+// is logged by default.
+func (o *Operation) WithLogger(l Logger) *Operation {
+	o.logger = nil
+	if l != nil {
+		o.logger = &operationLogger{base: l}
+	}
+	return o
+}
+
+// Logger returns the logger of the operation, see WithLogger, adding the correlation fields
+// of the operation to the lines, e.g. for the callbacks of the waits to log along with them.
+// Its lines are discarded if the operation has no logger.
+func (o *Operation) Logger() Logger {
+	if l := o.waitLogger(); l != nil {
+		return l
+	}
+	return nopLogger{}
+}
+
+// waitLogger returns the logger with the correlation fields of the operation, nil if it has
+// no logger.
+func (o *Operation) waitLogger() Logger {
+	if o.logger == nil {
+		return nil
+	}
+	o.logger.once.Do(func() {
+		fields := []interface{}{LogKeyOperationID, o.Id()}
+		if k := operationKindOf(o.Id()); k != nil {
+			fields = append(fields, LogKeyKind, k.name)
+		}
+		if o.origin.method != "" {
+			fields = append(fields, LogKeyOrigin, o.origin.method)
+		}
+		if o.origin.resource != "" {
+			fields = append(fields, LogKeyResource, TruncateText(o.origin.resource, TextLimit))
+		}
+		o.logger.child = fieldLogger{l: o.logger.base, fields: fields}
+	})
+	return o.logger.child
+}
+
+// operationLogger derives the logger of an operation once, on first use.
+type operationLogger struct {
+	base  Logger
+	once  sync.Once
+	child Logger
+}
+
+// fieldLogger adds fields to the lines of l.
+type fieldLogger struct {
+	l      Logger
+	fields []interface{}
+}
+
+func (f fieldLogger) Log(level LogLevel, msg string, kv ...interface{}) {
+	line := make([]interface{}, 0, len(f.fields)+len(kv))
+	f.l.Log(level, msg, append(append(line, f.fields...), kv...)...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(LogLevel, string, ...interface{}) {}
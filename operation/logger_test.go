@@ -0,0 +1,103 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+type logLine struct {
+	level  LogLevel
+	msg    string
+	fields map[string]interface{}
+}
+
+// lineRecorder is a Logger recording its lines.
+type lineRecorder struct {
+	mu    sync.Mutex
+	lines []logLine
+}
+
+func (r *lineRecorder) Log(level LogLevel, msg string, kv ...interface{}) {
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		fields[kv[i].(string)] = kv[i+1]
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, logLine{level, msg, fields})
+}
+
+func TestOperationLogger_Wait(t *testing.T) {
+	requireKinds(t)
+	id := CLICKHOUSE_OPERATION_PREFIX + "1"
+	poll := scriptedPoll(
+		pollStep{code: codes.Unavailable},
+		pollStep{status: doublecloud.Operation_STATUS_RUNNING},
+		pollStep{status: doublecloud.Operation_STATUS_DONE},
+	)
+	rec := &lineRecorder{}
+	op := New(nil, &Proto{Id: id, Status: doublecloud.Operation_STATUS_PENDING}).WithLogger(rec).
+		WithOrigin("/doublecloud.clickhouse.v1.ClusterService/Create", "cluster c1")
+	op.newTimer = fastTimer
+	onPoll := WithPollCallback(func(o *Operation, attempt int, err error) {
+		o.Logger().Log(LogInfo, "callback", "attempt", attempt)
+	})
+	require.NoError(t, op.Wait(context.Background(), WithPollFunc(poll), onPoll))
+
+	var msgs []string
+	for _, l := range rec.lines {
+		msgs = append(msgs, l.msg)
+		assert.Equal(t, id, l.fields[LogKeyOperationID], l.msg)
+		assert.Equal(t, KindClickHouse, l.fields[LogKeyKind], l.msg)
+		assert.Equal(t, "/doublecloud.clickhouse.v1.ClusterService/Create", l.fields[LogKeyOrigin], l.msg)
+		assert.Equal(t, "cluster c1", l.fields[LogKeyResource], l.msg)
+	}
+	assert.Equal(t, []string{
+		"operation wait started",
+		"callback", "operation poll failed, retrying",
+		"operation polled", "callback",
+		"operation polled", "callback",
+		"operation polled",
+		"operation wait finished",
+	}, msgs)
+	assert.Equal(t, LogWarn, rec.lines[2].level)
+	assert.Equal(t, "Unavailable", rec.lines[3].fields["code"])
+	assert.Equal(t, 3, rec.lines[7].fields["attempt"])
+	assert.Equal(t, "STATUS_DONE", rec.lines[8].fields["status"])
+}
+
+func TestOperationLogger_Failure(t *testing.T) {
+	rec := &lineRecorder{}
+	op := New(nil, &Proto{Id: "op1", Status: doublecloud.Operation_STATUS_PENDING}).WithLogger(rec)
+	op.newTimer = fastTimer
+	err := op.Wait(context.Background(), WithPollFunc(scriptedPoll(pollStep{code: codes.InvalidArgument})))
+	require.ErrorIs(t, err, ErrPoll)
+
+	last := rec.lines[len(rec.lines)-1]
+	assert.Equal(t, "operation wait failed", last.msg)
+	assert.Equal(t, LogInfo, last.level)
+	assert.Equal(t, err, last.fields["error"])
+	assert.Equal(t, 1, last.fields["polls"])
+	_, ok := last.fields[LogKeyKind]
+	assert.False(t, ok, "no kind for unknown IDs")
+}
+
+func TestOperationLogger_None(t *testing.T) {
+	op := New(nil, &Proto{Id: "op1", Status: doublecloud.Operation_STATUS_PENDING})
+	assert.Nil(t, op.waitLogger())
+	assert.NotPanics(t, func() { op.Logger().Log(LogInfo, "discarded") })
+	assert.Nil(t, op.WithLogger(nil).waitLogger())
+
+	rec := &lineRecorder{}
+	op.WithLogger(rec).Logger().Log(LogDebug, "line", "k", "v")
+	require.Len(t, rec.lines, 1)
+	assert.Equal(t, map[string]interface{}{LogKeyOperationID: "op1", "k": "v"}, rec.lines[0].fields)
+	assert.Equal(t, "debug", fmt.Sprint(rec.lines[0].level))
+}
@@ -0,0 +1,90 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultLongPollTimeout bounds a single blocking wait call.
+const DefaultLongPollTimeout = 50 * time.Second
+
+// maxLongPollDeadlines is the number of blocking wait calls in a row that may run out of
+// their timeout before the wait falls back to polling.
+const maxLongPollDeadlines = 3
+
+// LongPollClient is implemented by operation clients offering a blocking wait RPC.
+// WaitOperation returns the operation once it is done, or its current state when
+// the server-side timeout elapses first.
+type LongPollClient interface {
+	WaitOperation(ctx context.Context, operationID string, opts ...grpc.CallOption) (*Proto, error)
+}
+
+// LongPollTimeout replaces DefaultLongPollTimeout for the wait. Non-positive d disables
+// long-polling, so the wait polls even if the client implements LongPollClient.
+func LongPollTimeout(d time.Duration) grpc.CallOption {
+	return &longPollTimeout{timeout: d}
+}
+
+type longPollTimeout struct {
+	grpc.EmptyCallOption
+	timeout time.Duration
+}
+
+// longPoller waits for an operation with blocking LongPollClient calls.
+type longPoller struct {
+	client    LongPollClient
+	timeout   time.Duration
+	deadlines int
+}
+
+// newLongPoller returns nil if the client doesn't support long-polling or it is disabled by opts.
+func newLongPoller(client Client, opts []grpc.CallOption) *longPoller {
+	lp, ok := client.(LongPollClient)
+	if !ok {
+		return nil
+	}
+	timeout := DefaultLongPollTimeout
+	for _, o := range opts {
+		if o, ok := o.(*longPollTimeout); ok {
+			timeout = o.timeout
+		}
+	}
+	if timeout <= 0 {
+		return nil
+	}
+	return &longPoller{client: lp, timeout: timeout}
+}
+
+// wait makes a single blocking call. On success the operation state is updated.
+func (p *longPoller) wait(ctx context.Context, o *Operation, opts ...grpc.CallOption) error {
+	callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	state, err := p.client.WaitOperation(callCtx, o.Id(), opts...)
+	if err != nil {
+		return err
+	}
+	p.deadlines = 0
+	o.state.store(state)
+	return nil
+}
+
+// fallback reports whether the wait should switch to polling after err.
+// retry reports whether err is a timeout of the call to be retried silently.
+func (p *longPoller) fallback(ctx context.Context, err error) (fallback, retry bool) {
+	if status.Code(err) == codes.Unimplemented {
+		return true, false
+	}
+	if ctx.Err() != nil || (status.Code(err) != codes.DeadlineExceeded && !errors.Is(err, context.DeadlineExceeded)) {
+		return false, false
+	}
+	p.deadlines++
+	if p.deadlines >= maxLongPollDeadlines {
+		return true, false
+	}
+	return false, true
+}
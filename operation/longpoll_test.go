@@ -0,0 +1,168 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// longPollKafkaClient is a fakeKafkaClient that also answers blocking waits
+// with a scripted function of the 1-based call number.
+type longPollKafkaClient struct {
+	fakeKafkaClient
+
+	waitMu   sync.Mutex
+	waits    int
+	deadline []time.Duration
+	wait     func(ctx context.Context, n int, id string) (*Proto, error)
+}
+
+func (f *longPollKafkaClient) WaitOperation(ctx context.Context, id string, opts ...grpc.CallOption) (*Proto, error) {
+	f.waitMu.Lock()
+	f.waits++
+	n := f.waits
+	if d, ok := ctx.Deadline(); ok {
+		f.deadline = append(f.deadline, time.Until(d))
+	}
+	f.waitMu.Unlock()
+	return f.wait(ctx, n, id)
+}
+
+func (f *longPollKafkaClient) waitCalls() int {
+	f.waitMu.Lock()
+	defer f.waitMu.Unlock()
+	return f.waits
+}
+
+func TestWait_LongPoll(t *testing.T) {
+	client := &longPollKafkaClient{}
+	client.get = func(n int, id string) (*Proto, error) {
+		t.Fatal("long-polling wait must not poll")
+		return nil, nil
+	}
+	client.wait = func(ctx context.Context, n int, id string) (*Proto, error) {
+		if n < 3 {
+			// The server-side timeout elapsed before the operation was done.
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}
+
+	op := pendingKafkaOp(client)
+	require.NoError(t, op.Wait(context.Background()))
+	assert.True(t, op.Ok())
+	assert.Equal(t, 3, client.waitCalls())
+	for _, d := range client.deadline {
+		assert.LessOrEqual(t, d, DefaultLongPollTimeout)
+	}
+}
+
+func TestWait_LongPollFallback(t *testing.T) {
+	requireKinds(t)
+	t.Run("unimplemented", func(t *testing.T) {
+		client := &longPollKafkaClient{}
+		client.get = func(n int, id string) (*Proto, error) {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		}
+		client.wait = func(ctx context.Context, n int, id string) (*Proto, error) {
+			return nil, status.Error(codes.Unimplemented, "unknown method WaitOperation")
+		}
+
+		op := pendingKafkaOp(client)
+		require.NoError(t, op.Wait(context.Background()))
+		assert.Equal(t, 1, client.waitCalls())
+		assert.Equal(t, 1, client.calls())
+	})
+
+	t.Run("repeated deadline exceeded", func(t *testing.T) {
+		client := &longPollKafkaClient{}
+		client.get = func(n int, id string) (*Proto, error) {
+			status := doublecloud.Operation_STATUS_RUNNING
+			if n > 1 {
+				status = doublecloud.Operation_STATUS_DONE
+			}
+			return &Proto{Id: id, Status: status}, nil
+		}
+		client.wait = func(ctx context.Context, n int, id string) (*Proto, error) {
+			if n == 1 {
+				// A single timeout is retried.
+				return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+			}
+			if n == 2 {
+				return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		op := pendingKafkaOp(client)
+		require.NoError(t, op.Wait(context.Background(), LongPollTimeout(time.Millisecond)))
+		assert.Equal(t, 2+maxLongPollDeadlines, client.waitCalls())
+		assert.Equal(t, 2, client.calls())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := &longPollKafkaClient{}
+		client.get = func(n int, id string) (*Proto, error) {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		}
+		op := pendingKafkaOp(client)
+		require.NoError(t, op.Wait(context.Background(), LongPollTimeout(0)))
+		assert.Equal(t, 0, client.waitCalls())
+		assert.Equal(t, 1, client.calls())
+	})
+}
+
+func TestWait_LongPollErrors(t *testing.T) {
+	t.Run("context cancelled", func(t *testing.T) {
+		client := &longPollKafkaClient{}
+		client.wait = func(ctx context.Context, n int, id string) (*Proto, error) {
+			<-ctx.Done()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		op := pendingKafkaOp(client)
+		err := op.Wait(ctx)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NotErrorIs(t, err, ErrPoll)
+		assert.Equal(t, 1, client.waitCalls(), "the caller's deadline must not be retried")
+		assert.Equal(t, 0, client.calls())
+	})
+
+	t.Run("fatal code", func(t *testing.T) {
+		client := &longPollKafkaClient{}
+		client.wait = func(ctx context.Context, n int, id string) (*Proto, error) {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		}
+
+		op := pendingKafkaOp(client)
+		err := op.Wait(context.Background())
+		var fatal *FatalPollError
+		require.True(t, errors.As(err, &fatal))
+		assert.Equal(t, codes.PermissionDenied, fatal.Code)
+	})
+
+	t.Run("operation error", func(t *testing.T) {
+		client := &longPollKafkaClient{}
+		client.wait = func(ctx context.Context, n int, id string) (*Proto, error) {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE, Error: status.New(codes.Internal, "boom").Proto()}, nil
+		}
+
+		op := pendingKafkaOp(client)
+		err := op.Wait(context.Background())
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Contains(t, err.Error(), "boom")
+	})
+}
@@ -0,0 +1,98 @@
+// Package lro adapts operations to Google long-running operations, google.longrunning.Operation,
+// for tools built on them, such as the cloud.google.com/go/longrunning helpers.
+//
+// Operations have no response and a metadata string map where long-running operations have
+// Any messages, so the mapping is lossless only for the operations of Marshal, see Unmarshal.
+package lro
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// Marshal returns the long-running operation of o:
+//   - Name is the operation ID;
+//   - Done is o.Done(), with the Error of failed operations, or else an empty Response: the
+//     result of the operation is the resource of its ResourceId;
+//   - Metadata is the whole operation, a doublecloud.v1.Operation, keeping its status, times
+//     and metadata map.
+func Marshal(o *operation.Operation) (*longrunningpb.Operation, error) {
+	metadata, err := anypb.New(o.Proto())
+	if err != nil {
+		return nil, fmt.Errorf("%s metadata: %w", o, err)
+	}
+	lro := &longrunningpb.Operation{Name: o.Id(), Metadata: metadata, Done: o.Done()}
+	switch {
+	case o.Failed():
+		lro.Result = &longrunningpb.Operation_Error{Error: o.Proto().GetError()}
+	case o.Done():
+		response, err := anypb.New(&emptypb.Empty{})
+		if err != nil {
+			return nil, fmt.Errorf("%s response: %w", o, err)
+		}
+		lro.Result = &longrunningpb.Operation_Response{Response: response}
+	}
+	return lro, nil
+}
+
+// Unmarshal returns the operation of lro, polled with client. The Name, Done and Error of lro
+// take precedence over its Metadata, which is the rest of the operation if it was made by
+// Marshal. Otherwise the mapping is lossy:
+//   - a google.protobuf.Struct metadata keeps its string fields in the metadata map, other
+//     fields and metadata of other types are dropped;
+//   - operations not done are running, their times are unknown;
+//   - the Response is dropped.
+//
+// Operations not done with the state of a resumed operation, see operation.NewFromID, are
+// resumed again.
+func Unmarshal(client operation.Client, lro *longrunningpb.Operation) (*operation.Operation, error) {
+	state := &dc.Operation{}
+	if lro.GetMetadata().MessageIs(state) {
+		if err := lro.GetMetadata().UnmarshalTo(state); err != nil {
+			return nil, fmt.Errorf("operation %s metadata: %w", lro.GetName(), err)
+		}
+	} else {
+		state.Metadata = stringFields(lro.GetMetadata())
+		state.Status = dc.Operation_STATUS_RUNNING
+	}
+	state.Id = lro.GetName()
+	state.Error = nil
+	if lro.GetDone() {
+		state.Status = dc.Operation_STATUS_DONE
+		state.Error = lro.GetError()
+		return operation.New(client, state), nil
+	}
+	switch state.Status {
+	case dc.Operation_STATUS_PENDING, dc.Operation_STATUS_RUNNING:
+		return operation.New(client, state), nil
+	case dc.Operation_STATUS_DONE, dc.Operation_STATUS_INVALID:
+		// Done by the metadata but not by the LRO: the state is polled again.
+		return operation.NewFromID(client, state.Id), nil
+	default:
+		sdkerrors.UnknownEnum(string(state.Status.Descriptor().FullName()), int32(state.Status))
+		return operation.NewFromID(client, state.Id), nil
+	}
+}
+
+// stringFields returns the string fields of a Struct metadata, nil for other metadata.
+func stringFields(metadata *anypb.Any) map[string]string {
+	s := &structpb.Struct{}
+	if !metadata.MessageIs(s) || metadata.UnmarshalTo(s) != nil {
+		return nil
+	}
+	fields := map[string]string{}
+	for k, v := range s.GetFields() {
+		if v, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			fields[k] = v.StringValue
+		}
+	}
+	return fields
+}
@@ -0,0 +1,128 @@
+package lro
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	dcsdk "github.com/doublecloud/go-sdk"
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+var _ Resolver = (*dcsdk.SDK)(nil)
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	created := timestamppb.New(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	for name, state := range map[string]*dc.Operation{
+		"running": {Id: "cho1", ProjectId: "p1", Description: "Create cluster", CreateTime: created,
+			Status: dc.Operation_STATUS_RUNNING, Metadata: map[string]string{"cluster_id": "chcl1"}, ResourceId: "chcl1"},
+		"pending": {Id: "cho1", Status: dc.Operation_STATUS_PENDING},
+		"done":    {Id: "cho1", Status: dc.Operation_STATUS_DONE, CreateTime: created, FinishTime: created, ResourceId: "chcl1"},
+		"failed": {Id: "cho1", Status: dc.Operation_STATUS_DONE,
+			Error: &rpcstatus.Status{Code: int32(code.Code_RESOURCE_EXHAUSTED), Message: "quota"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			o := operation.New(nil, state)
+			lro, err := Marshal(o)
+			require.NoError(t, err)
+			assert.Equal(t, "cho1", lro.GetName())
+			assert.Equal(t, o.Done(), lro.GetDone())
+			assert.True(t, proto.Equal(state.GetError(), lro.GetError()))
+
+			back, err := Unmarshal(nil, lro)
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(state, back.Proto()), "got %v", back.Proto())
+			assert.Equal(t, o.Done(), back.Done())
+		})
+	}
+}
+
+func TestMarshal_Response(t *testing.T) {
+	lro, err := Marshal(operation.New(nil, &dc.Operation{Id: "cho1", Status: dc.Operation_STATUS_DONE}))
+	require.NoError(t, err)
+	assert.True(t, lro.GetResponse().MessageIs(&emptypb.Empty{}), "done operations have an empty response")
+
+	lro, err = Marshal(operation.New(nil, &dc.Operation{Id: "cho1", Status: dc.Operation_STATUS_RUNNING}))
+	require.NoError(t, err)
+	assert.Nil(t, lro.GetResult())
+}
+
+func TestMarshal_Resumed(t *testing.T) {
+	lro, err := Marshal(operation.NewFromID(nil, "cho1"))
+	require.NoError(t, err)
+	assert.False(t, lro.GetDone())
+
+	back, err := Unmarshal(nil, lro)
+	require.NoError(t, err)
+	assert.False(t, back.Done(), "the state is still unknown")
+	method, _ := back.Origin()
+	assert.Equal(t, operation.ResumedOrigin, method)
+}
+
+func TestUnmarshal_Foreign(t *testing.T) {
+	metadata, err := structpb.NewStruct(map[string]any{"cluster_id": "chcl1", "progress": 50})
+	require.NoError(t, err)
+	foreignMetadata, err := anypb.New(metadata)
+	require.NoError(t, err)
+	response, err := anypb.New(metadata)
+	require.NoError(t, err)
+
+	o, err := Unmarshal(nil, &longrunningpb.Operation{Name: "cho1", Metadata: foreignMetadata})
+	require.NoError(t, err)
+	assert.False(t, o.Done())
+	assert.Equal(t, dc.Operation_STATUS_RUNNING, o.Proto().GetStatus())
+	assert.Equal(t, map[string]string{"cluster_id": "chcl1"}, o.Metadata(), "only the string fields are kept")
+
+	o, err = Unmarshal(nil, &longrunningpb.Operation{Name: "cho1", Done: true, Result: &longrunningpb.Operation_Response{Response: response}})
+	require.NoError(t, err)
+	assert.True(t, o.Ok(), "the response is dropped")
+	assert.Empty(t, o.Metadata())
+
+	o, err = Unmarshal(nil, &longrunningpb.Operation{Name: "cho1", Done: true,
+		Result: &longrunningpb.Operation_Error{Error: &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"}}})
+	require.NoError(t, err)
+	assert.True(t, o.Failed())
+	assert.EqualError(t, o.Error(), "rpc error: code = Internal desc = boom")
+}
+
+func TestUnmarshal_DoneTakesPrecedence(t *testing.T) {
+	lro, err := Marshal(operation.New(nil, &dc.Operation{Id: "cho1", Status: dc.Operation_STATUS_RUNNING}))
+	require.NoError(t, err)
+	lro.Done = true
+	o, err := Unmarshal(nil, lro)
+	require.NoError(t, err)
+	assert.True(t, o.Ok())
+	assert.Equal(t, dc.Operation_STATUS_DONE, o.Proto().GetStatus())
+}
+
+func TestUnmarshal_StatusEnum(t *testing.T) {
+	var unknown []int32
+	defer sdkerrors.SetUnknownEnumHandler(func(kind string, value int32) { unknown = append(unknown, value) })()
+	unmarshal := func(st dc.Operation_Status) *operation.Operation {
+		metadata, err := anypb.New(&dc.Operation{Id: "cho1", Status: st})
+		require.NoError(t, err)
+		o, err := Unmarshal(nil, &longrunningpb.Operation{Name: "cho1", Metadata: metadata})
+		require.NoError(t, err)
+		return o
+	}
+
+	for v, name := range dc.Operation_Status_name {
+		unmarshal(dc.Operation_Status(v))
+		assert.Empty(t, unknown, name)
+	}
+	o := unmarshal(dc.Operation_Status(99))
+	assert.Equal(t, []int32{99}, unknown)
+	assert.False(t, o.Done(), "a newer status is polled again")
+}
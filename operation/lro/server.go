@@ -0,0 +1,112 @@
+package lro
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// Resolver returns the operation of an ID, in an unknown state until it is polled. It is
+// implemented by the SDK, see dcsdk.SDK.ResumeOperation.
+type Resolver interface {
+	ResumeOperation(id string) (*operation.Operation, error)
+}
+
+// Server serves the google.longrunning.Operations interface with the operations of a
+// Resolver, so that tools polling long-running operations can poll them. Operation names
+// are operation IDs. GetOperation and WaitOperation poll the operation, CancelOperation
+// cancels it; ListOperations and DeleteOperation are unimplemented.
+type Server struct {
+	longrunningpb.UnimplementedOperationsServer
+	resolver Resolver
+	opts     []grpc.CallOption
+}
+
+var _ longrunningpb.OperationsServer = (*Server)(nil)
+
+// NewServer returns the server of the operations of r, polled and waited with opts.
+func NewServer(r Resolver, opts ...grpc.CallOption) *Server {
+	return &Server{resolver: r, opts: opts}
+}
+
+func (s *Server) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	o, err := s.resolve(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Poll(ctx, s.opts...); err != nil {
+		return nil, serverError(err)
+	}
+	return marshal(o)
+}
+
+// WaitOperation waits for the operation until it is done or the timeout of the request runs
+// out, and returns its latest state in both cases.
+func (s *Server) WaitOperation(ctx context.Context, req *longrunningpb.WaitOperationRequest) (*longrunningpb.Operation, error) {
+	o, err := s.resolve(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	waitCtx := ctx
+	if req.GetTimeout() != nil {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, req.GetTimeout().AsDuration())
+		defer cancel()
+	}
+	err = o.Wait(waitCtx, s.opts...)
+	var opErr *operation.OperationError
+	switch {
+	case err == nil, errors.As(err, &opErr):
+	case ctx.Err() == nil && waitCtx.Err() != nil:
+		// The timeout of the request ran out.
+	default:
+		return nil, serverError(err)
+	}
+	return marshal(o)
+}
+
+func (s *Server) CancelOperation(ctx context.Context, req *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	o, err := s.resolve(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Cancel(ctx, s.opts...); err != nil {
+		if errors.Is(err, operation.ErrCancelUnsupported) {
+			return nil, status.Errorf(codes.Unimplemented, "%s can't be cancelled", o)
+		}
+		return nil, serverError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) resolve(name string) (*operation.Operation, error) {
+	o, err := s.resolver.ResumeOperation(name)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return o, nil
+}
+
+func marshal(o *operation.Operation) (*longrunningpb.Operation, error) {
+	lro, err := Marshal(o)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return lro, nil
+}
+
+// serverError returns the status error of err: its own if it has one, Unknown otherwise.
+func serverError(err error) error {
+	var s interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &s) {
+		return status.Error(s.GRPCStatus().Code(), err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
@@ -0,0 +1,133 @@
+package lro
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// fakeOperations holds the states of the operations, polled with poll.
+type fakeOperations struct {
+	mu     sync.Mutex
+	states map[string]*dc.Operation
+}
+
+func (f *fakeOperations) ResumeOperation(id string) (*operation.Operation, error) {
+	if !strings.HasPrefix(id, operation.CLICKHOUSE_OPERATION_PREFIX) {
+		return nil, fmt.Errorf("unknown operation type of %q", id)
+	}
+	return operation.NewFromID(f, id), nil
+}
+
+func (f *fakeOperations) poll(ctx context.Context, id string) (*operation.Proto, time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, ok := f.states[id]
+	if !ok {
+		return nil, 0, status.Errorf(codes.NotFound, "operation %s not found", id)
+	}
+	return state, 0, nil
+}
+
+func (f *fakeOperations) CancelOperation(ctx context.Context, id string, opts ...grpc.CallOption) (*operation.Proto, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[id] = &dc.Operation{Id: id, Status: dc.Operation_STATUS_DONE,
+		Error: &rpcstatus.Status{Code: int32(code.Code_CANCELLED), Message: "cancelled"}}
+	return f.states[id], nil
+}
+
+func newTestServer(t *testing.T, f *fakeOperations) longrunningpb.OperationsClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	longrunningpb.RegisterOperationsServer(srv, NewServer(f, operation.WithPollFunc(f.poll)))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return longrunningpb.NewOperationsClient(conn)
+}
+
+func TestServer_GetOperation(t *testing.T) {
+	f := &fakeOperations{states: map[string]*dc.Operation{
+		"cho1": {Id: "cho1", Status: dc.Operation_STATUS_RUNNING, Metadata: map[string]string{"cluster_id": "chcl1"}},
+		"cho2": {Id: "cho2", Status: dc.Operation_STATUS_DONE,
+			Error: &rpcstatus.Status{Code: int32(code.Code_RESOURCE_EXHAUSTED), Message: "quota"}},
+	}}
+	client := newTestServer(t, f)
+	ctx := context.Background()
+
+	lro, err := client.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: "cho1"})
+	require.NoError(t, err)
+	assert.False(t, lro.GetDone())
+	o, err := Unmarshal(nil, lro)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"cluster_id": "chcl1"}, o.Metadata())
+
+	lro, err = client.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: "cho2"})
+	require.NoError(t, err)
+	assert.True(t, lro.GetDone())
+	assert.Equal(t, int32(code.Code_RESOURCE_EXHAUSTED), lro.GetError().GetCode())
+
+	_, err = client.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: "cho3"})
+	assert.Equal(t, codes.NotFound, status.Code(err), "the poll error is passed on")
+	_, err = client.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: "xyz"})
+	assert.Equal(t, codes.NotFound, status.Code(err), "unknown operation types aren't found")
+}
+
+func TestServer_WaitOperation(t *testing.T) {
+	f := &fakeOperations{states: map[string]*dc.Operation{
+		"cho1": {Id: "cho1", Status: dc.Operation_STATUS_RUNNING},
+		"cho2": {Id: "cho2", Status: dc.Operation_STATUS_DONE},
+	}}
+	client := newTestServer(t, f)
+	ctx := context.Background()
+
+	lro, err := client.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: "cho2"})
+	require.NoError(t, err)
+	assert.True(t, lro.GetDone())
+
+	lro, err = client.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: "cho1", Timeout: durationpb.New(50 * time.Millisecond)})
+	require.NoError(t, err, "the latest state is returned once the timeout runs out")
+	assert.False(t, lro.GetDone())
+	assert.Equal(t, "cho1", lro.GetName())
+}
+
+func TestServer_CancelOperation(t *testing.T) {
+	f := &fakeOperations{states: map[string]*dc.Operation{"cho1": {Id: "cho1", Status: dc.Operation_STATUS_RUNNING}}}
+	client := newTestServer(t, f)
+	ctx := context.Background()
+
+	_, err := client.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: "cho1"})
+	require.NoError(t, err)
+	lro, err := client.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: "cho1"})
+	require.NoError(t, err)
+	assert.True(t, lro.GetDone())
+	assert.Equal(t, int32(code.Code_CANCELLED), lro.GetError().GetCode())
+
+	_, err = client.ListOperations(ctx, &longrunningpb.ListOperationsRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
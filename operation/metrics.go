@@ -0,0 +1,188 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Metrics is notified of the polls and waits of operations, e.g. to export them, see
+// SetMetrics and WithMetrics. Every poll is reported once, including the polls of WaitAll
+// and WaitAny, the failed ones and the long polls; every wait of a single operation, such
+// as Wait, WaitInterval and ResourceIdWait, is reported once when it returns, whatever
+// the outcome. The durations are measured with the clock of the wait, see WithClock.
+// The methods may be called concurrently.
+type Metrics interface {
+	// PollStarted is called before a poll of the operation.
+	PollStarted(o *Operation)
+	// PollFinished is called after the poll with its error and the time it took.
+	PollFinished(o *Operation, err error, d time.Duration)
+	// WaitFinished is called when a wait of the operation returns with its error, the number
+	// of polls it made and the time it took.
+	WaitFinished(o *Operation, err error, polls int, d time.Duration)
+}
+
+// NopMetrics is the Metrics of SetMetrics(nil), ignoring everything.
+type NopMetrics struct{}
+
+func (NopMetrics) PollStarted(*Operation)                             {}
+func (NopMetrics) PollFinished(*Operation, error, time.Duration)      {}
+func (NopMetrics) WaitFinished(*Operation, error, int, time.Duration) {}
+
+// metricsHolder keeps the type of the value of globalMetrics the same.
+type metricsHolder struct{ m Metrics }
+
+var globalMetrics atomic.Value
+
+func init() {
+	globalMetrics.Store(metricsHolder{NopMetrics{}})
+}
+
+// SetMetrics sets the Metrics of the waits without WithMetrics, NopMetrics if m is nil.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = NopMetrics{}
+	}
+	globalMetrics.Store(metricsHolder{m})
+}
+
+// WithMetrics makes the wait report to m instead of the Metrics of SetMetrics.
+func WithMetrics(m Metrics) grpc.CallOption {
+	return &metricsOption{m: m}
+}
+
+type metricsOption struct {
+	grpc.EmptyCallOption
+	m Metrics
+}
+
+// metricsOf returns the metrics of a wait made with opts, recovering their panics.
+func metricsOf(opts []grpc.CallOption) Metrics {
+	m := globalMetrics.Load().(metricsHolder).m
+	for _, opt := range opts {
+		if opt, ok := opt.(*metricsOption); ok && opt.m != nil {
+			m = opt.m
+		}
+	}
+	if _, ok := m.(NopMetrics); ok {
+		return m
+	}
+	return safeMetrics{m}
+}
+
+type safeMetrics struct{ m Metrics }
+
+func (s safeMetrics) PollStarted(o *Operation) {
+	_ = SafeCall("metrics", func() error { s.m.PollStarted(o); return nil })
+}
+
+func (s safeMetrics) PollFinished(o *Operation, err error, d time.Duration) {
+	_ = SafeCall("metrics", func() error { s.m.PollFinished(o, err, d); return nil })
+}
+
+func (s safeMetrics) WaitFinished(o *Operation, err error, polls int, d time.Duration) {
+	_ = SafeCall("metrics", func() error { s.m.WaitFinished(o, err, polls, d); return nil })
+}
+
+// Names of the metrics of NewLabeledMetrics.
+const (
+	// MetricPolls counts the polls by prefix and code.
+	MetricPolls = "operation_polls_total"
+	// MetricPollDuration is the histogram of the durations of the polls by prefix, in seconds.
+	MetricPollDuration = "operation_poll_duration_seconds"
+	// MetricWaits counts the waits by prefix and outcome.
+	MetricWaits = "operation_waits_total"
+	// MetricWaitDuration is the histogram of the durations of the waits by prefix, in seconds.
+	MetricWaitDuration = "operation_wait_duration_seconds"
+	// MetricWaitPolls is the histogram of the number of polls of the waits by prefix.
+	MetricWaitPolls = "operation_wait_polls"
+)
+
+// Outcomes of the waits of MetricWaits.
+const (
+	OutcomeSucceeded = "succeeded"
+	// OutcomeFailed is the outcome of the waits of failed operations.
+	OutcomeFailed = "failed"
+	// OutcomeCanceled is the outcome of the waits whose context is done.
+	OutcomeCanceled = "canceled"
+	// OutcomeError is the outcome of the waits that couldn't poll the operation.
+	OutcomeError = "error"
+)
+
+// MetricLabels are the labels of the metrics of NewLabeledMetrics.
+type MetricLabels struct {
+	// Prefix is the prefix of the operation ID, e.g. "cho" or "dte", "network" for the network
+	// operations and the kind name for other kinds, "unknown" if the kind is unknown.
+	Prefix string
+	// Code is the code of the poll of MetricPolls, e.g. "OK" or "NotFound".
+	Code string
+	// Outcome is the outcome of the wait of MetricWaits, e.g. OutcomeSucceeded.
+	Outcome string
+}
+
+// MetricsSink records the metrics of NewLabeledMetrics, e.g. an adapter to the counters and
+// histograms of prometheus or OpenTelemetry. It must be safe for concurrent use.
+type MetricsSink interface {
+	// Count adds one to the counter of the name with the labels.
+	Count(name string, labels MetricLabels)
+	// Observe records the value in the histogram of the name with the labels.
+	Observe(name string, labels MetricLabels, value float64)
+}
+
+// NewLabeledMetrics returns the Metrics recording to sink the counters and the histograms
+// of the Metric constants, labeled by the prefix of the operation IDs.
+func NewLabeledMetrics(sink MetricsSink) Metrics {
+	return labeledMetrics{sink: sink}
+}
+
+type labeledMetrics struct{ sink MetricsSink }
+
+func (labeledMetrics) PollStarted(*Operation) {}
+
+func (m labeledMetrics) PollFinished(o *Operation, err error, d time.Duration) {
+	prefix := metricPrefix(o.Id())
+	code := "OK"
+	if err != nil {
+		code = pollErrorCode(err).String()
+	}
+	m.sink.Count(MetricPolls, MetricLabels{Prefix: prefix, Code: code})
+	m.sink.Observe(MetricPollDuration, MetricLabels{Prefix: prefix}, d.Seconds())
+}
+
+func (m labeledMetrics) WaitFinished(o *Operation, err error, polls int, d time.Duration) {
+	prefix := metricPrefix(o.Id())
+	m.sink.Count(MetricWaits, MetricLabels{Prefix: prefix, Outcome: waitOutcome(err)})
+	m.sink.Observe(MetricWaitDuration, MetricLabels{Prefix: prefix}, d.Seconds())
+	m.sink.Observe(MetricWaitPolls, MetricLabels{Prefix: prefix}, float64(polls))
+}
+
+func waitOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSucceeded
+	case errors.Is(err, ErrOperationFailed):
+		return OutcomeFailed
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCanceled
+	}
+	return OutcomeError
+}
+
+// metricPrefix returns the MetricLabels.Prefix of the operation ID.
+func metricPrefix(id string) string {
+	k := operationKindOf(id)
+	switch {
+	case k == nil:
+		return "unknown"
+	case k.name == KindNetwork:
+		return "network"
+	case k.name == KindClickHouse, k.name == KindKafka, k.name == KindTransfer:
+		if len(id) >= 3 {
+			return id[:3]
+		}
+	}
+	return k.name
+}
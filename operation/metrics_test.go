@@ -0,0 +1,224 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingMetrics records the calls of its methods.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	started  int
+	finished []error
+	waits    []recordedWait
+}
+
+type recordedWait struct {
+	err   error
+	polls int
+	d     time.Duration
+}
+
+func (m *recordingMetrics) PollStarted(*Operation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started++
+}
+
+func (m *recordingMetrics) PollFinished(_ *Operation, err error, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished = append(m.finished, err)
+}
+
+func (m *recordingMetrics) WaitFinished(_ *Operation, err error, polls int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits = append(m.waits, recordedWait{err: err, polls: polls, d: d})
+}
+
+func TestWait_Metrics(t *testing.T) {
+	requireKinds(t)
+	c := newFakeClock(t)
+	m := &recordingMetrics{}
+	op := New(&fakeKafkaClient{get: runningFor(codes.NotFound)}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(c), WithMetrics(m)))
+
+	assert.Equal(t, 4, m.started)
+	require.Len(t, m.finished, 4)
+	assert.Equal(t, codes.NotFound, status.Code(m.finished[0]), "the retried poll is reported")
+	assert.Equal(t, []error{nil, nil, nil}, m.finished[1:])
+	require.Len(t, m.waits, 1)
+	assert.NoError(t, m.waits[0].err)
+	assert.Equal(t, 4, m.waits[0].polls)
+	assert.Equal(t, 3*DefaultPollInterval, m.waits[0].d, "the wait is timed with its clock")
+}
+
+func TestWait_MetricsErrors(t *testing.T) {
+	requireKinds(t)
+	t.Run("poll error", func(t *testing.T) {
+		m := &recordingMetrics{}
+		op := New(&fakeKafkaClient{get: runningFor(codes.PermissionDenied)}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		err := op.Wait(context.Background(), WithClock(newFakeClock(t)), WithMetrics(m))
+		require.Error(t, err)
+		assert.Equal(t, 1, m.started)
+		assert.Len(t, m.finished, 1)
+		require.Len(t, m.waits, 1)
+		assert.Equal(t, err, m.waits[0].err)
+		assert.Equal(t, 1, m.waits[0].polls)
+	})
+	t.Run("canceled", func(t *testing.T) {
+		m := &recordingMetrics{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		op := New(&fakeKafkaClient{get: runningFor()}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		err := op.Wait(ctx, WithClock(newFakeClock(t)), WithMetrics(m))
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, m.started)
+		assert.Len(t, m.finished, 1)
+		require.Len(t, m.waits, 1)
+		assert.ErrorIs(t, m.waits[0].err, context.Canceled)
+	})
+	t.Run("done", func(t *testing.T) {
+		m := &recordingMetrics{}
+		op := New(&fakeKafkaClient{get: runningFor()}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_DONE})
+		require.NoError(t, op.Wait(context.Background(), WithClock(newFakeClock(t)), WithMetrics(m)))
+		assert.Zero(t, m.started)
+		assert.Equal(t, []recordedWait{{}}, m.waits, "the wait without polls is reported")
+	})
+}
+
+func TestSetMetrics(t *testing.T) {
+	requireKinds(t)
+	global, own := &recordingMetrics{}, &recordingMetrics{}
+	SetMetrics(global)
+	t.Cleanup(func() { SetMetrics(nil) })
+
+	op := New(&fakeKafkaClient{get: runningFor()}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(newFakeClock(t))))
+	assert.Equal(t, 3, global.started)
+
+	op = New(&fakeKafkaClient{get: runningFor()}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(newFakeClock(t)), WithMetrics(own)))
+	assert.Equal(t, 3, global.started, "WithMetrics replaces the global metrics")
+	assert.Equal(t, 3, own.started)
+
+	SetMetrics(nil)
+	assert.Equal(t, NopMetrics{}, metricsOf(nil))
+}
+
+type panickingMetrics struct{ NopMetrics }
+
+func (panickingMetrics) PollFinished(*Operation, error, time.Duration) { panic("metrics bug") }
+
+func TestWait_MetricsPanic(t *testing.T) {
+	requireKinds(t)
+	op := New(&fakeKafkaClient{get: runningFor()}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(newFakeClock(t)), WithMetrics(panickingMetrics{})))
+	assert.True(t, op.Ok())
+}
+
+func TestWaitAll_Metrics(t *testing.T) {
+	requireKinds(t)
+	m := &recordingMetrics{}
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+	ops := []*Operation{
+		New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}),
+		New(client, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING}),
+	}
+	require.NoError(t, WaitAll(context.Background(), ops, WithMetrics(m)))
+	assert.Equal(t, 2, m.started)
+	assert.Len(t, m.finished, 2)
+	assert.Empty(t, m.waits, "batch waits report their polls only")
+}
+
+func TestResourceIdWait_Metrics(t *testing.T) {
+	requireKinds(t)
+	m := &recordingMetrics{}
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, ResourceId: "kf1", Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	id, err := op.ResourceIdWait(context.Background(), time.Minute, WithClock(newFakeClock(t)), WithMetrics(m))
+	require.NoError(t, err)
+	assert.Equal(t, "kf1", id)
+	assert.Equal(t, 1, m.started)
+	assert.Len(t, m.finished, 1)
+	require.Len(t, m.waits, 1)
+	assert.Equal(t, 1, m.waits[0].polls)
+}
+
+// recordingSink is a MetricsSink recording the counts and the observations by metric.
+type recordingSink struct {
+	mu       sync.Mutex
+	counts   map[string][]MetricLabels
+	observed map[string][]float64
+}
+
+func (s *recordingSink) Count(name string, labels MetricLabels) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[name] = append(s.counts[name], labels)
+}
+
+func (s *recordingSink) Observe(name string, labels MetricLabels, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observed[name] = append(s.observed[name], value)
+}
+
+func TestNewLabeledMetrics(t *testing.T) {
+	requireKinds(t)
+	sink := &recordingSink{counts: map[string][]MetricLabels{}, observed: map[string][]float64{}}
+	op := New(&fakeKafkaClient{get: runningFor(codes.Unavailable)}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(newFakeClock(t)), WithMetrics(NewLabeledMetrics(sink))))
+
+	assert.Equal(t, []MetricLabels{
+		{Prefix: "kfo", Code: "Unavailable"},
+		{Prefix: "kfo", Code: "OK"},
+		{Prefix: "kfo", Code: "OK"},
+		{Prefix: "kfo", Code: "OK"},
+	}, sink.counts[MetricPolls])
+	assert.Len(t, sink.observed[MetricPollDuration], 4)
+	assert.Equal(t, []MetricLabels{{Prefix: "kfo", Outcome: OutcomeSucceeded}}, sink.counts[MetricWaits])
+	assert.Equal(t, []float64{3}, sink.observed[MetricWaitDuration])
+	assert.Equal(t, []float64{4}, sink.observed[MetricWaitPolls])
+}
+
+func TestMetricPrefix(t *testing.T) {
+	requireKinds(t)
+	for id, prefix := range map[string]string{
+		"cho1":                                 "cho",
+		"kfo1":                                 "kfo",
+		"dtj1":                                 "dtj",
+		"dte1":                                 "dte",
+		"0b6a5f0e-2b8f-4c8e-9d0a-3c1f4f7e9a11": "network",
+		"xyz1":                                 "unknown",
+	} {
+		assert.Equal(t, prefix, metricPrefix(id), id)
+	}
+}
+
+func TestWaitOutcome(t *testing.T) {
+	for err, outcome := range map[error]string{
+		nil:                             OutcomeSucceeded,
+		&OperationError{}:               OutcomeFailed,
+		context.Canceled:                OutcomeCanceled,
+		context.DeadlineExceeded:        OutcomeCanceled,
+		errors.New("poll failed"):       OutcomeError,
+		status.Error(codes.Aborted, ""): OutcomeError,
+	} {
+		assert.Equal(t, outcome, waitOutcome(err), "%v", err)
+	}
+}
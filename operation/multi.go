@@ -0,0 +1,121 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultReprobeInterval is the time after which the polls of an operation of NewMulti that
+// failed over try the primary client again, unless the wait sets ReprobeInterval.
+const DefaultReprobeInterval = time.Minute
+
+// NewMulti is New polling the operation with the first of the clients, the primary one,
+// failing over to the next ones in order when a poll fails with a connection-level error:
+// Unavailable, or DeadlineExceeded while the context of the poll isn't done, or when the
+// client doesn't implement the operation service client of the operation, e.g. in a set of
+// clients of several services. Application errors, such as NotFound or PermissionDenied,
+// are returned as is.
+//
+// The client polling successfully is kept for the next polls, and is the one of Client,
+// long polls and Cancel; after a failover, the primary client is tried first again every
+// DefaultReprobeInterval, see ReprobeInterval. NewMulti with one client is New. It panics
+// without clients.
+func NewMulti(clients []Client, proto *Proto) *Operation {
+	if len(clients) == 0 {
+		panic("no operation clients")
+	}
+	o := New(clients[0], proto)
+	if len(clients) > 1 {
+		o.clients = append([]Client(nil), clients...)
+	}
+	return o
+}
+
+// ReprobeInterval replaces DefaultReprobeInterval for the polls of operations of NewMulti.
+// Non-positive d makes every poll try the primary client first.
+func ReprobeInterval(d time.Duration) grpc.CallOption {
+	return &reprobeInterval{interval: d}
+}
+
+type reprobeInterval struct {
+	grpc.EmptyCallOption
+	interval time.Duration
+}
+
+func reprobeIntervalOf(opts []grpc.CallOption) time.Duration {
+	interval := DefaultReprobeInterval
+	for _, opt := range opts {
+		if opt, ok := opt.(*reprobeInterval); ok {
+			interval = opt.interval
+		}
+	}
+	return interval
+}
+
+// failover holds the clients of NewMulti. The active client is guarded by the mutex of the
+// state of the operation.
+type failover struct {
+	clients []Client
+	// active is the index of the client polling, since the time of the failover to it.
+	active int
+	since  time.Time
+}
+
+// getWith gets the state of the operation with get and the client polling, failing over to
+// the other clients of NewMulti on connection-level errors.
+func (o *Operation) getWith(ctx context.Context, opts []grpc.CallOption, get func(client Client) (*Proto, error)) (*Proto, error) {
+	if len(o.clients) < 2 {
+		return get(o.Client())
+	}
+	now := clockOf(o, opts).now()
+	o.state.mu.RLock()
+	active, since := o.active, o.since
+	o.state.mu.RUnlock()
+	first := active
+	if first != 0 && now.Sub(since) >= reprobeIntervalOf(opts) {
+		first = 0
+	}
+	var state *Proto
+	var err error
+	for i := range o.clients {
+		n := (first + i) % len(o.clients)
+		state, err = get(o.clients[n])
+		if err == nil || !failoverError(ctx, err) {
+			if n != active {
+				o.state.mu.Lock()
+				o.active, o.since, o.client = n, now, o.clients[n]
+				o.state.mu.Unlock()
+			}
+			return state, err
+		}
+		if n == 0 && active != 0 {
+			// The primary client is still down: try it again after another interval.
+			o.state.mu.Lock()
+			o.since = now
+			o.state.mu.Unlock()
+		}
+	}
+	return state, err
+}
+
+// failoverError reports whether the poll error is the failure of the connection of the
+// client rather than an answer of the service, or *NoOperationClientError of a client
+// without the capability of the operation, see capabilities.
+func failoverError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrNoOperationClient) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// endpointClient is a fakeKafkaClient of an endpoint failing its polls with code, if set.
+func endpointClient(code *codes.Code) *fakeKafkaClient {
+	return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		if *code != codes.OK {
+			return nil, status.Error(*code, "endpoint failed")
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+}
+
+func TestNewMulti_Failover(t *testing.T) {
+	requireKinds(t)
+	c := newFakeClock(t)
+	primaryCode, secondaryCode := codes.OK, codes.OK
+	primary, secondary := endpointClient(&primaryCode), endpointClient(&secondaryCode)
+	op := NewMulti([]Client{primary, secondary}, &Proto{Id: "kfo1"})
+	ctx := context.Background()
+
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	assert.Equal(t, 1, primary.calls())
+	assert.Zero(t, secondary.calls())
+
+	primaryCode = codes.Unavailable
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	assert.Equal(t, 2, primary.calls())
+	assert.Equal(t, 1, secondary.calls())
+	assert.Same(t, secondary, op.Client())
+
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	assert.Equal(t, 2, primary.calls(), "the healthy client is kept")
+	assert.Equal(t, 2, secondary.calls())
+
+	c.t = c.t.Add(DefaultReprobeInterval)
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	assert.Equal(t, 3, primary.calls(), "the primary client is probed again")
+	assert.Equal(t, 3, secondary.calls())
+
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	assert.Equal(t, 3, primary.calls(), "the failed probe restarts the interval")
+
+	primaryCode = codes.OK
+	c.t = c.t.Add(DefaultReprobeInterval)
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	require.NoError(t, op.Poll(ctx, WithClock(c)))
+	assert.Equal(t, 5, primary.calls())
+	assert.Equal(t, 4, secondary.calls())
+	assert.Same(t, primary, op.Client(), "the primary client is back")
+}
+
+func TestNewMulti_ApplicationErrors(t *testing.T) {
+	requireKinds(t)
+	for _, code := range []codes.Code{codes.NotFound, codes.PermissionDenied, codes.Internal} {
+		t.Run(code.String(), func(t *testing.T) {
+			primaryCode, secondaryCode := code, codes.OK
+			primary, secondary := endpointClient(&primaryCode), endpointClient(&secondaryCode)
+			err := NewMulti([]Client{primary, secondary}, &Proto{Id: "kfo1"}).Poll(context.Background())
+			assert.Equal(t, code, status.Code(err))
+			assert.Zero(t, secondary.calls(), "application errors don't fail over")
+		})
+	}
+}
+
+func TestNewMulti_MissingCapability(t *testing.T) {
+	requireKinds(t)
+	secondaryCode := codes.OK
+	secondary := endpointClient(&secondaryCode)
+	op := NewMulti([]Client{journalOnly{}, secondary}, &Proto{Id: "kfo1"})
+	require.NoError(t, op.Poll(context.Background()), "the kafka operation fails over to the kafka client")
+	assert.Equal(t, 1, secondary.calls())
+	assert.Same(t, secondary, op.Client())
+
+	op = NewMulti([]Client{journalOnly{}, struct{}{}}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_RUNNING})
+	assert.ErrorIs(t, op.Wait(context.Background()), ErrNoOperationClient, "no client has the capability")
+}
+
+func TestNewMulti_AllUnavailable(t *testing.T) {
+	requireKinds(t)
+	primaryCode, secondaryCode := codes.DeadlineExceeded, codes.Unavailable
+	primary, secondary := endpointClient(&primaryCode), endpointClient(&secondaryCode)
+	op := NewMulti([]Client{primary, secondary}, &Proto{Id: "kfo1"})
+	err := op.Poll(context.Background())
+	assert.Equal(t, codes.Unavailable, status.Code(err), "the error of the last client is returned")
+	assert.Equal(t, 1, primary.calls())
+	assert.Equal(t, 1, secondary.calls())
+	assert.Same(t, primary, op.Client())
+}
+
+func TestNewMulti_ContextDone(t *testing.T) {
+	requireKinds(t)
+	primaryCode, secondaryCode := codes.OK, codes.OK
+	primary, secondary := endpointClient(&primaryCode), endpointClient(&secondaryCode)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewMulti([]Client{primary, secondary}, &Proto{Id: "kfo1"}).Poll(ctx)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Zero(t, secondary.calls(), "polls of a done context don't fail over")
+}
+
+func TestNewMulti_Wait(t *testing.T) {
+	requireKinds(t)
+	c := newFakeClock(t)
+	primaryCode := codes.Unavailable
+	primary := endpointClient(&primaryCode)
+	secondary := &fakeKafkaClient{get: runningFor()}
+	op := NewMulti([]Client{primary, secondary}, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(c), ReprobeInterval(time.Hour)))
+	assert.True(t, op.Ok())
+	assert.Equal(t, 1, primary.calls(), "the wait keeps polling the healthy client")
+}
+
+func TestNewMulti_Single(t *testing.T) {
+	requireKinds(t)
+	code := codes.Unavailable
+	client := endpointClient(&code)
+	op := NewMulti([]Client{client}, &Proto{Id: "kfo1"})
+	assert.Equal(t, codes.Unavailable, status.Code(op.Poll(context.Background())))
+	assert.Same(t, client, op.Client())
+	assert.Panics(t, func() { NewMulti(nil, &Proto{Id: "kfo1"}) })
+}
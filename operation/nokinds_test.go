@@ -0,0 +1,10 @@
+//go:build operation_nokinds
+
+package operation
+
+import "testing"
+
+func requireKinds(t *testing.T) {
+	t.Helper()
+	t.Skip("the kinds of the DoubleCloud services are left out by the operation_nokinds tag")
+}
@@ -0,0 +1,68 @@
+package operation
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WaitObserver is notified of the waits made with a context carrying it, e.g. to account
+// the time spent waiting, see ContextWithWaitObserver.
+type WaitObserver interface {
+	// ObserveWait is called when a wait of the operation returns, with the wall time it took.
+	ObserveWait(o *Operation, elapsed time.Duration, err error)
+}
+
+type waitObserverKey struct{}
+
+// ContextWithWaitObserver returns a context whose waits are reported to obs, replacing
+// the observer of ctx, if any.
+func ContextWithWaitObserver(ctx context.Context, obs WaitObserver) context.Context {
+	return context.WithValue(ctx, waitObserverKey{}, obs)
+}
+
+func observeWait(ctx context.Context, o *Operation, started time.Time, err error) {
+	obs, ok := ctx.Value(waitObserverKey{}).(WaitObserver)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(started)
+	_ = SafeCall("wait observer", func() error {
+		obs.ObserveWait(o, elapsed, err)
+		return nil
+	})
+}
+
+// PollCallback is called by waits after every poll with the 1-based number of the poll and
+// its error, nil if the poll succeeded, see WithPollCallback.
+type PollCallback func(o *Operation, attempt int, err error)
+
+// WithPollCallback makes waits call f after every poll, including the failed polls that are
+// retried, e.g. to show the progress of a wait or log the NotFound retries. f is called from
+// the wait loop, so slow callbacks delay the next poll. Long polls that run out of their
+// timeout and are made again are not reported.
+func WithPollCallback(f PollCallback) grpc.CallOption {
+	return &pollCallback{f: f}
+}
+
+type pollCallback struct {
+	grpc.EmptyCallOption
+	f PollCallback
+}
+
+// pollCallbackOf returns the callback of opts, a no-op if there is none.
+func pollCallbackOf(opts []grpc.CallOption) PollCallback {
+	f := PollCallback(func(*Operation, int, error) {})
+	for _, o := range opts {
+		if o, ok := o.(*pollCallback); ok && o.f != nil {
+			f = o.f
+		}
+	}
+	return func(op *Operation, attempt int, err error) {
+		_ = SafeCall("poll callback", func() error {
+			f(op, attempt, err)
+			return nil
+		})
+	}
+}
@@ -2,25 +2,20 @@ package operation
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/emptypb"
 
-	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
-	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
-	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
-	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
-	"github.com/doublecloud/go-genproto/doublecloud/v1"
 	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/doublecloud/go-sdk/pkg/retry"
 	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
 )
 
@@ -44,7 +39,41 @@ func New(client Client, proto *Proto) *Operation {
 	if proto == nil {
 		panic("nil operation")
 	}
-	return &Operation{proto: proto, client: client, newTimer: defaultTimer}
+	return &Operation{state: newOpState(proto, false), client: client, newTimer: defaultTimer}
+}
+
+// ErrNilOperation is returned by NewChecked for a nil proto.
+var ErrNilOperation = errors.New("operation: nil operation")
+
+// NewChecked is New returning an error instead of panicking for a nil proto, and checking
+// the client up front rather than at the first poll: it returns *UnknownOperationTypeError
+// if the operation ID matches no registered kind, and *NoOperationClientError if the client
+// implements neither the operation service client of the kind nor LongPollClient.
+func NewChecked(client Client, proto *Proto) (*Operation, error) {
+	if proto == nil {
+		return nil, ErrNilOperation
+	}
+	o := New(client, proto)
+	if kind := operationKindOf(o.Id()); kind == nil {
+		return nil, newUnknownOperationTypeError(o)
+	}
+	if err := o.clientError(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ResumedOrigin is the origin method of the operations of NewFromID.
+const ResumedOrigin = "resumed"
+
+// NewFromID returns the operation with the ID only, e.g. one persisted by a process that
+// restarted mid-wait: its state is filled in by the first poll, so waits pick it up where
+// the previous process left it. Until then it isn't done, and its other getters return zero
+// values.
+func NewFromID(client Client, id string) *Operation {
+	o := New(client, &Proto{Id: id})
+	o.state.resumed = true
+	return o.WithOrigin(ResumedOrigin, "")
 }
 
 func defaultTimer(d time.Duration) (func() <-chan time.Time, func() bool) {
@@ -55,29 +84,110 @@ func defaultTimer(d time.Duration) (func() <-chan time.Time, func() bool) {
 }
 
 type Operation struct {
-	proto    *Proto
+	state    *opState
 	client   Client
 	newTimer func(time.Duration) (func() <-chan time.Time, func() bool)
+	origin   origin
+	refresh  CredentialsRefresher
+	// rotation is the source of WithCredentialsRotation.
+	rotation CredentialsRotationFunc
+	skew     ClockSkewFunc
+	// synthetic is set for the operations of NewCompleted and NewFailedSynthetic.
+	synthetic bool
+	// jsonEncoding are the options of WithJSONEncoding.
+	jsonEncoding protojson.MarshalOptions
+	// consoleURL is the builder of ConsoleURL set with WithConsoleURL.
+	consoleURL ConsoleURLFunc
+	// payloadLimit is the limit of WithPayloadLimit.
+	payloadLimit int
+	// pollPolicy is the policy of WithPollPolicy.
+	pollPolicy PollPolicy
+	// journal is the journal of WithWaitJournal.
+	journal *WaitJournal
+	// logger is nil without WithLogger.
+	logger *operationLogger
+	// sampling is the sampling of WithTelemetrySampling, nil if all waits are sampled.
+	sampling *TelemetrySampling
+	// failover holds the clients of NewMulti.
+	failover
 }
 
-func (o *Operation) Proto() *Proto  { return o.proto }
-func (o *Operation) Client() Client { return o.client }
+// origin describes the SDK call that started the operation.
+type origin struct {
+	method   string
+	resource string
+}
+
+// clone returns a copy of the operation with its own copy of the state, keeping all the
+// settings of the With methods.
+func (o *Operation) clone() *Operation {
+	c := *o
+	state, resumed := o.state.load()
+	c.state = newOpState(proto.Clone(state).(*Proto), resumed)
+	c.state.suggested = o.state.suggestedInterval()
+	return &c
+}
+
+func (o *Operation) Proto() *Proto {
+	state, _ := o.state.load()
+	return state
+}
+
+func (o *Operation) Client() Client {
+	o.state.mu.RLock()
+	defer o.state.mu.RUnlock()
+	return o.client
+}
+
+// WithOrigin records the SDK method and the request resource that started the operation.
+// The origin is reported by String and in wait and poll errors.
+func (o *Operation) WithOrigin(method, resource string) *Operation {
+	o.origin = origin{method: method, resource: resource}
+	if o.logger != nil {
+		// The correlation fields are derived again, with the origin.
+		o.logger = &operationLogger{base: o.logger.base}
+	}
+	return o
+}
+
+// Origin returns the SDK method and the request resource that started the operation, if known.
+func (o *Operation) Origin() (method, resource string) {
+	return o.origin.method, o.origin.resource
+}
+
+func (o *Operation) String() string {
+	id := TruncateText(o.Id(), TextLimit)
+	if o.origin.method == "" {
+		return fmt.Sprintf("operation (id=%s)", id)
+	}
+	if o.origin.resource == "" {
+		return fmt.Sprintf("operation (id=%s, origin=%s)", id, o.origin.method)
+	}
+	return fmt.Sprintf("operation (id=%s, origin=%s, resource=%s)", id, o.origin.method, TruncateText(o.origin.resource, TextLimit))
+}
 
 //revive:disable:var-naming
-func (o *Operation) Id() string { return o.proto.GetId() }
+func (o *Operation) Id() string { return o.Proto().GetId() }
 
 //revive:enable:var-naming
-func (o *Operation) Description() string { return o.proto.GetDescription() }
-func (o *Operation) CreatedBy() string   { return o.proto.GetCreatedBy() }
+func (o *Operation) Description() string { return o.Proto().GetDescription() }
+func (o *Operation) CreatedBy() string   { return o.Proto().GetCreatedBy() }
 
-func (o *Operation) ResourceId() string { return o.proto.GetResourceId() }
+func (o *Operation) ResourceId() string { return o.Proto().GetResourceId() }
 
+// CreatedAt returns the creation time, zero if it is unknown.
 func (o *Operation) CreatedAt() time.Time {
-	return o.proto.GetCreateTime().AsTime()
+	created := o.Proto().GetCreateTime()
+	if created == nil {
+		return time.Time{}
+	}
+	return created.AsTime()
 }
 
+// Metadata returns the metadata map of the current state, without copying it: the map is
+// shared with the state and must not be modified.
 func (o *Operation) Metadata() map[string]string {
-	return o.proto.GetMetadata()
+	return o.Proto().GetMetadata()
 }
 
 func (o *Operation) Error() error {
@@ -89,109 +199,368 @@ func (o *Operation) Error() error {
 }
 
 func (o *Operation) ErrorStatus() *status.Status {
-	proto := o.proto.GetError()
+	proto := o.Proto().GetError()
 	if proto == nil {
 		return nil
 	}
 	return status.FromProto(proto)
 }
 
+// Done reports whether the operation is done. Operations of NewFromID are not done until
+// they are polled.
 func (o *Operation) Done() bool {
-	return o.proto.GetStatus() == dc.Operation_STATUS_DONE || o.proto.GetStatus() == dc.Operation_STATUS_INVALID
+	return stateDone(o.state.load())
 }
-func (o *Operation) Ok() bool     { return o.Done() && o.proto.GetError() == nil }
-func (o *Operation) Failed() bool { return o.Done() && o.proto.GetError() != nil }
 
-// Poll gets new state of operation from operation client. On success the operation state is updated.
-// Returns error if update request failed.
-func (o *Operation) Poll(ctx context.Context, opts ...grpc.CallOption) error {
-	var state *doublecloud.Operation
-	var err error
+func (o *Operation) Ok() bool {
+	state, resumed := o.state.load()
+	return stateDone(state, resumed) && state.GetError() == nil
+}
 
-	if strings.HasPrefix(o.Id(), CLICKHOUSE_OPERATION_PREFIX) {
-		state, err = o.Client().(clickhouse.OperationServiceClient).Get(ctx, &clickhouse.GetOperationRequest{OperationId: o.Id()}, opts...)
-	} else if strings.HasPrefix(o.Id(), KAFKA_OPERATION_PREFIX) {
-		state, err = o.Client().(kafka.OperationServiceClient).Get(ctx, &kafka.GetOperationRequest{OperationId: o.Id()}, opts...)
-	} else if strings.HasPrefix(o.Id(), TRANSFER_OPERATION_PREFIX) || strings.HasPrefix(o.Id(), TRANSFER_ENDPOINTS_OPERATION_PREFIX) {
-		state, err = o.Client().(transfer.OperationServiceClient).Get(ctx, &transfer.GetOperationRequest{OperationId: o.Id()}, opts...)
-	} else if _, err := uuid.Parse(o.Id()); err == nil {
-		state, err = o.Client().(network.OperationServiceClient).Get(ctx, &network.GetOperationRequest{OperationId: o.Id()}, opts...)
+func (o *Operation) Failed() bool {
+	state, resumed := o.state.load()
+	return stateDone(state, resumed) && state.GetError() != nil
+}
+
+// Poll gets new state of operation from operation client, or with the PollFunc set with
+// WithPollFunc. On success the operation state is updated.
+// Returns error if update request failed, *NoOperationClientError if the client can't get
+// operations of the kind. Synthetic operations are not polled, see NewCompleted.
+func (o *Operation) Poll(ctx context.Context, opts ...grpc.CallOption) error {
+	if o.synthetic {
+		return nil
+	}
+	ctx, err := withCallMetadata(ctx, callMetadataOf(opts), 1)
+	if err != nil {
+		return sdkerrors.WithMessagef(err, "%s poll", o)
+	}
+	if poll := pollFuncOf(opts); poll != nil {
+		_, err := o.pollWith(ctx, poll)
+		return err
+	}
+	kind := operationKindOf(o.Id())
+	if kind == nil {
+		return newUnknownOperationTypeError(o)
+	}
+	if kind.resolve != nil {
+		state, err := o.getWith(ctx, opts, func(client Client) (*Proto, error) {
+			return kind.resolve(ctx, client, o.Id(), opts...)
+		})
+		if err != nil {
+			return err
+		}
+		o.state.store(state)
+		return nil
 	}
-	if state == nil {
-		return sdkerrors.WithMessagef(err, "operation (id=%s) unknown type", o.Id())
+	req, err := kind.request(o.Id())
+	if err != nil {
+		return sdkerrors.WithMessagef(err, "%s poll", o)
 	}
+	state, err := o.getWith(ctx, opts, func(client Client) (*Proto, error) {
+		if !kind.implementedBy(client) {
+			return nil, &NoOperationClientError{Operation: o, Kind: kind.name, Expected: kind.client.String()}
+		}
+		return kind.get(ctx, client, req, opts...)
+	})
 	if err != nil {
 		return err
 	}
-	o.proto = state
+	o.state.store(state)
 	return nil
 }
 
 const DefaultPollInterval = time.Second
 
+// Wait waits for the operation to be done, polling it every DefaultPollInterval, or as the
+// policy of WithPollPolicy says.
+//
+// If the client implements LongPollClient, the wait makes blocking wait calls bounded by
+// DefaultLongPollTimeout (see LongPollTimeout) instead, ignoring the poll interval and the
+// interval suggested by the server. It falls back to polling if the call is unimplemented
+// or runs out of its timeout several times in a row.
+//
+// The error tells the failures apart: *OperationError if the operation failed, *PollError,
+// *PollRetriesExhaustedError or *FatalPollError if it couldn't be polled, and the error of
+// ctx, matched by errors.Is, if ctx is done first.
 func (o *Operation) Wait(ctx context.Context, opts ...grpc.CallOption) error {
-	return o.WaitInterval(ctx, DefaultPollInterval, opts...)
+	interval := DefaultPollInterval
+	if o.pollPolicy.Interval > 0 {
+		interval = o.pollPolicy.Interval
+	}
+	return o.WaitInterval(ctx, interval, opts...)
 }
 
-func (o *Operation) WaitInterval(ctx context.Context, pollInterval time.Duration, opts ...grpc.CallOption) error {
-	return o.waitInterval(ctx, pollInterval, opts...)
+// WaitInterval is Wait polling every pollInterval, DefaultPollInterval if it is not positive,
+// see WithBusyPoll. pollInterval replaces the Interval of the policy of WithPollPolicy.
+func (o *Operation) WaitInterval(ctx context.Context, pollInterval time.Duration, opts ...grpc.CallOption) (err error) {
+	if policy := o.pollPolicy.options(pollInterval); len(policy) > 0 {
+		opts = append(policy, opts...)
+	}
+	started := time.Now()
+	defer func() { observeWait(ctx, o, started, err) }()
+	waitsStarted(1)
+	defer waitsFinished(1)
+	var polls int
+	metrics, clock := metricsOf(opts), clockOf(o, opts)
+	defer func(started time.Time) { metrics.WaitFinished(o, err, polls, clock.now().Sub(started)) }(clock.now())
+	if logger := o.waitLogger(); logger != nil {
+		logger.Log(LogDebug, "operation wait started", "interval", pollInterval)
+		defer func(started time.Time) {
+			if err != nil {
+				logger.Log(LogInfo, "operation wait failed", "polls", polls, "duration", clock.now().Sub(started), "error", err)
+				return
+			}
+			logger.Log(LogInfo, "operation wait finished", "polls", polls, "duration", clock.now().Sub(started), "status", o.Proto().GetStatus().String())
+		}(clock.now())
+	}
+	err = o.waitInterval(ctx, pollInterval, &polls, opts...)
+	if err != nil && ctx.Err() != nil && !o.Done() && cancelOnAbandonOf(opts) {
+		return o.abandon(ctx, opts)
+	}
+	return err
 }
 
 const (
-	pollIntervalMetadataKey = "x-operation-poll-interval"
+	// PollIntervalMetadataKey is the header of the poll interval suggested by the server in
+	// poll responses, see ParsePollInterval.
+	PollIntervalMetadataKey = "x-operation-poll-interval"
 )
 
-func (o *Operation) waitInterval(ctx context.Context, pollInterval time.Duration, opts ...grpc.CallOption) error {
+// waitInterval counts the polls of the wait in polls.
+func (o *Operation) waitInterval(ctx context.Context, pollInterval time.Duration, polls *int, opts ...grpc.CallOption) (err error) {
 	var headers metadata.MD
-	opts = append(opts, grpc.Header(&headers))
+	// Polls are not retried by the retry interceptor unless opts set retry.Attempts:
+	// the wait loop tolerates failures itself, and inner retries would multiply its attempts.
+	// The new slice also keeps the header destination out of the caller's one, which
+	// may be shared with concurrent waits.
+	opts = append(append([]grpc.CallOption{retry.Disable()}, opts...), grpc.Header(&headers))
+	// The metadata of the polls is added by the wait, with the number of the attempt.
+	calls := callMetadataOf(opts)
+	opts = append(opts, WithCallMetadata(nil))
+	poll := pollFuncOf(opts)
+	if poll == nil {
+		if err := o.checkClient(); err != nil {
+			return err
+		}
+	}
 
-	// Sometimes, the returned operation is not on all replicas yet,
-	// so we need to ignore first couple of NotFound errors.
-	const maxNotFoundRetry = 3
-	notFoundCount := 0
+	// Sometimes, the returned operation is not on all replicas yet, or the connection drops,
+	// so failed polls are retried by the RetryPolicy. The retries are debited from the retry
+	// budget of ctx, shared with the retry interceptor, if any. The budget of the wait itself
+	// is reset by successful polls.
+	policy := retryPolicyOf(opts)
+	budget := retry.BudgetFromContext(ctx)
+	ownBudget := budget == nil
+	if ownBudget {
+		budget = retry.NewBudget(policy.MaxConsecutiveFailures)
+		ctx = retry.WithBudget(ctx, budget)
+	}
+	var failures int
+	fatal := fatalCodesOf(opts)
+	var refreshed bool
+	var refreshErr error
+	rotations := newRotationWatch(o.rotation)
+	var longPoll *longPoller
+	if poll == nil {
+		longPoll = newLongPoller(o.Client(), opts)
+	}
+	intervals := intervalPolicyOf(opts)
+	if intervals != nil {
+		defer intervals.done(o)
+	}
+	clock := clockOf(o, opts)
+	parent, deadline := ctx, serverDeadlineOf(opts, clock.now)
+	onPoll := pollCallbackOf(opts)
+	metrics := metricsOf(opts)
+	pause := pauseControllerOf(opts)
+	limiter := pollLimiterOf(opts)
+	sla := slaOf(opts, clock.now)
+	busy := busyPollOf(opts)
+	if pollInterval <= 0 && !busy {
+		pollInterval = DefaultPollInterval
+	}
+	checkpoints := newWaitCheckpoints(opts, clock.now)
+	logger := o.waitLogger()
+	telemetry := newWaitTelemetry(telemetrySamplingOf(o, opts), decisionLogOf(opts), waitJournalOf(o, opts), logger)
+	// decision is the decision of the last poll, recorded once it is complete.
+	var decision *Decision
+	record := func() {
+		if decision != nil {
+			telemetry.add(*decision)
+			decision = nil
+		}
+	}
+	defer func() {
+		record()
+		telemetry.finish(err)
+		checkpoints.finish(ctx, o)
+	}()
+	var attempt int
 	for !o.Done() {
+		record()
+		if err := pause.wait(ctx, o); err != nil {
+			return err
+		}
+		if err := limiter.wait(ctx, o); err != nil {
+			return err
+		}
 		headers = metadata.MD{}
-		err := o.Poll(ctx, opts...)
+		var hinted time.Duration
+		metrics.PollStarted(o)
+		limiter.polled()
+		longPolled, pollStarted := false, clock.now()
+		pollCtx, err := withCallMetadata(ctx, calls, attempt+1)
+		switch {
+		case err != nil:
+		case poll != nil:
+			attemptCtx, cancel := policy.attempt(pollCtx)
+			hinted, err = o.pollWith(attemptCtx, poll)
+			err = policy.attemptError(ctx, attemptCtx, o, err)
+			cancel()
+		case longPoll != nil:
+			longPolled = true
+			err = longPoll.wait(pollCtx, o, opts...)
+		default:
+			attemptCtx, cancel := policy.attempt(pollCtx)
+			err = o.Poll(attemptCtx, opts...)
+			err = policy.attemptError(ctx, attemptCtx, o, err)
+			cancel()
+		}
+		metrics.PollFinished(o, err, clock.now().Sub(pollStarted))
+		*polls++
+		if longPolled {
+			if fallback, retry := longPoll.fallback(ctx, err); fallback || retry {
+				if fallback {
+					longPoll = nil
+				}
+				continue
+			}
+		}
+		attempt++
+		onPoll(o, attempt, err)
+		checkpoints.polled(ctx, o)
+		if telemetry.enabled() {
+			decision = &Decision{OperationID: o.Id(), Attempt: attempt, At: clock.now(), Code: pollErrorCode(err), Status: o.Proto().GetStatus(), Failures: failures}
+		}
+		if err != nil && ctx.Err() != nil {
+			// The poll failed because ctx is done, e.g. with a status Canceled from gRPC.
+			if err := deadline.exceeded(ctx, parent, o); err != nil {
+				return err
+			}
+			return sdkerrors.WithMessagef(ctx.Err(), "%s wait context done", o)
+		}
 		if err != nil {
-			if notFoundCount < maxNotFoundRetry && shoudRetry(err) {
-				notFoundCount++
-			} else {
-				// Message needed to distinguish poll fail and operation error, which are both gRPC status.
-				return sdkerrors.WithMessagef(err, "operation (id=%s) poll fail", o.Id())
+			code := status.Code(err)
+			if rotatedAt := rotations.rejected(code); fatal[code] || !rotatedAt.IsZero() {
+				// Cached credentials may have just expired: refresh them once and poll again,
+				// and once more after a rotation of the credentials during the wait.
+				if again := rotations.refresh(rotatedAt) || !refreshed; o.refresh != nil && again {
+					refreshed = true
+					refreshErr = SafeCall("credentials refresher", func() error { return o.refresh(ctx, opts...) })
+					if refreshErr == nil {
+						if logger != nil {
+							logger.Log(LogInfo, "credentials refreshed, polling again", "attempt", attempt, "code", code.String())
+						}
+						decision.setNext(0, IntervalRefresh)
+						continue
+					}
+				}
+				fatalErr := &FatalPollError{
+					Operation:  o,
+					Code:       code,
+					Refreshed:  refreshed && refreshErr == nil,
+					RefreshErr: refreshErr,
+					Err:        err,
+				}
+				if !rotatedAt.IsZero() {
+					return &CredentialsRotatedError{RotatedAt: rotatedAt, Err: fatalErr}
+				}
+				return fatalErr
+			}
+			if err := deadline.exceeded(ctx, parent, o); err != nil {
+				return err
+			}
+			failures++
+			decision.setFailures(failures)
+			if errors.Is(err, ErrCallbackPanicked) || !policy.retryable(ctx, err) {
+				return &PollError{Operation: o, Attempts: failures, Err: err}
+			}
+			if failures > policy.MaxConsecutiveFailures || !budget.Take() {
+				return &PollRetriesExhaustedError{Operation: o, Attempts: failures, Code: pollErrorCode(err), Err: err}
+			}
+			if logger != nil {
+				logger.Log(LogWarn, "operation poll failed, retrying", "attempt", attempt, "failures", failures, "error", err)
+			}
+		} else {
+			failures = 0
+			decision.setFailures(0)
+			if ownBudget {
+				budget.Reset(policy.MaxConsecutiveFailures)
 			}
 		}
 		if o.Done() {
 			break
 		}
-		interval := pollInterval
-		if vals := headers.Get(pollIntervalMetadataKey); len(vals) > 0 {
-			i, err := strconv.Atoi(vals[0])
-			if err == nil {
-				interval = time.Duration(i) * time.Second
+		sla.check(o)
+		if err == nil {
+			if bounded, cancel, ok := deadline.bound(ctx, o); ok {
+				defer cancel()
+				ctx = bounded
 			}
 		}
-		if interval <= 0 {
+		if longPoll != nil {
+			decision.setNext(0, IntervalLongPoll)
 			continue
 		}
-		wait, stop := o.newTimer(interval)
+		interval, source := pollInterval, IntervalDefault
+		hint, hintOk := hinted, hinted > 0
+		if !hintOk {
+			hint, hintOk = o.intervalHint(headers, opts)
+		}
+		if err == nil {
+			o.state.setSuggested(hint)
+		}
+		switch {
+		case hintOk && intervals != nil:
+			interval, source = intervals.hinted(hint), IntervalServer
+		case hintOk:
+			interval, source = hint, IntervalServer
+		case intervals != nil:
+			interval, source = intervals.next(o), intervals.source()
+		}
+		if interval <= 0 {
+			if busy {
+				decision.setNext(0, source)
+				continue
+			}
+			interval, source = pollInterval, IntervalDefault
+		}
+		decision.setNext(interval, source)
+		wait, stop := clock.newTimer(interval)
 		select {
 		case <-wait():
 		case <-ctx.Done():
 			stop()
-			return sdkerrors.WithMessagef(ctx.Err(), "operation (id=%s) wait context done", o.Id())
+			if err := deadline.exceeded(ctx, parent, o); err != nil {
+				return err
+			}
+			return sdkerrors.WithMessagef(ctx.Err(), "%s wait context done", o)
 		}
 	}
-	return sdkerrors.WithMessagef(o.Error(), "operation (id=%s) failed", o.Id())
+	return operationError(o)
 }
 
-func shoudRetry(err error) bool {
-	status, ok := status.FromError(err)
-	return ok && status.Code() == codes.NotFound
+// logDecision logs the decision of a poll.
+func logDecision(logger Logger, d Decision) {
+	logger.Log(LogDebug, "operation polled", "attempt", d.Attempt, "code", d.Code.String(), "status", d.Status.String(),
+		"failures", d.Failures, "interval", d.Interval, "source", string(d.Source))
 }
 
-func unmarshalAny(msg *anypb.Any) (proto.Message, error) {
-	if msg == nil {
-		return nil, nil
+// pollErrorCode returns the code of the poll error, DeadlineExceeded for a poll running out
+// of its attempt timeout.
+func pollErrorCode(err error) codes.Code {
+	if code := status.Code(err); code != codes.Unknown {
+		return code
 	}
-	return msg.UnmarshalNew()
+	return status.FromContextError(err).Code()
 }
@@ -1,7 +1,9 @@
 package operation
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/doublecloud/go-genproto/doublecloud/v1"
 	"github.com/stretchr/testify/assert"
@@ -38,3 +40,27 @@ func TestOperation_Fail(t *testing.T) {
 	assert.False(t, op.Ok())
 	assert.True(t, op.Failed())
 }
+
+func TestNewFromID(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		if n == 1 {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_INVALID}, nil
+	}}
+	op := NewFromID(client, "kfo1")
+	op.newTimer = fastTimer
+	assert.Equal(t, "kfo1", op.Id())
+	assert.False(t, op.Done(), "not done before the first poll")
+	assert.False(t, op.Ok())
+	assert.False(t, op.Failed())
+	assert.Nil(t, op.Error())
+	assert.Equal(t, time.Time{}, op.CreatedAt())
+	assert.Nil(t, op.Metadata())
+	assert.Equal(t, "operation (id=kfo1, origin=resumed)", op.String())
+
+	assert.NoError(t, op.Wait(context.Background()))
+	assert.True(t, op.Ok())
+	assert.Equal(t, 2, client.calls(), "the wait polls until the operation is done")
+}
@@ -0,0 +1,211 @@
+// Package operationtest runs waits of operations against a scripted server on a virtual
+// clock, for tests of the wait machinery and of code tuning waits.
+//
+// A test declares the answers of the server to the polls, runs the wait, and checks the
+// trace of the polls made and the sleeps taken between them:
+//
+//	trace := operationtest.Run(ctx, []operationtest.Step{
+//		operationtest.Pending().WithPollInterval("5"),
+//		operationtest.PollError(codes.Unavailable),
+//		operationtest.Running(),
+//		operationtest.Done(),
+//	}, func(ctx context.Context, op *operation.Operation, opts ...grpc.CallOption) error {
+//		return op.Wait(ctx, opts...)
+//	})
+//	// trace.Sleeps: 5s, 1s, 1s
+//
+// The operations are Kafka operations, so the package requires the kinds of the DoubleCloud
+// services, which are not registered with the operation_nokinds tag.
+package operationtest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// OperationID is the ID of the operations of Run.
+const OperationID = "kfo1"
+
+// Start is the time of the virtual clock when the waits of Run start.
+var Start = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// Step is the answer of the server to a poll.
+type Step struct {
+	// Code fails the poll, unless it is codes.OK.
+	Code codes.Code
+	// Status is the status of the operation returned by the poll.
+	Status dcv1.Operation_Status
+	// Error fails the operation returned by the poll.
+	Error *status.Status
+	// PollInterval, if set, is the value of the poll interval header of the response, see
+	// operation.PollIntervalMetadataKey.
+	PollInterval string
+	// Took is the time the poll takes on the virtual clock.
+	Took time.Duration
+}
+
+// Pending answers with the operation pending.
+func Pending() Step { return Step{Status: dcv1.Operation_STATUS_PENDING} }
+
+// Running answers with the operation running.
+func Running() Step { return Step{Status: dcv1.Operation_STATUS_RUNNING} }
+
+// Done answers with the operation done.
+func Done() Step { return Step{Status: dcv1.Operation_STATUS_DONE} }
+
+// Failed answers with the operation failed with the code and the message.
+func Failed(code codes.Code, msg string) Step {
+	return Step{Status: dcv1.Operation_STATUS_DONE, Error: status.New(code, msg)}
+}
+
+// PollError fails the poll with the code.
+func PollError(code codes.Code) Step { return Step{Code: code} }
+
+// WithPollInterval returns the step with the poll interval header set to value, e.g. "5".
+func (s Step) WithPollInterval(value string) Step {
+	s.PollInterval = value
+	return s
+}
+
+// Taking returns the step taking d on the virtual clock.
+func (s Step) Taking(d time.Duration) Step {
+	s.Took = d
+	return s
+}
+
+// Trace is what happened during a wait of Run.
+type Trace struct {
+	// Polls are the polls made.
+	Polls []Poll
+	// Sleeps are the durations of the timers of the wait, the sleeps between polls.
+	Sleeps []time.Duration
+	// Elapsed is the time the wait took on the virtual clock.
+	Elapsed time.Duration
+	// Err is the error of the wait.
+	Err error
+	// Operation is the operation waited for.
+	Operation *operation.Operation
+}
+
+// Poll is a poll of a wait of Run.
+type Poll struct {
+	// At is the time of the poll since Start.
+	At time.Duration
+	// Step is the index of the answer in the script.
+	Step int
+}
+
+// WaitFunc waits for op with opts, which set the virtual clock, e.g. with op.Wait.
+type WaitFunc func(ctx context.Context, op *operation.Operation, opts ...grpc.CallOption) error
+
+// Run waits for a pending operation with wait, answering the polls with the steps of the
+// script in order, the last one once the script is over.
+func Run(ctx context.Context, script []Step, wait WaitFunc) *Trace {
+	s := &server{clock: &clock{now: Start}, script: script}
+	trace := &Trace{Operation: operation.New(s, &operation.Proto{Id: OperationID, Status: dcv1.Operation_STATUS_PENDING})}
+	trace.Err = wait(ctx, trace.Operation, operation.WithClock(s.clock))
+	trace.Polls = s.polls
+	trace.Sleeps = s.clock.sleeps
+	trace.Elapsed = s.clock.elapsed()
+	return trace
+}
+
+// server is the kafka.OperationServiceClient of Run.
+type server struct {
+	clock  *clock
+	script []Step
+
+	mu    sync.Mutex
+	polls []Poll
+}
+
+var _ kafka.OperationServiceClient = (*server)(nil)
+
+func (s *server) Get(ctx context.Context, in *kafka.GetOperationRequest, opts ...grpc.CallOption) (*dcv1.Operation, error) {
+	s.mu.Lock()
+	n := len(s.polls)
+	if n >= len(s.script) {
+		n = len(s.script) - 1
+	}
+	s.polls = append(s.polls, Poll{At: s.clock.elapsed(), Step: n})
+	s.mu.Unlock()
+	if n < 0 {
+		return nil, status.Error(codes.Internal, "operationtest: empty script")
+	}
+	step := s.script[n]
+	s.clock.advance(step.Took)
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if step.PollInterval != "" {
+		for _, opt := range opts {
+			if h, ok := opt.(grpc.HeaderCallOption); ok {
+				*h.HeaderAddr = metadata.Pairs(operation.PollIntervalMetadataKey, step.PollInterval)
+			}
+		}
+	}
+	if step.Code != codes.OK {
+		return nil, status.Error(step.Code, "operationtest: scripted")
+	}
+	op := &dcv1.Operation{Id: in.GetOperationId(), Status: step.Status}
+	if step.Error != nil {
+		op.Error = step.Error.Proto()
+	}
+	return op, nil
+}
+
+func (s *server) List(ctx context.Context, in *kafka.ListOperationsRequest, opts ...grpc.CallOption) (*kafka.ListOperationsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "operationtest: operations can't be listed")
+}
+
+// clock is the virtual clock of Run: its timers fire right away, moving the clock.
+type clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) NewTimer(d time.Duration) operation.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	t := firedTimer(make(chan time.Time, 1))
+	t <- c.now
+	return t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *clock) elapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now.Sub(Start)
+}
+
+type firedTimer chan time.Time
+
+func (t firedTimer) C() <-chan time.Time { return t }
+func (t firedTimer) Stop() bool          { return false }
@@ -0,0 +1,65 @@
+//go:build !operation_nokinds
+
+package operationtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+func wait(ctx context.Context, op *operation.Operation, opts ...grpc.CallOption) error {
+	return op.Wait(ctx, opts...)
+}
+
+func TestRun(t *testing.T) {
+	trace := Run(context.Background(), []Step{
+		Pending().WithPollInterval("5"),
+		PollError(codes.Unavailable),
+		Running().Taking(300 * time.Millisecond),
+		Done(),
+	}, wait)
+	require.NoError(t, trace.Err)
+	assert.True(t, trace.Operation.Ok())
+	assert.Equal(t, []time.Duration{5 * time.Second, time.Second, time.Second}, trace.Sleeps)
+	assert.Equal(t, []Poll{
+		{At: 0, Step: 0},
+		{At: 5 * time.Second, Step: 1},
+		{At: 6 * time.Second, Step: 2},
+		{At: 7300 * time.Millisecond, Step: 3},
+	}, trace.Polls)
+	assert.Equal(t, 7300*time.Millisecond, trace.Elapsed)
+}
+
+func TestRun_Failed(t *testing.T) {
+	trace := Run(context.Background(), []Step{Running(), Failed(codes.ResourceExhausted, "quota")}, wait)
+	assert.ErrorIs(t, trace.Err, operation.ErrOperationFailed)
+	assert.True(t, trace.Operation.Failed())
+	assert.Equal(t, codes.ResourceExhausted, trace.Operation.ErrorStatus().Code())
+	assert.Len(t, trace.Polls, 2)
+}
+
+func TestRun_LastStepRepeats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	trace := Run(ctx, []Step{Running()}, func(ctx context.Context, op *operation.Operation, opts ...grpc.CallOption) error {
+		return op.Wait(ctx, append(opts, operation.WithPollCallback(func(*operation.Operation, int, error) {
+			if n++; n == 10 {
+				cancel()
+			}
+		}))...)
+	})
+	assert.ErrorIs(t, trace.Err, context.Canceled)
+	require.GreaterOrEqual(t, len(trace.Polls), 10)
+	for _, p := range trace.Polls {
+		assert.Equal(t, 0, p.Step)
+	}
+}
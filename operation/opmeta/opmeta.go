@@ -0,0 +1,64 @@
+// Package opmeta provides well-known keys of operation metadata and accessors for them.
+//
+// Services put resource identifiers into operation metadata under keys that are not part
+// of the API schema. The keys below are the ones the SDK relies on; they are pinned
+// by conformance tests against operation fixtures in testdata.
+package opmeta
+
+import (
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// ClickHouse operation metadata keys.
+const (
+	ClickHouseClusterIDKey = "cluster_id"
+	ClickHouseBackupIDKey  = "backup_id"
+)
+
+// Kafka operation metadata keys.
+const (
+	KafkaClusterIDKey = "cluster_id"
+	KafkaTopicNameKey = "topic_name"
+	KafkaUserNameKey  = "user_name"
+)
+
+// Transfer operation metadata keys.
+const (
+	TransferIDKey               = "transfer_id"
+	TransferEndpointIDKey       = "endpoint_id"
+	TransferSourceEndpointIDKey = "source_endpoint_id"
+	TransferTargetEndpointIDKey = "target_endpoint_id"
+)
+
+// Network operation metadata keys.
+const (
+	NetworkIDKey           = "network_id"
+	NetworkConnectionIDKey = "network_connection_id"
+)
+
+// Get returns the metadata value stored under key. It reports false if the key is missing or empty.
+func Get(o *operation.Operation, key string) (string, bool) {
+	v := o.Metadata()[key]
+	return v, v != ""
+}
+
+// ClusterID returns the ClickHouse or Kafka cluster the operation belongs to.
+func ClusterID(o *operation.Operation) (string, bool) { return Get(o, ClickHouseClusterIDKey) }
+
+func BackupID(o *operation.Operation) (string, bool)  { return Get(o, ClickHouseBackupIDKey) }
+func TopicName(o *operation.Operation) (string, bool) { return Get(o, KafkaTopicNameKey) }
+func UserName(o *operation.Operation) (string, bool)  { return Get(o, KafkaUserNameKey) }
+
+func TransferID(o *operation.Operation) (string, bool) { return Get(o, TransferIDKey) }
+func EndpointID(o *operation.Operation) (string, bool) { return Get(o, TransferEndpointIDKey) }
+func SourceEndpointID(o *operation.Operation) (string, bool) {
+	return Get(o, TransferSourceEndpointIDKey)
+}
+func TargetEndpointID(o *operation.Operation) (string, bool) {
+	return Get(o, TransferTargetEndpointIDKey)
+}
+
+func NetworkID(o *operation.Operation) (string, bool) { return Get(o, NetworkIDKey) }
+func NetworkConnectionID(o *operation.Operation) (string, bool) {
+	return Get(o, NetworkConnectionIDKey)
+}
@@ -0,0 +1,59 @@
+package opmeta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+type accessor func(*operation.Operation) (string, bool)
+
+// TestConformance checks the accessors against operation fixtures in the services JSON format.
+// Fixtures should be refreshed from real responses with identifiers anonymized.
+// A failure here means a service renamed or dropped a metadata key.
+func TestConformance(t *testing.T) {
+	for file, want := range map[string]map[string]accessor{
+		"clickhouse_create_cluster.json": {"chcl7k3e0a1b2c3d4e5f": ClusterID},
+		"clickhouse_create_backup.json":  {"chcl7k3e0a1b2c3d4e5f": ClusterID, "chbk9x8w7v6u5t4s3r2q": BackupID},
+		"kafka_create_topic.json":        {"kfc0p9o8i7u6y5t4r3e2": ClusterID, "events": TopicName},
+		"kafka_create_user.json":         {"kfc0p9o8i7u6y5t4r3e2": ClusterID, "producer": UserName},
+		"transfer_create_endpoint.json":  {"dtea1s2d3f4g5h6j7k8l": EndpointID},
+		"transfer_create_transfer.json": {
+			"dttp0o9i8u7y6t5r4e3w": TransferID,
+			"dtea1s2d3f4g5h6j7k8l": SourceEndpointID,
+			"dtez9x8c7v6b5n4m3l2k": TargetEndpointID,
+		},
+		"network_create_connection.json": {"vpcn1b2v3c4x5z6a7s8d": NetworkID, "vpcc9f8g7h6j5k4l3m2n": NetworkConnectionID},
+	} {
+		t.Run(file, func(t *testing.T) {
+			op := loadFixture(t, file)
+			for value, get := range want {
+				got, ok := get(op)
+				assert.True(t, ok, "missing %q", value)
+				assert.Equal(t, value, got)
+			}
+		})
+	}
+}
+
+func TestGet_Missing(t *testing.T) {
+	op := operation.New(nil, &operation.Proto{Metadata: map[string]string{TransferIDKey: ""}})
+	_, ok := TransferID(op)
+	assert.False(t, ok)
+	_, ok = ClusterID(operation.New(nil, &operation.Proto{}))
+	assert.False(t, ok)
+}
+
+func loadFixture(t *testing.T, name string) *operation.Operation {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	p := &operation.Proto{}
+	require.NoError(t, protojson.Unmarshal(data, p))
+	return operation.New(nil, p)
+}
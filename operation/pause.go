@@ -0,0 +1,100 @@
+package operation
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// PauseController pauses the polls of the waits made with WithPauseController, e.g. during
+// the maintenance windows of the provider, without ending the waits. A controller may be
+// shared by any number of waits. The zero value is not usable, see NewPauseController.
+type PauseController struct {
+	mu sync.Mutex
+	// resumed is closed while the controller isn't paused.
+	resumed chan struct{}
+}
+
+// NewPauseController returns a controller not paused.
+func NewPauseController() *PauseController {
+	resumed := make(chan struct{})
+	close(resumed)
+	return &PauseController{resumed: resumed}
+}
+
+// Pause makes the waits stop polling before their next poll until Resume. The polls in
+// flight complete. Waits still end once their context is done.
+func (pc *PauseController) Pause() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	select {
+	case <-pc.resumed:
+		pc.resumed = make(chan struct{})
+	default:
+	}
+}
+
+// Resume lets the waits paused poll again right away. Their retry budgets and failure
+// counts are the ones they had when paused.
+func (pc *PauseController) Resume() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	select {
+	case <-pc.resumed:
+	default:
+		close(pc.resumed)
+	}
+}
+
+// Paused reports whether the controller is paused.
+func (pc *PauseController) Paused() bool {
+	select {
+	case <-pc.resumedChan():
+		return false
+	default:
+		return true
+	}
+}
+
+func (pc *PauseController) resumedChan() <-chan struct{} {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.resumed
+}
+
+// wait blocks until the controller isn't paused, or ctx is done.
+func (pc *PauseController) wait(ctx context.Context, o *Operation) error {
+	if pc == nil {
+		return nil
+	}
+	select {
+	case <-pc.resumedChan():
+		return nil
+	case <-ctx.Done():
+		return sdkerrors.WithMessagef(ctx.Err(), "%s wait context done", o)
+	}
+}
+
+// WithPauseController makes the waits hold their polls while pc is paused: Wait,
+// WaitInterval, ResourceIdWait, WaitAll and WaitAny.
+func WithPauseController(pc *PauseController) grpc.CallOption {
+	return &pauseOption{pc: pc}
+}
+
+type pauseOption struct {
+	grpc.EmptyCallOption
+	pc *PauseController
+}
+
+func pauseControllerOf(opts []grpc.CallOption) *PauseController {
+	var pc *PauseController
+	for _, opt := range opts {
+		if opt, ok := opt.(*pauseOption); ok {
+			pc = opt.pc
+		}
+	}
+	return pc
+}
@@ -0,0 +1,108 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// pausedFor is how long the tests check that nothing is polled while paused: with the fake
+// clock the waits would complete right away otherwise.
+const pausedFor = 50 * time.Millisecond
+
+func TestPauseController(t *testing.T) {
+	pc := NewPauseController()
+	assert.False(t, pc.Paused())
+	pc.Pause()
+	pc.Pause()
+	assert.True(t, pc.Paused())
+	pc.Resume()
+	pc.Resume()
+	assert.False(t, pc.Paused())
+}
+
+func TestWait_Paused(t *testing.T) {
+	requireKinds(t)
+	c := newFakeClock(t)
+	pc := NewPauseController()
+	client := &fakeKafkaClient{get: runningFor(codes.Unavailable)}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	pauseAfterFirst := WithPollCallback(func(o *Operation, attempt int, err error) {
+		if attempt == 1 {
+			pc.Pause()
+		}
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- op.Wait(context.Background(), WithClock(c), WithPauseController(pc), pauseAfterFirst)
+	}()
+	select {
+	case err := <-result:
+		t.Fatalf("the paused wait returned %v", err)
+	case <-time.After(pausedFor):
+	}
+	assert.Equal(t, 1, client.calls(), "nothing is polled while paused")
+
+	pc.Resume()
+	require.NoError(t, <-result, "the failure before the pause is still tolerated after it")
+	assert.True(t, op.Ok())
+	assert.Equal(t, 4, client.calls())
+}
+
+func TestWait_PausedContextDone(t *testing.T) {
+	requireKinds(t)
+	pc := NewPauseController()
+	pc.Pause()
+	client := &fakeKafkaClient{get: runningFor()}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	ctx, cancel := context.WithTimeout(context.Background(), pausedFor)
+	defer cancel()
+	err := op.Wait(ctx, WithClock(newFakeClock(t)), WithPauseController(pc))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, client.calls())
+}
+
+func TestPauseController_Shared(t *testing.T) {
+	requireKinds(t)
+	pc := NewPauseController()
+	pc.Pause()
+	single := &fakeKafkaClient{get: runningFor()}
+	batch := &fakeKafkaClient{get: runningFor()}
+	resource := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, ResourceId: "kf1", Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+	opts := WithPauseController(pc)
+	singleClock, batchClock, resourceClock := newFakeClock(t), newFakeClock(t), newFakeClock(t)
+
+	results := make(chan error, 3)
+	go func() {
+		results <- New(single, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}).Wait(context.Background(), opts, WithClock(singleClock))
+	}()
+	go func() {
+		ops := []*Operation{New(batch, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING})}
+		results <- WaitAll(context.Background(), ops, opts, WithClock(batchClock), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+			op, err := batch.Get(ctx, nil)
+			return op, time.Millisecond, err
+		}))
+	}()
+	go func() {
+		_, err := New(resource, &Proto{Id: "kfo3", Status: doublecloud.Operation_STATUS_PENDING}).ResourceIdWait(context.Background(), time.Minute, opts, WithClock(resourceClock))
+		results <- err
+	}()
+	time.Sleep(pausedFor)
+	assert.Zero(t, single.calls()+batch.calls()+resource.calls(), "nothing is polled while paused")
+
+	pc.Resume()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-results)
+	}
+	assert.NotZero(t, single.calls())
+	assert.NotZero(t, batch.calls())
+	assert.Equal(t, 1, resource.calls())
+}
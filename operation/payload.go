@@ -0,0 +1,156 @@
+package operation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// DefaultPayloadLimit is the size in bytes of the largest metadata value or error
+	// detail MarshalJSON encodes unless WithPayloadLimit is set.
+	DefaultPayloadLimit = 64 << 10
+	// TextLimit is the size in bytes of the longest text, e.g. a description or an error
+	// message, kept by Snapshot and String; longer ones are truncated with TruncateText.
+	TextLimit = 1 << 10
+)
+
+// PayloadRef records a payload MarshalJSON truncated or left out for exceeding the payload
+// limit of the operation.
+type PayloadRef struct {
+	// Field is "description" or "error.message" for the texts, truncated with TruncateText,
+	// "metadata.<key>" for the metadata values and "error.details[<index>]" for the Any
+	// details of the error, left out.
+	Field string `json:"field"`
+	// Size is the size of the payload in bytes.
+	Size int `json:"size"`
+	// SHA256 is the hex-encoded hash of the payload, of the Any value for error details.
+	SHA256 string `json:"sha256"`
+}
+
+func newPayloadRef[P string | []byte](field string, payload P) PayloadRef {
+	return PayloadRef{Field: field, Size: len(payload), SHA256: sha256Hex(payload)}
+}
+
+// sha256Hex returns the hex-encoded hash of s, without copying it whole.
+func sha256Hex[P string | []byte](s P) string {
+	h := sha256.New()
+	var buf [32 << 10]byte
+	for len(s) > 0 {
+		n := copy(buf[:], s)
+		h.Write(buf[:n])
+		s = s[n:]
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WithPayloadLimit sets the size in bytes of the largest payload MarshalJSON encodes,
+// DefaultPayloadLimit if limit is zero: larger metadata values and error details are left
+// out, and a longer description or error message are truncated with TruncateText. Either
+// way they are recorded by their size and hash, see PayloadRef, and the payloads left out
+// are back after the next poll of the decoded operation. A negative limit encodes every
+// payload whole.
+func (o *Operation) WithPayloadLimit(limit int) *Operation {
+	o.payloadLimit = limit
+	return o
+}
+
+func (o *Operation) payloadLimitOrDefault() int {
+	if o.payloadLimit == 0 {
+		return DefaultPayloadLimit
+	}
+	return o.payloadLimit
+}
+
+// limitPayloads returns state without the payloads larger than limit and their refs, state
+// itself if there are none. The state isn't modified: its copy is shallow, sharing all but
+// the fields limited.
+func limitPayloads(state *Proto, limit int) (*Proto, []PayloadRef) {
+	if limit < 0 {
+		return state, nil
+	}
+	var refs []PayloadRef
+	limited, st := state, state.GetError()
+	edit := func() {
+		if limited == state {
+			limited = shallowCopy(state)
+		}
+	}
+	editError := func() {
+		edit()
+		if st == state.GetError() {
+			st = shallowCopy(state.GetError())
+			limited.Error = st
+		}
+	}
+
+	if d := state.GetDescription(); len(d) > limit {
+		edit()
+		limited.Description = TruncateText(d, limit)
+		refs = append(refs, newPayloadRef("description", d))
+	}
+	var metadataCopied bool
+	for k, v := range state.GetMetadata() {
+		if len(v) <= limit {
+			continue
+		}
+		if edit(); !metadataCopied {
+			metadataCopied = true
+			limited.Metadata = make(map[string]string, len(state.GetMetadata()))
+			for k, v := range state.GetMetadata() {
+				limited.Metadata[k] = v
+			}
+		}
+		delete(limited.Metadata, k)
+		refs = append(refs, newPayloadRef("metadata."+k, v))
+	}
+	if m := st.GetMessage(); len(m) > limit {
+		editError()
+		st.Message = TruncateText(m, limit)
+		refs = append(refs, newPayloadRef("error.message", m))
+	}
+	var details []*anypb.Any
+	for i, d := range state.GetError().GetDetails() {
+		if len(d.GetValue()) <= limit {
+			details = append(details, d)
+			continue
+		}
+		editError()
+		refs = append(refs, newPayloadRef(fmt.Sprintf("error.details[%d]", i), d.GetValue()))
+	}
+	if st != state.GetError() {
+		st.Details = details
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Field < refs[j].Field })
+	return limited, refs
+}
+
+// shallowCopy returns a copy of m sharing its fields, unlike proto.Clone.
+func shallowCopy[M proto.Message](m M) M {
+	c := m.ProtoReflect().New()
+	m.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		c.Set(fd, v)
+		return true
+	})
+	return c.Interface().(M)
+}
+
+// TruncateText returns s if it is at most limit bytes long, and otherwise its prefix of
+// limit bytes, cut at a rune boundary, followed by the size and the hash of s.
+func TruncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && cut < len(s) && !isRuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (truncated, %d bytes, sha256 %s)", s[:cut], len(s), sha256Hex(s))
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
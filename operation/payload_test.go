@@ -0,0 +1,139 @@
+package operation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const largePayloadSize = 10 << 20
+
+// largePayloadOperation returns a failed operation with a metadata value and an error
+// detail of size bytes each.
+func largePayloadOperation(t testing.TB, size int) *Operation {
+	large, err := anypb.New(&errdetails.DebugInfo{Detail: strings.Repeat("d", size)})
+	require.NoError(t, err)
+	small, err := anypb.New(&errdetails.ErrorInfo{Reason: "QUOTA"})
+	require.NoError(t, err)
+	return New(nil, &Proto{
+		Id:          "dtj1",
+		Description: strings.Repeat("x", size),
+		Status:      doublecloud.Operation_STATUS_DONE,
+		Metadata:    map[string]string{"transfer_id": "dtt1", "report": strings.Repeat("r", size)},
+		Error: &rpcstatus.Status{
+			Code:    int32(code.Code_INTERNAL),
+			Message: strings.Repeat("m", size),
+			Details: []*anypb.Any{small, large},
+		},
+	})
+}
+
+func TestMarshalJSON_PayloadLimit(t *testing.T) {
+	op := largePayloadOperation(t, 1<<20)
+	state := op.Proto()
+	data, err := json.Marshal(op)
+	require.NoError(t, err)
+	assert.Less(t, len(data), 4*DefaultPayloadLimit)
+
+	var v operationJSON
+	require.NoError(t, json.Unmarshal(data, &v))
+	assert.Equal(t, []PayloadRef{
+		{Field: "description", Size: 1 << 20, SHA256: sha256Hex(state.GetDescription())},
+		{Field: "error.details[1]", Size: len(state.GetError().GetDetails()[1].GetValue()), SHA256: sha256Hex(state.GetError().GetDetails()[1].GetValue())},
+		{Field: "error.message", Size: 1 << 20, SHA256: sha256Hex(state.GetError().GetMessage())},
+		{Field: "metadata.report", Size: 1 << 20, SHA256: sha256Hex(strings.Repeat("r", 1<<20))},
+	}, v.Omitted)
+
+	back := roundTrip(t, op)
+	assert.Equal(t, map[string]string{"transfer_id": "dtt1"}, back.Metadata())
+	require.Len(t, back.Proto().GetError().GetDetails(), 1)
+	assert.True(t, back.Proto().GetError().GetDetails()[0].MessageIs(&errdetails.ErrorInfo{}))
+	assert.Equal(t, TruncateText(state.GetError().GetMessage(), DefaultPayloadLimit), back.Proto().GetError().GetMessage())
+	assert.Equal(t, TruncateText(state.GetDescription(), DefaultPayloadLimit), back.Description())
+	assert.Equal(t, int32(code.Code_INTERNAL), back.Proto().GetError().GetCode())
+
+	assert.Len(t, op.Metadata(), 2, "the state is not modified")
+	assert.Len(t, op.Proto().GetError().GetDetails(), 2)
+	assert.Len(t, op.Description(), 1<<20)
+}
+
+func TestMarshalJSON_PayloadLimitOption(t *testing.T) {
+	op := New(nil, &Proto{Id: "dtj1", Metadata: map[string]string{"a": "12345", "b": "123"}})
+	back := roundTrip(t, op)
+	assert.Len(t, back.Metadata(), 2, "small payloads are kept")
+
+	back = roundTrip(t, op.WithPayloadLimit(4))
+	assert.Equal(t, map[string]string{"b": "123"}, back.Metadata())
+	assert.Zero(t, back.payloadLimit, "the limit is not encoded")
+
+	back = roundTrip(t, op.WithPayloadLimit(-1))
+	assert.Len(t, back.Metadata(), 2, "a negative limit keeps all the payloads")
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "short", TruncateText("short", 5))
+	long := strings.Repeat("a", 20)
+	assert.Equal(t, "aaaaa... (truncated, 20 bytes, sha256 "+sha256Hex(long)+")", TruncateText(long, 5))
+
+	s := TruncateText("aaaa€€", 5)
+	assert.True(t, strings.HasPrefix(s, "aaaa... "), s)
+	assert.True(t, utf8.ValidString(s), "the text is cut at a rune boundary")
+}
+
+func TestSnapshot_Truncated(t *testing.T) {
+	op := largePayloadOperation(t, 1<<20)
+	s := op.Snapshot()
+	assert.Less(t, len(s.Description), TextLimit+256)
+	assert.Less(t, len(s.Error), TextLimit+256)
+	assert.Contains(t, s.Description, "truncated, 1048576 bytes")
+
+	op = New(nil, &Proto{Id: "kfo1", Description: "Create cluster"})
+	assert.Equal(t, "Create cluster", op.Snapshot().Description)
+}
+
+func TestString_Truncated(t *testing.T) {
+	op := New(nil, &Proto{Id: "kfo1"}).WithOrigin("CreateCluster", strings.Repeat("c", 1<<20))
+	assert.Less(t, len(op.String()), TextLimit+256)
+	assert.Equal(t, "operation (id=kfo1, origin=CreateCluster, resource=kfc1)", New(nil, &Proto{Id: "kfo1"}).WithOrigin("CreateCluster", "kfc1").String())
+}
+
+func BenchmarkMarshalJSON_LargePayload(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		limit int
+	}{
+		{"unlimited", -1},
+		{"limited", 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			op := largePayloadOperation(b, largePayloadSize).WithPayloadLimit(bc.limit)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := op.MarshalJSON(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSnapshot_LargePayload(b *testing.B) {
+	op := largePayloadOperation(b, largePayloadSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = op.Snapshot()
+		_ = op.String()
+		_ = op.Metadata()
+	}
+}
@@ -0,0 +1,54 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// PollFunc gets the current state of the operation with the ID, e.g. through an HTTP/JSON
+// gateway. A positive interval replaces the poll interval before the next poll, like the
+// interval hint of gRPC polls.
+type PollFunc func(ctx context.Context, id string) (op *Proto, interval time.Duration, err error)
+
+// WithPollFunc makes Poll and waits get the operation state with poll instead of the operation
+// client, which may be nil then. Errors of poll are handled like those of gRPC polls, e.g. waits
+// retry NotFound and end on the fatal codes. Long-polling is not used.
+func WithPollFunc(poll PollFunc) grpc.CallOption {
+	return &pollFunc{poll: poll}
+}
+
+type pollFunc struct {
+	grpc.EmptyCallOption
+	poll PollFunc
+}
+
+func pollFuncOf(opts []grpc.CallOption) PollFunc {
+	var poll PollFunc
+	for _, o := range opts {
+		if o, ok := o.(*pollFunc); ok {
+			poll = o.poll
+		}
+	}
+	return poll
+}
+
+// pollWith polls the operation with the PollFunc and returns the interval it suggests.
+func (o *Operation) pollWith(ctx context.Context, poll PollFunc) (time.Duration, error) {
+	var state *Proto
+	var interval time.Duration
+	err := SafeCall("poll", func() (err error) {
+		state, interval, err = poll(ctx, o.Id())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if state == nil {
+		return 0, fmt.Errorf("%s poll func returned no operation", o)
+	}
+	o.state.store(state)
+	return interval, nil
+}
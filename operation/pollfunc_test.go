@@ -0,0 +1,83 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingTimer records the requested intervals of a wait, firing after a millisecond.
+type recordingTimer struct{ intervals []time.Duration }
+
+func (r *recordingTimer) newTimer(d time.Duration) (func() <-chan time.Time, func() bool) {
+	r.intervals = append(r.intervals, d)
+	return defaultTimer(time.Millisecond)
+}
+
+func TestWait_PollFunc(t *testing.T) {
+	var polls []string
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls = append(polls, id)
+		switch len(polls) {
+		case 1:
+			return nil, 0, status.Error(codes.NotFound, "not replicated yet")
+		case 2:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, 5 * time.Second, nil
+		case 3:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, 0, nil
+		default:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+		}
+	}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	timer := &recordingTimer{}
+	op.newTimer = timer.newTimer
+
+	require.NoError(t, op.WaitInterval(context.Background(), 2*time.Second, WithPollFunc(poll)))
+	assert.Equal(t, []string{"cho1", "cho1", "cho1", "cho1"}, polls)
+	assert.Equal(t, []time.Duration{2 * time.Second, 5 * time.Second, 2 * time.Second}, timer.intervals,
+		"the interval suggested by the poll func replaces the poll interval")
+	assert.True(t, op.Ok())
+}
+
+func TestWait_PollFuncErrors(t *testing.T) {
+	requireKinds(t)
+	ctx := context.Background()
+	pending := func() *Operation {
+		op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = fastTimer
+		return op
+	}
+
+	assert.ErrorIs(t, pending().Wait(ctx), ErrNoOperationClient, "the client is required without a poll func")
+
+	err := pending().Wait(ctx, WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		return nil, 0, status.Error(codes.PermissionDenied, "denied")
+	}))
+	assert.ErrorIs(t, err, ErrFatalPoll)
+
+	err = pending().Wait(ctx, WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		return nil, 0, nil
+	}))
+	assert.EqualError(t, err, "operation (id=cho1) poll fail: operation (id=cho1) poll func returned no operation")
+
+	captureWarnings(t)
+	err = pending().Wait(ctx, WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		panic("boom")
+	}))
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+}
+
+func TestPoll_PollFunc(t *testing.T) {
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Poll(context.Background(), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+	})))
+	assert.True(t, op.Done())
+}
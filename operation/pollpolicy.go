@@ -0,0 +1,158 @@
+package operation
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// PollPolicy is a polling policy built once and shared by the pollers of the SDK: the waits of
+// operations, see Operation.WithPollPolicy and WaitConfig, the verification of WaitAndVerify,
+// the retries of failed pages, see paging.WithRetryPolicy, and the status feed of the SDK.
+// The zero fields keep the defaults of every poller, and the options given to a poller take
+// precedence over the policy.
+type PollPolicy struct {
+	// Interval is the interval between polls, the first one if Backoff grows it.
+	Interval time.Duration
+	// Backoff grows the interval after every poll, see BackoffPolicy.Multiplier. The interval
+	// is fixed with a Backoff of at most 1.
+	Backoff float64
+	// Jitter randomizes every interval by up to the fraction of it either way, see
+	// BackoffPolicy.Jitter.
+	Jitter float64
+	// MaxInterval bounds the interval grown by Backoff, before jitter.
+	MaxInterval time.Duration
+	// Budgets bound the retries of the failed polls.
+	Budgets PollBudgets
+	// FatalCodes replace DefaultFatalCodes, see FatalCodes.
+	FatalCodes []codes.Code
+	// Clock is the time source of the pollers, see WithClock.
+	Clock Clock
+	// Limiter, if set, limits the rate of the polls of the waits with the policy, shared
+	// by all of them, see WithPollLimiter.
+	Limiter PollLimiter
+}
+
+// PollBudgets bound the retries of the failed polls of a PollPolicy.
+type PollBudgets struct {
+	// Retries bounds the failed polls in a row that are retried, see
+	// RetryPolicy.MaxConsecutiveFailures. Zero keeps the default of the poller, and a
+	// negative number turns the retries off.
+	Retries int
+	// AttemptTimeout bounds every poll, if positive, see RetryPolicy.AttemptTimeout.
+	AttemptTimeout time.Duration
+}
+
+// WaitConfig returns the config of ForwardProgress waiting with the policy.
+func (p PollPolicy) WaitConfig() WaitConfig {
+	return WaitConfig{Interval: p.Interval, Options: p.Options()}
+}
+
+// Options returns the options of the waits with the policy, e.g. to pass to WaitAll. Options
+// given after them take precedence.
+func (p PollPolicy) Options() []grpc.CallOption {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return p.options(interval)
+}
+
+// options returns the options of a wait polling every interval, the first one with Backoff.
+func (p PollPolicy) options(interval time.Duration) []grpc.CallOption {
+	var opts []grpc.CallOption
+	if p.Backoff > 1 || p.Jitter > 0 {
+		opts = append(opts, WithBackoff(BackoffPolicy{
+			InitialInterval: interval,
+			MaxInterval:     p.MaxInterval,
+			Multiplier:      p.Backoff,
+			Jitter:          p.Jitter,
+		}))
+	}
+	if p.Budgets != (PollBudgets{}) {
+		retries := DefaultRetryPolicy
+		retries.AttemptTimeout = p.Budgets.AttemptTimeout
+		if p.Budgets.Retries < 0 {
+			retries.MaxConsecutiveFailures = 0
+		} else if p.Budgets.Retries > 0 {
+			retries.MaxConsecutiveFailures = p.Budgets.Retries
+		}
+		opts = append(opts, WithRetryPolicy(retries))
+	}
+	if p.FatalCodes != nil {
+		opts = append(opts, FatalCodes(p.FatalCodes...))
+	}
+	if p.Clock != nil {
+		opts = append(opts, WithClock(p.Clock))
+	}
+	if p.Limiter != nil {
+		opts = append(opts, WithPollLimiter(p.Limiter))
+	}
+	return opts
+}
+
+// Delay returns the interval before the poll following the attempt-th one, 1-based, of the
+// pollers without options of their own: Interval, DefaultPollInterval if not positive, grown
+// by Backoff up to MaxInterval and jittered.
+func (p PollPolicy) Delay(attempt int) time.Duration {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if p.Backoff > 1 && attempt > 1 {
+		grown := float64(interval) * math.Pow(p.Backoff, float64(attempt-1))
+		if p.MaxInterval > 0 && grown > float64(p.MaxInterval) {
+			grown = float64(p.MaxInterval)
+		}
+		interval = time.Duration(grown)
+	} else if p.MaxInterval > 0 && interval > p.MaxInterval {
+		interval = p.MaxInterval
+	}
+	if j := p.Jitter; j > 0 {
+		interval = time.Duration(float64(interval) * (1 + j*(2*jitterFloat64()-1)))
+	}
+	return interval
+}
+
+// RetryDelay returns the Delay before the retry-th retry in a row of a failed call, 1-based,
+// and false once Budgets.Retries are exhausted. The retries are not bounded with zero
+// Budgets.Retries.
+func (p PollPolicy) RetryDelay(retry int) (time.Duration, bool) {
+	if p.Budgets.Retries < 0 || (p.Budgets.Retries > 0 && retry > p.Budgets.Retries) {
+		return 0, false
+	}
+	return p.Delay(retry), true
+}
+
+// Sleep waits for d with the Clock of the policy, SystemClock if it has none. It returns the
+// error of ctx if ctx is done first.
+func (p PollPolicy) Sleep(ctx context.Context, d time.Duration) error {
+	c := p.Clock
+	if c == nil {
+		c = SystemClock
+	}
+	t := c.NewTimer(d)
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
+
+// WithPollPolicy sets the policy of the waits of the operation, e.g. the one of
+// Config.PollPolicy of the SDK. The interval of WaitInterval and the options given to waits
+// take precedence over the policy.
+func (o *Operation) WithPollPolicy(p PollPolicy) *Operation {
+	o.pollPolicy = p
+	return o
+}
+
+// PollPolicy returns the policy set with WithPollPolicy.
+func (o *Operation) PollPolicy() PollPolicy {
+	return o.pollPolicy
+}
@@ -0,0 +1,176 @@
+package operation
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stepClock fires the timers shorter than an hour right away, recording their durations,
+// and the longer ones never, e.g. the verification timeout.
+type stepClock struct {
+	t         time.Time
+	intervals []time.Duration
+}
+
+func (c *stepClock) Now() time.Time { return c.t }
+
+func (c *stepClock) NewTimer(d time.Duration) Timer {
+	if d >= time.Hour {
+		return firedTimer{func() <-chan time.Time { return nil }, func() bool { return true }}
+	}
+	c.intervals = append(c.intervals, d)
+	c.t = c.t.Add(d)
+	fired := make(chan time.Time, 1)
+	fired <- c.t
+	return firedTimer{func() <-chan time.Time { return fired }, func() bool { return false }}
+}
+
+// pollsUntilDone returns a poll func of an operation done at the n-th poll, failing the polls
+// with the scripted codes first.
+func pollsUntilDone(n int, errs ...codes.Code) PollFunc {
+	var polls int
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		if polls <= len(errs) {
+			return nil, 0, status.Error(errs[polls-1], "scripted")
+		}
+		st := doublecloud.Operation_STATUS_RUNNING
+		if polls >= n+len(errs) {
+			st = doublecloud.Operation_STATUS_DONE
+		}
+		return &Proto{Id: id, Status: st}, 0, nil
+	}
+}
+
+func TestPollPolicy_Wait(t *testing.T) {
+	clock := &stepClock{}
+	policy := PollPolicy{Interval: 2 * time.Second, Backoff: 2, MaxInterval: 5 * time.Second, Clock: clock}
+	pending := func() *Operation {
+		clock.intervals = nil
+		return New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}).WithPollPolicy(policy)
+	}
+	require.NoError(t, pending().Wait(context.Background(), WithPollFunc(pollsUntilDone(5))))
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, clock.intervals)
+
+	require.NoError(t, pending().WaitInterval(context.Background(), time.Second, WithPollFunc(pollsUntilDone(4))))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.intervals,
+		"the interval of WaitInterval replaces the one of the policy")
+
+	require.NoError(t, pending().Wait(context.Background(), WithBackoff(BackoffPolicy{InitialInterval: 3 * time.Second}), WithPollFunc(pollsUntilDone(3))))
+	assert.Equal(t, []time.Duration{3 * time.Second, 3 * time.Second}, clock.intervals, "the options of the wait take precedence")
+
+	other := &stepClock{}
+	require.NoError(t, pending().Wait(context.Background(), WithClock(other), WithPollFunc(pollsUntilDone(2))))
+	assert.Empty(t, clock.intervals)
+	assert.Equal(t, []time.Duration{2 * time.Second}, other.intervals)
+
+	plain := &stepClock{}
+	op := New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(plain), WithPollFunc(pollsUntilDone(3))))
+	assert.Equal(t, []time.Duration{DefaultPollInterval, DefaultPollInterval}, plain.intervals, "the defaults without a policy")
+}
+
+func TestPollPolicy_Budgets(t *testing.T) {
+	policy := PollPolicy{Budgets: PollBudgets{Retries: -1}, FatalCodes: []codes.Code{codes.NotFound}, Clock: &stepClock{}}
+	pending := func(p PollPolicy) *Operation {
+		return New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}).WithPollPolicy(p)
+	}
+
+	err := pending(policy).Wait(context.Background(), WithPollFunc(pollsUntilDone(1, codes.Unavailable)))
+	assert.ErrorIs(t, err, ErrPollRetriesExhausted, "the policy turns the retries off")
+	err = pending(policy).Wait(context.Background(), WithPollFunc(pollsUntilDone(1, codes.NotFound)))
+	assert.ErrorIs(t, err, ErrFatalPoll, "the fatal codes of the policy")
+
+	err = pending(policy).Wait(context.Background(), WithRetryPolicy(DefaultRetryPolicy), FatalCodes(), WithPollFunc(pollsUntilDone(1, codes.Unavailable, codes.NotFound)))
+	assert.NoError(t, err, "the options of the wait take precedence")
+
+	policy.Budgets.Retries = 5
+	err = pending(policy).Wait(context.Background(), WithPollFunc(pollsUntilDone(1, codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.Unavailable)))
+	assert.NoError(t, err, "4 failures in a row are within the 5 retries of the policy")
+}
+
+func TestPollPolicy_Options(t *testing.T) {
+	assert.Empty(t, PollPolicy{}.Options(), "the zero policy keeps the defaults")
+
+	clock := &stepClock{}
+	policy := PollPolicy{
+		Interval:    2 * time.Second,
+		Backoff:     1.5,
+		Jitter:      0.1,
+		MaxInterval: time.Minute,
+		Budgets:     PollBudgets{Retries: 7, AttemptTimeout: 10 * time.Second},
+		FatalCodes:  []codes.Code{codes.Unauthenticated},
+		Clock:       clock,
+	}
+	cfg := policy.WaitConfig()
+	assert.Equal(t, 2*time.Second, cfg.Interval)
+	require.Len(t, cfg.Options, 4)
+	assert.Equal(t, BackoffPolicy{InitialInterval: 2 * time.Second, MaxInterval: time.Minute, Multiplier: 1.5, Jitter: 0.1}, cfg.Options[0].(*backoffOption).policy)
+	assert.Equal(t, RetryPolicy{Codes: DefaultRetryPolicy.Codes, MaxConsecutiveFailures: 7, AttemptTimeout: 10 * time.Second}, retryPolicyOf(cfg.Options))
+	assert.Equal(t, map[codes.Code]bool{codes.Unauthenticated: true}, fatalCodesOf(cfg.Options))
+	assert.Same(t, clock, cfg.Options[3].(*clockOption).c)
+
+	backoff := PollPolicy{Backoff: 2}.Options()
+	require.Len(t, backoff, 1)
+	assert.Equal(t, DefaultPollInterval, backoff[0].(*backoffOption).policy.InitialInterval)
+}
+
+func TestPollPolicy_Delay(t *testing.T) {
+	jitterFloat64 = func() float64 { return 1 }
+	defer func() { jitterFloat64 = rand.Float64 }()
+
+	assert.Equal(t, DefaultPollInterval, PollPolicy{}.Delay(3))
+	policy := PollPolicy{Interval: time.Second, Backoff: 3, MaxInterval: 10 * time.Second}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 4; attempt++ {
+		delays = append(delays, policy.Delay(attempt))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 3 * time.Second, 9 * time.Second, 10 * time.Second}, delays)
+	policy.Jitter = 0.5
+	assert.Equal(t, 15*time.Second, policy.Delay(4), "jittered after the bound")
+
+	d, ok := PollPolicy{Interval: time.Second, Budgets: PollBudgets{Retries: 2}}.RetryDelay(2)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+	_, ok = PollPolicy{Budgets: PollBudgets{Retries: 2}}.RetryDelay(3)
+	assert.False(t, ok)
+	_, ok = PollPolicy{Budgets: PollBudgets{Retries: -1}}.RetryDelay(1)
+	assert.False(t, ok)
+	_, ok = PollPolicy{}.RetryDelay(100)
+	assert.True(t, ok, "not bounded without Retries")
+}
+
+func TestPollPolicy_Sleep(t *testing.T) {
+	clock := &stepClock{}
+	require.NoError(t, PollPolicy{Clock: clock}.Sleep(context.Background(), time.Minute))
+	assert.Equal(t, []time.Duration{time.Minute}, clock.intervals)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, PollPolicy{Clock: clock}.Sleep(ctx, 2*time.Hour), context.Canceled)
+}
+
+func TestWaitAndVerify_PollPolicy(t *testing.T) {
+	clock := &stepClock{}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE}).
+		WithPollPolicy(PollPolicy{Interval: time.Second, Backoff: 2, Clock: clock})
+	reads := 0
+	verify := func(ctx context.Context) (bool, error) {
+		reads++
+		return reads > 3, nil
+	}
+	require.NoError(t, WaitAndVerify(context.Background(), op, verify, VerifyConfig{Timeout: 2 * time.Hour}))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.intervals)
+
+	clock.intervals, reads = nil, 0
+	require.NoError(t, WaitAndVerify(context.Background(), op, verify, VerifyConfig{Interval: 5 * time.Second, Timeout: 2 * time.Hour}))
+	assert.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second}, clock.intervals, "the interval of the config takes precedence")
+}
@@ -0,0 +1,55 @@
+package operation
+
+import "context"
+
+// Principal is the user or the service account behind a principal ID, e.g. the creator of
+// an operation.
+type Principal struct {
+	ID string
+	// Name is the human-readable name, e.g. a login or the name of a service account.
+	Name string
+	// Kind is the kind of the principal, e.g. "user" or "service_account", if known.
+	Kind string
+	// Deleted marks the tombstones of the principals unknown to the lookup, e.g. deleted
+	// ones, see NewPrincipalTombstone.
+	Deleted bool
+}
+
+// NewPrincipalTombstone returns the tombstone of the principal of the ID.
+func NewPrincipalTombstone(id string) Principal {
+	return Principal{ID: id, Deleted: true}
+}
+
+// DisplayName returns the name of the principal, or a placeholder naming its ID.
+func (p Principal) DisplayName() string {
+	switch {
+	case p.Name != "":
+		return p.Name
+	case p.Deleted:
+		return "deleted principal " + p.ID
+	}
+	return p.ID
+}
+
+// PrincipalResolver resolves principal IDs, e.g. the SDK: every ID requested is in the
+// result, the unknown ones as tombstones.
+type PrincipalResolver interface {
+	ResolvePrincipals(ctx context.Context, ids []string) (map[string]Principal, error)
+}
+
+// CreatedByResolved returns the principal of CreatedBy resolved with r, the zero Principal
+// if the operation has no creator.
+func (o *Operation) CreatedByResolved(ctx context.Context, r PrincipalResolver) (Principal, error) {
+	id := o.CreatedBy()
+	if id == "" {
+		return Principal{}, nil
+	}
+	principals, err := r.ResolvePrincipals(ctx, []string{id})
+	if err != nil {
+		return Principal{}, err
+	}
+	if p, ok := principals[id]; ok {
+		return p, nil
+	}
+	return NewPrincipalTombstone(id), nil
+}
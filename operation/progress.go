@@ -0,0 +1,99 @@
+package operation
+
+import (
+	"context"
+	"time"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/doublecloud/go-sdk/pkg/retry"
+)
+
+// ProgressUpdate is the state of an operation after a poll, see ForwardProgress.
+type ProgressUpdate struct {
+	OperationID string
+	Status      dc.Operation_Status
+	// Elapsed is the time since ForwardProgress was called.
+	Elapsed time.Duration
+	// Attempt is the 1-based number of the poll, 0 for an operation done before the first poll.
+	Attempt int
+	// Metadata is a copy of the operation metadata, see package opmeta for the well-known keys.
+	Metadata map[string]string
+	// ServerDeadline is the completion hint of the operation, see Operation.ServerDeadline,
+	// zero if it has none.
+	ServerDeadline time.Time
+}
+
+// WaitConfig configures the wait of ForwardProgress.
+type WaitConfig struct {
+	// Interval between polls. Defaults to DefaultPollInterval.
+	Interval time.Duration
+	// Options are the options of the wait and of its polls, e.g. FatalCodes.
+	Options []grpc.CallOption
+}
+
+// forwardError carries the error of send through the wait. It doesn't unwrap, so that
+// status codes of send errors, e.g. of a closed stream, are not taken for poll failures.
+type forwardError struct{ err error }
+
+func (e *forwardError) Error() string { return "forward progress: " + e.err.Error() }
+
+// ForwardProgress waits for the operation, calling send with the state of the operation after
+// every poll, e.g. to stream the progress of the wait to the clients of a gRPC server.
+// If send fails, the wait ends with its error. Otherwise the result is that of the wait.
+//
+// send is called from the wait loop, so slow sends delay the next poll instead of letting
+// polls pile up, and there is nothing left running when ForwardProgress returns.
+func ForwardProgress(ctx context.Context, op *Operation, send func(ProgressUpdate) error, cfg WaitConfig) error {
+	started := time.Now()
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	var attempt int
+	var sendErr error
+	forward := func() error {
+		update := ProgressUpdate{
+			OperationID: op.Id(),
+			Status:      op.Proto().GetStatus(),
+			Elapsed:     time.Since(started),
+			Attempt:     attempt,
+			Metadata:    make(map[string]string, len(op.Metadata())),
+		}
+		for k, v := range op.Metadata() {
+			update.Metadata[k] = v
+		}
+		update.ServerDeadline, _ = op.ServerDeadline()
+		sendErr = SafeCall("progress send", func() error { return send(update) })
+		return sendErr
+	}
+	if op.Done() {
+		if err := forward(); err != nil {
+			return err
+		}
+	}
+
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		if sendErr != nil {
+			return nil, 0, &forwardError{sendErr}
+		}
+		var headers metadata.MD
+		opts := append(append([]grpc.CallOption{retry.Disable()}, cfg.Options...), grpc.Header(&headers))
+		if err := op.Poll(ctx, opts...); err != nil {
+			return nil, 0, err
+		}
+		attempt++
+		if err := forward(); err != nil {
+			return nil, 0, &forwardError{err}
+		}
+		hint, _ := op.intervalHint(headers, cfg.Options)
+		return op.Proto(), hint, nil
+	}
+	err := op.WaitInterval(ctx, interval, append(cfg.Options[:len(cfg.Options):len(cfg.Options)], WithPollFunc(poll))...)
+	if sendErr != nil {
+		return sendErr
+	}
+	return err
+}
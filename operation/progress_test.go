@@ -0,0 +1,102 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func progressingClient(polls int) *fakeKafkaClient {
+	return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		st := doublecloud.Operation_STATUS_RUNNING
+		if n >= polls {
+			st = doublecloud.Operation_STATUS_DONE
+		}
+		return &Proto{Id: id, Status: st, Metadata: map[string]string{"cluster_id": "kfc1"}}, nil
+	}}
+}
+
+func TestForwardProgress(t *testing.T) {
+	requireKinds(t)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	op := New(progressingClient(3), &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	var updates []ProgressUpdate
+	err := ForwardProgress(context.Background(), op, func(u ProgressUpdate) error {
+		updates = append(updates, u)
+		return nil
+	}, WaitConfig{})
+	require.NoError(t, err)
+
+	require.Len(t, updates, 3)
+	for i, u := range updates {
+		assert.Equal(t, i+1, u.Attempt)
+		assert.Equal(t, "kfo1", u.OperationID)
+		assert.Equal(t, map[string]string{"cluster_id": "kfc1"}, u.Metadata)
+	}
+	assert.Equal(t, doublecloud.Operation_STATUS_RUNNING, updates[1].Status)
+	assert.Equal(t, doublecloud.Operation_STATUS_DONE, updates[2].Status)
+	assert.GreaterOrEqual(t, updates[2].Elapsed, updates[1].Elapsed)
+}
+
+func TestForwardProgress_SlowSend(t *testing.T) {
+	requireKinds(t)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	client := progressingClient(4)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	var sends int
+	err := ForwardProgress(context.Background(), op, func(u ProgressUpdate) error {
+		sends++
+		assert.Equal(t, sends, client.calls(), "no polls are made while send blocks")
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, WaitConfig{Interval: time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, 4, sends)
+	assert.Equal(t, 4, client.calls())
+}
+
+func TestForwardProgress_SendError(t *testing.T) {
+	requireKinds(t)
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	client := progressingClient(10)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	// A stream error with a code the wait would otherwise retry.
+	streamErr := status.Error(codes.NotFound, "client went away")
+	err := ForwardProgress(context.Background(), op, func(u ProgressUpdate) error {
+		if u.Attempt == 2 {
+			return streamErr
+		}
+		return nil
+	}, WaitConfig{})
+	assert.Equal(t, streamErr, err)
+	assert.Equal(t, 2, client.calls(), "the wait ends on the failed send")
+
+	captureWarnings(t)
+	err = ForwardProgress(context.Background(), op, func(u ProgressUpdate) error { panic("boom") }, WaitConfig{})
+	assert.ErrorIs(t, err, ErrCallbackPanicked)
+}
+
+func TestForwardProgress_Done(t *testing.T) {
+	client := progressingClient(1)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_DONE})
+	var updates []ProgressUpdate
+	require.NoError(t, ForwardProgress(context.Background(), op, func(u ProgressUpdate) error {
+		updates = append(updates, u)
+		return nil
+	}, WaitConfig{}))
+	require.Len(t, updates, 1)
+	assert.Equal(t, 0, updates[0].Attempt)
+	assert.Zero(t, client.calls())
+}
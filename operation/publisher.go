@@ -0,0 +1,300 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
+)
+
+const (
+	DefaultPublisherBufferSize  = 1024
+	DefaultPublishMaxAttempts   = 5
+	DefaultPublishInitialDelay  = 100 * time.Millisecond
+	DefaultPublishMaxRetryDelay = 10 * time.Second
+)
+
+var (
+	// ErrPublisherOverflow is passed to the dead-letter callback for events dropped
+	// because the buffer was full.
+	ErrPublisherOverflow = errors.New("operation: publisher buffer overflow")
+	// ErrPublisherClosed is passed to the dead-letter callback for events left in the buffer
+	// when Close gave up waiting for them.
+	ErrPublisherClosed = errors.New("operation: publisher closed")
+)
+
+// Event describes a completed operation.
+type Event struct {
+	OperationID string
+	// Kind is the service of the operation: clickhouse, kafka, transfer or network.
+	Kind       string
+	ProjectID  string
+	ResourceID string
+	// ConsoleURL is the console page of the resource, if the operation has a ConsoleURLFunc,
+	// see Operation.ConsoleURL.
+	ConsoleURL string
+	// Failed reports whether the operation completed with an error.
+	Failed bool
+	// ErrorCode and ErrorMessage summarize the error of a failed operation.
+	ErrorCode    codes.Code
+	ErrorMessage string
+	// FinishedAt is the completion time of the operation, and Duration the time between its
+	// creation and completion, if known.
+	FinishedAt time.Time
+	Duration   time.Duration
+}
+
+// NewEvent describes the completed operation.
+func NewEvent(o *Operation) Event {
+	state := o.Proto()
+	e := Event{
+		OperationID: o.Id(),
+		Kind:        kindOf(o.Id()),
+		ProjectID:   state.GetProjectId(),
+		ResourceID:  o.ResourceId(),
+		Failed:      o.Failed(),
+	}
+	if u, err := o.ConsoleURL(); err == nil {
+		e.ConsoleURL = u
+	}
+	if st := o.ErrorStatus(); st != nil {
+		e.ErrorCode, e.ErrorMessage = st.Code(), st.Message()
+	}
+	if finished := state.GetFinishTime(); finished != nil {
+		e.FinishedAt = finished.AsTime()
+		if created := state.GetCreateTime(); created != nil {
+			e.Duration = e.FinishedAt.Sub(created.AsTime())
+		}
+	}
+	return e
+}
+
+func kindOf(id string) string {
+	if kind := operationKindOf(id); kind != nil {
+		return kind.name
+	}
+	return ""
+}
+
+// OverflowPolicy chooses the event dropped when the publisher buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the event being enqueued.
+	DropNewest OverflowPolicy = iota
+	// DropOldest drops the oldest buffered event to make room for the new one.
+	DropOldest
+)
+
+// PublisherConfig configures a Publisher. Only Publish is required.
+type PublisherConfig struct {
+	// Publish delivers an event, e.g. to a queue or a webhook. It is called from a single
+	// goroutine and retried until it succeeds or MaxAttempts is reached.
+	Publish func(ctx context.Context, e Event) error
+	// BufferSize bounds the number of events waiting for publication.
+	// Defaults to DefaultPublisherBufferSize.
+	BufferSize int
+	// Overflow chooses the event dropped when the buffer is full. Defaults to DropNewest.
+	Overflow OverflowPolicy
+	// MaxAttempts bounds Publish calls per event. Defaults to DefaultPublishMaxAttempts.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry, doubled for every next one up to
+	// MaxRetryDelay. Defaults to DefaultPublishInitialDelay and DefaultPublishMaxRetryDelay.
+	InitialDelay  time.Duration
+	MaxRetryDelay time.Duration
+	// DeadLetter, if set, is called with events that were not published: after MaxAttempts
+	// failures with the last error, on overflow with ErrPublisherOverflow and on Close
+	// with ErrPublisherClosed.
+	DeadLetter func(e Event, err error)
+}
+
+// Publisher publishes events of completed operations in the background.
+//
+// Every enqueued event is either published at least once or passed to the dead-letter
+// callback. Enqueueing never blocks: the buffer is bounded and the overflow policy decides
+// what to drop when it is full.
+type Publisher struct {
+	conf     PublisherConfig
+	newTimer func(time.Duration) (func() <-chan time.Time, func() bool)
+
+	mu     sync.Mutex
+	queue  []Event
+	closed bool
+	wake   chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewPublisher(conf PublisherConfig) *Publisher {
+	if conf.Publish == nil {
+		panic("nil Publish")
+	}
+	if conf.BufferSize <= 0 {
+		conf.BufferSize = DefaultPublisherBufferSize
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = DefaultPublishMaxAttempts
+	}
+	if conf.InitialDelay <= 0 {
+		conf.InitialDelay = DefaultPublishInitialDelay
+	}
+	if conf.MaxRetryDelay <= 0 {
+		conf.MaxRetryDelay = DefaultPublishMaxRetryDelay
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Publisher{
+		conf:     conf,
+		newTimer: defaultTimer,
+		wake:     make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Enqueue queues the event of the completed operation for publication.
+// It reports false if the operation is not done or the event was dropped.
+func (p *Publisher) Enqueue(o *Operation) bool {
+	if !o.Done() {
+		return false
+	}
+	return p.EnqueueEvent(NewEvent(o))
+}
+
+// EnqueueEvent queues the event for publication. It reports false if the event was dropped.
+func (p *Publisher) EnqueueEvent(e Event) bool {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.deadLetter(e, ErrPublisherClosed)
+		return false
+	}
+	var dropped *Event
+	if len(p.queue) >= p.conf.BufferSize {
+		if p.conf.Overflow != DropOldest {
+			p.mu.Unlock()
+			p.deadLetter(e, ErrPublisherOverflow)
+			return false
+		}
+		oldest := p.queue[0]
+		dropped = &oldest
+		p.queue = p.queue[1:]
+	}
+	p.queue = append(p.queue, e)
+	p.mu.Unlock()
+
+	if dropped != nil {
+		p.deadLetter(*dropped, ErrPublisherOverflow)
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Wait waits for the operation, like Operation.Wait, and enqueues its event
+// if the operation completed.
+func (p *Publisher) Wait(ctx context.Context, o *Operation, opts ...grpc.CallOption) error {
+	err := o.Wait(ctx, opts...)
+	p.Enqueue(o)
+	return err
+}
+
+// Close stops accepting events and waits until the buffered ones are published.
+// If ctx is done first, the rest are passed to the dead-letter callback
+// and ctx.Err() is returned.
+func (p *Publisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	defer p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-p.done
+		return ctx.Err()
+	}
+}
+
+// CloseWhenDone closes the publisher once ctx is done, like Close with ctx: the events not
+// published by then are passed to the dead-letter callback. Calling stop stops it; it
+// reports false if the close was started already.
+func (p *Publisher) CloseWhenDone(ctx context.Context) (stop func() bool) {
+	return lifecycle.AfterFunc(ctx, func() { _ = p.Close(ctx) })
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 {
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-p.wake:
+			case <-p.ctx.Done():
+			}
+			continue
+		}
+		e := p.queue[0]
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+
+		if p.ctx.Err() != nil {
+			p.deadLetter(e, ErrPublisherClosed)
+			continue
+		}
+		if err := p.publish(e); err != nil {
+			p.deadLetter(e, err)
+		}
+	}
+}
+
+func (p *Publisher) publish(e Event) error {
+	delay := p.conf.InitialDelay
+	for attempt := 1; ; attempt++ {
+		err := SafeCall("publish", func() error { return p.conf.Publish(p.ctx, e) })
+		if err == nil || attempt == p.conf.MaxAttempts {
+			return err
+		}
+		wait, stop := p.newTimer(delay)
+		select {
+		case <-wait():
+		case <-p.ctx.Done():
+			stop()
+			return err
+		}
+		if delay *= 2; delay > p.conf.MaxRetryDelay {
+			delay = p.conf.MaxRetryDelay
+		}
+	}
+}
+
+func (p *Publisher) deadLetter(e Event, err error) {
+	if p.conf.DeadLetter != nil {
+		_ = SafeCall("dead-letter", func() error {
+			p.conf.DeadLetter(e, err)
+			return nil
+		})
+	}
+}
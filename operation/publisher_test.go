@@ -0,0 +1,234 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// recorder records published and dead-lettered events.
+type recorder struct {
+	mu        sync.Mutex
+	published []string
+	dead      map[string]error
+}
+
+func (r *recorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.published = append(r.published, e.OperationID)
+}
+
+func (r *recorder) deadLetter(e Event, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dead == nil {
+		r.dead = map[string]error{}
+	}
+	r.dead[e.OperationID] = err
+}
+
+func (r *recorder) snapshot() ([]string, map[string]error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dead := map[string]error{}
+	for k, v := range r.dead {
+		dead[k] = v
+	}
+	return append([]string(nil), r.published...), dead
+}
+
+func doneOp(id string) *Operation {
+	return New(nil, &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE})
+}
+
+func TestNewEvent(t *testing.T) {
+	requireKinds(t)
+	created := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	op := New(nil, &Proto{
+		Id:         "kfo1",
+		ResourceId: "kfc1",
+		Status:     doublecloud.Operation_STATUS_DONE,
+		CreateTime: timestamppb.New(created),
+		FinishTime: timestamppb.New(created.Add(90 * time.Second)),
+		Error:      &rpcstatus.Status{Code: int32(code.Code_RESOURCE_EXHAUSTED), Message: "quota"},
+	})
+	assert.Equal(t, Event{
+		OperationID:  "kfo1",
+		Kind:         "kafka",
+		ResourceID:   "kfc1",
+		Failed:       true,
+		ErrorCode:    codes.ResourceExhausted,
+		ErrorMessage: "quota",
+		FinishedAt:   created.Add(90 * time.Second),
+		Duration:     90 * time.Second,
+	}, NewEvent(op))
+}
+
+func TestPublisher_Retry(t *testing.T) {
+	var rec recorder
+	attempts := map[string]int{}
+	p := NewPublisher(PublisherConfig{
+		Publish: func(ctx context.Context, e Event) error {
+			attempts[e.OperationID]++
+			if e.OperationID == "cho1" && attempts[e.OperationID] < 3 {
+				return errors.New("broker unavailable")
+			}
+			if e.OperationID == "cho2" {
+				return errors.New("message too large")
+			}
+			rec.record(e)
+			return nil
+		},
+		MaxAttempts:  4,
+		InitialDelay: time.Millisecond,
+		DeadLetter:   rec.deadLetter,
+	})
+
+	assert.True(t, p.Enqueue(doneOp("cho1")))
+	assert.True(t, p.Enqueue(doneOp("cho2")))
+	assert.True(t, p.Enqueue(doneOp("cho3")))
+	require.NoError(t, p.Close(context.Background()))
+
+	published, dead := rec.snapshot()
+	assert.Equal(t, []string{"cho1", "cho3"}, published)
+	assert.Equal(t, map[string]error{"cho2": errors.New("message too large")}, dead)
+	assert.Equal(t, map[string]int{"cho1": 3, "cho2": 4, "cho3": 1}, attempts)
+}
+
+// blockingPublisher returns a publisher stuck on publishing the first event until release is closed.
+func blockingPublisher(t *testing.T, rec *recorder, overflow OverflowPolicy) (p *Publisher, release func()) {
+	started, unblock := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	p = NewPublisher(PublisherConfig{
+		Publish: func(ctx context.Context, e Event) error {
+			once.Do(func() {
+				close(started)
+				<-unblock
+			})
+			rec.record(e)
+			return nil
+		},
+		BufferSize: 2,
+		Overflow:   overflow,
+		DeadLetter: rec.deadLetter,
+	})
+	require.True(t, p.Enqueue(doneOp("cho0")))
+	<-started
+	return p, func() { close(unblock) }
+}
+
+func TestPublisher_Overflow(t *testing.T) {
+	t.Run("drop newest", func(t *testing.T) {
+		var rec recorder
+		p, release := blockingPublisher(t, &rec, DropNewest)
+		assert.True(t, p.Enqueue(doneOp("cho1")))
+		assert.True(t, p.Enqueue(doneOp("cho2")))
+		assert.False(t, p.Enqueue(doneOp("cho3")))
+		release()
+		require.NoError(t, p.Close(context.Background()))
+
+		published, dead := rec.snapshot()
+		assert.Equal(t, []string{"cho0", "cho1", "cho2"}, published)
+		assert.Equal(t, map[string]error{"cho3": ErrPublisherOverflow}, dead)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		var rec recorder
+		p, release := blockingPublisher(t, &rec, DropOldest)
+		assert.True(t, p.Enqueue(doneOp("cho1")))
+		assert.True(t, p.Enqueue(doneOp("cho2")))
+		assert.True(t, p.Enqueue(doneOp("cho3")))
+		release()
+		require.NoError(t, p.Close(context.Background()))
+
+		published, dead := rec.snapshot()
+		assert.Equal(t, []string{"cho0", "cho2", "cho3"}, published)
+		assert.Equal(t, map[string]error{"cho1": ErrPublisherOverflow}, dead)
+	})
+}
+
+func TestPublisher_WaitDoesNotBlock(t *testing.T) {
+	requireKinds(t)
+	var rec recorder
+	p, release := blockingPublisher(t, &rec, DropNewest)
+	defer release()
+
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+	for _, id := range []string{"kfo1", "kfo2", "kfo3"} {
+		op := New(client, &Proto{Id: id, Status: doublecloud.Operation_STATUS_PENDING})
+		require.NoError(t, p.Wait(context.Background(), op))
+	}
+	_, dead := rec.snapshot()
+	assert.Equal(t, map[string]error{"kfo3": ErrPublisherOverflow}, dead)
+	assert.False(t, p.Enqueue(New(nil, &Proto{Id: "kfo4", Status: doublecloud.Operation_STATUS_PENDING})), "pending operations are not published")
+}
+
+func TestPublisher_CloseTimeout(t *testing.T) {
+	var rec recorder
+	p := NewPublisher(PublisherConfig{
+		Publish: func(ctx context.Context, e Event) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		MaxAttempts: 1,
+		DeadLetter:  rec.deadLetter,
+	})
+	p.Enqueue(doneOp("cho1"))
+	p.Enqueue(doneOp("cho2"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Close(ctx), context.DeadlineExceeded)
+	assert.False(t, p.Enqueue(doneOp("cho3")))
+
+	published, dead := rec.snapshot()
+	assert.Empty(t, published)
+	assert.Equal(t, map[string]error{
+		"cho1": context.Canceled,
+		"cho2": ErrPublisherClosed,
+		"cho3": ErrPublisherClosed,
+	}, dead)
+}
+
+func TestPublisher_CloseWhenDone(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var rec recorder
+	p := NewPublisher(PublisherConfig{
+		Publish: func(ctx context.Context, e Event) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		MaxAttempts: 1,
+		DeadLetter:  rec.deadLetter,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	p.CloseWhenDone(ctx)
+	p.Enqueue(doneOp("cho1"))
+	cancel()
+	require.Eventually(t, func() bool { return !p.Enqueue(doneOp("cho2")) }, 5*time.Second, time.Millisecond, "closed once ctx is done")
+	<-p.done
+	_, dead := rec.snapshot()
+	assert.Contains(t, dead, "cho1")
+
+	stopped := NewPublisher(PublisherConfig{Publish: func(ctx context.Context, e Event) error { return nil }})
+	ctx, cancel = context.WithCancel(context.Background())
+	assert.True(t, stopped.CloseWhenDone(ctx)())
+	cancel()
+	assert.True(t, stopped.Enqueue(doneOp("cho3")), "not closed once stopped")
+	require.NoError(t, stopped.Close(context.Background()))
+}
@@ -0,0 +1,126 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const DefaultResourceIDTimeout = 30 * time.Second
+
+// ErrResourceIDUnavailable is matched by errors.Is for every *ResourceIDUnavailableError.
+var ErrResourceIDUnavailable = errors.New("operation: resource id unavailable")
+
+// ResourceIDUnavailableError is returned by ResourceIdWait when the operation didn't report
+// the ID of its resource within the timeout, or completed without it.
+type ResourceIDUnavailableError struct {
+	Operation *Operation
+	Polls     int
+}
+
+func (e *ResourceIDUnavailableError) Error() string {
+	return fmt.Sprintf("%s has no resource id after %d polls", e.Operation, e.Polls)
+}
+
+func (e *ResourceIDUnavailableError) Is(target error) bool { return target == ErrResourceIDUnavailable }
+
+// ResourceIdWait returns the ID of the resource of the operation. Some services return the
+// operation of a Create call before it is enriched with the resource ID, so unless the ID is
+// set already, the operation is polled every DefaultPollInterval, or the interval suggested
+// by the server, until it reports the ID. Failed polls are retried until the timeout if the
+// RetryPolicy of the options retries them. The polls end early if the operation fails, with
+// its error, or completes without the ID. A non-positive timeout means
+// DefaultResourceIDTimeout; once it expires, *ResourceIDUnavailableError is returned.
+func (o *Operation) ResourceIdWait(ctx context.Context, timeout time.Duration, opts ...grpc.CallOption) (_ string, err error) {
+	clock, metrics := clockOf(o, opts), metricsOf(opts)
+	unavailable := &ResourceIDUnavailableError{Operation: o}
+	defer func(started time.Time) {
+		metrics.WaitFinished(o, err, unavailable.Polls, clock.now().Sub(started))
+	}(clock.now())
+	if id := o.ResourceId(); id != "" {
+		return id, nil
+	}
+	waitsStarted(1)
+	defer waitsFinished(1)
+	if timeout <= 0 {
+		timeout = DefaultResourceIDTimeout
+	}
+	deadline, stopDeadline := clock.newTimer(timeout)
+	defer stopDeadline()
+
+	policy := retryPolicyOf(opts)
+	var failures int
+	calls := callMetadataOf(opts)
+	pause := pauseControllerOf(opts)
+	limiter := pollLimiterOf(opts)
+	for {
+		if o.Failed() {
+			return "", operationError(o)
+		}
+		if o.Done() {
+			return "", unavailable
+		}
+
+		if err := pause.wait(ctx, o); err != nil {
+			return "", err
+		}
+		if err := limiter.wait(ctx, o); err != nil {
+			return "", err
+		}
+		interval := DefaultPollInterval
+		var headers metadata.MD
+		metrics.PollStarted(o)
+		limiter.polled()
+		pollStarted := clock.now()
+		pollCtx, err := withCallMetadata(ctx, calls, unavailable.Polls+1)
+		switch poll := pollFuncOf(opts); {
+		case err != nil:
+		case poll != nil:
+			var hinted time.Duration
+			if hinted, err = o.pollWith(pollCtx, poll); hinted > 0 {
+				interval = hinted
+			}
+			if err == nil {
+				o.state.setSuggested(hinted)
+			}
+		default:
+			if err = o.Poll(pollCtx, append(append([]grpc.CallOption(nil), opts...), grpc.Header(&headers), WithCallMetadata(nil))...); err == nil {
+				hint, _ := o.intervalHint(headers, opts)
+				o.state.setSuggested(hint)
+				if hint > 0 {
+					interval = hint
+				}
+			}
+		}
+		metrics.PollFinished(o, err, clock.now().Sub(pollStarted))
+		unavailable.Polls++
+		if err != nil && ctx.Err() != nil {
+			return "", sdkerrors.WithMessagef(ctx.Err(), "%s resource id wait context done", o)
+		}
+		if err == nil {
+			failures = 0
+		} else if failures++; !policy.retryable(ctx, err) {
+			return "", &PollError{Operation: o, Attempts: failures, Err: err}
+		}
+		if id := o.ResourceId(); err == nil && id != "" {
+			return id, nil
+		}
+
+		wait, stop := clock.newTimer(interval)
+		select {
+		case <-wait():
+		case <-deadline():
+			stop()
+			return "", unavailable
+		case <-ctx.Done():
+			stop()
+			return "", sdkerrors.WithMessagef(ctx.Err(), "%s resource id wait context done", o)
+		}
+	}
+}
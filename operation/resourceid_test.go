@@ -0,0 +1,134 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResourceIdWait_Set(t *testing.T) {
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		t.Fatal("unexpected poll")
+		return nil, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", ResourceId: "kf1", Status: doublecloud.Operation_STATUS_PENDING})
+
+	id, err := op.ResourceIdWait(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "kf1", id)
+}
+
+func TestResourceIdWait_SecondGet(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		op := &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}
+		if n >= 2 {
+			op.ResourceId = "kf1"
+		}
+		return op, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastPollTimer
+
+	id, err := op.ResourceIdWait(context.Background(), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "kf1", id)
+	assert.Equal(t, 2, client.calls())
+	assert.False(t, op.Done(), "the operation is not waited for")
+}
+
+func TestResourceIdWait_NotFoundRetried(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		if n == 1 {
+			return nil, status.Error(codes.NotFound, "not replicated yet")
+		}
+		return &Proto{Id: id, ResourceId: "kf1", Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastPollTimer
+
+	id, err := op.ResourceIdWait(context.Background(), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "kf1", id)
+}
+
+func TestResourceIdWait_Timeout(t *testing.T) {
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+
+	_, err := op.ResourceIdWait(context.Background(), 20*time.Millisecond,
+		WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, time.Millisecond, nil
+		}))
+	require.ErrorIs(t, err, ErrResourceIDUnavailable)
+	var unavailable *ResourceIDUnavailableError
+	require.ErrorAs(t, err, &unavailable)
+	assert.Same(t, op, unavailable.Operation)
+	assert.Greater(t, unavailable.Polls, 1)
+}
+
+func TestResourceIdWait_Done(t *testing.T) {
+	requireKinds(t)
+	t.Run("completed without id", func(t *testing.T) {
+		client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		}}
+		op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = fastPollTimer
+
+		_, err := op.ResourceIdWait(context.Background(), time.Minute)
+		assert.ErrorIs(t, err, ErrResourceIDUnavailable)
+		assert.Equal(t, 1, client.calls())
+	})
+	t.Run("failed", func(t *testing.T) {
+		client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE, Error: &rpcstatus.Status{Code: int32(codes.AlreadyExists)}}, nil
+		}}
+		op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = fastPollTimer
+
+		_, err := op.ResourceIdWait(context.Background(), time.Minute)
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+	t.Run("poll error", func(t *testing.T) {
+		client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		}}
+		op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+
+		_, err := op.ResourceIdWait(context.Background(), time.Minute)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestResourceIdWait_ContextDone(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := op.ResourceIdWait(ctx, time.Minute)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrResourceIDUnavailable)
+}
+
+// fastPollTimer shortens the poll intervals only, unlike fastTimer, keeping the timeout.
+func fastPollTimer(d time.Duration) (func() <-chan time.Time, func() bool) {
+	if d <= DefaultPollInterval {
+		d = time.Millisecond
+	}
+	return defaultTimer(d)
+}
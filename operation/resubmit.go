@@ -0,0 +1,123 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/doublecloud/go-sdk/pkg/retry"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultResubmitBackoff is the delay between resubmissions if ResubmitPolicy.Backoff is zero.
+const DefaultResubmitBackoff = time.Second
+
+// resubmittable lists the error reasons, by operation kind, of invalid operations whose
+// request may be resubmitted. Nothing is resubmittable unless registered.
+var resubmittable = struct {
+	mu      sync.RWMutex
+	reasons map[string]map[string]bool
+}{reasons: map[string]map[string]bool{}}
+
+// RegisterResubmittable marks invalid operations of the kind, e.g. KindClickHouse, whose error
+// carries google.rpc.ErrorInfo with one of the reasons as resubmittable.
+func RegisterResubmittable(kind string, reasons ...string) error {
+	if kindByName(kind) == nil {
+		return fmt.Errorf("operation: unknown operation kind %q", kind)
+	}
+	resubmittable.mu.Lock()
+	defer resubmittable.mu.Unlock()
+	if resubmittable.reasons[kind] == nil {
+		resubmittable.reasons[kind] = map[string]bool{}
+	}
+	for _, r := range reasons {
+		resubmittable.reasons[kind][r] = true
+	}
+	return nil
+}
+
+// IsResubmittable reports whether the operation ended with STATUS_INVALID for one of the
+// reasons registered for its kind with RegisterResubmittable, so its request may be made again.
+func IsResubmittable(o *Operation) bool {
+	if o.Proto().GetStatus() != dc.Operation_STATUS_INVALID {
+		return false
+	}
+	kind := operationKindOf(o.Id())
+	st := o.ErrorStatus()
+	if kind == nil || st == nil {
+		return false
+	}
+	resubmittable.mu.RLock()
+	defer resubmittable.mu.RUnlock()
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && resubmittable.reasons[kind.name][info.GetReason()] {
+			return true
+		}
+	}
+	return false
+}
+
+// ResubmitPolicy configures Resubmit.
+type ResubmitPolicy struct {
+	// MaxAttempts is the number of requests made, including the first one. Values below 1 mean 1.
+	MaxAttempts int
+	// Backoff is the delay before every resubmission. Defaults to DefaultResubmitBackoff.
+	Backoff time.Duration
+}
+
+// ResubmitResult lists the operations started by Resubmit, one per attempt, in order.
+type ResubmitResult struct {
+	Operations []*Operation
+}
+
+// Last returns the operation of the last attempt, nil if no operation was started.
+func (r *ResubmitResult) Last() *Operation {
+	if len(r.Operations) == 0 {
+		return nil
+	}
+	return r.Operations[len(r.Operations)-1]
+}
+
+// Resubmit starts an operation with start and waits for it. While the operation ends
+// resubmittable (see IsResubmittable) and attempts remain, the request is made again after
+// the policy backoff. It returns the error of the last attempt together with every operation started.
+//
+// The requests of start are numbered together, see retry.WithAttemptNumbering: the
+// resubmissions and the retries of the request are sent with their number and the time of
+// the first request in their metadata, so that the server can tell them apart.
+func Resubmit(ctx context.Context, policy ResubmitPolicy, start func(ctx context.Context) (*Operation, error), opts ...grpc.CallOption) (*ResubmitResult, error) {
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = DefaultResubmitBackoff
+	}
+	res := &ResubmitResult{}
+	// The waits keep ctx, their polls aren't resubmissions.
+	startCtx := retry.WithAttemptNumbering(ctx)
+	for attempt := 1; ; attempt++ {
+		var op *Operation
+		err := SafeCall("resubmit start", func() (err error) {
+			op, err = start(startCtx)
+			return err
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Operations = append(res.Operations, op)
+		err = op.Wait(ctx, opts...)
+		if err == nil || attempt >= policy.MaxAttempts || !IsResubmittable(op) {
+			return res, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return res, sdkerrors.WithMessagef(ctx.Err(), "%s resubmit context done", op)
+		}
+	}
+}
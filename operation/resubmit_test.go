@@ -0,0 +1,130 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func loadOperation(t *testing.T, name string) *Operation {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	p := &Proto{}
+	require.NoError(t, protojson.Unmarshal(data, p))
+	return New(nil, p)
+}
+
+func registerResubmittable(t *testing.T, kind string, reasons ...string) {
+	require.NoError(t, RegisterResubmittable(kind, reasons...))
+	t.Cleanup(func() {
+		resubmittable.mu.Lock()
+		delete(resubmittable.reasons, kind)
+		resubmittable.mu.Unlock()
+	})
+}
+
+func TestIsResubmittable(t *testing.T) {
+	requireKinds(t)
+	fixtures := []string{
+		"clickhouse_create_cluster_invalid.json",
+		"clickhouse_create_cluster_failed.json",
+		"kafka_create_cluster_invalid.json",
+		"kafka_create_cluster_invalid_no_reason.json",
+	}
+	for _, f := range fixtures {
+		assert.False(t, IsResubmittable(loadOperation(t, f)), "%s is resubmittable by default", f)
+	}
+
+	registerResubmittable(t, KindClickHouse, "CAPACITY_EXHAUSTED")
+	for f, want := range map[string]bool{
+		"clickhouse_create_cluster_invalid.json":      true,
+		"clickhouse_create_cluster_failed.json":       false, // not invalid
+		"kafka_create_cluster_invalid.json":           false, // reason not registered for kafka
+		"kafka_create_cluster_invalid_no_reason.json": false,
+	} {
+		assert.Equal(t, want, IsResubmittable(loadOperation(t, f)), f)
+	}
+
+	assert.EqualError(t, RegisterResubmittable("airflow", "BUSY"), `operation: unknown operation kind "airflow"`)
+}
+
+// invalidOperation is an invalid kafka operation failed for the reason.
+func invalidOperation(id, reason string) *Proto {
+	st, err := status.New(codes.Unavailable, "try again").WithDetails(&errdetails.ErrorInfo{Reason: reason})
+	if err != nil {
+		panic(err)
+	}
+	return &Proto{Id: id, Status: doublecloud.Operation_STATUS_INVALID, Error: st.Proto()}
+}
+
+func TestResubmit(t *testing.T) {
+	requireKinds(t)
+	registerResubmittable(t, KindKafka, "NETWORK_BUSY")
+	ctx := context.Background()
+	// Every create starts a new operation; the first two end invalid.
+	run := func(policy ResubmitPolicy, reason string) (*ResubmitResult, error) {
+		client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+			if n < 3 {
+				return invalidOperation(id, reason), nil
+			}
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		}}
+		var creates int
+		return Resubmit(ctx, policy, func(ctx context.Context) (*Operation, error) {
+			creates++
+			op := New(client, &Proto{Id: fmt.Sprintf("kfo%d", creates), Status: doublecloud.Operation_STATUS_PENDING})
+			op.newTimer = fastTimer
+			return op, nil
+		})
+	}
+
+	res, err := run(ResubmitPolicy{MaxAttempts: 3, Backoff: time.Millisecond}, "NETWORK_BUSY")
+	require.NoError(t, err)
+	require.Len(t, res.Operations, 3)
+	assert.Equal(t, []string{"kfo1", "kfo2", "kfo3"}, []string{res.Operations[0].Id(), res.Operations[1].Id(), res.Operations[2].Id()})
+	assert.True(t, res.Last().Ok())
+	assert.True(t, IsResubmittable(res.Operations[0]))
+
+	res, err = run(ResubmitPolicy{MaxAttempts: 2, Backoff: time.Millisecond}, "NETWORK_BUSY")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Len(t, res.Operations, 2, "attempts are bounded")
+
+	res, err = run(ResubmitPolicy{MaxAttempts: 3, Backoff: time.Millisecond}, "BAD_REQUEST")
+	assert.Error(t, err)
+	assert.Len(t, res.Operations, 1, "unlisted reasons are not resubmitted")
+}
+
+func TestResubmit_StartError(t *testing.T) {
+	res, err := Resubmit(context.Background(), ResubmitPolicy{MaxAttempts: 3}, func(ctx context.Context) (*Operation, error) {
+		return nil, status.Error(codes.InvalidArgument, "bad request")
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, res.Operations)
+	assert.Nil(t, res.Last())
+}
+
+func TestResubmit_ContextDone(t *testing.T) {
+	requireKinds(t)
+	registerResubmittable(t, KindKafka, "NETWORK_BUSY")
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return invalidOperation(id, "NETWORK_BUSY"), nil
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res, err := Resubmit(ctx, ResubmitPolicy{MaxAttempts: 3, Backoff: time.Hour}, func(ctx context.Context) (*Operation, error) {
+		return pendingKafkaOp(client), nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, res.Operations, 1)
+}
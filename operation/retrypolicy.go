@@ -0,0 +1,160 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy configures the retries of the failed polls of waits, see WithRetryPolicy.
+type RetryPolicy struct {
+	// Codes are the codes of the poll errors that are retried.
+	Codes []codes.Code
+	// MaxConsecutiveFailures bounds the failed polls in a row that are retried. A successful
+	// poll starts over. At most 0 means no retries.
+	MaxConsecutiveFailures int
+	// AttemptTimeout bounds every poll, if positive. Polls running out of it are retried
+	// like those failing with one of Codes.
+	AttemptTimeout time.Duration
+}
+
+// DefaultRetryPolicy retries up to 3 failed polls in a row: the operation returned by a call
+// may not be on all replicas yet, and the connection to the API may drop.
+var DefaultRetryPolicy = RetryPolicy{
+	Codes:                  []codes.Code{codes.NotFound, codes.Unavailable},
+	MaxConsecutiveFailures: 3,
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy for the wait. The fatal codes, see FatalCodes,
+// end the wait even if they are in p.Codes.
+func WithRetryPolicy(p RetryPolicy) grpc.CallOption {
+	return &retryPolicy{policy: p}
+}
+
+type retryPolicy struct {
+	grpc.EmptyCallOption
+	policy RetryPolicy
+}
+
+// DefaultPollTimeout is the timeout of WithPollTimeout with a duration of at most 0.
+const DefaultPollTimeout = 30 * time.Second
+
+// WithPollTimeout bounds every poll of the wait by d, DefaultPollTimeout if d is at most 0,
+// so that a poll stuck on a dead connection fails fast and is retried like the polls failing
+// with one of RetryPolicy.Codes. It replaces RetryPolicy.AttemptTimeout. A poll running out
+// of d fails with *PollTimeoutError, while a poll cut short by the context of the wait fails
+// with the error of the context, as without the option.
+func WithPollTimeout(d time.Duration) grpc.CallOption {
+	if d <= 0 {
+		d = DefaultPollTimeout
+	}
+	return &pollTimeout{d: d}
+}
+
+type pollTimeout struct {
+	grpc.EmptyCallOption
+	d time.Duration
+}
+
+func retryPolicyOf(opts []grpc.CallOption) RetryPolicy {
+	p := DefaultRetryPolicy
+	var timeout time.Duration
+	for _, o := range opts {
+		switch o := o.(type) {
+		case *retryPolicy:
+			p = o.policy
+		case *pollTimeout:
+			timeout = o.d
+		}
+	}
+	if timeout > 0 {
+		p.AttemptTimeout = timeout
+	}
+	return p
+}
+
+// retryable reports whether the poll error err is retried by the policy. ctx is the context
+// of the wait, which timeouts of the attempt leave alive.
+func (p RetryPolicy) retryable(ctx context.Context, err error) bool {
+	timedOut := errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+	if p.AttemptTimeout > 0 && timedOut && ctx.Err() == nil {
+		return true
+	}
+	code := status.Code(err)
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// attempt returns the context of a poll, bounded by AttemptTimeout.
+func (p RetryPolicy) attempt(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.AttemptTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.AttemptTimeout)
+}
+
+// attemptError returns *PollTimeoutError if the poll of attemptCtx failed with err because
+// it ran out of AttemptTimeout while ctx, the context of the wait, is alive, err otherwise.
+// It must be called before attemptCtx is cancelled.
+func (p RetryPolicy) attemptError(ctx, attemptCtx context.Context, o *Operation, err error) error {
+	if err == nil || p.AttemptTimeout <= 0 || ctx.Err() != nil || attemptCtx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	return &PollTimeoutError{Operation: o, Timeout: p.AttemptTimeout, Err: err}
+}
+
+// ErrPollTimeout is matched by errors.Is for every *PollTimeoutError.
+var ErrPollTimeout = errors.New("operation: poll timed out")
+
+// PollTimeoutError is the error of a poll that ran out of its timeout, see WithPollTimeout,
+// while the context of the wait is alive. It has the status codes.DeadlineExceeded and
+// unwraps to the error of the poll.
+type PollTimeoutError struct {
+	Operation *Operation
+	Timeout   time.Duration
+	Err       error
+}
+
+func (e *PollTimeoutError) Error() string {
+	return fmt.Sprintf("%s poll timed out after %s: %v", e.Operation, e.Timeout, e.Err)
+}
+
+func (e *PollTimeoutError) Is(target error) bool { return target == ErrPollTimeout }
+func (e *PollTimeoutError) Unwrap() error        { return e.Err }
+
+func (e *PollTimeoutError) GRPCStatus() *status.Status {
+	return status.New(codes.DeadlineExceeded, e.Error())
+}
+
+// ErrPollRetriesExhausted is matched by errors.Is for every *PollRetriesExhaustedError.
+var ErrPollRetriesExhausted = errors.New("operation: poll retries exhausted")
+
+// PollRetriesExhaustedError is returned by waits when a poll fails with a retryable error,
+// but the retries of the RetryPolicy, or of the retry budget of the context, are exhausted.
+// It unwraps to the error of the last poll and has its status.
+type PollRetriesExhaustedError struct {
+	Operation *Operation
+	// Attempts is the number of failed polls in a row.
+	Attempts int
+	// Code is the code of the last poll error.
+	Code codes.Code
+	Err  error
+}
+
+func (e *PollRetriesExhaustedError) Error() string {
+	return fmt.Sprintf("%s poll fail after %d attempts: %v", e.Operation, e.Attempts, e.Err)
+}
+
+func (e *PollRetriesExhaustedError) Is(target error) bool { return target == ErrPollRetriesExhausted }
+func (e *PollRetriesExhaustedError) Unwrap() error        { return e.Err }
+
+func (e *PollRetriesExhaustedError) GRPCStatus() *status.Status { return status.Convert(e.Err) }
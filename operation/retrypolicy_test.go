@@ -0,0 +1,185 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scriptedClient fails the polls with the codes of script, in order, and reports the
+// operation done after them.
+func scriptedClient(script ...codes.Code) *fakeKafkaClient {
+	return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		if n <= len(script) {
+			if c := script[n-1]; c != codes.OK {
+				return nil, status.Error(c, c.String())
+			}
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+}
+
+func TestWait_RetryPolicyDefault(t *testing.T) {
+	requireKinds(t)
+	client := scriptedClient(codes.NotFound, codes.Unavailable, codes.Unavailable)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	require.NoError(t, op.Wait(context.Background()))
+	assert.Equal(t, 4, client.calls())
+
+	client = scriptedClient(codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.OK,
+		codes.Unavailable, codes.NotFound, codes.Unavailable)
+	op = New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	require.NoError(t, op.Wait(context.Background()), "successful polls start the failures over")
+
+	client = scriptedClient(codes.Internal)
+	op = New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	err := op.Wait(context.Background())
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotErrorIs(t, err, ErrPollRetriesExhausted)
+	assert.Equal(t, 1, client.calls())
+}
+
+func TestWait_RetryPolicyExhausted(t *testing.T) {
+	requireKinds(t)
+	client := scriptedClient(codes.Unavailable, codes.Unavailable, codes.NotFound, codes.Unavailable, codes.Unavailable)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+
+	err := op.Wait(context.Background())
+	require.ErrorIs(t, err, ErrPollRetriesExhausted)
+	var exhausted *PollRetriesExhaustedError
+	require.ErrorAs(t, err, &exhausted)
+	assert.Equal(t, 4, exhausted.Attempts)
+	assert.Equal(t, codes.Unavailable, exhausted.Code)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "poll fail after 4 attempts")
+	assert.Equal(t, 4, client.calls())
+}
+
+func TestWait_RetryPolicyCustom(t *testing.T) {
+	requireKinds(t)
+	policy := WithRetryPolicy(RetryPolicy{Codes: []codes.Code{codes.Unavailable, codes.ResourceExhausted}, MaxConsecutiveFailures: 10})
+	script := []codes.Code{codes.ResourceExhausted}
+	for i := 0; i < 8; i++ {
+		script = append(script, codes.Unavailable)
+	}
+	client := scriptedClient(script...)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	require.NoError(t, op.Wait(context.Background(), policy))
+
+	client = scriptedClient(codes.NotFound)
+	op = New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	err := op.Wait(context.Background(), policy)
+	assert.Equal(t, codes.NotFound, status.Code(err), "NotFound is not retried by the policy")
+
+	client = scriptedClient(codes.Unavailable, codes.Unavailable)
+	op = New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	err = op.Wait(context.Background(), WithRetryPolicy(RetryPolicy{Codes: []codes.Code{codes.Unavailable}}))
+	assert.ErrorIs(t, err, ErrPollRetriesExhausted, "no retries without MaxConsecutiveFailures")
+	assert.Equal(t, 1, client.calls())
+
+	client = scriptedClient(codes.PermissionDenied)
+	op = New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	err = op.Wait(context.Background(), WithRetryPolicy(RetryPolicy{Codes: []codes.Code{codes.PermissionDenied}, MaxConsecutiveFailures: 3}))
+	assert.ErrorIs(t, err, ErrFatalPoll, "fatal codes are not retried")
+}
+
+func TestWait_RetryPolicyAttemptTimeout(t *testing.T) {
+	var polls int
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		if polls == 1 {
+			<-ctx.Done()
+			return nil, 0, ctx.Err()
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+	}
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	policy := DefaultRetryPolicy
+	policy.AttemptTimeout = 10 * time.Millisecond
+
+	require.NoError(t, op.Wait(context.Background(), WithPollFunc(poll), WithRetryPolicy(policy)))
+	assert.Equal(t, 2, polls)
+
+	polls = 0
+	op = New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING})
+	policy.MaxConsecutiveFailures = 0
+	err := op.Wait(context.Background(), WithPollFunc(poll), WithRetryPolicy(policy))
+	var exhausted *PollRetriesExhaustedError
+	require.ErrorAs(t, err, &exhausted)
+	assert.Equal(t, codes.DeadlineExceeded, exhausted.Code)
+}
+
+func TestWait_PollTimeout(t *testing.T) {
+	var polls int
+	stuckFirst := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		if polls == 1 {
+			<-ctx.Done()
+			return nil, 0, ctx.Err()
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+	}
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+	require.NoError(t, op.Wait(context.Background(), WithPollFunc(stuckFirst), WithPollTimeout(10*time.Millisecond)))
+	assert.Equal(t, 2, polls, "the stuck poll is retried")
+
+	polls = 0
+	op = New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING})
+	err := op.Wait(context.Background(), WithPollFunc(stuckFirst), WithPollTimeout(10*time.Millisecond), WithRetryPolicy(RetryPolicy{}))
+	assert.ErrorIs(t, err, ErrPollRetriesExhausted)
+	assert.ErrorIs(t, err, ErrPollTimeout)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Contains(t, err.Error(), "poll timed out after 10ms")
+}
+
+func TestWait_PollTimeoutParentWins(t *testing.T) {
+	stuck := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		<-ctx.Done()
+		return nil, 0, ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	err := op.Wait(ctx, WithPollFunc(stuck), WithPollTimeout(time.Hour))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrPollTimeout, "the context of the wait expired, not the poll timeout")
+}
+
+func TestRetryPolicyOf_PollTimeout(t *testing.T) {
+	assert.Zero(t, retryPolicyOf(nil).AttemptTimeout)
+	assert.Equal(t, DefaultPollTimeout, retryPolicyOf([]grpc.CallOption{WithPollTimeout(0)}).AttemptTimeout)
+	opts := []grpc.CallOption{WithPollTimeout(time.Second), WithRetryPolicy(RetryPolicy{AttemptTimeout: time.Minute})}
+	assert.Equal(t, time.Second, retryPolicyOf(opts).AttemptTimeout, "the poll timeout replaces the one of the policy")
+}
+
+func TestWait_RetryPolicyCancel(t *testing.T) {
+	requireKinds(t)
+	client := scriptedClient(codes.Unavailable, codes.Unavailable)
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := op.WaitInterval(ctx, time.Hour)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second, "ctx ends the wait between retries")
+	assert.Equal(t, 1, client.calls())
+}
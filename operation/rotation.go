@@ -0,0 +1,82 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCredentialsRotated is matched by errors.Is for every *CredentialsRotatedError.
+var ErrCredentialsRotated = errors.New("operation: credentials rotated during the wait")
+
+// CredentialsRotationFunc returns the time the credentials of the polls were last replaced,
+// zero if never.
+type CredentialsRotationFunc func() time.Time
+
+// WithCredentialsRotation sets the source of the time the credentials were last replaced,
+// e.g. by SDK.UpdateConfig. The waits started before a rotation whose polls are rejected
+// after it, with PermissionDenied or Unauthenticated, refresh the credentials and poll once
+// more, whatever their FatalCodes and earlier refreshes, then fail with
+// *CredentialsRotatedError: the new credentials likely lack permissions on the operation.
+func (o *Operation) WithCredentialsRotation(f CredentialsRotationFunc) *Operation {
+	o.rotation = f
+	return o
+}
+
+// CredentialsRotatedError is returned by waits whose polls were rejected after the
+// credentials were replaced during the wait, see WithCredentialsRotation. It unwraps to the
+// *FatalPollError of the last poll.
+type CredentialsRotatedError struct {
+	RotatedAt time.Time
+	Err       *FatalPollError
+}
+
+func (e *CredentialsRotatedError) Error() string {
+	return fmt.Sprintf("%v; the credentials were rotated during the wait, at %s: the new ones may lack permissions on the operation",
+		e.Err, e.RotatedAt.UTC().Format(time.RFC3339))
+}
+
+func (e *CredentialsRotatedError) Is(target error) bool       { return target == ErrCredentialsRotated }
+func (e *CredentialsRotatedError) Unwrap() error              { return e.Err }
+func (e *CredentialsRotatedError) GRPCStatus() *status.Status { return e.Err.GRPCStatus() }
+
+// rotationWatch tells the polls rejected after a rotation of the credentials during a wait.
+// A nil *rotationWatch sees no rotation.
+type rotationWatch struct {
+	rotatedAt CredentialsRotationFunc
+	// started is the time of the last rotation when the wait started, and refreshed the one
+	// of the last rotation the credentials were refreshed for.
+	started, refreshed time.Time
+}
+
+func newRotationWatch(f CredentialsRotationFunc) *rotationWatch {
+	if f == nil {
+		return nil
+	}
+	return &rotationWatch{rotatedAt: f, started: f()}
+}
+
+// rejected returns the time of the last rotation if it was made during the wait and the poll
+// failing with code was rejected because of the credentials, zero otherwise.
+func (w *rotationWatch) rejected(code codes.Code) time.Time {
+	if w == nil || code != codes.PermissionDenied && code != codes.Unauthenticated {
+		return time.Time{}
+	}
+	if at := w.rotatedAt(); at.After(w.started) {
+		return at
+	}
+	return time.Time{}
+}
+
+// refresh reports whether the credentials are to be refreshed for the rotation at: once per
+// rotation.
+func (w *rotationWatch) refresh(at time.Time) bool {
+	if w == nil || !at.After(w.refreshed) {
+		return false
+	}
+	w.refreshed = at
+	return true
+}
@@ -0,0 +1,125 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rotatingCredentials replaces the credentials on the poll rotateOn of its client, which
+// rejects the polls after it with code until the client is done on the poll doneOn, if any.
+type rotatingCredentials struct {
+	rotateOn, doneOn int
+	code             codes.Code
+
+	mu        sync.Mutex
+	rotatedAt time.Time
+	refreshes int
+}
+
+func (r *rotatingCredentials) client() *fakeKafkaClient {
+	return &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		switch {
+		case n == r.doneOn:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+		case n == r.rotateOn:
+			r.mu.Lock()
+			r.rotatedAt = time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+			r.mu.Unlock()
+		case n > r.rotateOn && r.code != codes.OK:
+			return nil, status.Error(r.code, "no permission on the operation")
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+	}}
+}
+
+func (r *rotatingCredentials) op(client Client) *Operation {
+	op := pendingKafkaOp(client)
+	op.WithCredentialsRotation(func() time.Time {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.rotatedAt
+	})
+	op.WithCredentialsRefresher(func(ctx context.Context, opts ...grpc.CallOption) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.refreshes++
+		return nil
+	})
+	return op
+}
+
+func TestWait_CredentialsRotatedToInvalid(t *testing.T) {
+	requireKinds(t)
+	for name, opts := range map[string][]grpc.CallOption{
+		"default fatal codes": nil,
+		"no fatal codes":      {FatalCodes()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &rotatingCredentials{rotateOn: 2, code: codes.PermissionDenied}
+			client := r.client()
+			err := r.op(client).Wait(context.Background(), opts...)
+
+			var rotated *CredentialsRotatedError
+			require.ErrorAs(t, err, &rotated)
+			assert.ErrorIs(t, err, ErrCredentialsRotated)
+			assert.ErrorIs(t, err, ErrCredentials)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+			assert.Equal(t, r.rotatedAt, rotated.RotatedAt)
+			assert.True(t, rotated.Err.Refreshed)
+			assert.Contains(t, err.Error(), "rotated during the wait, at 2026-10-14T09:30:00Z")
+			assert.Equal(t, 1, r.refreshes, "one refresh after the rotation")
+			assert.Equal(t, 4, client.calls(), "the rejected poll is retried once")
+		})
+	}
+}
+
+func TestWait_CredentialsRotatedAfterRefresh(t *testing.T) {
+	requireKinds(t)
+	r := &rotatingCredentials{rotateOn: 3, code: codes.PermissionDenied}
+	expired := r.client()
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		if n == 1 {
+			return nil, status.Error(codes.Unauthenticated, "token expired")
+		}
+		return expired.get(n, id)
+	}}
+	err := r.op(client).Wait(context.Background())
+	assert.ErrorIs(t, err, ErrCredentialsRotated)
+	assert.Equal(t, 2, r.refreshes, "the refresh of the expired token, then the one of the rotation")
+	assert.Equal(t, 5, client.calls())
+}
+
+func TestWait_CredentialsRotatedToValid(t *testing.T) {
+	requireKinds(t)
+	r := &rotatingCredentials{rotateOn: 2, doneOn: 4}
+	client := r.client()
+	require.NoError(t, r.op(client).Wait(context.Background()))
+	assert.Zero(t, r.refreshes)
+	assert.Equal(t, 4, client.calls())
+}
+
+func TestWait_CredentialsRotatedBeforeWait(t *testing.T) {
+	requireKinds(t)
+	r := &rotatingCredentials{code: codes.PermissionDenied, rotatedAt: time.Now()}
+	client := r.client()
+	err := r.op(client).Wait(context.Background())
+	var fatal *FatalPollError
+	require.ErrorAs(t, err, &fatal)
+	assert.False(t, errors.Is(err, ErrCredentialsRotated), "the credentials of the whole wait are rejected")
+	assert.Equal(t, 1, r.refreshes)
+
+	r = &rotatingCredentials{rotateOn: 2, code: codes.PermissionDenied}
+	op := r.op(r.client())
+	err = op.Wait(context.Background(), FatalCodes(codes.Unauthenticated), WithRetryPolicy(RetryPolicy{Codes: []codes.Code{codes.PermissionDenied}, MaxConsecutiveFailures: 3}))
+	assert.ErrorIs(t, err, ErrCredentialsRotated, "rejected polls after a rotation are fatal whatever the fatal codes")
+}
@@ -0,0 +1,168 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// WaitStats are the counters of the waits of the process, see RuntimeStats. The counters are
+// totals since the start of the process, except ActiveWaits: the stats of an interval are
+// the Since of two reads, e.g. the polls of the last minute.
+type WaitStats struct {
+	// At is when the stats were read.
+	At time.Time
+	// ActiveWaits are the waits in progress: Wait, WaitInterval and the waits built on them,
+	// ResourceIdWait, and WaitAll and WaitAny, counting one per operation.
+	ActiveWaits int64
+	// Polls counts the polls of the waits, including the failed ones.
+	Polls int64
+	// Timers counts the timers allocated by the waits, between polls and for timeouts.
+	Timers int64
+	// LimiterWait is the time the polls waited for their PollLimiter, summed over the polls.
+	LimiterWait time.Duration
+}
+
+var runtimeStats struct {
+	active, polls, timers atomic.Int64
+	// limiterWait is in nanoseconds.
+	limiterWait atomic.Int64
+	// maxWaits is the limit of SetMaxConcurrentWaits.
+	maxWaits atomic.Int64
+}
+
+// RuntimeStats returns the counters of the waits of the process, e.g. to tell the waits
+// saturating the timers or the goroutines of a process. They are counted with atomics, at
+// the cost of a few atomic additions per poll.
+func RuntimeStats() WaitStats {
+	return WaitStats{
+		At:          now(),
+		ActiveWaits: runtimeStats.active.Load(),
+		Polls:       runtimeStats.polls.Load(),
+		Timers:      runtimeStats.timers.Load(),
+		LimiterWait: time.Duration(runtimeStats.limiterWait.Load()),
+	}
+}
+
+// Since returns the stats of the interval between prev and s: the counters are the
+// differences, ActiveWaits the one of s.
+func (s WaitStats) Since(prev WaitStats) WaitStats {
+	return WaitStats{
+		At:          s.At,
+		ActiveWaits: s.ActiveWaits,
+		Polls:       s.Polls - prev.Polls,
+		Timers:      s.Timers - prev.Timers,
+		LimiterWait: s.LimiterWait - prev.LimiterWait,
+	}
+}
+
+// ErrTooManyWaits is matched by errors.Is for every *ConcurrentWaitsError.
+var ErrTooManyWaits = errors.New("operation: too many concurrent waits")
+
+// ConcurrentWaitsError is reported to the warning handler, see SetWarningHandler, by the wait
+// starting over the limit of SetMaxConcurrentWaits.
+type ConcurrentWaitsError struct {
+	// Active are the waits in progress, see WaitStats.ActiveWaits, Limit the soft limit.
+	Active int64
+	Limit  int64
+}
+
+func (e *ConcurrentWaitsError) Error() string {
+	return fmt.Sprintf("operation: %d concurrent waits, over the soft limit of %d", e.Active, e.Limit)
+}
+
+func (e *ConcurrentWaitsError) Is(target error) bool { return target == ErrTooManyWaits }
+
+// SetMaxConcurrentWaits sets a soft limit on the waits in progress in the process, see
+// WaitStats.ActiveWaits: the waits are not limited, but every time their number goes over
+// n, a *ConcurrentWaitsError is reported to the warning handler. A non-positive n, the
+// default, sets no limit.
+func SetMaxConcurrentWaits(n int) {
+	runtimeStats.maxWaits.Store(int64(n))
+}
+
+// waitsStarted counts n waits in progress, warning if they cross the limit.
+func waitsStarted(n int) {
+	active := runtimeStats.active.Add(int64(n))
+	if limit := runtimeStats.maxWaits.Load(); limit > 0 && active > limit && active-int64(n) <= limit {
+		warn(&ConcurrentWaitsError{Active: active, Limit: limit})
+	}
+}
+
+func waitsFinished(n int) {
+	runtimeStats.active.Add(-int64(n))
+}
+
+// PollLimiter limits the rate of the polls of the waits sharing it, e.g. a *rate.Limiter of
+// golang.org/x/time/rate, see WithPollLimiter.
+type PollLimiter interface {
+	// Wait blocks until a poll is allowed or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// WithPollLimiter makes every poll of the waits wait for l first: Wait, WaitInterval,
+// ResourceIdWait, WaitAll and WaitAny. The time waited is counted in WaitStats.LimiterWait
+// and WaitResult.LimiterWait. A limiter shared by waits with different intervals should be
+// a FairLimiter, see NewFairLimiter.
+func WithPollLimiter(l PollLimiter) grpc.CallOption {
+	return &pollLimiterOption{l: l}
+}
+
+type pollLimiterOption struct {
+	grpc.EmptyCallOption
+	l PollLimiter
+}
+
+// pollLimiter is the PollLimiter of a wait and its WaitResult, nil without WithPollLimiter
+// and WithWaitResult.
+type pollLimiter struct {
+	l      PollLimiter
+	result *waitResultOption
+}
+
+func pollLimiterOf(opts []grpc.CallOption) *pollLimiter {
+	var l PollLimiter
+	for _, opt := range opts {
+		if opt, ok := opt.(*pollLimiterOption); ok {
+			l = opt.l
+		}
+	}
+	result := waitResultOf(opts)
+	if l == nil && result == nil {
+		return nil
+	}
+	return &pollLimiter{l: l, result: result}
+}
+
+func (p *pollLimiter) wait(ctx context.Context, o *Operation) error {
+	if p == nil || p.l == nil {
+		return nil
+	}
+	started := time.Now()
+	err := p.l.Wait(ctx)
+	waited := time.Since(started)
+	runtimeStats.limiterWait.Add(int64(waited))
+	p.result.add(0, waited)
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return sdkerrors.WithMessagef(ctx.Err(), "%s wait context done", o)
+	default:
+		return sdkerrors.WithMessagef(err, "%s poll limiter", o)
+	}
+}
+
+// polled counts a poll of the wait.
+func (p *pollLimiter) polled() {
+	runtimeStats.polls.Add(1)
+	if p != nil {
+		p.result.add(1, 0)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build loadtest
+
+package operation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRuntimeStats_Load checks the counters of RuntimeStats under 10k concurrent waits, with
+// go test -tags loadtest -run TestRuntimeStats_Load ./operation.
+func TestRuntimeStats_Load(t *testing.T) {
+	const waits, polls = 10000, 3
+	before := RuntimeStats()
+	var warnings atomic.Int64
+	SetWarningHandler(func(err error) { warnings.Add(1) })
+	defer SetWarningHandler(nil)
+	SetMaxConcurrentWaits(int(before.ActiveWaits) + waits/2)
+	defer SetMaxConcurrentWaits(0)
+
+	// Every wait holds its first poll until all the waits are active.
+	var started sync.WaitGroup
+	started.Add(waits)
+	release := make(chan struct{})
+	limiter := &loadLimiter{}
+	var wg sync.WaitGroup
+	errs := make(chan error, waits)
+	for i := 0; i < waits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+			errs <- op.WaitInterval(context.Background(), time.Millisecond, WithPollLimiter(limiter), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+				if n++; n == 1 {
+					started.Done()
+					<-release
+				}
+				st := doublecloud.Operation_STATUS_RUNNING
+				if n == polls {
+					st = doublecloud.Operation_STATUS_DONE
+				}
+				return &Proto{Id: id, Status: st}, 0, nil
+			}))
+		}()
+	}
+	started.Wait()
+	busy := RuntimeStats()
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, before.ActiveWaits+waits, busy.ActiveWaits)
+	assert.Equal(t, int64(1), warnings.Load(), "the limit is crossed once")
+	stats := RuntimeStats().Since(before)
+	assert.Equal(t, before.ActiveWaits, stats.ActiveWaits)
+	assert.Equal(t, int64(waits*polls), stats.Polls)
+	assert.Equal(t, int64(waits*(polls-1)), stats.Timers)
+	assert.Equal(t, int64(waits*polls), limiter.waits.Load())
+	assert.GreaterOrEqual(t, stats.LimiterWait, time.Duration(waits*polls)*loadLimiterDelay)
+}
+
+const loadLimiterDelay = 10 * time.Microsecond
+
+// loadLimiter delays every poll by loadLimiterDelay.
+type loadLimiter struct {
+	waits atomic.Int64
+}
+
+func (l *loadLimiter) Wait(ctx context.Context) error {
+	l.waits.Add(1)
+	time.Sleep(loadLimiterDelay)
+	return nil
+}
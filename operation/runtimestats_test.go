@@ -0,0 +1,112 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeStats_Wait(t *testing.T) {
+	before := RuntimeStats()
+	var active []int64
+	poll := pollsUntilDone(3)
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.Wait(context.Background(), WithClock(&stepClock{}), WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		active = append(active, RuntimeStats().ActiveWaits)
+		return poll(ctx, id)
+	})))
+
+	stats := RuntimeStats().Since(before)
+	assert.Equal(t, int64(3), stats.Polls)
+	assert.Equal(t, int64(2), stats.Timers, "a timer between every two polls")
+	assert.Equal(t, before.ActiveWaits, stats.ActiveWaits)
+	assert.Equal(t, []int64{before.ActiveWaits + 1, before.ActiveWaits + 1, before.ActiveWaits + 1}, active)
+	assert.False(t, stats.At.Before(before.At))
+}
+
+func TestRuntimeStats_WaitAll(t *testing.T) {
+	before := RuntimeStats()
+	var active int64
+	ops := []*Operation{
+		New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING}),
+		New(nil, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_PENDING}),
+	}
+	require.NoError(t, WaitAll(context.Background(), ops, WithPollFunc(func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		active = RuntimeStats().ActiveWaits
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, 0, nil
+	})))
+
+	stats := RuntimeStats().Since(before)
+	assert.Equal(t, int64(2), stats.Polls)
+	assert.Equal(t, before.ActiveWaits+2, active, "one wait per operation")
+	assert.Equal(t, before.ActiveWaits, stats.ActiveWaits)
+}
+
+func TestSetMaxConcurrentWaits(t *testing.T) {
+	var warnings []error
+	SetWarningHandler(func(err error) { warnings = append(warnings, err) })
+	defer SetWarningHandler(nil)
+	limit := RuntimeStats().ActiveWaits + 2
+	SetMaxConcurrentWaits(int(limit))
+	defer SetMaxConcurrentWaits(0)
+
+	waitsStarted(2)
+	assert.Empty(t, warnings, "at the limit")
+	waitsStarted(1)
+	waitsStarted(1)
+	require.Len(t, warnings, 1, "once when crossing the limit")
+	var concurrentErr *ConcurrentWaitsError
+	require.ErrorAs(t, warnings[0], &concurrentErr)
+	assert.ErrorIs(t, warnings[0], ErrTooManyWaits)
+	assert.Equal(t, ConcurrentWaitsError{Active: limit + 1, Limit: limit}, *concurrentErr)
+	assert.EqualError(t, warnings[0], fmt.Sprintf("operation: %d concurrent waits, over the soft limit of %d", limit+1, limit))
+
+	waitsFinished(2)
+	waitsStarted(1)
+	assert.Len(t, warnings, 2, "again when crossing the limit again")
+	waitsFinished(3)
+
+	SetMaxConcurrentWaits(0)
+	waitsStarted(5)
+	waitsFinished(5)
+	assert.Len(t, warnings, 2, "no limit")
+}
+
+type countingLimiter struct {
+	waits int
+	err   error
+	delay time.Duration
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	time.Sleep(l.delay)
+	return l.err
+}
+
+func TestWithPollLimiter(t *testing.T) {
+	before := RuntimeStats()
+	limiter := &countingLimiter{delay: time.Millisecond}
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	require.NoError(t, op.WithPollPolicy(PollPolicy{Limiter: limiter, Clock: &stepClock{}}).Wait(context.Background(), WithPollFunc(pollsUntilDone(3))))
+	assert.Equal(t, 3, limiter.waits, "every poll waits for the limiter of the policy")
+	assert.GreaterOrEqual(t, RuntimeStats().Since(before).LimiterWait, 3*time.Millisecond)
+
+	limiter = &countingLimiter{err: errors.New("rate: Wait(n=1) would exceed context deadline")}
+	op = New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	err := op.Wait(context.Background(), WithPollLimiter(limiter), WithPollFunc(pollsUntilDone(1)))
+	assert.EqualError(t, err, "operation (id=kfo1) poll limiter: rate: Wait(n=1) would exceed context deadline")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.err = ctx.Err()
+	_, err = op.ResourceIdWait(ctx, time.Minute, WithPollLimiter(limiter), WithPollFunc(pollsUntilDone(1)))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualError(t, err, "operation (id=kfo1) wait context done: context canceled")
+}
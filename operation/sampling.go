@@ -0,0 +1,115 @@
+package operation
+
+import (
+	"math/rand"
+
+	"google.golang.org/grpc"
+)
+
+// TelemetrySampling bounds the overhead of the telemetry of many concurrent waits: only a
+// fraction of the waits record their decisions, see WithDecisionLog, WithWaitJournal and
+// WithLogger, while all of them keep their metrics, see WithMetrics and RuntimeStats.
+type TelemetrySampling struct {
+	// Ratio is the fraction of the waits recording their decisions, from 0 to 1.
+	Ratio float64
+	// AlwaysOnFailure makes the waits not sampled buffer their latest decisions, at most
+	// DefaultDecisionLogSize, and record them when the wait fails.
+	AlwaysOnFailure bool
+}
+
+// sampleFloat64 returns a random number in [0, 1), replaced in tests.
+var sampleFloat64 = rand.Float64
+
+// sampled reports whether a wait records its decisions.
+func (s *TelemetrySampling) sampled() bool {
+	return s == nil || s.Ratio >= 1 || s.Ratio > 0 && sampleFloat64() < s.Ratio
+}
+
+// WithTelemetrySampling makes only a fraction of the waits record their decisions, see
+// TelemetrySampling. It takes precedence over the sampling of
+// Operation.WithTelemetrySampling. All the waits record their decisions by default.
+func WithTelemetrySampling(s TelemetrySampling) grpc.CallOption {
+	return &telemetrySampling{sampling: s}
+}
+
+type telemetrySampling struct {
+	grpc.EmptyCallOption
+	sampling TelemetrySampling
+}
+
+// telemetrySamplingOf returns the sampling of opts, else the one of the operation, nil if
+// there is none.
+func telemetrySamplingOf(o *Operation, opts []grpc.CallOption) *TelemetrySampling {
+	s := o.sampling
+	for _, opt := range opts {
+		if opt, ok := opt.(*telemetrySampling); ok {
+			s = &opt.sampling
+		}
+	}
+	return s
+}
+
+// WithTelemetrySampling sets the sampling of the telemetry of the waits of the operation,
+// e.g. the one of the SDK, see WithTelemetrySampling.
+func (o *Operation) WithTelemetrySampling(s TelemetrySampling) *Operation {
+	o.sampling = &s
+	return o
+}
+
+// waitTelemetry records the decisions of a wait to the decision log, the journal and the
+// logger of the wait, if it is sampled.
+type waitTelemetry struct {
+	log     *DecisionLog
+	journal *WaitJournal
+	logger  Logger
+	// buffered keeps the decisions of a wait not sampled until it fails, nil if they are
+	// dropped.
+	buffered *DecisionLog
+}
+
+func newWaitTelemetry(sampling *TelemetrySampling, log *DecisionLog, journal *WaitJournal, logger Logger) *waitTelemetry {
+	t := &waitTelemetry{log: log, journal: journal, logger: logger}
+	if !t.enabled() || sampling.sampled() {
+		return t
+	}
+	if sampling.AlwaysOnFailure {
+		t.buffered = NewDecisionLog(DefaultDecisionLogSize)
+		return t
+	}
+	return &waitTelemetry{}
+}
+
+// enabled reports whether the decisions of the wait are recorded or buffered.
+func (t *waitTelemetry) enabled() bool {
+	return t.log != nil || t.journal != nil || t.logger != nil
+}
+
+func (t *waitTelemetry) add(d Decision) {
+	if t.buffered != nil {
+		t.buffered.add(d)
+		return
+	}
+	t.record(d)
+}
+
+func (t *waitTelemetry) record(d Decision) {
+	t.log.add(d)
+	t.journal.add(d)
+	if t.logger != nil {
+		logDecision(t.logger, d)
+	}
+}
+
+// finish records the buffered decisions if the wait failed with err, then flushes the
+// journal.
+func (t *waitTelemetry) finish(err error) {
+	if t.buffered != nil && err != nil {
+		if n := t.buffered.Dropped(); n > 0 && t.logger != nil {
+			t.logger.Log(LogDebug, "earlier operation poll decisions dropped", "dropped", n)
+		}
+		for _, d := range t.buffered.Decisions() {
+			t.record(d)
+		}
+	}
+	t.journal.flush()
+}
@@ -0,0 +1,176 @@
+package operation
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+)
+
+// runningThen answers the polls with a running operation, then with a done one, failed if
+// failed is set, on the poll done.
+func runningThen(done int, failed bool) PollFunc {
+	var n int
+	return func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		n++
+		op := &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}
+		if n == done {
+			op.Status = doublecloud.Operation_STATUS_DONE
+			if failed {
+				op.Error = &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "failed"}
+			}
+		}
+		return op, 0, nil
+	}
+}
+
+// sampledTelemetry is the telemetry of a wait, see wait.
+type sampledTelemetry struct {
+	log     *DecisionLog
+	journal *lockedBuffer
+	logger  *lineRecorder
+	metrics *recordingMetrics
+}
+
+func newSampledTelemetry() *sampledTelemetry {
+	return &sampledTelemetry{log: NewDecisionLog(0), journal: &lockedBuffer{}, logger: &lineRecorder{}, metrics: &recordingMetrics{}}
+}
+
+// wait waits for the operation with the polls of poll, recording its telemetry.
+func (s *sampledTelemetry) wait(op *Operation, poll PollFunc, opts ...grpc.CallOption) error {
+	op.WithLogger(s.logger).WithWaitJournal(NewWaitJournal(s.journal))
+	opts = append([]grpc.CallOption{WithPollFunc(poll), WithBusyPoll(), WithDecisionLog(s.log), WithMetrics(s.metrics)}, opts...)
+	return op.WaitInterval(context.Background(), 0, opts...)
+}
+
+// decisionLines returns the lines of the logger logging the decisions of the polls.
+func (s *sampledTelemetry) decisionLines() int {
+	var n int
+	for _, l := range s.logger.lines {
+		if l.msg == "operation polled" {
+			n++
+		}
+	}
+	return n
+}
+
+func pendingOp(id string) *Operation {
+	return New(nil, &Proto{Id: id, Status: doublecloud.Operation_STATUS_PENDING})
+}
+
+func TestTelemetrySampling_NotSampled(t *testing.T) {
+	for name, sampling := range map[string]TelemetrySampling{
+		"none":              {},
+		"always on failure": {AlwaysOnFailure: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newSampledTelemetry()
+			require.NoError(t, s.wait(pendingOp("kfo1").WithTelemetrySampling(sampling), runningThen(3, false)))
+			assert.Empty(t, s.log.Decisions())
+			assert.Empty(t, s.journal.String())
+			assert.Zero(t, s.decisionLines())
+			assert.NotEmpty(t, s.logger.lines, "the wait itself is logged")
+			assert.Equal(t, 3, s.metrics.started, "the metrics of all the waits are recorded")
+			require.Len(t, s.metrics.waits, 1)
+		})
+	}
+
+	s := newSampledTelemetry()
+	err := s.wait(pendingOp("kfo2").WithTelemetrySampling(TelemetrySampling{}), runningThen(3, true))
+	require.Error(t, err)
+	assert.Empty(t, s.log.Decisions(), "failed waits are dropped without AlwaysOnFailure")
+	assert.Empty(t, s.journal.String())
+}
+
+func TestTelemetrySampling_AlwaysOnFailure(t *testing.T) {
+	s := newSampledTelemetry()
+	sampling := WithTelemetrySampling(TelemetrySampling{AlwaysOnFailure: true})
+	err := s.wait(pendingOp("kfo1"), runningThen(3, true), sampling)
+	require.Error(t, err)
+
+	decisions := s.log.Decisions()
+	require.Len(t, decisions, 3, "the decisions of the failed wait are recorded once it fails")
+	for i, d := range decisions {
+		assert.Equal(t, i+1, d.Attempt)
+	}
+	entries := journalEntries(t, s.journal.String())
+	require.Len(t, entries, 3)
+	assert.Equal(t, "STATUS_DONE", entries[2].Status)
+	assert.Equal(t, 3, s.decisionLines())
+	assert.Equal(t, 1, s.journal.writes, "the journal is written at once")
+}
+
+func TestTelemetrySampling_BoundedBuffer(t *testing.T) {
+	s := newSampledTelemetry()
+	polls := DefaultDecisionLogSize + 20
+	err := s.wait(pendingOp("kfo1"), runningThen(polls, true), WithTelemetrySampling(TelemetrySampling{AlwaysOnFailure: true}))
+	require.Error(t, err)
+	decisions := s.log.Decisions()
+	require.Len(t, decisions, DefaultDecisionLogSize, "the latest decisions are kept")
+	assert.Equal(t, polls, decisions[len(decisions)-1].Attempt)
+	assert.Equal(t, 21, decisions[0].Attempt)
+
+	var dropped interface{}
+	for _, l := range s.logger.lines {
+		if l.msg == "earlier operation poll decisions dropped" {
+			dropped = l.fields["dropped"]
+		}
+	}
+	assert.Equal(t, 20, dropped)
+}
+
+func TestTelemetrySampling_Ratio(t *testing.T) {
+	samples := []float64{0.2, 0.7, 0.49}
+	sampleFloat64 = func() float64 {
+		f := samples[0]
+		samples = samples[1:]
+		return f
+	}
+	defer func() { sampleFloat64 = rand.Float64 }()
+
+	var sampled []bool
+	for i := 0; i < 3; i++ {
+		s := newSampledTelemetry()
+		op := pendingOp("kfo1").WithTelemetrySampling(TelemetrySampling{Ratio: 0.5})
+		require.NoError(t, s.wait(op, runningThen(2, false)))
+		sampled = append(sampled, len(s.log.Decisions()) == 2)
+	}
+	assert.Equal(t, []bool{true, false, true}, sampled)
+
+	s := newSampledTelemetry()
+	op := pendingOp("kfo1").WithTelemetrySampling(TelemetrySampling{})
+	require.NoError(t, s.wait(op, runningThen(2, false), WithTelemetrySampling(TelemetrySampling{Ratio: 1})))
+	assert.Len(t, s.log.Decisions(), 2, "the option takes precedence over the sampling of the operation")
+	assert.Empty(t, samples, "a ratio of 1 samples every wait")
+}
+
+func BenchmarkWait_TelemetrySampling(b *testing.B) {
+	for _, bc := range []struct {
+		name     string
+		sampling []grpc.CallOption
+	}{
+		{"full", nil},
+		{"sampled", []grpc.CallOption{WithTelemetrySampling(TelemetrySampling{Ratio: 0.01, AlwaysOnFailure: true})}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			log, journal := NewDecisionLog(0), NewWaitJournal(io.Discard)
+			opts := append([]grpc.CallOption{WithBusyPoll(), WithDecisionLog(log)}, bc.sampling...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				op := pendingOp("kfo1").WithLogger(nopLogger{}).WithWaitJournal(journal)
+				if err := op.WaitInterval(context.Background(), 0, append(opts, WithPollFunc(runningThen(20, false)))...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package operation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// SealVersion is the version of the payload of SealCheckpoint. OpenCheckpoint decodes it and
+// the versions before, see CheckpointVersionError.
+const SealVersion = 1
+
+// Checkpoint is an operation handle passed between services, e.g. through a queue, to be
+// polled by another worker. See SealCheckpoint to detect the tampered ones.
+type Checkpoint struct {
+	// OperationID is the ID of the operation, readable without decoding State.
+	OperationID string
+	// State is the state of the operation encoded by MarshalJSON.
+	State json.RawMessage
+}
+
+// NewCheckpoint returns the checkpoint of the operation.
+func NewCheckpoint(o *Operation) (*Checkpoint, error) {
+	state, err := o.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{OperationID: o.Id(), State: state}, nil
+}
+
+// Operation decodes the operation of the checkpoint, polled by client.
+func (cp *Checkpoint) Operation(client Client) (*Operation, error) {
+	o := &Operation{client: client}
+	if err := o.UnmarshalJSON(cp.State); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ErrCheckpointIntegrity is matched by errors.Is for every *CheckpointIntegrityError.
+var ErrCheckpointIntegrity = errors.New("operation: checkpoint integrity check failed")
+
+// CheckpointIntegrityError is returned by OpenCheckpoint for data that is not a checkpoint
+// sealed with one of its keys: corrupted, tampered with, or sealed with another key.
+type CheckpointIntegrityError struct {
+	// Reason is what failed, e.g. "signature mismatch".
+	Reason string
+}
+
+func (e *CheckpointIntegrityError) Error() string {
+	return "checkpoint integrity check failed: " + e.Reason
+}
+
+func (e *CheckpointIntegrityError) Is(target error) bool {
+	return target == ErrCheckpointIntegrity
+}
+
+type sealedJSON struct {
+	// Payload is the encoded checkpointJSON, MAC its HMAC-SHA256.
+	Payload []byte `json:"payload"`
+	MAC     []byte `json:"mac"`
+}
+
+type checkpointJSON struct {
+	Version     int             `json:"version"`
+	OperationID string          `json:"operation_id"`
+	State       json.RawMessage `json:"state"`
+}
+
+// SealCheckpoint encodes the checkpoint with an HMAC-SHA256 of it by key, so that
+// OpenCheckpoint detects the checkpoints altered on the way, e.g. on a queue shared by
+// services. The checkpoint is signed, not encrypted: its operation is readable.
+func SealCheckpoint(cp *Checkpoint, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("operation: empty checkpoint key")
+	}
+	payload, err := json.Marshal(checkpointJSON{Version: SealVersion, OperationID: cp.OperationID, State: cp.State})
+	if err != nil {
+		return nil, sdkerrors.WithMessage(err, "checkpoint")
+	}
+	return json.Marshal(sealedJSON{Payload: payload, MAC: checkpointMAC(payload, key)})
+}
+
+// OpenCheckpoint decodes the checkpoint of SealCheckpoint, checking its HMAC with every key in
+// turn, e.g. the current key then the previous ones during a key rotation. Checkpoints sealed
+// with none of the keys, or altered, fail with *CheckpointIntegrityError, and the ones of
+// versions newer than SealVersion with *CheckpointVersionError.
+func OpenCheckpoint(data []byte, keys ...[]byte) (*Checkpoint, error) {
+	var sealed sealedJSON
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, &CheckpointIntegrityError{Reason: fmt.Sprintf("malformed checkpoint: %v", err)}
+	}
+	if len(sealed.Payload) == 0 || len(sealed.MAC) == 0 {
+		return nil, &CheckpointIntegrityError{Reason: "checkpoint not sealed"}
+	}
+	if !checkpointKeyOf(sealed, keys) {
+		return nil, &CheckpointIntegrityError{Reason: "signature mismatch"}
+	}
+
+	var v checkpointJSON
+	if err := json.Unmarshal(sealed.Payload, &v); err != nil {
+		return nil, sdkerrors.WithMessage(err, "checkpoint")
+	}
+	if v.Version < 1 || v.Version > SealVersion {
+		return nil, &CheckpointVersionError{Format: "sealed", Version: v.Version, MaxVersion: SealVersion}
+	}
+	return &Checkpoint{OperationID: v.OperationID, State: v.State}, nil
+}
+
+// checkpointKeyOf reports whether the checkpoint is sealed with one of the keys.
+func checkpointKeyOf(sealed sealedJSON, keys [][]byte) bool {
+	for _, key := range keys {
+		if len(key) > 0 && hmac.Equal(sealed.MAC, checkpointMAC(sealed.Payload, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkpointMAC(payload, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
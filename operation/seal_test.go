@@ -0,0 +1,95 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sealedCheckpoint(t *testing.T, key []byte) []byte {
+	cp, err := NewCheckpoint(checkpointOperation())
+	require.NoError(t, err)
+	data, err := SealCheckpoint(cp, key)
+	require.NoError(t, err)
+	return data
+}
+
+func TestSealCheckpoint_RoundTrip(t *testing.T) {
+	key := []byte("queue-key-1")
+	cp, err := OpenCheckpoint(sealedCheckpoint(t, key), key)
+	require.NoError(t, err)
+	assert.Equal(t, "cho1a2b3c4d5e6f7g8h9", cp.OperationID)
+
+	o, err := cp.Operation(nil)
+	require.NoError(t, err)
+	want := checkpointOperation()
+	assert.Equal(t, want.Snapshot(), o.Snapshot())
+	assert.Equal(t, want.String(), o.String(), "the origin is kept")
+
+	_, err = SealCheckpoint(cp, nil)
+	assert.EqualError(t, err, "operation: empty checkpoint key")
+}
+
+func TestOpenCheckpoint_Tampered(t *testing.T) {
+	key := []byte("queue-key-1")
+	data := sealedCheckpoint(t, key)
+	var sealed sealedJSON
+	require.NoError(t, json.Unmarshal(data, &sealed))
+	forged := func(payload, mac []byte) []byte {
+		data, err := json.Marshal(sealedJSON{Payload: payload, MAC: mac})
+		require.NoError(t, err)
+		return data
+	}
+	other := bytes.Replace(sealed.Payload, []byte("cho1a2b3c4d5e6f7g8h9"), []byte("cho0000000000000000"), -1)
+	flipped := append([]byte(nil), sealed.MAC...)
+	flipped[0] ^= 1
+
+	for name, data := range map[string][]byte{
+		"payload":   forged(other, sealed.MAC),
+		"signature": forged(sealed.Payload, flipped),
+		"unsigned":  forged(sealed.Payload, nil),
+		"truncated": data[:len(data)/2],
+		"plain":     []byte(`{"version": 1, "operation_id": "cho1", "state": {}}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			cp, err := OpenCheckpoint(data, key)
+			assert.Nil(t, cp)
+			var integrityErr *CheckpointIntegrityError
+			assert.ErrorAs(t, err, &integrityErr)
+			assert.ErrorIs(t, err, ErrCheckpointIntegrity)
+		})
+	}
+
+	_, err := OpenCheckpoint(forged(other, sealed.MAC), key)
+	assert.EqualError(t, err, "checkpoint integrity check failed: signature mismatch")
+}
+
+func TestOpenCheckpoint_KeyRotation(t *testing.T) {
+	previous, current := []byte("queue-key-1"), []byte("queue-key-2")
+	old := sealedCheckpoint(t, previous)
+
+	cp, err := OpenCheckpoint(old, current, previous)
+	require.NoError(t, err, "the previous key is still accepted")
+	assert.Equal(t, "cho1a2b3c4d5e6f7g8h9", cp.OperationID)
+	_, err = OpenCheckpoint(sealedCheckpoint(t, current), current, previous)
+	assert.NoError(t, err)
+
+	_, err = OpenCheckpoint(old, current)
+	assert.ErrorIs(t, err, ErrCheckpointIntegrity, "the previous key retired")
+	_, err = OpenCheckpoint(old)
+	assert.ErrorIs(t, err, ErrCheckpointIntegrity, "no keys")
+}
+
+func TestOpenCheckpoint_FutureVersion(t *testing.T) {
+	key := []byte("queue-key-1")
+	payload := []byte(`{"version": 99, "operation_id": "cho1", "state": {}}`)
+	data, err := json.Marshal(sealedJSON{Payload: payload, MAC: checkpointMAC(payload, key)})
+	require.NoError(t, err)
+
+	_, err = OpenCheckpoint(data, key)
+	assert.ErrorIs(t, err, ErrCheckpointVersionUnsupported)
+	assert.EqualError(t, err, "unsupported sealed checkpoint version 99, this SDK supports up to 1")
+}
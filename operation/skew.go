@@ -0,0 +1,60 @@
+package operation
+
+import "time"
+
+// ClockSkewFunc returns the estimated offset of the server clock from the local one:
+// server time minus local time.
+type ClockSkewFunc func() time.Duration
+
+// WithClockSkew sets the estimate of the server clock offset used by the computations
+// comparing server timestamps of the operation to the local time, see WithSkewCorrection.
+func (o *Operation) WithClockSkew(skew ClockSkewFunc) *Operation {
+	o.skew = skew
+	return o
+}
+
+// TimeOption configures the computations comparing server timestamps to the local time.
+type TimeOption func(*timeOptions)
+
+type timeOptions struct {
+	skewCorrection bool
+}
+
+// WithSkewCorrection shifts the local time by the clock offset set with WithClockSkew, if any,
+// before comparing it to server timestamps.
+func WithSkewCorrection(enabled bool) TimeOption {
+	return func(o *timeOptions) { o.skewCorrection = enabled }
+}
+
+var now = time.Now
+
+// serverNow returns the local time, corrected to the server clock if the options ask to.
+func (o *Operation) serverNow(opts []TimeOption) time.Time {
+	var options timeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	t := now()
+	if options.skewCorrection && o.skew != nil {
+		t = t.Add(o.skew())
+	}
+	return t
+}
+
+// Age returns the time since the operation was created, until it finished for done operations.
+// The age of a running operation is measured with the local clock, see WithSkewCorrection;
+// ages that come out negative because of clock skew are zero.
+func (o *Operation) Age(opts ...TimeOption) time.Duration {
+	created := o.Proto().GetCreateTime()
+	if created == nil {
+		return 0
+	}
+	end := o.serverNow(opts)
+	if finished := o.Proto().GetFinishTime(); o.Done() && finished != nil {
+		end = finished.AsTime()
+	}
+	if age := end.Sub(created.AsTime()); age > 0 {
+		return age
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package operation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAge(t *testing.T) {
+	local := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return local }
+	defer func() { now = time.Now }()
+	// The server clock is 10s ahead, and the operation was created 30s ago by it.
+	created := timestamppb.New(local.Add(10*time.Second - 30*time.Second))
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_RUNNING, CreateTime: created})
+
+	assert.Equal(t, 20*time.Second, op.Age())
+	assert.Equal(t, 20*time.Second, op.Age(WithSkewCorrection(true)), "no skew estimate is set")
+	op.WithClockSkew(func() time.Duration { return 10 * time.Second })
+	assert.Equal(t, 30*time.Second, op.Age(WithSkewCorrection(true)))
+	assert.Equal(t, 20*time.Second, op.Age(WithSkewCorrection(false)))
+
+	ahead := New(nil, &Proto{Id: "cho2", Status: doublecloud.Operation_STATUS_RUNNING, CreateTime: timestamppb.New(local.Add(time.Minute))})
+	assert.Zero(t, ahead.Age(), "negative ages are zero")
+
+	done := New(nil, &Proto{Id: "cho3", Status: doublecloud.Operation_STATUS_DONE, CreateTime: created, FinishTime: timestamppb.New(created.AsTime().Add(time.Minute))})
+	assert.Equal(t, time.Minute, done.Age(WithSkewCorrection(true)), "done operations are measured by the server clock")
+	assert.Zero(t, New(nil, &Proto{Id: "cho4"}).Age())
+}
@@ -0,0 +1,90 @@
+package operation
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WithSLA makes waits call onExceed once when the operation is still running d after it was
+// created, or after the wait started if its creation time is unknown. The check is made after
+// every poll, so onExceed is called up to a poll interval late. It doesn't affect the outcome
+// of the wait, but runs on the wait loop, delaying the next poll: slow work, such as paging,
+// should be started on another goroutine.
+func WithSLA(d time.Duration, onExceed func(o *Operation)) grpc.CallOption {
+	return &slaOption{d: d, onExceed: onExceed}
+}
+
+// WithSLAQuantile is WithSLA with the threshold derived from the completion times of the
+// operations of the same type, see HistogramKey: multiplier times their q-quantile, e.g.
+// 3 times the median. The histogram is DefaultDurationHistogram unless set with
+// WithDurationHistogram; it is fed by adaptive waits, see WithAdaptiveInterval, or Observe.
+// Types without history have no SLA.
+func WithSLAQuantile(q, multiplier float64, onExceed func(o *Operation)) grpc.CallOption {
+	return &slaOption{q: q, multiplier: multiplier, quantile: true, onExceed: onExceed}
+}
+
+type slaOption struct {
+	grpc.EmptyCallOption
+	d             time.Duration
+	quantile      bool
+	q, multiplier float64
+	onExceed      func(o *Operation)
+}
+
+// sla checks the SLA of a single wait.
+type sla struct {
+	*slaOption
+	h       *DurationHistogram
+	now     func() time.Time
+	started time.Time
+	fired   bool
+}
+
+// slaOf returns the SLA of the last SLA option, nil if there is none.
+func slaOf(opts []grpc.CallOption, now func() time.Time) *sla {
+	var option *slaOption
+	h := DefaultDurationHistogram
+	for _, o := range opts {
+		switch o := o.(type) {
+		case *slaOption:
+			option = o
+		case *durationHistogram:
+			h = o.h
+		}
+	}
+	if option == nil || option.onExceed == nil {
+		return nil
+	}
+	return &sla{slaOption: option, h: h, now: now, started: now()}
+}
+
+// check calls the callback once the running operation exceeded the SLA.
+func (s *sla) check(o *Operation) {
+	if s == nil || s.fired {
+		return
+	}
+	threshold := s.d
+	if s.quantile {
+		expected, ok := s.h.Quantile(HistogramKey(o), s.q)
+		if !ok {
+			return
+		}
+		threshold = time.Duration(float64(expected) * s.multiplier)
+	}
+	if threshold <= 0 {
+		return
+	}
+	elapsed := s.now().Sub(s.started)
+	if created := o.Proto().GetCreateTime(); created != nil {
+		elapsed = s.now().Sub(created.AsTime())
+	}
+	if elapsed < threshold {
+		return
+	}
+	s.fired = true
+	_ = SafeCall("SLA callback", func() error {
+		s.onExceed(o)
+		return nil
+	})
+}
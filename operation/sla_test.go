@@ -0,0 +1,75 @@
+package operation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// slaWait waits for an operation done after d, polling every second of the fake clock, with
+// the SLA option of sla, and returns the number of SLA callbacks.
+func slaWait(t *testing.T, done time.Duration, sla func(onExceed func(*Operation)) grpc.CallOption, opts ...grpc.CallOption) int {
+	c := newFakeClock(t)
+	op := New(nil, &Proto{Id: "cho1", Description: "Create cluster", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = c.newTimer
+	var exceeded int
+	opts = append(opts, WithPollFunc(c.pollUntil(done, 0)), sla(func(o *Operation) {
+		exceeded++
+		assert.False(t, o.Done())
+	}))
+	require.NoError(t, op.WaitInterval(context.Background(), time.Second, opts...))
+	return exceeded
+}
+
+func fixedSLA(d time.Duration) func(func(*Operation)) grpc.CallOption {
+	return func(onExceed func(*Operation)) grpc.CallOption { return WithSLA(d, onExceed) }
+}
+
+func quantileSLA(q, multiplier float64) func(func(*Operation)) grpc.CallOption {
+	return func(onExceed func(*Operation)) grpc.CallOption { return WithSLAQuantile(q, multiplier, onExceed) }
+}
+
+func TestWait_SLA(t *testing.T) {
+	assert.Equal(t, 1, slaWait(t, 10*time.Second, fixedSLA(5*time.Second)), "called once while the operation runs past the SLA")
+	assert.Equal(t, 0, slaWait(t, 3*time.Second, fixedSLA(5*time.Second)), "not called for operations done in time")
+}
+
+func TestWait_SLAQuantile(t *testing.T) {
+	requireKinds(t)
+	h := seededHistogram("clickhouse/Create cluster", time.Second, 2*time.Second, 3*time.Second)
+	assert.Equal(t, 1, slaWait(t, 10*time.Second, quantileSLA(0.5, 3), WithDurationHistogram(h)), "past 3 times the median")
+	assert.Equal(t, 0, slaWait(t, 5*time.Second, quantileSLA(0.5, 3), WithDurationHistogram(h)))
+	assert.Equal(t, 0, slaWait(t, 10*time.Second, quantileSLA(0.5, 3), WithDurationHistogram(NewDurationHistogram())), "no SLA without history")
+}
+
+func TestWait_SLAWithoutCreateTime(t *testing.T) {
+	c := newFakeClock(t)
+	var polls int
+	poll := func(ctx context.Context, id string) (*Proto, time.Duration, error) {
+		polls++
+		op := &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}
+		if polls > 5 {
+			op.Status = doublecloud.Operation_STATUS_DONE
+		}
+		return op, 0, nil
+	}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = c.newTimer
+	var exceeded int
+	sla := WithSLA(3*time.Second, func(o *Operation) { exceeded++ })
+	require.NoError(t, op.WaitInterval(context.Background(), time.Second, WithPollFunc(poll), sla))
+	assert.Equal(t, 1, exceeded, "measured from the start of the wait")
+}
+
+func TestWait_SLAPanic(t *testing.T) {
+	c := newFakeClock(t)
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = c.newTimer
+	sla := WithSLA(time.Second, func(o *Operation) { panic("boom") })
+	assert.NoError(t, op.WaitInterval(context.Background(), time.Second, WithPollFunc(c.pollUntil(5*time.Second, 0)), sla), "the callback doesn't affect the wait")
+}
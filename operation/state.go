@@ -0,0 +1,121 @@
+package operation
+
+import (
+	"sync"
+	"time"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// opState is the state of an operation replaced by its polls. It is guarded, so the getters,
+// Poll and the wait of WaitAsync may run concurrently.
+type opState struct {
+	mu    sync.RWMutex
+	proto *Proto
+	// resumed is set for the state of NewFromID, until a poll replaces it.
+	resumed bool
+	// suggested is the poll interval suggested at the last poll of a wait.
+	suggested time.Duration
+	// done is the channel of DoneChan, made by its first call.
+	done chan struct{}
+	// async is set while the wait of WaitAsync runs.
+	async bool
+}
+
+func newOpState(proto *Proto, resumed bool) *opState {
+	return &opState{proto: proto, resumed: resumed}
+}
+
+func (s *opState) load() (proto *Proto, resumed bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.proto, s.resumed
+}
+
+// store replaces the state with a polled one, closing the channel of DoneChan once the
+// operation is done.
+func (s *opState) store(proto *Proto) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.proto, s.resumed = proto, false
+	if s.done != nil && stateDone(proto, false) {
+		select {
+		case <-s.done:
+		default:
+			close(s.done)
+		}
+	}
+}
+
+func (s *opState) suggestedInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.suggested
+}
+
+func (s *opState) setSuggested(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suggested = d
+}
+
+func (s *opState) doneChan() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done == nil {
+		s.done = make(chan struct{})
+		if stateDone(s.proto, s.resumed) {
+			close(s.done)
+		}
+	}
+	return s.done
+}
+
+// startAsync marks the wait of WaitAsync running, reporting false if one already is.
+func (s *opState) startAsync() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.async {
+		return false
+	}
+	s.async = true
+	return true
+}
+
+func (s *opState) stopAsync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.async = false
+}
+
+func (s *opState) asyncRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.async
+}
+
+// stateDone reports whether the operation of the state is done, see Operation.Done.
+func stateDone(proto *Proto, resumed bool) bool {
+	if resumed {
+		return false
+	}
+	return StatusDone(proto.GetStatus())
+}
+
+// StatusDone reports whether an operation of the status is done: DONE, and INVALID, the
+// status of the operations that ended invalid, see IsResubmittable. Statuses unknown to the SDK, e.g. added to
+// the API since, are reported with sdkerrors.UnknownEnum and are not done, so that the
+// operation is polled again.
+func StatusDone(status dc.Operation_Status) bool {
+	switch status {
+	case dc.Operation_STATUS_DONE, dc.Operation_STATUS_INVALID:
+		return true
+	case dc.Operation_STATUS_PENDING, dc.Operation_STATUS_RUNNING:
+		return false
+	default:
+		sdkerrors.UnknownEnum(string(status.Descriptor().FullName()), int32(status))
+		return false
+	}
+}
@@ -0,0 +1,29 @@
+package operation
+
+import (
+	"testing"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// TestStatusDone_Enum checks that every status of the API is handled explicitly, so that the
+// statuses added to the API since are the only ones reported as unknown.
+func TestStatusDone_Enum(t *testing.T) {
+	var unknown []int32
+	defer sdkerrors.SetUnknownEnumHandler(func(kind string, value int32) {
+		assert.Equal(t, "doublecloud.v1.Operation.Status", kind)
+		unknown = append(unknown, value)
+	})()
+
+	for v, name := range dc.Operation_Status_name {
+		want := name == "STATUS_DONE" || name == "STATUS_INVALID"
+		assert.Equal(t, want, StatusDone(dc.Operation_Status(v)), name)
+	}
+	assert.Empty(t, unknown, "every status is handled explicitly")
+
+	assert.False(t, StatusDone(dc.Operation_Status(99)), "a newer status is not done")
+	assert.Equal(t, []int32{99}, unknown)
+}
@@ -0,0 +1,72 @@
+package operation
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	dc "github.com/doublecloud/go-genproto/doublecloud/v1"
+)
+
+// SyntheticOption sets the state of the operations of NewCompleted and NewFailedSynthetic.
+type SyntheticOption func(p *Proto)
+
+// WithSyntheticDescription sets the description of a synthetic operation, e.g. the one of
+// the call it stands in for, see HistogramKey.
+func WithSyntheticDescription(description string) SyntheticOption {
+	return func(p *Proto) { p.Description = description }
+}
+
+// WithSyntheticMetadata sets the metadata of a synthetic operation, e.g. the resource IDs
+// a real one would have, see the opmeta package.
+func WithSyntheticMetadata(metadata map[string]string) SyntheticOption {
+	return func(p *Proto) { p.Metadata = metadata }
+}
+
+// WithSyntheticProjectID sets the project of a synthetic operation.
+func WithSyntheticProjectID(projectID string) SyntheticOption {
+	return func(p *Proto) { p.ProjectId = projectID }
+}
+
+// NewCompleted returns an operation on the resource of a service of kind, e.g. KindKafka,
+// done successfully without any call to the API, for calls with nothing to do such as
+// starting a running cluster or dry runs. Synthetic operations are never polled: Poll does
+// nothing and waits return right away. Their ID is unique but not an operation ID of the
+// service, and their times are the time of the call.
+func NewCompleted(kind, resourceID string, opts ...SyntheticOption) *Operation {
+	return newSynthetic(kind, resourceID, nil, opts)
+}
+
+// NewFailedSynthetic is NewCompleted for an operation failed with st, e.g. for calls
+// rejected by a local check. It panics if st is nil or OK.
+func NewFailedSynthetic(kind, resourceID string, st *status.Status, opts ...SyntheticOption) *Operation {
+	if st.Code() == codes.OK {
+		panic("synthetic operation failed without an error")
+	}
+	return newSynthetic(kind, resourceID, st, opts)
+}
+
+func newSynthetic(kind, resourceID string, st *status.Status, opts []SyntheticOption) *Operation {
+	at := timestamppb.New(now())
+	p := &Proto{
+		Id:         fmt.Sprintf("%s-synthetic-%s", kind, newUUID()),
+		CreateTime: at,
+		StartTime:  at,
+		FinishTime: at,
+		Status:     dc.Operation_STATUS_DONE,
+		Error:      st.Proto(),
+		ResourceId: resourceID,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	o := New(nil, p)
+	o.synthetic = true
+	return o
+}
+
+// Synthetic reports whether the operation was made by NewCompleted or NewFailedSynthetic,
+// without any call to the API.
+func (o *Operation) Synthetic() bool { return o.synthetic }
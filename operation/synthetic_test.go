@@ -0,0 +1,63 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewCompleted(t *testing.T) {
+	o := NewCompleted(KindKafka, "kfc1", WithSyntheticDescription("Start cluster"), WithSyntheticMetadata(map[string]string{"cluster_id": "kfc1"}))
+	assert.True(t, o.Synthetic())
+	assert.True(t, o.Done())
+	assert.True(t, o.Ok())
+	assert.False(t, o.Failed())
+	assert.NoError(t, o.Error())
+	assert.Equal(t, "kfc1", o.ResourceId())
+	assert.Equal(t, "Start cluster", o.Description())
+	assert.Equal(t, map[string]string{"cluster_id": "kfc1"}, o.Metadata())
+	assert.WithinDuration(t, time.Now(), o.CreatedAt(), time.Minute)
+	assert.NotEqual(t, o.Id(), NewCompleted(KindKafka, "kfc1").Id(), "the IDs are unique")
+	assert.False(t, New(nil, &Proto{Id: "kfo1"}).Synthetic())
+}
+
+func TestNewFailedSynthetic(t *testing.T) {
+	o := NewFailedSynthetic(KindClickHouse, "chcl1", status.New(codes.FailedPrecondition, "cluster is stopped"))
+	assert.True(t, o.Synthetic())
+	assert.True(t, o.Failed())
+	assert.Equal(t, codes.FailedPrecondition, o.ErrorStatus().Code())
+
+	assert.Panics(t, func() { NewFailedSynthetic(KindClickHouse, "chcl1", nil) })
+	assert.Panics(t, func() { NewFailedSynthetic(KindClickHouse, "chcl1", status.New(codes.OK, "")) })
+}
+
+func TestWait_Synthetic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	h := NewDurationHistogram()
+	o := NewCompleted(KindKafka, "kfc1", WithSyntheticDescription("Start cluster"))
+	require.NoError(t, o.Poll(ctx), "synthetic operations are not polled")
+	require.NoError(t, o.Wait(ctx, WithAdaptiveInterval(time.Second, time.Minute), WithDurationHistogram(h)))
+	_, ok := h.Quantile(HistogramKey(o), 0.5)
+	assert.False(t, ok, "synthetic operations don't feed the histogram")
+
+	failed := NewFailedSynthetic(KindKafka, "kfc1", status.New(codes.FailedPrecondition, "stopped"))
+	err := failed.Wait(ctx)
+	assert.ErrorIs(t, err, ErrOperationFailed)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestSynthetic_JSON(t *testing.T) {
+	data, err := json.Marshal(NewCompleted(KindKafka, "kfc1"))
+	require.NoError(t, err)
+	var o Operation
+	require.NoError(t, json.Unmarshal(data, &o))
+	assert.True(t, o.Synthetic())
+	assert.True(t, o.Ok())
+}
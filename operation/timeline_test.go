@@ -0,0 +1,131 @@
+//go:build !operation_nokinds
+
+package operation_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/operation/operationtest"
+)
+
+// waitInterval waits polling every interval, with the options of the test.
+func waitInterval(interval time.Duration, opts ...grpc.CallOption) operationtest.WaitFunc {
+	return func(ctx context.Context, op *operation.Operation, clock ...grpc.CallOption) error {
+		return op.WaitInterval(ctx, interval, append(clock, opts...)...)
+	}
+}
+
+// hinted returns the steps running twice then done, all with the poll interval header.
+func hinted(hint string) []operationtest.Step {
+	return []operationtest.Step{
+		operationtest.Running().WithPollInterval(hint),
+		operationtest.Running().WithPollInterval(hint),
+		operationtest.Done().WithPollInterval(hint),
+	}
+}
+
+func TestWait_ClockPollIntervalHeader(t *testing.T) {
+	for name, tc := range map[string]struct {
+		hint string
+		want time.Duration
+	}{
+		"no header":       {"", 2 * time.Second},
+		"seconds":         {"5", 5 * time.Second},
+		"fractional":      {"0.5", 500 * time.Millisecond},
+		"duration":        {"1500ms", 1500 * time.Millisecond},
+		"malformed":       {"5 seconds", 2 * time.Second},
+		"zero":            {"0", 2 * time.Second},
+		"negative":        {"-3", 2 * time.Second},
+		"longer interval": {"60", time.Minute},
+		"below minimum":   {"0.01", operation.DefaultMinIntervalHint},
+		"above maximum":   {"86400", operation.DefaultMaxIntervalHint},
+	} {
+		t.Run(name, func(t *testing.T) {
+			trace := operationtest.Run(context.Background(), hinted(tc.hint), waitInterval(2*time.Second))
+			require.NoError(t, trace.Err)
+			assert.Equal(t, []time.Duration{tc.want, tc.want}, trace.Sleeps)
+		})
+	}
+}
+
+func TestWait_IntervalHintBounds(t *testing.T) {
+	for name, tc := range map[string]struct {
+		hint string
+		want time.Duration
+	}{
+		"within":     {"5", 5 * time.Second},
+		"below":      {"0.5", time.Second},
+		"above":      {"3600", time.Minute},
+		"zero":       {"0", 2 * time.Second},
+		"duration":   {"90s", time.Minute},
+		"fractional": {"1.25", 1250 * time.Millisecond},
+	} {
+		t.Run(name, func(t *testing.T) {
+			trace := operationtest.Run(context.Background(), hinted(tc.hint), waitInterval(2*time.Second, operation.WithIntervalHintBounds(time.Second, time.Minute)))
+			require.NoError(t, trace.Err)
+			assert.Equal(t, []time.Duration{tc.want, tc.want}, trace.Sleeps)
+		})
+	}
+}
+
+func TestWait_ClockNotFoundRetries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		errs      []codes.Code
+		sleeps    int
+		exhausted bool
+	}{
+		"no failures":    {nil, 2, false},
+		"one NotFound":   {[]codes.Code{codes.NotFound}, 3, false},
+		"three NotFound": {[]codes.Code{codes.NotFound, codes.NotFound, codes.NotFound}, 5, false},
+		"four NotFound":  {[]codes.Code{codes.NotFound, codes.NotFound, codes.NotFound, codes.NotFound}, 3, true},
+		"interleaved":    {[]codes.Code{codes.NotFound, codes.Unavailable, codes.NotFound}, 5, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var script []operationtest.Step
+			for _, code := range tc.errs {
+				script = append(script, operationtest.PollError(code))
+			}
+			script = append(script, operationtest.Running(), operationtest.Running(), operationtest.Done())
+			log := operation.NewDecisionLog(0)
+			trace := operationtest.Run(context.Background(), script, waitInterval(2*time.Second, operation.WithDecisionLog(log)))
+			if tc.exhausted {
+				assert.ErrorIs(t, trace.Err, operation.ErrPollRetriesExhausted)
+			} else {
+				assert.NoError(t, trace.Err)
+			}
+			require.Len(t, trace.Sleeps, tc.sleeps)
+			for _, d := range trace.Sleeps {
+				assert.Equal(t, 2*time.Second, d, "failed polls are retried after the poll interval")
+			}
+			for i, p := range trace.Polls {
+				assert.Equal(t, time.Duration(i)*2*time.Second, p.At)
+			}
+			for i, d := range log.Decisions() {
+				if i < len(tc.errs) {
+					assert.Equal(t, i+1, d.Failures)
+				}
+				if i > 0 {
+					assert.Equal(t, 2*time.Second, d.At.Sub(log.Decisions()[i-1].At), "the decisions are timed with the clock")
+				}
+			}
+		})
+	}
+}
+
+func TestWait_SuggestedPollInterval(t *testing.T) {
+	trace := operationtest.Run(context.Background(), hinted("0.5"), waitInterval(0))
+	require.NoError(t, trace.Err)
+	assert.Equal(t, 500*time.Millisecond, trace.Operation.SuggestedPollInterval())
+
+	trace = operationtest.Run(context.Background(), hinted(""), waitInterval(0))
+	require.NoError(t, trace.Err)
+	assert.Zero(t, trace.Operation.SuggestedPollInterval())
+}
@@ -0,0 +1,127 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ErrUnmarshalOptions is returned for conflicting UnmarshalOption values.
+var ErrUnmarshalOptions = errors.New("operation: strict decoding doesn't allow unresolved Any messages")
+
+// UnmarshalOption configures the decoding of Any messages of operations, see ErrorDetails.
+type UnmarshalOption func(*unmarshalOptions)
+
+type unmarshalOptions struct {
+	resolver        protoregistry.MessageTypeResolver
+	allowUnresolved bool
+	strict          bool
+}
+
+// WithAllowUnresolvedAny returns Any messages of types unknown to the resolver as is,
+// instead of failing. The server may send types newer than the compiled genproto.
+func WithAllowUnresolvedAny() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.allowUnresolved = true }
+}
+
+// WithTypeResolver resolves the types of Any messages with r instead of
+// protoregistry.GlobalTypes, e.g. with dynamicpb types built from a descriptor set.
+func WithTypeResolver(r protoregistry.MessageTypeResolver) UnmarshalOption {
+	return func(o *unmarshalOptions) { o.resolver = r }
+}
+
+// WithStrictDecoding fails on decoded messages with fields unknown to their type, at any depth.
+// It can't be combined with WithAllowUnresolvedAny.
+func WithStrictDecoding() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.strict = true }
+}
+
+func newUnmarshalOptions(opts []UnmarshalOption) (*unmarshalOptions, error) {
+	o := &unmarshalOptions{resolver: protoregistry.GlobalTypes}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.strict && o.allowUnresolved {
+		return nil, ErrUnmarshalOptions
+	}
+	return o, nil
+}
+
+// ErrorDetails decodes the details of the operation error, nil if the operation has no error.
+// By default the types of the details are resolved with protoregistry.GlobalTypes and
+// unresolved types fail the decoding, see UnmarshalOption.
+func (o *Operation) ErrorDetails(opts ...UnmarshalOption) ([]proto.Message, error) {
+	options, err := newUnmarshalOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var details []proto.Message
+	for _, d := range o.Proto().GetError().GetDetails() {
+		m, err := unmarshalAny(d, options)
+		if err != nil {
+			return nil, fmt.Errorf("%s error details: %w", o, err)
+		}
+		details = append(details, m)
+	}
+	return details, nil
+}
+
+// unmarshalAny decodes msg, returning msg itself if its type is unresolved and that is allowed.
+func unmarshalAny(msg *anypb.Any, o *unmarshalOptions) (proto.Message, error) {
+	if msg == nil {
+		return nil, nil
+	}
+	mt, err := o.resolver.FindMessageByURL(msg.GetTypeUrl())
+	if errors.Is(err, protoregistry.NotFound) && o.allowUnresolved {
+		return msg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %w", msg.GetTypeUrl(), err)
+	}
+	m := mt.New().Interface()
+	if err := proto.Unmarshal(msg.GetValue(), m); err != nil {
+		return nil, fmt.Errorf("unmarshal %q: %w", msg.GetTypeUrl(), err)
+	}
+	if o.strict {
+		if path, ok := unknownFields(m.ProtoReflect(), string(m.ProtoReflect().Descriptor().FullName())); ok {
+			return nil, fmt.Errorf("unmarshal %q: unknown fields in %s", msg.GetTypeUrl(), path)
+		}
+	}
+	return m, nil
+}
+
+// unknownFields returns the path of the first message with unknown fields.
+func unknownFields(m protoreflect.Message, path string) (string, bool) {
+	if len(m.GetUnknown()) > 0 {
+		return path, true
+	}
+	var found string
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil {
+			return true
+		}
+		var ok bool
+		switch {
+		case fd.IsList():
+			for i := 0; i < v.List().Len() && !ok; i++ {
+				found, ok = unknownFields(v.List().Get(i).Message(), fmt.Sprintf("%s.%s[%d]", path, fd.Name(), i))
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				found, ok = unknownFields(v.Message(), fmt.Sprintf("%s.%s[%v]", path, fd.Name(), k.Interface()))
+				return !ok
+			})
+		default:
+			found, ok = unknownFields(v.Message(), fmt.Sprintf("%s.%s", path, fd.Name()))
+		}
+		return !ok
+	})
+	return found, found != ""
+}
@@ -0,0 +1,118 @@
+package operation
+
+import (
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// quotaDetailURL is the type URL of a detail type absent from the compiled registry.
+const quotaDetailURL = "type.googleapis.com/example.v2.QuotaDetail"
+
+// quotaDetail returns the detail encoded as example.v2.QuotaDetail{string reason = 1;}.
+func quotaDetail(reason string) *anypb.Any {
+	value := protowire.AppendTag(nil, 1, protowire.BytesType)
+	value = protowire.AppendString(value, reason)
+	return &anypb.Any{TypeUrl: quotaDetailURL, Value: value}
+}
+
+// quotaTypes resolves example.v2.QuotaDetail with a dynamic type built from its descriptor.
+func quotaTypes(t *testing.T) *protoregistry.Types {
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("example/v2/quota.proto"),
+		Package: proto.String("example.v2"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("QuotaDetail"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("reason"),
+				JsonName: proto.String("reason"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	types := &protoregistry.Types{}
+	require.NoError(t, types.RegisterMessage(dynamicpb.NewMessageType(fd.Messages().Get(0))))
+	require.NoError(t, types.RegisterMessage((&errdetails.ErrorInfo{}).ProtoReflect().Type()))
+	return types
+}
+
+func failedWithDetails(t *testing.T, details ...proto.Message) *Operation {
+	st := &rpcstatus.Status{Code: 8, Message: "quota exceeded"}
+	for _, d := range details {
+		if a, ok := d.(*anypb.Any); ok {
+			st.Details = append(st.Details, a)
+			continue
+		}
+		a, err := anypb.New(d)
+		require.NoError(t, err)
+		st.Details = append(st.Details, a)
+	}
+	return New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE, Error: st})
+}
+
+func TestErrorDetails(t *testing.T) {
+	info := &errdetails.ErrorInfo{Reason: "QUOTA"}
+	op := failedWithDetails(t, info, quotaDetail("disk"))
+
+	t.Run("default", func(t *testing.T) {
+		_, err := op.ErrorDetails()
+		assert.ErrorIs(t, err, protoregistry.NotFound)
+		assert.Contains(t, err.Error(), quotaDetailURL)
+	})
+	t.Run("allow unresolved", func(t *testing.T) {
+		details, err := op.ErrorDetails(WithAllowUnresolvedAny())
+		require.NoError(t, err)
+		require.Len(t, details, 2)
+		assert.True(t, proto.Equal(info, details[0]))
+		assert.True(t, proto.Equal(quotaDetail("disk"), details[1]), "unresolved details are returned raw")
+	})
+	t.Run("type resolver", func(t *testing.T) {
+		details, err := op.ErrorDetails(WithTypeResolver(quotaTypes(t)))
+		require.NoError(t, err)
+		require.Len(t, details, 2)
+		assert.True(t, proto.Equal(info, details[0]))
+		m := details[1].ProtoReflect()
+		assert.Equal(t, "example.v2.QuotaDetail", string(m.Descriptor().FullName()))
+		assert.Equal(t, "disk", m.Get(m.Descriptor().Fields().ByName("reason")).String())
+	})
+
+	details, err := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE}).ErrorDetails()
+	require.NoError(t, err)
+	assert.Nil(t, details)
+}
+
+func TestErrorDetails_Strict(t *testing.T) {
+	_, err := failedWithDetails(t).ErrorDetails(WithStrictDecoding(), WithAllowUnresolvedAny())
+	assert.ErrorIs(t, err, ErrUnmarshalOptions)
+
+	// ErrorInfo from a newer server with field 10, unknown to the compiled type.
+	newer, err := anypb.New(&errdetails.ErrorInfo{Reason: "QUOTA"})
+	require.NoError(t, err)
+	newer.Value = protowire.AppendVarint(protowire.AppendTag(newer.Value, 10, protowire.VarintType), 1)
+	op := failedWithDetails(t, newer)
+
+	details, err := op.ErrorDetails()
+	require.NoError(t, err)
+	assert.Equal(t, "QUOTA", details[0].(*errdetails.ErrorInfo).GetReason())
+
+	_, err = op.ErrorDetails(WithStrictDecoding())
+	assert.EqualError(t, err, `operation (id=cho1) error details: unmarshal "type.googleapis.com/google.rpc.ErrorInfo": unknown fields in google.rpc.ErrorInfo`)
+
+	_, err = failedWithDetails(t, quotaDetail("disk")).ErrorDetails(WithStrictDecoding())
+	assert.ErrorIs(t, err, protoregistry.NotFound)
+}
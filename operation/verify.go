@@ -0,0 +1,99 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const DefaultVerifyTimeout = 30 * time.Second
+
+// ErrVerificationTimeout is matched by errors.Is for every *VerificationTimeoutError.
+var ErrVerificationTimeout = errors.New("operation: verification timeout")
+
+// VerifyFunc reports whether the change made by an operation is visible on reads.
+type VerifyFunc func(ctx context.Context) (bool, error)
+
+// VerifyConfig configures WaitAndVerify.
+type VerifyConfig struct {
+	// Interval between verification attempts. Defaults to the intervals of the PollPolicy of
+	// the operation, see PollPolicy.Delay, DefaultPollInterval without one.
+	Interval time.Duration
+	// Timeout bounds verification after the operation is done. Defaults to DefaultVerifyTimeout.
+	Timeout time.Duration
+}
+
+// VerificationTimeoutError is returned by WaitAndVerify when the operation succeeded,
+// but the change was not observed within the verification timeout.
+type VerificationTimeoutError struct {
+	Operation *Operation
+	Attempts  int
+	// LastErr is the error returned by the last verification attempt, if any.
+	LastErr error
+}
+
+func (e *VerificationTimeoutError) Error() string {
+	msg := fmt.Sprintf("%s done, but change is not visible after %d verification attempts", e.Operation, e.Attempts)
+	if e.LastErr != nil {
+		msg += ": " + e.LastErr.Error()
+	}
+	return msg
+}
+
+func (e *VerificationTimeoutError) Is(target error) bool { return target == ErrVerificationTimeout }
+func (e *VerificationTimeoutError) Unwrap() error        { return e.LastErr }
+
+// WaitAndVerify waits for the operation and then calls verify until it reports true.
+// Reads right after an operation completes may return the previous state of the resource
+// due to replica lag, so verification attempts that report false or fail are retried
+// until cfg.Timeout expires, and then *VerificationTimeoutError is returned.
+func WaitAndVerify(ctx context.Context, op *Operation, verify VerifyFunc, cfg VerifyConfig, opts ...grpc.CallOption) error {
+	if err := op.Wait(ctx, opts...); err != nil {
+		return err
+	}
+	interval := func(int) time.Duration { return cfg.Interval }
+	if cfg.Interval <= 0 {
+		interval = op.pollPolicy.Delay
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultVerifyTimeout
+	}
+	// Like the wait, the verification sleeps with the clock of opts, else of the policy.
+	clock := clockOf(op, append(op.pollPolicy.options(0), opts...))
+	deadline, stopDeadline := clock.newTimer(timeout)
+	defer stopDeadline()
+
+	verr := &VerificationTimeoutError{Operation: op}
+	for {
+		verr.Attempts++
+		var ok bool
+		err := SafeCall("verify", func() (err error) {
+			ok, err = verify(ctx)
+			return err
+		})
+		if errors.Is(err, ErrCallbackPanicked) {
+			return sdkerrors.WithMessagef(err, "%s verification", op)
+		}
+		if ok && err == nil {
+			return nil
+		}
+		verr.LastErr = err
+
+		wait, stop := clock.newTimer(interval(verr.Attempts))
+		select {
+		case <-wait():
+		case <-deadline():
+			stop()
+			return verr
+		case <-ctx.Done():
+			stop()
+			return sdkerrors.WithMessagef(ctx.Err(), "%s verification context done", op)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+func TestWaitAndVerify_StaleReads(t *testing.T) {
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE})
+	reads := 0
+	verify := func(ctx context.Context) (bool, error) {
+		reads++
+		return reads > 2, nil
+	}
+	err := WaitAndVerify(context.Background(), op, verify, VerifyConfig{Interval: time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, 3, reads)
+}
+
+func TestWaitAndVerify_Timeout(t *testing.T) {
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE})
+	lastErr := errors.New("not found yet")
+	verify := func(ctx context.Context) (bool, error) { return false, lastErr }
+
+	err := WaitAndVerify(context.Background(), op, verify, VerifyConfig{Interval: time.Millisecond, Timeout: 20 * time.Millisecond})
+	require.ErrorIs(t, err, ErrVerificationTimeout)
+	require.ErrorIs(t, err, lastErr)
+	var verr *VerificationTimeoutError
+	require.True(t, errors.As(err, &verr))
+	assert.Same(t, op, verr.Operation)
+	assert.Greater(t, verr.Attempts, 1)
+}
+
+func TestWaitAndVerify_FailedOperation(t *testing.T) {
+	st := status.Status{Message: "internal error", Code: int32(code.Code_INTERNAL)}
+	op := New(nil, &Proto{Id: "cho1", Status: doublecloud.Operation_STATUS_DONE, Error: &st})
+	called := false
+	err := WaitAndVerify(context.Background(), op, func(ctx context.Context) (bool, error) {
+		called = true
+		return true, nil
+	}, VerifyConfig{})
+	assert.Error(t, err)
+	assert.False(t, called)
+}
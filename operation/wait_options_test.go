@@ -0,0 +1,141 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// headerKafkaClient fills the header call option of every Get with the polled operation ID
+// and records which operations each header destination was filled for.
+type headerKafkaClient struct {
+	fakeKafkaClient
+
+	mu      sync.Mutex
+	headers map[*metadata.MD]map[string]bool
+}
+
+func (f *headerKafkaClient) Get(ctx context.Context, in *kafka.GetOperationRequest, opts ...grpc.CallOption) (*Proto, error) {
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok {
+			f.mu.Lock()
+			if f.headers[h.HeaderAddr] == nil {
+				f.headers[h.HeaderAddr] = map[string]bool{}
+			}
+			f.headers[h.HeaderAddr][in.GetOperationId()] = true
+			f.mu.Unlock()
+			*h.HeaderAddr = metadata.Pairs("operation-id", in.GetOperationId())
+		}
+	}
+	return f.fakeKafkaClient.Get(ctx, in, opts...)
+}
+
+func TestWait_SharedCallOptions(t *testing.T) {
+	requireKinds(t)
+	client := &headerKafkaClient{headers: map[*metadata.MD]map[string]bool{}}
+	client.get = func(n int, id string) (*Proto, error) {
+		status := doublecloud.Operation_STATUS_RUNNING
+		if n > 40 {
+			status = doublecloud.Operation_STATUS_DONE
+		}
+		return &Proto{Id: id, Status: status}, nil
+	}
+
+	// Spare capacity lets append write past len into the shared backing array.
+	opts := make([]grpc.CallOption, 1, 8)
+	opts[0] = grpc.EmptyCallOption{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		op := New(client, &Proto{Id: fmt.Sprintf("kfo%d", i), Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = fastTimer
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, op.Wait(context.Background(), opts...))
+		}()
+	}
+	wg.Wait()
+
+	require.NotEmpty(t, client.headers)
+	for _, ops := range client.headers {
+		assert.Len(t, ops, 1, "header destination shared between operations")
+	}
+	assert.Len(t, opts, 1)
+}
+
+func TestWait_PollCallback(t *testing.T) {
+	requireKinds(t)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		switch {
+		case n == 1:
+			return nil, grpcstatus.Error(codes.NotFound, "not replicated yet")
+		case n < 4:
+			return &Proto{Id: id, Status: doublecloud.Operation_STATUS_RUNNING}, nil
+		}
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = fastTimer
+
+	var attempts []int
+	var errs []codes.Code
+	var statuses []doublecloud.Operation_Status
+	require.NoError(t, op.Wait(context.Background(), WithPollCallback(func(o *Operation, attempt int, err error) {
+		attempts = append(attempts, attempt)
+		errs = append(errs, grpcstatus.Code(err))
+		statuses = append(statuses, o.Proto().GetStatus())
+	})))
+	assert.Equal(t, []int{1, 2, 3, 4}, attempts)
+	assert.Equal(t, []codes.Code{codes.NotFound, codes.OK, codes.OK, codes.OK}, errs, "failed polls are reported")
+	assert.Equal(t, doublecloud.Operation_STATUS_DONE, statuses[3])
+
+	done := New(client, &Proto{Id: "kfo2", Status: doublecloud.Operation_STATUS_DONE})
+	require.NoError(t, done.Wait(context.Background(), WithPollCallback(func(*Operation, int, error) {
+		t.Fatal("no poll expected")
+	})))
+}
+
+func TestWait_PollCallbackPanic(t *testing.T) {
+	requireKinds(t)
+	SetWarningHandler(func(error) {})
+	defer SetWarningHandler(nil)
+	client := &fakeKafkaClient{get: func(n int, id string) (*Proto, error) {
+		return &Proto{Id: id, Status: doublecloud.Operation_STATUS_DONE}, nil
+	}}
+	op := New(client, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+
+	err := op.Wait(context.Background(), WithPollCallback(func(*Operation, int, error) { panic("boom") }))
+	assert.NoError(t, err, "the wait does not depend on the callback")
+}
+
+func TestWaitInterval_NonPositive(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		timer := &recordingTimer{}
+		op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+		op.newTimer = timer.newTimer
+		poll := scriptedPoll(pollStep{status: doublecloud.Operation_STATUS_RUNNING}, pollStep{status: doublecloud.Operation_STATUS_RUNNING}, pollStep{status: doublecloud.Operation_STATUS_DONE})
+		require.NoError(t, op.WaitInterval(context.Background(), interval, WithPollFunc(poll)))
+		assert.Equal(t, []time.Duration{DefaultPollInterval, DefaultPollInterval}, timer.intervals, "sleeps between polls with interval %s", interval)
+	}
+}
+
+func TestWaitInterval_BusyPoll(t *testing.T) {
+	timer := &recordingTimer{}
+	op := New(nil, &Proto{Id: "kfo1", Status: doublecloud.Operation_STATUS_PENDING})
+	op.newTimer = timer.newTimer
+	poll := scriptedPoll(pollStep{status: doublecloud.Operation_STATUS_RUNNING}, pollStep{status: doublecloud.Operation_STATUS_DONE})
+	require.NoError(t, op.WaitInterval(context.Background(), 0, WithPollFunc(poll), WithBusyPoll()))
+	assert.Empty(t, timer.intervals)
+}
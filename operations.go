@@ -0,0 +1,417 @@
+package dcsdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// ServiceKind identifies a DoubleCloud service owning operations.
+type ServiceKind = Endpoint
+
+// OperationState is a coarse operation status used for filtering and summaries.
+type OperationState int
+
+const (
+	// OperationAnyState matches every operation in Filter.
+	OperationAnyState OperationState = iota
+	OperationRunning
+	OperationSucceeded
+	OperationFailed
+)
+
+func (s OperationState) String() string {
+	switch s {
+	case OperationAnyState:
+		return "any"
+	case OperationRunning:
+		return "running"
+	case OperationSucceeded:
+		return "succeeded"
+	case OperationFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// operationState returns the state of a listed operation, consistent with operation.StatusDone:
+// the operations that ended INVALID are failed, and the ones of a status newer than the SDK
+// are running.
+func operationState(o *dcv1.Operation) OperationState {
+	switch o.GetStatus() {
+	case dcv1.Operation_STATUS_PENDING, dcv1.Operation_STATUS_RUNNING:
+		return OperationRunning
+	case dcv1.Operation_STATUS_INVALID:
+		return OperationFailed
+	case dcv1.Operation_STATUS_DONE:
+		if o.GetError() != nil {
+			return OperationFailed
+		}
+		return OperationSucceeded
+	default:
+		sdkerrors.UnknownEnum(string(o.GetStatus().Descriptor().FullName()), int32(o.GetStatus()))
+		return OperationRunning
+	}
+}
+
+// Filter selects operations listed by Operations.List.
+// The services only filter by project, so the other criteria are applied on the client.
+type Filter struct {
+	ProjectID string
+	// Kinds limits listing to the given services. Empty means all services that support listing.
+	Kinds []ServiceKind
+	// Status limits listing to operations in the given state. Zero value matches all.
+	Status OperationState
+	// CreatedAfter and CreatedBefore bound the operation creation time. Zero values are ignored.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Order sorts the operations of every service, which are listed in the order of Kinds.
+	// Defaults to ByCreateTime.
+	Order func(a, b *OperationSummary) bool
+}
+
+func (f *Filter) match(o *dcv1.Operation) bool {
+	if f.Status != OperationAnyState && operationState(o) != f.Status {
+		return false
+	}
+	created := o.GetCreateTime().AsTime()
+	if !f.CreatedAfter.IsZero() && created.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !created.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// ByCreateTime orders operations by creation time, then by ID.
+func ByCreateTime(a, b *OperationSummary) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// ByResourceID orders operations by resource ID, then by creation time and ID.
+func ByResourceID(a, b *OperationSummary) bool {
+	if a.ResourceID != b.ResourceID {
+		return a.ResourceID < b.ResourceID
+	}
+	return ByCreateTime(a, b)
+}
+
+// fingerprint identifies the listing of the filter in cursors.
+func (f *Filter) fingerprint(kinds []ServiceKind) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%v|%d|%d|%d", f.ProjectID, kinds, f.Status, f.CreatedAfter.UnixNano(), f.CreatedBefore.UnixNano())
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// OperationSummary is a service independent view of an operation.
+type OperationSummary struct {
+	ID         string
+	Kind       ServiceKind
+	ProjectID  string
+	ResourceID string
+	Status     OperationState
+	CreatedAt  time.Time
+	CreatedBy  string
+	// Duration is the time from creation till finish, zero for running operations.
+	Duration time.Duration
+	// ErrorCode is codes.OK unless the operation failed.
+	ErrorCode codes.Code
+}
+
+func summarize(kind ServiceKind, o *dcv1.Operation) OperationSummary {
+	s := OperationSummary{
+		ID:         o.GetId(),
+		Kind:       kind,
+		ProjectID:  o.GetProjectId(),
+		ResourceID: o.GetResourceId(),
+		Status:     operationState(o),
+		CreatedAt:  o.GetCreateTime().AsTime(),
+		CreatedBy:  o.GetCreatedBy(),
+	}
+	if s.Status != OperationRunning && o.GetFinishTime() != nil {
+		s.Duration = o.GetFinishTime().AsTime().Sub(s.CreatedAt)
+	}
+	if o.GetError() != nil {
+		s.ErrorCode = status.FromProto(o.GetError()).Code()
+	}
+	return s
+}
+
+// operationKinds are the services listed when Filter.Kinds is empty.
+// Transfer operations can only be fetched by ID, so listing them explicitly fails with Unimplemented.
+var operationKinds = []ServiceKind{ClickHouseServiceID, KafkaServiceID, VpcServiceID}
+
+// Operations lists operations across services.
+type Operations struct {
+	sdk *SDK
+}
+
+// Operations returns a client listing operations of all services.
+func (sdk *SDK) Operations() *Operations {
+	return &Operations{sdk: sdk}
+}
+
+// List returns an iterator over operations of the services selected by the filter.
+// Services are listed lazily one after another, the operations of every service sorted by
+// Filter.Order. A failure to list one service does not stop the iteration; see
+// OperationSummaryIterator.Error.
+func (ops *Operations) List(ctx context.Context, filter Filter, opts ...grpc.CallOption) *OperationSummaryIterator {
+	return ops.ListFrom(ctx, filter, "", opts...)
+}
+
+// ListFrom is List resuming after the operations returned before the cursor of
+// OperationSummaryIterator.Cursor. Services drained before the cursor are not listed again.
+// The listing starts over from the beginning when the cursor is invalid, encodes another
+// filter or its last operation is gone; see OperationSummaryIterator.Restarted.
+func (ops *Operations) ListFrom(ctx context.Context, filter Filter, cursor string, opts ...grpc.CallOption) *OperationSummaryIterator {
+	kinds := filter.Kinds
+	if len(kinds) == 0 {
+		kinds = operationKinds
+	}
+	if filter.Order == nil {
+		filter.Order = ByCreateTime
+	}
+	it := &OperationSummaryIterator{ctx: ctx, opts: opts, ops: ops, filter: filter, kinds: kinds, from: cursor}
+	it.leak = lifecycle.Track(it, "dcsdk.OperationSummaryIterator")
+	if cursor != "" {
+		c, err := decodeListCursor(cursor)
+		if err != nil || c.Filter != filter.fingerprint(kinds) || c.Next > len(kinds) || (c.Pos > 0 && c.Next == 0) {
+			it.restarted = true
+		} else {
+			it.resume = c
+			it.next = c.Next
+			if c.Pos > 0 {
+				// The service of Last is listed again to skip the operations returned.
+				it.next--
+			}
+		}
+	}
+	return it
+}
+
+func (ops *Operations) list(ctx context.Context, kind ServiceKind, projectID string, opts []grpc.CallOption) ([]*dcv1.Operation, error) {
+	switch kind {
+	case ClickHouseServiceID:
+		return ops.sdk.ClickHouse().Operation().OperationIterator(ctx, &clickhouse.ListOperationsRequest{ProjectId: projectID}, opts...).TakeAll()
+	case KafkaServiceID:
+		return ops.sdk.Kafka().Operation().OperationIterator(ctx, &kafka.ListOperationsRequest{ProjectId: projectID}, opts...).TakeAll()
+	case VpcServiceID:
+		return ops.sdk.Network().Operation().OperationIterator(ctx, &network.ListOperationsRequest{ProjectId: projectID}, opts...).TakeAll()
+	case TransferServiceID:
+		return nil, status.Error(codes.Unimplemented, "transfer service does not support operation listing")
+	default:
+		return nil, fmt.Errorf("unknown service kind %q", kind)
+	}
+}
+
+// OperationSummaryIterator iterates over operations listed by Operations.List.
+type OperationSummaryIterator struct {
+	ctx    context.Context
+	opts   []grpc.CallOption
+	ops    *Operations
+	filter Filter
+
+	kinds []ServiceKind
+	// next is the index of the next service to list in kinds.
+	next int
+	// items are the operations of kinds[next-1] left, items[0] is Value.
+	items []OperationSummary
+	// pos is the number of operations of kinds[next-1] returned, including Value.
+	pos int
+
+	from      string
+	started   bool
+	resume    *listCursor
+	restarted bool
+
+	errs   map[ServiceKind]error
+	merged error
+	leak   *lifecycle.Tracker
+}
+
+func (it *OperationSummaryIterator) Next() bool {
+	it.started = true
+	if len(it.items) > 0 {
+		it.items = it.items[1:]
+	}
+	for len(it.items) == 0 && it.next < len(it.kinds) {
+		kind := it.kinds[it.next]
+		it.next++
+		it.pos = 0
+		if err := it.ctx.Err(); err != nil {
+			it.fail(kind, err)
+			continue
+		}
+		listed, err := it.ops.list(it.ctx, kind, it.filter.ProjectID, it.opts)
+		if err != nil {
+			it.fail(kind, err)
+			continue
+		}
+		for _, o := range listed {
+			if it.filter.match(o) {
+				it.items = append(it.items, summarize(kind, o))
+			}
+		}
+		sort.SliceStable(it.items, func(i, j int) bool { return it.filter.Order(&it.items[i], &it.items[j]) })
+		if it.resume != nil {
+			c := it.resume
+			it.resume = nil
+			if c.Pos > 0 && !it.skipTo(c.Last) {
+				// The last operation of the cursor is gone, so its position is unknown.
+				it.restarted = true
+				it.items = nil
+				it.next = 0
+			}
+		}
+	}
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	it.pos++
+	return true
+}
+
+// skipTo drops the items up to the operation with the given ID.
+func (it *OperationSummaryIterator) skipTo(id string) bool {
+	for i := range it.items {
+		if it.items[i].ID == id {
+			it.items = it.items[i+1:]
+			it.pos = i + 1
+			return true
+		}
+	}
+	return false
+}
+
+func (it *OperationSummaryIterator) fail(kind ServiceKind, err error) {
+	if it.errs == nil {
+		it.errs = map[ServiceKind]error{}
+	}
+	err = sdkerrors.WithMessagef(err, "list %s operations", kind)
+	it.errs[kind] = err
+	it.merged = multierror.Append(it.merged, err)
+}
+
+func (it *OperationSummaryIterator) Value() OperationSummary {
+	if len(it.items) == 0 {
+		panic("calling Value on empty iterator")
+	}
+	return it.items[0]
+}
+
+// Take returns up to size next operations, e.g. a page of the listing to be continued
+// with ListFrom and Cursor. Operations of services that were listed successfully are
+// returned even if the error is not nil.
+func (it *OperationSummaryIterator) Take(size int) ([]OperationSummary, error) {
+	var result []OperationSummary
+	for len(result) < size && it.Next() {
+		result = append(result, it.Value())
+	}
+	return result, it.Error()
+}
+
+// TakeAll returns all remaining operations. Operations of services that were listed
+// successfully are returned even if the error is not nil.
+func (it *OperationSummaryIterator) TakeAll() ([]OperationSummary, error) {
+	var result []OperationSummary
+	for it.Next() {
+		result = append(result, it.Value())
+	}
+	return result, it.Error()
+}
+
+// Cursor returns the opaque cursor resuming the listing after Value with ListFrom, or an
+// empty string once Next reported the end of the listing. Services that failed before the
+// cursor are not listed again on resume, see ServiceErrors.
+func (it *OperationSummaryIterator) Cursor() string {
+	if !it.started {
+		return it.from
+	}
+	if len(it.items) == 0 {
+		return ""
+	}
+	c := &listCursor{Version: listCursorVersion, Filter: it.filter.fingerprint(it.kinds), Next: it.next}
+	if len(it.items) > 1 {
+		c.Pos, c.Last = it.pos, it.items[0].ID
+	}
+	return encodeListCursor(c)
+}
+
+// Restarted reports whether the cursor of ListFrom was stale or invalid, so the listing
+// started over and returns again the operations returned before the cursor.
+func (it *OperationSummaryIterator) Restarted() bool {
+	return it.restarted
+}
+
+// Close ends the listing and releases the operations listed and not returned yet: Next
+// returns false from then on.
+func (it *OperationSummaryIterator) Close() {
+	it.items, it.next, it.resume = nil, len(it.kinds), nil
+	it.leak.Close()
+}
+
+// Error returns failures of the services listed so far combined into one error.
+func (it *OperationSummaryIterator) Error() error {
+	return it.merged
+}
+
+// ServiceErrors returns failures of the services listed so far by service.
+func (it *OperationSummaryIterator) ServiceErrors() map[ServiceKind]error {
+	return it.errs
+}
+
+const listCursorVersion = 1
+
+// listCursor is the position of a listing of Operations.ListFrom: the services before Next
+// are drained, except for the service Next-1 if Pos is positive: Pos of its operations were
+// returned, the last one being Last.
+type listCursor struct {
+	Version int    `json:"v"`
+	Filter  string `json:"f"`
+	Next    int    `json:"n"`
+	Pos     int    `json:"p,omitempty"`
+	Last    string `json:"l,omitempty"`
+}
+
+func encodeListCursor(c *listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Version != listCursorVersion {
+		return nil, fmt.Errorf("unsupported cursor version %d", c.Version)
+	}
+	return &c, nil
+}
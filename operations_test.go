@@ -0,0 +1,356 @@
+package dcsdk
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+type fakeKafkaOperationList struct {
+	kafka.UnimplementedOperationServiceServer
+	ops   []*dcv1.Operation
+	lists int
+}
+
+func (f *fakeKafkaOperationList) List(ctx context.Context, req *kafka.ListOperationsRequest) (*kafka.ListOperationsResponse, error) {
+	f.lists++
+	return &kafka.ListOperationsResponse{Operations: f.ops}, nil
+}
+
+type fakeNetworkOperationList struct {
+	network.UnimplementedOperationServiceServer
+}
+
+func (fakeNetworkOperationList) List(ctx context.Context, req *network.ListOperationsRequest) (*network.ListOperationsResponse, error) {
+	return nil, status.Error(codes.Unavailable, "network is down")
+}
+
+type listedNetworkOperations struct {
+	network.UnimplementedOperationServiceServer
+	ops   []*dcv1.Operation
+	lists int
+}
+
+func (f *listedNetworkOperations) List(ctx context.Context, req *network.ListOperationsRequest) (*network.ListOperationsResponse, error) {
+	f.lists++
+	return &network.ListOperationsResponse{Operations: f.ops}, nil
+}
+
+var opsEpoch = time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func listedOp(id string, createdAfter time.Duration, st dcv1.Operation_Status, failure code.Code) *dcv1.Operation {
+	o := &dcv1.Operation{
+		Id:         id,
+		ResourceId: "res-" + id,
+		CreatedBy:  "alice",
+		Status:     st,
+		CreateTime: timestamppb.New(opsEpoch.Add(createdAfter)),
+	}
+	if st == dcv1.Operation_STATUS_DONE {
+		o.FinishTime = timestamppb.New(opsEpoch.Add(createdAfter + time.Minute))
+	}
+	if failure != code.Code_OK {
+		o.Error = &rpcstatus.Status{Code: int32(failure), Message: "failed"}
+	}
+	return o
+}
+
+func newOperationsTestSDK(t *testing.T) *SDK {
+	return newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterOperationServiceServer(s, &fakeClickHouseOperationList{ops: []*dcv1.Operation{
+			listedOp("cho-early", -time.Hour, dcv1.Operation_STATUS_DONE, code.Code_INTERNAL),
+			listedOp("cho-failed", time.Hour, dcv1.Operation_STATUS_DONE, code.Code_RESOURCE_EXHAUSTED),
+			listedOp("cho-ok", 2*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_OK),
+		}})
+		kafka.RegisterOperationServiceServer(s, &fakeKafkaOperationList{ops: []*dcv1.Operation{
+			listedOp("kfo-running", time.Hour, dcv1.Operation_STATUS_RUNNING, code.Code_OK),
+			listedOp("kfo-failed", 3*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_INVALID_ARGUMENT),
+			listedOp("kfo-late", 48*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_INTERNAL),
+		}})
+		network.RegisterOperationServiceServer(s, fakeNetworkOperationList{})
+	})
+}
+
+func summaryIDs(ops []OperationSummary) []string {
+	ids := make([]string, len(ops))
+	for i, o := range ops {
+		ids[i] = o.ID
+	}
+	return ids
+}
+
+func TestOperationsList_FailedInWindow(t *testing.T) {
+	sdk := newOperationsTestSDK(t)
+	it := sdk.Operations().List(context.Background(), Filter{
+		ProjectID:     "prj",
+		Status:        OperationFailed,
+		CreatedAfter:  opsEpoch,
+		CreatedBefore: opsEpoch.Add(24 * time.Hour),
+	})
+	ops, err := it.TakeAll()
+
+	assert.Equal(t, []string{"cho-failed", "kfo-failed"}, summaryIDs(ops))
+	assert.Equal(t, OperationSummary{
+		ID:         "cho-failed",
+		Kind:       ClickHouseServiceID,
+		ResourceID: "res-cho-failed",
+		Status:     OperationFailed,
+		CreatedAt:  opsEpoch.Add(time.Hour),
+		CreatedBy:  "alice",
+		Duration:   time.Minute,
+		ErrorCode:  codes.ResourceExhausted,
+	}, ops[0])
+	assert.Equal(t, KafkaServiceID, ops[1].Kind)
+
+	// The network failure is reported without hiding the other services.
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "list vpc operations")
+	assert.Equal(t, codes.Unavailable, status.Code(it.ServiceErrors()[VpcServiceID]))
+	assert.Len(t, it.ServiceErrors(), 1)
+}
+
+func TestOperationsList_Kinds(t *testing.T) {
+	sdk := newOperationsTestSDK(t)
+	ops, err := sdk.Operations().List(context.Background(), Filter{
+		ProjectID: "prj",
+		Kinds:     []ServiceKind{KafkaServiceID},
+		Status:    OperationRunning,
+	}).TakeAll()
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, "kfo-running", ops[0].ID)
+	assert.Zero(t, ops[0].Duration)
+	assert.Equal(t, codes.OK, ops[0].ErrorCode)
+}
+
+func TestOperationsList_TransferUnsupported(t *testing.T) {
+	sdk := newOperationsTestSDK(t)
+	it := sdk.Operations().List(context.Background(), Filter{
+		ProjectID: "prj",
+		Kinds:     []ServiceKind{TransferServiceID, ClickHouseServiceID},
+	})
+	ops, err := it.TakeAll()
+	assert.Equal(t, []string{"cho-early", "cho-failed", "cho-ok"}, summaryIDs(ops))
+	require.Error(t, err)
+	assert.Equal(t, codes.Unimplemented, status.Code(it.ServiceErrors()[TransferServiceID]))
+}
+
+type listingFakes struct {
+	clickhouse *fakeClickHouseOperationList
+	kafka      *fakeKafkaOperationList
+	network    *listedNetworkOperations
+}
+
+func (f *listingFakes) lists() []int {
+	return []int{f.clickhouse.lists, f.kafka.lists, f.network.lists}
+}
+
+// newListingTestSDK serves the operations of three services, listed out of order.
+func newListingTestSDK(t *testing.T) (*SDK, *listingFakes) {
+	fakes := &listingFakes{
+		clickhouse: &fakeClickHouseOperationList{ops: []*dcv1.Operation{
+			listedOp("cho-ok", 2*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_OK),
+			listedOp("cho-early", -time.Hour, dcv1.Operation_STATUS_DONE, code.Code_INTERNAL),
+			listedOp("cho-failed", time.Hour, dcv1.Operation_STATUS_DONE, code.Code_RESOURCE_EXHAUSTED),
+		}},
+		kafka: &fakeKafkaOperationList{ops: []*dcv1.Operation{
+			listedOp("kfo-late", 48*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_INTERNAL),
+			listedOp("kfo-running", time.Hour, dcv1.Operation_STATUS_RUNNING, code.Code_OK),
+			listedOp("kfo-failed", 3*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_INVALID_ARGUMENT),
+		}},
+		network: &listedNetworkOperations{ops: []*dcv1.Operation{
+			listedOp("vpo-b", time.Hour, dcv1.Operation_STATUS_DONE, code.Code_OK),
+			listedOp("vpo-a", time.Hour, dcv1.Operation_STATUS_DONE, code.Code_OK),
+		}},
+	}
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterOperationServiceServer(s, fakes.clickhouse)
+		kafka.RegisterOperationServiceServer(s, fakes.kafka)
+		network.RegisterOperationServiceServer(s, fakes.network)
+	})
+	return sdk, fakes
+}
+
+func TestOperationsList_Ordered(t *testing.T) {
+	sdk, _ := newListingTestSDK(t)
+	ops, err := sdk.Operations().List(context.Background(), Filter{ProjectID: "prj"}).TakeAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"cho-early", "cho-failed", "cho-ok",
+		"kfo-running", "kfo-failed", "kfo-late",
+		"vpo-a", "vpo-b",
+	}, summaryIDs(ops))
+
+	ops, err = sdk.Operations().List(context.Background(), Filter{
+		ProjectID: "prj",
+		Kinds:     []ServiceKind{KafkaServiceID, ClickHouseServiceID},
+		Order:     func(a, b *OperationSummary) bool { return ByCreateTime(b, a) },
+	}).TakeAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kfo-late", "kfo-failed", "kfo-running", "cho-ok", "cho-failed", "cho-early"}, summaryIDs(ops))
+}
+
+func TestOperationsListFrom_Pages(t *testing.T) {
+	sdk, fakes := newListingTestSDK(t)
+	ctx := context.Background()
+	filter := Filter{ProjectID: "prj"}
+
+	var pages [][]string
+	var lists [][]int
+	cursor := ""
+	for i := 0; ; i++ {
+		require.Less(t, i, 10, "the listing never ends")
+		it := sdk.Operations().ListFrom(ctx, filter, cursor)
+		page, err := it.Take(2)
+		require.NoError(t, err)
+		assert.False(t, it.Restarted())
+		if len(page) == 0 {
+			assert.Empty(t, it.Cursor(), "the cursor is empty at the end of the listing")
+			break
+		}
+		pages = append(pages, summaryIDs(page))
+		lists = append(lists, fakes.lists())
+		cursor = it.Cursor()
+		require.NotEmpty(t, cursor)
+	}
+
+	assert.Equal(t, [][]string{
+		{"cho-early", "cho-failed"},
+		{"cho-ok", "kfo-running"},
+		{"kfo-failed", "kfo-late"},
+		{"vpo-a", "vpo-b"},
+	}, pages)
+	// Services drained by the previous pages are not listed again.
+	assert.Equal(t, [][]int{{1, 0, 0}, {2, 1, 0}, {2, 2, 0}, {2, 2, 1}}, lists)
+	assert.Equal(t, []int{2, 2, 1}, fakes.lists())
+}
+
+func TestOperationsListFrom_Changed(t *testing.T) {
+	sdk, fakes := newListingTestSDK(t)
+	ctx := context.Background()
+	filter := Filter{ProjectID: "prj"}
+
+	it := sdk.Operations().ListFrom(ctx, filter, "")
+	_, err := it.Take(4)
+	require.NoError(t, err)
+	cursor := it.Cursor()
+
+	// Operations created meanwhile before the cursor don't shift the listing.
+	fakes.kafka.ops = append(fakes.kafka.ops, listedOp("kfo-new", -time.Hour, dcv1.Operation_STATUS_RUNNING, code.Code_OK))
+	it = sdk.Operations().ListFrom(ctx, filter, cursor)
+	ops, err := it.Take(2)
+	require.NoError(t, err)
+	assert.False(t, it.Restarted())
+	assert.Equal(t, []string{"kfo-failed", "kfo-late"}, summaryIDs(ops))
+	assert.Equal(t, []int{1, 2, 0}, fakes.lists())
+}
+
+func TestOperationsListFrom_Stale(t *testing.T) {
+	sdk, fakes := newListingTestSDK(t)
+	ctx := context.Background()
+	filter := Filter{ProjectID: "prj"}
+
+	it := sdk.Operations().ListFrom(ctx, filter, "")
+	_, err := it.Take(4)
+	require.NoError(t, err)
+	cursor := it.Cursor()
+	all := []string{"cho-early", "cho-failed", "cho-ok", "kfo-running", "kfo-failed", "kfo-late", "vpo-a", "vpo-b"}
+
+	for name, tc := range map[string]struct {
+		filter Filter
+		cursor string
+	}{
+		"garbage":        {filter, "not a cursor"},
+		"version":        {filter, encodeListCursor(&listCursor{Version: 2, Filter: filter.fingerprint(operationKinds), Next: 1})},
+		"another filter": {Filter{ProjectID: "prj2"}, cursor},
+		"other kinds":    {Filter{ProjectID: "prj", Kinds: []ServiceKind{KafkaServiceID, ClickHouseServiceID, VpcServiceID}}, cursor},
+	} {
+		t.Run(name, func(t *testing.T) {
+			it := sdk.Operations().ListFrom(ctx, tc.filter, tc.cursor)
+			ops, err := it.TakeAll()
+			require.NoError(t, err)
+			assert.True(t, it.Restarted())
+			assert.ElementsMatch(t, all, summaryIDs(ops))
+		})
+	}
+
+	t.Run("gone", func(t *testing.T) {
+		// kfo-running, the last operation before the cursor, is gone.
+		fakes.kafka.ops = fakes.kafka.ops[:1]
+		it := sdk.Operations().ListFrom(ctx, filter, cursor)
+		ops, err := it.TakeAll()
+		require.NoError(t, err)
+		assert.True(t, it.Restarted())
+		assert.Equal(t, []string{"cho-early", "cho-failed", "cho-ok", "kfo-late", "vpo-a", "vpo-b"}, summaryIDs(ops))
+	})
+}
+
+func TestOperationsList_Close(t *testing.T) {
+	var ids []string
+	sdk := newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token"), DebugLeakDetection: true}, func(s *grpc.Server) {
+		kafka.RegisterOperationServiceServer(s, &fakeKafkaOperationList{ops: []*dcv1.Operation{
+			listedOp("kfo-a", time.Hour, dcv1.Operation_STATUS_DONE, code.Code_OK),
+			listedOp("kfo-b", 2*time.Hour, dcv1.Operation_STATUS_DONE, code.Code_OK),
+		}})
+	})
+	t.Cleanup(func() { lifecycle.EnableLeakDetection(false) })
+	leaks := make(chan lifecycle.Leak, 10)
+	defer lifecycle.SetLeakReporter(func(l lifecycle.Leak) { leaks <- l })()
+
+	func() {
+		it := sdk.Operations().List(context.Background(), Filter{ProjectID: "prj", Kinds: []ServiceKind{KafkaServiceID}})
+		require.True(t, it.Next())
+		ids = append(ids, it.Value().ID)
+		it.Close()
+		assert.False(t, it.Next(), "closed mid-way")
+		assert.NoError(t, it.Error())
+	}()
+	assert.Equal(t, []string{"kfo-a"}, ids)
+
+	func() {
+		abandoned := sdk.Operations().List(context.Background(), Filter{ProjectID: "prj", Kinds: []ServiceKind{KafkaServiceID}})
+		require.True(t, abandoned.Next())
+	}()
+	var leak lifecycle.Leak
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		select {
+		case leak = <-leaks:
+			return leak.Kind == "dcsdk.OperationSummaryIterator"
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond, "Config.DebugLeakDetection reports the abandoned listing")
+	assert.Contains(t, leak.Stack, "TestOperationsList_Close")
+}
+
+func TestOperationState_StatusEnum(t *testing.T) {
+	var unknown []int32
+	defer sdkerrors.SetUnknownEnumHandler(func(kind string, value int32) { unknown = append(unknown, value) })()
+
+	for v, name := range dcv1.Operation_Status_name {
+		operationState(&dcv1.Operation{Status: dcv1.Operation_Status(v)})
+		assert.Empty(t, unknown, name)
+	}
+	assert.Equal(t, OperationRunning, operationState(&dcv1.Operation{Status: dcv1.Operation_Status(99)}), "a newer status is running")
+	assert.Equal(t, []int32{99}, unknown)
+	assert.Equal(t, OperationFailed, operationState(&dcv1.Operation{Status: dcv1.Operation_STATUS_DONE, Error: &rpcstatus.Status{Code: 13}}))
+	assert.Equal(t, OperationFailed, operationState(&dcv1.Operation{Status: dcv1.Operation_STATUS_INVALID}), "done for Wait, see operation.StatusDone")
+}
@@ -0,0 +1,106 @@
+package dcsdk
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxTrackedOrigins bounds the number of remembered operation origins,
+// so operations that are never wrapped don't accumulate.
+const maxTrackedOrigins = 1024
+
+// originFields are request fields naming the resource a call works on, in lookup order.
+var originFields = []string{
+	"cluster_id",
+	"endpoint_id",
+	"transfer_id",
+	"network_id",
+	"network_connection_id",
+	"workbook_id",
+	"topic_name",
+	"user_name",
+	"name",
+}
+
+type operationOrigin struct {
+	method   string
+	resource string
+}
+
+// operationOrigins remembers which SDK call returned an operation,
+// so that WrapOperation can stamp it onto the wrapped operation.
+type operationOrigins struct {
+	mu    sync.Mutex
+	byID  map[string]operationOrigin
+	order []string
+}
+
+func newOperationOrigins() *operationOrigins {
+	return &operationOrigins{byID: map[string]operationOrigin{}}
+}
+
+func (r *operationOrigins) InterceptUnary(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, conn, opts...)
+	if err != nil || strings.Contains(method, "OperationService/") {
+		return err
+	}
+	if op, ok := reply.(*dcv1.Operation); ok && op.GetId() != "" {
+		r.put(op.GetId(), operationOrigin{method: method, resource: requestResource(req)})
+	}
+	return nil
+}
+
+func (r *operationOrigins) put(id string, o operationOrigin) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byID[id]; !ok {
+		r.order = append(r.order, id)
+	}
+	r.byID[id] = o
+	for len(r.order) > maxTrackedOrigins {
+		delete(r.byID, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *operationOrigins) take(id string) (operationOrigin, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.byID[id]
+	if ok {
+		delete(r.byID, id)
+		for i, v := range r.order {
+			if v == id {
+				r.order = append(r.order[:i], r.order[i+1:]...)
+				break
+			}
+		}
+	}
+	return o, ok
+}
+
+// requestResource returns the resource name or ID the request refers to, if any.
+func requestResource(req interface{}) string {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return ""
+	}
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for _, name := range originFields {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			continue
+		}
+		if v := m.Get(fd).String(); v != "" {
+			return v
+		}
+	}
+	return ""
+}
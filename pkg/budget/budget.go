@@ -0,0 +1,99 @@
+// Package budget divides the deadline of a context between the phases of an operation made
+// of several calls, such as a create followed by a wait, or a rollback after a failed step.
+//
+// Without a budget, a slow phase may use up the whole deadline and leave nothing to the
+// cleanup that should follow it, e.g. the deletion of a temporary resource. Cleanup returns
+// the context of such a cleanup: it is not canceled with its parent and always gets at least
+// a minimum time. Reserve and Split bound the main phases instead, for callers that want the
+// whole operation, cleanup included, to end by the deadline of their context.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultCleanupTimeout is the time given to a cleanup by Cleanup with no minimum.
+const DefaultCleanupTimeout = 30 * time.Second
+
+// Cleanup returns the context of a cleanup made after the phases run on ctx. It keeps the
+// values of ctx, such as the credentials of the SDK, but is not canceled with it: it ends
+// at the deadline of ctx or after min, whichever is later, so the cleanup gets min even if
+// the deadline of ctx has passed or ctx has none. A min of zero or less is
+// DefaultCleanupTimeout.
+func Cleanup(ctx context.Context, min time.Duration) (context.Context, context.CancelFunc) {
+	if min <= 0 {
+		min = DefaultCleanupTimeout
+	}
+	deadline := time.Now().Add(min)
+	if d, ok := ctx.Deadline(); ok && d.After(deadline) {
+		deadline = d
+	}
+	return context.WithDeadline(Detach(ctx), deadline)
+}
+
+// Reserve returns the context of the main phase of an operation, ending reserve before the
+// deadline of ctx so that reserve is left to the cleanup after it, see Cleanup. The reserve
+// is at most half the time left, leaving the main phase a chance on short deadlines. If ctx
+// has no deadline, the main phase is not bounded.
+func Reserve(ctx context.Context, reserve time.Duration) (context.Context, context.CancelFunc) {
+	d, ok := ctx.Deadline()
+	if !ok || reserve <= 0 {
+		return context.WithCancel(ctx)
+	}
+	if left := time.Until(d); reserve > left/2 {
+		reserve = left / 2
+	}
+	return context.WithDeadline(ctx, d.Add(-reserve))
+}
+
+// Plan is the division of the deadline of a context between phases, see Split.
+type Plan struct {
+	parent  context.Context
+	weights []float64
+	next    int
+}
+
+// Split divides the time left until the deadline of ctx between phases in proportion to
+// their weights. The phases are run in order, each on the context returned by Plan.Next.
+// The share of a phase is taken when it starts, of the time left then: the time a phase
+// doesn't use goes to the ones after it. If ctx has no deadline, the phases are not bounded.
+//
+// Split panics if a weight is not positive.
+func Split(ctx context.Context, weights ...float64) *Plan {
+	for _, w := range weights {
+		if w <= 0 {
+			panic(fmt.Sprintf("budget: weight %v is not positive", w))
+		}
+	}
+	return &Plan{parent: ctx, weights: weights}
+}
+
+// Next returns the context of the next phase. Past the last phase, the context ends at the
+// deadline of the parent.
+func (p *Plan) Next() (context.Context, context.CancelFunc) {
+	d, ok := p.parent.Deadline()
+	if !ok || p.next >= len(p.weights) {
+		p.next++
+		return context.WithCancel(p.parent)
+	}
+	var sum float64
+	for _, w := range p.weights[p.next:] {
+		sum += w
+	}
+	share := p.weights[p.next] / sum
+	p.next++
+	return context.WithTimeout(p.parent, time.Duration(float64(time.Until(d))*share))
+}
+
+// Detach returns a context with the values of ctx but neither its deadline nor its
+// cancellation.
+func Detach(ctx context.Context) context.Context { return detachedContext{parent: ctx} }
+
+type detachedContext struct{ parent context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
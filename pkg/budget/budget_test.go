@@ -0,0 +1,121 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type key struct{}
+
+func TestCleanup_AfterExhaustedParent(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "tenant"), time.Millisecond)
+	defer cancel()
+	<-parent.Done()
+
+	ctx, cancel := Cleanup(parent, time.Minute)
+	defer cancel()
+	require.NoError(t, ctx.Err(), "the cleanup is not canceled with its parent")
+	d, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), d, time.Second, "the cleanup gets its minimum")
+	assert.Equal(t, "tenant", ctx.Value(key{}), "the values of the parent are kept")
+}
+
+func TestCleanup_Deadlines(t *testing.T) {
+	ctx, cancel := Cleanup(context.Background(), 0)
+	defer cancel()
+	d, ok := ctx.Deadline()
+	require.True(t, ok, "a parent without deadline gives the cleanup its own timeout")
+	assert.WithinDuration(t, time.Now().Add(DefaultCleanupTimeout), d, time.Second)
+
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelParent()
+	ctx, cancel = Cleanup(parent, time.Minute)
+	defer cancel()
+	d, _ = ctx.Deadline()
+	pd, _ := parent.Deadline()
+	assert.Equal(t, pd, d, "the later deadline of the parent is kept")
+	cancelParent()
+	assert.NoError(t, ctx.Err())
+}
+
+func TestReserve(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	pd, _ := parent.Deadline()
+
+	ctx, cancel := Reserve(parent, time.Minute)
+	defer cancel()
+	d, _ := ctx.Deadline()
+	assert.Equal(t, pd.Add(-time.Minute), d)
+
+	ctx, cancel = Reserve(parent, 2*time.Hour)
+	defer cancel()
+	d, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), d, time.Second, "at most half the time left is reserved")
+
+	ctx, cancel = Reserve(context.Background(), time.Minute)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
+
+// TestReserve_CleanupAfterMainPhase runs a main phase that uses up its whole budget: the
+// cleanup after it still has the reserve.
+func TestReserve_CleanupAfterMainPhase(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	main, cancelMain := Reserve(parent, 100*time.Millisecond)
+	defer cancelMain()
+	<-main.Done()
+	require.NoError(t, parent.Err(), "the main phase ends before its parent")
+
+	ctx, cancel := Cleanup(parent, 20*time.Millisecond)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		t.Fatal("cleanup context done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	d, _ := ctx.Deadline()
+	pd, _ := parent.Deadline()
+	assert.Equal(t, pd, d, "the cleanup runs until the deadline of the parent")
+}
+
+func TestSplit(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 40*time.Minute)
+	defer cancel()
+	pd, _ := parent.Deadline()
+	p := Split(parent, 1, 2, 1)
+
+	first, cancel := p.Next()
+	defer cancel()
+	d, _ := first.Deadline()
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), d, time.Second)
+
+	second, cancel := p.Next()
+	defer cancel()
+	d, _ = second.Deadline()
+	assert.WithinDuration(t, time.Now().Add(40*time.Minute*2/3), d, time.Second, "a share of the time left, the unused one included")
+
+	third, cancel := p.Next()
+	defer cancel()
+	d, _ = third.Deadline()
+	assert.Equal(t, pd, d, "the last phase ends with the parent")
+
+	extra, cancel := p.Next()
+	defer cancel()
+	d, _ = extra.Deadline()
+	assert.Equal(t, pd, d)
+
+	unbounded, cancel := Split(context.Background(), 1, 1).Next()
+	defer cancel()
+	_, ok := unbounded.Deadline()
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Split(parent, 1, 0) })
+}
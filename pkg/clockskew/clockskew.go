@@ -0,0 +1,109 @@
+// Package clockskew estimates the offset of the server clock from the local one from
+// timestamps observed in responses.
+//
+// A sample is the server time of a response minus the local time in the middle of the call.
+// The estimate is an exponentially weighted moving average of the samples, which ignores
+// samples far from it: a single response with a stale or bogus timestamp doesn't move the
+// estimate. A run of such samples is taken for a real clock change and restarts the estimate.
+package clockskew
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWeight is the weight of a new sample in the moving average.
+	DefaultWeight = 0.2
+	// DefaultMinTolerance is the lowest distance from the estimate at which samples are taken
+	// for outliers, however stable the previous samples were.
+	DefaultMinTolerance = time.Second
+	// warmupSamples are always accepted, so that the first estimate can settle.
+	warmupSamples = 3
+	// toleranceDeviations is the distance from the estimate, in mean deviations, at which
+	// samples are taken for outliers.
+	toleranceDeviations = 4
+	// outlierRun is the number of consecutive outliers that restarts the estimate.
+	outlierRun = 5
+)
+
+// Estimator estimates the clock offset. It is safe for concurrent use.
+type Estimator struct {
+	weight       float64
+	minTolerance time.Duration
+
+	mu       sync.Mutex
+	samples  int
+	offset   float64
+	dev      float64
+	outliers []float64
+}
+
+// NewEstimator returns an estimator with DefaultWeight and DefaultMinTolerance.
+func NewEstimator() *Estimator {
+	return &Estimator{weight: DefaultWeight, minTolerance: DefaultMinTolerance}
+}
+
+// Observe adds the sample: the server time of a response and the local times the call
+// was sent and its response received.
+func (e *Estimator) Observe(server, sent, received time.Time) {
+	e.ObserveOffset(server.Sub(sent.Add(received.Sub(sent) / 2)))
+}
+
+// ObserveOffset adds a sample of the offset: server time minus local time.
+// It reports whether the sample was accepted.
+func (e *Estimator) ObserveOffset(offset time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := float64(offset)
+	if e.samples == 0 {
+		e.add(s)
+		return true
+	}
+	diff := s - e.offset
+	if diff < 0 {
+		diff = -diff
+	}
+	tolerance := toleranceDeviations * e.dev
+	if tolerance < float64(e.minTolerance) {
+		tolerance = float64(e.minTolerance)
+	}
+	if e.samples >= warmupSamples && diff > tolerance {
+		e.outliers = append(e.outliers, s)
+		if len(e.outliers) < outlierRun {
+			return false
+		}
+		// The clock has changed: restart from the run of outliers.
+		run := e.outliers
+		e.outliers, e.samples = nil, 0
+		for _, s := range run {
+			e.add(s)
+		}
+		return true
+	}
+	e.outliers = nil
+	e.add(s)
+	return true
+}
+
+func (e *Estimator) add(s float64) {
+	if e.samples == 0 {
+		e.offset, e.dev, e.samples = s, 0, 1
+		return
+	}
+	diff := s - e.offset
+	if diff < 0 {
+		diff = -diff
+	}
+	e.offset += e.weight * (s - e.offset)
+	e.dev += e.weight * (diff - e.dev)
+	e.samples++
+}
+
+// Offset returns the estimated offset: server time minus local time. It reports false
+// until the first sample.
+func (e *Estimator) Offset() (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Duration(e.offset), e.samples > 0
+}
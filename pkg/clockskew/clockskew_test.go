@@ -0,0 +1,74 @@
+package clockskew
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jittered returns offset with up to jitter of uniform noise in either direction.
+func jittered(r *rand.Rand, offset, jitter time.Duration) time.Duration {
+	return offset + time.Duration((r.Float64()*2-1)*float64(jitter))
+}
+
+func TestEstimator_Converges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	e := NewEstimator()
+	_, ok := e.Offset()
+	assert.False(t, ok)
+
+	for i := 0; i < 100; i++ {
+		e.ObserveOffset(jittered(r, -3*time.Second, 200*time.Millisecond))
+	}
+	offset, ok := e.Offset()
+	require.True(t, ok)
+	assert.InDelta(t, float64(-3*time.Second), float64(offset), float64(100*time.Millisecond))
+}
+
+func TestEstimator_Outliers(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	e := NewEstimator()
+	for i := 0; i < 50; i++ {
+		e.ObserveOffset(jittered(r, 5*time.Second, 100*time.Millisecond))
+	}
+	// Stale timestamps, e.g. of a cached response, are spread among good samples.
+	for i := 0; i < 50; i++ {
+		if i%4 == 0 {
+			assert.False(t, e.ObserveOffset(-time.Hour), "outlier %d is rejected", i)
+			continue
+		}
+		e.ObserveOffset(jittered(r, 5*time.Second, 100*time.Millisecond))
+	}
+	offset, _ := e.Offset()
+	assert.InDelta(t, float64(5*time.Second), float64(offset), float64(100*time.Millisecond))
+}
+
+func TestEstimator_ClockStep(t *testing.T) {
+	e := NewEstimator()
+	for i := 0; i < 20; i++ {
+		e.ObserveOffset(2 * time.Second)
+	}
+	// The local clock was stepped by NTP: the new offset is consistent and sticks.
+	for i := 0; i < outlierRun-1; i++ {
+		assert.False(t, e.ObserveOffset(20*time.Millisecond))
+	}
+	assert.True(t, e.ObserveOffset(20*time.Millisecond))
+	for i := 0; i < 10; i++ {
+		e.ObserveOffset(20 * time.Millisecond)
+	}
+	offset, _ := e.Offset()
+	assert.Equal(t, 20*time.Millisecond, offset)
+}
+
+func TestEstimator_Observe(t *testing.T) {
+	e := NewEstimator()
+	sent := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	// The server stamped the response 10s ahead of the middle of a 2s call.
+	e.Observe(sent.Add(11*time.Second), sent, sent.Add(2*time.Second))
+	offset, ok := e.Offset()
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Second, offset)
+}
@@ -0,0 +1,270 @@
+// Package cloud describes the cloud providers the resources are placed in: the
+// provider-specific parts of the create requests, checked against a catalog of the regions
+// of every provider, and the provider-specific outputs of the resources.
+package cloud
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Provider is a cloud provider, the cloud_type of the requests and the resources.
+type Provider string
+
+const (
+	AWS Provider = "aws"
+	GCP Provider = "gcp"
+)
+
+// IsAWS reports whether cloudType, e.g. the cloud_type of a resource, is AWS.
+func IsAWS(cloudType string) bool { return strings.EqualFold(cloudType, string(AWS)) }
+
+// IsGCP reports whether cloudType, e.g. the cloud_type of a resource, is GCP.
+func IsGCP(cloudType string) bool { return strings.EqualFold(cloudType, string(GCP)) }
+
+// CloudSpec is where a resource is placed: an *AWSSpec or a *GCPSpec.
+type CloudSpec interface {
+	Provider() Provider
+	Region() string
+	// Validate checks the region against the catalog of the provider, see Regions.
+	Validate() error
+
+	isCloudSpec()
+}
+
+// AWSSpec places a resource in an AWS region.
+type AWSSpec struct {
+	RegionID string
+	// Peering is the VPC peered by NetworkConnection, if any.
+	Peering *AWSPeering
+}
+
+// AWSPeering is a VPC of an AWS account to peer with a network.
+type AWSPeering struct {
+	VpcID     string
+	AccountID string
+	// RegionID of the VPC, the one of the spec if empty.
+	RegionID      string
+	IPv4CIDRBlock string
+	IPv6CIDRBlock string
+}
+
+func (s *AWSSpec) Provider() Provider { return AWS }
+func (s *AWSSpec) Region() string     { return s.RegionID }
+func (s *AWSSpec) isCloudSpec()       {}
+
+func (s *AWSSpec) Validate() error {
+	if err := validateRegion(AWS, s.RegionID); err != nil {
+		return err
+	}
+	if p := s.Peering; p != nil {
+		if p.VpcID == "" || p.AccountID == "" {
+			return &SpecError{Provider: AWS, Region: s.RegionID, Reason: "peering needs the vpc id and the account id"}
+		}
+		if p.RegionID != "" {
+			return validateRegion(AWS, p.RegionID)
+		}
+	}
+	return nil
+}
+
+// GCPSpec places a resource in a GCP region. The API has no GCP-specific parameters yet.
+type GCPSpec struct {
+	RegionID string
+}
+
+func (s *GCPSpec) Provider() Provider { return GCP }
+func (s *GCPSpec) Region() string     { return s.RegionID }
+func (s *GCPSpec) isCloudSpec()       {}
+
+func (s *GCPSpec) Validate() error { return validateRegion(GCP, s.RegionID) }
+
+// SpecOf returns the spec of a cloud_type and a region_id, e.g. the ones of a resource, to
+// handle the providers with a type switch. It fails with *SpecError for unknown providers.
+func SpecOf(cloudType, regionID string) (CloudSpec, error) {
+	switch {
+	case IsAWS(cloudType):
+		return &AWSSpec{RegionID: regionID}, nil
+	case IsGCP(cloudType):
+		return &GCPSpec{RegionID: regionID}, nil
+	default:
+		return nil, &SpecError{Provider: Provider(cloudType), Region: regionID, Reason: "unknown provider"}
+	}
+}
+
+// ErrInvalidSpec is matched by errors.Is for every *SpecError.
+var ErrInvalidSpec = errors.New("cloud: invalid spec")
+
+// SpecError is returned for a spec not valid for its provider, e.g. with a region of
+// another provider.
+type SpecError struct {
+	Provider Provider
+	Region   string
+	Reason   string
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("cloud: %s region %q: %s", e.Provider, e.Region, e.Reason)
+}
+
+func (e *SpecError) Is(target error) bool { return target == ErrInvalidSpec }
+
+// ErrUnsupported is matched by errors.Is for every *UnsupportedError.
+var ErrUnsupported = errors.New("cloud: unsupported by the provider")
+
+// UnsupportedError is returned for a feature the API doesn't offer on the provider, e.g.
+// the network connections of GCP networks.
+type UnsupportedError struct {
+	Provider Provider
+	Feature  string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("cloud: %s not supported on %s", e.Feature, e.Provider)
+}
+
+func (e *UnsupportedError) Is(target error) bool { return target == ErrUnsupported }
+
+// Apply validates spec and sets the cloud_type and the region_id of req, a create request
+// placing a resource: e.g. a network, a ClickHouse or a Kafka cluster one.
+func Apply(spec CloudSpec, req proto.Message) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	m := req.ProtoReflect()
+	for name, value := range map[protoreflect.Name]string{"cloud_type": string(spec.Provider()), "region_id": spec.Region()} {
+		f := m.Descriptor().Fields().ByName(name)
+		if f == nil || f.Kind() != protoreflect.StringKind || f.Cardinality() == protoreflect.Repeated {
+			return fmt.Errorf("cloud: %s has no string field %s", m.Descriptor().FullName(), name)
+		}
+		m.Set(f, protoreflect.ValueOfString(value))
+	}
+	return nil
+}
+
+// NetworkConnection returns the request connecting the network to the VPC peered by spec.
+// It fails with *UnsupportedError on the providers without network connections, all but AWS.
+func NetworkConnection(spec CloudSpec, networkID, description string) (*network.CreateNetworkConnectionRequest, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	aws, ok := spec.(*AWSSpec)
+	if !ok {
+		return nil, &UnsupportedError{Provider: spec.Provider(), Feature: "network connections"}
+	}
+	p := aws.Peering
+	if p == nil {
+		return nil, &SpecError{Provider: AWS, Region: aws.RegionID, Reason: "no peering to connect"}
+	}
+	region := p.RegionID
+	if region == "" {
+		region = aws.RegionID
+	}
+	return &network.CreateNetworkConnectionRequest{
+		NetworkId:   networkID,
+		Description: description,
+		Params: &network.CreateNetworkConnectionRequest_Aws{Aws: &network.CreateAWSNetworkConnectionRequest{
+			Type: &network.CreateAWSNetworkConnectionRequest_Peering{Peering: &network.CreateAWSNetworkConnectionPeeringRequest{
+				VpcId:         p.VpcID,
+				AccountId:     p.AccountID,
+				RegionId:      region,
+				Ipv4CidrBlock: p.IPv4CIDRBlock,
+				Ipv6CidrBlock: p.IPv6CIDRBlock,
+			}},
+		}},
+	}, nil
+}
+
+//go:embed regions.txt
+var defaultRegions string
+
+// regions is the region catalog, by provider, see SetRegions.
+var regions = struct {
+	sync.RWMutex
+	byProvider map[Provider]map[string]bool
+}{byProvider: parseRegions(defaultRegions)}
+
+func parseRegions(text string) map[Provider]map[string]bool {
+	catalog := map[Provider]map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, region, ok := strings.Cut(line, " ")
+		if !ok {
+			panic(fmt.Sprintf("cloud: malformed region line %q", line))
+		}
+		if catalog[Provider(p)] == nil {
+			catalog[Provider(p)] = map[string]bool{}
+		}
+		catalog[Provider(p)][strings.TrimSpace(region)] = true
+	}
+	return catalog
+}
+
+// Regions returns the regions of the provider in the catalog, sorted, nil if the regions of
+// the provider aren't checked.
+func Regions(p Provider) []string {
+	regions.RLock()
+	defer regions.RUnlock()
+	var list []string
+	for r := range regions.byProvider[p] {
+		list = append(list, r)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// SetRegions replaces the regions of the provider in the catalog, e.g. with regions opened
+// after the SDK was released, until restore is called. With no regions, the regions of the
+// provider aren't checked.
+func SetRegions(p Provider, list ...string) (restore func()) {
+	regions.Lock()
+	defer regions.Unlock()
+	prev, had := regions.byProvider[p]
+	if len(list) == 0 {
+		delete(regions.byProvider, p)
+	} else {
+		set := map[string]bool{}
+		for _, r := range list {
+			set[r] = true
+		}
+		regions.byProvider[p] = set
+	}
+	return func() {
+		regions.Lock()
+		defer regions.Unlock()
+		if had {
+			regions.byProvider[p] = prev
+		} else {
+			delete(regions.byProvider, p)
+		}
+	}
+}
+
+func validateRegion(p Provider, region string) error {
+	if region == "" {
+		return &SpecError{Provider: p, Reason: "region is required"}
+	}
+	regions.RLock()
+	defer regions.RUnlock()
+	known := regions.byProvider[p]
+	if known == nil || known[region] {
+		return nil
+	}
+	for other, set := range regions.byProvider {
+		if other != p && set[region] {
+			return &SpecError{Provider: p, Region: region, Reason: fmt.Sprintf("is a region of %s", other)}
+		}
+	}
+	return &SpecError{Provider: p, Region: region, Reason: "unknown region, see cloud.SetRegions"}
+}
@@ -0,0 +1,137 @@
+package cloud
+
+import (
+	"testing"
+
+	clickhouse "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafka "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestApply(t *testing.T) {
+	for _, spec := range []CloudSpec{&AWSSpec{RegionID: "eu-central-1"}, &GCPSpec{RegionID: "europe-west3"}} {
+		nw := &network.CreateNetworkRequest{ProjectId: "prj1", Name: "net"}
+		require.NoError(t, Apply(spec, nw))
+		assert.Equal(t, string(spec.Provider()), nw.GetCloudType())
+		assert.Equal(t, spec.Region(), nw.GetRegionId())
+
+		ch := &clickhouse.CreateClusterRequest{ProjectId: "prj1", Name: "ch"}
+		require.NoError(t, Apply(spec, ch))
+		assert.True(t, proto.Equal(&clickhouse.CreateClusterRequest{ProjectId: "prj1", Name: "ch", CloudType: string(spec.Provider()), RegionId: spec.Region()}, ch))
+
+		kf := &kafka.CreateClusterRequest{}
+		require.NoError(t, Apply(spec, kf))
+		parsed, err := SpecOf(kf.GetCloudType(), kf.GetRegionId())
+		require.NoError(t, err)
+		assert.Equal(t, spec, parsed, "the spec of the request")
+	}
+
+	err := Apply(&AWSSpec{RegionID: "eu-central-1"}, &network.GetNetworkRequest{})
+	assert.EqualError(t, err, "cloud: doublecloud.network.v1.GetNetworkRequest has no string field cloud_type")
+	// ListNetworksRequest_Filter has wrapped fields.
+	err = Apply(&AWSSpec{RegionID: "eu-central-1"}, &network.ListNetworksRequest_Filter{})
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	for spec, want := range map[CloudSpec]string{
+		&AWSSpec{RegionID: "europe-west1"}: `cloud: aws region "europe-west1": is a region of gcp`,
+		&GCPSpec{RegionID: "us-east-1"}:    `cloud: gcp region "us-east-1": is a region of aws`,
+		&GCPSpec{RegionID: "mars-north1"}:  `cloud: gcp region "mars-north1": unknown region, see cloud.SetRegions`,
+		&AWSSpec{}:                         `cloud: aws region "": region is required`,
+		&AWSSpec{RegionID: "us-east-1", Peering: &AWSPeering{VpcID: "vpc-1"}}:                                         `cloud: aws region "us-east-1": peering needs the vpc id and the account id`,
+		&AWSSpec{RegionID: "us-east-1", Peering: &AWSPeering{VpcID: "vpc-1", AccountID: "123", RegionID: "us-east1"}}: `cloud: aws region "us-east1": is a region of gcp`,
+	} {
+		err := spec.Validate()
+		assert.ErrorIs(t, err, ErrInvalidSpec)
+		assert.EqualError(t, err, want)
+		req := &network.CreateNetworkRequest{}
+		assert.Equal(t, err, Apply(spec, req))
+		assert.Empty(t, req.GetCloudType(), "nothing is set")
+	}
+
+	_, err := SpecOf("azure", "westeurope")
+	assert.ErrorIs(t, err, ErrInvalidSpec)
+	assert.EqualError(t, err, `cloud: azure region "westeurope": unknown provider`)
+	spec, err := SpecOf("AWS", "us-west-2")
+	require.NoError(t, err)
+	assert.Equal(t, &AWSSpec{RegionID: "us-west-2"}, spec)
+	assert.True(t, IsAWS("AWS"))
+	assert.False(t, IsGCP("aws"))
+}
+
+func TestSetRegions(t *testing.T) {
+	assert.Contains(t, Regions(GCP), "europe-west1")
+	restore := SetRegions(GCP, "europe-west1", "mars-north1")
+	assert.Equal(t, []string{"europe-west1", "mars-north1"}, Regions(GCP))
+	assert.NoError(t, (&GCPSpec{RegionID: "mars-north1"}).Validate())
+	assert.Error(t, (&GCPSpec{RegionID: "europe-west2"}).Validate())
+	restore()
+	assert.Error(t, (&GCPSpec{RegionID: "mars-north1"}).Validate())
+
+	defer SetRegions(AWS)()
+	assert.Nil(t, Regions(AWS))
+	assert.NoError(t, (&AWSSpec{RegionID: "eu-mars-1"}).Validate(), "the regions aren't checked")
+	assert.Error(t, (&AWSSpec{}).Validate(), "still required")
+}
+
+func TestNetworkConnection(t *testing.T) {
+	spec := &AWSSpec{RegionID: "eu-central-1", Peering: &AWSPeering{VpcID: "vpc-1", AccountID: "123456789012", IPv4CIDRBlock: "10.1.0.0/16"}}
+	req, err := NetworkConnection(spec, "net1", "to prod")
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&network.CreateNetworkConnectionRequest{
+		NetworkId:   "net1",
+		Description: "to prod",
+		Params: &network.CreateNetworkConnectionRequest_Aws{Aws: &network.CreateAWSNetworkConnectionRequest{
+			Type: &network.CreateAWSNetworkConnectionRequest_Peering{Peering: &network.CreateAWSNetworkConnectionPeeringRequest{
+				VpcId: "vpc-1", AccountId: "123456789012", RegionId: "eu-central-1", Ipv4CidrBlock: "10.1.0.0/16",
+			}},
+		}},
+	}, req), "the region of the spec by default")
+
+	_, err = NetworkConnection(&AWSSpec{RegionID: "eu-central-1"}, "net1", "")
+	assert.EqualError(t, err, `cloud: aws region "eu-central-1": no peering to connect`)
+	_, err = NetworkConnection(&GCPSpec{RegionID: "europe-west1"}, "net1", "")
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.EqualError(t, err, "cloud: network connections not supported on gcp")
+}
+
+func TestAWSNetworkOf(t *testing.T) {
+	nw := &network.Network{Id: "net1", CloudType: "aws", ExternalResources: &network.Network_Aws{Aws: &network.AwsExternalResources{
+		VpcId:           "vpc-1",
+		SecurityGroupId: "sg-1",
+		Subnets:         []*network.AwsExternalResources_Subnet{{Id: "subnet-1", ZoneId: "euc1-az1"}},
+		AccountId:       wrapperspb.String("123456789012"),
+		IamRoleArn:      wrapperspb.String("arn:aws:iam::123456789012:role/dc"),
+	}}}
+	out, ok := AWSNetworkOf(nw)
+	require.True(t, ok)
+	assert.Equal(t, AWSNetwork{
+		VpcID:           "vpc-1",
+		SecurityGroupID: "sg-1",
+		Subnets:         []AWSSubnet{{ID: "subnet-1", ZoneID: "euc1-az1"}},
+		AccountID:       "123456789012",
+		IAMRoleARN:      "arn:aws:iam::123456789012:role/dc",
+	}, out)
+
+	_, ok = AWSNetworkOf(&network.Network{Id: "net2", CloudType: "gcp"})
+	assert.False(t, ok)
+}
+
+func TestAWSPeeringOf(t *testing.T) {
+	c := &network.NetworkConnection{Id: "nc1", ConnectionInfo: &network.NetworkConnection_Aws{Aws: &network.AWSNetworkConnectionInfo{
+		Type: &network.AWSNetworkConnectionInfo_Peering{Peering: &network.AWSNetworkConnectionPeeringInfo{
+			VpcId: "vpc-1", AccountId: "123", RegionId: "eu-central-1", PeeringConnectionId: "pcx-1", ManagedIpv4CidrBlock: "10.0.0.0/16",
+		}},
+	}}}
+	out, ok := AWSPeeringOf(c)
+	require.True(t, ok)
+	assert.Equal(t, AWSPeeringConnection{VpcID: "vpc-1", AccountID: "123", RegionID: "eu-central-1", PeeringConnectionID: "pcx-1", ManagedIPv4CIDRBlock: "10.0.0.0/16"}, out)
+
+	_, ok = AWSPeeringOf(&network.NetworkConnection{Id: "nc2"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,79 @@
+package cloud
+
+import (
+	network "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+)
+
+// AWSNetwork are the AWS resources behind a network.
+type AWSNetwork struct {
+	VpcID           string
+	SecurityGroupID string
+	Subnets         []AWSSubnet
+	// AccountID owns the VPC. It and the fields below are empty for the VPCs created by
+	// DoubleCloud.
+	AccountID string
+	// IAMRoleARN is the role DoubleCloud creates the resources with.
+	IAMRoleARN string
+	// StackID is the CloudFormation stack that created the VPC, TemplateVersion its version.
+	StackID         string
+	TemplateVersion string
+}
+
+type AWSSubnet struct {
+	ID     string
+	ZoneID string
+}
+
+// AWSNetworkOf returns the AWS resources of the network, false if it isn't an AWS network
+// or they aren't created yet.
+func AWSNetworkOf(nw *network.Network) (AWSNetwork, bool) {
+	aws := nw.GetAws()
+	if aws == nil {
+		return AWSNetwork{}, false
+	}
+	out := AWSNetwork{
+		VpcID:           aws.GetVpcId(),
+		SecurityGroupID: aws.GetSecurityGroupId(),
+		AccountID:       aws.GetAccountId().GetValue(),
+		IAMRoleARN:      aws.GetIamRoleArn().GetValue(),
+		StackID:         aws.GetStackId().GetValue(),
+		TemplateVersion: aws.GetCfTemplateVersion().GetValue(),
+	}
+	for _, s := range aws.GetSubnets() {
+		out.Subnets = append(out.Subnets, AWSSubnet{ID: s.GetId(), ZoneID: s.GetZoneId()})
+	}
+	return out, true
+}
+
+// AWSPeeringConnection is the AWS side of a peering network connection.
+type AWSPeeringConnection struct {
+	// PeeringConnectionID is the ID of the VPC peering connection in AWS, e.g. to accept it.
+	PeeringConnectionID string
+	VpcID               string
+	AccountID           string
+	RegionID            string
+	IPv4CIDRBlock       string
+	IPv6CIDRBlock       string
+	// ManagedIPv4CIDRBlock and ManagedIPv6CIDRBlock are the blocks of the network, to
+	// route to in the peered VPC.
+	ManagedIPv4CIDRBlock string
+	ManagedIPv6CIDRBlock string
+}
+
+// AWSPeeringOf returns the peering of the connection, false if it isn't an AWS peering.
+func AWSPeeringOf(c *network.NetworkConnection) (AWSPeeringConnection, bool) {
+	p := c.GetAws().GetPeering()
+	if p == nil {
+		return AWSPeeringConnection{}, false
+	}
+	return AWSPeeringConnection{
+		PeeringConnectionID:  p.GetPeeringConnectionId(),
+		VpcID:                p.GetVpcId(),
+		AccountID:            p.GetAccountId(),
+		RegionID:             p.GetRegionId(),
+		IPv4CIDRBlock:        p.GetIpv4CidrBlock(),
+		IPv6CIDRBlock:        p.GetIpv6CidrBlock(),
+		ManagedIPv4CIDRBlock: p.GetManagedIpv4CidrBlock(),
+		ManagedIPv6CIDRBlock: p.GetManagedIpv6CidrBlock(),
+	}, true
+}
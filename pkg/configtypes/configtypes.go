@@ -0,0 +1,230 @@
+// Package configtypes parses the durations and the sizes of configuration values, the same
+// way for every source: text, e.g. flags or JSON, YAML documents and environment variables.
+//
+//	var conf struct {
+//		Timeout configtypes.Duration `yaml:"timeout"`
+//		Cache   configtypes.ByteSize `yaml:"cache"`
+//	}
+//	err := yaml.Unmarshal([]byte("timeout: 30s\ncache: 100MiB"), &conf)
+//	_, err = configtypes.Env("DC_TIMEOUT", &conf.Timeout)
+//
+// The values are strict: negative values, missing units and unknown units are rejected with
+// *ParseError, naming the key when it is known and the accepted formats.
+package configtypes
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DurationFormats describes the values accepted by Duration.
+const DurationFormats = `a non-negative number with a unit of "ns", "us", "ms", "s", "m" or "h", e.g. "30s" or "1h30m", or "0"`
+
+// ByteSizeFormats describes the values accepted by ByteSize.
+const ByteSizeFormats = `a non-negative integer with a unit of "B", "KB", "MB", "GB", "TB", "KiB", "MiB", "GiB" or "TiB", e.g. "100GiB", or "0"`
+
+// ErrParse is matched by errors.Is for every *ParseError.
+var ErrParse = errors.New("configtypes: invalid value")
+
+// ParseError is returned for the values that can't be parsed.
+type ParseError struct {
+	// Key is the name of the value, e.g. the environment variable, if known.
+	Key string
+	// Value is the rejected text.
+	Value string
+	// Type is "duration" or "byte size".
+	Type string
+	// Formats are the accepted formats, DurationFormats or ByteSizeFormats.
+	Formats string
+	// Reason tells what is wrong with Value.
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("invalid %s %q: %s; want %s", e.Type, e.Value, e.Reason, e.Formats)
+	if e.Key != "" {
+		return fmt.Sprintf("configtypes: %s: %s", e.Key, msg)
+	}
+	return "configtypes: " + msg
+}
+
+func (e *ParseError) Is(target error) bool { return target == ErrParse }
+
+// Duration is a time.Duration configured as text, see DurationFormats.
+type Duration time.Duration
+
+var (
+	_ encoding.TextUnmarshaler = (*Duration)(nil)
+	_ yaml.Unmarshaler         = (*Duration)(nil)
+)
+
+// ParseDuration parses s, see DurationFormats.
+func ParseDuration(s string) (Duration, error) {
+	fail := func(reason string) (Duration, error) {
+		return 0, &ParseError{Value: s, Type: "duration", Formats: DurationFormats, Reason: reason}
+	}
+	switch {
+	case s == "0":
+		return 0, nil
+	case s == "":
+		return fail("empty")
+	case strings.HasPrefix(s, "-"):
+		return fail("negative")
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return fail("missing unit")
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fail(strings.TrimPrefix(err.Error(), "time: "))
+	}
+	return Duration(d), nil
+}
+
+// Duration returns d as a time.Duration.
+func (d Duration) Duration() time.Duration { return time.Duration(d) }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalYAML(node, d)
+}
+
+// ByteSize is a number of bytes configured as text, see ByteSizeFormats.
+type ByteSize int64
+
+var (
+	_ encoding.TextUnmarshaler = (*ByteSize)(nil)
+	_ yaml.Unmarshaler         = (*ByteSize)(nil)
+)
+
+// byteSizeUnits are the units of ByteSize, the longest first, so that suffixes match right.
+var byteSizeUnits = []struct {
+	name string
+	size int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// ParseByteSize parses s, see ByteSizeFormats.
+func ParseByteSize(s string) (ByteSize, error) {
+	fail := func(reason string) (ByteSize, error) {
+		return 0, &ParseError{Value: s, Type: "byte size", Formats: ByteSizeFormats, Reason: reason}
+	}
+	switch {
+	case s == "0":
+		return 0, nil
+	case s == "":
+		return fail("empty")
+	case strings.HasPrefix(s, "-"):
+		return fail("negative")
+	}
+	for _, unit := range byteSizeUnits {
+		digits, ok := strings.CutSuffix(s, unit.name)
+		if !ok {
+			continue
+		}
+		switch rest := strings.TrimLeft(digits, "0123456789"); {
+		case digits == "":
+			return fail("missing number")
+		case rest != "" && unicode.IsLetter(rune(rest[len(rest)-1])):
+			// Another unit ending like this one, e.g. "XB".
+			continue
+		case rest != "":
+			return fail("not an integer number of " + unit.name)
+		}
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil || n > math.MaxInt64/unit.size {
+			return fail("out of range")
+		}
+		return ByteSize(n * unit.size), nil
+	}
+	if strings.TrimLeft(s, "0123456789") == "" {
+		return fail("missing unit")
+	}
+	return fail("unknown unit")
+}
+
+// Bytes returns s as a number of bytes.
+func (s ByteSize) Bytes() int64 { return int64(s) }
+
+// String returns s in the largest binary unit dividing it, e.g. "100GiB".
+func (s ByteSize) String() string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if s != 0 && int64(s)%unit.size == 0 {
+			return strconv.FormatInt(int64(s)/unit.size, 10) + unit.name
+		}
+	}
+	if s == 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+func (s ByteSize) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+func (s *ByteSize) UnmarshalText(text []byte) error {
+	v, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+func (s *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalYAML(node, s)
+}
+
+// unmarshalYAML parses the scalar node with v, naming its position in errors.
+func unmarshalYAML(node *yaml.Node, v encoding.TextUnmarshaler) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("configtypes: line %d: want a scalar value", node.Line)
+	}
+	err := v.UnmarshalText([]byte(node.Value))
+	var perr *ParseError
+	if errors.As(err, &perr) && perr.Key == "" {
+		perr.Key = fmt.Sprintf("line %d", node.Line)
+	}
+	return err
+}
+
+// Env parses the environment variable key with v, e.g. a *Duration or a *ByteSize. It
+// reports whether the variable is set; errors name the variable.
+func Env(key string, v encoding.TextUnmarshaler) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return false, nil
+	}
+	err := v.UnmarshalText([]byte(value))
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.Key = key
+	}
+	return true, err
+}
@@ -0,0 +1,158 @@
+package configtypes
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"0":        0,
+		"0s":       0,
+		"1ns":      time.Nanosecond,
+		"1.5s":     1500 * time.Millisecond,
+		"30s":      30 * time.Second,
+		"2m":       2 * time.Minute,
+		"1h30m":    90 * time.Minute,
+		"+5s":      5 * time.Second,
+		"2562047h": 2562047 * time.Hour,
+	} {
+		d, err := ParseDuration(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, d.Duration(), s)
+	}
+	for s, reason := range map[string]string{
+		"":         "empty",
+		"-1s":      "negative",
+		"-0":       "negative",
+		"30":       "missing unit",
+		"1.5":      "missing unit",
+		"1d":       `unknown unit "d" in duration "1d"`,
+		"s":        `invalid duration "s"`,
+		"30 s":     `unknown unit " s" in duration "30 s"`,
+		"9999999h": `invalid duration "9999999h"`,
+	} {
+		_, err := ParseDuration(s)
+		var perr *ParseError
+		require.ErrorAs(t, err, &perr, s)
+		assert.Equal(t, reason, perr.Reason, s)
+		assert.ErrorIs(t, err, ErrParse)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"0":                    0,
+		"0B":                   0,
+		"1B":                   1,
+		"1KB":                  1000,
+		"1KiB":                 1024,
+		"512MiB":               512 << 20,
+		"100GiB":               100 << 30,
+		"10GB":                 10e9,
+		"2TiB":                 2 << 40,
+		"5TB":                  5e12,
+		"9223372036854775807B": math.MaxInt64,
+		"8388607TiB":           8388607 << 40,
+	} {
+		n, err := ParseByteSize(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, n.Bytes(), s)
+	}
+	for s, reason := range map[string]string{
+		"":                     "empty",
+		"-1GiB":                "negative",
+		"100":                  "missing unit",
+		"GiB":                  "missing number",
+		"1.5GiB":               "not an integer number of GiB",
+		"1 GiB":                "not an integer number of GiB",
+		"100XB":                "unknown unit",
+		"100gib":               "unknown unit",
+		"100GIB":               "unknown unit",
+		"1PiB":                 "unknown unit",
+		"8388608TiB":           "out of range",
+		"9223372036854775808B": "out of range",
+	} {
+		_, err := ParseByteSize(s)
+		var perr *ParseError
+		require.ErrorAs(t, err, &perr, s)
+		assert.Equal(t, reason, perr.Reason, s)
+	}
+}
+
+func TestByteSize_String(t *testing.T) {
+	for n, want := range map[ByteSize]string{
+		0:         "0",
+		1:         "1B",
+		1000:      "1000B",
+		1 << 10:   "1KiB",
+		1536:      "1536B",
+		100 << 30: "100GiB",
+		3 << 40:   "3TiB",
+	} {
+		assert.Equal(t, want, n.String())
+		back, err := ParseByteSize(n.String())
+		require.NoError(t, err)
+		assert.Equal(t, n, back, "String round-trips")
+	}
+}
+
+type config struct {
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+	Cache   ByteSize `json:"cache" yaml:"cache"`
+}
+
+func TestLoaders(t *testing.T) {
+	want := config{Timeout: Duration(30 * time.Second), Cache: ByteSize(100 << 20)}
+
+	var fromYAML config
+	require.NoError(t, yaml.Unmarshal([]byte("timeout: 30s\ncache: 100MiB\n"), &fromYAML))
+	assert.Equal(t, want, fromYAML)
+
+	var fromJSON config
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout": "30s", "cache": "100MiB"}`), &fromJSON))
+	assert.Equal(t, want, fromJSON)
+
+	t.Setenv("TEST_TIMEOUT", "30s")
+	t.Setenv("TEST_CACHE", "100MiB")
+	var fromEnv config
+	ok, err := Env("TEST_TIMEOUT", &fromEnv.Timeout)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = Env("TEST_CACHE", &fromEnv.Cache)
+	require.NoError(t, err)
+	assert.Equal(t, want, fromEnv)
+
+	ok, err = Env("TEST_UNSET", &fromEnv.Timeout)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, want.Timeout, fromEnv.Timeout, "unset variables leave the value")
+
+	data, err := json.Marshal(want)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"timeout": "30s", "cache": "100MiB"}`, string(data))
+}
+
+func TestLoaders_Errors(t *testing.T) {
+	var c config
+	err := yaml.Unmarshal([]byte("cache: 1G\ntimeout: 30\n"), &c)
+	assert.ErrorIs(t, err, ErrParse)
+	assert.Contains(t, err.Error(), `line 1: invalid byte size "1G": unknown unit; want a non-negative integer`)
+
+	err = yaml.Unmarshal([]byte("timeout: [30s]\n"), &c)
+	assert.EqualError(t, err, "configtypes: line 1: want a scalar value")
+
+	err = json.Unmarshal([]byte(`{"timeout": "-1s"}`), &c)
+	assert.ErrorIs(t, err, ErrParse)
+	assert.Contains(t, err.Error(), `configtypes: invalid duration "-1s": negative; want a non-negative number with a unit`)
+
+	t.Setenv("TEST_TIMEOUT", "30")
+	_, err = Env("TEST_TIMEOUT", &c.Timeout)
+	assert.EqualError(t, err, `configtypes: TEST_TIMEOUT: invalid duration "30": missing unit; want `+DurationFormats)
+}
@@ -0,0 +1,60 @@
+package grpcclient
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CloseConn closes the conn of addr once the calls in flight on it end, and returns once
+// it is closed. The next GetConn of addr dials a new conn: the calls started meanwhile
+// already get it. If ctx is done first, the conn is closed anyway, failing the calls still
+// in flight, and the error of ctx is returned. It does nothing if addr has no conn, and
+// fails with ErrConnContextClosed once the context is shut down.
+func (cc *lazyConnContext) CloseConn(ctx context.Context, addr string) error {
+	cc.mu.Lock()
+	if cc.closed || cc.closing {
+		cc.mu.Unlock()
+		return ErrConnContextClosed
+	}
+	tc, ok := cc.conns[addr]
+	if !ok {
+		cc.mu.Unlock()
+		return nil
+	}
+	delete(cc.conns, addr)
+	tc.mu.Lock()
+	if tc.idle == nil {
+		tc.idle = make(chan struct{})
+		if tc.inFlight == 0 {
+			close(tc.idle)
+		}
+	}
+	idle := tc.idle
+	tc.mu.Unlock()
+	cc.mu.Unlock()
+
+	var err error
+	// As for the recycled conns, the calls that got the conn right before are let start.
+	settle := time.NewTimer(drainSettle)
+	defer settle.Stop()
+	select {
+	case <-settle.C:
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	cc.mu.Lock()
+	delete(cc.tracked, tc.conn)
+	cc.mu.Unlock()
+	if closeErr := tc.conn.Close(); closeErr != nil && err == nil && status.Code(closeErr) != codes.Canceled {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCloseConn(t *testing.T) {
+	cc, _ := newRecyclingConnContext(t, 0, 0, &recycleEvents{})
+	ctx := context.Background()
+
+	first, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	require.NoError(t, check(ctx, cc))
+	require.NoError(t, cc.CloseConn(ctx, "health"))
+	assert.Equal(t, connectivity.Shutdown, first.GetState())
+
+	require.NoError(t, check(ctx, cc), "dialed again")
+	second, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	assert.NotSame(t, first, second)
+	assert.NoError(t, cc.CloseConn(ctx, "unknown"), "nothing to close")
+
+	require.NoError(t, cc.Shutdown(ctx))
+	assert.ErrorIs(t, cc.CloseConn(ctx, "health"), ErrConnContextClosed)
+}
+
+func TestCloseConn_InFlight(t *testing.T) {
+	cc, _ := newRecyclingConnContext(t, 0, 0, &recycleEvents{})
+	streamCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := cc.GetConn(streamCtx, "health")
+	require.NoError(t, err)
+	watch, err := healthpb.NewHealthClient(conn).Watch(streamCtx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	// The conn is closed once the stream ends.
+	go func() {
+		time.Sleep(3 * drainSettle)
+		cancel()
+		_, _ = watch.Recv()
+	}()
+	started := time.Now()
+	require.NoError(t, cc.CloseConn(context.Background(), "health"))
+	assert.GreaterOrEqual(t, time.Since(started), 3*drainSettle, "drained first")
+	assert.Equal(t, connectivity.Shutdown, conn.GetState())
+
+	// Or once ctx is done.
+	conn, err = cc.GetConn(context.Background(), "health")
+	require.NoError(t, err)
+	_, err = healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	ctx, cancelClose := context.WithTimeout(context.Background(), 2*drainSettle)
+	defer cancelClose()
+	assert.ErrorIs(t, cc.CloseConn(ctx, "health"), context.DeadlineExceeded)
+	assert.Equal(t, connectivity.Shutdown, conn.GetState(), "closed with the stream in flight")
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/singleflight"
 
 	multierror "github.com/hashicorp/go-multierror"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var ErrConnContextClosed = errors.New("grpcclient: client connection context closed")
@@ -27,6 +30,8 @@ func (d *DialError) Error() string {
 type ConnContext interface {
 	GetConn(ctx context.Context, addr string) (*grpc.ClientConn, error)
 	CallOptions() []grpc.CallOption
+	// CloseConn drains and closes the conn of addr, see lazyConnContext.CloseConn.
+	CloseConn(ctx context.Context, addr string) error
 	Shutdown(context.Context) error
 }
 
@@ -35,6 +40,8 @@ type LazyConnContextOption func(*lazyConnContextOptions)
 type lazyConnContextOptions struct {
 	dialOpts []grpc.DialOption
 	callOpts []grpc.CallOption
+	recycle  recycleOptions
+	goTask   func(name string, fn func(ctx context.Context)) bool
 }
 
 func DialOptions(dopts ...grpc.DialOption) LazyConnContextOption {
@@ -49,14 +56,29 @@ func CallOptions(copts ...grpc.CallOption) LazyConnContextOption {
 	}
 }
 
+// Tasks runs the background goroutines of the conn context, the drains of the conns recycled
+// by MaxConnectionAge and the OnRecycle callbacks, with goTask rather than go statements, e.g.
+// on a registry waiting for them on shutdown. The context passed to fn is done once they must
+// stop, and goTask returns false if it didn't start fn.
+func Tasks(goTask func(name string, fn func(ctx context.Context)) bool) LazyConnContextOption {
+	return func(o *lazyConnContextOptions) {
+		o.goTask = goTask
+	}
+}
+
 type lazyConnContext struct {
 	opts *lazyConnContextOptions
+	// now may be replaced in tests
+	now func() time.Time
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	mu      sync.Mutex
-	conns   map[string]*grpc.ClientConn
+	mu    sync.Mutex
+	conns map[string]*trackedConn
+	// tracked are the conns dialed and not closed yet, retired and closing ones included,
+	// for the interceptors counting their calls in flight.
+	tracked map[*grpc.ClientConn]*trackedConn
 	closed  bool
 	closing bool
 
@@ -70,12 +92,27 @@ func NewLazyConnContext(opt ...LazyConnContextOption) ConnContext {
 		o(opts)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-	return &lazyConnContext{
-		opts:   opts,
-		ctx:    ctx,
-		cancel: cancel,
-		conns:  map[string]*grpc.ClientConn{},
+	cc := &lazyConnContext{
+		opts:    opts,
+		now:     time.Now,
+		ctx:     ctx,
+		cancel:  cancel,
+		conns:   map[string]*trackedConn{},
+		tracked: map[*grpc.ClientConn]*trackedConn{},
 	}
+	if opts.goTask == nil {
+		opts.goTask = func(name string, fn func(ctx context.Context)) bool {
+			go fn(ctx)
+			return true
+		}
+	}
+	// The calls in flight are tracked for the drains of MaxConnectionAge and CloseConn.
+	// First, so that the calls are in flight through all the interceptors, e.g. retries.
+	opts.dialOpts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(cc.trackUnary),
+		grpc.WithChainStreamInterceptor(cc.trackStream),
+	}, opts.dialOpts...)
+	return cc
 }
 
 func (cc *lazyConnContext) GetConn(ctx context.Context, addr string) (*grpc.ClientConn, error) {
@@ -84,40 +121,49 @@ func (cc *lazyConnContext) GetConn(ctx context.Context, addr string) (*grpc.Clie
 		cc.mu.Unlock()
 		return nil, ErrConnContextClosed
 	}
-	if conn, ok := cc.conns[addr]; ok {
+	if tc, ok := cc.conns[addr]; ok && !cc.expired(tc) {
 		cc.mu.Unlock()
-		return conn, nil
+		return tc.conn, nil
 	}
 	cc.mu.Unlock()
 
 	result, err, _ := cc.dial.Do(addr, func() (any, error) {
+		cc.mu.Lock()
+		old, ok := cc.conns[addr]
+		cc.mu.Unlock()
+		if ok && !cc.expired(old) {
+			// Recycled meanwhile.
+			return old.conn, nil
+		}
 		conn, err := grpc.DialContext(cc.ctx, addr, cc.opts.dialOpts...)
 		if err != nil {
 			if err == cc.ctx.Err() {
 				err = ErrConnContextClosed
+			} else if old != nil {
+				// Keep the old conn rather than failing the calls.
+				return old.conn, nil
 			} else {
 				err = &DialError{err, addr}
 			}
-			// return connAndErr{err: err}
 			return nil, err
 		}
 		defer cc.mu.Unlock()
 		cc.mu.Lock()
-		
+
 		if cc.closed || cc.closing {
-			// cc.mu.Unlock()
 			// we swallow error here, since the client doesn't care about it
 			_ = conn.Close()
-			// return connAndErr{conn: nil, err: ErrConnContextClosed}
 			return nil, ErrConnContextClosed
 		}
-		cc.conns[addr] = conn
-		// cc.mu.Unlock()
-		// return connAndErr{conn: conn}
+		tc := &trackedConn{conn: conn, addr: addr, dialedAt: cc.now()}
+		cc.conns[addr] = tc
+		cc.tracked[conn] = tc
+		if old != nil {
+			cc.retire(old)
+		}
 		return conn, nil
 	})
-	ce := result.(*grpc.ClientConn)
-	// return ce.conn, ce.err
+	ce, _ := result.(*grpc.ClientConn)
 	return ce, err
 }
 
@@ -140,8 +186,8 @@ func (cc *lazyConnContext) Shutdown(ctx context.Context) error {
 	result, err, _ := cc.shutdown.Do("shutdown", func() (any, error) {
 		cc.mu.Lock()
 		cc.cancel()
-		conns := make([]*grpc.ClientConn, 0, len(cc.conns))
-		for _, conn := range cc.conns {
+		conns := make([]*grpc.ClientConn, 0, len(cc.tracked))
+		for conn := range cc.tracked {
 			conns = append(conns, conn)
 		}
 		cc.mu.Unlock()
@@ -149,7 +195,7 @@ func (cc *lazyConnContext) Shutdown(ctx context.Context) error {
 		var errs error
 		for _, conn := range conns {
 			err := conn.Close()
-			if err != nil {
+			if err != nil && status.Code(err) != codes.Canceled {
 				errs = multierror.Append(errs, err)
 			}
 		}
@@ -170,4 +216,4 @@ func (cc *lazyConnContext) Shutdown(ctx context.Context) error {
 type connAndErr struct {
 	conn *grpc.ClientConn
 	err  error
-}
\ No newline at end of file
+}
@@ -0,0 +1,198 @@
+package grpcclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// DefaultConnectionDrainTimeout bounds the drain of recycled connections unless
+// MaxConnectionAge sets it.
+const DefaultConnectionDrainTimeout = 30 * time.Second
+
+// RecycleEvent is reported by OnRecycle for the connections recycled by MaxConnectionAge.
+type RecycleEvent struct {
+	Addr     string
+	DialedAt time.Time
+	// Age is the age of the connection when it was replaced, or closed if Closed.
+	Age time.Duration
+	// Closed is false for the event of the replacement of the connection, and true for the
+	// event of its close once drained.
+	Closed bool
+	// InFlight is the number of calls still in flight on the connection when it was closed
+	// by the drain timeout, zero if they all ended.
+	InFlight int
+}
+
+type recycleOptions struct {
+	maxAge       time.Duration
+	drainTimeout time.Duration
+	onRecycle    func(RecycleEvent)
+}
+
+// MaxConnectionAge recycles the connections older than maxAge: the next GetConn dials a
+// new connection to the address and returns it from then on, and the old one is closed
+// once the calls in flight on it end, after drainTimeout at the latest,
+// DefaultConnectionDrainTimeout if not positive. Connections are never recycled if maxAge
+// is not positive.
+func MaxConnectionAge(maxAge, drainTimeout time.Duration) LazyConnContextOption {
+	return func(o *lazyConnContextOptions) {
+		o.recycle.maxAge = maxAge
+		o.recycle.drainTimeout = drainTimeout
+	}
+}
+
+// OnRecycle sets the function called with the events of the connections recycled by
+// MaxConnectionAge. It is called in the background, see Tasks, and its panics are recovered,
+// see operation.SafeCall.
+func OnRecycle(f func(RecycleEvent)) LazyConnContextOption {
+	return func(o *lazyConnContextOptions) {
+		o.recycle.onRecycle = f
+	}
+}
+
+// drainSettle is how long a retired conn is kept at least, for the calls that got it
+// from GetConn right before it was retired to start.
+const drainSettle = 100 * time.Millisecond
+
+// trackedConn is a conn with its calls in flight.
+type trackedConn struct {
+	conn     *grpc.ClientConn
+	addr     string
+	dialedAt time.Time
+
+	mu       sync.Mutex
+	inFlight int
+	// idle is the channel closed once the retired conn has no calls in flight.
+	idle chan struct{}
+}
+
+func (tc *trackedConn) begin() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.inFlight++
+}
+
+func (tc *trackedConn) end() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.inFlight--
+	if tc.inFlight == 0 && tc.idle != nil {
+		close(tc.idle)
+	}
+}
+
+// expired reports whether tc is due to be recycled.
+func (cc *lazyConnContext) expired(tc *trackedConn) bool {
+	return cc.opts.recycle.maxAge > 0 && cc.now().Sub(tc.dialedAt) >= cc.opts.recycle.maxAge
+}
+
+// retire drains tc in the background, see Tasks, and closes it. cc.mu must be held.
+func (cc *lazyConnContext) retire(tc *trackedConn) {
+	tc.mu.Lock()
+	tc.idle = make(chan struct{})
+	if tc.inFlight == 0 {
+		close(tc.idle)
+	}
+	tc.mu.Unlock()
+	cc.report(RecycleEvent{Addr: tc.addr, DialedAt: tc.dialedAt, Age: cc.now().Sub(tc.dialedAt)})
+
+	timeout := cc.opts.recycle.drainTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectionDrainTimeout
+	}
+	started := cc.opts.goTask("drain connection "+tc.addr, func(ctx context.Context) {
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+		select {
+		case <-time.After(drainSettle):
+			select {
+			case <-tc.idle:
+			case <-deadline.C:
+			case <-cc.ctx.Done():
+			case <-ctx.Done():
+			}
+		case <-deadline.C:
+		case <-cc.ctx.Done():
+		case <-ctx.Done():
+		}
+		cc.mu.Lock()
+		delete(cc.tracked, tc.conn)
+		cc.mu.Unlock()
+		if cc.ctx.Err() != nil {
+			// Closed by Shutdown.
+			return
+		}
+		_ = tc.conn.Close()
+		if ctx.Err() != nil {
+			// The drain is cut short by the shutdown of the tasks.
+			return
+		}
+		tc.mu.Lock()
+		inFlight := tc.inFlight
+		tc.mu.Unlock()
+		cc.report(RecycleEvent{Addr: tc.addr, DialedAt: tc.dialedAt, Age: cc.now().Sub(tc.dialedAt), Closed: true, InFlight: inFlight})
+	})
+	if !started {
+		delete(cc.tracked, tc.conn)
+		_ = tc.conn.Close()
+	}
+}
+
+// report calls the OnRecycle callback with e in the background: the replacements are
+// reported with cc.mu held.
+func (cc *lazyConnContext) report(e RecycleEvent) {
+	if f := cc.opts.recycle.onRecycle; f != nil {
+		cc.opts.goTask("connection recycle callback", func(context.Context) {
+			_ = operation.SafeCall("connection recycle", func() error { f(e); return nil })
+		})
+	}
+}
+
+func (cc *lazyConnContext) lookupTracked(conn *grpc.ClientConn) *trackedConn {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.tracked[conn]
+}
+
+func (cc *lazyConnContext) trackUnary(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if tc := cc.lookupTracked(conn); tc != nil {
+		tc.begin()
+		defer tc.end()
+	}
+	return invoker(ctx, method, req, reply, conn, opts...)
+}
+
+func (cc *lazyConnContext) trackStream(ctx context.Context, desc *grpc.StreamDesc, conn *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	tc := cc.lookupTracked(conn)
+	if tc == nil {
+		return streamer(ctx, desc, conn, method, opts...)
+	}
+	tc.begin()
+	stream, err := streamer(ctx, desc, conn, method, opts...)
+	if err != nil {
+		tc.end()
+		return nil, err
+	}
+	return &trackedStream{ClientStream: stream, end: tc.end}, nil
+}
+
+// trackedStream ends its call in flight once it receives an error, the io.EOF of the end
+// of the stream included.
+type trackedStream struct {
+	grpc.ClientStream
+	once sync.Once
+	end  func()
+}
+
+func (s *trackedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(s.end)
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type recycleEvents struct {
+	mu     sync.Mutex
+	events []RecycleEvent
+}
+
+func (r *recycleEvents) add(e RecycleEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recycleEvents) closed() []RecycleEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var closed []RecycleEvent
+	for _, e := range r.events {
+		if e.Closed {
+			closed = append(closed, e)
+		}
+	}
+	return closed
+}
+
+func newRecyclingConnContext(t *testing.T, maxAge, drainTimeout time.Duration, events *recycleEvents) (*lazyConnContext, func(time.Duration)) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	cc := NewLazyConnContext(
+		DialOptions(
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		),
+		MaxConnectionAge(maxAge, drainTimeout),
+		OnRecycle(events.add),
+	).(*lazyConnContext)
+	t.Cleanup(func() { _ = cc.Shutdown(context.Background()) })
+	clock := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	cc.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+	return cc, func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		clock = clock.Add(d)
+	}
+}
+
+func check(ctx context.Context, cc ConnContext) error {
+	conn, err := cc.GetConn(ctx, "health")
+	if err != nil {
+		return err
+	}
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMaxConnectionAge(t *testing.T) {
+	events := &recycleEvents{}
+	cc, advance := newRecyclingConnContext(t, time.Hour, time.Minute, events)
+	ctx := context.Background()
+
+	first, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	require.NoError(t, check(ctx, cc))
+	same, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	assert.Same(t, first, same)
+
+	advance(time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, check(ctx, cc), "calls succeed across the recycle")
+			}
+		}()
+	}
+	wg.Wait()
+	second, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	assert.NotSame(t, first, second, "the old conn is replaced")
+
+	require.Eventually(t, func() bool { return len(events.closed()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, connectivity.Shutdown, first.GetState(), "the old conn is closed once drained")
+	closed := events.closed()[0]
+	assert.Equal(t, "health", closed.Addr)
+	assert.Zero(t, closed.InFlight)
+	events.mu.Lock()
+	assert.Len(t, events.events, 2, "the replacement and the close")
+	assert.Equal(t, time.Hour, events.events[0].Age)
+	events.mu.Unlock()
+}
+
+func TestMaxConnectionAge_InFlight(t *testing.T) {
+	events := &recycleEvents{}
+	cc, advance := newRecyclingConnContext(t, time.Hour, time.Minute, events)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	watch, err := healthpb.NewHealthClient(first).Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	advance(time.Hour)
+	require.NoError(t, check(context.Background(), cc))
+	time.Sleep(3 * drainSettle)
+	assert.NotEqual(t, connectivity.Shutdown, first.GetState(), "the old conn is kept while a stream is in flight")
+	assert.Empty(t, events.closed())
+
+	cancel()
+	_, err = watch.Recv()
+	require.Error(t, err)
+	require.Eventually(t, func() bool { return len(events.closed()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, connectivity.Shutdown, first.GetState())
+}
+
+func TestMaxConnectionAge_DrainTimeout(t *testing.T) {
+	events := &recycleEvents{}
+	cc, advance := newRecyclingConnContext(t, time.Hour, 2*drainSettle, events)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	_, err = healthpb.NewHealthClient(first).Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	advance(time.Hour)
+	require.NoError(t, check(context.Background(), cc))
+	require.Eventually(t, func() bool { return len(events.closed()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, events.closed()[0].InFlight, "closed by the drain timeout with the stream in flight")
+}
+
+func TestMaxConnectionAge_Tasks(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		names []string
+	)
+	var calls int32
+	cc := NewLazyConnContext(
+		DialOptions(
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		),
+		MaxConnectionAge(time.Hour, time.Minute),
+		OnRecycle(func(RecycleEvent) {
+			atomic.AddInt32(&calls, 1)
+			panic("boom")
+		}),
+		Tasks(func(name string, fn func(ctx context.Context)) bool {
+			mu.Lock()
+			names = append(names, name)
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn(context.Background())
+			}()
+			return true
+		}),
+	).(*lazyConnContext)
+	t.Cleanup(func() { _ = cc.Shutdown(context.Background()) })
+	clock := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	cc.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	first, err := cc.GetConn(ctx, "health")
+	require.NoError(t, err)
+	clock = clock.Add(time.Hour)
+	require.NoError(t, check(ctx, cc))
+
+	wg.Wait()
+	assert.Equal(t, connectivity.Shutdown, first.GetState(), "the drain is run by Tasks")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "the panics of the callback are recovered")
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"drain connection health", "connection recycle callback", "connection recycle callback"}, names)
+	mu.Unlock()
+	require.NoError(t, check(ctx, cc))
+}
+
+func TestMaxConnectionAge_Disabled(t *testing.T) {
+	events := &recycleEvents{}
+	cc, advance := newRecyclingConnContext(t, 0, 0, events)
+	first, err := cc.GetConn(context.Background(), "health")
+	require.NoError(t, err)
+	advance(1000 * time.Hour)
+	second, err := cc.GetConn(context.Background(), "health")
+	require.NoError(t, err)
+	assert.Same(t, first, second, "conns are not recycled without a max age")
+	assert.Empty(t, events.events)
+}
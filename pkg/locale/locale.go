@@ -0,0 +1,131 @@
+// Package locale carries the language and the time zone of human-facing output, e.g. the
+// descriptions of the planned maintenance of clusters, in contexts:
+//
+//	ctx = locale.NewContext(ctx, locale.New("ru", moscow))
+//	info, err := sdk.ClickHouse().Cluster().MaintenanceInfo(ctx, clusterID)
+//	fmt.Println(info) // formatted in Russian, in the Moscow time
+//
+// The formatting itself takes a Locale rather than a context. Output falls back to English
+// for the languages and the messages without a translation and to UTC without a time zone,
+// so the output of callers that never set a locale doesn't change.
+package locale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLang is the language of the messages without a translation.
+const DefaultLang = "en"
+
+// Default is the locale of the contexts without one: English, UTC.
+var Default = Locale{Lang: DefaultLang, Location: time.UTC}
+
+// Locale is a language and a time zone.
+type Locale struct {
+	// Lang is a BCP 47 language tag, e.g. "en" or "pt-BR". Messages are looked up by the
+	// tag, then by its base language.
+	Lang string
+	// Location is the time zone times are shown in, UTC if nil.
+	Location *time.Location
+}
+
+// New returns the locale of the language and the time zone, DefaultLang if lang is empty
+// and UTC if tz is nil.
+func New(lang string, tz *time.Location) Locale {
+	if lang = strings.TrimSpace(lang); lang == "" {
+		lang = DefaultLang
+	}
+	if tz == nil {
+		tz = time.UTC
+	}
+	return Locale{Lang: lang, Location: tz}
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying l.
+func NewContext(ctx context.Context, l Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the locale of ctx, Default if it has none.
+func FromContext(ctx context.Context) Locale {
+	if l, ok := ctx.Value(contextKey{}).(Locale); ok {
+		return l
+	}
+	return Default
+}
+
+var (
+	mu sync.RWMutex
+	// catalog maps lowercase languages to message keys to fmt formats.
+	catalog = map[string]map[string]string{}
+)
+
+// Register adds the messages, message keys to fmt formats, in the language, replacing the
+// ones of the same keys, e.g. to translate the messages of the SDK to more languages. The
+// layout key TimeLayout is the time.Layout of the language.
+func Register(lang string, messages map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	lang = strings.ToLower(lang)
+	if catalog[lang] == nil {
+		catalog[lang] = map[string]string{}
+	}
+	for key, format := range messages {
+		catalog[lang][key] = format
+	}
+}
+
+// TimeLayout is the message key of the time layout of a language, see Locale.Time.
+const TimeLayout = "time.layout"
+
+// format returns the format of the key in the language of l, its base language or
+// DefaultLang, and the key itself if there is none.
+func (l Locale) format(key string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	lang := strings.ToLower(l.Lang)
+	base, _, _ := strings.Cut(lang, "-")
+	for _, lang := range []string{lang, base, DefaultLang} {
+		if format, ok := catalog[lang][key]; ok {
+			return format
+		}
+	}
+	return key
+}
+
+// Message formats the message of the key with args, see Register.
+func (l Locale) Message(key string, args ...any) string {
+	return fmt.Sprintf(l.format(key), args...)
+}
+
+// Time formats t in the time zone of l with the TimeLayout of its language.
+func (l Locale) Time(t time.Time) string {
+	loc := l.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(l.format(TimeLayout))
+}
+
+func init() {
+	Register("en", map[string]string{
+		TimeLayout:             "Jan 2, 2006 15:04 MST",
+		"maintenance.info":     "maintenance %q is scheduled for %s",
+		"maintenance.deadline": "it can be delayed until %s",
+		"maintenance.window":   "the next maintenance window starts at %s",
+		"maintenance.started":  "it started as %s",
+	})
+	Register("ru", map[string]string{
+		TimeLayout:             "02.01.2006 15:04 MST",
+		"maintenance.info":     "обслуживание %q запланировано на %s",
+		"maintenance.deadline": "его можно отложить до %s",
+		"maintenance.window":   "следующее окно обслуживания начинается %s",
+		"maintenance.started":  "оно началось как %s",
+	})
+}
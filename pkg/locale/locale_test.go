@@ -0,0 +1,35 @@
+package locale
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var at = time.Date(2023, 5, 20, 3, 0, 0, 0, time.UTC)
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, Default, FromContext(context.Background()))
+	l := New("ru", time.FixedZone("MSK", 3*60*60))
+	assert.Equal(t, l, FromContext(NewContext(context.Background(), l)))
+	assert.Equal(t, Default, New(" ", nil))
+}
+
+func TestLocale_Time(t *testing.T) {
+	assert.Equal(t, "May 20, 2023 03:00 UTC", Default.Time(at))
+	assert.Equal(t, "May 20, 2023 03:00 UTC", Locale{}.Time(at), "the zero locale is the default one")
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	assert.Equal(t, "May 20, 2023 08:30 IST", New("en", ist).Time(at))
+	assert.Equal(t, "20.05.2023 08:30 IST", New("ru-RU", ist).Time(at), "the base language of the tag")
+}
+
+func TestLocale_Message(t *testing.T) {
+	Register("xx-test", map[string]string{"maintenance.info": "xx %q %s"})
+	l := New("XX-test", nil)
+	assert.Equal(t, `xx "upgrade" now`, l.Message("maintenance.info", "upgrade", "now"))
+	assert.Equal(t, "it can be delayed until now", l.Message("maintenance.deadline", "now"), "English without a translation")
+	assert.Equal(t, "May 20, 2023 03:00 UTC", l.Time(at), "the English layout without a translated one")
+	assert.Equal(t, "no.such.key", l.Message("no.such.key"))
+}
@@ -0,0 +1,153 @@
+// Package namegen builds resource names following a naming convention and validates them
+// against the constraints of the services before any create, so that bad names fail at once
+// rather than minutes into provisioning:
+//
+//	c := namegen.Convention{Env: "prod", Team: "data", Purpose: "events"}
+//	name, err := c.Generate(namegen.KafkaCluster) // "prod-data-events"
+//
+// The uniqueness of the names is checked against the API by SDK.CheckNameAvailable and the
+// WithNameCheck option of the create calls.
+package namegen
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Scope is where the names of resources must be unique.
+type Scope string
+
+const (
+	// ScopeProject names are unique within the project, e.g. the ones of clusters.
+	ScopeProject Scope = "project"
+	// ScopeCluster names are unique within the cluster, e.g. the ones of Kafka topics.
+	ScopeCluster Scope = "cluster"
+)
+
+// Constraints are the constraints of the names of the resources of a kind.
+type Constraints struct {
+	MinLength, MaxLength int
+	// Pattern is matched by the valid names, described by Charset in errors.
+	Pattern *regexp.Regexp
+	Charset string
+	// Reserved are the names not allowed, whatever the pattern.
+	Reserved []string
+	Scope    Scope
+}
+
+// dnsLabel are the names of lowercase letters, digits and hyphens, starting with a letter
+// and not ending with a hyphen.
+var dnsLabel = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+const dnsLabelCharset = "lowercase letters, digits and hyphens, starting with a letter and not ending with a hyphen"
+
+// The constraints of the names of the services.
+var (
+	ClickHouseCluster = Constraints{MinLength: 1, MaxLength: 63, Pattern: dnsLabel, Charset: dnsLabelCharset, Scope: ScopeProject}
+	KafkaCluster      = Constraints{MinLength: 1, MaxLength: 63, Pattern: dnsLabel, Charset: dnsLabelCharset, Scope: ScopeProject}
+	Network           = Constraints{MinLength: 1, MaxLength: 63, Pattern: dnsLabel, Charset: dnsLabelCharset, Scope: ScopeProject}
+	Transfer          = Constraints{MinLength: 1, MaxLength: 63, Pattern: dnsLabel, Charset: dnsLabelCharset, Scope: ScopeProject}
+	TransferEndpoint  = Constraints{MinLength: 1, MaxLength: 63, Pattern: dnsLabel, Charset: dnsLabelCharset, Scope: ScopeProject}
+	// KafkaTopic are the constraints of Apache Kafka on the names of topics.
+	KafkaTopic = Constraints{
+		MinLength: 1,
+		MaxLength: 249,
+		Pattern:   regexp.MustCompile(`^[a-zA-Z0-9._-]+$`),
+		Charset:   "letters, digits, dots, underscores and hyphens",
+		Reserved:  []string{".", ".."},
+		Scope:     ScopeCluster,
+	}
+)
+
+// ErrInvalidName is matched by errors.Is for every *NameError.
+var ErrInvalidName = errors.New("invalid resource name")
+
+// NameError is returned for the names not meeting their Constraints. It converts to an
+// InvalidArgument gRPC status.
+type NameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *NameError) Error() string {
+	return fmt.Sprintf("invalid resource name %q: %s", e.Name, e.Reason)
+}
+
+func (e *NameError) Is(target error) bool { return target == ErrInvalidName }
+
+func (e *NameError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+// Validate returns *NameError if the name doesn't meet the constraints.
+func (c Constraints) Validate(name string) error {
+	n := utf8.RuneCountInString(name)
+	switch {
+	case n == 0:
+		return &NameError{Name: name, Reason: "empty"}
+	case n < c.MinLength:
+		return &NameError{Name: name, Reason: fmt.Sprintf("shorter than %d characters", c.MinLength)}
+	case c.MaxLength > 0 && n > c.MaxLength:
+		return &NameError{Name: name, Reason: fmt.Sprintf("longer than %d characters", c.MaxLength)}
+	case c.Pattern != nil && !c.Pattern.MatchString(name):
+		return &NameError{Name: name, Reason: "only " + c.Charset + " are allowed"}
+	}
+	for _, r := range c.Reserved {
+		if name == r {
+			return &NameError{Name: name, Reason: "reserved name"}
+		}
+	}
+	return nil
+}
+
+// Convention names the resources after their environment, team and purpose, e.g.
+// "prod-data-events". The empty parts are left out.
+type Convention struct {
+	Env, Team, Purpose string
+}
+
+// Name returns the name of the convention with the suffixes, e.g. an index, joined with
+// hyphens. The parts are lowercased, and their runs of characters other than letters and
+// digits are replaced by hyphens.
+func (c Convention) Name(suffixes ...string) string {
+	var parts []string
+	for _, p := range append([]string{c.Env, c.Team, c.Purpose}, suffixes...) {
+		if p = normalizePart(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Generate returns the Name with the suffixes, or *NameError if it doesn't meet the
+// constraints.
+func (c Convention) Generate(constraints Constraints, suffixes ...string) (string, error) {
+	name := c.Name(suffixes...)
+	if err := constraints.Validate(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func normalizePart(p string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(p) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
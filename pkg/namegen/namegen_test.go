@@ -0,0 +1,103 @@
+package namegen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConstraints(t *testing.T) {
+	projectNames := map[string]bool{
+		"a":                     true,
+		"prod-data-events":      true,
+		"kfc1":                  true,
+		strings.Repeat("a", 63): true,
+		strings.Repeat("a", 64): false,
+		"":                      false,
+		"1cluster":              false,
+		"cluster-":              false,
+		"Cluster":               false,
+		"my_cluster":            false,
+		"my cluster":            false,
+		"кластер":               false,
+	}
+	for name, c := range map[string]Constraints{
+		"clickhouse cluster": ClickHouseCluster,
+		"kafka cluster":      KafkaCluster,
+		"network":            Network,
+		"transfer":           Transfer,
+		"transfer endpoint":  TransferEndpoint,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, ScopeProject, c.Scope)
+			for n, valid := range projectNames {
+				err := c.Validate(n)
+				if valid {
+					assert.NoError(t, err, n)
+				} else {
+					assert.ErrorIs(t, err, ErrInvalidName, n)
+				}
+			}
+		})
+	}
+
+	t.Run("kafka topic", func(t *testing.T) {
+		assert.Equal(t, ScopeCluster, KafkaTopic.Scope)
+		for n, valid := range map[string]bool{
+			"events":                 true,
+			"Events.v1_raw-2":        true,
+			"..events":               true,
+			strings.Repeat("t", 249): true,
+			strings.Repeat("t", 250): false,
+			"":                       false,
+			".":                      false,
+			"..":                     false,
+			"events/raw":             false,
+			"events raw":             false,
+		} {
+			err := KafkaTopic.Validate(n)
+			if valid {
+				assert.NoError(t, err, n)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidName, n)
+			}
+		}
+	})
+}
+
+func TestNameError(t *testing.T) {
+	err := ClickHouseCluster.Validate(strings.Repeat("a", 64))
+	assert.EqualError(t, err, `invalid resource name "`+strings.Repeat("a", 64)+`": longer than 63 characters`)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = KafkaTopic.Validate("events/raw")
+	assert.EqualError(t, err, `invalid resource name "events/raw": only letters, digits, dots, underscores and hyphens are allowed`)
+	assert.EqualError(t, KafkaTopic.Validate(".."), `invalid resource name "..": reserved name`)
+	assert.EqualError(t, Network.Validate(""), `invalid resource name "": empty`)
+
+	var nameErr *NameError
+	require.True(t, errors.As(err, &nameErr))
+	assert.Equal(t, "events/raw", nameErr.Name)
+}
+
+func TestConvention(t *testing.T) {
+	c := Convention{Env: "Prod", Team: "Data Platform", Purpose: "events__raw"}
+	assert.Equal(t, "prod-data-platform-events-raw", c.Name())
+	assert.Equal(t, "prod-data-platform-events-raw-2", c.Name("2"))
+	assert.Equal(t, "staging-ingest", Convention{Env: " staging ", Purpose: "--ingest--"}.Name())
+	assert.Empty(t, Convention{}.Name())
+
+	name, err := Convention{Env: "prod", Team: "data", Purpose: "events"}.Generate(KafkaCluster)
+	require.NoError(t, err)
+	assert.Equal(t, "prod-data-events", name)
+
+	_, err = Convention{Env: "2024", Purpose: "events"}.Generate(ClickHouseCluster)
+	assert.ErrorIs(t, err, ErrInvalidName, "the names of clusters start with a letter")
+	_, err = Convention{Team: strings.Repeat("t", 60), Purpose: "events"}.Generate(Network)
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
@@ -0,0 +1,68 @@
+package paging
+
+import (
+	"time"
+)
+
+const (
+	// DefaultTargetPageLatency is the page latency AdaptivePageSize aims for.
+	DefaultTargetPageLatency = 2 * time.Second
+)
+
+// AdaptivePageSize is a PageSizer tuning the page size by observed latency:
+//   - a page that failed with DeadlineExceeded halves the size and is retried, unless the size is
+//     already min;
+//   - a page slower than the target latency shrinks the size by a quarter;
+//   - a page faster than half of the target latency grows the size by a quarter.
+//
+// The size starts at max and always stays within [min, max]. It depends only on the observed
+// latencies, so it is deterministic under an injected clock.
+type AdaptivePageSize struct {
+	Min, Max int64
+	// Target is the desired page latency. Defaults to DefaultTargetPageLatency.
+	Target time.Duration
+
+	size int64
+}
+
+func NewAdaptivePageSize(min, max int64) *AdaptivePageSize {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &AdaptivePageSize{Min: min, Max: max, size: max}
+}
+
+func (s *AdaptivePageSize) PageSize() int64 { return s.size }
+
+func (s *AdaptivePageSize) Observe(latency time.Duration, err error) bool {
+	target := s.Target
+	if target <= 0 {
+		target = DefaultTargetPageLatency
+	}
+	switch {
+	case err != nil:
+		if !isDeadlineExceeded(err) || s.size == s.Min {
+			return false
+		}
+		s.set(s.size / 2)
+		return true
+	case latency > target:
+		s.set(s.size - s.size/4)
+	case latency < target/2:
+		s.set(s.size + (s.size+3)/4)
+	}
+	return false
+}
+
+func (s *AdaptivePageSize) set(size int64) {
+	if size < s.Min {
+		size = s.Min
+	}
+	if size > s.Max {
+		size = s.Max
+	}
+	s.size = size
+}
@@ -0,0 +1,187 @@
+// Package paging contains an iterator over paginated list calls.
+// Unlike the generated iterators, which make a single List call, it follows NextPage tokens:
+//
+//	it := paging.New(ctx, func(ctx context.Context, p *dcv1.Paging) ([]*clickhouse.Cluster, *dcv1.NextPage, error) {
+//		resp, err := sdk.ClickHouse().Cluster().List(ctx, &clickhouse.ListClustersRequest{ProjectId: projectID, Paging: p})
+//		return resp.GetClusters(), resp.GetNextPage(), err
+//	}, paging.WithAdaptivePageSize(50, 1000))
+package paging
+
+import (
+	"context"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
+)
+
+const DefaultPageSize int64 = 1000
+
+// PageFunc lists a single page. It sets paging on the list request, calls List
+// and returns the items and the next page of the response.
+type PageFunc[T any] func(ctx context.Context, paging *dcv1.Paging) ([]T, *dcv1.NextPage, error)
+
+type Option func(*config)
+
+type config struct {
+	sizer PageSizer
+	now   func() time.Time
+	retry RetryPolicy
+}
+
+// WithPageSize requests pages of the fixed size. It's the default, with DefaultPageSize.
+func WithPageSize(size int64) Option {
+	return func(c *config) { c.sizer = fixedPageSize(size) }
+}
+
+// WithAdaptivePageSize adjusts the page size between min and max by latency of the pages,
+// see AdaptivePageSize.
+func WithAdaptivePageSize(min, max int64) Option {
+	return func(c *config) { c.sizer = NewAdaptivePageSize(min, max) }
+}
+
+// WithPageSizer requests page sizes from the given sizer.
+func WithPageSizer(s PageSizer) Option {
+	return func(c *config) { c.sizer = s }
+}
+
+// WithClock sets the clock used to measure page latency. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) { c.now = now }
+}
+
+// WithRetryPolicy delays and bounds the retries of the failed pages the PageSizer retries.
+// Without it they are retried right away.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *config) { c.retry = p }
+}
+
+// RetryPolicy delays the retries of failed pages, see WithRetryPolicy. operation.PollPolicy
+// implements it, e.g. to share the polling policy of the SDK.
+type RetryPolicy interface {
+	// RetryDelay returns the delay before the retry-th retry in a row, 1-based, and false
+	// if the page must not be retried.
+	RetryDelay(retry int) (time.Duration, bool)
+	// Sleep waits for d, returning the error of ctx if it is done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// PageSizer chooses the size of the next page from observed results of the previous ones.
+type PageSizer interface {
+	PageSize() int64
+	// Observe is called after every page request. It reports whether a failed request should be
+	// retried with the new page size.
+	Observe(latency time.Duration, err error) (retry bool)
+}
+
+type fixedPageSize int64
+
+func (s fixedPageSize) PageSize() int64                   { return int64(s) }
+func (s fixedPageSize) Observe(time.Duration, error) bool { return false }
+
+// Iterator iterates over items of all pages.
+type Iterator[T any] struct {
+	ctx   context.Context
+	fetch PageFunc[T]
+	conf  config
+
+	err   error
+	token string
+	done  bool
+	items []T
+	leak  *lifecycle.Tracker
+}
+
+func New[T any](ctx context.Context, fetch PageFunc[T], opts ...Option) *Iterator[T] {
+	conf := config{sizer: fixedPageSize(DefaultPageSize), now: time.Now}
+	for _, o := range opts {
+		o(&conf)
+	}
+	it := &Iterator[T]{ctx: ctx, fetch: fetch, conf: conf}
+	it.leak = lifecycle.Track(it, "paging.Iterator")
+	return it
+}
+
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if len(it.items) > 1 {
+		it.items = it.items[1:]
+		return true
+	}
+	it.items = nil // consume last item, if any
+
+	for len(it.items) == 0 && !it.done {
+		if err := it.fetchPage(); err != nil {
+			it.err = err
+			it.leak.Close()
+			return false
+		}
+	}
+	if len(it.items) == 0 {
+		it.leak.Close()
+		return false
+	}
+	return true
+}
+
+func (it *Iterator[T]) fetchPage() error {
+	for retries := 1; ; retries++ {
+		paging := &dcv1.Paging{PageSize: it.conf.sizer.PageSize(), PageToken: it.token}
+		start := it.conf.now()
+		items, next, err := it.fetch(it.ctx, paging)
+		retry := it.conf.sizer.Observe(it.conf.now().Sub(start), err)
+		if err == nil {
+			it.items = items
+			it.token = next.GetToken()
+			it.done = it.token == ""
+			return nil
+		}
+		if !retry || it.ctx.Err() != nil {
+			return err
+		}
+		if it.conf.retry != nil {
+			delay, ok := it.conf.retry.RetryDelay(retries)
+			if !ok {
+				return err
+			}
+			if err := it.conf.retry.Sleep(it.ctx, delay); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (it *Iterator[T]) Value() T {
+	if len(it.items) == 0 {
+		panic("calling Value on empty iterator")
+	}
+	return it.items[0]
+}
+
+func (it *Iterator[T]) TakeAll() ([]T, error) {
+	var result []T
+	for it.Next() {
+		result = append(result, it.Value())
+	}
+	return result, it.err
+}
+
+func (it *Iterator[T]) Error() error {
+	return it.err
+}
+
+// Close ends the iteration and releases the buffered items and the page token: Next
+// returns false from then on.
+func (it *Iterator[T]) Close() {
+	it.done, it.items, it.token = true, nil, ""
+	it.leak.Close()
+}
+
+func isDeadlineExceeded(err error) bool {
+	return err == context.DeadlineExceeded || status.Code(err) == codes.DeadlineExceeded
+}
@@ -0,0 +1,152 @@
+package paging
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// simulation serves items from a list over a simulated link. Serving a page advances the fake
+// clock by the page size times the current per-item cost; pages taking longer than the
+// deadline fail with DeadlineExceeded.
+type simulation struct {
+	items    []int
+	now      time.Time
+	deadline time.Duration
+	// perItem returns the per-item cost for the n-th request.
+	perItem func(n int) time.Duration
+
+	requests int
+	sizes    []int64
+}
+
+func (s *simulation) clock() time.Time { return s.now }
+
+func (s *simulation) fetch(ctx context.Context, paging *dcv1.Paging) ([]int, *dcv1.NextPage, error) {
+	s.requests++
+	s.sizes = append(s.sizes, paging.GetPageSize())
+	latency := time.Duration(paging.GetPageSize()) * s.perItem(s.requests)
+	if latency > s.deadline {
+		s.now = s.now.Add(s.deadline)
+		return nil, nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+	s.now = s.now.Add(latency)
+
+	start := 0
+	if paging.GetPageToken() != "" {
+		start, _ = strconv.Atoi(paging.GetPageToken())
+	}
+	end := start + int(paging.GetPageSize())
+	if end >= len(s.items) {
+		return s.items[start:], nil, nil
+	}
+	return s.items[start:end], &dcv1.NextPage{Token: strconv.Itoa(end)}, nil
+}
+
+func simulatedItems(n int) []int {
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+	return items
+}
+
+func TestIterator_FixedPageSize(t *testing.T) {
+	sim := &simulation{items: simulatedItems(25), deadline: time.Hour, perItem: func(int) time.Duration { return time.Millisecond }}
+	got, err := New[int](context.Background(), sim.fetch, WithPageSize(10), WithClock(sim.clock)).TakeAll()
+	require.NoError(t, err)
+	assert.Equal(t, sim.items, got)
+	assert.Equal(t, []int64{10, 10, 10}, sim.sizes)
+}
+
+func TestIterator_AdaptivePageSize(t *testing.T) {
+	// The link is fast for the first requests, degrades to a crawl and then recovers.
+	sim := &simulation{items: simulatedItems(3000), deadline: 5 * time.Second, perItem: func(n int) time.Duration {
+		switch {
+		case n <= 2:
+			return time.Millisecond
+		case n <= 8:
+			return 50 * time.Millisecond
+		default:
+			return 2 * time.Millisecond
+		}
+	}}
+	it := New[int](context.Background(), sim.fetch, WithAdaptivePageSize(10, 400), WithClock(sim.clock))
+	got, err := it.TakeAll()
+	require.NoError(t, err)
+	assert.Equal(t, sim.items, got)
+	assert.Equal(t, []int64{
+		400, 400, // fast link: stays at max
+		400, 200, // 20s and 10s: deadline exceeded, halved and retried
+		100, 75, 57, 43, // 5s down to 2.15s: slower than target, shrinks by a quarter
+		33, 42, 53, 67, 84, 105, 132, 165, 207, 259, 324, 400, // recovered: grows by a quarter
+		400, // capped at max
+	}, sim.sizes)
+}
+
+func TestIterator_AdaptiveGivesUpAtMin(t *testing.T) {
+	sim := &simulation{items: simulatedItems(100), deadline: time.Second, perItem: func(int) time.Duration { return time.Second }}
+	_, err := New[int](context.Background(), sim.fetch, WithAdaptivePageSize(5, 40), WithClock(sim.clock)).TakeAll()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, []int64{40, 20, 10, 5}, sim.sizes)
+}
+
+func TestIterator_NonDeadlineErrorNotRetried(t *testing.T) {
+	calls := 0
+	_, err := New[int](context.Background(), func(ctx context.Context, p *dcv1.Paging) ([]int, *dcv1.NextPage, error) {
+		calls++
+		return nil, nil, status.Error(codes.Unavailable, "unavailable")
+	}, WithAdaptivePageSize(1, 100)).TakeAll()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestIterator_Close(t *testing.T) {
+	sim := &simulation{items: simulatedItems(25), deadline: time.Hour, perItem: func(int) time.Duration { return time.Millisecond }}
+	it := New[int](context.Background(), sim.fetch, WithPageSize(10), WithClock(sim.clock))
+	require.True(t, it.Next())
+	assert.Equal(t, 0, it.Value())
+	it.Close()
+	assert.False(t, it.Next(), "the iteration ends with Close")
+	assert.NoError(t, it.Error())
+	assert.Nil(t, it.items, "the page is released")
+	assert.Equal(t, 1, sim.requests, "no pages are fetched after Close")
+}
+
+// recordingRetryPolicy allows retries retries, recording the delays slept.
+type recordingRetryPolicy struct {
+	retries int
+	slept   []time.Duration
+}
+
+func (p *recordingRetryPolicy) RetryDelay(retry int) (time.Duration, bool) {
+	return time.Duration(retry) * time.Second, retry <= p.retries
+}
+
+func (p *recordingRetryPolicy) Sleep(ctx context.Context, d time.Duration) error {
+	p.slept = append(p.slept, d)
+	return nil
+}
+
+func TestIterator_RetryPolicy(t *testing.T) {
+	sim := &simulation{items: simulatedItems(100), deadline: time.Second, perItem: func(int) time.Duration { return time.Second }}
+	policy := &recordingRetryPolicy{retries: 2}
+	_, err := New[int](context.Background(), sim.fetch, WithAdaptivePageSize(5, 40), WithClock(sim.clock), WithRetryPolicy(policy)).TakeAll()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, []int64{40, 20, 10}, sim.sizes, "the retries are bounded by the policy")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, policy.slept)
+
+	sim = &simulation{items: simulatedItems(100), deadline: time.Second, perItem: func(int) time.Duration { return time.Second }}
+	override := &recordingRetryPolicy{retries: 10}
+	_, err = New[int](context.Background(), sim.fetch, WithAdaptivePageSize(5, 40), WithClock(sim.clock), WithRetryPolicy(policy), WithRetryPolicy(override)).TakeAll()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, []int64{40, 20, 10, 5}, sim.sizes, "the last policy wins, the sizer still gives up at min")
+	assert.Len(t, override.slept, 3)
+}
@@ -0,0 +1,105 @@
+package paging
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// ForEachOption configures ForEachParallel.
+type ForEachOption func(*forEachConfig)
+
+type forEachConfig struct {
+	continueOnError bool
+}
+
+// ContinueOnError makes ForEachParallel process all the items whatever the errors of fn. By
+// default, no item is dispatched after the first error.
+func ContinueOnError() ForEachOption {
+	return func(c *forEachConfig) { c.continueOnError = true }
+}
+
+// ForEachParallel calls fn with every item of the iterator on one of workers goroutines, at
+// least one, e.g. to process a large listing in parallel without loading it all in memory.
+// The pages are fetched one after the other, as the page tokens require, while the items of
+// the previous page are processed: at most one page and one item per worker are held.
+//
+// On the first error of fn, the context of the calls is canceled, and neither items are
+// dispatched nor pages fetched anymore, unless ContinueOnError is set, see ForEachOption.
+// ForEachParallel returns once all the calls of fn have returned, with the errors of fn and
+// of the iterator aggregated, or the error of ctx if it was done before all the items were
+// processed. The iterator is closed if the iteration is stopped early.
+func ForEachParallel[T any](ctx context.Context, it *Iterator[T], workers int, fn func(context.Context, T) error, opts ...ForEachOption) error {
+	var conf forEachConfig
+	for _, o := range opts {
+		o(&conf)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		errs   error
+		failed bool
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The calls canceled after the first error only report the cancellation.
+		if failed && !conf.continueOnError && errors.Is(err, context.Canceled) && parent.Err() == nil {
+			return
+		}
+		errs = multierror.Append(errs, err)
+		failed = true
+		if !conf.continueOnError {
+			cancel()
+		}
+	}
+
+	// The channel is unbuffered: the items wait in the page of the iterator until a worker
+	// takes them.
+	items := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := fn(ctx, item); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	stopped := false
+	for !stopped && ctx.Err() == nil && it.Next() {
+		select {
+		case items <- it.Value():
+		case <-ctx.Done():
+			stopped = true
+		}
+	}
+	stopped = stopped || ctx.Err() != nil
+	close(items)
+	wg.Wait()
+
+	if stopped {
+		it.Close()
+	} else if err := it.Error(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if errs == nil && stopped {
+		return parent.Err()
+	}
+	return errs
+}
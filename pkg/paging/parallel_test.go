@@ -0,0 +1,192 @@
+package paging
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newSimulatedIterator(ctx context.Context, s *simulation, pageSize int64) *Iterator[int] {
+	s.perItem = func(int) time.Duration { return 0 }
+	return New(ctx, s.fetch, WithPageSize(pageSize), WithClock(s.clock))
+}
+
+func TestForEachParallel(t *testing.T) {
+	s := &simulation{items: simulatedItems(1000)}
+	var (
+		mu        sync.Mutex
+		seen      = map[int]int{}
+		running   int32
+		maxActive int32
+	)
+	err := ForEachParallel(context.Background(), newSimulatedIterator(context.Background(), s, 50), 8, func(ctx context.Context, item int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxActive)
+			if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+				break
+			}
+		}
+		mu.Lock()
+		seen[item]++
+		mu.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, seen, 1000)
+	for item, n := range seen {
+		assert.Equal(t, 1, n, "item %d processed once", item)
+	}
+	assert.LessOrEqual(t, maxActive, int32(8), "at most one call per worker")
+	assert.Equal(t, 20, s.requests, "the pages are fetched once")
+}
+
+func TestForEachParallel_StopsOnError(t *testing.T) {
+	s := &simulation{items: simulatedItems(1000)}
+	var calls, after int32
+	var returned atomic.Bool
+	err := ForEachParallel(context.Background(), newSimulatedIterator(context.Background(), s, 10), 4, func(ctx context.Context, item int) error {
+		if returned.Load() {
+			atomic.AddInt32(&after, 1)
+		}
+		atomic.AddInt32(&calls, 1)
+		switch {
+		case item == 25:
+			return fmt.Errorf("item %d failed", item)
+		case item > 25:
+			// The calls running with the failed one see their context canceled.
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	returned.Store(true)
+	require.Error(t, err)
+	assert.EqualError(t, err, "1 error occurred:\n\t* item 25 failed\n\n", "the cancellations are not reported")
+	assert.Less(t, s.requests, 10, "no page is fetched after the failure")
+	assert.Less(t, atomic.LoadInt32(&calls), int32(40))
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(&after), "fn is never called after the return")
+}
+
+func TestForEachParallel_ContinueOnError(t *testing.T) {
+	s := &simulation{items: simulatedItems(100)}
+	var calls int32
+	err := ForEachParallel(context.Background(), newSimulatedIterator(context.Background(), s, 10), 4, func(ctx context.Context, item int) error {
+		atomic.AddInt32(&calls, 1)
+		if item%25 == 0 {
+			return fmt.Errorf("item %d failed", item)
+		}
+		return ctx.Err()
+	}, ContinueOnError())
+	var merr interface{ WrappedErrors() []error }
+	require.True(t, errors.As(err, &merr))
+	assert.Len(t, merr.WrappedErrors(), 4, "the errors are aggregated")
+	assert.Equal(t, int32(100), calls, "all the items are processed")
+}
+
+func TestForEachParallel_IteratorError(t *testing.T) {
+	var pages int
+	it := New(context.Background(), func(ctx context.Context, p *dcv1.Paging) ([]int, *dcv1.NextPage, error) {
+		pages++
+		if pages == 3 {
+			return nil, nil, status.Error(codes.PermissionDenied, "denied")
+		}
+		return []int{1, 2}, &dcv1.NextPage{Token: "next"}, nil
+	})
+	var calls int32
+	err := ForEachParallel(context.Background(), it, 2, func(ctx context.Context, item int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(errors.Unwrap(err)))
+	assert.Equal(t, int32(4), calls, "the items fetched are processed")
+}
+
+func TestForEachParallel_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &simulation{items: simulatedItems(10000)}
+	it := newSimulatedIterator(ctx, s, 100)
+	var calls int32
+	var returned atomic.Bool
+	err := ForEachParallel(ctx, it, 4, func(ctx context.Context, item int) error {
+		if returned.Load() {
+			t.Error("fn called after the return")
+		}
+		if atomic.AddInt32(&calls, 1) == 50 {
+			cancel()
+		}
+		return nil
+	})
+	returned.Store(true)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, s.requests, 5, "no page is fetched once ctx is done")
+	assert.False(t, it.Next(), "the iterator is closed")
+
+	err = ForEachParallel(ctx, New(ctx, s.fetch), 0, func(ctx context.Context, item int) error {
+		t.Error("no item is processed with ctx done")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// benchmarkItem is the processing of an item of the benchmarks, taking a few microseconds.
+func benchmarkItem(item int) {
+	sum := sha256.Sum256([]byte{byte(item)})
+	for i := 0; i < 20; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+}
+
+func BenchmarkForEachParallel(b *testing.B) {
+	const items, pageSize, workers = 50000, 1000, 8
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := &simulation{items: simulatedItems(items)}
+			err := ForEachParallel(context.Background(), newSimulatedIterator(context.Background(), s, pageSize), workers, func(ctx context.Context, item int) error {
+				benchmarkItem(item)
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("drain then process", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := &simulation{items: simulatedItems(items)}
+			all, err := newSimulatedIterator(context.Background(), s, pageSize).TakeAll()
+			if err != nil {
+				b.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			next := int64(-1)
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := atomic.AddInt64(&next, 1); j < int64(len(all)); j = atomic.AddInt64(&next, 1) {
+						benchmarkItem(all[j])
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	})
+}
@@ -0,0 +1,169 @@
+// Package redact scrubs sensitive fields, such as passwords and tokens, out of protobuf messages
+// before they are logged or otherwise shown. Sensitive fields are registered by the full name of
+// their message, the registry is pre-populated with the known ones of the DoubleCloud API:
+//
+//	redact.Register("example.v1.Credentials", "secret", "oauth.refresh_token")
+//	log.Print(protojson.Format(redact.Message(req)))
+package redact
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Placeholder replaces the values of redacted string fields. Fields of other kinds are cleared.
+const Placeholder = "[REDACTED]"
+
+var registry = struct {
+	mu    sync.RWMutex
+	paths map[protoreflect.FullName]map[string]bool
+}{paths: map[protoreflect.FullName]map[string]bool{}}
+
+func init() {
+	for name, paths := range knownSensitiveFields {
+		Register(name, paths...)
+	}
+}
+
+// knownSensitiveFields are the sensitive fields of the DoubleCloud API messages.
+var knownSensitiveFields = map[protoreflect.FullName][]string{
+	"doublecloud.v1.CreateIamTokenResponse": {"iam_token"},
+	"doublecloud.v1.Key":                    {"private_key"},
+
+	"doublecloud.clickhouse.v1.ConnectionInfo":            {"password"},
+	"doublecloud.clickhouse.v1.PrivateConnectionInfo":     {"password"},
+	"doublecloud.clickhouse.v1.ClickhouseConfig.Kafka":    {"sasl_password"},
+	"doublecloud.clickhouse.v1.ClickhouseConfig.Rabbitmq": {"password"},
+
+	"doublecloud.kafka.v1.ConnectionInfo":                {"password"},
+	"doublecloud.kafka.v1.PrivateConnectionInfo":         {"password"},
+	"doublecloud.kafka.v1.MetricsExporterConnectionInfo": {"password"},
+	"doublecloud.kafka.v1.UserSpec":                      {"password"},
+	"doublecloud.kafka.v1.UpdateUserRequest.UpdateSpec":  {"password"},
+
+	// Passwords of the transfer endpoints are all Secret messages.
+	"doublecloud.transfer.v1.endpoint.Secret":                                      {"raw"},
+	"doublecloud.transfer.v1.endpoint.airbyte.AmazonAdsSource":                     {"client_secret", "refresh_token"},
+	"doublecloud.transfer.v1.endpoint.airbyte.AWSCloudTrailSource":                 {"aws_key_id", "aws_secret_key"},
+	"doublecloud.transfer.v1.endpoint.airbyte.BigQuerySource":                      {"credentials_json"},
+	"doublecloud.transfer.v1.endpoint.airbyte.FacebookMarketingSource":             {"access_token"},
+	"doublecloud.transfer.v1.endpoint.airbyte.GoogleAdsSource.Credentials":         {"developer_token", "client_secret", "access_token", "refresh_token"},
+	"doublecloud.transfer.v1.endpoint.airbyte.InstagramSource":                     {"access_token"},
+	"doublecloud.transfer.v1.endpoint.airbyte.LinkedinAdsSource.Credentials":       {"access_token"},
+	"doublecloud.transfer.v1.endpoint.airbyte.LinkedinAdsSource.Credentials.OAuth": {"client_secret", "refresh_token"},
+	"doublecloud.transfer.v1.endpoint.airbyte.MSSQLSource":                         {"password"},
+	"doublecloud.transfer.v1.endpoint.airbyte.RedshiftSource":                      {"password"},
+	"doublecloud.transfer.v1.endpoint.airbyte.S3Source.Provider":                   {"aws_access_key_id", "aws_secret_access_key"},
+
+	"doublecloud.visualization.v1.PlainSecret": {"secret"},
+}
+
+// Register adds sensitive fields of the message with the given full name. A path is a field
+// name of the message, or of its nested messages separated by dots, e.g. "auth.password".
+// Fields of repeated messages on the path are redacted in every element.
+func Register(message protoreflect.FullName, paths ...string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.paths[message] == nil {
+		registry.paths[message] = map[string]bool{}
+	}
+	for _, p := range paths {
+		registry.paths[message][p] = true
+	}
+}
+
+// Paths returns the registered sensitive field paths of the message, sorted.
+func Paths(message protoreflect.FullName) []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	var paths []string
+	for p := range registry.paths[message] {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Message returns a deep copy of msg with the registered sensitive fields redacted,
+// in msg itself and in all messages nested in it. msg is not modified.
+func Message(msg proto.Message) proto.Message {
+	if msg == nil {
+		return nil
+	}
+	c := proto.Clone(msg)
+	scrub(c.ProtoReflect())
+	return c
+}
+
+func scrub(m protoreflect.Message) {
+	if !m.IsValid() {
+		return
+	}
+	for _, p := range Paths(m.Descriptor().FullName()) {
+		redactPath(m, strings.Split(p, "."))
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					scrub(mv.Message())
+					return true
+				})
+			}
+		case fd.Message() == nil:
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				scrub(v.List().Get(i).Message())
+			}
+		default:
+			scrub(v.Message())
+		}
+		return true
+	})
+}
+
+func redactPath(m protoreflect.Message, path []string) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil || !m.Has(fd) {
+		return
+	}
+	if len(path) == 1 {
+		redactField(m, fd)
+		return
+	}
+	if fd.Message() == nil || fd.IsMap() {
+		return
+	}
+	if fd.IsList() {
+		list := m.Mutable(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			redactPath(list.Get(i).Message(), path[1:])
+		}
+		return
+	}
+	redactPath(m.Mutable(fd).Message(), path[1:])
+}
+
+func redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	switch {
+	case fd.IsList() && fd.Kind() == protoreflect.StringKind:
+		list := m.Mutable(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			list.Set(i, protoreflect.ValueOfString(Placeholder))
+		}
+	case fd.IsList() || fd.IsMap():
+		m.Clear(fd)
+	case fd.Kind() == protoreflect.StringKind:
+		m.Set(fd, protoreflect.ValueOfString(Placeholder))
+	case fd.Message() != nil && fd.Message().FullName() == "google.protobuf.StringValue":
+		wrapper := m.Mutable(fd).Message()
+		wrapper.Set(wrapper.Descriptor().Fields().ByName("value"), protoreflect.ValueOfString(Placeholder))
+	default:
+		m.Clear(fd)
+	}
+}
@@ -0,0 +1,96 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint"
+	_ "github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint/airbyte"
+	_ "github.com/doublecloud/go-genproto/doublecloud/visualization/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/doublecloud/go-sdk/iamkey"
+)
+
+func TestMessage(t *testing.T) {
+	resp := &iamkey.CreateIamTokenResponse{IamToken: "t1.secret"}
+	redacted := Message(resp).(*iamkey.CreateIamTokenResponse)
+	assert.Equal(t, Placeholder, redacted.IamToken)
+	assert.Equal(t, "t1.secret", resp.IamToken, "the original is not modified")
+	assert.Nil(t, Message(nil))
+}
+
+func TestMessage_Nested(t *testing.T) {
+	req := &transfer.CreateEndpointRequest{
+		Name: "pg",
+		Settings: &transfer.EndpointSettings{Settings: &transfer.EndpointSettings_PostgresSource{PostgresSource: &endpoint.PostgresSource{
+			User:     "admin",
+			Password: &endpoint.Secret{Value: &endpoint.Secret_Raw{Raw: "hunter2"}},
+		}}},
+	}
+	redacted := Message(req).(*transfer.CreateEndpointRequest)
+	assert.Equal(t, Placeholder, redacted.Settings.GetPostgresSource().Password.GetRaw())
+	assert.Equal(t, "admin", redacted.Settings.GetPostgresSource().User)
+	assert.Equal(t, "hunter2", req.Settings.GetPostgresSource().Password.GetRaw())
+
+	update := &kafka.UpdateUserRequest{UpdateSpec: &kafka.UpdateUserRequest_UpdateSpec{Password: wrapperspb.String("hunter2")}}
+	assert.Equal(t, Placeholder, Message(update).(*kafka.UpdateUserRequest).UpdateSpec.Password.GetValue())
+	assert.Equal(t, "hunter2", update.UpdateSpec.Password.GetValue())
+}
+
+func TestMessage_Lists(t *testing.T) {
+	resp := &clickhouse.ListClustersResponse{Clusters: []*clickhouse.Cluster{
+		{Id: "chc1", ConnectionInfo: &clickhouse.ConnectionInfo{User: "admin", Password: "p1"}},
+		{Id: "chc2"},
+		{Id: "chc3", ConnectionInfo: &clickhouse.ConnectionInfo{User: "admin", Password: "p3"}},
+	}}
+	redacted := Message(resp).(*clickhouse.ListClustersResponse)
+	assert.Equal(t, Placeholder, redacted.Clusters[0].ConnectionInfo.Password)
+	assert.Nil(t, redacted.Clusters[1].ConnectionInfo)
+	assert.Equal(t, Placeholder, redacted.Clusters[2].ConnectionInfo.Password)
+	assert.Equal(t, "p1", resp.Clusters[0].ConnectionInfo.Password)
+}
+
+func TestRegister(t *testing.T) {
+	name := (&clickhouse.Cluster{}).ProtoReflect().Descriptor().FullName()
+	Register(name, "description", "resources.clickhouse.disk_size")
+	t.Cleanup(func() {
+		registry.mu.Lock()
+		delete(registry.paths, name)
+		registry.mu.Unlock()
+	})
+	assert.Equal(t, []string{"description", "resources.clickhouse.disk_size"}, Paths(name))
+
+	cluster := &clickhouse.Cluster{
+		Name:        "c",
+		Description: "internal",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			DiskSize:         wrapperspb.Int64(32),
+		}},
+	}
+	redacted := Message(cluster).(*clickhouse.Cluster)
+	assert.Equal(t, Placeholder, redacted.Description)
+	assert.Nil(t, redacted.Resources.Clickhouse.DiskSize, "non-string fields are cleared")
+	assert.Equal(t, "s1-c2-m4", redacted.Resources.Clickhouse.ResourcePresetId)
+	assert.True(t, proto.Equal(wrapperspb.Int64(32), cluster.Resources.Clickhouse.DiskSize))
+}
+
+func TestKnownSensitiveFields(t *testing.T) {
+	for name, paths := range knownSensitiveFields {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+		require.NoError(t, err, name)
+		md, ok := desc.(protoreflect.MessageDescriptor)
+		require.True(t, ok, name)
+		for _, p := range paths {
+			assert.NotNil(t, md.Fields().ByName(protoreflect.Name(p)), "%s.%s", name, p)
+		}
+	}
+}
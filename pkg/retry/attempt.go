@@ -0,0 +1,87 @@
+package retry
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// AttemptMetadataKey is the metadata key of the number of a request sent again, 2 for the
+	// first retry, e.g. for the server logs to tell the retries of a call.
+	AttemptMetadataKey = "x-attempt"
+	// OriginalRequestTimeMetadataKey is the metadata key of the time of the first request of
+	// the ones numbered by AttemptMetadataKey, in RFC 3339 format with nanoseconds, in UTC.
+	OriginalRequestTimeMetadataKey = "x-original-request-time"
+)
+
+// WithAttemptNumbering returns ctx numbering the requests of all the calls made with it
+// together: the Interceptor sends every request after the first one with its number under
+// AttemptMetadataKey, and the time of the first one under OriginalRequestTimeMetadataKey. The
+// requests of a call are numbered on their own without it: its retries only. It is meant for
+// calls sending the same request, e.g. operation.Resubmit numbers its resubmissions and their
+// retries together. The numbering of ctx, if any, is kept.
+func WithAttemptNumbering(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(attemptsKey{}).(*attempts); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, attemptsKey{}, &attempts{})
+}
+
+// AttemptOf returns the number of the request with the outgoing metadata md, and the time of
+// the first request, see WithAttemptNumbering. ok is false for metadata numbering no
+// request, e.g. the one of a first request.
+func AttemptOf(md metadata.MD) (attempt int, original time.Time, ok bool) {
+	vals := md.Get(AttemptMetadataKey)
+	if len(vals) == 0 {
+		return 0, time.Time{}, false
+	}
+	attempt, err := strconv.Atoi(vals[len(vals)-1])
+	if err != nil || attempt < 2 {
+		return 0, time.Time{}, false
+	}
+	if vals := md.Get(OriginalRequestTimeMetadataKey); len(vals) > 0 {
+		original, _ = time.Parse(time.RFC3339Nano, vals[len(vals)-1])
+	}
+	return attempt, original, true
+}
+
+type attemptsKey struct{}
+
+// attempts numbers the requests of the calls made with a context, see WithAttemptNumbering.
+type attempts struct {
+	mu       sync.Mutex
+	n        int
+	original time.Time
+}
+
+// attemptsOf returns the numbering of ctx, a new one for a single call if it has none.
+func attemptsOf(ctx context.Context) *attempts {
+	if a, ok := ctx.Value(attemptsKey{}).(*attempts); ok {
+		return a
+	}
+	return &attempts{}
+}
+
+// next returns ctx with the metadata of the next request, unchanged for the first one.
+func (a *attempts) next(ctx context.Context, now time.Time) context.Context {
+	a.mu.Lock()
+	a.n++
+	if a.n == 1 {
+		a.original = now
+	}
+	n, original := a.n, a.original
+	a.mu.Unlock()
+	if n == 1 {
+		return ctx
+	}
+	// The keys are set rather than appended, keeping a single value over nested retries.
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(AttemptMetadataKey, strconv.Itoa(n))
+	md.Set(OriginalRequestTimeMetadataKey, original.UTC().Format(time.RFC3339Nano))
+	return metadata.NewOutgoingContext(ctx, md)
+}
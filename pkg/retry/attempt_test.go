@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// recordingInvoker fails every call with Unavailable, recording the outgoing metadata.
+type recordingInvoker struct {
+	mds []metadata.MD
+}
+
+func (r *recordingInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	r.mds = append(r.mds, md)
+	return status.Error(codes.Unavailable, "failed")
+}
+
+// attempts returns the attempts of the recorded calls, 1 for the unnumbered ones,
+// and their original times.
+func (r *recordingInvoker) attempts() ([]int, []time.Time) {
+	var attempts []int
+	var originals []time.Time
+	for _, md := range r.mds {
+		attempt, original, ok := AttemptOf(md)
+		if !ok {
+			attempt = 1
+		}
+		attempts = append(attempts, attempt)
+		originals = append(originals, original)
+	}
+	return attempts, originals
+}
+
+func TestInterceptor_AttemptMetadata(t *testing.T) {
+	i := newTestInterceptor(Config{MaxAttempts: 3})
+	inv := &recordingInvoker{}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "r1")
+	started := time.Now()
+	_ = i.InterceptUnary(ctx, "/svc/Create", nil, nil, nil, inv.invoke)
+	_ = i.InterceptUnary(ctx, "/svc/Create", nil, nil, nil, inv.invoke)
+
+	attempts, originals := inv.attempts()
+	assert.Equal(t, []int{1, 2, 3, 1, 2, 3}, attempts, "every call is numbered on its own")
+	assert.Empty(t, inv.mds[0].Get(AttemptMetadataKey), "the first request is not numbered")
+	assert.Equal(t, originals[1], originals[2])
+	assert.WithinDuration(t, started, originals[1], time.Second)
+	assert.False(t, originals[4].Before(originals[2]), "the time of the first request of the call")
+	for _, md := range inv.mds {
+		assert.Equal(t, []string{"r1"}, md.Get("x-request-id"), "the metadata of ctx is kept")
+	}
+	out, _ := metadata.FromOutgoingContext(ctx)
+	assert.Empty(t, out.Get(AttemptMetadataKey), "ctx is not changed")
+}
+
+func TestWithAttemptNumbering(t *testing.T) {
+	i := newTestInterceptor(Config{MaxAttempts: 2})
+	inv := &recordingInvoker{}
+	ctx := WithAttemptNumbering(context.Background())
+	assert.Equal(t, ctx, WithAttemptNumbering(ctx), "the numbering of ctx is kept")
+	_ = i.InterceptUnary(ctx, "/svc/Create", nil, nil, nil, inv.invoke)
+	_ = i.InterceptUnary(ctx, "/svc/Create", nil, nil, nil, inv.invoke, Disable())
+	_ = i.InterceptUnary(ctx, "/svc/Create", nil, nil, nil, inv.invoke)
+
+	attempts, originals := inv.attempts()
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, attempts, "the calls are numbered together")
+	for _, original := range originals[1:] {
+		assert.Equal(t, originals[1], original, "the time of the first request")
+	}
+	for _, md := range inv.mds[1:] {
+		assert.Len(t, md.Get(AttemptMetadataKey), 1)
+	}
+}
+
+func TestAttemptOf(t *testing.T) {
+	original := time.Date(2023, 5, 15, 12, 0, 0, 123, time.UTC)
+	attempt, got, ok := AttemptOf(metadata.Pairs(AttemptMetadataKey, "3", OriginalRequestTimeMetadataKey, "2023-05-15T12:00:00.000000123Z"))
+	require.True(t, ok)
+	assert.Equal(t, 3, attempt)
+	assert.True(t, original.Equal(got))
+
+	for _, md := range []metadata.MD{nil, metadata.Pairs(AttemptMetadataKey, "1"), metadata.Pairs(AttemptMetadataKey, "two")} {
+		_, _, ok := AttemptOf(md)
+		assert.False(t, ok, md)
+	}
+	attempt, got, ok = AttemptOf(metadata.Pairs(AttemptMetadataKey, "2"))
+	assert.True(t, ok)
+	assert.Equal(t, 2, attempt)
+	assert.True(t, got.IsZero(), "without the original time")
+}
@@ -0,0 +1,224 @@
+// Package retry contains a unary client interceptor retrying calls that failed with transient
+// errors. Retries of all calls made with a context carrying a Budget are bounded by the budget,
+// so nested retry loops, e.g. an operation wait polling through the interceptor, share
+// a single allowance instead of multiplying their attempts:
+//
+//	ctx = retry.WithBudget(ctx, retry.NewBudget(5))
+//	err := op.Wait(ctx, retry.Attempts(3))
+package retry
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultBackoff is the delay before the first retry, doubled for every next one.
+	DefaultBackoff = 100 * time.Millisecond
+	// DefaultMaxBackoff bounds the delay between retries.
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// DefaultCodes are retried if Config.Codes is empty.
+var DefaultCodes = []codes.Code{codes.Unavailable}
+
+// Config configures the Interceptor.
+type Config struct {
+	// MaxAttempts is the number of attempts of a call, including the first one,
+	// unless the call sets Attempts. Calls are not retried if it is less than 2.
+	MaxAttempts int
+	// Codes are the status codes of transient failures. Defaults to DefaultCodes.
+	Codes []codes.Code
+	// Backoff is the delay before the first retry, doubled for every next one up to
+	// DefaultMaxBackoff. Defaults to DefaultBackoff. A retry waits at least the pushback of
+	// the server, see PushbackMetadataKey.
+	Backoff time.Duration
+	// Throttle, if set, throttles the retries of every endpoint, see ThrottleConfig.
+	Throttle *ThrottleConfig
+}
+
+type attemptsOption struct {
+	grpc.EmptyCallOption
+	n int
+}
+
+// Attempts sets the number of attempts of the call, including the first one,
+// overriding Config.MaxAttempts. The last one of the call options wins.
+func Attempts(n int) grpc.CallOption {
+	return attemptsOption{n: n}
+}
+
+// Disable turns retries of the call off.
+func Disable() grpc.CallOption {
+	return Attempts(1)
+}
+
+// Budget bounds the number of retries of all calls sharing it through a context.
+// It is safe for concurrent use.
+type Budget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+// NewBudget returns a budget allowing the given number of retries.
+func NewBudget(retries int) *Budget {
+	return &Budget{remaining: retries}
+}
+
+// Take debits a single retry from the budget. It reports false if the budget is exhausted.
+func (b *Budget) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// Reset sets the number of retries left.
+func (b *Budget) Reset(retries int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remaining = retries
+}
+
+// Remaining returns the number of retries left.
+func (b *Budget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context whose calls debit their retries from the budget.
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFromContext returns the budget of the context, nil if it has none.
+func BudgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// Interceptor retries unary calls failing with the configured codes.
+type Interceptor struct {
+	conf      Config
+	codes     map[codes.Code]bool
+	sleep     func(ctx context.Context, d time.Duration) error
+	throttles *throttles
+}
+
+func NewInterceptor(conf Config) *Interceptor {
+	if len(conf.Codes) == 0 {
+		conf.Codes = DefaultCodes
+	}
+	if conf.Backoff <= 0 {
+		conf.Backoff = DefaultBackoff
+	}
+	i := &Interceptor{conf: conf, codes: map[codes.Code]bool{}, sleep: sleep}
+	if conf.Throttle != nil {
+		i.throttles = newThrottles(*conf.Throttle)
+	}
+	for _, code := range conf.Codes {
+		i.codes[code] = true
+	}
+	return i
+}
+
+func (i *Interceptor) InterceptUnary(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	attempts := i.conf.MaxAttempts
+	for _, o := range opts {
+		if o, ok := o.(attemptsOption); ok {
+			attempts = o.n
+		}
+	}
+	budget := BudgetFromContext(ctx)
+	backoff := i.conf.Backoff
+	var throttle *throttle
+	if i.throttles != nil {
+		throttle = i.throttles.of(target(conn))
+	}
+	var header, trailer metadata.MD
+	attemptOpts := append(opts[:len(opts):len(opts)], grpc.Header(&header), grpc.Trailer(&trailer))
+	numbering := attemptsOf(ctx)
+	for attempt := 1; ; attempt++ {
+		header, trailer = nil, nil
+		err := invoker(numbering.next(ctx, time.Now()), method, req, reply, conn, attemptOpts...)
+		transient := err != nil && i.codes[status.Code(err)]
+		if throttle != nil && (err == nil || transient) {
+			throttle.record(err == nil)
+		}
+		if !transient || attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+		pushback, retry := pushbackOf(trailer, header)
+		if !retry || (throttle != nil && throttle.throttled()) {
+			return err
+		}
+		if budget != nil && !budget.Take() {
+			return err
+		}
+		delay := backoff
+		if pushback > delay {
+			delay = pushback
+		}
+		if err := i.sleep(ctx, delay); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > DefaultMaxBackoff {
+			backoff = DefaultMaxBackoff
+		}
+	}
+}
+
+// PushbackMetadataKey is the metadata of the retry pushback of the server, in milliseconds:
+// the retry of the call waits at least for it, and is not made if it is negative or
+// malformed, as in the retry design of gRPC. It is read from the trailer, where the servers
+// send it with the failures, then from the header.
+const PushbackMetadataKey = "grpc-retry-pushback-ms"
+
+// pushbackOf returns the pushback of the response trailer or header and whether the call
+// may be retried.
+func pushbackOf(trailer, header metadata.MD) (time.Duration, bool) {
+	vals := trailer.Get(PushbackMetadataKey)
+	if len(vals) == 0 {
+		vals = header.Get(PushbackMetadataKey)
+	}
+	if len(vals) == 0 {
+		return 0, true
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(vals[0]), 10, 64)
+	if err != nil || ms < 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+func target(conn *grpc.ClientConn) string {
+	if conn == nil {
+		return ""
+	}
+	return conn.Target()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,228 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// failingInvoker fails every call with the code and counts the calls.
+type failingInvoker struct {
+	code  codes.Code
+	calls int
+}
+
+func (f *failingInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	f.calls++
+	return status.Error(f.code, "failed")
+}
+
+func newTestInterceptor(conf Config) *Interceptor {
+	i := NewInterceptor(conf)
+	i.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	return i
+}
+
+func TestInterceptor(t *testing.T) {
+	for name, tc := range map[string]struct {
+		conf  Config
+		code  codes.Code
+		opts  []grpc.CallOption
+		calls int
+	}{
+		"transient":     {conf: Config{MaxAttempts: 3}, code: codes.Unavailable, calls: 3},
+		"not transient": {conf: Config{MaxAttempts: 3}, code: codes.InvalidArgument, calls: 1},
+		"custom codes":  {conf: Config{MaxAttempts: 3, Codes: []codes.Code{codes.Aborted}}, code: codes.Aborted, calls: 3},
+		"disabled":      {conf: Config{}, code: codes.Unavailable, calls: 1},
+		"call attempts": {conf: Config{MaxAttempts: 3}, code: codes.Unavailable, opts: []grpc.CallOption{Attempts(5)}, calls: 5},
+		"call disable":  {conf: Config{MaxAttempts: 3}, code: codes.Unavailable, opts: []grpc.CallOption{Disable()}, calls: 1},
+		"last wins":     {conf: Config{MaxAttempts: 3}, code: codes.Unavailable, opts: []grpc.CallOption{Disable(), Attempts(2)}, calls: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			inv := &failingInvoker{code: tc.code}
+			err := newTestInterceptor(tc.conf).InterceptUnary(context.Background(), "/svc/Get", nil, nil, nil, inv.invoke, tc.opts...)
+			assert.Equal(t, tc.code, status.Code(err))
+			assert.Equal(t, tc.calls, inv.calls)
+		})
+	}
+}
+
+func TestInterceptor_Budget(t *testing.T) {
+	i := newTestInterceptor(Config{MaxAttempts: 3})
+	budget := NewBudget(3)
+	ctx := WithBudget(context.Background(), budget)
+
+	inv := &failingInvoker{code: codes.Unavailable}
+	_ = i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	_ = i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	_ = i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	assert.Equal(t, 3+3, inv.calls, "three calls share three retries")
+	assert.Equal(t, 0, budget.Remaining())
+	assert.False(t, budget.Take())
+}
+
+func TestInterceptor_ContextDone(t *testing.T) {
+	i := NewInterceptor(Config{MaxAttempts: 3, Backoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	inv := &failingInvoker{code: codes.Unavailable}
+	err := i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, inv.calls)
+}
+
+// scriptedInvoker fails the calls with the codes in order, then succeeds, answering with
+// the pushbacks in order, if any, in the trailers, or in the headers if inHeader.
+type scriptedInvoker struct {
+	codes     []codes.Code
+	pushbacks []string
+	inHeader  bool
+	calls     int
+}
+
+func (f *scriptedInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	n := f.calls
+	f.calls++
+	if n < len(f.pushbacks) && f.pushbacks[n] != "" {
+		for _, o := range opts {
+			switch o := o.(type) {
+			case grpc.HeaderCallOption:
+				if f.inHeader {
+					*o.HeaderAddr = metadata.Pairs(PushbackMetadataKey, f.pushbacks[n])
+				}
+			case grpc.TrailerCallOption:
+				if !f.inHeader {
+					*o.TrailerAddr = metadata.Pairs(PushbackMetadataKey, f.pushbacks[n])
+				}
+			}
+		}
+	}
+	if n < len(f.codes) {
+		return status.Error(f.codes[n], "failed")
+	}
+	return nil
+}
+
+// sleepRecorder records the sleeps of an interceptor without sleeping.
+func sleepRecorder(i *Interceptor) *[]time.Duration {
+	var sleeps []time.Duration
+	i.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	return &sleeps
+}
+
+func TestInterceptor_Pushback(t *testing.T) {
+	unavailable := []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable}
+	for name, tc := range map[string]struct {
+		pushbacks []string
+		calls     int
+		sleeps    []time.Duration
+	}{
+		"none":          {nil, 4, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}},
+		"longer":        {[]string{"1500"}, 4, []time.Duration{1500 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}},
+		"shorter":       {[]string{"", "50"}, 4, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}},
+		"zero":          {[]string{"0"}, 4, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}},
+		"negative":      {[]string{"", "-1"}, 2, []time.Duration{100 * time.Millisecond}},
+		"malformed":     {[]string{"soon"}, 1, nil},
+		"later retries": {[]string{"", "", "10000"}, 4, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 10 * time.Second}},
+	} {
+		for _, inHeader := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/header=%v", name, inHeader), func(t *testing.T) {
+				i := NewInterceptor(Config{MaxAttempts: 4})
+				sleeps := sleepRecorder(i)
+				inv := &scriptedInvoker{codes: unavailable, pushbacks: tc.pushbacks, inHeader: inHeader}
+				_ = i.InterceptUnary(context.Background(), "/svc/Get", nil, nil, nil, inv.invoke)
+				assert.Equal(t, tc.calls, inv.calls)
+				assert.Equal(t, tc.sleeps, *sleeps)
+			})
+		}
+	}
+}
+
+func TestPushbackOf_TrailerFirst(t *testing.T) {
+	d, retry := pushbackOf(metadata.Pairs(PushbackMetadataKey, "300"), metadata.Pairs(PushbackMetadataKey, "-1"))
+	assert.True(t, retry)
+	assert.Equal(t, 300*time.Millisecond, d)
+	d, retry = pushbackOf(nil, metadata.Pairs(PushbackMetadataKey, "200"))
+	assert.True(t, retry)
+	assert.Equal(t, 200*time.Millisecond, d)
+}
+
+func TestInterceptor_PushbackKeepsCallerHeader(t *testing.T) {
+	i := NewInterceptor(Config{MaxAttempts: 2})
+	sleepRecorder(i)
+	var header, trailer metadata.MD
+	opts := []grpc.CallOption{grpc.Header(&header), grpc.Trailer(&trailer)}
+	inv := &scriptedInvoker{codes: []codes.Code{codes.Unavailable}, pushbacks: []string{"10", "20"}, inHeader: true}
+	assert.NoError(t, i.InterceptUnary(context.Background(), "/svc/Get", nil, nil, nil, inv.invoke, opts...))
+	assert.Equal(t, []string{"20"}, header.Get(PushbackMetadataKey), "the caller gets the header of the last attempt")
+
+	inv = &scriptedInvoker{codes: []codes.Code{codes.Unavailable}, pushbacks: []string{"10", "20"}}
+	assert.NoError(t, i.InterceptUnary(context.Background(), "/svc/Get", nil, nil, nil, inv.invoke, opts...))
+	assert.Equal(t, []string{"20"}, trailer.Get(PushbackMetadataKey), "and the trailer")
+	assert.Len(t, opts, 2)
+}
+
+func TestInterceptor_Throttle(t *testing.T) {
+	var changes []ThrottleStats
+	i := NewInterceptor(Config{MaxAttempts: 3, Throttle: &ThrottleConfig{MaxTokens: 4, TokenRatio: 0.5, Stats: func(s ThrottleStats) {
+		changes = append(changes, s)
+	}}})
+	sleepRecorder(i)
+	ctx := context.Background()
+	assert.Empty(t, i.ThrottleStats())
+
+	inv := &failingInvoker{code: codes.Unavailable}
+	_ = i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	assert.Equal(t, 2, inv.calls, "the second failure leaves half of the tokens")
+	assert.Equal(t, []ThrottleStats{{Tokens: 2, MaxTokens: 4, Throttled: true}}, i.ThrottleStats())
+
+	inv.calls = 0
+	_ = i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	assert.Equal(t, 1, inv.calls, "throttled calls are not retried")
+
+	inv = &failingInvoker{code: codes.InvalidArgument}
+	_ = i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, inv.invoke)
+	assert.Equal(t, 1.0, i.ThrottleStats()[0].Tokens, "failures that are not retried don't take tokens")
+
+	ok := &scriptedInvoker{}
+	for n := 0; n < 5; n++ {
+		require.NoError(t, i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, ok.invoke))
+	}
+	assert.Equal(t, []ThrottleStats{{Tokens: 3.5, MaxTokens: 4}}, i.ThrottleStats(), "successes give tokens back")
+
+	recovered := &scriptedInvoker{codes: []codes.Code{codes.Unavailable}}
+	require.NoError(t, i.InterceptUnary(ctx, "/svc/Get", nil, nil, nil, recovered.invoke))
+	assert.Equal(t, 2, recovered.calls)
+
+	var tokens []float64
+	for _, s := range changes {
+		tokens = append(tokens, s.Tokens)
+	}
+	assert.Equal(t, []float64{3, 2, 1, 1.5, 2, 2.5, 3, 3.5, 2.5, 3}, tokens)
+}
+
+func TestInterceptor_ThrottleBounds(t *testing.T) {
+	i := NewInterceptor(Config{MaxAttempts: 2, Throttle: &ThrottleConfig{}})
+	sleepRecorder(i)
+	ok := &scriptedInvoker{}
+	require.NoError(t, i.InterceptUnary(context.Background(), "/svc/Get", nil, nil, nil, ok.invoke))
+	assert.Equal(t, []ThrottleStats{{Tokens: DefaultThrottleMaxTokens, MaxTokens: DefaultThrottleMaxTokens}}, i.ThrottleStats(), "tokens don't exceed the maximum")
+
+	inv := &failingInvoker{code: codes.Unavailable}
+	for n := 0; n < 20; n++ {
+		_ = i.InterceptUnary(context.Background(), "/svc/Get", nil, nil, nil, inv.invoke)
+	}
+	assert.Equal(t, 0.0, i.ThrottleStats()[0].Tokens, "tokens don't go below zero")
+	assert.Nil(t, NewInterceptor(Config{}).ThrottleStats())
+}
@@ -0,0 +1,137 @@
+package retry
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultThrottleMaxTokens is the number of tokens of an endpoint unless
+	// ThrottleConfig.MaxTokens is set.
+	DefaultThrottleMaxTokens = 10
+	// DefaultThrottleTokenRatio is the tokens got back by a success unless
+	// ThrottleConfig.TokenRatio is set.
+	DefaultThrottleTokenRatio = 0.1
+)
+
+// ThrottleConfig configures the client-side throttling of retries, as in the retry design
+// of gRPC: every endpoint starts with MaxTokens tokens, loses a token with every call
+// failing with a retried code and gets TokenRatio tokens back with every successful call.
+// The calls to an endpoint are not retried while it has at most half of MaxTokens left.
+type ThrottleConfig struct {
+	// MaxTokens defaults to DefaultThrottleMaxTokens.
+	MaxTokens float64
+	// TokenRatio defaults to DefaultThrottleTokenRatio.
+	TokenRatio float64
+	// Stats, if set, is called with the state of an endpoint every time it changes, e.g. to
+	// export it as a metric.
+	Stats func(ThrottleStats)
+}
+
+// ThrottleStats is the state of the throttling of the retries to an endpoint.
+type ThrottleStats struct {
+	// Target is the target of the connection to the endpoint.
+	Target    string
+	Tokens    float64
+	MaxTokens float64
+	// Throttled reports whether the calls to the endpoint are not retried.
+	Throttled bool
+}
+
+// throttles are the throttles of the endpoints of an Interceptor.
+type throttles struct {
+	conf ThrottleConfig
+
+	mu        sync.Mutex
+	endpoints map[string]*throttle
+}
+
+func newThrottles(conf ThrottleConfig) *throttles {
+	if conf.MaxTokens <= 0 {
+		conf.MaxTokens = DefaultThrottleMaxTokens
+	}
+	if conf.TokenRatio <= 0 {
+		conf.TokenRatio = DefaultThrottleTokenRatio
+	}
+	return &throttles{conf: conf, endpoints: map[string]*throttle{}}
+}
+
+func (t *throttles) of(target string) *throttle {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	th, ok := t.endpoints[target]
+	if !ok {
+		th = &throttle{conf: &t.conf, target: target, tokens: t.conf.MaxTokens}
+		t.endpoints[target] = th
+	}
+	return th
+}
+
+func (t *throttles) stats() []ThrottleStats {
+	t.mu.Lock()
+	endpoints := make([]*throttle, 0, len(t.endpoints))
+	for _, th := range t.endpoints {
+		endpoints = append(endpoints, th)
+	}
+	t.mu.Unlock()
+	stats := make([]ThrottleStats, 0, len(endpoints))
+	for _, th := range endpoints {
+		th.mu.Lock()
+		stats = append(stats, th.statsLocked())
+		th.mu.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
+
+// throttle is the token bucket of an endpoint.
+type throttle struct {
+	conf   *ThrottleConfig
+	target string
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// record takes a token for a failure, gives some back for a success.
+func (t *throttle) record(success bool) {
+	t.mu.Lock()
+	before := t.tokens
+	if success {
+		t.tokens += t.conf.TokenRatio
+		if t.tokens > t.conf.MaxTokens {
+			t.tokens = t.conf.MaxTokens
+		}
+	} else if t.tokens--; t.tokens < 0 {
+		t.tokens = 0
+	}
+	stats := t.statsLocked()
+	t.mu.Unlock()
+	if t.conf.Stats != nil && stats.Tokens != before {
+		t.conf.Stats(stats)
+	}
+}
+
+func (t *throttle) throttled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statsLocked().Throttled
+}
+
+func (t *throttle) statsLocked() ThrottleStats {
+	return ThrottleStats{
+		Target:    t.target,
+		Tokens:    t.tokens,
+		MaxTokens: t.conf.MaxTokens,
+		Throttled: t.tokens <= t.conf.MaxTokens/2,
+	}
+}
+
+// ThrottleStats returns the state of the throttling of the endpoints called so far, sorted
+// by target, nil without Config.Throttle.
+func (i *Interceptor) ThrottleStats() []ThrottleStats {
+	if i.throttles == nil {
+		return nil
+	}
+	return i.throttles.stats()
+}
@@ -0,0 +1,32 @@
+package sdkerrors
+
+import (
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// CachedNotFoundError is a NotFound error of a Get call served by the negative cache of the
+// SDK, without a call to the API. Its status is the one of the call that was cached.
+type CachedNotFoundError struct {
+	// Err is the error of the call that was cached.
+	Err error
+	// CachedAt is when the error was cached.
+	CachedAt time.Time
+}
+
+func (e *CachedNotFoundError) Error() string {
+	return "cached: " + e.Err.Error()
+}
+
+func (e *CachedNotFoundError) Unwrap() error { return e.Err }
+
+func (e *CachedNotFoundError) GRPCStatus() *status.Status { return status.Convert(e.Err) }
+
+// IsCachedNotFound reports whether err is a NotFound served by the negative cache, see
+// CachedNotFoundError. The calls made with the context of dcsdk.NoCache make a real lookup.
+func IsCachedNotFound(err error) bool {
+	var cached *CachedNotFoundError
+	return errors.As(err, &cached)
+}
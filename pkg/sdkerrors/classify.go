@@ -0,0 +1,119 @@
+package sdkerrors
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FailureClass is the party a failure is attributed to, see Classify, e.g. to exclude the
+// failures caused by user input from an SLO.
+type FailureClass int
+
+const (
+	// Indeterminate failures can't be attributed, e.g. canceled calls or unknown errors.
+	Indeterminate FailureClass = iota
+	// UserError failures are caused by the request or the state of the account, e.g. an
+	// invalid CIDR or an exceeded quota.
+	UserError
+	// ProviderError failures are caused by the provider, e.g. internal errors or a lack of
+	// capacity.
+	ProviderError
+)
+
+func (c FailureClass) String() string {
+	switch c {
+	case Indeterminate:
+		return "Indeterminate"
+	case UserError:
+		return "UserError"
+	case ProviderError:
+		return "ProviderError"
+	}
+	return fmt.Sprintf("FailureClass(%d)", int(c))
+}
+
+// DefaultFailureCodeClasses are the classes of the status codes of Classify. The codes
+// missing, e.g. Unknown, Canceled, Aborted and Unimplemented, are Indeterminate.
+// Unavailable and DeadlineExceeded are provider errors: the operations failing with them
+// have already been retried by the provider.
+var DefaultFailureCodeClasses = map[codes.Code]FailureClass{
+	codes.InvalidArgument:    UserError,
+	codes.NotFound:           UserError,
+	codes.AlreadyExists:      UserError,
+	codes.PermissionDenied:   UserError,
+	codes.ResourceExhausted:  UserError,
+	codes.FailedPrecondition: UserError,
+	codes.OutOfRange:         UserError,
+	codes.Unauthenticated:    UserError,
+	codes.Internal:           ProviderError,
+	codes.Unavailable:        ProviderError,
+	codes.DeadlineExceeded:   ProviderError,
+	codes.DataLoss:           ProviderError,
+}
+
+// DefaultFailureReasonClasses are the classes of the reasons of google.rpc.ErrorInfo of
+// Classify, of any domain, taking precedence over the ones of the codes.
+var DefaultFailureReasonClasses = map[string]FailureClass{
+	"QUOTA_EXCEEDED":     UserError,
+	"INVALID_CIDR":       UserError,
+	"CIDR_OVERLAP":       UserError,
+	"CAPACITY_EXHAUSTED": ProviderError,
+}
+
+type failureReason struct {
+	domain, reason string
+}
+
+// failureReasons are the classes registered with RegisterFailureReason.
+var failureReasons = struct {
+	mu      sync.RWMutex
+	classes map[failureReason]FailureClass
+}{classes: map[failureReason]FailureClass{}}
+
+// RegisterFailureReason sets the class of the failures whose google.rpc.ErrorInfo has the
+// reason, in the domain, e.g. "kafka.double.cloud", or in any domain if it is empty. The
+// registered classes take precedence over the default ones, and the ones of a domain over
+// the ones of any domain.
+func RegisterFailureReason(domain, reason string, class FailureClass) {
+	failureReasons.mu.Lock()
+	defer failureReasons.mu.Unlock()
+	failureReasons.classes[failureReason{domain, reason}] = class
+}
+
+// Classify returns the class of err by the google.rpc.ErrorInfo of its status, see
+// RegisterFailureReason and DefaultFailureReasonClasses, or else by its code, see
+// DefaultFailureCodeClasses. Errors without a status and nil are Indeterminate.
+func Classify(err error) FailureClass {
+	if err == nil {
+		return Indeterminate
+	}
+	st := status.Convert(err)
+	for _, d := range st.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok {
+			continue
+		}
+		if class, ok := reasonClass(info.GetDomain(), info.GetReason()); ok {
+			return class
+		}
+		break
+	}
+	return DefaultFailureCodeClasses[st.Code()]
+}
+
+func reasonClass(domain, reason string) (FailureClass, bool) {
+	failureReasons.mu.RLock()
+	defer failureReasons.mu.RUnlock()
+	if class, ok := failureReasons.classes[failureReason{domain, reason}]; ok {
+		return class, true
+	}
+	if class, ok := failureReasons.classes[failureReason{"", reason}]; ok {
+		return class, true
+	}
+	class, ok := DefaultFailureReasonClasses[reason]
+	return class, ok
+}
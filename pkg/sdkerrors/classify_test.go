@@ -0,0 +1,82 @@
+package sdkerrors
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify_RecordedFailures(t *testing.T) {
+	classByName := map[string]FailureClass{}
+	for _, c := range []FailureClass{Indeterminate, UserError, ProviderError} {
+		classByName[c.String()] = c
+	}
+	f, err := os.Open("testdata/classified_failures.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var n int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", 5)
+		require.Len(t, fields, 5, line)
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		want, ok := classByName[fields[0]]
+		require.True(t, ok, "unknown class %s", fields[0])
+		code, ok := codeByName[fields[1]]
+		require.True(t, ok, "unknown code %s", fields[1])
+		st := status.New(code, fields[4])
+		if fields[2] != "" || fields[3] != "" {
+			st, err = st.WithDetails(&errdetails.ErrorInfo{Domain: fields[2], Reason: fields[3]})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, want, Classify(st.Err()), line)
+		assert.Equal(t, want, Classify(WithMessage(st.Err(), "create cluster")), "the class of wrapped errors: %s", line)
+		n++
+	}
+	require.NoError(t, scanner.Err())
+	assert.NotZero(t, n)
+}
+
+func TestClassify_NoStatus(t *testing.T) {
+	assert.Equal(t, Indeterminate, Classify(nil))
+	assert.Equal(t, Indeterminate, Classify(errors.New("boom")))
+	assert.Equal(t, Indeterminate, Classify(context.Canceled))
+	assert.Equal(t, "FailureClass(7)", FailureClass(7).String())
+}
+
+func TestRegisterFailureReason(t *testing.T) {
+	defer func() { failureReasons.classes = map[failureReason]FailureClass{} }()
+	withReason := func(code codes.Code, domain, reason string) error {
+		st, err := status.New(code, "failed").WithDetails(&errdetails.ErrorInfo{Domain: domain, Reason: reason})
+		require.NoError(t, err)
+		return st.Err()
+	}
+	busy := withReason(codes.FailedPrecondition, "kafka.double.cloud", "NETWORK_BUSY")
+	assert.Equal(t, UserError, Classify(busy), "the class of the code")
+
+	RegisterFailureReason("", "NETWORK_BUSY", Indeterminate)
+	assert.Equal(t, Indeterminate, Classify(busy))
+	RegisterFailureReason("kafka.double.cloud", "NETWORK_BUSY", ProviderError)
+	assert.Equal(t, ProviderError, Classify(busy), "the class of the domain first")
+	assert.Equal(t, Indeterminate, Classify(withReason(codes.FailedPrecondition, "clickhouse.double.cloud", "NETWORK_BUSY")))
+
+	quota := withReason(codes.ResourceExhausted, "clickhouse.double.cloud", "QUOTA_EXCEEDED")
+	RegisterFailureReason("clickhouse.double.cloud", "QUOTA_EXCEEDED", ProviderError)
+	assert.Equal(t, ProviderError, Classify(quota), "the registered classes override the default ones")
+}
@@ -0,0 +1,56 @@
+package sdkerrors
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// UnknownEnum reports a value of a genproto enum the SDK doesn't know, e.g. one added upstream
+// after the SDK was released. kind is the full name of the enum, e.g.
+// "doublecloud.v1.ClusterStatus". Every switch of the SDK on an enum of the API calls it in its
+// default case, then behaves conservatively, as documented by the switch: e.g. an unknown
+// cluster status is not ready, an unknown operation status is not done.
+//
+// The values are logged once per kind and value by default, see SetUnknownEnumHandler. The
+// switches may report a value every time they meet it.
+func UnknownEnum(kind string, value int32) {
+	unknownEnums.RLock()
+	handle := unknownEnums.handle
+	unknownEnums.RUnlock()
+	handle(kind, value)
+}
+
+// SetUnknownEnumHandler replaces the function called by UnknownEnum, logging the values once
+// by default, e.g. to count them in metrics or to fail tests, until restore is called.
+func SetUnknownEnumHandler(handle func(kind string, value int32)) (restore func()) {
+	unknownEnums.Lock()
+	defer unknownEnums.Unlock()
+	prev := unknownEnums.handle
+	unknownEnums.handle = handle
+	return func() {
+		unknownEnums.Lock()
+		defer unknownEnums.Unlock()
+		unknownEnums.handle = prev
+	}
+}
+
+type enumValue struct {
+	kind  string
+	value int32
+}
+
+var unknownEnums = struct {
+	sync.RWMutex
+	handle func(kind string, value int32)
+}{handle: logUnknownEnum}
+
+// loggedEnums are the values logged by logUnknownEnum.
+var loggedEnums sync.Map
+
+func logUnknownEnum(kind string, value int32) {
+	if _, logged := loggedEnums.LoadOrStore(enumValue{kind, value}, true); logged {
+		return
+	}
+	grpclog.Warningf("dcsdk: unknown %s value %d, newer than the SDK: handled conservatively, consider upgrading the SDK", kind, value)
+}
@@ -0,0 +1,37 @@
+package sdkerrors
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestUnknownEnum_Handler(t *testing.T) {
+	type report struct {
+		kind  string
+		value int32
+	}
+	var reports []report
+	restore := SetUnknownEnumHandler(func(kind string, value int32) { reports = append(reports, report{kind, value}) })
+	UnknownEnum("doublecloud.v1.ClusterStatus", 42)
+	UnknownEnum("doublecloud.v1.ClusterStatus", 42)
+	restore()
+	UnknownEnum("doublecloud.v1.ClusterStatus", 43)
+	assert.Equal(t, []report{{"doublecloud.v1.ClusterStatus", 42}, {"doublecloud.v1.ClusterStatus", 42}}, reports)
+}
+
+func TestUnknownEnum_LoggedOnce(t *testing.T) {
+	var warnings bytes.Buffer
+	grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, &warnings, io.Discard))
+	defer grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, io.Discard, io.Discard))
+
+	UnknownEnum("doublecloud.v1.Operation.Status", 17)
+	UnknownEnum("doublecloud.v1.Operation.Status", 17)
+	UnknownEnum("doublecloud.v1.Operation.Status", 18)
+	assert.Equal(t, 1, strings.Count(warnings.String(), "unknown doublecloud.v1.Operation.Status value 17"))
+	assert.Equal(t, 1, strings.Count(warnings.String(), "unknown doublecloud.v1.Operation.Status value 18"))
+}
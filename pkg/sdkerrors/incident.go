@@ -0,0 +1,76 @@
+package sdkerrors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// Incident is an incident of the provider active when a call failed, e.g. a degraded
+// control plane, see IncidentInfo.
+type Incident struct {
+	ID    string
+	Title string
+	// Component is the affected part of the provider, e.g. "ClickHouse control plane".
+	Component string
+	// Status is the state of the component, e.g. "degraded" or "maintenance".
+	Status string
+	Since  time.Time
+}
+
+func (i *Incident) String() string {
+	component := i.Component
+	if component == "" {
+		component = i.Title
+	}
+	return fmt.Sprintf("provider reports %s %s since %s", i.Status, component, i.Since.UTC().Format("15:04 MST"))
+}
+
+// WithIncident annotates err with the incident active when it occurred. The status of err,
+// if any, is kept.
+func WithIncident(err error, incident *Incident) error {
+	if err == nil {
+		return nil
+	}
+	withIncident := errWithIncident{err, incident}
+	if _, ok := err.(statusErr); ok {
+		return &statusErrWithIncident{withIncident}
+	}
+	return &withIncident
+}
+
+// IncidentInfo returns the incident err was annotated with by WithIncident, if any.
+func IncidentInfo(err error) (*Incident, bool) {
+	var e *errWithIncident
+	if errors.As(err, &e) {
+		return e.incident, true
+	}
+	var s *statusErrWithIncident
+	if errors.As(err, &s) {
+		return s.incident, true
+	}
+	return nil, false
+}
+
+type errWithIncident struct {
+	err      error
+	incident *Incident
+}
+
+type statusErrWithIncident struct {
+	errWithIncident
+}
+
+func (e *errWithIncident) Error() string {
+	return fmt.Sprintf("%s (%s)", e.err, e.incident)
+}
+
+func (e *errWithIncident) Unwrap() error {
+	return e.err
+}
+
+func (e *statusErrWithIncident) GRPCStatus() *status.Status {
+	return status.Convert(e.err)
+}
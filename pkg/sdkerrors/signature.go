@@ -0,0 +1,93 @@
+package sdkerrors
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// NormalizeRule replaces the matches of Pattern in error messages with Replacement, so
+// that failures differing only in e.g. IDs and timestamps get the same Signature.
+type NormalizeRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultNormalizeRules are the rules applied first by Normalize, in order:
+//   - "time": RFC 3339 timestamps, e.g. 2023-05-12T14:30:11.5Z or 2023-05-12 14:30:11;
+//   - "uuid": UUIDs, e.g. request IDs;
+//   - "id": DoubleCloud resource IDs, words of 19 or 20 lowercase letters or digits
+//     starting with 3 letters, e.g. chcl9x8w7v6u5t4s3r2q;
+//   - "hex": hexadecimal strings of 16 digits or more, e.g. trace IDs and hashes;
+//   - "ip": IPv4 addresses with an optional port;
+//   - "duration": durations such as 1.5s, 300ms or 1h30m;
+//   - "space": runs of whitespace, collapsed to a single space.
+var DefaultNormalizeRules = []NormalizeRule{
+	{Name: "time", Pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), Replacement: "<time>"},
+	{Name: "uuid", Pattern: regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), Replacement: "<uuid>"},
+	{Name: "id", Pattern: regexp.MustCompile(`\b[a-z]{3}[0-9a-z]{16,17}\b`), Replacement: "<id>"},
+	{Name: "hex", Pattern: regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`), Replacement: "<hex>"},
+	{Name: "ip", Pattern: regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), Replacement: "<ip>"},
+	{Name: "duration", Pattern: regexp.MustCompile(`\b(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+\b`), Replacement: "<duration>"},
+	{Name: "space", Pattern: regexp.MustCompile(`\s+`), Replacement: " "},
+}
+
+// normalizeRules are the rules registered with RegisterNormalizeRule, applied after
+// DefaultNormalizeRules.
+var normalizeRules struct {
+	mu    sync.RWMutex
+	rules []NormalizeRule
+}
+
+// RegisterNormalizeRule adds a rule applied by Normalize after the default rules and the
+// rules registered before, e.g. for the IDs of a service not covered by the default rules.
+func RegisterNormalizeRule(rule NormalizeRule) error {
+	if rule.Pattern == nil {
+		return errors.New("sdkerrors: normalize rule " + rule.Name + " has no pattern")
+	}
+	normalizeRules.mu.Lock()
+	defer normalizeRules.mu.Unlock()
+	normalizeRules.rules = append(normalizeRules.rules, rule)
+	return nil
+}
+
+// Normalize strips the parts of an error message changing between occurrences of the same
+// failure with DefaultNormalizeRules and the rules of RegisterNormalizeRule.
+func Normalize(message string) string {
+	for _, r := range DefaultNormalizeRules {
+		message = r.Pattern.ReplaceAllString(message, r.Replacement)
+	}
+	normalizeRules.mu.RLock()
+	defer normalizeRules.mu.RUnlock()
+	for _, r := range normalizeRules.rules {
+		message = r.Pattern.ReplaceAllString(message, r.Replacement)
+	}
+	return strings.TrimSpace(message)
+}
+
+// Signature returns a stable fingerprint of err to deduplicate failures: the status code,
+// the domain and the reason of its google.rpc.ErrorInfo, if any, and the hash of its
+// normalized message, see Normalize, e.g. "Unavailable|clickhouse.double.cloud|CAPACITY_EXHAUSTED|1f2e3d4c5b6a7988".
+// Errors without a status have the code Unknown. It returns "" for a nil err.
+func Signature(err error) string {
+	if err == nil {
+		return ""
+	}
+	st := status.Convert(err)
+	var domain, reason string
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			domain, reason = info.GetDomain(), info.GetReason()
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte(Normalize(st.Message())))
+	return strings.Join([]string{st.Code().String(), domain, reason, hex.EncodeToString(sum[:8])}, "|")
+}
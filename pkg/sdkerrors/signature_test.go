@@ -0,0 +1,94 @@
+package sdkerrors
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var codeByName = map[string]codes.Code{}
+
+func init() {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		codeByName[c.String()] = c
+	}
+}
+
+func TestSignature_ObservedFailures(t *testing.T) {
+	f, err := os.Open("testdata/failures.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	signatures := map[string]string{}
+	groups := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", 5)
+		require.Len(t, fields, 5, line)
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		group, message := fields[0], fields[4]
+		code, ok := codeByName[fields[1]]
+		require.True(t, ok, "unknown code %s", fields[1])
+		st := status.New(code, message)
+		if fields[2] != "" || fields[3] != "" {
+			st, err = st.WithDetails(&errdetails.ErrorInfo{Domain: fields[2], Reason: fields[3]})
+			require.NoError(t, err)
+		}
+
+		sig := Signature(WithMessage(st.Err(), "create cluster"))
+		assert.True(t, strings.HasPrefix(sig, fields[1]+"|"+fields[2]+"|"+fields[3]+"|"), sig)
+		if want, ok := signatures[group]; ok {
+			assert.Equal(t, want, sig, "%s: %s", group, Normalize(message))
+			continue
+		}
+		if other, ok := groups[sig]; ok {
+			t.Errorf("%s has the signature of %s: %s", group, other, Normalize(message))
+		}
+		signatures[group] = sig
+		groups[sig] = group
+	}
+	require.NoError(t, scanner.Err())
+	assert.NotEmpty(t, signatures)
+}
+
+func TestNormalize(t *testing.T) {
+	for message, want := range map[string]string{
+		"host chcl9x8w7v6u5t4s3r2q-1 did not become alive in 15m":        "host <id>-1 did not become alive in <duration>",
+		"last heartbeat at 2023-05-12T14:31:02.318+02:00, retry in 1.5s": "last heartbeat at <time>, retry in <duration>",
+		"request 3f2b9c4e-1a7d-4e8b-9c6f-2d5a8b7e1f03 to 10.12.0.7:9000": "request <uuid> to <ip>",
+		"trace 4bf92f3577b34da6a3ce929d0e0e4736\n\tfailed":               "trace <hex> failed",
+		"resource preset s2-c2-m4 in eu-central-1a":                      "resource preset s2-c2-m4 in eu-central-1a",
+	} {
+		assert.Equal(t, want, Normalize(message), message)
+	}
+}
+
+func TestRegisterNormalizeRule(t *testing.T) {
+	defer func() { normalizeRules.rules = nil }()
+	message := "shard shard-0042 is read-only"
+	assert.Equal(t, message, Normalize(message))
+	require.NoError(t, RegisterNormalizeRule(NormalizeRule{Name: "shard", Pattern: regexp.MustCompile(`shard-\d+`), Replacement: "<shard>"}))
+	assert.Equal(t, "shard <shard> is read-only", Normalize(message))
+	assert.Error(t, RegisterNormalizeRule(NormalizeRule{Name: "empty"}))
+}
+
+func TestSignature_NoStatus(t *testing.T) {
+	assert.Empty(t, Signature(nil))
+	assert.Equal(t, Signature(errors.New("dial 10.0.0.1:443: connection refused")), Signature(errors.New("dial 10.0.0.2:443: connection refused")))
+	assert.True(t, strings.HasPrefix(Signature(errors.New("boom")), "Unknown|||"))
+}
@@ -0,0 +1,201 @@
+package specutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/doublecloud/go-sdk/pkg/redact"
+)
+
+// Unset is the value of FieldChange.Old and New for fields that aren't set.
+const Unset = "<unset>"
+
+// FieldChange is a difference between two messages in a single field.
+type FieldChange struct {
+	// Path of the field from the root message, e.g. `resources.clickhouse.disk_size`,
+	// `hosts[1]` or `labels["env"]`.
+	Path string
+	Old  string
+	New  string
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+// Diff returns the changes turning a into b, in the order of field declaration, with map
+// entries sorted by key.
+// Well-known wrapper types are compared as scalars, excluded fields (see ExcludeFields)
+// and unknown fields are ignored. Messages of different types differ in the root path "".
+// Values of sensitive fields (see redact.Register) are reported as redact.Placeholder.
+func Diff(a, b proto.Message) []FieldChange {
+	am, bm := a.ProtoReflect(), b.ProtoReflect()
+	if am.Descriptor().FullName() != bm.Descriptor().FullName() {
+		return []FieldChange{{Old: string(am.Descriptor().FullName()), New: string(bm.Descriptor().FullName())}}
+	}
+	var d differ
+	d.messages("", am, bm, nil)
+	return d.changes
+}
+
+type differ struct {
+	changes []FieldChange
+}
+
+func (d *differ) add(path, old, new string) {
+	d.changes = append(d.changes, FieldChange{Path: path, Old: old, New: new})
+}
+
+// messages diffs messages of the same type. sensitive are the sensitive field paths
+// relative to the messages registered for their ancestors.
+func (d *differ) messages(prefix string, a, b protoreflect.Message, sensitive []string) {
+	sensitive = append(sensitive, redact.Paths(a.Descriptor().FullName())...)
+	fields := a.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if isExcluded(fd) {
+			continue
+		}
+		path := string(fd.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		nested, isSensitive := sensitiveField(sensitive, fd)
+		switch {
+		case isSensitive:
+			if !fieldEqual(a, b, fd) {
+				d.add(path, redactedField(a, fd), redactedField(b, fd))
+			}
+		case fd.IsList():
+			d.lists(path, fd, a.Get(fd).List(), b.Get(fd).List(), nested)
+		case fd.IsMap():
+			d.maps(path, fd, a.Get(fd).Map(), b.Get(fd).Map())
+		case fd.Message() != nil && !isWrapper(fd.Message()) && a.Has(fd) && b.Has(fd):
+			d.messages(path, a.Get(fd).Message(), b.Get(fd).Message(), nested)
+		default:
+			old, new := formatField(a, fd), formatField(b, fd)
+			if old != new {
+				d.add(path, old, new)
+			}
+		}
+	}
+}
+
+func (d *differ) lists(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.List, sensitive []string) {
+	for i := 0; i < a.Len() || i < b.Len(); i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= a.Len():
+			d.add(p, Unset, formatValue(fd, b.Get(i)))
+		case i >= b.Len():
+			d.add(p, formatValue(fd, a.Get(i)), Unset)
+		case fd.Message() != nil && !isWrapper(fd.Message()):
+			d.messages(p, a.Get(i).Message(), b.Get(i).Message(), sensitive)
+		default:
+			if old, new := formatValue(fd, a.Get(i)), formatValue(fd, b.Get(i)); old != new {
+				d.add(p, old, new)
+			}
+		}
+	}
+}
+
+func (d *differ) maps(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.Map) {
+	keys := map[string]protoreflect.MapKey{}
+	collect := func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys[formatValue(fd.MapKey(), k.Value())] = k
+		return true
+	}
+	a.Range(collect)
+	b.Range(collect)
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	vd := fd.MapValue()
+	for _, ks := range sorted {
+		k := keys[ks]
+		p := fmt.Sprintf("%s[%s]", path, ks)
+		switch {
+		case !a.Has(k):
+			d.add(p, Unset, formatValue(vd, b.Get(k)))
+		case !b.Has(k):
+			d.add(p, formatValue(vd, a.Get(k)), Unset)
+		case vd.Message() != nil && !isWrapper(vd.Message()):
+			d.messages(p, a.Get(k).Message(), b.Get(k).Message(), nil)
+		default:
+			if old, new := formatValue(vd, a.Get(k)), formatValue(vd, b.Get(k)); old != new {
+				d.add(p, old, new)
+			}
+		}
+	}
+}
+
+// sensitiveField reports whether the field is one of the sensitive paths, and returns
+// the paths nested in it otherwise.
+func sensitiveField(sensitive []string, fd protoreflect.FieldDescriptor) (nested []string, ok bool) {
+	name := string(fd.Name())
+	for _, p := range sensitive {
+		if p == name {
+			return nil, true
+		}
+		if strings.HasPrefix(p, name+".") {
+			nested = append(nested, p[len(name)+1:])
+		}
+	}
+	return nested, false
+}
+
+func fieldEqual(a, b protoreflect.Message, fd protoreflect.FieldDescriptor) bool {
+	onlyField := func(m protoreflect.Message) proto.Message {
+		c := m.New()
+		if m.Has(fd) {
+			c.Set(fd, m.Get(fd))
+		}
+		return c.Interface()
+	}
+	return proto.Equal(onlyField(a), onlyField(b))
+}
+
+func redactedField(m protoreflect.Message, fd protoreflect.FieldDescriptor) string {
+	if !m.Has(fd) {
+		return Unset
+	}
+	return redact.Placeholder
+}
+
+func formatField(m protoreflect.Message, fd protoreflect.FieldDescriptor) string {
+	if fd.HasPresence() && !m.Has(fd) {
+		return Unset
+	}
+	return formatValue(fd, m.Get(fd))
+}
+
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		m := v.Message()
+		if isWrapper(m.Descriptor()) {
+			inner := m.Descriptor().Fields().ByName("value")
+			return formatValue(inner, m.Get(inner))
+		}
+		return "{" + strings.TrimSpace(prototext.MarshalOptions{}.Format(redact.Message(m.Interface()))) + "}"
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return fmt.Sprint(v.Enum())
+	case protoreflect.StringKind:
+		return fmt.Sprintf("%q", v.String())
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%x", v.Bytes())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,114 @@
+package specutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDiff(t *testing.T) {
+	a := &clickhouse.CreateClusterRequest{
+		ProjectId: "p",
+		Name:      "c",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			DiskSize:         wrapperspb.Int64(32),
+		}},
+	}
+	b := &clickhouse.CreateClusterRequest{
+		ProjectId: "p",
+		Name:      "c2",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			DiskSize:         wrapperspb.Int64(64),
+			ReplicaCount:     wrapperspb.Int64(3),
+		}},
+	}
+
+	assert.Equal(t, []FieldChange{
+		{Path: "name", Old: `"c"`, New: `"c2"`},
+		{Path: "resources.clickhouse.disk_size", Old: "32", New: "64"},
+		{Path: "resources.clickhouse.replica_count", Old: Unset, New: "3"},
+	}, Diff(a, b))
+	assert.Empty(t, Diff(a, a))
+}
+
+func TestDiff_ListsAndMaps(t *testing.T) {
+	pg := func(hosts ...string) *transfer.EndpointSettings {
+		return &transfer.EndpointSettings{Settings: &transfer.EndpointSettings_PostgresSource{PostgresSource: &endpoint.PostgresSource{
+			Connection: &endpoint.PostgresConnection{Connection: &endpoint.PostgresConnection_OnPremise{
+				OnPremise: &endpoint.OnPremisePostgres{Hosts: hosts},
+			}},
+		}}}
+	}
+	a := &transfer.CreateEndpointRequest{
+		Labels:   map[string]string{"env": "prod", "team": "core", "old": "x"},
+		Settings: pg("h1", "h2"),
+	}
+	b := &transfer.CreateEndpointRequest{
+		Labels:   map[string]string{"env": "stage", "team": "core", "new": "y"},
+		Settings: pg("h1", "h3", "h4"),
+	}
+
+	assert.Equal(t, []FieldChange{
+		{Path: `labels["env"]`, Old: `"prod"`, New: `"stage"`},
+		{Path: `labels["new"]`, Old: Unset, New: `"y"`},
+		{Path: `labels["old"]`, Old: `"x"`, New: Unset},
+		{Path: "settings.postgres_source.connection.on_premise.hosts[1]", Old: `"h2"`, New: `"h3"`},
+		{Path: "settings.postgres_source.connection.on_premise.hosts[2]", Old: Unset, New: `"h4"`},
+	}, Diff(a, b))
+}
+
+func TestDiff_MapOrderingIsDeterministic(t *testing.T) {
+	var keys []int
+	for i := 0; i < 32; i++ {
+		keys = append(keys, i)
+	}
+	a, b := labeledEndpoint(keys), labeledEndpoint(keys)
+	for k := range b.Labels {
+		b.Labels[k] += "-changed"
+	}
+	want := Diff(a, b)
+	assert.Len(t, want, 32)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, want, Diff(a, b))
+	}
+}
+
+func TestDiff_ExcludedAndDifferentTypes(t *testing.T) {
+	a := &clickhouse.Cluster{Name: "c", CreateTime: timestamppb.New(time.Unix(1, 0))}
+	b := &clickhouse.Cluster{Name: "c", CreateTime: timestamppb.New(time.Unix(2, 0))}
+	assert.Empty(t, Diff(a, b))
+
+	assert.Equal(t, []FieldChange{{Old: "doublecloud.clickhouse.v1.Cluster", New: "doublecloud.transfer.v1.CreateEndpointRequest"}},
+		Diff(a, &transfer.CreateEndpointRequest{}))
+}
+
+func TestDiff_Redacted(t *testing.T) {
+	settings := func(user, password string) *transfer.EndpointSettings {
+		return &transfer.EndpointSettings{Settings: &transfer.EndpointSettings_PostgresSource{PostgresSource: &endpoint.PostgresSource{
+			User:     user,
+			Password: &endpoint.Secret{Value: &endpoint.Secret_Raw{Raw: password}},
+		}}}
+	}
+	a := &transfer.CreateEndpointRequest{Name: "pg", Settings: settings("admin", "old")}
+	b := &transfer.CreateEndpointRequest{Name: "pg", Settings: settings("root", "new")}
+	assert.Equal(t, []FieldChange{
+		{Path: "settings.postgres_source.user", Old: `"admin"`, New: `"root"`},
+		{Path: "settings.postgres_source.password.raw", Old: "[REDACTED]", New: "[REDACTED]"},
+	}, Diff(a, b))
+	assert.Empty(t, Diff(a, &transfer.CreateEndpointRequest{Name: "pg", Settings: settings("admin", "old")}))
+
+	c := &clickhouse.Cluster{ConnectionInfo: &clickhouse.ConnectionInfo{Password: "p"}}
+	assert.Equal(t, []FieldChange{{Path: "connection_info.password", Old: "[REDACTED]", New: Unset}},
+		Diff(c, &clickhouse.Cluster{ConnectionInfo: &clickhouse.ConnectionInfo{}}))
+
+	assert.Equal(t, []FieldChange{{Path: "connection_info", Old: `{password:"[REDACTED]"}`, New: Unset}},
+		Diff(c, &clickhouse.Cluster{}))
+}
@@ -0,0 +1,99 @@
+package specutil
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// driftExcluded holds server-managed fields by message type, see ExcludeFromDrift.
+var driftExcluded = struct {
+	sync.RWMutex
+	fields map[protoreflect.FullName]map[protoreflect.Name]bool
+}{fields: map[protoreflect.FullName]map[protoreflect.Name]bool{}}
+
+func init() {
+	// Identity, state and connection details are managed by the server.
+	ExcludeFromDrift("doublecloud.clickhouse.v1.Cluster",
+		"id", "status", "connection_info", "private_connection_info", "maintenance_operation")
+	ExcludeFromDrift("doublecloud.kafka.v1.Cluster",
+		"id", "status", "connection_info", "private_connection_info", "planned_operation", "metrics_exporter_connection_info")
+}
+
+// ExcludeFromDrift registers server-managed fields of the message type ignored by Drift,
+// in addition to the fields excluded with ExcludeFields. The exclusion applies to messages
+// of the type at any depth.
+func ExcludeFromDrift(message protoreflect.FullName, fields ...protoreflect.Name) {
+	driftExcluded.Lock()
+	defer driftExcluded.Unlock()
+	set := driftExcluded.fields[message]
+	if set == nil {
+		set = map[protoreflect.Name]bool{}
+		driftExcluded.fields[message] = set
+	}
+	for _, f := range fields {
+		set[f] = true
+	}
+}
+
+func isDriftExcluded(fd protoreflect.FieldDescriptor) bool {
+	driftExcluded.RLock()
+	defer driftExcluded.RUnlock()
+	return driftExcluded.fields[fd.ContainingMessage().FullName()][fd.Name()]
+}
+
+// Drift returns the changes turning the actual resource into the desired one, like Diff,
+// comparing only the fields set in desired: a desired spec doesn't list the fields defaulted
+// by the service. Fields of messages set in desired are compared the same way, except for
+// well-known wrapper types, lists and maps, which are compared whole. Fields excluded from
+// drift (see ExcludeFromDrift) are ignored. Zero scalars can't be told from unset ones
+// in proto3, so desired can't require a field to be zero.
+func Drift(actual, desired proto.Message) []FieldChange {
+	a, d := proto.Clone(actual), proto.Clone(desired)
+	if a.ProtoReflect().Descriptor().FullName() == d.ProtoReflect().Descriptor().FullName() {
+		normalize(a.ProtoReflect(), d.ProtoReflect())
+	}
+	return Diff(a, d)
+}
+
+// normalize clears the fields of actual not set in desired, and the fields excluded from drift of both.
+func normalize(actual, desired protoreflect.Message) {
+	fields := desired.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case isDriftExcluded(fd):
+			actual.Clear(fd)
+			desired.Clear(fd)
+		case !desired.Has(fd):
+			actual.Clear(fd)
+		case fd.Message() != nil && !fd.IsList() && !fd.IsMap() && !isWrapper(fd.Message()) && actual.Has(fd):
+			normalize(actual.Mutable(fd).Message(), desired.Mutable(fd).Message())
+		}
+	}
+}
+
+// FillUpdate sets the fields of the update request reverting the changes, taking
+// the values of same-named top-level fields of desired, and returns the changes of the
+// fields the update has no field for, e.g. region_id of a cluster, or that are immutable,
+// see RegisterImmutable: the other changes of the top-level field of an immutable change
+// are not reverted either, as the update sets the field whole. Fields of the update not
+// changed are left unset, as the service keeps the current value of unset fields.
+func FillUpdate(update, desired proto.Message, changes []FieldChange) (unfixable []FieldChange) {
+	dst, src := update.ProtoReflect(), proto.Clone(desired).ProtoReflect()
+	immutable := map[protoreflect.Name]bool{}
+	for _, c := range Immutable(src.Descriptor().FullName(), changes) {
+		immutable[topLevelName(c.Path)] = true
+	}
+	for _, c := range changes {
+		name := topLevelName(c.Path)
+		sf, df := src.Descriptor().Fields().ByName(name), dst.Descriptor().Fields().ByName(name)
+		if immutable[name] || sf == nil || df == nil || !sameShape(sf, df) {
+			unfixable = append(unfixable, c)
+			continue
+		}
+		dst.Set(df, src.Get(sf))
+	}
+	return unfixable
+}
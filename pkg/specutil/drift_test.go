@@ -0,0 +1,147 @@
+package specutil
+
+import (
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func liveCluster() *clickhouse.Cluster {
+	return &clickhouse.Cluster{
+		Id:          "chc1",
+		ProjectId:   "p",
+		RegionId:    "eu-central-1",
+		Name:        "orders",
+		Description: "Orders",
+		Status:      dcv1.ClusterStatus_CLUSTER_STATUS_UPDATING,
+		CreateTime:  timestamppb.Now(),
+		Version:     "23.3",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			DiskSize:         wrapperspb.Int64(32 << 30),
+			ReplicaCount:     wrapperspb.Int64(3),
+			ShardCount:       wrapperspb.Int64(1),
+		}},
+		ConnectionInfo: &clickhouse.ConnectionInfo{Host: "rw.chc1.at.double.cloud", Password: "p"},
+		Access:         &dcv1.Access{Ipv4CidrBlocks: &dcv1.Access_CidrBlockList{Values: []*dcv1.Access_CidrBlock{{Value: "10.0.0.0/8"}}}},
+	}
+}
+
+func TestDrift_Normalization(t *testing.T) {
+	for name, tc := range map[string]struct {
+		desired proto.Message
+		want    []FieldChange
+	}{
+		"empty spec":      {desired: &clickhouse.Cluster{}},
+		"matching subset": {desired: &clickhouse.Cluster{Name: "orders", Version: "23.3"}},
+		"server-managed fields": {desired: &clickhouse.Cluster{
+			Id:             "chc2",
+			Status:         dcv1.ClusterStatus_CLUSTER_STATUS_ALIVE,
+			CreateTime:     timestamppb.Now(),
+			ConnectionInfo: &clickhouse.ConnectionInfo{Host: "other"},
+		}},
+		"scalar": {
+			desired: &clickhouse.Cluster{Name: "orders", Description: "Orders and refunds"},
+			want:    []FieldChange{{Path: "description", Old: `"Orders"`, New: `"Orders and refunds"`}},
+		},
+		"nested partial message": {
+			desired: &clickhouse.Cluster{Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+				DiskSize: wrapperspb.Int64(64 << 30),
+			}}},
+			want: []FieldChange{{Path: "resources.clickhouse.disk_size", Old: "34359738368", New: "68719476736"}},
+		},
+		"empty nested message": {desired: &clickhouse.Cluster{Resources: &clickhouse.ClusterResources{}}},
+		"wrapper compared as scalar": {
+			desired: &clickhouse.Cluster{Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+				ShardCount: wrapperspb.Int64(0),
+			}}},
+			want: []FieldChange{{Path: "resources.clickhouse.shard_count", Old: "1", New: "0"}},
+		},
+		"list compared whole": {
+			desired: &clickhouse.Cluster{Access: &dcv1.Access{Ipv4CidrBlocks: &dcv1.Access_CidrBlockList{Values: []*dcv1.Access_CidrBlock{
+				{Value: "10.0.0.0/8"}, {Value: "192.168.0.0/16"},
+			}}}},
+			want: []FieldChange{{Path: "access.ipv4_cidr_blocks.values[1]", Old: Unset, New: `{value:"192.168.0.0/16"}`}},
+		},
+		"message missing in actual": {
+			desired: &clickhouse.Cluster{MaintenanceWindow: &dcv1.MaintenanceWindow{}},
+			want:    []FieldChange{{Path: "maintenance_window", Old: Unset, New: "{}"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			live := liveCluster()
+			before := proto.Clone(live)
+			assert.Equal(t, tc.want, Drift(live, tc.desired))
+			assert.True(t, proto.Equal(before, live), "actual must not be modified")
+		})
+	}
+}
+
+func TestDrift_Maps(t *testing.T) {
+	live := &transfer.Transfer{Name: "orders", Labels: map[string]string{"team": "data", "env": "prod"}}
+	assert.Empty(t, Drift(live, &transfer.Transfer{Description: ""}))
+	assert.Equal(t, []FieldChange{
+		{Path: `labels["env"]`, Old: `"prod"`, New: Unset},
+	}, Drift(live, &transfer.Transfer{Labels: map[string]string{"team": "data"}}), "maps are compared whole")
+}
+
+func TestDrift_Kafka(t *testing.T) {
+	live := &kafka.Cluster{
+		Id:               "kfc1",
+		Name:             "events",
+		Status:           dcv1.ClusterStatus_CLUSTER_STATUS_ALIVE,
+		PlannedOperation: &dcv1.MaintenanceOperation{Info: "restart"},
+		Resources: &kafka.ClusterResources{Kafka: &kafka.ClusterResources_Kafka{
+			ResourcePresetId: "s1-c2-m4",
+			BrokerCount:      wrapperspb.Int64(3),
+		}},
+	}
+	desired := &kafka.Cluster{
+		Name: "events",
+		Resources: &kafka.ClusterResources{Kafka: &kafka.ClusterResources_Kafka{
+			ResourcePresetId: "s1-c4-m8",
+		}},
+	}
+	assert.Equal(t, []FieldChange{
+		{Path: "resources.kafka.resource_preset_id", Old: `"s1-c2-m4"`, New: `"s1-c4-m8"`},
+	}, Drift(live, desired))
+}
+
+func TestDrift_DifferentTypes(t *testing.T) {
+	assert.Equal(t, []FieldChange{{Old: "doublecloud.clickhouse.v1.Cluster", New: "doublecloud.kafka.v1.Cluster"}},
+		Drift(&clickhouse.Cluster{}, &kafka.Cluster{}))
+}
+
+func TestFillUpdate(t *testing.T) {
+	live := liveCluster()
+	desired := &clickhouse.Cluster{
+		RegionId:    "us-east-1",
+		Description: "Orders and refunds",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			DiskSize: wrapperspb.Int64(64 << 30),
+		}},
+	}
+	changes := Drift(live, desired)
+	assert.Len(t, changes, 3)
+
+	update := &clickhouse.UpdateClusterRequest{ClusterId: "chc1"}
+	unfixable := FillUpdate(update, desired, changes)
+	assert.Equal(t, []FieldChange{{Path: "region_id", Old: `"eu-central-1"`, New: `"us-east-1"`}}, unfixable)
+	assert.True(t, proto.Equal(&clickhouse.UpdateClusterRequest{
+		ClusterId:   "chc1",
+		Description: "Orders and refunds",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			DiskSize: wrapperspb.Int64(64 << 30),
+		}},
+	}, update), "got %v", update)
+
+	update.Resources.Clickhouse.DiskSize.Value = 1
+	assert.Equal(t, int64(64<<30), desired.Resources.Clickhouse.DiskSize.GetValue(), "desired must not be modified")
+}
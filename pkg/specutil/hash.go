@@ -0,0 +1,85 @@
+package specutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// excluded holds volatile fields by message type, see ExcludeFields.
+var excluded = struct {
+	sync.RWMutex
+	fields map[protoreflect.FullName]map[protoreflect.Name]bool
+}{fields: map[protoreflect.FullName]map[protoreflect.Name]bool{}}
+
+func init() {
+	// Set by the server, never by the desired spec.
+	ExcludeFields("doublecloud.clickhouse.v1.Cluster", "create_time")
+	ExcludeFields("doublecloud.kafka.v1.Cluster", "create_time")
+	ExcludeFields("doublecloud.network.v1.Network", "create_time")
+	ExcludeFields("doublecloud.network.v1.NetworkConnection", "create_time")
+}
+
+// ExcludeFields registers volatile fields of the message type ignored by Hash and Diff,
+// e.g. timestamps set by the server. The exclusion applies to messages of the type
+// at any depth.
+func ExcludeFields(message protoreflect.FullName, fields ...protoreflect.Name) {
+	excluded.Lock()
+	defer excluded.Unlock()
+	set := excluded.fields[message]
+	if set == nil {
+		set = map[protoreflect.Name]bool{}
+		excluded.fields[message] = set
+	}
+	for _, f := range fields {
+		set[f] = true
+	}
+}
+
+func isExcluded(fd protoreflect.FieldDescriptor) bool {
+	excluded.RLock()
+	defer excluded.RUnlock()
+	return excluded.fields[fd.ContainingMessage().FullName()][fd.Name()]
+}
+
+// Hash returns a stable hash of the canonicalized message: unknown and excluded fields are
+// dropped and the rest is marshaled deterministically, so map ordering doesn't matter.
+// Messages equal up to excluded fields have equal hashes.
+func Hash(msg proto.Message) (string, error) {
+	m := proto.Clone(msg)
+	canonicalize(m.ProtoReflect())
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func canonicalize(m protoreflect.Message) {
+	m.SetUnknown(nil)
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if isExcluded(fd) {
+			m.Clear(fd)
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				canonicalize(l.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				canonicalize(v.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			canonicalize(v.Message())
+		}
+		return true
+	})
+}
@@ -0,0 +1,86 @@
+package specutil
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func labeledEndpoint(keys []int) *transfer.CreateEndpointRequest {
+	req := &transfer.CreateEndpointRequest{ProjectId: "p", Name: "e", Labels: map[string]string{}}
+	for _, k := range keys {
+		req.Labels[fmt.Sprintf("key-%d", k)] = fmt.Sprintf("value-%d", k)
+	}
+	return req
+}
+
+func TestHash_MapOrdering(t *testing.T) {
+	var forward, backward []int
+	for i := 0; i < 64; i++ {
+		forward = append(forward, i)
+		backward = append([]int{i}, backward...)
+	}
+	want, err := Hash(labeledEndpoint(forward))
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		got, err := Hash(labeledEndpoint(backward))
+		require.NoError(t, err)
+		require.Equal(t, want, got, "attempt %d", i)
+	}
+
+	changed := labeledEndpoint(forward)
+	changed.Labels["key-0"] = "other"
+	got, err := Hash(changed)
+	require.NoError(t, err)
+	assert.NotEqual(t, want, got)
+}
+
+func TestHash_Golden(t *testing.T) {
+	got, err := Hash(&clickhouse.CreateClusterRequest{
+		ProjectId: "p",
+		Name:      "c",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s1-c2-m4",
+			DiskSize:         wrapperspb.Int64(32 << 30),
+		}},
+	})
+	require.NoError(t, err)
+	// The hash is stored to detect changes: it must not change between releases.
+	assert.Equal(t, "3b3e04886bbc4536593a58a91d5d632dbd97a29d0c89e48cf79b05101b9eded6", got)
+}
+
+func TestHash_UnknownFieldsStripped(t *testing.T) {
+	req := &clickhouse.CreateClusterRequest{ProjectId: "p", Name: "c"}
+	want, err := Hash(req)
+	require.NoError(t, err)
+
+	withUnknown := &clickhouse.CreateClusterRequest{ProjectId: "p", Name: "c"}
+	withUnknown.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 999, protowire.VarintType), 1))
+	got, err := Hash(withUnknown)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.NotEmpty(t, withUnknown.ProtoReflect().GetUnknown(), "the argument must not be modified")
+}
+
+func TestHash_ExcludedFields(t *testing.T) {
+	cluster := func(created time.Time, name string) *clickhouse.Cluster {
+		return &clickhouse.Cluster{Id: "chc1", Name: name, CreateTime: timestamppb.New(created)}
+	}
+	h1, err := Hash(cluster(time.Unix(1, 0), "c"))
+	require.NoError(t, err)
+	h2, err := Hash(cluster(time.Unix(2, 0), "c"))
+	require.NoError(t, err)
+	assert.Equal(t, h1, h2, "create_time is excluded")
+
+	h3, err := Hash(cluster(time.Unix(1, 0), "other"))
+	require.NoError(t, err)
+	assert.NotEqual(t, h1, h3)
+}
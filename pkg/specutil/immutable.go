@@ -0,0 +1,150 @@
+package specutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrImmutableField is matched by errors.Is for every *ImmutableFieldError.
+var ErrImmutableField = errors.New("specutil: immutable field")
+
+// ImmutableFieldError is returned by CheckUpdate for updates changing a field that can't be
+// changed after the resource is created, see RegisterImmutable.
+type ImmutableFieldError struct {
+	// Path of the field from the resource, like FieldChange.Path.
+	Path      string
+	Current   string
+	Requested string
+}
+
+func (e *ImmutableFieldError) Error() string {
+	return fmt.Sprintf("%s can't be changed after creation: current value %s, requested %s", e.Path, e.Current, e.Requested)
+}
+
+func (e *ImmutableFieldError) Is(target error) bool { return target == ErrImmutableField }
+
+// immutable holds the immutable field paths by resource type, see RegisterImmutable.
+var immutable = struct {
+	sync.RWMutex
+	paths map[protoreflect.FullName]map[string]bool
+}{paths: map[protoreflect.FullName]map[string]bool{}}
+
+func init() {
+	// Placement of the clusters, chosen at creation.
+	RegisterImmutable("doublecloud.clickhouse.v1.Cluster", "project_id", "cloud_type", "region_id", "network_id")
+	RegisterImmutable("doublecloud.kafka.v1.Cluster", "project_id", "cloud_type", "region_id", "network_id")
+}
+
+// RegisterImmutable registers field paths of the resource type that can't be changed after
+// creation, e.g. `region_id` or `resources.clickhouse.zone`, in addition to the ones
+// registered before. A path covers the fields nested in it, e.g. `labels` covers
+// `labels["env"]`. Update wrappers reject changes of them, see CheckUpdate, and drift
+// reports list them as requiring a recreate.
+func RegisterImmutable(resource protoreflect.FullName, paths ...string) {
+	immutable.Lock()
+	defer immutable.Unlock()
+	set := immutable.paths[resource]
+	if set == nil {
+		set = map[string]bool{}
+		immutable.paths[resource] = set
+	}
+	for _, p := range paths {
+		set[p] = true
+	}
+}
+
+// UnregisterImmutable removes field paths registered with RegisterImmutable, e.g. for
+// deployments where they can be updated.
+func UnregisterImmutable(resource protoreflect.FullName, paths ...string) {
+	immutable.Lock()
+	defer immutable.Unlock()
+	for _, p := range paths {
+		delete(immutable.paths[resource], p)
+	}
+}
+
+// ImmutablePaths returns the immutable field paths of the resource type, sorted.
+func ImmutablePaths(resource protoreflect.FullName) []string {
+	immutable.RLock()
+	defer immutable.RUnlock()
+	paths := make([]string, 0, len(immutable.paths[resource]))
+	for p := range immutable.paths[resource] {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Immutable returns the changes of a resource of the type, e.g. of Drift, to immutable
+// fields.
+func Immutable(resource protoreflect.FullName, changes []FieldChange) []FieldChange {
+	paths := ImmutablePaths(resource)
+	if len(paths) == 0 {
+		return nil
+	}
+	var result []FieldChange
+	for _, c := range changes {
+		for _, p := range paths {
+			if c.Path == p || strings.HasPrefix(c.Path, p+".") || strings.HasPrefix(c.Path, p+"[") {
+				result = append(result, c)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// UpdatesImmutable reports whether the update request sets a same-named top-level field of
+// an immutable path of the resource type, so that CheckUpdate needs the current resource.
+func UpdatesImmutable(resource protoreflect.FullName, update proto.Message) bool {
+	m := update.ProtoReflect()
+	for _, p := range ImmutablePaths(resource) {
+		if fd := m.Descriptor().Fields().ByName(topLevelName(p)); fd != nil && m.Has(fd) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckUpdate returns *ImmutableFieldError if the update request changes an immutable field
+// of the current resource. Like FillUpdate, the fields of the update are the same-named
+// top-level fields of the resource, and the unset ones keep the current value. The first
+// change in the order of Diff is reported.
+func CheckUpdate(current, update proto.Message) error {
+	resource := current.ProtoReflect().Descriptor().FullName()
+	if !UpdatesImmutable(resource, update) {
+		return nil
+	}
+	updated := proto.Clone(current).ProtoReflect()
+	src := update.ProtoReflect()
+	src.Range(func(sf protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if df := updated.Descriptor().Fields().ByName(sf.Name()); df != nil && sameShape(sf, df) {
+			updated.Set(df, v)
+		}
+		return true
+	})
+	if changes := Immutable(resource, Diff(current, updated.Interface())); len(changes) > 0 {
+		return &ImmutableFieldError{Path: changes[0].Path, Current: changes[0].Old, Requested: changes[0].New}
+	}
+	return nil
+}
+
+// topLevelName returns the name of the top-level field of the path.
+func topLevelName(path string) protoreflect.Name {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return protoreflect.Name(path[:i])
+	}
+	return protoreflect.Name(path)
+}
+
+// sameShape reports whether values of the field a can be set to the field b.
+func sameShape(a, b protoreflect.FieldDescriptor) bool {
+	return a.Kind() == b.Kind() && a.Cardinality() == b.Cardinality() && a.IsMap() == b.IsMap() &&
+		(a.Message() == nil || a.Message().FullName() == b.Message().FullName())
+}
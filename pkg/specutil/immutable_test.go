@@ -0,0 +1,74 @@
+package specutil
+
+import (
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const clickHouseCluster = "doublecloud.clickhouse.v1.Cluster"
+
+func registerImmutable(t *testing.T, paths ...string) {
+	RegisterImmutable(clickHouseCluster, paths...)
+	t.Cleanup(func() { UnregisterImmutable(clickHouseCluster, paths...) })
+}
+
+func TestImmutable(t *testing.T) {
+	assert.Equal(t, []string{"cloud_type", "network_id", "project_id", "region_id"}, ImmutablePaths(clickHouseCluster))
+	registerImmutable(t, "resources.clickhouse", "labels")
+	changes := []FieldChange{
+		{Path: "region_id"},
+		{Path: "description"},
+		{Path: "resources.clickhouse.disk_size"},
+		{Path: "resources.dedicated_keeper"},
+		{Path: "labels[\"env\"]"},
+		{Path: "region_idx"},
+	}
+	assert.Equal(t, []FieldChange{{Path: "region_id"}, {Path: "resources.clickhouse.disk_size"}, {Path: "labels[\"env\"]"}}, Immutable(clickHouseCluster, changes))
+	assert.Empty(t, Immutable("doublecloud.network.v1.Network", changes))
+}
+
+func TestCheckUpdate(t *testing.T) {
+	live := liveCluster()
+	update := &clickhouse.UpdateClusterRequest{ClusterId: "chc1", Version: "24.3", Resources: &clickhouse.ClusterResources{
+		Clickhouse: &clickhouse.ClusterResources_Clickhouse{ResourcePresetId: "s1-c2-m4", DiskSize: wrapperspb.Int64(64 << 30)},
+	}}
+	assert.False(t, UpdatesImmutable(clickHouseCluster, update), "no update field is immutable by default")
+	require.NoError(t, CheckUpdate(live, update))
+
+	registerImmutable(t, "version", "resources.clickhouse.resource_preset_id")
+	assert.True(t, UpdatesImmutable(clickHouseCluster, update))
+	err := CheckUpdate(live, update)
+	var immutableErr *ImmutableFieldError
+	require.ErrorAs(t, err, &immutableErr)
+	assert.ErrorIs(t, err, ErrImmutableField)
+	assert.Equal(t, &ImmutableFieldError{Path: "version", Current: `"23.3"`, Requested: `"24.3"`}, immutableErr)
+	assert.EqualError(t, err, `version can't be changed after creation: current value "23.3", requested "24.3"`)
+
+	update.Version = ""
+	assert.NoError(t, CheckUpdate(live, update), "unset fields and unchanged immutable fields are accepted")
+	update.Resources.Clickhouse.ResourcePresetId = "s2-c4-m16"
+	assert.ErrorIs(t, CheckUpdate(live, update), ErrImmutableField)
+	assert.Equal(t, "23.3", live.Version, "the current resource must not be modified")
+}
+
+func TestFillUpdate_Immutable(t *testing.T) {
+	registerImmutable(t, "resources.clickhouse.resource_preset_id")
+	desired := &clickhouse.Cluster{
+		Description: "Orders and refunds",
+		Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+			ResourcePresetId: "s2-c4-m16",
+			DiskSize:         wrapperspb.Int64(64 << 30),
+		}},
+	}
+	changes := Drift(liveCluster(), desired)
+	require.Len(t, changes, 3)
+	update := &clickhouse.UpdateClusterRequest{ClusterId: "chc1"}
+	unfixable := FillUpdate(update, desired, changes)
+	assert.Equal(t, changes[1:], unfixable, "the changes sharing the top-level field of an immutable change are not reverted")
+	assert.True(t, proto.Equal(&clickhouse.UpdateClusterRequest{ClusterId: "chc1", Description: "Orders and refunds"}, update), "got %v", update)
+}
@@ -0,0 +1,81 @@
+// Package specutil contains helpers for comparing resource specs expressed as protobuf messages.
+package specutil
+
+import (
+	"bytes"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Matches reports whether every field set in desired has the same value in actual.
+// Fields are matched by name, so desired may be a request message (e.g. UpdateClusterRequest)
+// and actual the resource it is applied to (e.g. Cluster). Nested messages are compared
+// the same way, except for well-known wrapper types, which must be equal.
+func Matches(actual, desired proto.Message) bool {
+	return matches(actual.ProtoReflect(), desired.ProtoReflect())
+}
+
+func matches(actual, desired protoreflect.Message) bool {
+	ok := true
+	desired.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		afd := actual.Descriptor().Fields().ByName(fd.Name())
+		if afd == nil || afd.Kind() != fd.Kind() || afd.Cardinality() != fd.Cardinality() || afd.IsMap() != fd.IsMap() {
+			ok = false
+			return false
+		}
+		ok = fieldMatches(fd, actual.Get(afd), v)
+		return ok
+	})
+	return ok
+}
+
+func fieldMatches(fd protoreflect.FieldDescriptor, actual, desired protoreflect.Value) bool {
+	switch {
+	case fd.IsList():
+		a, d := actual.List(), desired.List()
+		if a.Len() != d.Len() {
+			return false
+		}
+		for i := 0; i < d.Len(); i++ {
+			if !valueEqual(fd, a.Get(i), d.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case fd.IsMap():
+		a, d := actual.Map(), desired.Map()
+		if a.Len() != d.Len() {
+			return false
+		}
+		equal := true
+		d.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			equal = a.Has(k) && valueEqual(fd.MapValue(), a.Get(k), v)
+			return equal
+		})
+		return equal
+	case fd.Message() != nil && !isWrapper(fd.Message()):
+		if fd.Message().FullName() != actual.Message().Descriptor().FullName() {
+			return false
+		}
+		return matches(actual.Message(), desired.Message())
+	default:
+		return valueEqual(fd, actual, desired)
+	}
+}
+
+func valueEqual(fd protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return proto.Equal(a.Message().Interface(), b.Message().Interface())
+	case protoreflect.BytesKind:
+		return bytes.Equal(a.Bytes(), b.Bytes())
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+func isWrapper(md protoreflect.MessageDescriptor) bool {
+	return md.ParentFile().Package() == "google.protobuf" && strings.HasSuffix(string(md.Name()), "Value")
+}
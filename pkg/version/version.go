@@ -0,0 +1,187 @@
+// Package version parses the version names of DoubleCloud services and matches them
+// against semver-style constraints.
+//
+// The names are not strict semver: they have two to four numeric components, e.g. "23.8" or
+// "22.8.21.38", may start with "v" and may end with a tag such as " LTS" or "-stable".
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Version is a parsed version name.
+type Version struct {
+	// Parts are the numeric components of the version, at least one.
+	Parts []int
+	// Tag is the lowercase suffix after the numeric components, e.g. "lts", if any.
+	Tag string
+	raw string
+}
+
+// Parse parses the version name.
+func Parse(name string) (Version, error) {
+	// Product prefixes, e.g. "ClickHouse 23.8" or "v3.5", are skipped.
+	s := strings.TrimLeftFunc(name, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsSpace(r) })
+	parts, rest, err := parseParts(s, false)
+	if err != nil {
+		return Version{}, fmt.Errorf("version: parse %q: %w", name, err)
+	}
+	return Version{Parts: parts, Tag: parseTag(rest), raw: name}, nil
+}
+
+// String returns the version name as parsed.
+func (v Version) String() string { return v.raw }
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than w.
+// Missing trailing components are zero and tags are ignored: "23.8 LTS" equals "23.8.0".
+func (v Version) Compare(w Version) int {
+	return compareParts(v.Parts, w.Parts)
+}
+
+// parseParts parses the dot separated numeric components at the start of s, returning
+// the rest. With wildcard, the last component may be "x", "X" or "*", which ends the parts.
+func parseParts(s string, wildcard bool) (parts []int, rest string, err error) {
+	for {
+		if wildcard && s != "" && strings.ContainsRune("xX*", rune(s[0])) {
+			return parts, s[1:], nil
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return nil, "", fmt.Errorf("expected a number at %q", s)
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return nil, "", err
+		}
+		parts = append(parts, n)
+		s = s[i:]
+		if len(s) < 2 || s[0] != '.' {
+			return parts, s, nil
+		}
+		if !wildcard && (s[1] < '0' || s[1] > '9') {
+			return parts, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+// parseTag normalizes the suffix of a version name: " LTS", "-lts" and "(lts)" are all "lts".
+func parseTag(s string) string {
+	return strings.ToLower(strings.Trim(s, " -_.+()[]"))
+}
+
+func compareParts(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Constraint is a set of conditions on versions, see ParseConstraint.
+type Constraint struct {
+	terms []term
+	raw   string
+}
+
+type term struct {
+	op     string
+	parts  []int
+	prefix bool
+}
+
+// ParseConstraint parses comma-separated conditions that versions must all satisfy.
+// A condition is a version with an optional comparison: "=", "!=", ">", ">=", "<" or "<=".
+// Without an operator a version matches as a prefix and may end with a wildcard: "24",
+// "24.x" and "24.*" all match "24.3" and "24.8 LTS". An empty constraint, "*" or "x" matches
+// every version. Tags are ignored.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	if strings.TrimSpace(s) == "" {
+		return c, nil
+	}
+	for _, cond := range strings.Split(s, ",") {
+		t, err := parseTerm(strings.TrimSpace(cond))
+		if err != nil {
+			return Constraint{}, fmt.Errorf("version: parse constraint %q: %w", s, err)
+		}
+		c.terms = append(c.terms, t)
+	}
+	return c, nil
+}
+
+func parseTerm(cond string) (term, error) {
+	var t term
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(cond, op) {
+			t.op = op
+			cond = strings.TrimSpace(cond[len(op):])
+			break
+		}
+	}
+	t.prefix = t.op == ""
+	cond = strings.TrimPrefix(strings.TrimPrefix(cond, "v"), "V")
+	parts, rest, err := parseParts(cond, t.prefix)
+	if err != nil {
+		return term{}, err
+	}
+	if rest != "" {
+		return term{}, fmt.Errorf("unexpected %q", rest)
+	}
+	t.parts = parts
+	return t, nil
+}
+
+// Match reports whether v satisfies every condition of the constraint.
+func (c Constraint) Match(v Version) bool {
+	for _, t := range c.terms {
+		if !t.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t term) match(v Version) bool {
+	if t.prefix {
+		if len(v.Parts) < len(t.parts) {
+			return compareParts(v.Parts, t.parts) == 0
+		}
+		return compareParts(v.Parts[:len(t.parts)], t.parts) == 0
+	}
+	cmp := compareParts(v.Parts, t.parts)
+	switch t.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+// String returns the constraint as parsed.
+func (c Constraint) String() string { return c.raw }
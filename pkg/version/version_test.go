@@ -0,0 +1,98 @@
+package version
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_ObservedNames(t *testing.T) {
+	f, err := os.Open("testdata/names.txt")
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		name := fields[0]
+		t.Run(name, func(t *testing.T) {
+			v, err := Parse(name)
+			if fields[1] == "error" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var parts []int
+			for _, p := range strings.Split(fields[1], ".") {
+				n, err := strconv.Atoi(p)
+				require.NoError(t, err)
+				parts = append(parts, n)
+			}
+			assert.Equal(t, parts, v.Parts)
+			assert.Equal(t, fields[2], v.Tag)
+			assert.Equal(t, name, v.String())
+		})
+	}
+	require.NoError(t, scanner.Err())
+}
+
+func TestCompare(t *testing.T) {
+	parse := func(name string) Version {
+		v, err := Parse(name)
+		require.NoError(t, err)
+		return v
+	}
+	assert.Equal(t, 0, parse("23.8 LTS").Compare(parse("23.8.0")))
+	assert.Equal(t, -1, parse("23.8").Compare(parse("23.12")))
+	assert.Equal(t, 1, parse("24.1").Compare(parse("23.12.4")))
+	assert.Equal(t, -1, parse("3.5").Compare(parse("3.5.1")))
+}
+
+func TestConstraint(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		matches    []string
+		misses     []string
+	}{
+		{"24.x", []string{"24.1", "24.3 LTS", "24.8.2.3"}, []string{"23.8", "2.4"}},
+		{"24.*", []string{"24.3"}, []string{"25.1"}},
+		{"24", []string{"24.3"}, []string{"23.8"}},
+		{"23.8.x", []string{"23.8", "23.8.4"}, []string{"23.3", "24.8"}},
+		{"", []string{"3.4", "23.8"}, nil},
+		{"*", []string{"3.4"}, nil},
+		{">=23.3, <24", []string{"23.3", "23.8 LTS"}, []string{"22.8", "24.1"}},
+		{"!=3.4", []string{"3.5"}, []string{"3.4.0"}},
+		{"=3.5", []string{"3.5"}, []string{"3.5.1"}},
+		{"> v3.5", []string{"3.5.1"}, []string{"3.5"}},
+	} {
+		c, err := ParseConstraint(tc.constraint)
+		require.NoError(t, err, tc.constraint)
+		for _, name := range tc.matches {
+			v, err := Parse(name)
+			require.NoError(t, err)
+			assert.True(t, c.Match(v), "%q matches %q", tc.constraint, name)
+		}
+		for _, name := range tc.misses {
+			v, err := Parse(name)
+			require.NoError(t, err)
+			assert.False(t, c.Match(v), "%q doesn't match %q", tc.constraint, name)
+		}
+	}
+
+	for _, bad := range []string{"24.x.1", ">=24.x", "latest", "24.", "24 lts", ">=23,"} {
+		_, err := ParseConstraint(bad)
+		assert.Error(t, err, bad)
+	}
+}
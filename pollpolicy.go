@@ -0,0 +1,16 @@
+package dcsdk
+
+import "github.com/doublecloud/go-sdk/operation"
+
+// PollPolicy is a polling policy shared by the pollers of the SDK, see Config.PollPolicy and
+// operation.PollPolicy.
+type PollPolicy = operation.PollPolicy
+
+// PollBudgets bound the retries of the failed polls of a PollPolicy.
+type PollBudgets = operation.PollBudgets
+
+// PollPolicy returns the current Config.PollPolicy of the SDK, e.g. for
+// paging.WithRetryPolicy.
+func (sdk *SDK) PollPolicy() PollPolicy {
+	return sdk.config().PollPolicy
+}
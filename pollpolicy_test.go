@@ -0,0 +1,182 @@
+package dcsdk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/paging"
+)
+
+// sleepRecorder is an operation.Clock whose timers fire right away, recording their
+// durations.
+type sleepRecorder struct {
+	slept []time.Duration
+}
+
+func (c *sleepRecorder) Now() time.Time { return time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+func (c *sleepRecorder) NewTimer(d time.Duration) operation.Timer {
+	c.slept = append(c.slept, d)
+	fired := make(chan time.Time, 1)
+	fired <- c.Now()
+	return firedTimer{fired}
+}
+
+type firedTimer struct{ c chan time.Time }
+
+func (t firedTimer) C() <-chan time.Time { return t.c }
+func (t firedTimer) Stop() bool          { return false }
+
+// runningPolls returns a poll func of an operation done at the n-th poll.
+func runningPolls(n int) operation.PollFunc {
+	var polls int
+	return func(ctx context.Context, id string) (*dcv1.Operation, time.Duration, error) {
+		polls++
+		st := dcv1.Operation_STATUS_RUNNING
+		if polls >= n {
+			st = dcv1.Operation_STATUS_DONE
+		}
+		return &dcv1.Operation{Id: id, Status: st}, 0, nil
+	}
+}
+
+func newPollPolicySDK(t *testing.T, conf Config) *SDK {
+	feed := httptest.NewServer(&fakeStatusFeed{})
+	t.Cleanup(feed.Close)
+	conf.Credentials = NewIAMTokenCredentials("test-token")
+	conf.StatusFeedURL = feed.URL
+	return newTestSDKWithConfig(t, conf, func(s *grpc.Server) {})
+}
+
+func TestPollPolicy_Consumers(t *testing.T) {
+	clock := &sleepRecorder{}
+	policy := PollPolicy{Interval: 3 * time.Second, Backoff: 2, Budgets: PollBudgets{Retries: 2}, Clock: clock}
+	sdk := newPollPolicySDK(t, Config{PollPolicy: policy})
+	ctx := context.Background()
+
+	// Operation waits.
+	pending := func() *operation.Operation {
+		op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+		require.NoError(t, err)
+		return op
+	}
+	require.NoError(t, pending().Wait(ctx, operation.WithPollFunc(runningPolls(3))))
+	assert.Equal(t, []time.Duration{3 * time.Second, 6 * time.Second}, clock.slept, "the policy of the SDK")
+	clock.slept = nil
+	require.NoError(t, pending().Wait(ctx, operation.WithBackoff(operation.BackoffPolicy{InitialInterval: time.Second}), operation.WithPollFunc(runningPolls(3))))
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, clock.slept, "the options of the wait take precedence")
+
+	// Page retries.
+	clock.slept = nil
+	var pages int
+	_, err := paging.New(ctx, func(ctx context.Context, p *dcv1.Paging) ([]int, *dcv1.NextPage, error) {
+		pages++
+		return nil, nil, status.Error(codes.DeadlineExceeded, "slow page")
+	}, paging.WithAdaptivePageSize(1, 1000), paging.WithRetryPolicy(sdk.PollPolicy())).TakeAll()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, 3, pages, "the retries are bounded by the budget of the policy")
+	assert.Equal(t, []time.Duration{3 * time.Second, 6 * time.Second}, clock.slept)
+
+	// Status feed.
+	assert.Equal(t, 3*time.Second, sdk.statusFeed.interval)
+	explicit := newPollPolicySDK(t, Config{PollPolicy: policy, StatusFeedInterval: time.Minute})
+	assert.Equal(t, time.Minute, explicit.statusFeed.interval, "StatusFeedInterval takes precedence")
+	defaults := newPollPolicySDK(t, Config{})
+	assert.Equal(t, DefaultStatusFeedInterval, defaults.statusFeed.interval)
+	op, err := defaults.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	assert.Zero(t, op.PollPolicy(), "the defaults without a policy")
+}
+
+func TestPollPolicy_UpdateConfig(t *testing.T) {
+	sdk := newPollPolicySDK(t, Config{PollPolicy: PollPolicy{Interval: 3 * time.Second}})
+	before, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1"}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) { c.PollPolicy.Interval = 5 * time.Second }))
+	after, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho2"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, sdk.PollPolicy().Interval)
+	assert.Equal(t, 5*time.Second, after.PollPolicy().Interval, "the operations wrapped after the update")
+	assert.Equal(t, 3*time.Second, before.PollPolicy().Interval)
+}
+
+func TestConfig_WaitJournal(t *testing.T) {
+	var journal bytes.Buffer
+	sdk := newPollPolicySDK(t, Config{WaitJournal: &journal, PollPolicy: PollPolicy{Clock: &sleepRecorder{}}})
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(context.Background(), operation.WithPollFunc(runningPolls(2))))
+	assert.Equal(t, 2, strings.Count(journal.String(), `"operation_id":"cho1"`), "a line per poll")
+
+	err = sdk.UpdateConfig(func(c *MutableConfig) { c.WaitJournal = &bytes.Buffer{} })
+	assert.ErrorIs(t, err, ErrImmutableConfig)
+}
+
+func TestConfig_WaitTelemetrySampling(t *testing.T) {
+	var journal bytes.Buffer
+	sdk := newPollPolicySDK(t, Config{
+		WaitJournal:           &journal,
+		WaitTelemetrySampling: &operation.TelemetrySampling{AlwaysOnFailure: true},
+		PollPolicy:            PollPolicy{Clock: &sleepRecorder{}},
+	})
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(context.Background(), operation.WithPollFunc(runningPolls(2))))
+	assert.Empty(t, journal.String(), "the waits succeeding are not sampled")
+
+	op, err = sdk.WrapOperation(&dcv1.Operation{Id: "cho2", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	err = op.Wait(context.Background(), operation.WithPollFunc(func(ctx context.Context, id string) (*dcv1.Operation, time.Duration, error) {
+		return nil, 0, status.Error(codes.InvalidArgument, "bad request")
+	}))
+	require.Error(t, err)
+	assert.Equal(t, 1, strings.Count(journal.String(), `"operation_id":"cho2"`), "the failed waits are recorded")
+
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) { c.WaitTelemetrySampling = nil }))
+	op, err = sdk.WrapOperation(&dcv1.Operation{Id: "cho3", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(context.Background(), operation.WithPollFunc(runningPolls(2))))
+	assert.Equal(t, 2, strings.Count(journal.String(), `"operation_id":"cho3"`), "all the waits are sampled")
+}
+
+// messageLogger records the messages of its lines with their first field.
+type messageLogger struct {
+	lines []string
+}
+
+func (l *messageLogger) Log(level operation.LogLevel, msg string, kv ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf("%s %v=%v", msg, kv[0], kv[1]))
+}
+
+func TestConfig_Logger(t *testing.T) {
+	logger := &messageLogger{}
+	sdk := newPollPolicySDK(t, Config{Logger: logger, PollPolicy: PollPolicy{Clock: &sleepRecorder{}}})
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(context.Background(), operation.WithPollFunc(runningPolls(1))))
+	assert.Equal(t, []string{
+		"operation wait started operation_id=cho1",
+		"operation polled operation_id=cho1",
+		"operation wait finished operation_id=cho1",
+	}, logger.lines)
+
+	require.NoError(t, sdk.UpdateConfig(func(c *MutableConfig) { c.Logger = nil }))
+	op, err = sdk.WrapOperation(&dcv1.Operation{Id: "cho2", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(context.Background(), operation.WithPollFunc(runningPolls(1))))
+	assert.Len(t, logger.lines, 3, "the logger is removed")
+}
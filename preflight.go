@@ -0,0 +1,74 @@
+package dcsdk
+
+import (
+	"context"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/gen/network"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// WithPreflight makes ClickHouse and Kafka cluster Create calls check the target network
+// with Network().Preflight first and fail fast with *network.PreflightError on findings.
+// Requests without a network ID use the default network and are not checked.
+func WithPreflight(enabled bool) grpc.CallOption {
+	return &withPreflight{enabled: enabled}
+}
+
+type withPreflight struct {
+	grpc.EmptyCallOption
+	enabled bool
+}
+
+func preflightEnabled(opts []grpc.CallOption) bool {
+	enabled := false
+	for _, o := range opts {
+		if o, ok := o.(*withPreflight); ok {
+			enabled = o.enabled
+		}
+	}
+	return enabled
+}
+
+// preflightRequirements extracts the network a create request places the cluster into
+// and what the cluster needs from it. The Kafka broker count is per availability zone.
+func preflightRequirements(req interface{}) (string, network.Requirements, bool) {
+	switch req := req.(type) {
+	case *clickhouse.CreateClusterRequest:
+		res := req.GetResources().GetClickhouse()
+		hosts := res.GetReplicaCount().GetValue() * max64(res.GetShardCount().GetValue(), 1)
+		return req.GetNetworkId(), network.Requirements{NeededIPs: int(hosts), Region: req.GetRegionId()}, true
+	case *kafka.CreateClusterRequest:
+		res := req.GetResources().GetKafka()
+		hosts := res.GetBrokerCount().GetValue() * max64(res.GetZoneCount().GetValue(), 1)
+		return req.GetNetworkId(), network.Requirements{NeededIPs: int(hosts), Region: req.GetRegionId()}, true
+	}
+	return "", network.Requirements{}, false
+}
+
+func (sdk *SDK) interceptPreflight(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !preflightEnabled(opts) {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+	networkID, requirements, ok := preflightRequirements(req)
+	if ok && networkID != "" {
+		report, err := sdk.Network().Preflight(ctx, networkID, requirements)
+		if err != nil {
+			return sdkerrors.WithMessage(err, "network preflight")
+		}
+		if err := report.Err(); err != nil {
+			return err
+		}
+	}
+	return invoker(ctx, method, req, reply, conn, opts...)
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,113 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkpb "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/doublecloud/go-sdk/gen/network"
+)
+
+type countingClickHouseClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	creates int32
+}
+
+func (f *countingClickHouseClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	atomic.AddInt32(&f.creates, 1)
+	return &dcv1.Operation{Id: "cho1"}, nil
+}
+
+type countingKafkaClusters struct {
+	kafka.UnimplementedClusterServiceServer
+	creates int32
+}
+
+func (f *countingKafkaClusters) Create(ctx context.Context, req *kafka.CreateClusterRequest) (*dcv1.Operation, error) {
+	atomic.AddInt32(&f.creates, 1)
+	return &dcv1.Operation{Id: "kfo1"}, nil
+}
+
+type fakePreflightNetworks struct {
+	networkpb.UnimplementedNetworkServiceServer
+}
+
+func (fakePreflightNetworks) Get(ctx context.Context, req *networkpb.GetNetworkRequest) (*networkpb.Network, error) {
+	if req.NetworkId != "net" {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &networkpb.Network{Id: "net", RegionId: "eu-central-1", Ipv4CidrBlock: "10.0.0.0/30", Status: networkpb.Network_NETWORK_STATUS_ACTIVE}, nil
+}
+
+func TestWithPreflight(t *testing.T) {
+	clusters := &countingClickHouseClusters{}
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, clusters)
+		networkpb.RegisterNetworkServiceServer(s, fakePreflightNetworks{})
+	})
+	ctx := context.Background()
+	create := func(networkID string, replicas int64, opts ...grpc.CallOption) error {
+		_, err := sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{
+			NetworkId: networkID,
+			RegionId:  "eu-central-1",
+			Resources: &clickhouse.ClusterResources{Clickhouse: &clickhouse.ClusterResources_Clickhouse{
+				ReplicaCount: wrapperspb.Int64(replicas),
+			}},
+		}, opts...)
+		return err
+	}
+
+	err := create("missing", 1, WithPreflight(true))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, network.ErrPreflightFailed))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	err = create("net", 8, WithPreflight(true))
+	var perr *network.PreflightError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, network.InsufficientAddresses, perr.Report.Findings[0].Kind)
+	assert.Zero(t, atomic.LoadInt32(&clusters.creates))
+
+	require.NoError(t, create("net", 2, WithPreflight(true)))
+	require.NoError(t, create("missing", 1))
+	require.NoError(t, create("missing", 1, WithPreflight(true), WithPreflight(false)))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&clusters.creates))
+}
+
+func TestWithPreflight_KafkaZones(t *testing.T) {
+	clusters := &countingKafkaClusters{}
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		kafka.RegisterClusterServiceServer(s, clusters)
+		networkpb.RegisterNetworkServiceServer(s, fakePreflightNetworks{})
+	})
+	create := func(resources *kafka.ClusterResources_Kafka) error {
+		_, err := sdk.Kafka().Cluster().Create(context.Background(), &kafka.CreateClusterRequest{
+			NetworkId: "net",
+			RegionId:  "eu-central-1",
+			Resources: &kafka.ClusterResources{Kafka: resources},
+		}, WithPreflight(true))
+		return err
+	}
+
+	err := create(&kafka.ClusterResources_Kafka{BrokerCount: wrapperspb.Int64(2), ZoneCount: wrapperspb.Int64(3)})
+	var perr *network.PreflightError
+	require.True(t, errors.As(err, &perr), "2 brokers in each of 3 zones need 6 addresses")
+	assert.Equal(t, network.InsufficientAddresses, perr.Report.Findings[0].Kind)
+	assert.Zero(t, atomic.LoadInt32(&clusters.creates))
+
+	require.NoError(t, create(&kafka.ClusterResources_Kafka{BrokerCount: wrapperspb.Int64(2)}))
+	require.NoError(t, create(&kafka.ClusterResources_Kafka{BrokerCount: wrapperspb.Int64(1), ZoneCount: wrapperspb.Int64(3)}))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&clusters.creates))
+}
@@ -0,0 +1,162 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const (
+	// DefaultPrincipalTTL is how long resolved principals are cached unless
+	// PrincipalConfig.TTL is set.
+	DefaultPrincipalTTL = 10 * time.Minute
+	// DefaultPrincipalMaxEntries bounds the principal cache unless PrincipalConfig.MaxEntries
+	// is set.
+	DefaultPrincipalMaxEntries = 4096
+)
+
+// Principal is the user or the service account behind a principal ID, see
+// operation.Principal.
+type Principal = operation.Principal
+
+// ErrNoPrincipalLookup is returned by ResolvePrincipals without PrincipalConfig.Lookup.
+var ErrNoPrincipalLookup = errors.New("no principal lookup configured")
+
+// PrincipalLookup looks principals up by ID in a single call, e.g. in an IAM or a user
+// directory: the DoubleCloud API has no principal lookup. IDs missing from the result are
+// unknown, e.g. deleted.
+type PrincipalLookup interface {
+	LookupPrincipals(ctx context.Context, ids []string) (map[string]Principal, error)
+}
+
+// PrincipalLookupFunc is a PrincipalLookup function.
+type PrincipalLookupFunc func(ctx context.Context, ids []string) (map[string]Principal, error)
+
+func (f PrincipalLookupFunc) LookupPrincipals(ctx context.Context, ids []string) (map[string]Principal, error) {
+	return f(ctx, ids)
+}
+
+// PrincipalConfig configures SDK.ResolvePrincipals.
+type PrincipalConfig struct {
+	// Lookup looks up the principals not cached.
+	Lookup PrincipalLookup
+	// TTL is how long the principals, tombstones included, are cached. Defaults to
+	// DefaultPrincipalTTL; a negative TTL disables the cache.
+	TTL time.Duration
+	// MaxEntries bounds the number of cached principals, the ones expiring first are
+	// evicted. Defaults to DefaultPrincipalMaxEntries.
+	MaxEntries int
+}
+
+type principalEntry struct {
+	principal Principal
+	expires   time.Time
+}
+
+// principalCache caches the principals of ResolvePrincipals by ID.
+type principalCache struct {
+	conf PrincipalConfig
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]principalEntry
+}
+
+func newPrincipalCache(conf PrincipalConfig) *principalCache {
+	if conf.TTL == 0 {
+		conf.TTL = DefaultPrincipalTTL
+	}
+	if conf.MaxEntries <= 0 {
+		conf.MaxEntries = DefaultPrincipalMaxEntries
+	}
+	return &principalCache{conf: conf, now: now, entries: map[string]principalEntry{}}
+}
+
+// get returns the cached principals of ids and the IDs missing, deduplicated.
+func (c *principalCache) get(ids []string) (map[string]Principal, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	found := make(map[string]Principal, len(ids))
+	var missing []string
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if e, ok := c.entries[id]; ok && now.Before(e.expires) {
+			found[id] = e.principal
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing
+}
+
+func (c *principalCache) put(principals map[string]Principal) {
+	if c.conf.TTL < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for id, p := range principals {
+		c.entries[id] = principalEntry{principal: p, expires: now.Add(c.conf.TTL)}
+	}
+	if len(c.entries) <= c.conf.MaxEntries {
+		return
+	}
+	ids := make([]string, 0, len(c.entries))
+	for id, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) <= c.conf.MaxEntries {
+		return
+	}
+	sort.Slice(ids, func(i, j int) bool { return c.entries[ids[i]].expires.Before(c.entries[ids[j]].expires) })
+	for _, id := range ids[:len(ids)-c.conf.MaxEntries] {
+		delete(c.entries, id)
+	}
+}
+
+// ResolvePrincipals returns the principals of the IDs, e.g. of operation.CreatedBy, from
+// the cache or else with a single call of PrincipalConfig.Lookup for all the IDs missing.
+// Every ID is in the result: the ones unknown to the lookup are tombstones, see
+// operation.NewPrincipalTombstone. It returns ErrNoPrincipalLookup without a lookup.
+func (sdk *SDK) ResolvePrincipals(ctx context.Context, ids []string) (map[string]Principal, error) {
+	principals, missing := sdk.principals.get(ids)
+	if len(missing) == 0 {
+		return principals, nil
+	}
+	if sdk.principals.conf.Lookup == nil {
+		return nil, ErrNoPrincipalLookup
+	}
+	found, err := sdk.principals.conf.Lookup.LookupPrincipals(ctx, missing)
+	if err != nil {
+		return nil, sdkerrors.WithMessagef(err, "look up %d principals", len(missing))
+	}
+	resolved := make(map[string]Principal, len(missing))
+	for _, id := range missing {
+		p, ok := found[id]
+		if !ok {
+			p = operation.NewPrincipalTombstone(id)
+		}
+		p.ID = id
+		resolved[id] = p
+		principals[id] = p
+	}
+	sdk.principals.put(resolved)
+	return principals, nil
+}
+
+var _ operation.PrincipalResolver = (*SDK)(nil)
@@ -0,0 +1,124 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// directory is a principal lookup recording its calls.
+type directory struct {
+	principals map[string]Principal
+	calls      [][]string
+	err        error
+}
+
+func (d *directory) LookupPrincipals(ctx context.Context, ids []string) (map[string]Principal, error) {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	d.calls = append(d.calls, sorted)
+	if d.err != nil {
+		return nil, d.err
+	}
+	found := map[string]Principal{}
+	for _, id := range ids {
+		if p, ok := d.principals[id]; ok {
+			found[id] = p
+		}
+	}
+	return found, nil
+}
+
+func newPrincipalSDK(t *testing.T, conf PrincipalConfig) (*SDK, *time.Time) {
+	sdk := newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token"), Principals: conf}, func(s *grpc.Server) {})
+	clock := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	sdk.principals.now = func() time.Time { return clock }
+	return sdk, &clock
+}
+
+func TestResolvePrincipals(t *testing.T) {
+	dir := &directory{principals: map[string]Principal{
+		"usr1": {Name: "alice", Kind: "user"},
+		"sa1":  {Name: "deployer", Kind: "service_account"},
+	}}
+	sdk, clock := newPrincipalSDK(t, PrincipalConfig{Lookup: dir, TTL: time.Minute})
+	ctx := context.Background()
+
+	got, err := sdk.ResolvePrincipals(ctx, []string{"usr1", "sa1", "usr1", "gone1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Principal{
+		"usr1":  {ID: "usr1", Name: "alice", Kind: "user"},
+		"sa1":   {ID: "sa1", Name: "deployer", Kind: "service_account"},
+		"gone1": {ID: "gone1", Deleted: true},
+	}, got)
+	assert.Equal(t, [][]string{{"gone1", "sa1", "usr1"}}, dir.calls, "a single lookup of the distinct IDs")
+	assert.Equal(t, "deleted principal gone1", got["gone1"].DisplayName())
+
+	got, err = sdk.ResolvePrincipals(ctx, []string{"usr1", "gone1", "usr2"})
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+	assert.Equal(t, []string{"usr2"}, dir.calls[1], "the cached principals and tombstones are not looked up")
+	assert.True(t, got["usr2"].Deleted)
+
+	*clock = clock.Add(time.Minute)
+	_, err = sdk.ResolvePrincipals(ctx, []string{"usr1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"usr1"}, dir.calls[2], "expired principals are looked up again")
+	assert.Len(t, dir.calls, 3)
+}
+
+func TestResolvePrincipals_Errors(t *testing.T) {
+	sdk, _ := newPrincipalSDK(t, PrincipalConfig{})
+	_, err := sdk.ResolvePrincipals(context.Background(), []string{"usr1"})
+	assert.ErrorIs(t, err, ErrNoPrincipalLookup)
+	got, err := sdk.ResolvePrincipals(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	dir := &directory{err: errors.New("directory down")}
+	sdk, _ = newPrincipalSDK(t, PrincipalConfig{Lookup: dir, TTL: -1})
+	_, err = sdk.ResolvePrincipals(context.Background(), []string{"usr1"})
+	assert.ErrorIs(t, err, dir.err)
+
+	dir.err = nil
+	for i := 0; i < 2; i++ {
+		_, err = sdk.ResolvePrincipals(context.Background(), []string{"usr1"})
+		require.NoError(t, err)
+	}
+	assert.Len(t, dir.calls, 3, "nothing is cached with a negative TTL")
+}
+
+func TestResolvePrincipals_MaxEntries(t *testing.T) {
+	dir := &directory{}
+	sdk, clock := newPrincipalSDK(t, PrincipalConfig{Lookup: dir, MaxEntries: 2})
+	for _, id := range []string{"usr1", "usr2", "usr3"} {
+		*clock = clock.Add(time.Second)
+		_, err := sdk.ResolvePrincipals(context.Background(), []string{id})
+		require.NoError(t, err)
+	}
+	assert.Len(t, sdk.principals.entries, 2)
+	assert.NotContains(t, sdk.principals.entries, "usr1", "the principal expiring first is evicted")
+}
+
+func TestCreatedByResolved(t *testing.T) {
+	dir := &directory{principals: map[string]Principal{"usr1": {Name: "alice"}}}
+	sdk, _ := newPrincipalSDK(t, PrincipalConfig{Lookup: dir})
+	op := operation.New(nil, &dcv1.Operation{Id: "cho1", CreatedBy: "usr1"})
+	p, err := op.CreatedByResolved(context.Background(), sdk)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.DisplayName())
+
+	p, err = operation.New(nil, &dcv1.Operation{Id: "cho2"}).CreatedByResolved(context.Background(), sdk)
+	require.NoError(t, err)
+	assert.Equal(t, Principal{}, p, "no creator")
+	assert.Len(t, dir.calls, 1)
+}
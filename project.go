@@ -0,0 +1,143 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/doublecloud/go-sdk/gen/clickhouse"
+	"github.com/doublecloud/go-sdk/gen/kafka"
+	"github.com/doublecloud/go-sdk/gen/network"
+	"github.com/doublecloud/go-sdk/gen/transfer"
+	"github.com/doublecloud/go-sdk/gen/visualization"
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// projectIDField is the request field scoped by ProjectSDK.
+const projectIDField protoreflect.Name = "project_id"
+
+// ErrProjectMismatch is matched by errors.Is for every *ProjectMismatchError.
+var ErrProjectMismatch = errors.New("project mismatch")
+
+// ProjectMismatchError is returned by ProjectSDK calls with a request for another project.
+type ProjectMismatchError struct {
+	Method  string
+	Project string
+	// Requested is the project set in the request.
+	Requested string
+}
+
+func (e *ProjectMismatchError) Error() string {
+	return fmt.Sprintf("%s: request for project %q in SDK scoped to project %q", e.Method, e.Requested, e.Project)
+}
+
+func (e *ProjectMismatchError) Is(target error) bool { return target == ErrProjectMismatch }
+
+func (e *ProjectMismatchError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+// ProjectSDK is a view of SDK bound to a single project.
+// Requests with an empty project_id get the project filled, requests for
+// another project fail with *ProjectMismatchError without being sent.
+// Iterators are scoped the same way, as they send List requests.
+// The view shares connections and credentials with the SDK it was created from.
+type ProjectSDK struct {
+	sdk       *SDK
+	projectID string
+}
+
+// ForProject returns a view of the SDK bound to the project.
+func (sdk *SDK) ForProject(projectID string) *ProjectSDK {
+	return &ProjectSDK{sdk: sdk, projectID: projectID}
+}
+
+// ProjectID returns the project the view is bound to.
+func (p *ProjectSDK) ProjectID() string {
+	return p.projectID
+}
+
+// SDK returns the SDK the view was created from.
+func (p *ProjectSDK) SDK() *SDK {
+	return p.sdk
+}
+
+// WrapOperation wraps operation proto message, see SDK.WrapOperation.
+func (p *ProjectSDK) WrapOperation(o *dcv1.Operation, err error) (*operation.Operation, error) {
+	return p.sdk.WrapOperation(o, err)
+}
+
+func (p *ProjectSDK) Kafka() *kafka.Kafka {
+	return kafka.NewKafkaWithConnInterface(p.getConn(KafkaServiceID))
+}
+
+func (p *ProjectSDK) Network() *network.Network {
+	return network.NewNetworkWithConnInterface(p.getConn(VpcServiceID))
+}
+
+func (p *ProjectSDK) ClickHouse() *clickhouse.ClickHouse {
+	return clickhouse.NewClickHouseWithConnInterface(p.getConn(ClickHouseServiceID))
+}
+
+func (p *ProjectSDK) Transfer() *transfer.Transfer {
+	return transfer.NewTransferWithConnInterface(p.getConn(TransferServiceID))
+}
+
+func (p *ProjectSDK) Visualization() *visualization.Visualization {
+	return visualization.NewVisualizationWithConnInterface(p.getConn(VisualizationServiceID))
+}
+
+func (p *ProjectSDK) getConn(serviceID Endpoint) func(ctx context.Context) (grpc.ClientConnInterface, error) {
+	getConn := p.sdk.getConn(serviceID)
+	return func(ctx context.Context) (grpc.ClientConnInterface, error) {
+		conn, err := getConn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &projectConn{ClientConn: conn, projectID: p.projectID}, nil
+	}
+}
+
+// projectConn scopes unary calls of the shared connection to the project.
+type projectConn struct {
+	*grpc.ClientConn
+	projectID string
+}
+
+func (c *projectConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	scoped, err := scopeToProject(method, args, c.projectID)
+	if err != nil {
+		return err
+	}
+	return c.ClientConn.Invoke(ctx, method, scoped, reply, opts...)
+}
+
+// scopeToProject returns the request with project_id filled in, if the request has the field.
+// The caller's request is not modified.
+func scopeToProject(method string, req interface{}, projectID string) (interface{}, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return req, nil
+	}
+	fd := msg.ProtoReflect().Descriptor().Fields().ByName(projectIDField)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.Cardinality() == protoreflect.Repeated {
+		return req, nil
+	}
+	switch requested := msg.ProtoReflect().Get(fd).String(); requested {
+	case projectID:
+		return req, nil
+	case "":
+		scoped := proto.Clone(msg)
+		scoped.ProtoReflect().Set(fd, protoreflect.ValueOfString(projectID))
+		return scoped, nil
+	default:
+		return nil, &ProjectMismatchError{Method: method, Project: projectID, Requested: requested}
+	}
+}
@@ -0,0 +1,121 @@
+package dcsdk
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// projectRecordingClusters records project IDs of the requests it receives.
+type projectRecordingClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	mu       sync.Mutex
+	projects []string
+}
+
+func (s *projectRecordingClusters) record(projectID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.projects = append(s.projects, projectID)
+}
+
+func (s *projectRecordingClusters) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.projects...)
+}
+
+func (s *projectRecordingClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	s.record(req.ProjectId)
+	return &dcv1.Operation{Id: "cho1", ProjectId: req.ProjectId, Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (s *projectRecordingClusters) List(ctx context.Context, req *clickhouse.ListClustersRequest) (*clickhouse.ListClustersResponse, error) {
+	s.record(req.ProjectId)
+	return &clickhouse.ListClustersResponse{Clusters: []*clickhouse.Cluster{{Id: "chc1", ProjectId: req.ProjectId}}}, nil
+}
+
+func (s *projectRecordingClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return &clickhouse.Cluster{Id: req.ClusterId}, nil
+}
+
+func newProjectTestSDK(t *testing.T) (*SDK, *projectRecordingClusters) {
+	clusters := &projectRecordingClusters{}
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, clusters)
+	})
+	return sdk, clusters
+}
+
+func TestForProject_Fill(t *testing.T) {
+	sdk, clusters := newProjectTestSDK(t)
+	ctx := context.Background()
+	p := sdk.ForProject("p1")
+	assert.Equal(t, "p1", p.ProjectID())
+
+	req := &clickhouse.CreateClusterRequest{Name: "c"}
+	op, err := p.WrapOperation(p.ClickHouse().Cluster().Create(ctx, req))
+	require.NoError(t, err)
+	assert.Equal(t, "p1", op.Proto().GetProjectId())
+	assert.Empty(t, req.ProjectId, "the caller's request must not be modified")
+
+	_, err = p.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{ProjectId: "p1", Name: "c"})
+	require.NoError(t, err)
+
+	clustersList, err := p.ClickHouse().Cluster().ClusterIterator(ctx, &clickhouse.ListClustersRequest{}).TakeAll()
+	require.NoError(t, err)
+	require.Len(t, clustersList, 1)
+	assert.Equal(t, "p1", clustersList[0].ProjectId)
+
+	// Requests without project_id are passed through.
+	_, err = p.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: "chc1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"p1", "p1", "p1"}, clusters.received())
+}
+
+func TestForProject_Mismatch(t *testing.T) {
+	sdk, clusters := newProjectTestSDK(t)
+	ctx := context.Background()
+	p := sdk.ForProject("p1")
+
+	_, err := p.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{ProjectId: "p2", Name: "c"})
+	require.ErrorIs(t, err, ErrProjectMismatch)
+	var mismatch *ProjectMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, &ProjectMismatchError{
+		Method:    "/doublecloud.clickhouse.v1.ClusterService/Create",
+		Project:   "p1",
+		Requested: "p2",
+	}, mismatch)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = p.ClickHouse().Cluster().ClusterIterator(ctx, &clickhouse.ListClustersRequest{ProjectId: "p2"}).TakeAll()
+	require.ErrorIs(t, err, ErrProjectMismatch)
+
+	assert.Empty(t, clusters.received(), "mismatched requests must not be sent")
+}
+
+func TestForProject_ParentUnchanged(t *testing.T) {
+	sdk, clusters := newProjectTestSDK(t)
+	ctx := context.Background()
+	_ = sdk.ForProject("p1")
+
+	_, err := sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "c"})
+	require.NoError(t, err)
+	_, err = sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{ProjectId: "p2", Name: "c"})
+	require.NoError(t, err)
+	_, err = sdk.ClickHouse().Cluster().ClusterIterator(ctx, &clickhouse.ListClustersRequest{ProjectId: "p3"}).TakeAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"", "p2", "p3"}, clusters.received())
+}
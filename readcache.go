@@ -0,0 +1,259 @@
+package dcsdk
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultReadCacheMaxEntries bounds the read cache if ReadCacheConfig.MaxEntries is not set.
+const DefaultReadCacheMaxEntries = 1024
+
+// ReadCacheConfig configures the read-through cache of Get calls. The cache is disabled
+// if TTL is not positive.
+//
+// A cached response is served for up to TTL and may be stale for that long with respect to
+// changes made by other clients or by the service itself, e.g. status transitions of a cluster.
+// Entries of a resource are dropped early when this SDK instance makes a mutating call on it,
+// i.e. any call other than Get and List, and when it observes an operation on the resource
+// complete, e.g. while waiting for it, and the replies of the Get calls of the resource then in
+// flight are not cached. Use NoCache for calls that must read the current state.
+//
+// NotFound errors of Get calls are cached apart, for Config.NegativeCacheTTL, with the same
+// invalidation: e.g. the error of a deleted resource is served until this SDK instance
+// creates a resource with its ID. They are told apart with sdkerrors.IsCachedNotFound.
+type ReadCacheConfig struct {
+	TTL time.Duration
+	// MaxEntries bounds the number of cached responses, the least recently used are evicted.
+	// Defaults to DefaultReadCacheMaxEntries.
+	MaxEntries int
+}
+
+type noCacheKey struct{}
+
+// NoCache returns a context whose Get calls bypass the read cache. Their responses
+// are not cached either.
+func NoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+type readCacheEntry struct {
+	key      string
+	resource string
+	// reply is the cached response, nil for a cached NotFound err.
+	reply   proto.Message
+	err     error
+	stored  time.Time
+	expires time.Time
+}
+
+// readCache caches responses of Get calls by method and request,
+// indexed by the resource of the request for invalidation.
+type readCache struct {
+	conf ReadCacheConfig
+	// negativeTTL is Config.NegativeCacheTTL.
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu         sync.Mutex
+	lru        *list.List
+	byKey      map[string]*list.Element
+	byResource map[string]map[string]bool
+	// gets are the resources with Get calls in flight, see beginGet.
+	gets map[string]*resourceGets
+}
+
+// resourceGets counts the Get calls in flight of a resource, and the invalidations of the
+// resource made meanwhile: the replies of the calls started before an invalidation may
+// predate the mutation, and are not stored.
+type resourceGets struct {
+	calls      int
+	generation uint64
+}
+
+func newReadCache(conf ReadCacheConfig, negativeTTL time.Duration) *readCache {
+	if conf.MaxEntries <= 0 {
+		conf.MaxEntries = DefaultReadCacheMaxEntries
+	}
+	return &readCache{
+		conf:        conf,
+		negativeTTL: negativeTTL,
+		now:         now,
+		lru:         list.New(),
+		byKey:       map[string]*list.Element{},
+		byResource:  map[string]map[string]bool{},
+		gets:        map[string]*resourceGets{},
+	}
+}
+
+func isGetMethod(method string) bool {
+	return strings.HasSuffix(method, "/Get") && !strings.Contains(method, "OperationService/")
+}
+
+func isReadMethod(method string) bool {
+	i := strings.LastIndex(method, "/")
+	return strings.HasPrefix(method[i+1:], "Get") || strings.HasPrefix(method[i+1:], "List")
+}
+
+func (c *readCache) InterceptUnary(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if c.conf.TTL <= 0 && c.negativeTTL <= 0 {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+	if isGetMethod(method) {
+		return c.get(ctx, method, req, reply, conn, invoker, opts)
+	}
+	if strings.Contains(method, "OperationService/") {
+		err := invoker(ctx, method, req, reply, conn, opts...)
+		if op, ok := reply.(*dcv1.Operation); ok && err == nil && isDone(op) {
+			c.invalidate(op.GetResourceId())
+		}
+		return err
+	}
+	if isReadMethod(method) {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+
+	// Mutating call: its effects may be visible before the call returns.
+	resource := requestResource(req)
+	c.invalidate(resource)
+	err := invoker(ctx, method, req, reply, conn, opts...)
+	c.invalidate(resource)
+	if op, ok := reply.(*dcv1.Operation); ok && err == nil {
+		c.invalidate(op.GetResourceId())
+	}
+	return err
+}
+
+func isDone(op *dcv1.Operation) bool {
+	return operation.StatusDone(op.GetStatus())
+}
+
+func (c *readCache) get(ctx context.Context, method string, req, reply interface{}, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts []grpc.CallOption) error {
+	resource := requestResource(req)
+	reqMsg, ok := req.(proto.Message)
+	replyMsg, replyOk := reply.(proto.Message)
+	if !ok || !replyOk || resource == "" || ctx.Value(noCacheKey{}) != nil {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(reqMsg)
+	if err != nil {
+		return invoker(ctx, method, req, reply, conn, opts...)
+	}
+	key := method + "\x00" + string(b)
+	if cached := c.lookup(key); cached != nil {
+		if cached.err != nil {
+			return &sdkerrors.CachedNotFoundError{Err: cached.err, CachedAt: cached.stored}
+		}
+		proto.Reset(replyMsg)
+		proto.Merge(replyMsg, cached.reply)
+		return nil
+	}
+	generation := c.beginGet(resource)
+	defer c.endGet(resource)
+	err = invoker(ctx, method, req, reply, conn, opts...)
+	switch {
+	case err == nil && c.conf.TTL > 0:
+		c.store(&readCacheEntry{key: key, resource: resource, reply: proto.Clone(replyMsg)}, c.conf.TTL, generation)
+	case status.Code(err) == codes.NotFound && c.negativeTTL > 0:
+		c.store(&readCacheEntry{key: key, resource: resource, err: err}, c.negativeTTL, generation)
+	}
+	return err
+}
+
+// beginGet registers a Get call of the resource in flight until endGet, and returns the
+// generation of the resource to store its reply with.
+func (c *readCache) beginGet(resource string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.gets[resource]
+	if g == nil {
+		g = &resourceGets{}
+		c.gets[resource] = g
+	}
+	g.calls++
+	return g.generation
+}
+
+func (c *readCache) endGet(resource string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.gets[resource]
+	if g.calls--; g.calls == 0 {
+		delete(c.gets, resource)
+	}
+}
+
+func (c *readCache) lookup(key string) *readCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.byKey[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*readCacheEntry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return e
+}
+
+// store caches e for ttl, unless its resource was invalidated since the generation, see
+// beginGet.
+func (c *readCache) store(e *readCacheEntry, ttl time.Duration, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, resource := e.key, e.resource
+	if g := c.gets[resource]; g != nil && g.generation != generation {
+		return
+	}
+	if el, ok := c.byKey[key]; ok {
+		c.remove(el)
+	}
+	e.stored = c.now()
+	e.expires = e.stored.Add(ttl)
+	c.byKey[key] = c.lru.PushFront(e)
+	if c.byResource[resource] == nil {
+		c.byResource[resource] = map[string]bool{}
+	}
+	c.byResource[resource][key] = true
+	for c.lru.Len() > c.conf.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate drops the cached responses of the resource.
+func (c *readCache) invalidate(resource string) {
+	if resource == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if g := c.gets[resource]; g != nil {
+		g.generation++
+	}
+	for key := range c.byResource[resource] {
+		c.remove(c.byKey[key])
+	}
+}
+
+func (c *readCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*readCacheEntry)
+	delete(c.byKey, e.key)
+	delete(c.byResource[e.resource], e.key)
+	if len(c.byResource[e.resource]) == 0 {
+		delete(c.byResource, e.resource)
+	}
+}
@@ -0,0 +1,281 @@
+package dcsdk
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// cachedClusters serves clusters whose description counts the Get calls made for them,
+// so stale responses are easy to tell apart.
+type cachedClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	mu   sync.Mutex
+	gets map[string]int
+	// hold, if set, is called by Get once the response is read.
+	hold func()
+}
+
+func (s *cachedClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	s.mu.Lock()
+	s.gets[req.ClusterId]++
+	c := &clickhouse.Cluster{Id: req.ClusterId, Description: string(rune('0' + s.gets[req.ClusterId]))}
+	hold := s.hold
+	s.mu.Unlock()
+	if hold != nil {
+		hold()
+	}
+	return c, nil
+}
+
+func (s *cachedClusters) Update(ctx context.Context, req *clickhouse.UpdateClusterRequest) (*dcv1.Operation, error) {
+	return &dcv1.Operation{Id: "cho-update", ResourceId: req.ClusterId, Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (s *cachedClusters) calls(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets[id]
+}
+
+// cachedClusterOperations reports every operation done.
+type cachedClusterOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+}
+
+func (cachedClusterOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	return &dcv1.Operation{Id: req.OperationId, ResourceId: "chc1", Status: dcv1.Operation_STATUS_DONE}, nil
+}
+
+func newCacheTestSDK(t *testing.T, conf ReadCacheConfig) (*SDK, *cachedClusters) {
+	srv := &cachedClusters{gets: map[string]int{}}
+	sdk := newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token"), ReadCache: conf}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, srv)
+		clickhouse.RegisterOperationServiceServer(s, cachedClusterOperations{})
+	})
+	return sdk, srv
+}
+
+func getDescription(t *testing.T, ctx context.Context, sdk *SDK, id string) string {
+	t.Helper()
+	c, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: id})
+	require.NoError(t, err)
+	return c.Description
+}
+
+func TestReadCache(t *testing.T) {
+	sdk, srv := newCacheTestSDK(t, ReadCacheConfig{TTL: time.Minute})
+	ctx := context.Background()
+
+	assert.Equal(t, "1", getDescription(t, ctx, sdk, "chc1"))
+	assert.Equal(t, "1", getDescription(t, ctx, sdk, "chc1"))
+	assert.Equal(t, "1", getDescription(t, ctx, sdk, "chc2"))
+	assert.Equal(t, 1, srv.calls("chc1"))
+	assert.Equal(t, 1, srv.calls("chc2"))
+
+	assert.Equal(t, "2", getDescription(t, NoCache(ctx), sdk, "chc1"), "NoCache reads the current state")
+	assert.Equal(t, "1", getDescription(t, ctx, sdk, "chc1"), "NoCache responses are not cached")
+}
+
+func TestReadCache_GetInFlightOnMutation(t *testing.T) {
+	sdk, srv := newCacheTestSDK(t, ReadCacheConfig{TTL: time.Minute})
+	ctx := context.Background()
+	read, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	srv.mu.Lock()
+	srv.hold = func() {
+		once.Do(func() {
+			close(read)
+			<-release
+		})
+	}
+	srv.mu.Unlock()
+
+	stale := make(chan string)
+	go func() {
+		c, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: "chc1"})
+		assert.NoError(t, err)
+		stale <- c.GetDescription()
+	}()
+	<-read
+	_, err := sdk.ClickHouse().Cluster().Update(ctx, &clickhouse.UpdateClusterRequest{ClusterId: "chc1"})
+	require.NoError(t, err)
+	close(release)
+	assert.Equal(t, "1", <-stale, "the Get read the cluster before the update")
+
+	assert.Equal(t, "2", getDescription(t, ctx, sdk, "chc1"), "the reply of the Get in flight on the update is not cached")
+	assert.Equal(t, "2", getDescription(t, ctx, sdk, "chc1"))
+	assert.Equal(t, 2, srv.calls("chc1"))
+	assert.Empty(t, sdk.cache.gets, "no Get in flight is left")
+}
+
+func TestReadCache_Disabled(t *testing.T) {
+	sdk, srv := newCacheTestSDK(t, ReadCacheConfig{})
+	ctx := context.Background()
+	getDescription(t, ctx, sdk, "chc1")
+	getDescription(t, ctx, sdk, "chc1")
+	assert.Equal(t, 2, srv.calls("chc1"))
+}
+
+func TestReadCache_TTL(t *testing.T) {
+	sdk, srv := newCacheTestSDK(t, ReadCacheConfig{TTL: time.Minute})
+	clock := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	sdk.cache.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	getDescription(t, ctx, sdk, "chc1")
+	clock = clock.Add(59 * time.Second)
+	assert.Equal(t, "1", getDescription(t, ctx, sdk, "chc1"), "stale for up to TTL")
+	clock = clock.Add(time.Second)
+	assert.Equal(t, "2", getDescription(t, ctx, sdk, "chc1"))
+	assert.Equal(t, 2, srv.calls("chc1"))
+}
+
+func TestReadCache_MaxEntries(t *testing.T) {
+	sdk, srv := newCacheTestSDK(t, ReadCacheConfig{TTL: time.Minute, MaxEntries: 2})
+	ctx := context.Background()
+
+	getDescription(t, ctx, sdk, "chc1")
+	getDescription(t, ctx, sdk, "chc2")
+	getDescription(t, ctx, sdk, "chc1")
+	getDescription(t, ctx, sdk, "chc3") // evicts chc2, the least recently used
+	getDescription(t, ctx, sdk, "chc1")
+	getDescription(t, ctx, sdk, "chc2")
+	assert.Equal(t, 1, srv.calls("chc1"))
+	assert.Equal(t, 2, srv.calls("chc2"))
+}
+
+func TestReadCache_Invalidation(t *testing.T) {
+	sdk, srv := newCacheTestSDK(t, ReadCacheConfig{TTL: time.Minute})
+	ctx := context.Background()
+
+	getDescription(t, ctx, sdk, "chc1")
+	getDescription(t, ctx, sdk, "chc2")
+	op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Update(ctx, &clickhouse.UpdateClusterRequest{ClusterId: "chc1"}))
+	require.NoError(t, err)
+	assert.Equal(t, "2", getDescription(t, ctx, sdk, "chc1"), "mutating calls invalidate the resource")
+	assert.Equal(t, "1", getDescription(t, ctx, sdk, "chc2"), "other resources stay cached")
+
+	// The state read while the update was running is cached until the operation completes.
+	assert.Equal(t, "2", getDescription(t, ctx, sdk, "chc1"))
+	require.NoError(t, op.Wait(ctx))
+	assert.Equal(t, "3", getDescription(t, ctx, sdk, "chc1"), "completed operations invalidate their resource")
+	assert.Equal(t, 3, srv.calls("chc1"))
+	assert.Equal(t, 1, srv.calls("chc2"))
+}
+
+// deletedClusters serves no cluster until it is created, with the name as its ID.
+type deletedClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	mu      sync.Mutex
+	gets    int
+	created map[string]bool
+}
+
+func (s *deletedClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if !s.created[req.ClusterId] {
+		return nil, status.Errorf(codes.NotFound, "cluster %s not found", req.ClusterId)
+	}
+	return &clickhouse.Cluster{Id: req.ClusterId}, nil
+}
+
+func (s *deletedClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created[req.Name] = true
+	return &dcv1.Operation{Id: "cho-create", ResourceId: req.Name, Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (s *deletedClusters) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func newNegativeCacheTestSDK(t *testing.T, ttl time.Duration) (*SDK, *deletedClusters) {
+	srv := &deletedClusters{created: map[string]bool{}}
+	sdk := newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token"), NegativeCacheTTL: ttl}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, srv)
+		clickhouse.RegisterOperationServiceServer(s, cachedClusterOperations{})
+	})
+	return sdk, srv
+}
+
+func TestReadCache_NegativeTTL(t *testing.T) {
+	sdk, srv := newNegativeCacheTestSDK(t, time.Second)
+	clock := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	sdk.cache.now = func() time.Time { return clock }
+	ctx := context.Background()
+	get := func(ctx context.Context, id string) error {
+		_, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: id})
+		return err
+	}
+
+	err := get(ctx, "chc1")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.False(t, sdkerrors.IsCachedNotFound(err), "the first lookup is a real one")
+	clock = clock.Add(999 * time.Millisecond)
+	err = get(ctx, "chc1")
+	assert.Equal(t, codes.NotFound, status.Code(err), "the cached error keeps its status")
+	assert.EqualError(t, err, "cached: rpc error: code = NotFound desc = cluster chc1 not found")
+	var cached *sdkerrors.CachedNotFoundError
+	require.ErrorAs(t, err, &cached)
+	assert.Equal(t, clock.Add(-999*time.Millisecond), cached.CachedAt)
+	assert.Equal(t, 1, srv.calls())
+
+	err = get(NoCache(ctx), "chc1")
+	assert.False(t, sdkerrors.IsCachedNotFound(err), "NoCache forces a real lookup")
+	assert.Equal(t, 2, srv.calls())
+
+	clock = clock.Add(time.Millisecond)
+	assert.False(t, sdkerrors.IsCachedNotFound(get(ctx, "chc1")), "expired")
+	assert.Equal(t, 3, srv.calls())
+	assert.True(t, sdkerrors.IsCachedNotFound(get(ctx, "chc1")))
+	assert.Equal(t, 3, srv.calls())
+}
+
+func TestReadCache_NegativeInvalidation(t *testing.T) {
+	sdk, srv := newNegativeCacheTestSDK(t, time.Minute)
+	ctx := context.Background()
+	get := func(id string) error {
+		_, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: id})
+		return err
+	}
+
+	require.Error(t, get("chc1"))
+	require.Error(t, get("chc2"))
+	assert.True(t, sdkerrors.IsCachedNotFound(get("chc1")))
+	_, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "chc1"}))
+	require.NoError(t, err)
+	assert.NoError(t, get("chc1"), "the create invalidates the resource of its operation")
+	assert.NoError(t, get("chc1"), "the responses are not cached without ReadCache.TTL")
+	assert.True(t, sdkerrors.IsCachedNotFound(get("chc2")), "other resources stay cached")
+	assert.Equal(t, 4, srv.calls())
+
+	// The operations of cachedClusterOperations complete on chc1.
+	srv.mu.Lock()
+	delete(srv.created, "chc1")
+	srv.mu.Unlock()
+	require.Error(t, get("chc1"))
+	assert.True(t, sdkerrors.IsCachedNotFound(get("chc1")))
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho-create", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(ctx))
+	assert.False(t, sdkerrors.IsCachedNotFound(get("chc1")), "completed operations invalidate their resource")
+}
@@ -0,0 +1,146 @@
+package dcsdk
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/doublecloud/go-sdk/internal/recordings"
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// The wire-compatibility tests check the assumptions of the SDK against the sanitized
+// responses of the API of testdata/recordings: the responses must decode without unknown
+// fields, and the operations, resources and errors in them must satisfy the invariants
+// below. New recordings are added with internal/recordings/cmd/record.
+
+// metadataKeyPattern is the format of the operation metadata keys of opmeta.
+var metadataKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// sanitizedIDPattern matches the resource IDs of the recordings, see recordings.Sanitize.
+var sanitizedIDPattern = regexp.MustCompile(`\b[a-z]{3}\d{16,17}\b`)
+
+func TestRecordings(t *testing.T) {
+	recs, err := recordings.Load("testdata/recordings")
+	require.NoError(t, err)
+	require.NotEmpty(t, recs)
+	for _, r := range recs {
+		r := r
+		t.Run(r.Path, func(t *testing.T) {
+			kind, _ := methodLabels(r.Method)
+			require.NotEmpty(t, kind, "a method of MethodCatalog")
+			assert.Equal(t, r.Service(), filepath.Dir(r.Path), "the directory of the service")
+			if r.IsError() {
+				st, err := r.DecodeStatus()
+				require.NoError(t, err)
+				checkRecordedStatus(t, st)
+				return
+			}
+			msg, err := r.Decode()
+			require.NoError(t, err, "the fields of the response are known")
+			ops := recordedOperations(msg)
+			for _, o := range ops {
+				checkRecordedOperation(t, kind, o)
+			}
+			if len(ops) == 0 {
+				checkRecordedResource(t, kind, msg)
+			}
+		})
+	}
+}
+
+// recordedOperations returns the operations of msg: msg itself or the ones of its lists.
+func recordedOperations(msg proto.Message) []*dcv1.Operation {
+	if o, ok := msg.(*dcv1.Operation); ok {
+		return []*dcv1.Operation{o}
+	}
+	var ops []*dcv1.Operation
+	m := msg.ProtoReflect()
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !fd.IsList() || fd.Message() == nil {
+			return true
+		}
+		for i := 0; i < v.List().Len(); i++ {
+			if o, ok := v.List().Get(i).Message().Interface().(*dcv1.Operation); ok {
+				ops = append(ops, o)
+			}
+		}
+		return true
+	})
+	return ops
+}
+
+func checkRecordedOperation(t *testing.T, kind ServiceKind, o *dcv1.Operation) {
+	op := operation.New(nil, o)
+	done := o.GetStatus() == dcv1.Operation_STATUS_DONE
+	assert.Equal(t, done, op.Done(), "%s: Done of STATUS_DONE", o.GetId())
+	assert.Equal(t, done && o.GetError() != nil, op.Failed(), "%s: Failed of done operations with an error", o.GetId())
+	assert.Equal(t, done && o.GetError() == nil, op.Ok(), "%s: Ok of done operations without error", o.GetId())
+	assert.Equal(t, kind, operationServiceKind(o.GetId()), "%s: the service of the ID", o.GetId())
+	assert.False(t, op.CreatedAt().IsZero(), "%s: a creation time", o.GetId())
+	for key := range o.GetMetadata() {
+		assert.Regexp(t, metadataKeyPattern, key, "%s: the metadata keys of opmeta", o.GetId())
+	}
+	if id := o.GetResourceId(); id != "" {
+		checkRecordedResourceID(t, kind, id)
+	}
+
+	if !done {
+		return
+	}
+	// Done operations are not polled again: their state is the result of their waits.
+	err := op.Wait(context.Background(), operation.WithPollFunc(func(ctx context.Context, id string) (*dcv1.Operation, time.Duration, error) {
+		t.Errorf("%s: done operation polled", id)
+		return o, 0, nil
+	}))
+	if op.Ok() {
+		assert.NoError(t, err, o.GetId())
+		assert.Empty(t, operation.FailureSignature(op), o.GetId())
+		return
+	}
+	assert.ErrorIs(t, err, operation.ErrOperationFailed, o.GetId())
+	assert.Equal(t, codes.Code(o.GetError().GetCode()), status.Code(err), "%s: the status of the error", o.GetId())
+	assert.NotEqual(t, codes.OK, status.Code(err), "%s: failed with a code", o.GetId())
+	assert.Equal(t, sdkerrors.Signature(status.ErrorProto(o.GetError())), operation.FailureSignature(op), o.GetId())
+}
+
+func checkRecordedStatus(t *testing.T, st *status.Status) {
+	assert.NotEqual(t, codes.OK, st.Code(), "errors have a code")
+	assert.NotEmpty(t, st.Message())
+	assert.Len(t, st.Details(), len(st.Proto().GetDetails()), "the details are of known types")
+	assert.NotRegexp(t, sanitizedIDPattern, sdkerrors.Normalize(st.Message()), "the IDs are normalized")
+	assert.Regexp(t, "^"+st.Code().String()+"\\|", sdkerrors.Signature(st.Err()))
+}
+
+func checkRecordedResource(t *testing.T, kind ServiceKind, msg proto.Message) {
+	m := msg.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName("id")
+	require.NotNil(t, fd, "resources have an ID")
+	id := m.Get(fd).String()
+	require.NotEmpty(t, id)
+	checkRecordedResourceID(t, kind, id)
+	assert.NotEmpty(t, resourceProject(msg), "%s: resources have a project", id)
+}
+
+// checkRecordedResourceID checks that the kind of the prefix of id, if known, belongs to
+// the service.
+func checkRecordedResourceID(t *testing.T, kind ServiceKind, id string) {
+	require.Greater(t, len(id), 3)
+	if k, ok := resourceIDPrefixes[id[:3]]; ok {
+		assert.Equal(t, kind, k.ServiceKind(), "%s: the service of the prefix", id)
+		ref, err := parseResourceID(id)
+		assert.NoError(t, err, id)
+		assert.Equal(t, k, ref.Kind, id)
+	}
+}
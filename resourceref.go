@@ -0,0 +1,366 @@
+package dcsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	clickhousepb "github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	kafkapb "github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	networkpb "github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	transferpb "github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	visualizationpb "github.com/doublecloud/go-genproto/doublecloud/visualization/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ResourceKind is the kind of a resource of a ResourceRef, e.g. ResourceClickHouseCluster.
+type ResourceKind string
+
+const (
+	ResourceClickHouseCluster     ResourceKind = "clickhouse/cluster"
+	ResourceKafkaCluster          ResourceKind = "kafka/cluster"
+	ResourceNetwork               ResourceKind = "vpc/network"
+	ResourceTransfer              ResourceKind = "transfer/transfer"
+	ResourceTransferEndpoint      ResourceKind = "transfer/endpoint"
+	ResourceVisualizationWorkbook ResourceKind = "visualization/workbook"
+)
+
+// ServiceKind returns the service of the resources of the kind, e.g. ClickHouseServiceID.
+func (k ResourceKind) ServiceKind() ServiceKind {
+	return ServiceKind(k[:strings.IndexByte(string(k)+"/", '/')])
+}
+
+// resourceIDPrefixes are the kinds of the resource IDs by prefix. The IDs of networks and
+// workbooks have none the SDK knows.
+var resourceIDPrefixes = map[string]ResourceKind{
+	"chc": ResourceClickHouseCluster,
+	"kfc": ResourceKafkaCluster,
+	"dtt": ResourceTransfer,
+	"dte": ResourceTransferEndpoint,
+}
+
+// consoleResourceKinds are the kinds of the resources of the console URLs by service kind.
+// The transfer pages are refined by the prefix of the ID, see resourceIDPrefixes.
+var consoleResourceKinds = map[ServiceKind]ResourceKind{
+	ClickHouseServiceID:    ResourceClickHouseCluster,
+	KafkaServiceID:         ResourceKafkaCluster,
+	VpcServiceID:           ResourceNetwork,
+	TransferServiceID:      ResourceTransfer,
+	VisualizationServiceID: ResourceVisualizationWorkbook,
+}
+
+// resourceNameScheme is the prefix of the fully-qualified names of ResourceRef.String.
+const resourceNameScheme = "crn:doublecloud:"
+
+// ResourceRef refers to a resource of any service, see SDK.ParseResourceRef.
+type ResourceRef struct {
+	Kind ResourceKind
+	// ProjectID is the project of the resource, empty if the reference doesn't tell it,
+	// e.g. a bare ID.
+	ProjectID string
+	ID        string
+}
+
+// String returns the fully-qualified name of the resource,
+// "crn:doublecloud:<service>:<project>:<type>/<id>", e.g.
+// "crn:doublecloud:clickhouse:prj1:cluster/chc1". The project is empty if unknown.
+func (r ResourceRef) String() string {
+	service, typ, _ := strings.Cut(string(r.Kind), "/")
+	return resourceNameScheme + service + ":" + r.ProjectID + ":" + typ + "/" + r.ID
+}
+
+// ErrInvalidResourceRef is matched by errors.Is for every *ResourceRefError.
+var ErrInvalidResourceRef = errors.New("invalid resource reference")
+
+// ResourceRefError is returned by ParseResourceRef for the input of no format, or of several
+// resources.
+type ResourceRefError struct {
+	Input string
+	// Tried are the formats tried, with the reasons they didn't match, e.g.
+	// {"id", "unknown prefix"}.
+	Tried []ResourceRefAttempt
+	// Ambiguous are the references the input matched, if more than one.
+	Ambiguous []ResourceRef
+}
+
+// ResourceRefAttempt is a format tried by ParseResourceRef.
+type ResourceRefAttempt struct {
+	// Format is "id", "console url" or "resource name".
+	Format string
+	Reason string
+}
+
+func (e *ResourceRefError) Error() string {
+	if len(e.Ambiguous) > 0 {
+		refs := make([]string, len(e.Ambiguous))
+		for i, r := range e.Ambiguous {
+			refs[i] = r.String()
+		}
+		return fmt.Sprintf("ambiguous resource reference %q: matches %s", e.Input, strings.Join(refs, ", "))
+	}
+	tried := make([]string, len(e.Tried))
+	for i, a := range e.Tried {
+		tried[i] = a.Format + ": " + a.Reason
+	}
+	return fmt.Sprintf("invalid resource reference %q: tried %s", e.Input, strings.Join(tried, "; "))
+}
+
+func (e *ResourceRefError) Is(target error) bool { return target == ErrInvalidResourceRef }
+
+// ParseResourceRef returns the resource of s, any of:
+//
+//   - a bare ID with a known prefix, e.g. "chc1a2b3c"; the IDs of networks and workbooks
+//     have none, so they need one of the other formats;
+//   - the URL of the console page of the resource, matched against the templates of
+//     Config.ConsoleURLs and DefaultConsoleURLs, query and fragment ignored, e.g. the URL of
+//     SDK.ConsoleURL, or of a tab of the page;
+//   - the fully-qualified name of ResourceRef.String.
+//
+// The surrounding spaces are ignored. Inputs of none of the formats fail with
+// *ResourceRefError listing the formats tried, and so do the URLs matching the templates of
+// several resources, e.g. overlapping Config.ConsoleURLs.
+func (sdk *SDK) ParseResourceRef(s string) (ResourceRef, error) {
+	input := s
+	s = strings.TrimSpace(s)
+	refErr := &ResourceRefError{Input: input}
+	if s == "" {
+		refErr.Tried = []ResourceRefAttempt{{Format: "id", Reason: "empty"}}
+		return ResourceRef{}, refErr
+	}
+	switch {
+	case strings.HasPrefix(s, resourceNameScheme):
+		ref, err := parseResourceName(s)
+		if err == nil {
+			return ref, nil
+		}
+		refErr.Tried = append(refErr.Tried, ResourceRefAttempt{Format: "resource name", Reason: err.Error()})
+	case strings.Contains(s, "://"):
+		refs, err := sdk.parseConsoleURL(s)
+		switch {
+		case err != nil:
+			refErr.Tried = append(refErr.Tried, ResourceRefAttempt{Format: "console url", Reason: err.Error()})
+		case len(refs) == 1:
+			return refs[0], nil
+		default:
+			refErr.Ambiguous = refs
+		}
+	default:
+		ref, err := parseResourceID(s)
+		if err == nil {
+			return ref, nil
+		}
+		refErr.Tried = append(refErr.Tried,
+			ResourceRefAttempt{Format: "id", Reason: err.Error()},
+			ResourceRefAttempt{Format: "console url", Reason: "not a url"},
+			ResourceRefAttempt{Format: "resource name", Reason: "no " + resourceNameScheme + " prefix"},
+		)
+	}
+	return ResourceRef{}, refErr
+}
+
+// parseResourceID returns the resource of a bare ID.
+func parseResourceID(id string) (ResourceRef, error) {
+	if strings.ContainsAny(id, "/:? \t\n") {
+		return ResourceRef{}, errors.New("not an id")
+	}
+	for prefix, kind := range resourceIDPrefixes {
+		if strings.HasPrefix(id, prefix) && len(id) > len(prefix) {
+			return ResourceRef{Kind: kind, ID: id}, nil
+		}
+	}
+	return ResourceRef{}, fmt.Errorf("unknown prefix, known: %s", strings.Join(knownResourcePrefixes(), ", "))
+}
+
+func knownResourcePrefixes() []string {
+	prefixes := make([]string, 0, len(resourceIDPrefixes))
+	for prefix := range resourceIDPrefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// parseResourceName parses the name of ResourceRef.String.
+func parseResourceName(s string) (ResourceRef, error) {
+	parts := strings.Split(strings.TrimPrefix(s, resourceNameScheme), ":")
+	if len(parts) != 3 {
+		return ResourceRef{}, errors.New("want crn:doublecloud:<service>:<project>:<type>/<id>")
+	}
+	typ, id, ok := strings.Cut(parts[2], "/")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return ResourceRef{}, fmt.Errorf("want <type>/<id>, got %q", parts[2])
+	}
+	kind := ResourceKind(parts[0] + "/" + typ)
+	if !kind.known() {
+		return ResourceRef{}, fmt.Errorf("unknown resource kind %q", kind)
+	}
+	ref := ResourceRef{Kind: kind, ProjectID: parts[1], ID: id}
+	return ref, ref.checkPrefix()
+}
+
+func (k ResourceKind) known() bool {
+	for _, known := range consoleResourceKinds {
+		if known == k {
+			return true
+		}
+	}
+	return k == ResourceTransferEndpoint
+}
+
+// checkPrefix reports the IDs of a known prefix of another kind.
+func (r ResourceRef) checkPrefix() error {
+	if id, err := parseResourceID(r.ID); err == nil && id.Kind.ServiceKind() != r.Kind.ServiceKind() {
+		return fmt.Errorf("id %q is a %s id, not a %s one", r.ID, id.Kind, r.Kind)
+	}
+	return nil
+}
+
+// parseConsoleURL returns the resources of the console templates matching the URL.
+func (sdk *SDK) parseConsoleURL(s string) ([]ResourceRef, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed url: %v", err)
+	}
+	if u.Host == "" {
+		return nil, errors.New("no host")
+	}
+	templates := DefaultConsoleURLs()
+	for kind, template := range sdk.config().ConsoleURLs {
+		templates[kind] = template
+	}
+	kinds := make([]string, 0, len(templates))
+	for kind := range templates {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+
+	var refs []ResourceRef
+	var mismatches []string
+	for _, kind := range kinds {
+		template := templates[ServiceKind(kind)]
+		resourceKind, ok := consoleResourceKinds[ServiceKind(kind)]
+		if template == "" || !ok {
+			continue
+		}
+		project, id, ok := matchConsoleTemplate(template, u)
+		if !ok {
+			continue
+		}
+		ref := ResourceRef{Kind: resourceKind, ProjectID: project, ID: id}
+		if prefixed, err := parseResourceID(id); err == nil && prefixed.Kind.ServiceKind() == ref.Kind.ServiceKind() {
+			ref.Kind = prefixed.Kind
+		}
+		if err := ref.checkPrefix(); err != nil {
+			mismatches = append(mismatches, err.Error())
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	switch {
+	case len(refs) > 0:
+		return refs, nil
+	case len(mismatches) > 0:
+		return nil, errors.New(strings.Join(mismatches, "; "))
+	}
+	return nil, fmt.Errorf("matches no console url template of %s", strings.Join(kinds, ", "))
+}
+
+// matchConsoleTemplate matches the URL against the template of ConsoleURL, returning the
+// project and the resource. The URL may have more path segments, e.g. of a tab of the page.
+func matchConsoleTemplate(template string, u *url.URL) (project, resource string, ok bool) {
+	t, err := url.Parse(template)
+	if err != nil || !strings.EqualFold(t.Scheme, u.Scheme) || !strings.EqualFold(t.Host, u.Host) {
+		return "", "", false
+	}
+	want := strings.Split(strings.Trim(t.Path, "/"), "/")
+	got := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if len(got) < len(want) {
+		return "", "", false
+	}
+	for i, segment := range want {
+		placeholder, value := "{project}", &project
+		if !strings.Contains(segment, placeholder) {
+			placeholder, value = "{resource}", &resource
+		}
+		prefix, suffix, found := strings.Cut(segment, placeholder)
+		if !found {
+			if segment != got[i] {
+				return "", "", false
+			}
+			continue
+		}
+		if !strings.HasPrefix(got[i], prefix) || !strings.HasSuffix(got[i], suffix) || len(got[i]) <= len(prefix)+len(suffix) {
+			return "", "", false
+		}
+		v, err := url.PathUnescape(got[i][len(prefix) : len(got[i])-len(suffix)])
+		if err != nil {
+			return "", "", false
+		}
+		*value = v
+	}
+	return project, resource, resource != ""
+}
+
+// GetResource gets the resource of the reference, e.g. of ParseResourceRef, with the Get
+// method of its service: a *clickhouse.Cluster, a *kafka.Cluster, a *network.Network, a
+// *transfer.Transfer, a *transfer.Endpoint or a *visualization.Workbook. A resource of
+// another project than the one of the reference, if set, fails with *ResourceProjectError.
+func (sdk *SDK) GetResource(ctx context.Context, ref ResourceRef, opts ...grpc.CallOption) (proto.Message, error) {
+	var res proto.Message
+	var err error
+	switch ref.Kind {
+	case ResourceClickHouseCluster:
+		res, err = sdk.ClickHouse().Cluster().Get(ctx, &clickhousepb.GetClusterRequest{ClusterId: ref.ID}, opts...)
+	case ResourceKafkaCluster:
+		res, err = sdk.Kafka().Cluster().Get(ctx, &kafkapb.GetClusterRequest{ClusterId: ref.ID}, opts...)
+	case ResourceNetwork:
+		res, err = sdk.Network().Network().Get(ctx, &networkpb.GetNetworkRequest{NetworkId: ref.ID}, opts...)
+	case ResourceTransfer:
+		res, err = sdk.Transfer().Transfer().Get(ctx, &transferpb.GetTransferRequest{TransferId: ref.ID}, opts...)
+	case ResourceTransferEndpoint:
+		res, err = sdk.Transfer().Endpoint().Get(ctx, &transferpb.GetEndpointRequest{EndpointId: ref.ID}, opts...)
+	case ResourceVisualizationWorkbook:
+		var resp *visualizationpb.GetWorkbookResponse
+		if resp, err = sdk.Visualization().Workbook().Get(ctx, &visualizationpb.GetWorkbookRequest{WorkbookId: ref.ID}, opts...); err == nil {
+			res = resp.GetWorkbook()
+		}
+	default:
+		return nil, fmt.Errorf("get %s: unknown resource kind %q", ref, ref.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if project := resourceProject(res); ref.ProjectID != "" && project != "" && project != ref.ProjectID {
+		return nil, &ResourceProjectError{Ref: ref, ProjectID: project}
+	}
+	return res, nil
+}
+
+// ResourceProjectError is returned by GetResource for a resource of another project than
+// the one of the reference.
+type ResourceProjectError struct {
+	Ref ResourceRef
+	// ProjectID is the project of the resource.
+	ProjectID string
+}
+
+func (e *ResourceProjectError) Error() string {
+	return fmt.Sprintf("%s is in project %q", e.Ref, e.ProjectID)
+}
+
+func (e *ResourceProjectError) Is(target error) bool { return target == ErrInvalidResourceRef }
+
+// resourceProject returns the project_id field of the resource, if it has one.
+func resourceProject(res proto.Message) string {
+	m := res.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name("project_id"))
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return ""
+	}
+	return m.Get(fd).String()
+}
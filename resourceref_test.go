@@ -0,0 +1,188 @@
+package dcsdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseResourceRef(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	for input, want := range map[string]ResourceRef{
+		// Bare IDs.
+		"chc1a2b3c":     {Kind: ResourceClickHouseCluster, ID: "chc1a2b3c"},
+		"  kfc9z8y7 \n": {Kind: ResourceKafkaCluster, ID: "kfc9z8y7"},
+		"dtt1":          {Kind: ResourceTransfer, ID: "dtt1"},
+		"dte1":          {Kind: ResourceTransferEndpoint, ID: "dte1"},
+
+		// Console URLs.
+		"https://app.double.cloud/projects/prj1/clickhouse/clusters/chc1":          {Kind: ResourceClickHouseCluster, ProjectID: "prj1", ID: "chc1"},
+		"https://app.double.cloud/projects/prj1/kafka/clusters/kfc1/topics?page=2": {Kind: ResourceKafkaCluster, ProjectID: "prj1", ID: "kfc1"},
+		"https://APP.double.cloud/projects/prj1/networks/f1e2d3c4/#peering":        {Kind: ResourceNetwork, ProjectID: "prj1", ID: "f1e2d3c4"},
+		"https://app.double.cloud/projects/prj1/data-transfer/dtt1":                {Kind: ResourceTransfer, ProjectID: "prj1", ID: "dtt1"},
+		"https://app.double.cloud/projects/prj1/data-transfer/dte1":                {Kind: ResourceTransferEndpoint, ProjectID: "prj1", ID: "dte1"},
+		"https://app.double.cloud/projects/prj1/visualization/workbooks/wb1":       {Kind: ResourceVisualizationWorkbook, ProjectID: "prj1", ID: "wb1"},
+		"https://app.double.cloud/projects/prj%201/networks/net%2F1":               {Kind: ResourceNetwork, ProjectID: "prj 1", ID: "net/1"},
+
+		// Resource names.
+		"crn:doublecloud:clickhouse:prj1:cluster/chc1":        {Kind: ResourceClickHouseCluster, ProjectID: "prj1", ID: "chc1"},
+		"crn:doublecloud:vpc::network/net1":                   {Kind: ResourceNetwork, ID: "net1"},
+		"crn:doublecloud:visualization:prj1:workbook/wb1":     {Kind: ResourceVisualizationWorkbook, ProjectID: "prj1", ID: "wb1"},
+		"crn:doublecloud:transfer:prj1:endpoint/dte1":         {Kind: ResourceTransferEndpoint, ProjectID: "prj1", ID: "dte1"},
+		" crn:doublecloud:kafka:prj1:cluster/kfc1 ":           {Kind: ResourceKafkaCluster, ProjectID: "prj1", ID: "kfc1"},
+		"crn:doublecloud:transfer:prj1:transfer/custom-name1": {Kind: ResourceTransfer, ProjectID: "prj1", ID: "custom-name1"},
+	} {
+		ref, err := sdk.ParseResourceRef(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, ref, input)
+	}
+}
+
+func TestParseResourceRef_RoundTrip(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	for _, ref := range []ResourceRef{
+		{Kind: ResourceClickHouseCluster, ProjectID: "prj1", ID: "chc1"},
+		{Kind: ResourceKafkaCluster, ID: "kfc1"},
+		{Kind: ResourceNetwork, ProjectID: "prj1", ID: "net1"},
+		{Kind: ResourceTransferEndpoint, ProjectID: "prj1", ID: "dte1"},
+	} {
+		parsed, err := sdk.ParseResourceRef(ref.String())
+		require.NoError(t, err, ref)
+		assert.Equal(t, ref, parsed)
+
+		if ref.ProjectID == "" {
+			continue
+		}
+		u, err := sdk.ConsoleURL(ref.Kind.ServiceKind(), ref.ProjectID, ref.ID)
+		require.NoError(t, err)
+		parsed, err = sdk.ParseResourceRef(u)
+		require.NoError(t, err, u)
+		assert.Equal(t, ref, parsed, "the console URL of the resource")
+	}
+	assert.Equal(t, "crn:doublecloud:clickhouse:prj1:cluster/chc1", ResourceRef{Kind: ResourceClickHouseCluster, ProjectID: "prj1", ID: "chc1"}.String())
+	assert.Equal(t, TransferServiceID, ResourceTransferEndpoint.ServiceKind())
+}
+
+func TestParseResourceRef_Errors(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	for input, want := range map[string]string{
+		"": `invalid resource reference "": tried id: empty`,
+		"xyz123": `invalid resource reference "xyz123": tried id: unknown prefix, known: chc, dte, dtt, kfc; ` +
+			`console url: not a url; resource name: no crn:doublecloud: prefix`,
+		"chc": `invalid resource reference "chc": tried id: unknown prefix, known: chc, dte, dtt, kfc; ` +
+			`console url: not a url; resource name: no crn:doublecloud: prefix`,
+		"projects/prj1/clusters/chc1": `invalid resource reference "projects/prj1/clusters/chc1": tried id: not an id; ` +
+			`console url: not a url; resource name: no crn:doublecloud: prefix`,
+		"https://app.double.cloud/projects/prj1/clickhouse": `invalid resource reference "https://app.double.cloud/projects/prj1/clickhouse": ` +
+			`tried console url: matches no console url template of clickhouse, kafka, transfer, visualization, vpc`,
+		"https://example.com/projects/prj1/clickhouse/clusters/chc1": `invalid resource reference "https://example.com/projects/prj1/clickhouse/clusters/chc1": ` +
+			`tried console url: matches no console url template of clickhouse, kafka, transfer, visualization, vpc`,
+		"http://app.double.cloud/projects/prj1/clickhouse/clusters/chc1": `invalid resource reference "http://app.double.cloud/projects/prj1/clickhouse/clusters/chc1": ` +
+			`tried console url: matches no console url template of clickhouse, kafka, transfer, visualization, vpc`,
+		"https://app.double.cloud/projects//clickhouse/clusters/chc1": `invalid resource reference "https://app.double.cloud/projects//clickhouse/clusters/chc1": ` +
+			`tried console url: matches no console url template of clickhouse, kafka, transfer, visualization, vpc`,
+		"https://app.double.cloud/projects/prj1/clickhouse/clusters/kfc1": `invalid resource reference "https://app.double.cloud/projects/prj1/clickhouse/clusters/kfc1": ` +
+			`tried console url: id "kfc1" is a kafka/cluster id, not a clickhouse/cluster one`,
+		"https://app.double.cloud/projects/prj1/networks/net%zz": `invalid resource reference "https://app.double.cloud/projects/prj1/networks/net%zz": ` +
+			`tried console url: malformed url: parse "https://app.double.cloud/projects/prj1/networks/net%zz": invalid URL escape "%zz"`,
+		"https:///projects/prj1/networks/net1": `invalid resource reference "https:///projects/prj1/networks/net1": tried console url: no host`,
+		"crn:doublecloud:clickhouse:cluster/chc1": `invalid resource reference "crn:doublecloud:clickhouse:cluster/chc1": ` +
+			`tried resource name: want crn:doublecloud:<service>:<project>:<type>/<id>`,
+		"crn:doublecloud:clickhouse:prj1:cluster": `invalid resource reference "crn:doublecloud:clickhouse:prj1:cluster": ` +
+			`tried resource name: want <type>/<id>, got "cluster"`,
+		"crn:doublecloud:clickhouse:prj1:cluster/a/b": `invalid resource reference "crn:doublecloud:clickhouse:prj1:cluster/a/b": ` +
+			`tried resource name: want <type>/<id>, got "cluster/a/b"`,
+		"crn:doublecloud:billing:prj1:account/b1": `invalid resource reference "crn:doublecloud:billing:prj1:account/b1": ` +
+			`tried resource name: unknown resource kind "billing/account"`,
+		"crn:doublecloud:kafka:prj1:cluster/chc1": `invalid resource reference "crn:doublecloud:kafka:prj1:cluster/chc1": ` +
+			`tried resource name: id "chc1" is a clickhouse/cluster id, not a kafka/cluster one`,
+	} {
+		_, err := sdk.ParseResourceRef(input)
+		var refErr *ResourceRefError
+		require.ErrorAs(t, err, &refErr, input)
+		assert.ErrorIs(t, err, ErrInvalidResourceRef)
+		assert.Equal(t, input, refErr.Input)
+		assert.EqualError(t, err, want, input)
+	}
+}
+
+func TestParseResourceRef_ConsoleURLs(t *testing.T) {
+	conf := Config{Credentials: NewIAMTokenCredentials("test-token"), ConsoleURLs: map[ServiceKind]string{
+		KafkaServiceID:      "https://console.example.com/{project}/streams/kafka-{resource}",
+		ClickHouseServiceID: "",
+		// Overlapping with the networks, on purpose.
+		VisualizationServiceID: "https://app.double.cloud/projects/{project}/networks/{resource}",
+	}}
+	sdk := newTestSDKWithConfig(t, conf, func(s *grpc.Server) {})
+
+	ref, err := sdk.ParseResourceRef("https://console.example.com/prj1/streams/kafka-kfc1")
+	require.NoError(t, err)
+	assert.Equal(t, ResourceRef{Kind: ResourceKafkaCluster, ProjectID: "prj1", ID: "kfc1"}, ref, "the templates of the config")
+
+	_, err = sdk.ParseResourceRef("https://app.double.cloud/projects/prj1/clickhouse/clusters/chc1")
+	assert.ErrorIs(t, err, ErrInvalidResourceRef, "an empty template disables the kind")
+	ref, err = sdk.ParseResourceRef("chc1")
+	require.NoError(t, err)
+	assert.Equal(t, ResourceClickHouseCluster, ref.Kind, "the IDs keep their prefixes")
+
+	_, err = sdk.ParseResourceRef("https://app.double.cloud/projects/prj1/networks/net1")
+	var refErr *ResourceRefError
+	require.ErrorAs(t, err, &refErr)
+	assert.Equal(t, []ResourceRef{
+		{Kind: ResourceVisualizationWorkbook, ProjectID: "prj1", ID: "net1"},
+		{Kind: ResourceNetwork, ProjectID: "prj1", ID: "net1"},
+	}, refErr.Ambiguous)
+	assert.EqualError(t, err, `ambiguous resource reference "https://app.double.cloud/projects/prj1/networks/net1": `+
+		`matches crn:doublecloud:visualization:prj1:workbook/net1, crn:doublecloud:vpc:prj1:network/net1`)
+}
+
+type refClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+}
+
+func (refClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return &clickhouse.Cluster{Id: req.ClusterId, ProjectId: "prj1"}, nil
+}
+
+type refNetworks struct {
+	network.UnimplementedNetworkServiceServer
+}
+
+func (refNetworks) Get(ctx context.Context, req *network.GetNetworkRequest) (*network.Network, error) {
+	return &network.Network{Id: req.NetworkId, ProjectId: "prj1"}, nil
+}
+
+func TestGetResource(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, refClusters{})
+		network.RegisterNetworkServiceServer(s, refNetworks{})
+	})
+	ctx := context.Background()
+
+	ref, err := sdk.ParseResourceRef("chc1")
+	require.NoError(t, err)
+	res, err := sdk.GetResource(ctx, ref)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&clickhouse.Cluster{Id: "chc1", ProjectId: "prj1"}, res))
+
+	ref, err = sdk.ParseResourceRef("https://app.double.cloud/projects/prj1/networks/net1")
+	require.NoError(t, err)
+	res, err = sdk.GetResource(ctx, ref)
+	require.NoError(t, err)
+	assert.Equal(t, "net1", res.(*network.Network).GetId())
+
+	_, err = sdk.GetResource(ctx, ResourceRef{Kind: ResourceNetwork, ProjectID: "prj2", ID: "net1"})
+	var projectErr *ResourceProjectError
+	require.ErrorAs(t, err, &projectErr)
+	assert.Equal(t, "prj1", projectErr.ProjectID)
+	assert.EqualError(t, err, `crn:doublecloud:vpc:prj2:network/net1 is in project "prj1"`)
+
+	_, err = sdk.GetResource(ctx, ResourceRef{Kind: "billing/account", ID: "b1"})
+	assert.EqualError(t, err, `get crn:doublecloud:billing::account/b1: unknown resource kind "billing/account"`)
+}
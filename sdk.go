@@ -4,8 +4,8 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
@@ -22,8 +23,11 @@ import (
 	"github.com/doublecloud/go-sdk/gen/transfer"
 	"github.com/doublecloud/go-sdk/gen/visualization"
 	"github.com/doublecloud/go-sdk/iamkey"
+	"github.com/doublecloud/go-sdk/internal/lifecycle"
 	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/clockskew"
 	"github.com/doublecloud/go-sdk/pkg/grpcclient"
+	"github.com/doublecloud/go-sdk/pkg/retry"
 	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
 	"github.com/google/uuid"
 	"golang.org/x/sync/singleflight"
@@ -32,6 +36,8 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -60,16 +66,119 @@ type Config struct {
 	// DialContextTimeout time.Duration
 	// TLSConfig is optional tls.Config that one can use in order to tune TLS options.
 	TLSConfig *tls.Config
+	// SecurityProfile restricts the crypto used by the TLS connections and credentials,
+	// see SecurityProfileStrict. Plaintext connections are not allowed by the strict profile.
+	SecurityProfile SecurityProfile
 
-	// Endpoint is an API endpoint of DoubleCloud against which the SDK is used.
-	// Most users won't need to explicitly set it.
+	// Endpoint is an API endpoint of DoubleCloud against which the SDK is used, normalized
+	// by Build, see NormalizeEndpoint. Most users won't need to explicitly set it.
 	Endpoint  string
 	Plaintext bool
+	// Environment is the environment of Endpoint, e.g. EnvironmentProduction. Build fails with
+	// *EnvironmentMismatchError for credentials of another environment, see WithEnvironment.
+	// If empty, it is detected from Endpoint by EnvironmentDetector.
+	Environment string
+	// EnvironmentDetector overrides DefaultEnvironmentDetector, e.g. for on-prem installs.
+	EnvironmentDetector EnvironmentDetector
+
+	// ReadCache enables caching of Get responses, see ReadCacheConfig.
+	ReadCache ReadCacheConfig
+	// NegativeCacheTTL, if positive, enables caching of the NotFound errors of Get calls for
+	// that long, e.g. against the lookups of resources just deleted, see ReadCacheConfig.
+	NegativeCacheTTL time.Duration
+	// Retry configures retries of calls failing with transient errors, see retry.Config.
+	// Retries are disabled by default. Operation polls are never retried by it unless
+	// the wait sets retry.Attempts.
+	Retry retry.Config
+	// DefaultLabels are merged into the labels of create and update requests that have them,
+	// see ContextWithLabels.
+	DefaultLabels map[string]string
+	// RequestMutators change or reject the requests before they are sent, in order, see
+	// RequestMutator. They run before the read cache and the DefaultLabels, whose labels
+	// don't replace the ones they set.
+	RequestMutators []RequestMutator
+	// OnWorkflowEnd, if set, is called with the stats of every workflow when it ends,
+	// see BeginWorkflow.
+	OnWorkflowEnd func(WorkflowStats)
+	// Metrics, if set, records every call sent to the API, e.g. *MetricsStats. The calls are
+	// labeled by service kind, method and code only, see CallMetric and ContextWithMetricLabels.
+	Metrics MetricsRecorder
+	// MetricLabelLimit bounds the distinct values of every label of ContextWithMetricLabels,
+	// DefaultMetricLabelLimit if not positive. MetricLabelOverflow sets what becomes of the
+	// values over the limit.
+	MetricLabelLimit    int
+	MetricLabelOverflow MetricLabelOverflow
+	// StatusFeedURL, if set, is the URL of the status feed of the provider, fetched in the
+	// background every StatusFeedInterval, else PollPolicy.Interval, else
+	// DefaultStatusFeedInterval. The failed calls to a service with an incident going on
+	// are annotated with it, see sdkerrors.IncidentInfo. Nothing is annotated while the
+	// feed can't be fetched.
+	StatusFeedURL      string
+	StatusFeedInterval time.Duration
+	// JSONEncoding are the protojson options of the JSON the SDK emits: SDK.MarshalProtoJSON
+	// and the operations of WrapOperation and ResumeOperation, see
+	// operation.Operation.WithJSONEncoding. The zero value means protojson defaults, with
+	// enums encoded as strings.
+	JSONEncoding protojson.MarshalOptions
+	// ConsoleURLs override the templates of DefaultConsoleURLs by service kind, e.g. for
+	// private deployments: "{project}" and "{resource}" are replaced by the IDs, see
+	// SDK.ConsoleURL. An empty template leaves the kind without console URL.
+	ConsoleURLs map[ServiceKind]string
+	// CredentialsRefresh configures the backoff of failed IAM token refreshes, see
+	// CredentialsRefreshConfig.
+	CredentialsRefresh CredentialsRefreshConfig
+	// Principals configures the principal lookup of ResolvePrincipals, see PrincipalConfig.
+	Principals PrincipalConfig
+	// MaxConnectionAge, if positive, is the maximum lifetime of the connections of the SDK,
+	// e.g. to pick up certificate rotations of the server: older connections are replaced
+	// by new ones at the next call, and closed once the calls in flight on them end, after
+	// ConnectionDrainTimeout at the latest, grpcclient.DefaultConnectionDrainTimeout if not
+	// positive. OnConnectionRecycle, if set, is called with the events of the recycles.
+	MaxConnectionAge       time.Duration
+	ConnectionDrainTimeout time.Duration
+	OnConnectionRecycle    func(grpcclient.RecycleEvent)
+	// DebugLeakDetection logs the iterators collected by the garbage collector without being
+	// exhausted or closed, with the stack that created them, e.g. to find the listings
+	// abandoned mid-way. It captures a stack per iterator, so it is meant for debugging,
+	// and applies to the whole process once an SDK is built with it.
+	DebugLeakDetection bool
+	// PollPolicy is the default polling policy of the SDK: the operations of the SDK wait
+	// with it, see operation.Operation.WithPollPolicy, and the status feed is fetched every
+	// PollPolicy.Interval unless StatusFeedInterval is set. SDK.PollPolicy returns it for
+	// the other pollers, e.g. paging.WithRetryPolicy. The options of a wait take precedence.
+	PollPolicy PollPolicy
+	// MaxConcurrentWaits, if positive, is a soft limit on the operation waits in progress:
+	// every time they go over it, an *operation.ConcurrentWaitsError is reported to the
+	// warning handler, see operation.RuntimeStats and operation.SetWarningHandler. Like
+	// DebugLeakDetection, it applies to the whole process once an SDK is built with it.
+	MaxConcurrentWaits int
+	// WaitJournal, if set, gets a JSON line per poll of the waits of the operations of the
+	// SDK, see operation.WaitJournal, e.g. an *operation.JournalFile rotated by size. The
+	// lines are written when a wait finishes and by Shutdown. The operation.WithWaitJournal
+	// option of a wait takes precedence.
+	WaitJournal io.Writer
+	// Logger, if set, logs the waits of the operations of the SDK, every line carrying the
+	// ID, the kind and the origin of the operation, see operation.Operation.WithLogger. The
+	// operations wrapped before a change keep their logger.
+	Logger operation.Logger
+	// WaitTelemetrySampling, if set, makes only a fraction of the waits of the operations of
+	// the SDK record their decisions to the WaitJournal, the Logger and the decision logs of
+	// the waits, while all of them keep their metrics, see operation.TelemetrySampling. The
+	// operation.WithTelemetrySampling option of a wait takes precedence.
+	WaitTelemetrySampling *operation.TelemetrySampling
+	// CheckpointStore, if set, stores the checkpoints of the tracked waits of GoWait, saved
+	// under TrackedWaitKeyPrefix and the name of the wait after their polls, at most once per
+	// CheckpointInterval, or operation.DefaultCheckpointInterval if it is not positive. See
+	// operation.WithCheckpointStore and ResumeCheckpoints.
+	CheckpointStore    operation.CheckpointStore
+	CheckpointInterval time.Duration
 }
 
 // SDK is a DoubleCloud SDK
 type SDK struct {
-	conf      Config
+	// snapshot is the current *configSnapshot, see UpdateConfig.
+	snapshot  atomic.Pointer[configSnapshot]
+	configMu  sync.Mutex
 	cc        grpcclient.ConnContext
 	endpoints struct {
 		initDone bool
@@ -80,55 +189,102 @@ type SDK struct {
 	initErr  error
 	initCall singleflight.Group
 	muErr    sync.Mutex
+
+	origins *operationOrigins
+	tasks   *backgroundTasks
+	tokens  *IamTokenMiddleware
+	cache   *readCache
+	// principals is the cache of ResolvePrincipals.
+	principals *principalCache
+
+	suspendables *suspendables
+	versions     *versionCache
+	skew         *clockskew.Estimator
+	metricLabels *labelGuard
+	// statusFeed is nil without Config.StatusFeedURL.
+	statusFeed *statusFeed
+	// journal is nil without Config.WaitJournal.
+	journal *operation.WaitJournal
 }
 
 // Build creates an SDK instance
 func Build(ctx context.Context, conf Config, customOpts ...grpc.DialOption) (*SDK, error) {
-	if conf.Credentials == nil {
-		return nil, errors.New("credentials required")
-	}
-
-	const defaultEndpoint = "api.double.cloud:443"
-	if conf.Endpoint == "" {
-		conf.Endpoint = defaultEndpoint
+	if err := conf.Validate(); err != nil {
+		return nil, err
 	}
 	const DefaultTimeout = 20 * time.Second
 
-	switch creds := conf.Credentials.(type) {
-	case ExchangeableCredentials, NonExchangeableCredentials:
-	default:
-		return nil, fmt.Errorf("unsupported credentials type %T", creds)
+	if err := prepareCredentials(&conf); err != nil {
+		return nil, err
 	}
 	sdk := &SDK{
-		cc:   nil, // Later
-		conf: conf,
+		cc:      nil, // Later
+		origins: newOperationOrigins(),
+		tasks:   newBackgroundTasks(),
+		cache:   newReadCache(conf.ReadCache, conf.NegativeCacheTTL),
+
+		principals:   newPrincipalCache(conf.Principals),
+		suspendables: newSuspendables(),
+		versions:     newVersionCache(),
+		skew:         clockskew.NewEstimator(),
+		metricLabels: newLabelGuard(conf.MetricLabelLimit, conf.MetricLabelOverflow),
 	}
-	tokenMiddleware := NewIAMTokenMiddleware(sdk, now)
+	sdk.snapshot.Store(newConfigSnapshot(conf, 0))
+	if conf.WaitJournal != nil {
+		sdk.journal = operation.NewWaitJournal(conf.WaitJournal)
+	}
+	if conf.StatusFeedURL != "" {
+		interval := conf.StatusFeedInterval
+		if interval <= 0 {
+			interval = conf.PollPolicy.Interval
+		}
+		sdk.statusFeed = newStatusFeed(conf.StatusFeedURL, interval)
+		sdk.tasks.goTask("status feed", sdk.statusFeed.run)
+	}
+	tokenMiddleware := NewIAMTokenMiddleware(sdk, now).WithRefreshBackoff(conf.CredentialsRefresh)
+	sdk.tokens = tokenMiddleware
 	var dialOpts []grpc.DialOption
 	dialOpts = append(dialOpts,
-		grpc.WithChainUnaryInterceptor(tokenMiddleware.InterceptUnary),
+		grpc.WithChainUnaryInterceptor(sdk.interceptConfig, sdk.interceptIncidents, sdk.interceptMutators, sdk.cache.InterceptUnary, sdk.origins.InterceptUnary, sdk.interceptLabels, sdk.interceptPreflight, sdk.interceptNameCheck, interceptWorkflowCalls, sdk.interceptMetrics, sdk.interceptRetry, interceptWorkflowAttempts, tokenMiddleware.InterceptUnary, sdk.interceptClockSkew),
 		grpc.WithChainStreamInterceptor(tokenMiddleware.InterceptStream),
 	)
 
 	if conf.Plaintext {
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	} else {
-		tlsConfig := conf.TLSConfig
-		if tlsConfig == nil {
-			tlsConfig = &tls.Config{}
-		}
-		creds := credentials.NewTLS(tlsConfig)
+		creds := credentials.NewTLS(conf.SecurityProfile.tlsConfig(conf.TLSConfig))
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
 	}
 	// Append custom options after default, to allow to customize dialer and etc.
 	dialOpts = append(dialOpts, customOpts...)
-	sdk.cc = grpcclient.NewLazyConnContext(grpcclient.DialOptions(dialOpts...))
+	sdk.cc = grpcclient.NewLazyConnContext(
+		grpcclient.DialOptions(dialOpts...),
+		grpcclient.MaxConnectionAge(conf.MaxConnectionAge, conf.ConnectionDrainTimeout),
+		grpcclient.OnRecycle(conf.OnConnectionRecycle),
+		grpcclient.Tasks(sdk.tasks.goTask),
+	)
+	if conf.DebugLeakDetection {
+		lifecycle.EnableLeakDetection(true)
+	}
+	if conf.MaxConcurrentWaits > 0 {
+		operation.SetMaxConcurrentWaits(conf.MaxConcurrentWaits)
+	}
 	return sdk, nil
 }
 
 // Shutdown shutdowns SDK and closes all open connections.
+// Background tasks of the SDK are cancelled and waited for until ctx is done.
 func (sdk *SDK) Shutdown(ctx context.Context) error {
-	return sdk.cc.Shutdown(ctx)
+	tasksErr := sdk.tasks.shutdown(ctx)
+	if sdk.journal != nil {
+		if err := sdk.journal.Flush(); err != nil && tasksErr == nil {
+			tasksErr = err
+		}
+	}
+	if err := sdk.cc.Shutdown(ctx); err != nil {
+		return err
+	}
+	return tasksErr
 }
 
 func (sdk *SDK) CheckEndpointConnection(ctx context.Context, endpoint Endpoint) error {
@@ -136,44 +292,82 @@ func (sdk *SDK) CheckEndpointConnection(ctx context.Context, endpoint Endpoint)
 	return err
 }
 
-// WrapOperation wraps operation proto message to handy structure
+// WrapOperation wraps operation proto message to handy structure.
+// Operations returned by SDK calls are stamped with the originating method and resource.
+// Waits of the operation refresh the SDK IAM token once if polls are rejected as unauthenticated.
 func (sdk *SDK) WrapOperation(o *dcv1.Operation, err error) (*operation.Operation, error) {
 	if err != nil {
 		return nil, err
 	}
-	if strings.HasPrefix(o.Id, operation.CLICKHOUSE_OPERATION_PREFIX) {
-		return operation.New(sdk.ClickHouse().Operation(), o), nil
+	client, err := sdk.operationClient(o.GetId())
+	if err != nil {
+		return nil, err
+	}
+	op := sdk.withOperationDefaults(operation.New(client, o))
+	if origin, ok := sdk.origins.take(o.GetId()); ok {
+		op.WithOrigin(origin.method, origin.resource)
+	}
+	return op, nil
+}
+
+// ResumeOperation returns the operation of the ID, e.g. one persisted before a restart, to
+// be waited like the operations of WrapOperation. Its state is unknown until it is polled,
+// see operation.NewFromID.
+func (sdk *SDK) ResumeOperation(id string) (*operation.Operation, error) {
+	client, err := sdk.operationClient(id)
+	if err != nil {
+		return nil, err
+	}
+	return sdk.withOperationDefaults(operation.NewFromID(client, id)), nil
+}
+
+func (sdk *SDK) withOperationDefaults(op *operation.Operation) *operation.Operation {
+	if s := sdk.config().WaitTelemetrySampling; s != nil {
+		op.WithTelemetrySampling(*s)
+	}
+	return op.WithCredentialsRefresher(sdk.tokens.Refresh).
+		WithCredentialsRotation(sdk.credentialsRotatedAt).
+		WithClockSkew(sdk.clockSkew).
+		WithJSONEncoding(sdk.config().JSONEncoding).
+		WithConsoleURL(sdk.consoleURLOf).
+		WithPollPolicy(sdk.config().PollPolicy).
+		WithWaitJournal(sdk.journal).
+		WithLogger(sdk.config().Logger)
+}
+
+// MarshalProtoJSON encodes msg with protojson and the options of Config.JSONEncoding. It is
+// not named MarshalJSON, which would make *SDK a broken json.Marshaler.
+func (sdk *SDK) MarshalProtoJSON(msg proto.Message) ([]byte, error) {
+	return sdk.config().JSONEncoding.Marshal(msg)
+}
+
+// operationClient returns the operation client of the service of the operation ID.
+func (sdk *SDK) operationClient(id string) (operation.Client, error) {
+	if strings.HasPrefix(id, operation.CLICKHOUSE_OPERATION_PREFIX) {
+		return sdk.ClickHouse().Operation(), nil
 	}
-	if strings.HasPrefix(o.Id, operation.KAFKA_OPERATION_PREFIX) {
-		return operation.New(sdk.Kafka().Operation(), o), nil
+	if strings.HasPrefix(id, operation.KAFKA_OPERATION_PREFIX) {
+		return sdk.Kafka().Operation(), nil
 	}
-	if strings.HasPrefix(o.Id, operation.TRANSFER_ENDPOINTS_OPERATION_PREFIX) || strings.HasPrefix(o.Id, operation.TRANSFER_OPERATION_PREFIX) {
-		return operation.New(sdk.Transfer().Operation(), o), nil
+	if strings.HasPrefix(id, operation.TRANSFER_ENDPOINTS_OPERATION_PREFIX) || strings.HasPrefix(id, operation.TRANSFER_OPERATION_PREFIX) {
+		return sdk.Transfer().Operation(), nil
 	}
-	if _, err := uuid.Parse(o.Id); err == nil {
-		return operation.New(sdk.Network().Operation(), o), nil
+	if _, err := uuid.Parse(id); err == nil {
+		return sdk.Network().Operation(), nil
 	}
-	return nil, sdkerrors.WithMessage(nil, "Unknown operation type")
+	return nil, fmt.Errorf("unknown operation type of %q", id)
 }
 
 func (sdk *SDK) getConn(serviceID Endpoint) func(ctx context.Context) (*grpc.ClientConn, error) {
 	return func(ctx context.Context) (*grpc.ClientConn, error) {
-		if !sdk.initDone() {
-			sdk.initCall.Do("init", func() (any, error) {
-				sdk.muErr.Lock()
-				sdk.initErr = sdk.initConns(ctx)
-				sdk.muErr.Unlock()
-				return nil, nil
-			})
-			if err := sdk.InitErr(); err != nil {
-				return nil, err
-			}
+		if err := sdk.ensureInit(ctx); err != nil {
+			return nil, err
 		}
 		endpoint, endpointExist := sdk.Endpoint(serviceID)
 		if !endpointExist {
 			return nil, &ServiceIsNotAvailableError{
 				ServiceID:           serviceID,
-				APIEndpoint:         sdk.conf.Endpoint,
+				APIEndpoint:         sdk.config().Endpoint,
 				availableServiceIDs: sdk.KnownServices(),
 			}
 		}
@@ -181,6 +375,20 @@ func (sdk *SDK) getConn(serviceID Endpoint) func(ctx context.Context) (*grpc.Cli
 	}
 }
 
+// ensureInit initializes the endpoints of the services once.
+func (sdk *SDK) ensureInit(ctx context.Context) error {
+	if sdk.initDone() {
+		return nil
+	}
+	sdk.initCall.Do("init", func() (any, error) {
+		sdk.muErr.Lock()
+		sdk.initErr = sdk.initConns(ctx)
+		sdk.muErr.Unlock()
+		return nil, nil
+	})
+	return sdk.InitErr()
+}
+
 type ServiceIsNotAvailableError struct {
 	ServiceID           Endpoint
 	APIEndpoint         string
@@ -228,9 +436,11 @@ func (sdk *SDK) InitErr() error {
 	return sdk.initErr
 }
 
+var serviceIDs = []Endpoint{ClickHouseServiceID, KafkaServiceID, VpcServiceID, TransferServiceID, VisualizationServiceID}
+
 func endpointsMap() map[Endpoint]*APIEndpoint {
 	m := make(map[Endpoint]*APIEndpoint)
-	for _, v := range []Endpoint{ClickHouseServiceID, KafkaServiceID, VpcServiceID, TransferServiceID, VisualizationServiceID} {
+	for _, v := range serviceIDs {
 		m[v] = &APIEndpoint{
 			Id:      v,
 			Address: fmt.Sprintf("%v.api.double.cloud:443", v),
@@ -315,7 +525,21 @@ func exchangeJWT2IAM(ctx context.Context, request *iamkey.CreateIamTokenRequest)
 }
 
 func (sdk *SDK) CreateIAMToken(ctx context.Context) (*iamkey.CreateIamTokenResponse, error) {
-	creds := sdk.conf.Credentials
+	return createIAMToken(ctx, sdk.callConfig(ctx).Credentials)
+}
+
+// createIAMTokenWith creates a token of credentials of ContextWithCredentials, prepared
+// like Config.Credentials.
+func (sdk *SDK) createIAMTokenWith(ctx context.Context, creds Credentials) (*iamkey.CreateIamTokenResponse, error) {
+	conf := sdk.callConfig(ctx).Config
+	conf.Credentials = creds
+	if err := prepareCredentials(&conf); err != nil {
+		return nil, sdkerrors.WithMessage(err, "context credentials")
+	}
+	return createIAMToken(ctx, conf.Credentials)
+}
+
+func createIAMToken(ctx context.Context, creds Credentials) (*iamkey.CreateIamTokenResponse, error) {
 	switch creds := creds.(type) {
 	case ExchangeableCredentials:
 		req, err := creds.IAMTokenRequest()
@@ -0,0 +1,294 @@
+package dcsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/retry"
+)
+
+// newTestSDK builds an SDK talking to an in-memory gRPC server with the given services registered.
+func newTestSDK(t *testing.T, register func(s *grpc.Server)) *SDK {
+	return newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token")}, register)
+}
+
+func newTestSDKWithConfig(t *testing.T, conf Config, register func(s *grpc.Server)) *SDK {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conf.Plaintext = true
+	sdk, err := Build(context.Background(), conf, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sdk.Shutdown(context.Background()) })
+	return sdk
+}
+
+type fakeClickHouseClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+}
+
+func (fakeClickHouseClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	return &dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+type fakeClickHouseOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+}
+
+func (fakeClickHouseOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	return &dcv1.Operation{
+		Id:     req.OperationId,
+		Status: dcv1.Operation_STATUS_DONE,
+		Error:  &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"},
+	}, nil
+}
+
+func TestWrapOperation_Origin(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, fakeClickHouseClusters{})
+		clickhouse.RegisterOperationServiceServer(s, fakeClickHouseOperations{})
+	})
+	ctx := context.Background()
+
+	op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{Name: "my-cluster"}))
+	require.NoError(t, err)
+	method, resource := op.Origin()
+	assert.Equal(t, "/doublecloud.clickhouse.v1.ClusterService/Create", method)
+	assert.Equal(t, "my-cluster", resource)
+	assert.Contains(t, op.String(), "origin=/doublecloud.clickhouse.v1.ClusterService/Create")
+
+	err = op.Wait(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "origin=/doublecloud.clickhouse.v1.ClusterService/Create, resource=my-cluster")
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestWrapOperation_NoOrigin(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho2"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "operation (id=cho2)", op.String())
+}
+
+func TestResumeOperation(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterOperationServiceServer(s, fakeClickHouseOperations{})
+	})
+	op, err := sdk.ResumeOperation("cho3")
+	require.NoError(t, err)
+	assert.False(t, op.Done())
+
+	err = op.Wait(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "origin=resumed")
+	assert.Contains(t, err.Error(), "boom")
+
+	_, err = sdk.ResumeOperation("bogus")
+	assert.Error(t, err)
+}
+
+func TestMarshalProtoJSON(t *testing.T) {
+	state := &dcv1.Operation{Id: "cho4", Status: dcv1.Operation_STATUS_DONE, ResourceId: "chcl1"}
+
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	data, err := sdk.MarshalProtoJSON(state)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "cho4", "status": "STATUS_DONE", "resourceId": "chcl1"}`, string(data), "protojson defaults with string enums")
+
+	conf := Config{Credentials: NewIAMTokenCredentials("test-token"), JSONEncoding: protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true}}
+	sdk = newTestSDKWithConfig(t, conf, func(s *grpc.Server) {})
+	data, err = sdk.MarshalProtoJSON(state)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "cho4", "status": 3, "resource_id": "chcl1"}`, string(data))
+
+	op, err := sdk.WrapOperation(state, nil)
+	require.NoError(t, err)
+	data, err = json.Marshal(op)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version": 1, "operation": {"id": "cho4", "status": 3, "resource_id": "chcl1"}}`, string(data), "operations are encoded with the options too")
+	var back operation.Operation
+	require.NoError(t, json.Unmarshal(data, &back))
+	assert.True(t, proto.Equal(state, back.Proto()))
+
+	err = sdk.UpdateConfig(func(c *MutableConfig) { c.JSONEncoding.Multiline = true })
+	assert.ErrorIs(t, err, ErrImmutableConfig)
+}
+
+// missingOperations never finds operations, counting the polls.
+type missingOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	polls int32
+}
+
+func (s *missingOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	atomic.AddInt32(&s.polls, 1)
+	return nil, status.Error(codes.NotFound, "operation not found")
+}
+
+func TestWait_RetryBudget(t *testing.T) {
+	srv := &missingOperations{}
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials: NewIAMTokenCredentials("test-token"),
+		Retry:       retry.Config{MaxAttempts: 3, Codes: []codes.Code{codes.NotFound}, Backoff: time.Millisecond},
+	}, func(s *grpc.Server) { clickhouse.RegisterOperationServiceServer(s, srv) })
+	ctx := context.Background()
+	wait := func(opts ...grpc.CallOption) error {
+		op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+		require.NoError(t, err)
+		return op.WaitInterval(ctx, time.Millisecond, opts...)
+	}
+
+	// Without the shared budget, every tolerated poll failure would be retried 3 times: 12 polls.
+	assert.Equal(t, codes.NotFound, status.Code(wait()))
+	assert.EqualValues(t, 4, atomic.SwapInt32(&srv.polls, 0), "polls are not retried by the interceptor")
+
+	assert.Equal(t, codes.NotFound, status.Code(wait(retry.Attempts(3))))
+	assert.EqualValues(t, 4, atomic.SwapInt32(&srv.polls, 0), "interceptor retries debit the wait budget")
+
+	budget := retry.NewBudget(1)
+	ctx = retry.WithBudget(ctx, budget)
+	assert.Equal(t, codes.NotFound, status.Code(wait()))
+	assert.EqualValues(t, 2, atomic.SwapInt32(&srv.polls, 0), "the budget of ctx is used")
+	assert.Equal(t, 0, budget.Remaining())
+
+	_, err := sdk.ClickHouse().Operation().Get(context.Background(), &clickhouse.GetOperationRequest{OperationId: "cho1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.EqualValues(t, 3, atomic.SwapInt32(&srv.polls, 0), "other calls are retried")
+}
+
+// attemptClusters records the attempt metadata of the create requests. The first request
+// fails with Unavailable, the operation of the second one ends resubmittable.
+type attemptClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	mu       sync.Mutex
+	requests []metadata.MD
+	polls    []metadata.MD
+}
+
+func (s *attemptClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.requests = append(s.requests, md)
+	if len(s.requests) == 1 {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &dcv1.Operation{Id: fmt.Sprintf("cho%d", len(s.requests)-1), Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+type attemptOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	*attemptClusters
+}
+
+func (s attemptOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.polls = append(s.polls, md)
+	if req.OperationId != "cho1" {
+		return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_DONE}, nil
+	}
+	st, err := status.New(codes.ResourceExhausted, "no capacity").WithDetails(&errdetails.ErrorInfo{Reason: "TEST_ATTEMPT_CAPACITY"})
+	if err != nil {
+		return nil, err
+	}
+	return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_INVALID, Error: st.Proto()}, nil
+}
+
+func TestCreateWithResubmit_AttemptMetadata(t *testing.T) {
+	require.NoError(t, operation.RegisterResubmittable(operation.KindClickHouse, "TEST_ATTEMPT_CAPACITY"))
+	srv := &attemptClusters{}
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials: NewIAMTokenCredentials("test-token"),
+		Retry:       retry.Config{MaxAttempts: 2, Backoff: time.Millisecond},
+	}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, srv)
+		clickhouse.RegisterOperationServiceServer(s, attemptOperations{attemptClusters: srv})
+	})
+	policy := operation.ResubmitPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	res, err := sdk.ClickHouse().Cluster().CreateWithResubmit(context.Background(), &clickhouse.CreateClusterRequest{Name: "c"}, policy, operation.WithBusyPoll())
+	require.NoError(t, err)
+	require.Len(t, res.Operations, 2)
+
+	// The retry of the interceptor is the attempt 2, the resubmission the attempt 3.
+	require.Len(t, srv.requests, 3)
+	assert.Empty(t, srv.requests[0].Get(retry.AttemptMetadataKey))
+	var originals []string
+	for i, md := range srv.requests[1:] {
+		assert.Equal(t, []string{strconv.Itoa(i + 2)}, md.Get(retry.AttemptMetadataKey))
+		originals = append(originals, md.Get(retry.OriginalRequestTimeMetadataKey)...)
+	}
+	require.Len(t, originals, 2)
+	assert.Equal(t, originals[0], originals[1], "the time of the first request")
+	for _, md := range srv.polls {
+		assert.Empty(t, md.Get(retry.AttemptMetadataKey), "the polls are not numbered")
+	}
+}
+
+// hintedOperations records the project hint, field 2 unknown to this API version, of Get requests.
+type hintedOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	hint string
+}
+
+func (s *hintedOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	b := req.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		if num == 2 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			s.hint, b = v, b[n:]
+			continue
+		}
+		b = b[protowire.ConsumeFieldValue(num, typ, b):]
+	}
+	return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_DONE}, nil
+}
+
+func TestWait_RequestDecorator(t *testing.T) {
+	srv := &hintedOperations{}
+	sdk := newTestSDK(t, func(s *grpc.Server) { clickhouse.RegisterOperationServiceServer(s, srv) })
+	require.NoError(t, operation.SetRequestDecorator(operation.KindClickHouse, func(id string) proto.Message {
+		req := &clickhouse.GetOperationRequest{OperationId: id}
+		req.ProtoReflect().SetUnknown(protowire.AppendString(protowire.AppendTag(nil, 2, protowire.BytesType), "prj1"))
+		return req
+	}))
+	t.Cleanup(func() { _ = operation.SetRequestDecorator(operation.KindClickHouse, nil) })
+
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(context.Background()))
+	assert.Equal(t, "prj1", srv.hint)
+}
@@ -0,0 +1,120 @@
+package sdktest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// ClickHouse fakes the ClickHouse cluster and operation services.
+type ClickHouse struct {
+	clickhouse.UnimplementedClusterServiceServer
+
+	ops *operations
+
+	mu       sync.Mutex
+	clusters map[string]*clickhouse.Cluster
+}
+
+func newClickHouse() *ClickHouse {
+	return &ClickHouse{ops: newOperations(), clusters: map[string]*clickhouse.Cluster{}}
+}
+
+// Cluster returns a copy of the cluster, nil if it doesn't exist.
+func (f *ClickHouse) Cluster(id string) *clickhouse.Cluster {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clusters[id]; ok {
+		return proto.Clone(c).(*clickhouse.Cluster)
+	}
+	return nil
+}
+
+func (f *ClickHouse) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	if req.GetProjectId() == "" || req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "project_id and name are required")
+	}
+	id := f.ops.nextID("chc")
+	f.mu.Lock()
+	f.clusters[id] = &clickhouse.Cluster{
+		Id:          id,
+		ProjectId:   req.GetProjectId(),
+		CloudType:   req.GetCloudType(),
+		RegionId:    req.GetRegionId(),
+		CreateTime:  timestamppb.Now(),
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Status:      dcv1.ClusterStatus_CLUSTER_STATUS_CREATING,
+		Version:     req.GetVersion(),
+		Resources:   req.GetResources(),
+		NetworkId:   req.GetNetworkId(),
+	}
+	f.mu.Unlock()
+	return f.ops.start(operation.CLICKHOUSE_OPERATION_PREFIX, req.GetProjectId(), id, "Create cluster", nil, func() error {
+		return f.setStatus(id, dcv1.ClusterStatus_CLUSTER_STATUS_ALIVE)
+	}), nil
+}
+
+func (f *ClickHouse) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	if c := f.Cluster(req.GetClusterId()); c != nil {
+		return c, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "cluster %s not found", req.GetClusterId())
+}
+
+func (f *ClickHouse) List(ctx context.Context, req *clickhouse.ListClustersRequest) (*clickhouse.ListClustersResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &clickhouse.ListClustersResponse{}
+	for _, c := range f.clusters {
+		if c.ProjectId == req.GetProjectId() {
+			resp.Clusters = append(resp.Clusters, proto.Clone(c).(*clickhouse.Cluster))
+		}
+	}
+	return resp, nil
+}
+
+func (f *ClickHouse) Delete(ctx context.Context, req *clickhouse.DeleteClusterRequest) (*dcv1.Operation, error) {
+	c, err := f.Get(ctx, &clickhouse.GetClusterRequest{ClusterId: req.GetClusterId()})
+	if err != nil {
+		return nil, err
+	}
+	return f.ops.start(operation.CLICKHOUSE_OPERATION_PREFIX, c.ProjectId, c.Id, "Delete cluster", nil, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.clusters, c.Id)
+		return nil
+	}), nil
+}
+
+func (f *ClickHouse) setStatus(id string, st dcv1.ClusterStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.clusters[id]
+	if !ok {
+		return status.Errorf(codes.NotFound, "cluster %s not found", id)
+	}
+	c.Status = st
+	return nil
+}
+
+type clickHouseOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	ops *operations
+}
+
+func (s *clickHouseOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	return s.ops.get(req.GetOperationId())
+}
+
+func (s *clickHouseOperations) List(ctx context.Context, req *clickhouse.ListOperationsRequest) (*clickhouse.ListOperationsResponse, error) {
+	return &clickhouse.ListOperationsResponse{Operations: s.ops.list(req.GetProjectId())}, nil
+}
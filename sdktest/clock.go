@@ -0,0 +1,106 @@
+package sdktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// Clock is an operation.Clock whose time only moves with Advance, to test waits without
+// sleeping, see operation.WithClock:
+//
+//	clock := sdktest.NewClock(time.Now())
+//	go func() { errc <- op.Wait(ctx, operation.WithClock(clock)) }()
+//	clock.BlockUntil(1) // the wait sleeps before its next poll
+//	clock.Advance(operation.DefaultPollInterval)
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*clockTimer
+}
+
+var _ operation.Clock = (*Clock)(nil)
+
+// NewClock returns a clock set to now.
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer firing once the clock is advanced by d. Timers of at most 0 fire
+// right away.
+func (c *Clock) NewTimer(d time.Duration) operation.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &clockTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock by d, firing the timers due by then.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// Timers returns the number of timers waiting to fire.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until n timers are waiting to fire, e.g. until a wait sleeps before its
+// next poll.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+type clockTimer struct {
+	clock *Clock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *clockTimer) C() <-chan time.Time { return t.c }
+
+func (t *clockTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package sdktest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+func TestClock_Wait(t *testing.T) {
+	clock := NewClock(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	var polls atomic.Int32
+	poll := func(ctx context.Context, id string) (*operation.Proto, time.Duration, error) {
+		op := &operation.Proto{Id: id, Status: dcv1.Operation_STATUS_RUNNING}
+		if polls.Add(1) == 3 {
+			op.Status = dcv1.Operation_STATUS_DONE
+		}
+		return op, 0, nil
+	}
+	op := operation.New(nil, &operation.Proto{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- op.Wait(context.Background(), operation.WithPollFunc(poll), operation.WithClock(clock))
+	}()
+
+	for i := 1; i <= 2; i++ {
+		clock.BlockUntil(1)
+		assert.Equal(t, int32(i), polls.Load(), "no poll until the clock is advanced")
+		clock.Advance(operation.DefaultPollInterval / 2)
+		assert.Equal(t, 1, clock.Timers(), "the timer fires after the whole interval")
+		clock.Advance(operation.DefaultPollInterval / 2)
+	}
+	require.NoError(t, <-errc)
+	assert.Equal(t, int32(3), polls.Load())
+	assert.Equal(t, time.Date(2023, 5, 1, 12, 0, 2, 0, time.UTC), clock.Now())
+}
+
+func TestClock_Timer(t *testing.T) {
+	clock := NewClock(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	stopped := clock.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	timer := clock.NewTimer(time.Second)
+	clock.Advance(time.Second)
+	assert.Equal(t, clock.Now(), <-timer.C())
+	assert.False(t, timer.Stop(), "already fired")
+
+	select {
+	case <-clock.NewTimer(0).C():
+	default:
+		t.Fatal("timers of at most 0 fire right away")
+	}
+}
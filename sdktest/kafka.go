@@ -0,0 +1,232 @@
+package sdktest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/operation/opmeta"
+)
+
+// Kafka fakes the Kafka cluster, topic and operation services.
+type Kafka struct {
+	kafka.UnimplementedClusterServiceServer
+
+	ops *operations
+
+	mu       sync.Mutex
+	clusters map[string]*kafka.Cluster
+	topics   map[string]map[string]*kafka.Topic // by cluster ID and name
+}
+
+func newKafka() *Kafka {
+	return &Kafka{
+		ops:      newOperations(),
+		clusters: map[string]*kafka.Cluster{},
+		topics:   map[string]map[string]*kafka.Topic{},
+	}
+}
+
+// Cluster returns a copy of the cluster, nil if it doesn't exist.
+func (f *Kafka) Cluster(id string) *kafka.Cluster {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clusters[id]; ok {
+		return proto.Clone(c).(*kafka.Cluster)
+	}
+	return nil
+}
+
+// Topic returns a copy of the topic, nil if it doesn't exist.
+func (f *Kafka) Topic(clusterID, name string) *kafka.Topic {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.topics[clusterID][name]; ok {
+		return proto.Clone(t).(*kafka.Topic)
+	}
+	return nil
+}
+
+func (f *Kafka) Create(ctx context.Context, req *kafka.CreateClusterRequest) (*dcv1.Operation, error) {
+	if req.GetProjectId() == "" || req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "project_id and name are required")
+	}
+	id := f.ops.nextID("kfc")
+	f.mu.Lock()
+	f.clusters[id] = &kafka.Cluster{
+		Id:          id,
+		ProjectId:   req.GetProjectId(),
+		CloudType:   req.GetCloudType(),
+		RegionId:    req.GetRegionId(),
+		CreateTime:  timestamppb.Now(),
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Status:      dcv1.ClusterStatus_CLUSTER_STATUS_CREATING,
+		Version:     req.GetVersion(),
+		Resources:   req.GetResources(),
+		NetworkId:   req.GetNetworkId(),
+	}
+	f.topics[id] = map[string]*kafka.Topic{}
+	f.mu.Unlock()
+	return f.ops.start(operation.KAFKA_OPERATION_PREFIX, req.GetProjectId(), id, "Create cluster", nil, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		c, ok := f.clusters[id]
+		if !ok {
+			return status.Errorf(codes.NotFound, "cluster %s not found", id)
+		}
+		c.Status = dcv1.ClusterStatus_CLUSTER_STATUS_ALIVE
+		return nil
+	}), nil
+}
+
+func (f *Kafka) Get(ctx context.Context, req *kafka.GetClusterRequest) (*kafka.Cluster, error) {
+	if c := f.Cluster(req.GetClusterId()); c != nil {
+		return c, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "cluster %s not found", req.GetClusterId())
+}
+
+func (f *Kafka) List(ctx context.Context, req *kafka.ListClustersRequest) (*kafka.ListClustersResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &kafka.ListClustersResponse{}
+	for _, c := range f.clusters {
+		if c.ProjectId == req.GetProjectId() {
+			resp.Clusters = append(resp.Clusters, proto.Clone(c).(*kafka.Cluster))
+		}
+	}
+	return resp, nil
+}
+
+func (f *Kafka) Delete(ctx context.Context, req *kafka.DeleteClusterRequest) (*dcv1.Operation, error) {
+	c, err := f.Get(ctx, &kafka.GetClusterRequest{ClusterId: req.GetClusterId()})
+	if err != nil {
+		return nil, err
+	}
+	return f.ops.start(operation.KAFKA_OPERATION_PREFIX, c.ProjectId, c.Id, "Delete cluster", nil, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.clusters, c.Id)
+		delete(f.topics, c.Id)
+		return nil
+	}), nil
+}
+
+// startTopicOp starts an operation on a topic of an existing cluster.
+func (f *Kafka) startTopicOp(clusterID, name, description string, apply func() error) (*dcv1.Operation, error) {
+	c := f.Cluster(clusterID)
+	if c == nil {
+		return nil, status.Errorf(codes.NotFound, "cluster %s not found", clusterID)
+	}
+	metadata := map[string]string{opmeta.KafkaClusterIDKey: clusterID, opmeta.KafkaTopicNameKey: name}
+	return f.ops.start(operation.KAFKA_OPERATION_PREFIX, c.ProjectId, clusterID, description, metadata, apply), nil
+}
+
+func topicFromSpec(clusterID string, spec *kafka.TopicSpec) *kafka.Topic {
+	t := &kafka.Topic{
+		Name:              spec.GetName(),
+		ClusterId:         clusterID,
+		Partitions:        spec.GetPartitions(),
+		ReplicationFactor: spec.GetReplicationFactor(),
+	}
+	switch c := spec.GetTopicConfig().(type) {
+	case *kafka.TopicSpec_TopicConfig_2_8:
+		t.TopicConfig = &kafka.Topic_TopicConfig_2_8{TopicConfig_2_8: c.TopicConfig_2_8}
+	case *kafka.TopicSpec_TopicConfig_3:
+		t.TopicConfig = &kafka.Topic_TopicConfig_3{TopicConfig_3: c.TopicConfig_3}
+	}
+	return t
+}
+
+type kafkaTopics struct {
+	kafka.UnimplementedTopicServiceServer
+	f *Kafka
+}
+
+func (s *kafkaTopics) Get(ctx context.Context, req *kafka.GetTopicRequest) (*kafka.Topic, error) {
+	if t := s.f.Topic(req.GetClusterId(), req.GetTopicName()); t != nil {
+		return t, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "topic %s not found in cluster %s", req.GetTopicName(), req.GetClusterId())
+}
+
+func (s *kafkaTopics) List(ctx context.Context, req *kafka.ListTopicsRequest) (*kafka.ListTopicsResponse, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	resp := &kafka.ListTopicsResponse{}
+	for _, t := range s.f.topics[req.GetClusterId()] {
+		resp.Topics = append(resp.Topics, proto.Clone(t).(*kafka.Topic))
+	}
+	return resp, nil
+}
+
+func (s *kafkaTopics) Create(ctx context.Context, req *kafka.CreateTopicRequest) (*dcv1.Operation, error) {
+	name := req.GetTopicSpec().GetName()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "topic name is required")
+	}
+	if s.f.Topic(req.GetClusterId(), name) != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "topic %s already exists", name)
+	}
+	t := topicFromSpec(req.GetClusterId(), req.GetTopicSpec())
+	return s.f.startTopicOp(req.GetClusterId(), name, "Create topic", func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		topics, ok := s.f.topics[t.ClusterId]
+		if !ok {
+			return status.Errorf(codes.NotFound, "cluster %s not found", t.ClusterId)
+		}
+		topics[t.Name] = t
+		return nil
+	})
+}
+
+func (s *kafkaTopics) Update(ctx context.Context, req *kafka.UpdateTopicRequest) (*dcv1.Operation, error) {
+	if _, err := s.Get(ctx, &kafka.GetTopicRequest{ClusterId: req.GetClusterId(), TopicName: req.GetTopicName()}); err != nil {
+		return nil, err
+	}
+	t := topicFromSpec(req.GetClusterId(), req.GetTopicSpec())
+	t.Name = req.GetTopicName()
+	return s.f.startTopicOp(req.GetClusterId(), t.Name, "Update topic", func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		if _, ok := s.f.topics[t.ClusterId][t.Name]; !ok {
+			return status.Errorf(codes.NotFound, "topic %s not found", t.Name)
+		}
+		s.f.topics[t.ClusterId][t.Name] = t
+		return nil
+	})
+}
+
+func (s *kafkaTopics) Delete(ctx context.Context, req *kafka.DeleteTopicRequest) (*dcv1.Operation, error) {
+	if _, err := s.Get(ctx, &kafka.GetTopicRequest{ClusterId: req.GetClusterId(), TopicName: req.GetTopicName()}); err != nil {
+		return nil, err
+	}
+	return s.f.startTopicOp(req.GetClusterId(), req.GetTopicName(), "Delete topic", func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		delete(s.f.topics[req.GetClusterId()], req.GetTopicName())
+		return nil
+	})
+}
+
+type kafkaOperations struct {
+	kafka.UnimplementedOperationServiceServer
+	ops *operations
+}
+
+func (s *kafkaOperations) Get(ctx context.Context, req *kafka.GetOperationRequest) (*dcv1.Operation, error) {
+	return s.ops.get(req.GetOperationId())
+}
+
+func (s *kafkaOperations) List(ctx context.Context, req *kafka.ListOperationsRequest) (*kafka.ListOperationsResponse, error) {
+	return &kafka.ListOperationsResponse{Operations: s.ops.list(req.GetProjectId())}, nil
+}
@@ -0,0 +1,97 @@
+package sdktest
+
+import (
+	"fmt"
+	"sync"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// operations stores operations of a single fake service. An operation completes on its first
+// poll: the change it makes is applied and the operation becomes DONE, failed if apply failed.
+type operations struct {
+	mu    sync.Mutex
+	seq   int
+	byID  map[string]*fakeOperation
+	order []string
+}
+
+type fakeOperation struct {
+	proto *dcv1.Operation
+	apply func() error
+}
+
+func newOperations() *operations {
+	return &operations{byID: map[string]*fakeOperation{}}
+}
+
+// start registers a pending operation with the ID of the given prefix.
+func (o *operations) start(prefix, projectID, resourceID, description string, metadata map[string]string, apply func() error) *dcv1.Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	p := &dcv1.Operation{
+		Id:          fmt.Sprintf("%s%d", prefix, o.seq),
+		ProjectId:   projectID,
+		ResourceId:  resourceID,
+		Description: description,
+		Metadata:    metadata,
+		Status:      dcv1.Operation_STATUS_PENDING,
+		CreateTime:  timestamppb.Now(),
+	}
+	o.byID[p.Id] = &fakeOperation{proto: p, apply: apply}
+	o.order = append(o.order, p.Id)
+	return proto.Clone(p).(*dcv1.Operation)
+}
+
+func (o *operations) get(id string) (*dcv1.Operation, error) {
+	o.mu.Lock()
+	op, ok := o.byID[id]
+	if !ok {
+		o.mu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "operation %s not found", id)
+	}
+	apply := op.apply
+	op.apply = nil
+	o.mu.Unlock()
+
+	var err error
+	if apply != nil {
+		err = apply()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if op.proto.Status != dcv1.Operation_STATUS_DONE {
+		op.proto.Status = dcv1.Operation_STATUS_DONE
+		op.proto.FinishTime = timestamppb.Now()
+		if err != nil {
+			op.proto.Error = status.Convert(err).Proto()
+		}
+	}
+	return proto.Clone(op.proto).(*dcv1.Operation), nil
+}
+
+func (o *operations) list(projectID string) []*dcv1.Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var result []*dcv1.Operation
+	for _, id := range o.order {
+		if p := o.byID[id].proto; projectID == "" || p.ProjectId == projectID {
+			result = append(result, proto.Clone(p).(*dcv1.Operation))
+		}
+	}
+	return result
+}
+
+// nextID returns a new resource ID with the prefix.
+func (o *operations) nextID(prefix string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	return fmt.Sprintf("%s%d", prefix, o.seq)
+}
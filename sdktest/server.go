@@ -0,0 +1,88 @@
+// Package sdktest provides in-memory fakes of DoubleCloud services for tests of code using the SDK.
+//
+// The fakes keep resources in memory and make changes through operations, like the real services.
+// An operation completes on its first poll, so operation.Wait returns without sleeping:
+//
+//	srv := sdktest.New(t)
+//	sdk := srv.SDK(t)
+//	op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(ctx, req))
+//	err = op.Wait(ctx)
+//	cluster := srv.ClickHouse.Cluster(op.ResourceId())
+package sdktest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	dcsdk "github.com/doublecloud/go-sdk"
+)
+
+// Server serves the fake services over an in-memory connection.
+type Server struct {
+	ClickHouse *ClickHouse
+	Kafka      *Kafka
+	Transfer   *Transfer
+
+	lis *bufconn.Listener
+	srv *grpc.Server
+}
+
+// NewServer starts a server with empty fakes. Stop it with Close.
+func NewServer() *Server {
+	s := &Server{
+		ClickHouse: newClickHouse(),
+		Kafka:      newKafka(),
+		Transfer:   newTransfer(),
+		lis:        bufconn.Listen(1 << 20),
+		srv:        grpc.NewServer(),
+	}
+	clickhouse.RegisterClusterServiceServer(s.srv, s.ClickHouse)
+	clickhouse.RegisterOperationServiceServer(s.srv, &clickHouseOperations{ops: s.ClickHouse.ops})
+	kafka.RegisterClusterServiceServer(s.srv, s.Kafka)
+	kafka.RegisterTopicServiceServer(s.srv, &kafkaTopics{f: s.Kafka})
+	kafka.RegisterOperationServiceServer(s.srv, &kafkaOperations{ops: s.Kafka.ops})
+	transfer.RegisterEndpointServiceServer(s.srv, &transferEndpoints{f: s.Transfer})
+	transfer.RegisterTransferServiceServer(s.srv, s.Transfer)
+	transfer.RegisterOperationServiceServer(s.srv, &transferOperations{ops: s.Transfer.ops})
+	go func() { _ = s.srv.Serve(s.lis) }()
+	return s
+}
+
+// New starts a server stopped at the end of the test.
+func New(t testing.TB) *Server {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Stop()
+}
+
+// Build builds an SDK talking to the fakes.
+func (s *Server) Build(ctx context.Context, opts ...grpc.DialOption) (*dcsdk.SDK, error) {
+	opts = append([]grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return s.lis.DialContext(ctx)
+	})}, opts...)
+	return dcsdk.Build(ctx, dcsdk.Config{
+		Credentials: dcsdk.NewIAMTokenCredentials("sdktest"),
+		Plaintext:   true,
+	}, opts...)
+}
+
+// SDK builds an SDK talking to the fakes and shut down at the end of the test.
+func (s *Server) SDK(t testing.TB) *dcsdk.SDK {
+	sdk, err := s.Build(context.Background())
+	if err != nil {
+		t.Fatalf("sdktest: build sdk: %v", err)
+	}
+	t.Cleanup(func() { _ = sdk.Shutdown(context.Background()) })
+	return sdk
+}
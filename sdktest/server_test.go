@@ -0,0 +1,54 @@
+package sdktest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_OperationCompletesOnFirstPoll(t *testing.T) {
+	srv := New(t)
+	sdk := srv.SDK(t)
+	ctx := context.Background()
+
+	op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(ctx, &clickhouse.CreateClusterRequest{ProjectId: "p", Name: "c"}))
+	require.NoError(t, err)
+	assert.False(t, op.Done())
+	require.NoError(t, op.Wait(ctx))
+
+	ops, err := sdk.ClickHouse().Operation().List(ctx, &clickhouse.ListOperationsRequest{ProjectId: "p"})
+	require.NoError(t, err)
+	require.Len(t, ops.Operations, 1)
+	assert.Equal(t, op.Id(), ops.Operations[0].Id)
+}
+
+func TestServer_FailedChangeFailsOperation(t *testing.T) {
+	srv := New(t)
+	sdk := srv.SDK(t)
+	ctx := context.Background()
+
+	createEndpoint := func(settings *transfer.EndpointSettings) string {
+		op, err := sdk.WrapOperation(sdk.Transfer().Endpoint().Create(ctx, &transfer.CreateEndpointRequest{ProjectId: "p", Name: "e", Settings: settings}))
+		require.NoError(t, err)
+		require.NoError(t, op.Wait(ctx))
+		return op.ResourceId()
+	}
+	src := createEndpoint(&transfer.EndpointSettings{Settings: &transfer.EndpointSettings_PostgresSource{PostgresSource: &endpoint.PostgresSource{}}})
+	dst := createEndpoint(&transfer.EndpointSettings{Settings: &transfer.EndpointSettings_ClickhouseTarget{ClickhouseTarget: &endpoint.ClickhouseTarget{}}})
+	op, err := sdk.WrapOperation(sdk.Transfer().Transfer().Create(ctx, &transfer.CreateTransferRequest{ProjectId: "p", Name: "t", SourceId: src, TargetId: dst}))
+	require.NoError(t, err)
+	require.NoError(t, op.Wait(ctx))
+
+	op, err = sdk.WrapOperation(sdk.Transfer().Endpoint().Delete(ctx, &transfer.DeleteEndpointRequest{EndpointId: src}))
+	require.NoError(t, err)
+	err = op.Wait(ctx)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "%v", err)
+	assert.NotNil(t, srv.Transfer.Endpoint(src), "endpoint used by the transfer must be kept")
+}
@@ -0,0 +1,237 @@
+package sdktest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// Transfer fakes the transfer, endpoint and operation services of Data Transfer.
+type Transfer struct {
+	transfer.UnimplementedTransferServiceServer
+
+	ops *operations
+
+	mu        sync.Mutex
+	endpoints map[string]*transfer.Endpoint
+	transfers map[string]*transfer.Transfer
+}
+
+func newTransfer() *Transfer {
+	return &Transfer{
+		ops:       newOperations(),
+		endpoints: map[string]*transfer.Endpoint{},
+		transfers: map[string]*transfer.Transfer{},
+	}
+}
+
+// Endpoint returns a copy of the endpoint, nil if it doesn't exist.
+func (f *Transfer) Endpoint(id string) *transfer.Endpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.endpoints[id]; ok {
+		return proto.Clone(e).(*transfer.Endpoint)
+	}
+	return nil
+}
+
+// Transfer returns a copy of the transfer, nil if it doesn't exist.
+func (f *Transfer) Transfer(id string) *transfer.Transfer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.transfers[id]; ok {
+		return proto.Clone(t).(*transfer.Transfer)
+	}
+	return nil
+}
+
+func (f *Transfer) Create(ctx context.Context, req *transfer.CreateTransferRequest) (*dcv1.Operation, error) {
+	if req.GetProjectId() == "" || req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "project_id and name are required")
+	}
+	src, dst := f.Endpoint(req.GetSourceId()), f.Endpoint(req.GetTargetId())
+	if src == nil || dst == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "endpoints %s and %s must exist", req.GetSourceId(), req.GetTargetId())
+	}
+	t := &transfer.Transfer{
+		Id:          f.ops.nextID("dtt"),
+		ProjectId:   req.GetProjectId(),
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Labels:      req.GetLabels(),
+		Source:      src,
+		Target:      dst,
+		Status:      transfer.TransferStatus_CREATING,
+		Type:        req.GetType(),
+	}
+	f.mu.Lock()
+	f.transfers[t.Id] = t
+	f.mu.Unlock()
+	return f.startTransferOp(t.Id, "Create transfer", func(t *transfer.Transfer) {
+		t.Status = transfer.TransferStatus_CREATED
+	})
+}
+
+func (f *Transfer) Get(ctx context.Context, req *transfer.GetTransferRequest) (*transfer.Transfer, error) {
+	if t := f.Transfer(req.GetTransferId()); t != nil {
+		return t, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "transfer %s not found", req.GetTransferId())
+}
+
+func (f *Transfer) List(ctx context.Context, req *transfer.ListTransfersRequest) (*transfer.ListTransfersResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &transfer.ListTransfersResponse{}
+	for _, t := range f.transfers {
+		if t.ProjectId == req.GetProjectId() {
+			resp.Transfers = append(resp.Transfers, proto.Clone(t).(*transfer.Transfer))
+		}
+	}
+	return resp, nil
+}
+
+func (f *Transfer) Update(ctx context.Context, req *transfer.UpdateTransferRequest) (*dcv1.Operation, error) {
+	return f.startTransferOp(req.GetTransferId(), "Update transfer", func(t *transfer.Transfer) {
+		t.Name = req.GetName()
+		t.Description = req.GetDescription()
+		t.Labels = req.GetLabels()
+	})
+}
+
+func (f *Transfer) Activate(ctx context.Context, req *transfer.ActivateTransferRequest) (*dcv1.Operation, error) {
+	return f.startTransferOp(req.GetTransferId(), "Activate transfer", func(t *transfer.Transfer) {
+		t.Status = transfer.TransferStatus_RUNNING
+	})
+}
+
+func (f *Transfer) Deactivate(ctx context.Context, req *transfer.DeactivateTransferRequest) (*dcv1.Operation, error) {
+	return f.startTransferOp(req.GetTransferId(), "Deactivate transfer", func(t *transfer.Transfer) {
+		t.Status = transfer.TransferStatus_STOPPED
+	})
+}
+
+func (f *Transfer) Delete(ctx context.Context, req *transfer.DeleteTransferRequest) (*dcv1.Operation, error) {
+	return f.startTransferOp(req.GetTransferId(), "Delete transfer", func(t *transfer.Transfer) {
+		delete(f.transfers, t.Id)
+	})
+}
+
+// startTransferOp starts an operation applying change to an existing transfer under the lock.
+func (f *Transfer) startTransferOp(id, description string, change func(*transfer.Transfer)) (*dcv1.Operation, error) {
+	t := f.Transfer(id)
+	if t == nil {
+		return nil, status.Errorf(codes.NotFound, "transfer %s not found", id)
+	}
+	return f.ops.start(operation.TRANSFER_OPERATION_PREFIX, t.ProjectId, id, description, nil, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		t, ok := f.transfers[id]
+		if !ok {
+			return status.Errorf(codes.NotFound, "transfer %s not found", id)
+		}
+		change(t)
+		return nil
+	}), nil
+}
+
+type transferEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+	f *Transfer
+}
+
+func (s *transferEndpoints) Create(ctx context.Context, req *transfer.CreateEndpointRequest) (*dcv1.Operation, error) {
+	if req.GetProjectId() == "" || req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "project_id and name are required")
+	}
+	if req.GetSettings().GetSettings() == nil {
+		return nil, status.Error(codes.InvalidArgument, "endpoint settings are required")
+	}
+	e := &transfer.Endpoint{
+		Id:          s.f.ops.nextID("dte"),
+		ProjectId:   req.GetProjectId(),
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Labels:      req.GetLabels(),
+		Settings:    req.GetSettings(),
+	}
+	return s.f.ops.start(operation.TRANSFER_ENDPOINTS_OPERATION_PREFIX, e.ProjectId, e.Id, "Create endpoint", nil, func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		s.f.endpoints[e.Id] = e
+		return nil
+	}), nil
+}
+
+func (s *transferEndpoints) Get(ctx context.Context, req *transfer.GetEndpointRequest) (*transfer.Endpoint, error) {
+	if e := s.f.Endpoint(req.GetEndpointId()); e != nil {
+		return e, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "endpoint %s not found", req.GetEndpointId())
+}
+
+func (s *transferEndpoints) List(ctx context.Context, req *transfer.ListEndpointsRequest) (*transfer.ListEndpointsResponse, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	resp := &transfer.ListEndpointsResponse{}
+	for _, e := range s.f.endpoints {
+		if e.ProjectId == req.GetProjectId() {
+			resp.Endpoints = append(resp.Endpoints, proto.Clone(e).(*transfer.Endpoint))
+		}
+	}
+	return resp, nil
+}
+
+func (s *transferEndpoints) Update(ctx context.Context, req *transfer.UpdateEndpointRequest) (*dcv1.Operation, error) {
+	e, err := s.Get(ctx, &transfer.GetEndpointRequest{EndpointId: req.GetEndpointId()})
+	if err != nil {
+		return nil, err
+	}
+	return s.f.ops.start(operation.TRANSFER_ENDPOINTS_OPERATION_PREFIX, e.ProjectId, e.Id, "Update endpoint", nil, func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		e, ok := s.f.endpoints[req.GetEndpointId()]
+		if !ok {
+			return status.Errorf(codes.NotFound, "endpoint %s not found", req.GetEndpointId())
+		}
+		e.Name = req.GetName()
+		e.Description = req.GetDescription()
+		e.Labels = req.GetLabels()
+		e.Settings = req.GetSettings()
+		return nil
+	}), nil
+}
+
+func (s *transferEndpoints) Delete(ctx context.Context, req *transfer.DeleteEndpointRequest) (*dcv1.Operation, error) {
+	e, err := s.Get(ctx, &transfer.GetEndpointRequest{EndpointId: req.GetEndpointId()})
+	if err != nil {
+		return nil, err
+	}
+	return s.f.ops.start(operation.TRANSFER_ENDPOINTS_OPERATION_PREFIX, e.ProjectId, e.Id, "Delete endpoint", nil, func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		for _, t := range s.f.transfers {
+			if t.Source.GetId() == e.Id || t.Target.GetId() == e.Id {
+				return status.Errorf(codes.FailedPrecondition, "endpoint %s is used by transfer %s", e.Id, t.Id)
+			}
+		}
+		delete(s.f.endpoints, e.Id)
+		return nil
+	}), nil
+}
+
+type transferOperations struct {
+	transfer.UnimplementedOperationServiceServer
+	ops *operations
+}
+
+func (s *transferOperations) Get(ctx context.Context, req *transfer.GetOperationRequest) (*dcv1.Operation, error) {
+	return s.ops.get(req.GetOperationId())
+}
@@ -0,0 +1,83 @@
+package dcsdk
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// SecurityProfile selects the crypto settings of the SDK connections and credentials.
+type SecurityProfile int
+
+const (
+	// SecurityProfileDefault uses Go defaults for TLS. Service account JWTs are signed with PS256.
+	SecurityProfileDefault SecurityProfile = iota
+	// SecurityProfileStrict allows FIPS-compatible crypto only: TLS 1.2 or later with ECDHE AES-GCM
+	// cipher suites and NIST curves, no Ed25519 server certificates, and service account keys
+	// of at least 2048 bits. Service account JWTs are signed with PS256.
+	SecurityProfileStrict
+)
+
+func (p SecurityProfile) String() string {
+	switch p {
+	case SecurityProfileDefault:
+		return "default"
+	case SecurityProfileStrict:
+		return "strict"
+	default:
+		return fmt.Sprintf("SecurityProfile(%d)", int(p))
+	}
+}
+
+// strictCipherSuites are the TLS 1.2 cipher suites of SecurityProfileStrict.
+// TLS 1.3 suites are not configurable and are all AES-GCM or ChaCha20 based;
+// Go prefers AES-GCM on hardware supporting it.
+var strictCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+var strictCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// strictMinRSAKeyBits is the minimal service account key size of SecurityProfileStrict.
+const strictMinRSAKeyBits = 2048
+
+var errEd25519Certificate = errors.New("ed25519 server certificates are not allowed by the strict security profile")
+
+// tlsConfig returns the TLS config of SDK connections based on the user provided one, if any.
+// The base config is not modified.
+func (p SecurityProfile) tlsConfig(base *tls.Config) *tls.Config {
+	conf := &tls.Config{}
+	if base != nil {
+		conf = base.Clone()
+	}
+	if p != SecurityProfileStrict {
+		return conf
+	}
+	if conf.MinVersion < tls.VersionTLS12 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+	conf.CipherSuites = strictCipherSuites
+	conf.CurvePreferences = strictCurves
+	verify := conf.VerifyConnection
+	conf.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) > 0 {
+			if _, ok := cs.PeerCertificates[0].PublicKey.(ed25519.PublicKey); ok {
+				return errEd25519Certificate
+			}
+		}
+		if verify != nil {
+			return verify(cs)
+		}
+		return nil
+	}
+	return conf
+}
+
+// profiledCredentials are credentials depending on the security profile.
+type profiledCredentials interface {
+	withSecurityProfile(p SecurityProfile) (Credentials, error)
+}
@@ -0,0 +1,120 @@
+package dcsdk
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/doublecloud/go-sdk/iamkey"
+)
+
+func TestSecurityProfile_TLSConfig(t *testing.T) {
+	base := &tls.Config{ServerName: "api.example.com", MinVersion: tls.VersionTLS10}
+
+	conf := SecurityProfileDefault.tlsConfig(base)
+	assert.Equal(t, "api.example.com", conf.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS10), conf.MinVersion)
+	assert.Nil(t, conf.CipherSuites)
+	assert.Nil(t, conf.CurvePreferences)
+	assert.Nil(t, conf.VerifyConnection)
+	assert.NotNil(t, SecurityProfileDefault.tlsConfig(nil))
+
+	conf = SecurityProfileStrict.tlsConfig(base)
+	assert.Equal(t, "api.example.com", conf.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+	assert.Equal(t, []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}, conf.CipherSuites)
+	assert.Equal(t, []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}, conf.CurvePreferences)
+	assert.Equal(t, uint16(tls.VersionTLS10), base.MinVersion, "the base config is not modified")
+	assert.Nil(t, base.CipherSuites)
+
+	conf = SecurityProfileStrict.tlsConfig(&tls.Config{MinVersion: tls.VersionTLS13})
+	assert.Equal(t, uint16(tls.VersionTLS13), conf.MinVersion, "stricter minimum versions are kept")
+}
+
+func TestSecurityProfile_VerifyConnection(t *testing.T) {
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var verified int
+	conf := SecurityProfileStrict.tlsConfig(&tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		verified++
+		return nil
+	}})
+	state := func(key interface{}) tls.ConnectionState {
+		return tls.ConnectionState{PeerCertificates: []*x509.Certificate{{PublicKey: key}}}
+	}
+	assert.ErrorIs(t, conf.VerifyConnection(state(edKey)), errEd25519Certificate)
+	assert.Equal(t, 0, verified)
+	assert.NoError(t, conf.VerifyConnection(state(&rsaKey.PublicKey)))
+	assert.Equal(t, 1, verified, "user verification is chained")
+}
+
+func testServiceAccountKey(t *testing.T, bits int) *iamkey.Key {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return &iamkey.Key{
+		Id:         "key1",
+		Subject:    &iamkey.Key_ServiceAccountId{ServiceAccountId: "sa1"},
+		PrivateKey: string(b),
+	}
+}
+
+func jwtHeader(t *testing.T, creds Credentials) map[string]interface{} {
+	t.Helper()
+	req, err := creds.(ExchangeableCredentials).IAMTokenRequest()
+	require.NoError(t, err)
+	token, _, err := new(jwt.Parser).ParseUnverified(req.GetJwt(), &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	return token.Header
+}
+
+func TestSecurityProfile_ServiceAccountJWT(t *testing.T) {
+	for _, profile := range []SecurityProfile{SecurityProfileDefault, SecurityProfileStrict} {
+		t.Run(profile.String(), func(t *testing.T) {
+			creds, err := ServiceAccountKey(testServiceAccountKey(t, 2048))
+			require.NoError(t, err)
+			sdk, err := Build(context.Background(), Config{Credentials: creds, SecurityProfile: profile})
+			require.NoError(t, err)
+
+			header := jwtHeader(t, sdk.config().Credentials)
+			assert.Equal(t, "PS256", header["alg"])
+			assert.Equal(t, "key1", header["kid"])
+		})
+	}
+}
+
+func TestSecurityProfile_StrictRejects(t *testing.T) {
+	creds, err := ServiceAccountKey(testServiceAccountKey(t, 1024))
+	require.NoError(t, err)
+	_, err = Build(context.Background(), Config{Credentials: creds})
+	require.NoError(t, err)
+	_, err = Build(context.Background(), Config{Credentials: creds, SecurityProfile: SecurityProfileStrict})
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "1024 bit service account key is not allowed"), err.Error())
+
+	_, err = Build(context.Background(), Config{
+		Credentials:     NewIAMTokenCredentials("test-token"),
+		Plaintext:       true,
+		SecurityProfile: SecurityProfileStrict,
+	})
+	assert.EqualError(t, err, "plaintext connections are not allowed by the strict security profile")
+}
@@ -0,0 +1,191 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	endpoint_airbyte "github.com/doublecloud/go-genproto/doublecloud/transfer/v1/endpoint/airbyte"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/google/uuid"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/budget"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const (
+	// DefaultSmokeCleanupTimeout is the least time given to the deletion of the resource created
+	// by SmokeTest.
+	DefaultSmokeCleanupTimeout = 5 * time.Minute
+	// SmokeLabel labels the resources created by SmokeTest, to find leftovers.
+	SmokeLabel = "dcsdk-smoke"
+)
+
+// SmokeSpec configures SDK.SmokeTest.
+type SmokeSpec struct {
+	ProjectID string
+	// NetworkID, if set, is read as well.
+	NetworkID string
+	// SkipCreate limits the test to reads. Otherwise the test also creates a transfer
+	// endpoint in the project, waits for it and deletes it.
+	SkipCreate bool
+}
+
+// SmokeStep is the outcome of a step of SmokeTest.
+type SmokeStep struct {
+	Name     string
+	Duration time.Duration
+	// Err is nil if the step succeeded.
+	Err error
+}
+
+// SmokeReport lists the steps made by SmokeTest in order.
+type SmokeReport struct {
+	Steps []SmokeStep
+	// ResourceID is the ID of the endpoint created by the test, if any. It is left behind
+	// only if the step deleting it failed.
+	ResourceID string
+}
+
+// Err returns the errors of the failed steps, nil if all of them succeeded.
+func (r *SmokeReport) Err() error {
+	var result *multierror.Error
+	for _, s := range r.Steps {
+		if s.Err != nil {
+			result = multierror.Append(result, fmt.Errorf("%s: %w", s.Name, s.Err))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+func (r *SmokeReport) step(name string, f func() error) error {
+	started := now()
+	err := f()
+	r.Steps = append(r.Steps, SmokeStep{Name: name, Duration: now().Sub(started), Err: err})
+	return err
+}
+
+// SmokeTest checks that the SDK works against the account: it acquires a token and reads
+// from every service. Unless spec.SkipCreate is set, it then creates a transfer endpoint
+// named after SmokeLabel, waits for the creation and deletes the endpoint again. The
+// endpoint is deleted even if the test fails or ctx is done after the endpoint was created,
+// on a context detached from ctx getting at least DefaultSmokeCleanupTimeout, see
+// budget.Cleanup.
+//
+// All steps are made even if some of them fail. The report is returned along with the
+// errors of the failed steps, see SmokeReport.Err.
+func (sdk *SDK) SmokeTest(ctx context.Context, spec SmokeSpec) (*SmokeReport, error) {
+	if spec.ProjectID == "" {
+		return nil, fmt.Errorf("project id required")
+	}
+	r := &SmokeReport{}
+	_ = r.step("token", func() error {
+		_, err := sdk.CreateIAMToken(ctx)
+		return err
+	})
+	for _, read := range sdk.smokeReads(spec) {
+		read := read
+		_ = r.step(read.name, func() error { return read.f(ctx) })
+	}
+	if !spec.SkipCreate {
+		sdk.smokeCreate(ctx, spec, r)
+	}
+	return r, r.Err()
+}
+
+type smokeRead struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+func (sdk *SDK) smokeReads(spec SmokeSpec) []smokeRead {
+	paging := func() *dcv1.Paging { return &dcv1.Paging{PageSize: 1} }
+	reads := []smokeRead{
+		{"clickhouse/list clusters", func(ctx context.Context) error {
+			_, err := sdk.ClickHouse().Cluster().List(ctx, &clickhouse.ListClustersRequest{ProjectId: spec.ProjectID, Paging: paging()})
+			return err
+		}},
+		{"kafka/list clusters", func(ctx context.Context) error {
+			_, err := sdk.Kafka().Cluster().List(ctx, &kafka.ListClustersRequest{ProjectId: spec.ProjectID, Paging: paging()})
+			return err
+		}},
+		{"vpc/list networks", func(ctx context.Context) error {
+			_, err := sdk.Network().Network().List(ctx, &network.ListNetworksRequest{ProjectId: spec.ProjectID, Paging: paging()})
+			return err
+		}},
+		{"transfer/list endpoints", func(ctx context.Context) error {
+			_, err := sdk.Transfer().Endpoint().List(ctx, &transfer.ListEndpointsRequest{ProjectId: spec.ProjectID})
+			return err
+		}},
+		{"transfer/list transfers", func(ctx context.Context) error {
+			_, err := sdk.Transfer().Transfer().List(ctx, &transfer.ListTransfersRequest{ProjectId: spec.ProjectID})
+			return err
+		}},
+	}
+	if spec.NetworkID != "" {
+		reads = append(reads, smokeRead{"vpc/get network", func(ctx context.Context) error {
+			_, err := sdk.Network().Network().Get(ctx, &network.GetNetworkRequest{NetworkId: spec.NetworkID})
+			return err
+		}})
+	}
+	return reads
+}
+
+// smokeCreate creates a transfer endpoint and deletes it, once it was created, on a
+// detached context.
+func (sdk *SDK) smokeCreate(ctx context.Context, spec SmokeSpec, r *SmokeReport) {
+	var op *operation.Operation
+	err := r.step("transfer/create endpoint", func() error {
+		var err error
+		op, err = sdk.WrapOperation(sdk.Transfer().Endpoint().Create(ctx, smokeEndpointRequest(spec.ProjectID)))
+		return err
+	})
+	if err != nil {
+		return
+	}
+	r.ResourceID = op.ResourceId()
+	defer func() {
+		ctx, cancel := budget.Cleanup(ctx, DefaultSmokeCleanupTimeout)
+		defer cancel()
+		_ = r.step("transfer/delete endpoint", func() error {
+			op, err := sdk.WrapOperation(sdk.Transfer().Endpoint().Delete(ctx, &transfer.DeleteEndpointRequest{EndpointId: r.ResourceID}))
+			if status.Code(err) == codes.NotFound {
+				// The creation failed.
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return sdkerrors.WithMessagef(op.Wait(ctx), "wait for %s", op)
+		})
+	}()
+	_ = r.step("transfer/wait create endpoint", func() error { return op.Wait(ctx) })
+}
+
+func smokeEndpointRequest(projectID string) *transfer.CreateEndpointRequest {
+	return &transfer.CreateEndpointRequest{
+		ProjectId:   projectID,
+		Name:        SmokeLabel + "-" + uuid.NewString()[:8],
+		Description: "Created by the SDK smoke test, safe to delete.",
+		Labels:      map[string]string{SmokeLabel: "true"},
+		Settings: &transfer.EndpointSettings{
+			Settings: &transfer.EndpointSettings_S3Source{
+				S3Source: &endpoint_airbyte.S3Source{
+					Dataset:     "smoke",
+					PathPattern: "smoke",
+					Schema:      "{}",
+					Format:      &endpoint_airbyte.S3Source_Format{Format: &endpoint_airbyte.S3Source_Format_Csv{}},
+					Provider:    &endpoint_airbyte.S3Source_Provider{Bucket: SmokeLabel},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,236 @@
+package dcsdk
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/network/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/transfer/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/iamkey"
+)
+
+type smokeClickHouse struct {
+	clickhouse.UnimplementedClusterServiceServer
+}
+
+func (smokeClickHouse) List(ctx context.Context, req *clickhouse.ListClustersRequest) (*clickhouse.ListClustersResponse, error) {
+	return &clickhouse.ListClustersResponse{}, nil
+}
+
+type smokeKafka struct {
+	kafka.UnimplementedClusterServiceServer
+	err error
+}
+
+func (f smokeKafka) List(ctx context.Context, req *kafka.ListClustersRequest) (*kafka.ListClustersResponse, error) {
+	return &kafka.ListClustersResponse{}, f.err
+}
+
+type smokeNetwork struct {
+	network.UnimplementedNetworkServiceServer
+}
+
+func (smokeNetwork) List(ctx context.Context, req *network.ListNetworksRequest) (*network.ListNetworksResponse, error) {
+	return &network.ListNetworksResponse{}, nil
+}
+
+func (smokeNetwork) Get(ctx context.Context, req *network.GetNetworkRequest) (*network.Network, error) {
+	return &network.Network{Id: req.NetworkId}, nil
+}
+
+type smokeTransfers struct {
+	transfer.UnimplementedTransferServiceServer
+}
+
+func (smokeTransfers) List(ctx context.Context, req *transfer.ListTransfersRequest) (*transfer.ListTransfersResponse, error) {
+	return &transfer.ListTransfersResponse{}, nil
+}
+
+// smokeEndpoints fakes the endpoints and their operations: the creation fails with
+// createErr, or its operation with createOpErr, or blocks until ctx is done if block is set.
+type smokeEndpoints struct {
+	transfer.UnimplementedEndpointServiceServer
+	createErr   error
+	createOpErr *rpcstatus.Status
+	block       chan struct{}
+
+	mu      sync.Mutex
+	created []string
+	deleted []string
+}
+
+func (f *smokeEndpoints) List(ctx context.Context, req *transfer.ListEndpointsRequest) (*transfer.ListEndpointsResponse, error) {
+	return &transfer.ListEndpointsResponse{}, nil
+}
+
+func (f *smokeEndpoints) Create(ctx context.Context, req *transfer.CreateEndpointRequest) (*dcv1.Operation, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, req.Name)
+	return &dcv1.Operation{Id: "dteo-create", ResourceId: "dte1", Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (f *smokeEndpoints) Delete(ctx context.Context, req *transfer.DeleteEndpointRequest) (*dcv1.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, req.EndpointId)
+	return &dcv1.Operation{Id: "dteo-delete", ResourceId: req.EndpointId, Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (f *smokeEndpoints) deletedEndpoints() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deleted...)
+}
+
+type smokeOperations struct {
+	transfer.UnimplementedOperationServiceServer
+	*smokeEndpoints
+}
+
+func (f smokeOperations) Get(ctx context.Context, req *transfer.GetOperationRequest) (*dcv1.Operation, error) {
+	op := &dcv1.Operation{Id: req.OperationId, ResourceId: "dte1", Status: dcv1.Operation_STATUS_DONE}
+	if req.OperationId == "dteo-create" {
+		if f.block != nil {
+			close(f.block)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		op.Error = f.createOpErr
+	}
+	return op, nil
+}
+
+func newSmokeSDK(t *testing.T, endpoints *smokeEndpoints, kafkaErr error) *SDK {
+	return newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, smokeClickHouse{})
+		kafka.RegisterClusterServiceServer(s, smokeKafka{err: kafkaErr})
+		network.RegisterNetworkServiceServer(s, smokeNetwork{})
+		transfer.RegisterTransferServiceServer(s, smokeTransfers{})
+		transfer.RegisterEndpointServiceServer(s, endpoints)
+		transfer.RegisterOperationServiceServer(s, smokeOperations{smokeEndpoints: endpoints})
+	})
+}
+
+func stepNames(r *SmokeReport) []string {
+	var names []string
+	for _, s := range r.Steps {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+var smokeReadSteps = []string{"token", "clickhouse/list clusters", "kafka/list clusters", "vpc/list networks", "transfer/list endpoints", "transfer/list transfers"}
+
+func TestSmokeTest_SkipCreate(t *testing.T) {
+	endpoints := &smokeEndpoints{}
+	sdk := newSmokeSDK(t, endpoints, nil)
+
+	r, err := sdk.SmokeTest(context.Background(), SmokeSpec{ProjectID: "p1", NetworkID: "n1", SkipCreate: true})
+	require.NoError(t, err)
+	assert.Equal(t, append(smokeReadSteps, "vpc/get network"), stepNames(r))
+	assert.Empty(t, endpoints.created)
+	assert.Empty(t, r.ResourceID)
+
+	_, err = sdk.SmokeTest(context.Background(), SmokeSpec{})
+	assert.Error(t, err)
+}
+
+func TestSmokeTest_ReadFailure(t *testing.T) {
+	sdk := newSmokeSDK(t, &smokeEndpoints{}, status.Error(codes.PermissionDenied, "denied"))
+
+	r, err := sdk.SmokeTest(context.Background(), SmokeSpec{ProjectID: "p1", SkipCreate: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kafka/list clusters")
+	assert.Equal(t, smokeReadSteps, stepNames(r), "the steps after a failed one are made")
+	assert.Equal(t, codes.PermissionDenied, status.Code(r.Steps[2].Err))
+}
+
+func TestSmokeTest_Create(t *testing.T) {
+	endpoints := &smokeEndpoints{}
+	sdk := newSmokeSDK(t, endpoints, nil)
+
+	r, err := sdk.SmokeTest(context.Background(), SmokeSpec{ProjectID: "p1"})
+	require.NoError(t, err)
+	assert.Equal(t, append(smokeReadSteps, "transfer/create endpoint", "transfer/wait create endpoint", "transfer/delete endpoint"), stepNames(r))
+	require.Len(t, endpoints.created, 1)
+	assert.Contains(t, endpoints.created[0], SmokeLabel)
+	assert.Equal(t, "dte1", r.ResourceID)
+	assert.Equal(t, []string{"dte1"}, endpoints.deletedEndpoints())
+}
+
+func TestSmokeTest_CleanupOnFailure(t *testing.T) {
+	t.Run("create fails", func(t *testing.T) {
+		endpoints := &smokeEndpoints{createErr: status.Error(codes.ResourceExhausted, "quota")}
+		sdk := newSmokeSDK(t, endpoints, nil)
+
+		r, err := sdk.SmokeTest(context.Background(), SmokeSpec{ProjectID: "p1"})
+		require.Error(t, err)
+		assert.Equal(t, append(smokeReadSteps, "transfer/create endpoint"), stepNames(r))
+		assert.Empty(t, endpoints.deletedEndpoints(), "nothing was created")
+	})
+	t.Run("operation fails", func(t *testing.T) {
+		endpoints := &smokeEndpoints{createOpErr: &rpcstatus.Status{Code: int32(code.Code_INTERNAL), Message: "boom"}}
+		sdk := newSmokeSDK(t, endpoints, nil)
+
+		r, err := sdk.SmokeTest(context.Background(), SmokeSpec{ProjectID: "p1"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "wait create endpoint")
+		assert.Equal(t, []string{"dte1"}, endpoints.deletedEndpoints())
+		assert.NoError(t, r.Steps[len(r.Steps)-1].Err)
+	})
+	t.Run("context done while waiting", func(t *testing.T) {
+		endpoints := &smokeEndpoints{block: make(chan struct{})}
+		sdk := newSmokeSDK(t, endpoints, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-endpoints.block
+			cancel()
+		}()
+
+		r, err := sdk.SmokeTest(ctx, SmokeSpec{ProjectID: "p1"})
+		require.Error(t, err)
+		assert.Equal(t, []string{"dte1"}, endpoints.deletedEndpoints(), "the endpoint is deleted on a detached context")
+		assert.Equal(t, "transfer/delete endpoint", r.Steps[len(r.Steps)-1].Name)
+		assert.NoError(t, r.Steps[len(r.Steps)-1].Err)
+	})
+}
+
+// TestSmokeTest_Live runs the smoke test against the account of the service account key
+// in DC_SMOKE_KEY_FILE and the project in DC_SMOKE_PROJECT_ID, if both are set.
+// It creates resources unless DC_SMOKE_SKIP_CREATE is set.
+func TestSmokeTest_Live(t *testing.T) {
+	keyFile, projectID := os.Getenv("DC_SMOKE_KEY_FILE"), os.Getenv("DC_SMOKE_PROJECT_ID")
+	if keyFile == "" || projectID == "" {
+		t.Skip("DC_SMOKE_KEY_FILE and DC_SMOKE_PROJECT_ID are not set")
+	}
+	key, err := iamkey.ReadFromJSONFile(keyFile)
+	require.NoError(t, err)
+	creds, err := ServiceAccountKey(key)
+	require.NoError(t, err)
+	sdk, err := Build(context.Background(), Config{Credentials: creds})
+	require.NoError(t, err)
+	defer func() { _ = sdk.Shutdown(context.Background()) }()
+
+	r, err := sdk.SmokeTest(context.Background(), SmokeSpec{ProjectID: projectID, SkipCreate: os.Getenv("DC_SMOKE_SKIP_CREATE") != ""})
+	for _, s := range r.Steps {
+		t.Logf("%s: %s, %v", s.Name, s.Duration, s.Err)
+	}
+	assert.NoError(t, err)
+}
@@ -0,0 +1,164 @@
+package dcsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/grpclog"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// DefaultStatusFeedInterval is the interval between the fetches of Config.StatusFeedURL when
+// Config.StatusFeedInterval is not positive.
+const DefaultStatusFeedInterval = time.Minute
+
+// statusFeedTimeout bounds every fetch of the status feed.
+const statusFeedTimeout = 10 * time.Second
+
+// statusFeedJSON is the document served by Config.StatusFeedURL.
+type statusFeedJSON struct {
+	Incidents []incidentJSON `json:"incidents"`
+}
+
+type incidentJSON struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Component string `json:"component"`
+	// Services are the affected service kinds, e.g. "clickhouse", all of them if empty.
+	Services []ServiceKind `json:"services"`
+	// Status is e.g. "degraded", "outage" or "maintenance", "resolved" once it is over.
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// active reports whether the incident is going on at t.
+func (i *incidentJSON) active(t time.Time) bool {
+	return i.Status != "resolved" && i.ResolvedAt == nil && !i.StartedAt.After(t)
+}
+
+// affects reports whether the incident affects the services of kind.
+func (i *incidentJSON) affects(kind ServiceKind) bool {
+	if len(i.Services) == 0 {
+		return true
+	}
+	for _, k := range i.Services {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// statusFeed caches the incidents of the status feed of the provider, refreshed in the
+// background. It fails open: while the feed can't be fetched or decoded, no incident is
+// reported.
+type statusFeed struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	mu        sync.Mutex
+	incidents []incidentJSON
+}
+
+func newStatusFeed(url string, interval time.Duration) *statusFeed {
+	if interval <= 0 {
+		interval = DefaultStatusFeedInterval
+	}
+	return &statusFeed{url: url, interval: interval, client: http.DefaultClient, now: now}
+}
+
+// run refreshes the feed until ctx is done.
+func (f *statusFeed) run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		if err := f.refresh(ctx); err != nil && ctx.Err() == nil {
+			grpclog.Warningf("dcsdk: status feed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh fetches the feed, forgetting the incidents if it fails.
+func (f *statusFeed) refresh(ctx context.Context) error {
+	incidents, err := f.fetch(ctx)
+	f.mu.Lock()
+	f.incidents = incidents
+	f.mu.Unlock()
+	return err
+}
+
+func (f *statusFeed) fetch(ctx context.Context) ([]incidentJSON, error) {
+	ctx, cancel := context.WithTimeout(ctx, statusFeedTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var feed statusFeedJSON
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return feed.Incidents, nil
+}
+
+// active returns the earliest incident going on for the services of kind, if any.
+func (f *statusFeed) active(kind ServiceKind) (*sdkerrors.Incident, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.now()
+	var found *incidentJSON
+	for i := range f.incidents {
+		inc := &f.incidents[i]
+		if inc.active(t) && inc.affects(kind) && (found == nil || inc.StartedAt.Before(found.StartedAt)) {
+			found = inc
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	return &sdkerrors.Incident{
+		ID:        found.ID,
+		Title:     found.Title,
+		Component: found.Component,
+		Status:    found.Status,
+		Since:     found.StartedAt,
+	}, true
+}
+
+// interceptIncidents annotates the failed calls with the incident of their service going on,
+// see Config.StatusFeedURL and sdkerrors.IncidentInfo.
+func (sdk *SDK) interceptIncidents(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil || sdk.statusFeed == nil {
+		return err
+	}
+	kind, _ := methodLabels(method)
+	if incident, ok := sdk.statusFeed.active(kind); ok {
+		return sdkerrors.WithIncident(err, incident)
+	}
+	return err
+}
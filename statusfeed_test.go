@@ -0,0 +1,139 @@
+package dcsdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+const (
+	activeIncident = `{"incidents": [
+		{"id": "inc1", "title": "Cluster creation delays", "component": "ClickHouse control plane", "services": ["clickhouse"],
+		 "status": "degraded", "started_at": "2023-05-01T12:03:00Z"},
+		{"id": "inc0", "component": "Kafka control plane", "services": ["kafka"],
+		 "status": "outage", "started_at": "2023-04-01T08:00:00Z", "resolved_at": "2023-04-01T09:00:00Z"}
+	]}`
+	resolvedIncident = `{"incidents": [
+		{"id": "inc1", "component": "ClickHouse control plane", "services": ["clickhouse"],
+		 "status": "resolved", "started_at": "2023-05-01T12:03:00Z"}
+	]}`
+)
+
+// fakeStatusFeed serves the status feed document set with set, failing with code if set.
+type fakeStatusFeed struct {
+	mu   sync.Mutex
+	body string
+	code int
+}
+
+func (f *fakeStatusFeed) set(body string, code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.body, f.code = body, code
+}
+
+func (f *fakeStatusFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.code != 0 {
+		w.WriteHeader(f.code)
+	}
+	_, _ = w.Write([]byte(f.body))
+}
+
+func newStatusFeedSDK(t *testing.T, feed *fakeStatusFeed) *SDK {
+	srv := httptest.NewServer(feed)
+	t.Cleanup(srv.Close)
+	return newTestSDKWithConfig(t, Config{Credentials: NewIAMTokenCredentials("test-token"), StatusFeedURL: srv.URL}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, fakeClickHouseClusters{})
+	})
+}
+
+func TestStatusFeed_AnnotatesFailures(t *testing.T) {
+	feed := &fakeStatusFeed{body: activeIncident}
+	sdk := newStatusFeedSDK(t, feed)
+	ctx := context.Background()
+	require.NoError(t, sdk.statusFeed.refresh(ctx))
+
+	_, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: "chcl1"})
+	require.Error(t, err)
+	incident, ok := sdkerrors.IncidentInfo(err)
+	require.True(t, ok)
+	assert.Equal(t, "inc1", incident.ID)
+	assert.Equal(t, "provider reports degraded ClickHouse control plane since 12:03 UTC", incident.String())
+	assert.Contains(t, err.Error(), "(provider reports degraded ClickHouse control plane since 12:03 UTC)")
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "the status of the error is kept")
+
+	_, err = sdk.Kafka().Cluster().Get(ctx, &kafka.GetClusterRequest{ClusterId: "kfc1"})
+	require.Error(t, err)
+	_, ok = sdkerrors.IncidentInfo(err)
+	assert.False(t, ok, "resolved incidents and incidents of other services are ignored")
+}
+
+func TestStatusFeed_Resolved(t *testing.T) {
+	feed := &fakeStatusFeed{body: resolvedIncident}
+	sdk := newStatusFeedSDK(t, feed)
+	require.NoError(t, sdk.statusFeed.refresh(context.Background()))
+
+	_, err := sdk.ClickHouse().Cluster().Get(context.Background(), &clickhouse.GetClusterRequest{ClusterId: "chcl1"})
+	require.Error(t, err)
+	_, ok := sdkerrors.IncidentInfo(err)
+	assert.False(t, ok)
+}
+
+func TestStatusFeed_FailsOpen(t *testing.T) {
+	feed := &fakeStatusFeed{body: activeIncident}
+	sdk := newStatusFeedSDK(t, feed)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{"unavailable", http.StatusServiceUnavailable},
+		{"{not json", 0},
+	} {
+		require.NoError(t, sdk.statusFeed.refresh(ctx))
+		feed.set(tc.body, tc.code)
+		assert.Error(t, sdk.statusFeed.refresh(ctx))
+
+		_, err := sdk.ClickHouse().Cluster().Get(ctx, &clickhouse.GetClusterRequest{ClusterId: "chcl1"})
+		require.Error(t, err)
+		_, ok := sdkerrors.IncidentInfo(err)
+		assert.False(t, ok, "incidents are forgotten when the feed fails")
+		feed.set(activeIncident, 0)
+	}
+}
+
+func TestStatusFeed_Disabled(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) { clickhouse.RegisterClusterServiceServer(s, fakeClickHouseClusters{}) })
+	assert.Nil(t, sdk.statusFeed)
+	for _, task := range sdk.BackgroundTasks() {
+		assert.NotEqual(t, "status feed", task.Name)
+	}
+	_, err := sdk.ClickHouse().Cluster().Get(context.Background(), &clickhouse.GetClusterRequest{ClusterId: "chcl1"})
+	_, ok := sdkerrors.IncidentInfo(err)
+	assert.False(t, ok)
+}
+
+func TestStatusFeed_Background(t *testing.T) {
+	feed := &fakeStatusFeed{body: activeIncident}
+	sdk := newStatusFeedSDK(t, feed)
+	require.Eventually(t, func() bool {
+		_, ok := sdk.statusFeed.active(ClickHouseServiceID)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond, "the feed is fetched right after Build")
+}
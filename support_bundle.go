@@ -0,0 +1,207 @@
+package dcsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+const (
+	sdkModulePath = "github.com/doublecloud/go-sdk"
+
+	DefaultBundleMaxFailedOperations = 20
+)
+
+// BundleSpec configures SDK.SupportBundle.
+type BundleSpec struct {
+	ProjectID string
+	// Since limits collected operations to the ones created after it. Zero means no limit.
+	Since time.Time
+	// MaxFailedOperations bounds the number of failed operations collected per service.
+	// Zero means DefaultBundleMaxFailedOperations.
+	MaxFailedOperations int
+	// MaxTextSize bounds the descriptions and the error messages of the collected
+	// operations, longer ones are truncated with operation.TruncateText. Zero means
+	// operation.TextLimit.
+	MaxTextSize int
+}
+
+// Bundle is a support bundle: diagnostic information to attach to a support ticket.
+// It never contains credentials or other secrets.
+type Bundle struct {
+	SDKVersion  string       `json:"sdk_version"`
+	GoVersion   string       `json:"go_version"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	ProjectID   string       `json:"project_id"`
+	Config      BundleConfig `json:"config"`
+	// Services maps known service IDs to their API addresses.
+	Services map[string]string `json:"services"`
+	// Diagnosis maps service IDs to connection check results.
+	Diagnosis map[string]string `json:"diagnosis"`
+	// FailedOperations maps service IDs to the most recent failed operations, newest first.
+	FailedOperations map[string][]BundleOperation `json:"failed_operations"`
+	// Errors maps service IDs to errors that occurred while collecting their data.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// BundleConfig is the redacted effective SDK config.
+type BundleConfig struct {
+	Endpoint        string `json:"endpoint"`
+	Plaintext       bool   `json:"plaintext"`
+	CustomTLS       bool   `json:"custom_tls"`
+	CredentialsType string `json:"credentials_type"`
+}
+
+// BundleOperation describes a failed operation.
+type BundleOperation struct {
+	ID           string    `json:"id"`
+	Description  string    `json:"description"`
+	ResourceID   string    `json:"resource_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ErrorCode    string    `json:"error_code"`
+	ErrorMessage string    `json:"error_message"`
+	RequestIDs   []string  `json:"request_ids,omitempty"`
+	ErrorReasons []string  `json:"error_reasons,omitempty"`
+}
+
+// JSON serializes the bundle to a single JSON blob.
+func (b *Bundle) JSON() ([]byte, error) {
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// SupportBundle collects diagnostic information about the SDK and recent failed operations
+// of the project. Failures to collect data of a single service are reported in Bundle.Errors.
+func (sdk *SDK) SupportBundle(ctx context.Context, spec BundleSpec) (*Bundle, error) {
+	if spec.ProjectID == "" {
+		return nil, fmt.Errorf("project id required")
+	}
+	limit := spec.MaxFailedOperations
+	if limit <= 0 {
+		limit = DefaultBundleMaxFailedOperations
+	}
+	textLimit := spec.MaxTextSize
+	if textLimit <= 0 {
+		textLimit = operation.TextLimit
+	}
+	b := &Bundle{
+		SDKVersion:       sdkVersion(),
+		GoVersion:        runtime.Version(),
+		GeneratedAt:      now().UTC(),
+		ProjectID:        spec.ProjectID,
+		Config:           sdk.bundleConfig(),
+		Services:         map[string]string{},
+		Diagnosis:        map[string]string{},
+		FailedOperations: map[string][]BundleOperation{},
+		Errors:           map[string]string{},
+	}
+
+	for _, id := range serviceIDs {
+		b.Diagnosis[string(id)] = "ok"
+		if err := sdk.CheckEndpointConnection(ctx, id); err != nil {
+			b.Diagnosis[string(id)] = err.Error()
+		}
+		if ep, ok := sdk.Endpoint(id); ok {
+			b.Services[string(id)] = ep.Address
+		}
+	}
+
+	ops := sdk.Operations()
+	for _, id := range operationKinds {
+		listed, err := ops.list(ctx, id, spec.ProjectID, nil)
+		if err != nil {
+			b.Errors[string(id)] = err.Error()
+			continue
+		}
+		b.FailedOperations[string(id)] = failedOperations(listed, spec.Since, limit, textLimit)
+	}
+	if len(b.Errors) == 0 {
+		b.Errors = nil
+	}
+	return b, nil
+}
+
+func (sdk *SDK) bundleConfig() BundleConfig {
+	conf := sdk.config()
+	return BundleConfig{
+		Endpoint:        redactEndpoint(conf.Endpoint),
+		Plaintext:       conf.Plaintext,
+		CustomTLS:       conf.TLSConfig != nil,
+		CredentialsType: fmt.Sprintf("%T", conf.Credentials),
+	}
+}
+
+// redactEndpoint drops user info, paths and queries that may carry secrets out of the endpoint.
+func redactEndpoint(endpoint string) string {
+	scheme := ""
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		scheme, endpoint = endpoint[:i+3], endpoint[i+3:]
+	}
+	if i := strings.LastIndex(endpoint, "@"); i >= 0 {
+		endpoint = endpoint[i+1:]
+	}
+	if i := strings.IndexAny(endpoint, "/?#"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	return scheme + endpoint
+}
+
+func failedOperations(ops []*dcv1.Operation, since time.Time, limit, textLimit int) []BundleOperation {
+	result := []BundleOperation{}
+	for _, o := range ops {
+		if o.GetError() == nil || (!since.IsZero() && o.GetCreateTime().AsTime().Before(since)) {
+			continue
+		}
+		// Not status.FromProto: it clones the status with its details, whatever their size.
+		st := o.GetError()
+		bo := BundleOperation{
+			ID:           o.GetId(),
+			Description:  operation.TruncateText(o.GetDescription(), textLimit),
+			ResourceID:   o.GetResourceId(),
+			CreatedAt:    o.GetCreateTime().AsTime(),
+			ErrorCode:    codes.Code(st.GetCode()).String(),
+			ErrorMessage: operation.TruncateText(st.GetMessage(), textLimit),
+		}
+		for _, d := range st.GetDetails() {
+			requestInfo, errorInfo := &errdetails.RequestInfo{}, &errdetails.ErrorInfo{}
+			switch {
+			case d.MessageIs(requestInfo) && d.UnmarshalTo(requestInfo) == nil:
+				bo.RequestIDs = append(bo.RequestIDs, requestInfo.GetRequestId())
+			case d.MessageIs(errorInfo) && d.UnmarshalTo(errorInfo) == nil:
+				bo.ErrorReasons = append(bo.ErrorReasons, errorInfo.GetDomain()+"/"+errorInfo.GetReason())
+			}
+		}
+		result = append(result, bo)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func sdkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == sdkModulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == sdkModulePath {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,99 @@
+package dcsdk
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fakeClickHouseOperationList struct {
+	clickhouse.UnimplementedOperationServiceServer
+	ops   []*dcv1.Operation
+	lists int
+}
+
+func (f *fakeClickHouseOperationList) List(ctx context.Context, req *clickhouse.ListOperationsRequest) (*clickhouse.ListOperationsResponse, error) {
+	f.lists++
+	return &clickhouse.ListOperationsResponse{Operations: f.ops}, nil
+}
+
+func TestSupportBundle(t *testing.T) {
+	since := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	st, err := status.New(codes.Internal, "disk quota exceeded").WithDetails(
+		&errdetails.RequestInfo{RequestId: "req-1"},
+		&errdetails.ErrorInfo{Domain: "clickhouse", Reason: "QUOTA"},
+	)
+	require.NoError(t, err)
+	ops := &fakeClickHouseOperationList{ops: []*dcv1.Operation{
+		{Id: "cho-old", CreateTime: timestamppb.New(since.Add(-time.Hour)), Error: st.Proto()},
+		{Id: "cho-ok", CreateTime: timestamppb.New(since.Add(time.Hour)), Status: dcv1.Operation_STATUS_DONE},
+		{Id: "cho-failed", CreateTime: timestamppb.New(since.Add(2 * time.Hour)), Status: dcv1.Operation_STATUS_DONE, Error: st.Proto()},
+	}}
+
+	const secretToken = "t1.very-secret-token"
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials: NewIAMTokenCredentials(secretToken),
+		Endpoint:    "https://api.example.com/",
+	}, func(s *grpc.Server) {
+		clickhouse.RegisterOperationServiceServer(s, ops)
+	})
+
+	bundle, err := sdk.SupportBundle(context.Background(), BundleSpec{ProjectID: "prj", Since: since})
+	require.NoError(t, err)
+
+	assert.Equal(t, "api.example.com:443", bundle.Config.Endpoint)
+	assert.Equal(t, "*dcsdk.IAMTokenCredentials", bundle.Config.CredentialsType)
+	assert.Contains(t, bundle.Services, "clickhouse")
+	require.Len(t, bundle.FailedOperations["clickhouse"], 1)
+	failed := bundle.FailedOperations["clickhouse"][0]
+	assert.Equal(t, "cho-failed", failed.ID)
+	assert.Equal(t, "Internal", failed.ErrorCode)
+	assert.Equal(t, []string{"req-1"}, failed.RequestIDs)
+	assert.Equal(t, []string{"clickhouse/QUOTA"}, failed.ErrorReasons)
+	// Services without operation listing on the fake server are reported, not fatal.
+	assert.Contains(t, bundle.Errors, "kafka")
+
+	blob, err := bundle.JSON()
+	require.NoError(t, err)
+	assert.NotContains(t, string(blob), secretToken)
+}
+
+func TestRedactEndpoint(t *testing.T) {
+	for in, want := range map[string]string{
+		"api.double.cloud:443":                   "api.double.cloud:443",
+		"user:pass@api.double.cloud:443":         "api.double.cloud:443",
+		"https://user:p/ss@api.double.cloud/x?y": "https://api.double.cloud",
+	} {
+		assert.Equal(t, want, redactEndpoint(in), in)
+	}
+}
+
+func TestFailedOperations_Truncated(t *testing.T) {
+	large, err := anypb.New(&errdetails.DebugInfo{Detail: strings.Repeat("d", 1<<20)})
+	require.NoError(t, err)
+	st, err := status.New(codes.Internal, strings.Repeat("m", 1<<20)).WithDetails(&errdetails.RequestInfo{RequestId: "req-1"})
+	require.NoError(t, err)
+	proto := st.Proto()
+	proto.Details = append(proto.Details, large)
+	ops := []*dcv1.Operation{{Id: "dtj1", Description: strings.Repeat("x", 1<<20), Error: proto}}
+
+	failed := failedOperations(ops, time.Time{}, 1, 100)
+	require.Len(t, failed, 1)
+	assert.Less(t, len(failed[0].Description), 256)
+	assert.Contains(t, failed[0].Description, "truncated, 1048576 bytes")
+	assert.Less(t, len(failed[0].ErrorMessage), 256)
+	assert.Equal(t, "Internal", failed[0].ErrorCode)
+	assert.Equal(t, []string{"req-1"}, failed[0].RequestIDs)
+}
@@ -0,0 +1,372 @@
+package dcsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+	"github.com/doublecloud/go-sdk/pkg/sdkerrors"
+)
+
+// StateVersion is the version of the State format written by Suspend. Resume restores it
+// and the versions before:
+//   - version 1 saves the waits by operation ID only, resumed as operations not polled yet;
+//   - version 2 adds the operations, encoded with operation.Operation.MarshalJSON, so that
+//     the resumed waits keep their origin and last state.
+const StateVersion = 2
+
+var (
+	// ErrSuspended is matched by the errors of tracked waits stopped by Suspend, and returned
+	// by GoWait while the SDK is suspended.
+	ErrSuspended = errors.New("dcsdk: suspended")
+	// ErrCheckpointUnsupported is returned by components that can't save their state.
+	// Suspend reports them in the State warnings.
+	ErrCheckpointUnsupported = errors.New("dcsdk: checkpoint not supported")
+	// ErrCheckpointVersionUnsupported is matched by the errors of Resume for states of a
+	// version newer than StateVersion, see CheckpointVersionError.
+	ErrCheckpointVersionUnsupported = operation.ErrCheckpointVersionUnsupported
+)
+
+// CheckpointVersionError is returned by Resume for states of an unknown version, Format
+// "state", and for waits whose operation is of an unknown version, Format "operation".
+type CheckpointVersionError = operation.CheckpointVersionError
+
+// State is the in-flight work of an SDK saved by Suspend, to be restored by Resume, e.g. after
+// a restart. It is serializable with encoding/json.
+type State struct {
+	Version int `json:"version"`
+	// Waits are the tracked waits of operations that were not done yet.
+	Waits []WaitCheckpoint `json:"waits,omitempty"`
+	// Components are the checkpoints of the registered components by name.
+	Components map[string]json.RawMessage `json:"components,omitempty"`
+	// Warnings describe the work that was not saved.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// WaitCheckpoint is a tracked wait saved by Suspend.
+type WaitCheckpoint struct {
+	Name        string `json:"name"`
+	OperationID string `json:"operation_id"`
+	// Operation is the operation of the wait encoded with MarshalJSON, since version 2. It
+	// is missing for the waits that didn't stop before the context of Suspend was done.
+	Operation json.RawMessage `json:"operation,omitempty"`
+}
+
+// Checkpointer is a background component, e.g. a watcher or a publisher, saved by Suspend
+// and restored by Resume, see RegisterCheckpointer.
+type Checkpointer interface {
+	// Checkpoint stops the component and returns its state. Components that can't save their
+	// state return ErrCheckpointUnsupported.
+	Checkpoint(ctx context.Context) (json.RawMessage, error)
+	// Restore restarts the component from the state returned by Checkpoint.
+	Restore(ctx context.Context, checkpoint json.RawMessage) error
+}
+
+// TrackedWaitKeyPrefix is the prefix of the keys of the checkpoints of the tracked waits in
+// Config.CheckpointStore, followed by the name of the wait.
+const TrackedWaitKeyPrefix = "waits/"
+
+// TrackedWait is a wait of an operation started by GoWait. Suspend stops it and saves the
+// operation ID, Resume starts it again.
+type TrackedWait struct {
+	name string
+	op   *operation.Operation
+	id   string
+	done chan struct{}
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped bool
+	err     error
+}
+
+// Name returns the name of the wait given to GoWait.
+func (w *TrackedWait) Name() string { return w.name }
+
+// Operation returns the waited operation. It must not be used until the wait is done.
+func (w *TrackedWait) Operation() *operation.Operation { return w.op }
+
+// Done is closed when the wait returns.
+func (w *TrackedWait) Done() <-chan struct{} { return w.done }
+
+// Err returns the error of the wait once it is done. Waits stopped by Suspend before the
+// operation was done fail with ErrSuspended.
+func (w *TrackedWait) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *TrackedWait) run(ctx context.Context, opts []grpc.CallOption) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w.mu.Lock()
+	w.cancel = cancel
+	stopped := w.stopped
+	w.mu.Unlock()
+
+	err := fmt.Errorf("%s: %w", w.op, ErrSuspended)
+	if !stopped {
+		err = w.op.Wait(ctx, opts...)
+	}
+	w.mu.Lock()
+	if w.stopped && err != nil && !w.op.Done() {
+		err = fmt.Errorf("%s: %w", w.op, ErrSuspended)
+	}
+	w.err = err
+	w.mu.Unlock()
+	close(w.done)
+}
+
+func (w *TrackedWait) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// suspendables are the tracked waits and the components saved by Suspend.
+type suspendables struct {
+	mu         sync.Mutex
+	suspended  bool
+	waits      map[string]*TrackedWait
+	components map[string]Checkpointer
+}
+
+func newSuspendables() *suspendables {
+	return &suspendables{waits: map[string]*TrackedWait{}, components: map[string]Checkpointer{}}
+}
+
+// GoWait waits for the operation in the background, so that Suspend saves the wait until the
+// operation is done. The name identifies the wait in the State and must be unique among the
+// running waits; finished waits stay available with TrackedWait until replaced. With
+// Config.CheckpointStore, the wait also saves its checkpoints there, see ResumeCheckpoints.
+func (sdk *SDK) GoWait(name string, op *operation.Operation, opts ...grpc.CallOption) (*TrackedWait, error) {
+	if conf := sdk.config(); conf.CheckpointStore != nil {
+		opts = append([]grpc.CallOption{operation.WithCheckpointStore(conf.CheckpointStore, TrackedWaitKeyPrefix+name, conf.CheckpointInterval)}, opts...)
+	}
+	s := sdk.suspendables
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.suspended {
+		return nil, fmt.Errorf("wait %q: %w", name, ErrSuspended)
+	}
+	if w, ok := s.waits[name]; ok {
+		select {
+		case <-w.done:
+		default:
+			return nil, fmt.Errorf("wait %q is already running", name)
+		}
+	}
+	w := &TrackedWait{name: name, op: op, id: op.Id(), done: make(chan struct{})}
+	if !sdk.tasks.goTask("wait "+name, func(ctx context.Context) { w.run(ctx, opts) }) {
+		return nil, fmt.Errorf("wait %q: SDK is shut down", name)
+	}
+	s.waits[name] = w
+	return w, nil
+}
+
+// TrackedWait returns the wait started by GoWait or Resume with the name.
+func (sdk *SDK) TrackedWait(name string) (*TrackedWait, bool) {
+	s := sdk.suspendables
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.waits[name]
+	return w, ok
+}
+
+// RegisterCheckpointer registers the component saved by Suspend and restored by Resume
+// under the name, replacing the component registered with the same name.
+func (sdk *SDK) RegisterCheckpointer(name string, c Checkpointer) {
+	s := sdk.suspendables
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.components[name] = c
+}
+
+// Suspend stops the tracked waits and the registered components and saves their state.
+// Components that fail to checkpoint, and waits that didn't stop before ctx is done, are
+// reported in the State warnings instead of failing the suspend: the waits are saved anyway,
+// since resuming a wait of a done operation only polls it once.
+// Until Resume, GoWait fails with ErrSuspended.
+func (sdk *SDK) Suspend(ctx context.Context) (*State, error) {
+	s := sdk.suspendables
+	s.mu.Lock()
+	if s.suspended {
+		s.mu.Unlock()
+		return nil, errors.New("dcsdk: already suspended")
+	}
+	s.suspended = true
+	waits := make([]*TrackedWait, 0, len(s.waits))
+	for _, w := range s.waits {
+		waits = append(waits, w)
+	}
+	components := make(map[string]Checkpointer, len(s.components))
+	for name, c := range s.components {
+		components[name] = c
+	}
+	s.mu.Unlock()
+
+	state := &State{Version: StateVersion}
+	sort.Slice(waits, func(i, j int) bool { return waits[i].name < waits[j].name })
+	for _, w := range waits {
+		w.stop()
+	}
+	for _, w := range waits {
+		cp := WaitCheckpoint{Name: w.name, OperationID: w.id}
+		select {
+		case <-w.done:
+			if !errors.Is(w.Err(), ErrSuspended) {
+				continue
+			}
+			// The operation is not used by the wait anymore.
+			data, err := json.Marshal(w.op)
+			if err != nil {
+				state.Warnings = append(state.Warnings, fmt.Sprintf("wait %q: operation not saved: %v", w.name, err))
+				break
+			}
+			cp.Operation = data
+		case <-ctx.Done():
+			state.Warnings = append(state.Warnings, fmt.Sprintf("wait %q: not stopped: %v", w.name, ctx.Err()))
+		}
+		state.Waits = append(state.Waits, cp)
+	}
+
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var checkpoint json.RawMessage
+		err := operation.SafeCall("checkpoint", func() (err error) {
+			checkpoint, err = components[name].Checkpoint(ctx)
+			return err
+		})
+		if err != nil {
+			state.Warnings = append(state.Warnings, fmt.Sprintf("component %q: %v", name, err))
+			continue
+		}
+		if state.Components == nil {
+			state.Components = map[string]json.RawMessage{}
+		}
+		state.Components[name] = checkpoint
+	}
+	return state, nil
+}
+
+// Resume restarts the waits and restores the components saved in the state, of StateVersion
+// or a version before; states of newer versions fail with *CheckpointVersionError. The
+// components must be registered with the same names before. The resumed waits use opts and are available
+// with TrackedWait. Failures to restore a component don't stop the others and are returned
+// together.
+func (sdk *SDK) Resume(ctx context.Context, state *State, opts ...grpc.CallOption) error {
+	if state.Version < 1 || state.Version > StateVersion {
+		return &CheckpointVersionError{Format: "state", Version: state.Version, MaxVersion: StateVersion}
+	}
+	s := sdk.suspendables
+	s.mu.Lock()
+	s.suspended = false
+	components := make(map[string]Checkpointer, len(s.components))
+	for name, c := range s.components {
+		components[name] = c
+	}
+	s.mu.Unlock()
+
+	var result *multierror.Error
+	for _, cp := range state.Waits {
+		op, err := sdk.resumeWaitOperation(cp)
+		if err == nil {
+			_, err = sdk.GoWait(cp.Name, op, opts...)
+		}
+		result = multierror.Append(result, sdkerrors.WithMessagef(err, "resume wait %q", cp.Name))
+	}
+	names := make([]string, 0, len(state.Components))
+	for name := range state.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c, ok := components[name]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("restore component %q: not registered", name))
+			continue
+		}
+		err := operation.SafeCall("restore", func() error { return c.Restore(ctx, state.Components[name]) })
+		result = multierror.Append(result, sdkerrors.WithMessagef(err, "restore component %q", name))
+	}
+	return result.ErrorOrNil()
+}
+
+// ErrNoCheckpointStore is returned by ResumeCheckpoints without Config.CheckpointStore.
+var ErrNoCheckpointStore = errors.New("dcsdk: no checkpoint store configured")
+
+// ResumeCheckpoints restarts the tracked waits saved in Config.CheckpointStore, e.g. by a
+// process that stopped before their operations were done, with the names they had. The
+// resumed waits use opts and are available with TrackedWait. Failures to resume a wait don't
+// stop the others and are returned together.
+func (sdk *SDK) ResumeCheckpoints(ctx context.Context, opts ...grpc.CallOption) error {
+	store := sdk.config().CheckpointStore
+	if store == nil {
+		return ErrNoCheckpointStore
+	}
+	var keys []string
+	err := operation.SafeCall("checkpoint store", func() (err error) {
+		keys, err = store.List(ctx, TrackedWaitKeyPrefix)
+		return err
+	})
+	if err != nil {
+		return sdkerrors.WithMessage(err, "list checkpoints")
+	}
+	var result *multierror.Error
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, TrackedWaitKeyPrefix)
+		var cp *operation.Checkpoint
+		err := operation.SafeCall("checkpoint store", func() (err error) {
+			cp, err = store.Load(ctx, key)
+			return err
+		})
+		if errors.Is(err, operation.ErrCheckpointNotFound) {
+			// The wait finished since the listing.
+			continue
+		}
+		var op *operation.Operation
+		if err == nil {
+			op, err = sdk.resumeWaitOperation(WaitCheckpoint{Name: name, OperationID: cp.OperationID, Operation: cp.State})
+		}
+		if err == nil {
+			_, err = sdk.GoWait(name, op, opts...)
+		}
+		result = multierror.Append(result, sdkerrors.WithMessagef(err, "resume wait %q", name))
+	}
+	return result.ErrorOrNil()
+}
+
+// resumeWaitOperation returns the operation of the saved wait, pending unless the wait
+// saved its operation.
+func (sdk *SDK) resumeWaitOperation(cp WaitCheckpoint) (*operation.Operation, error) {
+	if len(cp.Operation) == 0 {
+		// Version 1, or a wait that didn't stop.
+		return sdk.WrapOperation(&dcv1.Operation{Id: cp.OperationID, Status: dcv1.Operation_STATUS_PENDING}, nil)
+	}
+	op := &operation.Operation{}
+	if err := json.Unmarshal(cp.Operation, op); err != nil {
+		return nil, err
+	}
+	client, err := sdk.operationClient(op.Id())
+	if err != nil {
+		return nil, err
+	}
+	return sdk.withOperationDefaults(op.WithClient(client)), nil
+}
@@ -0,0 +1,289 @@
+package dcsdk
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+var updateCheckpoints = flag.Bool("update", false, "rewrite the state fixture of the current StateVersion")
+
+// releasedOperations keeps operations running until released.
+type releasedOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	released int32
+}
+
+func (s *releasedOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	if atomic.LoadInt32(&s.released) == 0 {
+		return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_RUNNING}, nil
+	}
+	return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_DONE}, nil
+}
+
+// cursorWatcher saves its cursor as the checkpoint.
+type cursorWatcher struct{ cursor string }
+
+func (w *cursorWatcher) Checkpoint(ctx context.Context) (json.RawMessage, error) {
+	return json.Marshal(w.cursor)
+}
+
+func (w *cursorWatcher) Restore(ctx context.Context, checkpoint json.RawMessage) error {
+	return json.Unmarshal(checkpoint, &w.cursor)
+}
+
+type volatileComponent struct{}
+
+func (volatileComponent) Checkpoint(ctx context.Context) (json.RawMessage, error) {
+	return nil, ErrCheckpointUnsupported
+}
+
+func (volatileComponent) Restore(ctx context.Context, checkpoint json.RawMessage) error { return nil }
+
+func TestSuspendResume(t *testing.T) {
+	srv := &releasedOperations{}
+	register := func(s *grpc.Server) { clickhouse.RegisterOperationServiceServer(s, srv) }
+	ctx := context.Background()
+
+	sdk := newTestSDK(t, register)
+	running, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	wait, err := sdk.GoWait("create-cluster", running)
+	require.NoError(t, err)
+	_, err = sdk.GoWait("create-cluster", running)
+	assert.EqualError(t, err, `wait "create-cluster" is already running`)
+	done, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho2", Status: dcv1.Operation_STATUS_DONE}, nil)
+	require.NoError(t, err)
+	finished, err := sdk.GoWait("delete-cluster", done)
+	require.NoError(t, err)
+	<-finished.Done()
+	sdk.RegisterCheckpointer("watcher", &cursorWatcher{cursor: "c42"})
+	sdk.RegisterCheckpointer("publisher", volatileComponent{})
+
+	suspendCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	state, err := sdk.Suspend(suspendCtx)
+	require.NoError(t, err)
+	<-wait.Done()
+	assert.ErrorIs(t, wait.Err(), ErrSuspended)
+	assert.NoError(t, finished.Err())
+	_, err = sdk.GoWait("late", running)
+	assert.ErrorIs(t, err, ErrSuspended)
+	_, err = sdk.Suspend(suspendCtx)
+	assert.EqualError(t, err, "dcsdk: already suspended")
+
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.Len(t, state.Waits, 1)
+	var saved operation.Operation
+	require.NoError(t, json.Unmarshal(state.Waits[0].Operation, &saved))
+	assert.Equal(t, "cho1", saved.Id(), "the operation of the wait is saved")
+	withoutOperations := *state
+	withoutOperations.Waits = []WaitCheckpoint{{Name: state.Waits[0].Name, OperationID: state.Waits[0].OperationID}}
+	stateData, err := json.Marshal(withoutOperations)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 2,
+		"waits": [{"name": "create-cluster", "operation_id": "cho1"}],
+		"components": {"watcher": "c42"},
+		"warnings": ["component \"publisher\": dcsdk: checkpoint not supported"]
+	}`, string(stateData))
+
+	// Restart: a new SDK with the components registered again resumes from the saved file.
+	var savedState State
+	require.NoError(t, json.Unmarshal(data, &savedState))
+	restarted := newTestSDK(t, register)
+	watcher := &cursorWatcher{}
+	restarted.RegisterCheckpointer("watcher", watcher)
+	atomic.StoreInt32(&srv.released, 1)
+	require.NoError(t, restarted.Resume(ctx, &savedState))
+	assert.Equal(t, "c42", watcher.cursor)
+
+	resumed, ok := restarted.TrackedWait("create-cluster")
+	require.True(t, ok)
+	<-resumed.Done()
+	require.NoError(t, resumed.Err())
+	assert.True(t, resumed.Operation().Ok())
+}
+
+func TestResume_Errors(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	ctx := context.Background()
+
+	err := sdk.Resume(ctx, &State{Version: StateVersion + 1})
+	var versionErr *CheckpointVersionError
+	require.ErrorAs(t, err, &versionErr)
+	assert.ErrorIs(t, err, ErrCheckpointVersionUnsupported)
+	assert.Equal(t, "state", versionErr.Format)
+	assert.EqualError(t, sdk.Resume(ctx, &State{}), "unsupported state checkpoint version 0, this SDK supports up to 2")
+
+	watcher := &cursorWatcher{}
+	sdk.RegisterCheckpointer("watcher", watcher)
+	err = sdk.Resume(ctx, &State{Version: StateVersion, Components: map[string]json.RawMessage{
+		"watcher": json.RawMessage(`"c7"`),
+		"gone":    json.RawMessage(`{}`),
+	}})
+	assert.ErrorContains(t, err, `restore component "gone": not registered`)
+	assert.Equal(t, "c7", watcher.cursor, "other components are restored")
+}
+
+// checkpointOperations serves the operation of the state fixtures, running until released.
+type checkpointOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	released int32
+}
+
+func checkpointProto() *dcv1.Operation {
+	return &dcv1.Operation{
+		Id:          "cho1a2b3c4d5e6f7g8h9",
+		ProjectId:   "prj1a2b3c4d5e6f7g8h",
+		Description: "Create cluster",
+		CreateTime:  timestamppb.New(time.Date(2023, 5, 12, 14, 30, 11, 0, time.UTC)),
+		Metadata:    map[string]string{"cluster_id": "chcl9x8w7v6u5t4s3r2q"},
+		Status:      dcv1.Operation_STATUS_RUNNING,
+		ResourceId:  "chcl9x8w7v6u5t4s3r2q",
+	}
+}
+
+func (s *checkpointOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	op := checkpointProto()
+	if atomic.LoadInt32(&s.released) != 0 {
+		op.Status = dcv1.Operation_STATUS_DONE
+	}
+	return op, nil
+}
+
+func stateFixture(version int) string {
+	return filepath.Join("testdata", "checkpoints", fmt.Sprintf("state_v%d.json", version))
+}
+
+// TestSuspend_CurrentVersion checks that the State of StateVersion is still the one of its
+// fixture: changing the format requires a new version, with its fixture written by -update,
+// keeping the fixtures of the versions before. Run with -update to write the fixture.
+func TestSuspend_CurrentVersion(t *testing.T) {
+	srv := &checkpointOperations{}
+	sdk := newTestSDK(t, func(s *grpc.Server) { clickhouse.RegisterOperationServiceServer(s, srv) })
+	op, err := sdk.WrapOperation(checkpointProto(), nil)
+	require.NoError(t, err)
+	_, err = sdk.GoWait("create-cluster", op.WithOrigin("/doublecloud.clickhouse.v1.ClusterService/Create", "prj1a2b3c4d5e6f7g8h/analytics"))
+	require.NoError(t, err)
+	sdk.RegisterCheckpointer("watcher", &cursorWatcher{cursor: "c42"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	state, err := sdk.Suspend(ctx)
+	require.NoError(t, err)
+	data, err := json.MarshalIndent(state, "", "  ")
+	require.NoError(t, err)
+
+	if *updateCheckpoints {
+		require.NoError(t, os.WriteFile(stateFixture(StateVersion), append(data, '\n'), 0o644))
+	}
+	want, err := os.ReadFile(stateFixture(StateVersion))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(data))
+}
+
+// TestResume_Versions checks that the fixtures of every version supported resume.
+func TestResume_Versions(t *testing.T) {
+	for v := 1; v <= StateVersion; v++ {
+		t.Run(fmt.Sprintf("v%d", v), func(t *testing.T) {
+			data, err := os.ReadFile(stateFixture(v))
+			require.NoError(t, err)
+			var state State
+			require.NoError(t, json.Unmarshal(data, &state))
+			assert.Equal(t, v, state.Version)
+
+			srv := &checkpointOperations{released: 1}
+			sdk := newTestSDK(t, func(s *grpc.Server) { clickhouse.RegisterOperationServiceServer(s, srv) })
+			watcher := &cursorWatcher{}
+			sdk.RegisterCheckpointer("watcher", watcher)
+			require.NoError(t, sdk.Resume(context.Background(), &state))
+			assert.Equal(t, "c42", watcher.cursor)
+
+			w, ok := sdk.TrackedWait("create-cluster")
+			require.True(t, ok)
+			<-w.Done()
+			require.NoError(t, w.Err())
+			op := w.Operation()
+			assert.True(t, op.Ok())
+			want := checkpointProto()
+			want.Status = dcv1.Operation_STATUS_DONE
+			assert.True(t, proto.Equal(want, op.Proto()), "got %v", op.Proto())
+			if v >= 2 {
+				method, resource := op.Origin()
+				assert.Equal(t, "/doublecloud.clickhouse.v1.ClusterService/Create", method, "the origin is kept since version 2")
+				assert.Equal(t, "prj1a2b3c4d5e6f7g8h/analytics", resource)
+			}
+		})
+	}
+}
+
+func TestResume_FutureOperationVersion(t *testing.T) {
+	sdk := newTestSDK(t, func(s *grpc.Server) {})
+	err := sdk.Resume(context.Background(), &State{Version: StateVersion, Waits: []WaitCheckpoint{{
+		Name:        "create-cluster",
+		OperationID: "cho1",
+		Operation:   json.RawMessage(`{"version": 99, "operation": {"id": "cho1"}}`),
+	}}})
+	assert.ErrorIs(t, err, ErrCheckpointVersionUnsupported)
+	_, ok := sdk.TrackedWait("create-cluster")
+	assert.False(t, ok, "the wait is not resumed from a misparsed operation")
+}
+
+func TestCheckpointStore_TrackedWaits(t *testing.T) {
+	srv := &releasedOperations{}
+	register := func(s *grpc.Server) { clickhouse.RegisterOperationServiceServer(s, srv) }
+	store := operation.NewMemoryCheckpointStore()
+	conf := Config{
+		Credentials:        NewIAMTokenCredentials("test-token"),
+		CheckpointStore:    store,
+		CheckpointInterval: time.Hour,
+		PollPolicy:         PollPolicy{Interval: time.Millisecond},
+	}
+	ctx := context.Background()
+
+	sdk := newTestSDKWithConfig(t, conf, register)
+	op, err := sdk.WrapOperation(&dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil)
+	require.NoError(t, err)
+	wait, err := sdk.GoWait("create-cluster", op)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return store.Saves(TrackedWaitKeyPrefix+"create-cluster") > 0 }, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, store.Saves(TrackedWaitKeyPrefix+"create-cluster"), "at most one checkpoint per interval")
+
+	// The process stops: the wait saves its last checkpoint.
+	require.NoError(t, sdk.Shutdown(ctx))
+	<-wait.Done()
+	assert.Error(t, wait.Err())
+	assert.Equal(t, 2, store.Saves(TrackedWaitKeyPrefix+"create-cluster"))
+
+	restarted := newTestSDKWithConfig(t, conf, register)
+	atomic.StoreInt32(&srv.released, 1)
+	require.NoError(t, restarted.ResumeCheckpoints(ctx))
+	resumed, ok := restarted.TrackedWait("create-cluster")
+	require.True(t, ok)
+	<-resumed.Done()
+	require.NoError(t, resumed.Err())
+	assert.True(t, resumed.Operation().Ok())
+	keys, err := store.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, keys, "the checkpoint of the finished wait is deleted")
+
+	assert.ErrorIs(t, newTestSDK(t, register).ResumeCheckpoints(ctx), ErrNoCheckpointStore)
+}
@@ -0,0 +1,105 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// TaskInfo describes a background task run by the SDK.
+type TaskInfo struct {
+	Name      string
+	StartedAt time.Time
+}
+
+// backgroundTasks supervises goroutines owned by the SDK. Every background goroutine
+// of the SDK must be started with goTask, not a plain go statement, so that Shutdown stops
+// it.
+type backgroundTasks struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	nextID  int
+	running map[int]TaskInfo
+}
+
+func newBackgroundTasks() *backgroundTasks {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundTasks{ctx: ctx, cancel: cancel, running: map[int]TaskInfo{}}
+}
+
+// goTask runs fn in a new goroutine. The context passed to fn is cancelled on shutdown.
+// A panic in fn is recovered and logged. After shutdown it doesn't start fn and returns false.
+func (t *backgroundTasks) goTask(name string, fn func(ctx context.Context)) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	id := t.nextID
+	t.nextID++
+	t.running[id] = TaskInfo{Name: name, StartedAt: now()}
+	t.wg.Add(1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				grpclog.Warningf("dcsdk: background task %q panicked: %v\n%s", name, r, debug.Stack())
+			}
+			t.mu.Lock()
+			delete(t.running, id)
+			t.mu.Unlock()
+			t.wg.Done()
+		}()
+		fn(t.ctx)
+	}()
+	return true
+}
+
+// list returns the running tasks, oldest first.
+func (t *backgroundTasks) list() []TaskInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]int, 0, len(t.running))
+	for id := range t.running {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	tasks := make([]TaskInfo, len(ids))
+	for i, id := range ids {
+		tasks[i] = t.running[id]
+	}
+	return tasks
+}
+
+// shutdown cancels all tasks and waits for them to return until ctx is done.
+func (t *backgroundTasks) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	t.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background tasks still running %v: %w", t.list(), ctx.Err())
+	}
+}
+
+// BackgroundTasks lists background tasks currently run by the SDK, for debugging.
+func (sdk *SDK) BackgroundTasks() []TaskInfo {
+	return sdk.tasks.list()
+}
@@ -0,0 +1,60 @@
+package dcsdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestBackgroundTasks_Shutdown(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	sdk, err := Build(context.Background(), Config{Credentials: NewIAMTokenCredentials("test-token")})
+	require.NoError(t, err)
+	started := make(chan struct{}, 2)
+	for _, name := range []string{"first", "second"} {
+		require.True(t, sdk.tasks.goTask(name, func(ctx context.Context) {
+			started <- struct{}{}
+			<-ctx.Done()
+		}))
+	}
+	<-started
+	<-started
+	tasks := sdk.BackgroundTasks()
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "first", tasks[0].Name)
+	assert.Equal(t, "second", tasks[1].Name)
+
+	require.NoError(t, sdk.Shutdown(context.Background()))
+	assert.Empty(t, sdk.BackgroundTasks())
+	assert.False(t, sdk.tasks.goTask("late", func(ctx context.Context) { t.Error("started after shutdown") }))
+}
+
+func TestBackgroundTasks_Panic(t *testing.T) {
+	tasks := newBackgroundTasks()
+	done := make(chan struct{})
+	tasks.goTask("panicking", func(ctx context.Context) {
+		defer close(done)
+		panic("boom")
+	})
+	<-done
+	require.NoError(t, tasks.shutdown(context.Background()))
+	assert.Empty(t, tasks.list())
+}
+
+func TestBackgroundTasks_ShutdownDeadline(t *testing.T) {
+	tasks := newBackgroundTasks()
+	release := make(chan struct{})
+	defer close(release)
+	tasks.goTask("stuck", func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := tasks.shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "stuck")
+}
@@ -0,0 +1,162 @@
+package dcsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/pkg/paging"
+	"github.com/doublecloud/go-sdk/pkg/version"
+)
+
+// VersionCacheTTL is how long the version catalogs listed by Versions are cached by the SDK.
+const VersionCacheTTL = time.Hour
+
+// VersionInfo is a version accepted by a service for its clusters.
+type VersionInfo struct {
+	// ID is the version to set in cluster specs, e.g. "23.8".
+	ID   string
+	Name string
+	// Deprecated versions are still accepted, but not offered for new clusters.
+	Deprecated bool
+	// UpdatableTo are the IDs of the versions the clusters of this version can be upgraded to.
+	UpdatableTo []string
+}
+
+// Parse parses the version ID, falling back to the name.
+func (v VersionInfo) Parse() (version.Version, error) {
+	parsed, err := version.Parse(v.ID)
+	if err != nil {
+		if byName, nameErr := version.Parse(v.Name); nameErr == nil {
+			return byName, nil
+		}
+	}
+	return parsed, err
+}
+
+// Versions lists the versions of a service, see SDK.Versions.
+type Versions struct {
+	sdk  *SDK
+	kind ServiceKind
+}
+
+// Versions returns the version catalog of the service: ClickHouseServiceID or KafkaServiceID.
+// Catalogs are cached by the SDK instance for VersionCacheTTL.
+func (sdk *SDK) Versions(kind ServiceKind) *Versions {
+	return &Versions{sdk: sdk, kind: kind}
+}
+
+// List returns the versions of the service in the order of the catalog.
+func (v *Versions) List(ctx context.Context, opts ...grpc.CallOption) ([]VersionInfo, error) {
+	return v.sdk.versions.get(ctx, v.kind, func(ctx context.Context) ([]VersionInfo, error) {
+		return v.list(ctx, opts)
+	})
+}
+
+// Latest returns the highest version that is not deprecated and matches the constraint,
+// e.g. "24.x" or ">=23.3, <24", see version.ParseConstraint.
+func (v *Versions) Latest(ctx context.Context, constraint string, opts ...grpc.CallOption) (VersionInfo, error) {
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	versions, err := v.List(ctx, opts...)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	var latest VersionInfo
+	var latestVersion version.Version
+	found := false
+	for _, info := range versions {
+		if info.Deprecated {
+			continue
+		}
+		parsed, err := info.Parse()
+		if err != nil || !c.Match(parsed) {
+			continue
+		}
+		if !found || parsed.Compare(latestVersion) > 0 {
+			latest, latestVersion, found = info, parsed, true
+		}
+	}
+	if !found {
+		return VersionInfo{}, fmt.Errorf("no %s version matches %q", v.kind, constraint)
+	}
+	return latest, nil
+}
+
+func (v *Versions) list(ctx context.Context, opts []grpc.CallOption) ([]VersionInfo, error) {
+	switch v.kind {
+	case ClickHouseServiceID:
+		versions, err := paging.New(ctx, func(ctx context.Context, p *dcv1.Paging) ([]*clickhouse.Version, *dcv1.NextPage, error) {
+			resp, err := v.sdk.ClickHouse().Version().List(ctx, &clickhouse.ListVersionsRequest{Paging: p}, opts...)
+			return resp.GetVersions(), resp.GetNextPage(), err
+		}).TakeAll()
+		infos := make([]VersionInfo, len(versions))
+		for i, ver := range versions {
+			infos[i] = VersionInfo{ID: ver.GetId(), Name: ver.GetName(), Deprecated: ver.GetDeprecated(), UpdatableTo: ver.GetUpdatableTo()}
+		}
+		return infos, err
+	case KafkaServiceID:
+		versions, err := paging.New(ctx, func(ctx context.Context, p *dcv1.Paging) ([]*kafka.Version, *dcv1.NextPage, error) {
+			resp, err := v.sdk.Kafka().Version().List(ctx, &kafka.ListVersionsRequest{Paging: p}, opts...)
+			return resp.GetVersions(), resp.GetNextPage(), err
+		}).TakeAll()
+		infos := make([]VersionInfo, len(versions))
+		for i, ver := range versions {
+			infos[i] = VersionInfo{ID: ver.GetId(), Name: ver.GetName(), Deprecated: ver.GetDeprecated(), UpdatableTo: ver.GetUpdatableTo()}
+		}
+		return infos, err
+	default:
+		return nil, fmt.Errorf("service %q has no version catalog", v.kind)
+	}
+}
+
+type versionCacheEntry struct {
+	versions []VersionInfo
+	expires  time.Time
+}
+
+// versionCache caches version catalogs by service. Concurrent misses of a service share
+// a single listing.
+type versionCache struct {
+	now   func() time.Time
+	fetch singleflight.Group
+
+	mu      sync.Mutex
+	entries map[ServiceKind]versionCacheEntry
+}
+
+func newVersionCache() *versionCache {
+	return &versionCache{now: now, entries: map[ServiceKind]versionCacheEntry{}}
+}
+
+func (c *versionCache) get(ctx context.Context, kind ServiceKind, list func(ctx context.Context) ([]VersionInfo, error)) ([]VersionInfo, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[kind]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return append([]VersionInfo(nil), entry.versions...), nil
+	}
+	versions, err, _ := c.fetch.Do(string(kind), func() (interface{}, error) {
+		versions, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.entries[kind] = versionCacheEntry{versions: versions, expires: c.now().Add(VersionCacheTTL)}
+		c.mu.Unlock()
+		return versions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]VersionInfo(nil), versions.([]VersionInfo)...), nil
+}
@@ -0,0 +1,87 @@
+package dcsdk
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	"github.com/doublecloud/go-genproto/doublecloud/kafka/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakeClickHouseVersions serves the catalog in pages of two, counting the calls.
+type fakeClickHouseVersions struct {
+	clickhouse.UnimplementedVersionServiceServer
+	calls int32
+}
+
+func (s *fakeClickHouseVersions) List(ctx context.Context, req *clickhouse.ListVersionsRequest) (*clickhouse.ListVersionsResponse, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if req.GetPaging().GetPageToken() == "" {
+		return &clickhouse.ListVersionsResponse{
+			Versions: []*clickhouse.Version{
+				{Id: "22.8", Name: "22.8 LTS", Deprecated: true, UpdatableTo: []string{"23.3", "23.8"}},
+				{Id: "23.3", Name: "23.3 LTS", UpdatableTo: []string{"23.8"}},
+			},
+			NextPage: &dcv1.NextPage{Token: "2"},
+		}, nil
+	}
+	return &clickhouse.ListVersionsResponse{Versions: []*clickhouse.Version{
+		{Id: "23.8", Name: "23.8 LTS"},
+		{Id: "23.12", Name: "23.12"},
+		{Id: "24.1", Name: "24.1", Deprecated: true},
+	}}, nil
+}
+
+type fakeKafkaVersions struct {
+	kafka.UnimplementedVersionServiceServer
+}
+
+func (fakeKafkaVersions) List(ctx context.Context, req *kafka.ListVersionsRequest) (*kafka.ListVersionsResponse, error) {
+	return &kafka.ListVersionsResponse{Versions: []*kafka.Version{{Id: "3.4", Name: "Kafka 3.4"}, {Id: "3.5", Name: "Kafka 3.5"}}}, nil
+}
+
+func TestVersions(t *testing.T) {
+	ch := &fakeClickHouseVersions{}
+	sdk := newTestSDK(t, func(s *grpc.Server) {
+		clickhouse.RegisterVersionServiceServer(s, ch)
+		kafka.RegisterVersionServiceServer(s, fakeKafkaVersions{})
+	})
+	ctx := context.Background()
+
+	versions, err := sdk.Versions(ClickHouseServiceID).List(ctx)
+	require.NoError(t, err)
+	require.Len(t, versions, 5)
+	assert.Equal(t, VersionInfo{ID: "22.8", Name: "22.8 LTS", Deprecated: true, UpdatableTo: []string{"23.3", "23.8"}}, versions[0])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&ch.calls), "all pages are listed")
+
+	latest, err := sdk.Versions(ClickHouseServiceID).Latest(ctx, "23.x")
+	require.NoError(t, err)
+	assert.Equal(t, "23.12", latest.ID, "versions are compared numerically")
+	latest, err = sdk.Versions(ClickHouseServiceID).Latest(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "23.12", latest.ID, "deprecated versions are skipped")
+	_, err = sdk.Versions(ClickHouseServiceID).Latest(ctx, "24.x")
+	assert.EqualError(t, err, `no clickhouse version matches "24.x"`)
+	_, err = sdk.Versions(ClickHouseServiceID).Latest(ctx, "24.x.1")
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&ch.calls), "the catalog is cached by the SDK")
+
+	clock := time.Now().Add(VersionCacheTTL)
+	sdk.versions.now = func() time.Time { return clock }
+	_, err = sdk.Versions(ClickHouseServiceID).List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&ch.calls), "expired catalogs are listed again")
+
+	latest, err = sdk.Versions(KafkaServiceID).Latest(ctx, "3.x")
+	require.NoError(t, err)
+	assert.Equal(t, "3.5", latest.ID)
+
+	_, err = sdk.Versions(TransferServiceID).List(ctx)
+	assert.EqualError(t, err, `service "transfer" has no version catalog`)
+}
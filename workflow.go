@@ -0,0 +1,151 @@
+package dcsdk
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/doublecloud/go-sdk/operation"
+)
+
+// WorkflowStats are the API calls and waits made within a workflow, see BeginWorkflow.
+type WorkflowStats struct {
+	Name string
+	// Calls counts the calls sent to the API by method, e.g.
+	// "/doublecloud.clickhouse.v1.ClusterService/Create", or OtherMethod for methods missing
+	// in MethodCatalog. Responses served by the read cache are not counted, operation polls are.
+	Calls map[string]int
+	// Retries counts the attempts of the calls after the first one.
+	Retries int
+	// Polls counts the calls getting operations.
+	Polls int
+	// WaitTime is the total wall time of the operation waits. Concurrent waits add up.
+	WaitTime time.Duration
+	// Duration is the time from BeginWorkflow until the workflow context was done, or
+	// until now for running workflows.
+	Duration time.Duration
+}
+
+type workflow struct {
+	name    string
+	parent  *workflow
+	started time.Time
+
+	mu    sync.Mutex
+	stats WorkflowStats
+	ended time.Time
+}
+
+// update applies f to the stats of the workflow and of all its parents.
+func (w *workflow) update(f func(s *WorkflowStats)) {
+	for ; w != nil; w = w.parent {
+		w.mu.Lock()
+		f(&w.stats)
+		w.mu.Unlock()
+	}
+}
+
+func (w *workflow) snapshot() WorkflowStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.stats
+	s.Calls = make(map[string]int, len(w.stats.Calls))
+	for method, n := range w.stats.Calls {
+		s.Calls[method] = n
+	}
+	if w.ended.IsZero() {
+		s.Duration = now().Sub(w.started)
+	} else {
+		s.Duration = w.ended.Sub(w.started)
+	}
+	return s
+}
+
+// ObserveWait implements operation.WaitObserver.
+func (w *workflow) ObserveWait(o *operation.Operation, elapsed time.Duration, err error) {
+	w.update(func(s *WorkflowStats) { s.WaitTime += elapsed })
+}
+
+type workflowKey struct{}
+
+func workflowFromContext(ctx context.Context) *workflow {
+	w, _ := ctx.Value(workflowKey{}).(*workflow)
+	return w
+}
+
+// BeginWorkflow returns a context accounting the API calls and the operation waits made with
+// it to the named workflow, see WorkflowStats. A workflow begun within another one is also
+// accounted to the outer workflow. When ctx is done, or the SDK is shut down, the stats of
+// the workflow are passed to Config.OnWorkflowEnd, if set.
+func (sdk *SDK) BeginWorkflow(ctx context.Context, name string) context.Context {
+	w := &workflow{
+		name:    name,
+		parent:  workflowFromContext(ctx),
+		started: now(),
+		stats:   WorkflowStats{Name: name, Calls: map[string]int{}},
+	}
+	done := ctx.Done()
+	sdk.tasks.goTask("workflow "+name, func(shutdown context.Context) {
+		select {
+		case <-done:
+		case <-shutdown.Done():
+		}
+		w.mu.Lock()
+		w.ended = now()
+		w.mu.Unlock()
+		if onEnd := sdk.config().OnWorkflowEnd; onEnd != nil {
+			stats := w.snapshot()
+			_ = operation.SafeCall("workflow end", func() error {
+				onEnd(stats)
+				return nil
+			})
+		}
+	})
+	ctx = context.WithValue(ctx, workflowKey{}, w)
+	return operation.ContextWithWaitObserver(ctx, w)
+}
+
+// WorkflowStats returns the stats of the innermost workflow of ctx so far.
+// It reports false if ctx is not within a workflow.
+func (sdk *SDK) WorkflowStats(ctx context.Context) (WorkflowStats, bool) {
+	w := workflowFromContext(ctx)
+	if w == nil {
+		return WorkflowStats{}, false
+	}
+	return w.snapshot(), true
+}
+
+type workflowAttemptsKey struct{}
+
+// interceptWorkflowCalls counts the calls of workflows. It runs before the retry interceptor,
+// which calls interceptWorkflowAttempts for every attempt.
+func interceptWorkflowCalls(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	w := workflowFromContext(ctx)
+	if w == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	var attempts int
+	err := invoker(context.WithValue(ctx, workflowAttemptsKey{}, &attempts), method, req, reply, cc, opts...)
+	poll := strings.Contains(method, "OperationService/") && strings.HasSuffix(method, "/Get")
+	_, methodLabel := methodLabels(method)
+	w.update(func(s *WorkflowStats) {
+		s.Calls[methodLabel]++
+		if attempts > 1 {
+			s.Retries += attempts - 1
+		}
+		if poll {
+			s.Polls++
+		}
+	})
+	return err
+}
+
+func interceptWorkflowAttempts(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if attempts, ok := ctx.Value(workflowAttemptsKey{}).(*int); ok {
+		*attempts++
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,103 @@
+package dcsdk
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doublecloud/go-genproto/doublecloud/clickhouse/v1"
+	dcv1 "github.com/doublecloud/go-genproto/doublecloud/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/doublecloud/go-sdk/pkg/retry"
+)
+
+// flakyClusters fails the first Create with Unavailable.
+type flakyClusters struct {
+	clickhouse.UnimplementedClusterServiceServer
+	creates int32
+}
+
+func (s *flakyClusters) Create(ctx context.Context, req *clickhouse.CreateClusterRequest) (*dcv1.Operation, error) {
+	if atomic.AddInt32(&s.creates, 1) == 1 {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &dcv1.Operation{Id: "cho1", Status: dcv1.Operation_STATUS_PENDING}, nil
+}
+
+func (s *flakyClusters) Get(ctx context.Context, req *clickhouse.GetClusterRequest) (*clickhouse.Cluster, error) {
+	return &clickhouse.Cluster{Id: req.ClusterId}, nil
+}
+
+// slowOperations reports operations running for the first poll.
+type slowOperations struct {
+	clickhouse.UnimplementedOperationServiceServer
+	polls int32
+}
+
+func (s *slowOperations) Get(ctx context.Context, req *clickhouse.GetOperationRequest) (*dcv1.Operation, error) {
+	if atomic.AddInt32(&s.polls, 1) == 1 {
+		return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_RUNNING}, nil
+	}
+	return &dcv1.Operation{Id: req.OperationId, Status: dcv1.Operation_STATUS_DONE}, nil
+}
+
+func TestWorkflow(t *testing.T) {
+	ended := make(chan WorkflowStats, 2)
+	sdk := newTestSDKWithConfig(t, Config{
+		Credentials:   NewIAMTokenCredentials("test-token"),
+		Retry:         retry.Config{MaxAttempts: 3, Backoff: time.Millisecond},
+		OnWorkflowEnd: func(s WorkflowStats) { ended <- s },
+	}, func(s *grpc.Server) {
+		clickhouse.RegisterClusterServiceServer(s, &flakyClusters{})
+		clickhouse.RegisterOperationServiceServer(s, &slowOperations{})
+	})
+	const (
+		createMethod = "/doublecloud.clickhouse.v1.ClusterService/Create"
+		getMethod    = "/doublecloud.clickhouse.v1.ClusterService/Get"
+		pollMethod   = "/doublecloud.clickhouse.v1.OperationService/Get"
+	)
+
+	stackCtx, endStack := context.WithCancel(context.Background())
+	defer endStack()
+	stackCtx = sdk.BeginWorkflow(stackCtx, "create-stack")
+	_, err := sdk.ClickHouse().Cluster().Get(stackCtx, &clickhouse.GetClusterRequest{ClusterId: "chc1"})
+	require.NoError(t, err)
+
+	clusterCtx, endCluster := context.WithCancel(stackCtx)
+	clusterCtx = sdk.BeginWorkflow(clusterCtx, "create-clickhouse")
+	op, err := sdk.WrapOperation(sdk.ClickHouse().Cluster().Create(clusterCtx, &clickhouse.CreateClusterRequest{Name: "events"}))
+	require.NoError(t, err)
+	require.NoError(t, op.WaitInterval(clusterCtx, time.Millisecond))
+
+	cluster, ok := sdk.WorkflowStats(clusterCtx)
+	require.True(t, ok)
+	assert.Equal(t, "create-clickhouse", cluster.Name)
+	assert.Equal(t, map[string]int{createMethod: 1, pollMethod: 2}, cluster.Calls)
+	assert.Equal(t, 1, cluster.Retries)
+	assert.Equal(t, 2, cluster.Polls)
+	assert.Positive(t, cluster.WaitTime)
+
+	stack, ok := sdk.WorkflowStats(stackCtx)
+	require.True(t, ok)
+	assert.Equal(t, map[string]int{getMethod: 1, createMethod: 1, pollMethod: 2}, stack.Calls, "nested workflows roll up")
+	assert.Equal(t, 1, stack.Retries)
+	assert.Equal(t, 2, stack.Polls)
+	assert.Equal(t, cluster.WaitTime, stack.WaitTime)
+	assert.GreaterOrEqual(t, stack.Duration, cluster.Duration)
+
+	endCluster()
+	assert.Equal(t, "create-clickhouse", (<-ended).Name)
+	endStack()
+	final := <-ended
+	assert.Equal(t, "create-stack", final.Name)
+	assert.Equal(t, 4, final.Calls[getMethod]+final.Calls[createMethod]+final.Calls[pollMethod])
+
+	_, ok = sdk.WorkflowStats(context.Background())
+	assert.False(t, ok)
+}